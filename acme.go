@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var acmeHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+// acmePollInterval and acmePollAttempts limit how long authorizations and orders are waited for.
+var acmePollInterval = 2 * time.Second
+var acmePollAttempts = 30
+
+type acmeDirectory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+type acmeOrder struct {
+	Status         string       `json:"status"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate"`
+	Error          *acmeProblem `json:"error"`
+}
+
+type acmeAuthorization struct {
+	Status     string `json:"status"`
+	Identifier struct {
+		Value string `json:"value"`
+	} `json:"identifier"`
+	Challenges []struct {
+		Type  string       `json:"type"`
+		Url   string       `json:"url"`
+		Token string       `json:"token"`
+		Error *acmeProblem `json:"error"`
+	} `json:"challenges"`
+}
+
+// acmeClient obtains certificates from an ACME v2 server (e.g. Let's Encrypt) through HTTP-01 challenges. The key
+// authorizations are stored in acmeChallenges so the proxy answers the challenges itself.
+type acmeClient struct {
+	directory acmeDirectory
+	key       *ecdsa.PrivateKey
+	kid       string
+	nonce     string
+}
+
+// newAcmeClient registers the account of the key with the server behind the directory URL. Servers return the
+// existing account if the key is already registered.
+func newAcmeClient(directoryUrl, email string, key *ecdsa.PrivateKey) (*acmeClient, error) {
+	c := &acmeClient{key: key}
+	resp, err := acmeHttpClient.Get(directoryUrl)
+	if err != nil {
+		return nil, fmt.Errorf("Could not fetch the ACME directory %s\n%s", directoryUrl, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Could not fetch the ACME directory %s\nThe server responded with the status %d", directoryUrl, resp.StatusCode)
+	} else if err := json.NewDecoder(resp.Body).Decode(&c.directory); err != nil {
+		return nil, fmt.Errorf("Could not parse the ACME directory %s\n%s", directoryUrl, err.Error())
+	}
+	account := map[string]interface{}{"termsOfServiceAgreed": true}
+	if len(email) > 0 {
+		account["contact"] = []string{"mailto:" + email}
+	}
+	_, header, err := c.post(c.directory.NewAccount, account, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Could not register the ACME account\n%s", err.Error())
+	}
+	c.kid = header.Get("Location")
+	return c, nil
+}
+
+// Obtain orders a certificate for the domains and returns the certificate chain followed by its private key.
+func (c *acmeClient) Obtain(domains []string) (string, error) {
+	identifiers := []map[string]string{}
+	for _, domain := range domains {
+		identifiers = append(identifiers, map[string]string{"type": "dns", "value": domain})
+	}
+	order := acmeOrder{}
+	_, header, err := c.post(c.directory.NewOrder, map[string]interface{}{"identifiers": identifiers}, &order)
+	if err != nil {
+		return "", fmt.Errorf("Could not create the order\n%s", err.Error())
+	}
+	orderUrl := header.Get("Location")
+	for _, authzUrl := range order.Authorizations {
+		if err := c.authorize(authzUrl); err != nil {
+			return "", err
+		}
+	}
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, certKey)
+	if err != nil {
+		return "", err
+	}
+	if _, _, err := c.post(order.Finalize, map[string]string{"csr": acmeEncode(csr)}, &order); err != nil {
+		return "", fmt.Errorf("Could not finalize the order\n%s", err.Error())
+	}
+	for i := 0; order.Status != "valid"; i++ {
+		if order.Status == "invalid" || i >= acmePollAttempts {
+			return "", fmt.Errorf("The order of %s is %s%s", strings.Join(domains, ", "), order.Status, order.Error)
+		}
+		time.Sleep(acmePollInterval)
+		if _, _, err := c.post(orderUrl, nil, &order); err != nil {
+			return "", err
+		}
+	}
+	chain, _, err := c.post(order.Certificate, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("Could not download the certificate\n%s", err.Error())
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(certKey)
+	return string(chain) + string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})), nil
+}
+
+// authorize answers the HTTP-01 challenge of the authorization and waits until the server validates it.
+func (c *acmeClient) authorize(authzUrl string) error {
+	authz := acmeAuthorization{}
+	if _, _, err := c.post(authzUrl, nil, &authz); err != nil {
+		return err
+	} else if authz.Status == "valid" {
+		return nil
+	}
+	challengeUrl := ""
+	for _, challenge := range authz.Challenges {
+		if challenge.Type == "http-01" {
+			challengeUrl = challenge.Url
+			acmeChallenges.Put(challenge.Token, challenge.Token+"."+c.thumbprint(), acmeChallengeTTL)
+			defer acmeChallenges.Delete(challenge.Token)
+		}
+	}
+	if len(challengeUrl) == 0 {
+		return fmt.Errorf("The server did not offer an HTTP-01 challenge for %s", authz.Identifier.Value)
+	}
+	if _, _, err := c.post(challengeUrl, map[string]string{}, nil); err != nil {
+		return fmt.Errorf("Could not start the challenge for %s\n%s", authz.Identifier.Value, err.Error())
+	}
+	for i := 0; authz.Status != "valid"; i++ {
+		if authz.Status == "invalid" || i >= acmePollAttempts {
+			detail := ""
+			for _, challenge := range authz.Challenges {
+				if challenge.Error != nil {
+					detail = challenge.Error.String()
+				}
+			}
+			return fmt.Errorf("The authorization of %s is %s%s", authz.Identifier.Value, authz.Status, detail)
+		}
+		time.Sleep(acmePollInterval)
+		if _, _, err := c.post(authzUrl, nil, &authz); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// post sends a JWS signed request. A nil payload sends a POST-as-GET request. Requests rejected because of a stale
+// nonce are retried once.
+func (c *acmeClient) post(url string, payload, out interface{}) ([]byte, http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		body, header, problem, err := c.send(url, payload)
+		if err != nil {
+			return nil, nil, err
+		} else if problem != nil && problem.Type == "urn:ietf:params:acme:error:badNonce" && attempt == 0 {
+			continue
+		} else if problem != nil {
+			return nil, nil, fmt.Errorf("%s", problem.Detail)
+		}
+		if out != nil {
+			if err := json.Unmarshal(body, out); err != nil {
+				return nil, nil, fmt.Errorf("Could not parse the response from %s\n%s", url, err.Error())
+			}
+		}
+		return body, header, nil
+	}
+}
+
+func (c *acmeClient) send(url string, payload interface{}) ([]byte, http.Header, *acmeProblem, error) {
+	if len(c.nonce) == 0 {
+		resp, err := acmeHttpClient.Head(c.directory.NewNonce)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		resp.Body.Close()
+		c.nonce = resp.Header.Get("Replay-Nonce")
+	}
+	js, err := c.sign(url, payload)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	resp, err := acmeHttpClient.Post(url, "application/jose+json", bytes.NewReader(js))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer resp.Body.Close()
+	c.nonce = resp.Header.Get("Replay-Nonce")
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, nil, err
+	} else if resp.StatusCode >= 400 {
+		problem := &acmeProblem{}
+		if err := json.Unmarshal(body, problem); err != nil || len(problem.Type) == 0 {
+			problem = &acmeProblem{Detail: fmt.Sprintf("%s responded with the status %d", url, resp.StatusCode)}
+		}
+		return nil, nil, problem, nil
+	}
+	return body, resp.Header, nil, nil
+}
+
+// sign returns the payload as a flattened JWS. The account key is identified by its URL once the account exists.
+func (c *acmeClient) sign(url string, payload interface{}) ([]byte, error) {
+	protected := map[string]interface{}{"alg": "ES256", "nonce": c.nonce, "url": url}
+	if len(c.kid) > 0 {
+		protected["kid"] = c.kid
+	} else {
+		protected["jwk"] = c.jwk()
+	}
+	protectedJs, _ := json.Marshal(protected)
+	payloadJs := []byte{}
+	if payload != nil {
+		payloadJs, _ = json.Marshal(payload)
+	}
+	data := acmeEncode(protectedJs) + "." + acmeEncode(payloadJs)
+	hash := sha256.Sum256([]byte(data))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := append(acmePad(r, 32), acmePad(s, 32)...)
+	return json.Marshal(map[string]string{
+		"protected": acmeEncode(protectedJs),
+		"payload":   acmeEncode(payloadJs),
+		"signature": acmeEncode(signature),
+	})
+}
+
+func (c *acmeClient) jwk() map[string]string {
+	return map[string]string{
+		"crv": "P-256",
+		"kty": "EC",
+		"x":   acmeEncode(acmePad(c.key.X, 32)),
+		"y":   acmeEncode(acmePad(c.key.Y, 32)),
+	}
+}
+
+// thumbprint returns the JWK thumbprint (RFC 7638) of the account key used in key authorizations.
+func (c *acmeClient) thumbprint() string {
+	jwk := c.jwk()
+	hash := sha256.Sum256([]byte(fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, jwk["crv"], jwk["kty"], jwk["x"], jwk["y"])))
+	return acmeEncode(hash[:])
+}
+
+func (p *acmeProblem) String() string {
+	if p == nil {
+		return ""
+	}
+	return "\n" + p.Detail
+}
+
+func acmeEncode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func acmePad(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
@@ -0,0 +1,219 @@
+// +build !integration
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type AcmeTestSuite struct {
+	suite.Suite
+	server      *httptest.Server
+	accountKey  *ecdsa.PublicKey
+	kids        []string
+	keyAuths    []string
+	authzStatus string
+	csrDomains  []string
+	badNonces   int
+}
+
+func (s *AcmeTestSuite) SetupTest() {
+	s.accountKey = nil
+	s.kids = []string{}
+	s.keyAuths = []string{}
+	s.authzStatus = "pending"
+	s.csrDomains = nil
+	s.badNonces = 0
+	acmePollInterval = time.Millisecond
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveAcme))
+}
+
+func (s *AcmeTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// serveAcme imitates the parts of an ACME server used to obtain a certificate. The signatures of all requests are
+// verified with the key of the account.
+func (s *AcmeTestSuite) serveAcme(w http.ResponseWriter, req *http.Request) {
+	url := s.server.URL
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+	if req.URL.Path == "/directory" {
+		fmt.Fprintf(w, `{"newNonce":"%s/nonce","newAccount":"%s/account","newOrder":"%s/order"}`, url, url, url)
+		return
+	} else if req.URL.Path == "/nonce" {
+		return
+	}
+	payload, err := s.verify(req)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, `{"type":"urn:ietf:params:acme:error:unauthorized","detail":"%s"}`, err.Error())
+		return
+	}
+	if s.badNonces > 0 {
+		s.badNonces--
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"type":"urn:ietf:params:acme:error:badNonce","detail":"The nonce is invalid"}`)
+		return
+	}
+	switch req.URL.Path {
+	case "/account":
+		w.Header().Set("Location", url+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"valid"}`)
+	case "/order":
+		w.Header().Set("Location", url+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"status":"pending","authorizations":["%s/authz/1"],"finalize":"%s/finalize"}`, url, url)
+	case "/authz/1":
+		fmt.Fprintf(w, `{"status":"%s","identifier":{"value":"acme.com"},"challenges":[{"type":"dns-01","url":"%s/challenge/dns","token":"dns-token"},{"type":"http-01","url":"%s/challenge/http","token":"http-token"%s}]}`, s.authzStatus, url, url, s.getChallengeError())
+	case "/challenge/http":
+		keyAuth, _ := acmeChallenges.Get("http-token")
+		s.keyAuths = append(s.keyAuths, keyAuth)
+		if s.authzStatus == "pending" {
+			s.authzStatus = "valid"
+		}
+		fmt.Fprint(w, `{"status":"processing"}`)
+	case "/finalize":
+		csrJs := map[string]string{}
+		json.Unmarshal(payload, &csrJs)
+		der, _ := base64.RawURLEncoding.DecodeString(csrJs["csr"])
+		if csr, err := x509.ParseCertificateRequest(der); err == nil {
+			s.csrDomains = csr.DNSNames
+		}
+		fmt.Fprintf(w, `{"status":"processing","finalize":"%s/finalize"}`, url)
+	case "/order/1":
+		fmt.Fprintf(w, `{"status":"valid","certificate":"%s/cert/1"}`, url)
+	case "/cert/1":
+		fmt.Fprint(w, "-----BEGIN CERTIFICATE-----\nY2VydA==\n-----END CERTIFICATE-----\n")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *AcmeTestSuite) getChallengeError() string {
+	if s.authzStatus != "invalid" {
+		return ""
+	}
+	return `,"error":{"type":"urn:ietf:params:acme:error:connection","detail":"Connection refused"}`
+}
+
+func (s *AcmeTestSuite) getKey() *ecdsa.PrivateKey {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return key
+}
+
+func (s *AcmeTestSuite) verify(req *http.Request) ([]byte, error) {
+	body, _ := ioutil.ReadAll(req.Body)
+	jws := map[string]string{}
+	if err := json.Unmarshal(body, &jws); err != nil {
+		return nil, err
+	}
+	protectedJs, _ := base64.RawURLEncoding.DecodeString(jws["protected"])
+	protected := struct {
+		Alg   string
+		Nonce string
+		Url   string
+		Kid   string
+		Jwk   map[string]string
+	}{}
+	json.Unmarshal(protectedJs, &protected)
+	if protected.Url != s.server.URL+req.URL.Path || len(protected.Nonce) == 0 {
+		return nil, fmt.Errorf("The url or the nonce is invalid")
+	}
+	if protected.Jwk != nil {
+		x, _ := base64.RawURLEncoding.DecodeString(protected.Jwk["x"])
+		y, _ := base64.RawURLEncoding.DecodeString(protected.Jwk["y"])
+		s.accountKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	} else {
+		s.kids = append(s.kids, protected.Kid)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(jws["signature"])
+	hash := sha256.Sum256([]byte(jws["protected"] + "." + jws["payload"]))
+	if protected.Alg != "ES256" || len(signature) != 64 || s.accountKey == nil ||
+		!ecdsa.Verify(s.accountKey, hash[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		return nil, fmt.Errorf("The signature is invalid")
+	}
+	return base64.RawURLEncoding.DecodeString(jws["payload"])
+}
+
+// Obtain
+
+func (s *AcmeTestSuite) Test_Obtain_ReturnsCertAndKey() {
+	client, err := newAcmeClient(s.server.URL+"/directory", "admin@acme.com", s.getKey())
+	s.Require().NoError(err)
+
+	actual, err := client.Obtain([]string{"acme.com", "www.acme.com"})
+
+	s.Require().NoError(err)
+	block, rest := pem.Decode([]byte(actual))
+	s.Equal("CERTIFICATE", block.Type)
+	block, _ = pem.Decode(rest)
+	s.Equal("EC PRIVATE KEY", block.Type)
+	s.Equal([]string{"acme.com", "www.acme.com"}, s.csrDomains)
+	for _, kid := range s.kids {
+		s.Equal(s.server.URL+"/account/1", kid)
+	}
+}
+
+func (s *AcmeTestSuite) Test_Obtain_AnswersChallengeWithKeyAuthorization() {
+	client, _ := newAcmeClient(s.server.URL+"/directory", "admin@acme.com", s.getKey())
+
+	client.Obtain([]string{"acme.com"})
+
+	jwk := client.jwk()
+	thumbprint := sha256.Sum256([]byte(fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, jwk["x"], jwk["y"])))
+	s.Equal([]string{"http-token." + base64.RawURLEncoding.EncodeToString(thumbprint[:])}, s.keyAuths)
+	_, ok := acmeChallenges.Get("http-token")
+	s.False(ok)
+}
+
+func (s *AcmeTestSuite) Test_Obtain_ReturnsError_WhenAuthorizationIsInvalid() {
+	client, _ := newAcmeClient(s.server.URL+"/directory", "admin@acme.com", s.getKey())
+	s.authzStatus = "invalid"
+
+	_, err := client.Obtain([]string{"acme.com"})
+
+	s.EqualError(err, "The authorization of acme.com is invalid\nConnection refused")
+}
+
+func (s *AcmeTestSuite) Test_Obtain_RetriesRequest_WhenNonceIsRejected() {
+	client, _ := newAcmeClient(s.server.URL+"/directory", "admin@acme.com", s.getKey())
+	s.badNonces = 1
+
+	_, err := client.Obtain([]string{"acme.com"})
+
+	s.NoError(err)
+}
+
+// newAcmeClient
+
+func (s *AcmeTestSuite) Test_NewAcmeClient_ReturnsError_WhenDirectoryIsNotAvailable() {
+	_, err := newAcmeClient(s.server.URL+"/unknown", "admin@acme.com", s.getKey())
+
+	s.Error(err)
+	s.True(strings.HasPrefix(err.Error(), "Could not fetch the ACME directory"))
+}
+
+// Suite
+
+func TestAcmeUnitTestSuite(t *testing.T) {
+	acmePollIntervalOrig := acmePollInterval
+	defer func() { acmePollInterval = acmePollIntervalOrig }()
+	suite.Run(t, new(AcmeTestSuite))
+}
@@ -0,0 +1,102 @@
+package actions
+
+import (
+	"fmt"
+
+	haproxy "../proxy"
+)
+
+// ReconfigureBatch configures multiple services with a single proxy reload.
+type ReconfigureBatch struct {
+	BaseReconfigure
+	Services []ServiceReconfigure
+}
+
+// batchChange is a service whose configuration files were changed by the batch, together with the service stored
+// before.
+type batchChange struct {
+	recon     *Reconfigure
+	previous  ServiceReconfigure
+	wasStored bool
+}
+
+var NewReconfigureBatch = func(baseData BaseReconfigure, services []ServiceReconfigure) Executable {
+	return &ReconfigureBatch{baseData, services}
+}
+
+// Execute validates all the services before any configuration is written so that an invalid service does not leave
+// the batch partially applied. If writing, validating or reloading the configuration fails, the files of all the
+// services in the batch are rolled back.
+func (m *ReconfigureBatch) Execute(args []string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	recons := []*Reconfigure{}
+	withdrawn := []*Reconfigure{}
+	for _, sr := range m.Services {
+		recon := &Reconfigure{m.BaseReconfigure, sr}
+		if !sr.MatchesProxyRole() {
+			withdrawn = append(withdrawn, recon)
+			continue
+		}
+		if err := recon.checkServiceAddress(); err != nil {
+			return err
+		}
+		if _, _, err := recon.GetTemplates(sr); err != nil {
+			return fmt.Errorf("Could not create the configuration of the service %s\n%s", sr.ServiceName, err.Error())
+		}
+		recons = append(recons, recon)
+	}
+	changes := []batchChange{}
+	for _, recon := range recons {
+		stored, ok := getStoredService(recon.getStoredName())
+		changes = append(changes, batchChange{recon, stored, ok})
+		if err := recon.createConfigs(m.TemplatesPath, &recon.ServiceReconfigure); err != nil {
+			m.rollback(changes)
+			return err
+		}
+	}
+	for _, recon := range withdrawn {
+		stored, ok := getStoredService(recon.getStoredName())
+		changes = append(changes, batchChange{recon, stored, ok})
+		recon.withdraw(m.TemplatesPath, recon.ServiceReconfigure)
+	}
+	if _, _, err := WriteDomainMap(m.ConfigsPath); err != nil {
+		logPrintf(err.Error())
+	}
+	if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
+		m.rollback(changes)
+		return err
+	}
+	for _, recon := range recons {
+		haproxy.AddReloadChange(recon.ServiceName, haproxy.ReloadActionReconfigure, recon.PostReloadHook)
+	}
+	for _, recon := range withdrawn {
+		haproxy.AddReloadChange(recon.ServiceName, haproxy.ReloadActionRemove, "")
+	}
+	if err := haproxy.Instance.Reload(); err != nil {
+		m.rollback(changes)
+		// The configuration of the batch would otherwise be loaded by the next reload
+		if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
+			logPrintf(err.Error())
+		}
+		return err
+	}
+	for _, recon := range append(recons, withdrawn...) {
+		if len(m.ConsulAddresses) > 0 || !isSwarm(recon.ServiceReconfigure.Mode) {
+			if err := recon.putToConsul(m.ConsulAddresses, recon.ServiceReconfigure, m.InstanceName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rollback restores the configuration files of the changed services, in reverse order.
+func (m *ReconfigureBatch) rollback(changes []batchChange) {
+	for i := len(changes) - 1; i >= 0; i-- {
+		changes[i].recon.rollbackConfigs(changes[i].previous, changes[i].wasStored)
+	}
+	if _, _, err := WriteDomainMap(m.ConfigsPath); err != nil {
+		logPrintf(err.Error())
+	}
+}
@@ -0,0 +1,206 @@
+// +build !integration
+
+package actions
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"testing"
+
+	haproxy "../proxy"
+)
+
+type BatchTestSuite struct {
+	suite.Suite
+	proxyOrig haproxy.Proxy
+	files     []string
+}
+
+func (s *BatchTestSuite) SetupTest() {
+	s.proxyOrig = haproxy.Instance
+	haproxy.Instance = getProxyMock("")
+	s.files = []string{}
+	writeFeTemplate = func(filename string, data []byte, perm os.FileMode) error {
+		s.files = append(s.files, filename)
+		return nil
+	}
+	writeBeTemplate = writeFeTemplate
+	writeDomainMapFile = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+	lookupHost = func(host string) (addrs []string, err error) {
+		return []string{}, nil
+	}
+}
+
+func (s *BatchTestSuite) TearDownTest() {
+	haproxy.Instance = s.proxyOrig
+}
+
+// Execute
+
+func (s *BatchTestSuite) Test_Execute_ReloadsOnce() {
+	mockObj := getProxyMock("")
+	haproxy.Instance = mockObj
+	services := []ServiceReconfigure{
+		{ServiceName: "service-1", ServicePath: []string{"/1"}, Port: "8080", Mode: "swarm"},
+		{ServiceName: "service-2", ServicePath: []string{"/2"}, Port: "8080", Mode: "swarm"},
+	}
+
+	err := NewReconfigureBatch(BaseReconfigure{TemplatesPath: "/tmpl"}, services).Execute([]string{})
+
+	s.NoError(err)
+	s.Equal([]string{"/tmpl/service-1-fe.cfg", "/tmpl/service-1-be.cfg", "/tmpl/service-2-fe.cfg", "/tmpl/service-2-be.cfg"}, s.files)
+	mockObj.AssertNumberOfCalls(s.T(), "CreateConfigFromTemplates", 1)
+	mockObj.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
+func (s *BatchTestSuite) Test_Execute_DoesNotWriteConfigs_WhenAnyServiceIsInvalid() {
+	mockObj := getProxyMock("")
+	haproxy.Instance = mockObj
+	services := []ServiceReconfigure{
+		{ServiceName: "service-1", ServicePath: []string{"/1"}, Port: "8080", Mode: "swarm"},
+		{ServiceName: "service-2", TemplateFePath: "/does/not/exist", TemplateBePath: "/does/not/exist", Mode: "swarm"},
+	}
+
+	err := NewReconfigureBatch(BaseReconfigure{TemplatesPath: "/tmpl"}, services).Execute([]string{})
+
+	s.Error(err)
+	s.Empty(s.files)
+	mockObj.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *BatchTestSuite) Test_Execute_RestoresConfigs_WhenConfigExceedsLimits() {
+	configuredServicesOrig := configuredServices
+	removeFeTemplateOrig := removeFeTemplate
+	removeBeTemplateOrig := removeBeTemplate
+	defer func() {
+		configuredServices = configuredServicesOrig
+		removeFeTemplate = removeFeTemplateOrig
+		removeBeTemplate = removeBeTemplateOrig
+	}()
+	stored := ServiceReconfigure{ServiceName: "service-1", AclName: "service-1", ServicePath: []string{"/old"}, Port: "8080", Mode: "swarm"}
+	configuredServices = map[string]ServiceReconfigure{"service-1": stored}
+	removed := []string{}
+	removeFeTemplate = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+	removeBeTemplate = removeFeTemplate
+	limitErr := &haproxy.ConfigLimitError{Limit: "MAX_CONFIG_SIZE", Subject: "bytes of the configuration", Value: 2, Max: 1}
+	mockObj := getProxyMock("CreateConfigFromTemplates")
+	mockObj.On("CreateConfigFromTemplates").Return(limitErr)
+	haproxy.Instance = mockObj
+	services := []ServiceReconfigure{
+		{ServiceName: "service-1", ServicePath: []string{"/1"}, Port: "8080", Mode: "swarm"},
+		{ServiceName: "service-2", ServicePath: []string{"/2"}, Port: "8080", Mode: "swarm"},
+	}
+
+	err := NewReconfigureBatch(BaseReconfigure{TemplatesPath: "/tmpl"}, services).Execute([]string{})
+
+	s.Equal(limitErr, err)
+	mockObj.AssertNotCalled(s.T(), "Reload")
+	s.Contains(removed, "/tmpl/service-2-fe.cfg")
+	s.Contains(removed, "/tmpl/service-2-be.cfg")
+	s.Equal([]string{"/tmpl/service-1-fe.cfg", "/tmpl/service-1-be.cfg"}, s.files[len(s.files)-2:])
+	s.Equal([]ServiceReconfigure{stored}, GetServices())
+}
+
+func (s *BatchTestSuite) Test_Execute_RestoresConfigs_WhenReloadFails() {
+	configuredServicesOrig := configuredServices
+	removeFeTemplateOrig := removeFeTemplate
+	removeBeTemplateOrig := removeBeTemplate
+	defer func() {
+		configuredServices = configuredServicesOrig
+		removeFeTemplate = removeFeTemplateOrig
+		removeBeTemplate = removeBeTemplateOrig
+	}()
+	stored := ServiceReconfigure{ServiceName: "service-1", AclName: "service-1", ServicePath: []string{"/old"}, Port: "8080", Mode: "swarm"}
+	configuredServices = map[string]ServiceReconfigure{"service-1": stored}
+	removed := []string{}
+	removeFeTemplate = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+	removeBeTemplate = removeFeTemplate
+	mockObj := getProxyMock("Reload")
+	mockObj.On("Reload").Return(fmt.Errorf("This is an error"))
+	haproxy.Instance = mockObj
+	services := []ServiceReconfigure{
+		{ServiceName: "service-1", ServicePath: []string{"/1"}, Port: "8080", Mode: "swarm"},
+		{ServiceName: "service-2", ServicePath: []string{"/2"}, Port: "8080", Mode: "swarm"},
+	}
+
+	err := NewReconfigureBatch(BaseReconfigure{TemplatesPath: "/tmpl"}, services).Execute([]string{})
+
+	s.Error(err)
+	s.Contains(removed, "/tmpl/service-2-fe.cfg")
+	s.Contains(removed, "/tmpl/service-2-be.cfg")
+	s.Equal([]string{"/tmpl/service-1-fe.cfg", "/tmpl/service-1-be.cfg"}, s.files[len(s.files)-2:])
+	s.Equal([]ServiceReconfigure{stored}, GetServices())
+	mockObj.AssertNumberOfCalls(s.T(), "CreateConfigFromTemplates", 2)
+}
+
+func (s *BatchTestSuite) Test_Execute_RemovesConfigs_WhenConfigIsInvalid() {
+	configuredServicesOrig := configuredServices
+	removeFeTemplateOrig := removeFeTemplate
+	removeBeTemplateOrig := removeBeTemplate
+	defer func() {
+		configuredServices = configuredServicesOrig
+		removeFeTemplate = removeFeTemplateOrig
+		removeBeTemplate = removeBeTemplateOrig
+	}()
+	configuredServices = map[string]ServiceReconfigure{}
+	removed := []string{}
+	removeFeTemplate = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+	removeBeTemplate = removeFeTemplate
+	mockObj := getProxyMock("CreateConfigFromTemplates")
+	mockObj.On("CreateConfigFromTemplates").Return(fmt.Errorf("This is an error"))
+	haproxy.Instance = mockObj
+	services := []ServiceReconfigure{
+		{ServiceName: "service-1", ServicePath: []string{"/1"}, Port: "8080", Mode: "swarm"},
+		{ServiceName: "service-2", ServicePath: []string{"/2"}, Port: "8080", Mode: "swarm"},
+	}
+
+	err := NewReconfigureBatch(BaseReconfigure{TemplatesPath: "/tmpl"}, services).Execute([]string{})
+
+	s.Error(err)
+	mockObj.AssertNotCalled(s.T(), "Reload")
+	for _, file := range []string{"/tmpl/service-1-fe.cfg", "/tmpl/service-1-be.cfg", "/tmpl/service-2-fe.cfg", "/tmpl/service-2-be.cfg"} {
+		s.Contains(removed, file)
+	}
+	s.Empty(GetServices())
+}
+
+func (s *BatchTestSuite) Test_Execute_ReturnsError_WhenServiceCannotBeResolved() {
+	lookupHost = func(host string) (addrs []string, err error) {
+		return []string{}, os.ErrNotExist
+	}
+	services := []ServiceReconfigure{{ServiceName: "service-1", ServicePath: []string{"/1"}, Port: "8080", Mode: "swarm"}}
+
+	err := NewReconfigureBatch(BaseReconfigure{TemplatesPath: "/tmpl"}, services).Execute([]string{})
+
+	s.Error(err)
+	s.Empty(s.files)
+}
+
+// Suite
+
+func TestBatchUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	writeFeTemplateOrig := writeFeTemplate
+	writeBeTemplateOrig := writeBeTemplate
+	writeDomainMapFileOrig := writeDomainMapFile
+	lookupHostOrig := lookupHost
+	defer func() {
+		writeFeTemplate = writeFeTemplateOrig
+		writeBeTemplate = writeBeTemplateOrig
+		writeDomainMapFile = writeDomainMapFileOrig
+		lookupHost = lookupHostOrig
+	}()
+	suite.Run(t, new(BatchTestSuite))
+}
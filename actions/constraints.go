@@ -0,0 +1,124 @@
+package actions
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// ConstraintRequires means that the parameter can be used only together with the other one.
+	ConstraintRequires = "requires"
+	// ConstraintConflicts means that the parameter cannot be used together with the other one.
+	ConstraintConflicts = "conflicts"
+)
+
+// Constraint describes a relation between two reconfigure parameters. A parameter written as name=value (e.g.
+// reqMode=tcp) is considered set only if it has that value.
+type Constraint struct {
+	Parameter string
+	Kind      string
+	Other     string
+}
+
+// ParameterError describes a violated constraint of a single parameter.
+type ParameterError struct {
+	Parameter string
+	Message   string
+}
+
+// ReconfigureConstraints lists the dependencies and conflicts between the parameters of the reconfigure endpoint.
+var ReconfigureConstraints = []Constraint{
+	{"consulTemplateFePath", ConstraintConflicts, "templateFePath"},
+	{"consulTemplateFePath", ConstraintRequires, "consulTemplateBePath"},
+	{"consulTemplateBePath", ConstraintRequires, "consulTemplateFePath"},
+	{"templateFePath", ConstraintRequires, "templateBePath"},
+	{"templateBePath", ConstraintRequires, "templateFePath"},
+	{"reqRepSearch", ConstraintRequires, "reqRepReplace"},
+	{"reqRepReplace", ConstraintRequires, "reqRepSearch"},
+	{"corsMethods", ConstraintRequires, "corsOrigins"},
+	{"corsHeaders", ConstraintRequires, "corsOrigins"},
+	{"skipCheck", ConstraintConflicts, "checkGrpc"},
+	{"canaryHeader", ConstraintRequires, "serviceColor"},
+	{"useDomainMap", ConstraintRequires, "serviceDomain"},
+	{"letsEncrypt", ConstraintRequires, "serviceDomain"},
+	{"clientCertVerify", ConstraintRequires, "clientCertCaFile"},
+	{"clientCertCaFile", ConstraintRequires, "clientCertVerify"},
+	{"clientCertVerify", ConstraintConflicts, "useDomainMap"},
+	{"certName", ConstraintConflicts, "serviceCert"},
+	{"certName", ConstraintConflicts, "letsEncrypt"},
+	{"serviceDomainMatchAll", ConstraintRequires, "serviceDomain"},
+	{"serviceDomainMatchAll", ConstraintConflicts, "useDomainMap"},
+	{"redirectFromDomain", ConstraintRequires, "serviceDomain"},
+	{"allowedMethods", ConstraintConflicts, "deniedMethods"},
+	{"compressionType", ConstraintRequires, "compressionAlgo"},
+	{"reqRatePeriod", ConstraintRequires, "reqRateLimit"},
+	{"checkMethod", ConstraintRequires, "checkPath"},
+	{"httpCheckExpect", ConstraintRequires, "checkPath"},
+	{"checkPath", ConstraintConflicts, "skipCheck"},
+	{"checkPath", ConstraintConflicts, "checkGrpc"},
+	{"backupPort", ConstraintRequires, "backupHostname"},
+	{"redirectWhenHttpProto", ConstraintConflicts, "httpsOnly"},
+	{"sslVerifyNone", ConstraintConflicts, "sslCaCert"},
+	{"reqMode=tcp", ConstraintRequires, "srcPort"},
+	// The queries that make sense only for http
+	{"servicePath", ConstraintConflicts, "reqMode=tcp"},
+	{"serviceDomain", ConstraintConflicts, "reqMode=tcp"},
+	{"users", ConstraintConflicts, "reqMode=tcp"},
+	{"reqRepSearch", ConstraintConflicts, "reqMode=tcp"},
+	{"httpsOnly", ConstraintConflicts, "reqMode=tcp"},
+	{"httpsPort", ConstraintConflicts, "reqMode=tcp"},
+	{"allowedMethods", ConstraintConflicts, "reqMode=tcp"},
+	{"deniedMethods", ConstraintConflicts, "reqMode=tcp"},
+	{"denyHttp", ConstraintConflicts, "reqMode=tcp"},
+	{"addReqHeader", ConstraintConflicts, "reqMode=tcp"},
+	{"setReqHeader", ConstraintConflicts, "reqMode=tcp"},
+	{"delReqHeader", ConstraintConflicts, "reqMode=tcp"},
+	{"addResHeader", ConstraintConflicts, "reqMode=tcp"},
+	{"delResHeader", ConstraintConflicts, "reqMode=tcp"},
+	{"forwardedProto=true", ConstraintConflicts, "reqMode=tcp"},
+	{"compressionAlgo", ConstraintConflicts, "reqMode=tcp"},
+	{"reqRateLimit", ConstraintConflicts, "reqMode=tcp"},
+	{"checkPath", ConstraintConflicts, "reqMode=tcp"},
+	{"checkGrpc", ConstraintConflicts, "reqMode=tcp"},
+	{"redirectWhenHttpProto", ConstraintConflicts, "reqMode=tcp"},
+	{"sessionType", ConstraintConflicts, "reqMode=tcp"},
+	{"cookieName", ConstraintConflicts, "reqMode=tcp"},
+	{"corsOrigins", ConstraintConflicts, "reqMode=tcp"},
+	{"connectionMode", ConstraintConflicts, "reqMode=tcp"},
+	{"httpReuse", ConstraintConflicts, "reqMode=tcp"},
+	{"timeoutHttpRequest", ConstraintConflicts, "reqMode=tcp"},
+	{"maintenance=true", ConstraintConflicts, "reqMode=tcp"},
+	{"allowMissingHost", ConstraintConflicts, "reqMode=tcp"},
+	{"sslVerifyNone", ConstraintConflicts, "reqMode=tcp"},
+	{"sslCaCert", ConstraintConflicts, "reqMode=tcp"},
+}
+
+// ValidateConstraints returns all the constraints violated by the service. A parameter is considered set if it is
+// not empty.
+func ValidateConstraints(constraints []Constraint, sr ServiceReconfigure) []ParameterError {
+	query := EncodeParameters(ReconfigureParameters, sr)
+	errs := []ParameterError{}
+	for _, c := range constraints {
+		if !isConstraintParameterSet(query, c.Parameter) {
+			continue
+		}
+		name := strings.SplitN(c.Parameter, "=", 2)[0]
+		isOtherSet := isConstraintParameterSet(query, c.Other)
+		if c.Kind == ConstraintRequires && !isOtherSet {
+			errs = append(errs, ParameterError{name, fmt.Sprintf("The %s query requires the %s query", c.Parameter, c.Other)})
+		} else if c.Kind == ConstraintConflicts && isOtherSet {
+			errs = append(errs, ParameterError{name, fmt.Sprintf("The %s query cannot be combined with the %s query", c.Parameter, c.Other)})
+		}
+	}
+	return errs
+}
+
+// isConstraintParameterSet tells whether the parameter of a constraint is set. Values are compared case-insensitively.
+func isConstraintParameterSet(query url.Values, parameter string) bool {
+	nameValue := strings.SplitN(parameter, "=", 2)
+	if len(nameValue) == 1 {
+		return len(query.Get(parameter)) > 0
+	}
+	return strings.EqualFold(query.Get(nameValue[0]), nameValue[1])
+}
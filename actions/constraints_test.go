@@ -0,0 +1,316 @@
+// +build !integration
+
+package actions
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ConstraintsTestSuite struct {
+	suite.Suite
+}
+
+// ValidateConstraints
+
+func (s ConstraintsTestSuite) Test_ValidateConstraints_ReturnsError_WhenConstraintIsViolated() {
+	cases := []struct {
+		sr       ServiceReconfigure
+		expected ParameterError
+	}{
+		{
+			ServiceReconfigure{ConsulTemplateFePath: "/fe", ConsulTemplateBePath: "/be", TemplateFePath: "/fe", TemplateBePath: "/be"},
+			ParameterError{"consulTemplateFePath", "The consulTemplateFePath query cannot be combined with the templateFePath query"},
+		},
+		{
+			ServiceReconfigure{ConsulTemplateFePath: "/fe"},
+			ParameterError{"consulTemplateFePath", "The consulTemplateFePath query requires the consulTemplateBePath query"},
+		},
+		{
+			ServiceReconfigure{ConsulTemplateBePath: "/be"},
+			ParameterError{"consulTemplateBePath", "The consulTemplateBePath query requires the consulTemplateFePath query"},
+		},
+		{
+			ServiceReconfigure{TemplateFePath: "/fe"},
+			ParameterError{"templateFePath", "The templateFePath query requires the templateBePath query"},
+		},
+		{
+			ServiceReconfigure{TemplateBePath: "/be"},
+			ParameterError{"templateBePath", "The templateBePath query requires the templateFePath query"},
+		},
+		{
+			ServiceReconfigure{ReqRepSearch: "search"},
+			ParameterError{"reqRepSearch", "The reqRepSearch query requires the reqRepReplace query"},
+		},
+		{
+			ServiceReconfigure{ReqRepReplace: "replace"},
+			ParameterError{"reqRepReplace", "The reqRepReplace query requires the reqRepSearch query"},
+		},
+		{
+			ServiceReconfigure{CorsMethods: []string{"GET"}},
+			ParameterError{"corsMethods", "The corsMethods query requires the corsOrigins query"},
+		},
+		{
+			ServiceReconfigure{CorsHeaders: []string{"X-Custom"}},
+			ParameterError{"corsHeaders", "The corsHeaders query requires the corsOrigins query"},
+		},
+		{
+			ServiceReconfigure{SkipCheck: true, CheckGrpc: true},
+			ParameterError{"skipCheck", "The skipCheck query cannot be combined with the checkGrpc query"},
+		},
+		{
+			ServiceReconfigure{CanaryHeader: "X-Canary:green"},
+			ParameterError{"canaryHeader", "The canaryHeader query requires the serviceColor query"},
+		},
+		{
+			ServiceReconfigure{UseDomainMap: true},
+			ParameterError{"useDomainMap", "The useDomainMap query requires the serviceDomain query"},
+		},
+		{
+			ServiceReconfigure{LetsEncrypt: true},
+			ParameterError{"letsEncrypt", "The letsEncrypt query requires the serviceDomain query"},
+		},
+		{
+			ServiceReconfigure{ClientCertVerify: "required"},
+			ParameterError{"clientCertVerify", "The clientCertVerify query requires the clientCertCaFile query"},
+		},
+		{
+			ServiceReconfigure{ClientCertCaFile: "my-ca.pem"},
+			ParameterError{"clientCertCaFile", "The clientCertCaFile query requires the clientCertVerify query"},
+		},
+		{
+			ServiceReconfigure{ClientCertVerify: "required", ClientCertCaFile: "my-ca.pem", UseDomainMap: true, ServiceDomain: []string{"acme.com"}},
+			ParameterError{"clientCertVerify", "The clientCertVerify query cannot be combined with the useDomainMap query"},
+		},
+		{
+			ServiceReconfigure{CertName: "my-cert.pem", ServiceCert: "CERT"},
+			ParameterError{"certName", "The certName query cannot be combined with the serviceCert query"},
+		},
+		{
+			ServiceReconfigure{CertName: "my-cert.pem", LetsEncrypt: true, ServiceDomain: []string{"acme.com"}},
+			ParameterError{"certName", "The certName query cannot be combined with the letsEncrypt query"},
+		},
+		{
+			ServiceReconfigure{ServiceDomainMatchAll: true},
+			ParameterError{"serviceDomainMatchAll", "The serviceDomainMatchAll query requires the serviceDomain query"},
+		},
+		{
+			ServiceReconfigure{ServiceDomainMatchAll: true, ServiceDomain: []string{"acme.com"}, UseDomainMap: true},
+			ParameterError{"serviceDomainMatchAll", "The serviceDomainMatchAll query cannot be combined with the useDomainMap query"},
+		},
+		{
+			ServiceReconfigure{RedirectFromDomain: []string{"old.com"}},
+			ParameterError{"redirectFromDomain", "The redirectFromDomain query requires the serviceDomain query"},
+		},
+		{
+			ServiceReconfigure{AllowedMethods: []string{"GET"}, DeniedMethods: []string{"DELETE"}},
+			ParameterError{"allowedMethods", "The allowedMethods query cannot be combined with the deniedMethods query"},
+		},
+		{
+			ServiceReconfigure{CompressionType: []string{"application/json"}},
+			ParameterError{"compressionType", "The compressionType query requires the compressionAlgo query"},
+		},
+		{
+			ServiceReconfigure{ReqRatePeriod: 10},
+			ParameterError{"reqRatePeriod", "The reqRatePeriod query requires the reqRateLimit query"},
+		},
+		{
+			ServiceReconfigure{CheckMethod: "HEAD"},
+			ParameterError{"checkMethod", "The checkMethod query requires the checkPath query"},
+		},
+		{
+			ServiceReconfigure{HttpCheckExpect: "status 204"},
+			ParameterError{"httpCheckExpect", "The httpCheckExpect query requires the checkPath query"},
+		},
+		{
+			ServiceReconfigure{CheckPath: "/healthz", SkipCheck: true},
+			ParameterError{"checkPath", "The checkPath query cannot be combined with the skipCheck query"},
+		},
+		{
+			ServiceReconfigure{CheckPath: "/healthz", CheckGrpc: true},
+			ParameterError{"checkPath", "The checkPath query cannot be combined with the checkGrpc query"},
+		},
+		{
+			ServiceReconfigure{BackupPort: "8080"},
+			ParameterError{"backupPort", "The backupPort query requires the backupHostname query"},
+		},
+		{
+			ServiceReconfigure{RedirectWhenHttpProto: true, HttpsOnly: true},
+			ParameterError{"redirectWhenHttpProto", "The redirectWhenHttpProto query cannot be combined with the httpsOnly query"},
+		},
+		{
+			ServiceReconfigure{SslVerifyNone: true, SslCaCert: "my-ca.pem"},
+			ParameterError{"sslVerifyNone", "The sslVerifyNone query cannot be combined with the sslCaCert query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp"},
+			ParameterError{"reqMode", "The reqMode=tcp query requires the srcPort query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", ServicePath: []string{"/db"}},
+			ParameterError{"servicePath", "The servicePath query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", ServiceDomain: []string{"db.com"}},
+			ParameterError{"serviceDomain", "The serviceDomain query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", Users: []User{{Username: "user", Password: "pass"}}},
+			ParameterError{"users", "The users query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", ReqRepSearch: "search", ReqRepReplace: "replace"},
+			ParameterError{"reqRepSearch", "The reqRepSearch query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", HttpsOnly: true},
+			ParameterError{"httpsOnly", "The httpsOnly query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", HttpsPort: "8443"},
+			ParameterError{"httpsPort", "The httpsPort query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", AllowedMethods: []string{"GET"}},
+			ParameterError{"allowedMethods", "The allowedMethods query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", DeniedMethods: []string{"DELETE"}},
+			ParameterError{"deniedMethods", "The deniedMethods query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", DenyHttp: true},
+			ParameterError{"denyHttp", "The denyHttp query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", AddReqHeader: []string{"X-Foo bar"}},
+			ParameterError{"addReqHeader", "The addReqHeader query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", SetReqHeader: []string{"X-Foo bar"}},
+			ParameterError{"setReqHeader", "The setReqHeader query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", DelReqHeader: []string{"X-Foo"}},
+			ParameterError{"delReqHeader", "The delReqHeader query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", AddResHeader: []string{"X-Foo bar"}},
+			ParameterError{"addResHeader", "The addResHeader query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", DelResHeader: []string{"X-Foo"}},
+			ParameterError{"delResHeader", "The delResHeader query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", ForwardedProto: "true"},
+			ParameterError{"forwardedProto", "The forwardedProto=true query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", CompressionAlgo: []string{"gzip"}},
+			ParameterError{"compressionAlgo", "The compressionAlgo query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", ReqRateLimit: "10"},
+			ParameterError{"reqRateLimit", "The reqRateLimit query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", CheckPath: "/healthz"},
+			ParameterError{"checkPath", "The checkPath query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", CheckGrpc: true},
+			ParameterError{"checkGrpc", "The checkGrpc query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", RedirectWhenHttpProto: true},
+			ParameterError{"redirectWhenHttpProto", "The redirectWhenHttpProto query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", SessionType: "sticky-server"},
+			ParameterError{"sessionType", "The sessionType query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", CookieName: "SERVERID"},
+			ParameterError{"cookieName", "The cookieName query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", CorsOrigins: []string{"*"}},
+			ParameterError{"corsOrigins", "The corsOrigins query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", ConnectionMode: "http-keep-alive"},
+			ParameterError{"connectionMode", "The connectionMode query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", HttpReuse: "safe"},
+			ParameterError{"httpReuse", "The httpReuse query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", TimeoutHttpRequest: 10},
+			ParameterError{"timeoutHttpRequest", "The timeoutHttpRequest query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", Maintenance: "true"},
+			ParameterError{"maintenance", "The maintenance=true query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", AllowMissingHost: true},
+			ParameterError{"allowMissingHost", "The allowMissingHost query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", SslVerifyNone: true},
+			ParameterError{"sslVerifyNone", "The sslVerifyNone query cannot be combined with the reqMode=tcp query"},
+		},
+		{
+			ServiceReconfigure{ReqMode: "tcp", SrcPort: "5432", SslCaCert: "my-ca.pem"},
+			ParameterError{"sslCaCert", "The sslCaCert query cannot be combined with the reqMode=tcp query"},
+		},
+	}
+	s.Len(cases, len(ReconfigureConstraints))
+	for _, c := range cases {
+		actual := ValidateConstraints(ReconfigureConstraints, c.sr)
+
+		s.Equal([]ParameterError{c.expected}, actual)
+	}
+}
+
+func (s ConstraintsTestSuite) Test_ValidateConstraints_ComparesValuesOfParameters() {
+	for _, sr := range []ServiceReconfigure{
+		{ReqMode: "http", ServicePath: []string{"/api"}},
+		{ReqMode: "TCP", SrcPort: "5432", ForwardedProto: "false"},
+		{ReqMode: "tcp", SrcPort: "5432", Maintenance: "false"},
+	} {
+		actual := ValidateConstraints(ReconfigureConstraints, sr)
+
+		s.Empty(actual)
+	}
+}
+
+func (s ConstraintsTestSuite) Test_ValidateConstraints_ReturnsAllErrors() {
+	sr := ServiceReconfigure{ReqRepSearch: "search", CorsMethods: []string{"GET"}}
+
+	actual := ValidateConstraints(ReconfigureConstraints, sr)
+
+	s.Len(actual, 2)
+}
+
+func (s ConstraintsTestSuite) Test_ValidateConstraints_ReturnsEmptyArray_WhenThereAreNoViolations() {
+	sr := ServiceReconfigure{
+		ServiceName:    "my-service",
+		TemplateFePath: "/fe",
+		TemplateBePath: "/be",
+		CorsOrigins:    []string{"*"},
+		CorsMethods:    []string{"GET"},
+	}
+
+	actual := ValidateConstraints(ReconfigureConstraints, sr)
+
+	s.Empty(actual)
+}
+
+// Suite
+
+func TestConstraintsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ConstraintsTestSuite))
+}
@@ -0,0 +1,94 @@
+package actions
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	haproxy "../proxy"
+)
+
+var readDomainMapFile = ioutil.ReadFile
+var writeDomainMapFile = ioutil.WriteFile
+var updateDomainMap = haproxy.UpdateMap
+
+// GetDomainMapPath returns the path of the map file that routes the domains of the services reconfigured with
+// useDomainMap.
+func GetDomainMapPath(configsPath string) string {
+	return fmt.Sprintf("%s/%s", configsPath, haproxy.DomainMapFile)
+}
+
+// GetDomainMap returns the backends of the domains of the services reconfigured with useDomainMap. Domains are in
+// lower case since the frontend lowers the Host header before the lookup.
+func GetDomainMap() map[string]string {
+	knownServicesMu.Lock()
+	defer knownServicesMu.Unlock()
+	entries := map[string]string{}
+	for _, sr := range configuredServices {
+		if !sr.UseDomainMap {
+			continue
+		}
+		name := sr.AclName
+		if len(name) == 0 {
+			name = sr.ServiceName
+		}
+		for _, domain := range sr.ServiceDomain {
+			entries[strings.ToLower(domain)] = name + "-be"
+		}
+	}
+	return entries
+}
+
+// WriteDomainMap rebuilds the map file from the configured services. It returns the entries the file held before
+// together with the new ones.
+func WriteDomainMap(configsPath string) (previous, current map[string]string, err error) {
+	path := GetDomainMapPath(configsPath)
+	previous = map[string]string{}
+	if content, err := readDomainMapFile(path); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 {
+				previous[fields[0]] = fields[1]
+			}
+		}
+	}
+	current = GetDomainMap()
+	domains := []string{}
+	for domain := range current {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	content := ""
+	for _, domain := range domains {
+		content += fmt.Sprintf("%s %s\n", domain, current[domain])
+	}
+	if err := writeDomainMapFile(path, []byte(content), 0664); err != nil {
+		return previous, current, fmt.Errorf("Could not write the map %s\n%s", path, err.Error())
+	}
+	return previous, current, nil
+}
+
+// applyDomainMap writes the map file and tells whether the running proxy was updated through the admin socket. That
+// is possible only if the service was already using the map and nothing but its domains changed. Otherwise, or if the
+// socket update fails, the proxy has to be reloaded.
+func (m *Reconfigure) applyDomainMap(stored ServiceReconfigure, wasStored bool) bool {
+	previous, current, err := WriteDomainMap(m.ConfigsPath)
+	if err != nil {
+		logPrintf(err.Error())
+		return false
+	}
+	if !wasStored || !stored.UseDomainMap || !m.UseDomainMap || len(previous) == 0 {
+		return false
+	}
+	stored.ServiceDomain = m.ServiceDomain
+	if !reflect.DeepEqual(stored, m.ServiceReconfigure) {
+		return false
+	}
+	if err := updateDomainMap(GetDomainMapPath(m.ConfigsPath), previous, current); err != nil {
+		logPrintf("%s\nThe proxy will be reloaded", err.Error())
+		return false
+	}
+	logPrintf("The domains of the service %s were updated without reloading the proxy", m.ServiceName)
+	return true
+}
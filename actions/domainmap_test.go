@@ -0,0 +1,178 @@
+// +build !integration
+
+package actions
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"testing"
+
+	haproxy "../proxy"
+)
+
+type DomainMapTestSuite struct {
+	suite.Suite
+	mapContent string
+	written    map[string]string
+	updates    [][]map[string]string
+	updateErr  error
+	proxyMock  *ProxyMock
+	proxyOrig  haproxy.Proxy
+}
+
+func (s *DomainMapTestSuite) SetupTest() {
+	configuredServices = map[string]ServiceReconfigure{}
+	knownServices = map[string]bool{}
+	s.mapContent = ""
+	s.written = map[string]string{}
+	s.updates = [][]map[string]string{}
+	s.updateErr = nil
+	readDomainMapFile = func(filename string) ([]byte, error) {
+		if len(s.mapContent) == 0 {
+			return nil, os.ErrNotExist
+		}
+		return []byte(s.mapContent), nil
+	}
+	writeDomainMapFile = func(filename string, data []byte, perm os.FileMode) error {
+		s.written[filename] = string(data)
+		return nil
+	}
+	updateDomainMap = func(path string, previous, current map[string]string) error {
+		s.updates = append(s.updates, []map[string]string{previous, current})
+		return s.updateErr
+	}
+	writeFeTemplate = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+	writeBeTemplate = writeFeTemplate
+	s.proxyOrig = haproxy.Instance
+	s.proxyMock = getProxyMock("")
+	haproxy.Instance = s.proxyMock
+}
+
+func (s *DomainMapTestSuite) TearDownTest() {
+	haproxy.Instance = s.proxyOrig
+	configuredServices = map[string]ServiceReconfigure{}
+	knownServices = map[string]bool{}
+}
+
+// GetDomainMap
+
+func (s *DomainMapTestSuite) Test_GetDomainMap_ReturnsBackendsOfServicesThatUseDomainMap() {
+	storeService("service-1", ServiceReconfigure{ServiceName: "service-1", ServiceDomain: []string{"Customer-1.com", "customer-2.com"}, UseDomainMap: true})
+	storeService("my-acl", ServiceReconfigure{ServiceName: "service-2", AclName: "my-acl", ServiceDomain: []string{"customer-3.com"}, UseDomainMap: true})
+	storeService("service-3", ServiceReconfigure{ServiceName: "service-3", ServiceDomain: []string{"service-3.com"}})
+
+	actual := GetDomainMap()
+
+	s.Equal(map[string]string{
+		"customer-1.com": "service-1-be",
+		"customer-2.com": "service-1-be",
+		"customer-3.com": "my-acl-be",
+	}, actual)
+}
+
+// WriteDomainMap
+
+func (s *DomainMapTestSuite) Test_WriteDomainMap_WritesSortedEntriesAndReturnsPreviousOnes() {
+	s.mapContent = "customer-1.com service-1-be\nold.com old-be\n"
+	storeService("service-1", ServiceReconfigure{ServiceName: "service-1", ServiceDomain: []string{"customer-2.com", "customer-1.com"}, UseDomainMap: true})
+
+	previous, current, err := WriteDomainMap("/cfg")
+
+	s.NoError(err)
+	s.Equal(map[string]string{"customer-1.com": "service-1-be", "old.com": "old-be"}, previous)
+	s.Equal(map[string]string{"customer-1.com": "service-1-be", "customer-2.com": "service-1-be"}, current)
+	s.Equal(map[string]string{"/cfg/domains.map": "customer-1.com service-1-be\ncustomer-2.com service-1-be\n"}, s.written)
+}
+
+func (s *DomainMapTestSuite) Test_WriteDomainMap_ReturnsError_WhenFileCannotBeWritten() {
+	writeDomainMapFile = func(filename string, data []byte, perm os.FileMode) error {
+		return fmt.Errorf("This is an error")
+	}
+
+	_, _, err := WriteDomainMap("/cfg")
+
+	s.Error(err)
+}
+
+// Execute
+
+func (s *DomainMapTestSuite) Test_Execute_UpdatesMapThroughSocketWithoutReload_WhenOnlyDomainsChanged() {
+	storeService("service-1", ServiceReconfigure{ServiceName: "service-1", AclName: "service-1", ServiceDomain: []string{"customer-1.com"}, UseDomainMap: true, Mode: "swarm", Port: "8080"})
+	s.mapContent = "customer-1.com service-1-be\n"
+	sr := ServiceReconfigure{ServiceName: "service-1", ServiceDomain: []string{"customer-1.com", "customer-2.com"}, UseDomainMap: true, Mode: "swarm", Port: "8080"}
+
+	err := s.getReconfigure(sr).Execute([]string{})
+
+	s.NoError(err)
+	s.Equal([][]map[string]string{{
+		{"customer-1.com": "service-1-be"},
+		{"customer-1.com": "service-1-be", "customer-2.com": "service-1-be"},
+	}}, s.updates)
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+	s.Equal("customer-1.com service-1-be\ncustomer-2.com service-1-be\n", s.written["/cfg/domains.map"])
+}
+
+func (s *DomainMapTestSuite) Test_Execute_Reloads_WhenSocketUpdateFails() {
+	storeService("service-1", ServiceReconfigure{ServiceName: "service-1", AclName: "service-1", ServiceDomain: []string{"customer-1.com"}, UseDomainMap: true, Mode: "swarm", Port: "8080"})
+	s.mapContent = "customer-1.com service-1-be\n"
+	s.updateErr = fmt.Errorf("This is an error")
+	sr := ServiceReconfigure{ServiceName: "service-1", ServiceDomain: []string{"customer-2.com"}, UseDomainMap: true, Mode: "swarm", Port: "8080"}
+
+	err := s.getReconfigure(sr).Execute([]string{})
+
+	s.NoError(err)
+	s.Len(s.updates, 1)
+	s.proxyMock.AssertCalled(s.T(), "CreateConfigFromTemplates")
+	s.proxyMock.AssertCalled(s.T(), "Reload")
+}
+
+func (s *DomainMapTestSuite) Test_Execute_Reloads_WhenOtherParametersChanged() {
+	storeService("service-1", ServiceReconfigure{ServiceName: "service-1", AclName: "service-1", ServiceDomain: []string{"customer-1.com"}, UseDomainMap: true, Mode: "swarm", Port: "8080"})
+	s.mapContent = "customer-1.com service-1-be\n"
+	sr := ServiceReconfigure{ServiceName: "service-1", ServiceDomain: []string{"customer-2.com"}, UseDomainMap: true, Mode: "swarm", Port: "9090"}
+
+	err := s.getReconfigure(sr).Execute([]string{})
+
+	s.NoError(err)
+	s.Empty(s.updates)
+	s.proxyMock.AssertCalled(s.T(), "Reload")
+}
+
+func (s *DomainMapTestSuite) Test_Execute_Reloads_WhenServiceIsNew() {
+	sr := ServiceReconfigure{ServiceName: "service-1", ServiceDomain: []string{"customer-1.com"}, UseDomainMap: true, Mode: "swarm", Port: "8080"}
+
+	err := s.getReconfigure(sr).Execute([]string{})
+
+	s.NoError(err)
+	s.Empty(s.updates)
+	s.proxyMock.AssertCalled(s.T(), "Reload")
+	s.Equal("customer-1.com service-1-be\n", s.written["/cfg/domains.map"])
+}
+
+func (s *DomainMapTestSuite) getReconfigure(sr ServiceReconfigure) *Reconfigure {
+	r := &Reconfigure{BaseReconfigure{ConfigsPath: "/cfg", TemplatesPath: "/cfg/tmpl"}, sr}
+	r.skipAddressValidation = true
+	return r
+}
+
+// Suite
+
+func TestDomainMapUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	readDomainMapFileOrig := readDomainMapFile
+	writeDomainMapFileOrig := writeDomainMapFile
+	updateDomainMapOrig := updateDomainMap
+	writeFeTemplateOrig := writeFeTemplate
+	writeBeTemplateOrig := writeBeTemplate
+	defer func() {
+		readDomainMapFile = readDomainMapFileOrig
+		writeDomainMapFile = writeDomainMapFileOrig
+		updateDomainMap = updateDomainMapOrig
+		writeFeTemplate = writeFeTemplateOrig
+		writeBeTemplate = writeBeTemplateOrig
+	}()
+	suite.Run(t, new(DomainMapTestSuite))
+}
@@ -0,0 +1,67 @@
+package actions
+
+import (
+	"net/url"
+	"os"
+)
+
+// parameterDefaults lists the parameters services inherit from the defaults section of the proxy, the environment
+// variables that set them and the values the proxy uses if the variables are not set.
+var parameterDefaults = []struct {
+	name  string
+	env   string
+	value string
+}{
+	{"timeoutConnect", "TIMEOUT_CONNECT", "5"},
+	{"timeoutClient", "TIMEOUT_CLIENT", "20"},
+	{"timeoutServer", "TIMEOUT_SERVER", "20"},
+	{"timeoutQueue", "TIMEOUT_QUEUE", "30"},
+	{"timeoutHttpRequest", "TIMEOUT_HTTP_REQUEST", "5"},
+	{"users", "USERS", ""},
+}
+
+// GetEffectiveService returns the configured service with the defaults of the proxy applied to the parameters it does
+// not set, together with the source of each parameter (request, profile or default). Non-explicit values of a service
+// with a profile are the ones the profile contributed when the service was reconfigured, so the result reflects the
+// running configuration even if the profile was redefined since.
+func GetEffectiveService(sr ServiceReconfigure) (ServiceReconfigure, map[string]string) {
+	query := EncodeParameters(ReconfigureParameters, sr)
+	explicit := getExplicitParameters(ServiceReconfigure{}, query)
+	profile := map[string]string{}
+	if len(sr.Profile) > 0 {
+		explicit = getExplicitParameters(sr, query)
+		profile = getProfileValues(query, explicit)
+	}
+	merged, sources := mergeParameters(query, explicit, profile, getParameterDefaults())
+	effective := DecodeParameters(ReconfigureParameters, merged)
+	effective.Mode = sr.Mode
+	effective.ExplicitParameters = sr.ExplicitParameters
+	return effective, sources
+}
+
+func getParameterDefaults() map[string]string {
+	defaults := map[string]string{}
+	for _, d := range parameterDefaults {
+		if value := os.Getenv(d.env); len(value) > 0 {
+			defaults[d.name] = value
+		} else if len(d.value) > 0 {
+			defaults[d.name] = d.value
+		}
+	}
+	return defaults
+}
+
+// getProfileValues returns the values of the query that were not set explicitly.
+func getProfileValues(query url.Values, explicit []string) map[string]string {
+	isExplicit := map[string]bool{}
+	for _, key := range explicit {
+		isExplicit[key] = true
+	}
+	values := map[string]string{}
+	for key := range query {
+		if !isExplicit[key] {
+			values[key] = query.Get(key)
+		}
+	}
+	return values
+}
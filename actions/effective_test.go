@@ -0,0 +1,126 @@
+// +build !integration
+
+package actions
+
+import (
+	"github.com/stretchr/testify/suite"
+	"os"
+	"testing"
+)
+
+type EffectiveTestSuite struct {
+	suite.Suite
+}
+
+func (s *EffectiveTestSuite) SetupTest() {
+	for _, d := range parameterDefaults {
+		os.Unsetenv(d.env)
+	}
+}
+
+// GetEffectiveService
+
+func (s *EffectiveTestSuite) Test_GetEffectiveService_AppliesDefaults() {
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/api"}, Port: "8080", Mode: "swarm"}
+
+	actual, sources := GetEffectiveService(sr)
+
+	s.Equal(ServiceReconfigure{
+		ServiceName:        "my-service",
+		ServicePath:        []string{"/api"},
+		Port:               "8080",
+		Mode:               "swarm",
+		TimeoutConnect:     5,
+		TimeoutClient:      20,
+		TimeoutServer:      20,
+		TimeoutQueue:       30,
+		TimeoutHttpRequest: 5,
+	}, actual)
+	s.Equal(map[string]string{
+		"serviceName":        SourceRequest,
+		"servicePath":        SourceRequest,
+		"port":               SourceRequest,
+		"timeoutConnect":     SourceDefault,
+		"timeoutClient":      SourceDefault,
+		"timeoutServer":      SourceDefault,
+		"timeoutQueue":       SourceDefault,
+		"timeoutHttpRequest": SourceDefault,
+	}, sources)
+}
+
+func (s *EffectiveTestSuite) Test_GetEffectiveService_TakesDefaultsFromEnvironmentVariables() {
+	os.Setenv("TIMEOUT_SERVER", "45")
+	os.Setenv("USERS", "user-1:pass-1")
+	defer func() {
+		os.Unsetenv("TIMEOUT_SERVER")
+		os.Unsetenv("USERS")
+	}()
+	sr := ServiceReconfigure{ServiceName: "my-service"}
+
+	actual, sources := GetEffectiveService(sr)
+
+	s.Equal(45, actual.TimeoutServer)
+	s.Equal([]User{{Username: "user-1", Password: "pass-1"}}, actual.Users)
+	s.Equal(SourceDefault, sources["users"])
+}
+
+func (s *EffectiveTestSuite) Test_GetEffectiveService_ReturnsRequestSource_WhenValueOverridesDefault() {
+	sr := ServiceReconfigure{ServiceName: "my-service", TimeoutServer: 60}
+
+	actual, sources := GetEffectiveService(sr)
+
+	s.Equal(60, actual.TimeoutServer)
+	s.Equal(SourceRequest, sources["timeoutServer"])
+	s.Equal(SourceDefault, sources["timeoutQueue"])
+}
+
+func (s *EffectiveTestSuite) Test_GetEffectiveService_ReturnsProfileSource_WhenValueIsNotExplicit() {
+	sr := ServiceReconfigure{
+		ServiceName:        "my-service",
+		Profile:            "public-api",
+		PathType:           "path_beg",
+		CorsOrigins:        []string{"*"},
+		TimeoutServer:      90,
+		ColorAddresses:     map[string]string{"blue": "10.0.0.1"},
+		ExplicitParameters: []string{"serviceName", "profile", "pathType"},
+	}
+
+	actual, sources := GetEffectiveService(sr)
+
+	s.Equal("path_beg", actual.PathType)
+	s.Equal([]string{"*"}, actual.CorsOrigins)
+	s.Equal(90, actual.TimeoutServer)
+	s.Equal(map[string]string{"blue": "10.0.0.1"}, actual.ColorAddresses)
+	s.Equal([]string{"serviceName", "profile", "pathType"}, actual.ExplicitParameters)
+	s.Equal(map[string]string{
+		"serviceName":        SourceRequest,
+		"profile":            SourceRequest,
+		"pathType":           SourceRequest,
+		"addr.blue":          SourceRequest,
+		"corsOrigins":        SourceProfile,
+		"timeoutServer":      SourceProfile,
+		"timeoutConnect":     SourceDefault,
+		"timeoutClient":      SourceDefault,
+		"timeoutQueue":       SourceDefault,
+		"timeoutHttpRequest": SourceDefault,
+	}, sources)
+}
+
+func (s *EffectiveTestSuite) Test_GetEffectiveService_ReturnsDefaultSource_WhenExplicitValueIsEmpty() {
+	sr := ServiceReconfigure{
+		ServiceName:        "my-service",
+		Profile:            "public-api",
+		ExplicitParameters: []string{"serviceName", "profile", "timeoutServer"},
+	}
+
+	actual, sources := GetEffectiveService(sr)
+
+	s.Equal(20, actual.TimeoutServer)
+	s.Equal(SourceDefault, sources["timeoutServer"])
+}
+
+// Suite
+
+func TestEffectiveUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EffectiveTestSuite))
+}
@@ -0,0 +1,80 @@
+package actions
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// serviceFileRegexp matches the configuration files created for services. Other files are never collected.
+var serviceFileRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+-(internal-fe|fe|be)\.cfg$`)
+
+// knownServices holds the names used for the configuration files of the services the proxy knows about.
+var knownServices = map[string]bool{}
+var knownServicesMu = &sync.Mutex{}
+
+// GarbageResult summarizes a garbage collection of service configuration files.
+type GarbageResult struct {
+	DryRun  bool
+	Orphans []string
+	Failed  map[string]string
+}
+
+// AddKnownService marks the configuration files of the service as referenced.
+func AddKnownService(name string) {
+	knownServicesMu.Lock()
+	defer knownServicesMu.Unlock()
+	knownServices[name] = true
+}
+
+// RemoveKnownService marks the configuration files of the service as no longer referenced.
+func RemoveKnownService(name string) {
+	knownServicesMu.Lock()
+	defer knownServicesMu.Unlock()
+	delete(knownServices, name)
+	delete(configuredServices, name)
+}
+
+// CollectGarbage deletes service configuration files that do not belong to any known service. If dryRun is true,
+// the orphans are only reported.
+func CollectGarbage(paths []string, dryRun bool) (GarbageResult, error) {
+	result := GarbageResult{DryRun: dryRun, Orphans: []string{}, Failed: map[string]string{}}
+	knownServicesMu.Lock()
+	defer knownServicesMu.Unlock()
+	for _, path := range paths {
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			return result, fmt.Errorf("Could not read the directory %s\n%s", path, err.Error())
+		}
+		for _, file := range files {
+			if file.IsDir() || !serviceFileRegexp.MatchString(file.Name()) || isKnownServiceFile(file.Name()) {
+				continue
+			}
+			orphan := fmt.Sprintf("%s/%s", path, file.Name())
+			if !dryRun {
+				if err := os.Remove(orphan); err != nil {
+					result.Failed[orphan] = err.Error()
+					continue
+				}
+				logPrintf("Removed the orphaned configuration %s", orphan)
+			}
+			result.Orphans = append(result.Orphans, orphan)
+		}
+	}
+	sort.Strings(result.Orphans)
+	return result, nil
+}
+
+// isKnownServiceFile checks all the names the file might belong to since service names can end with -internal.
+func isKnownServiceFile(filename string) bool {
+	for _, suffix := range []string{"-internal-fe.cfg", "-fe.cfg", "-be.cfg"} {
+		if strings.HasSuffix(filename, suffix) && knownServices[strings.TrimSuffix(filename, suffix)] {
+			return true
+		}
+	}
+	return false
+}
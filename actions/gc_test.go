@@ -0,0 +1,106 @@
+// +build !integration
+
+package actions
+
+import (
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type GcTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (s *GcTestSuite) SetupTest() {
+	s.dir, _ = ioutil.TempDir("", "templates")
+	for _, file := range []string{
+		"haproxy.tmpl",
+		"service-formatted-fe.ctmpl",
+		"my-service-fe.cfg",
+		"my-service-be.cfg",
+		"my-internal-service-internal-fe.cfg",
+		"my-internal-service-be.cfg",
+		"removed-service-fe.cfg",
+		"removed-service-be.cfg",
+		"notes.txt",
+	} {
+		ioutil.WriteFile(s.dir+"/"+file, []byte("content"), 0664)
+	}
+	knownServices = map[string]bool{}
+	AddKnownService("my-service")
+	AddKnownService("my-internal-service")
+}
+
+func (s *GcTestSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+}
+
+// CollectGarbage
+
+func (s *GcTestSuite) Test_CollectGarbage_RemovesOrphans() {
+	actual, err := CollectGarbage([]string{s.dir}, false)
+
+	s.NoError(err)
+	s.Equal([]string{s.dir + "/removed-service-be.cfg", s.dir + "/removed-service-fe.cfg"}, actual.Orphans)
+	s.Equal([]string{
+		"haproxy.tmpl",
+		"my-internal-service-be.cfg",
+		"my-internal-service-internal-fe.cfg",
+		"my-service-be.cfg",
+		"my-service-fe.cfg",
+		"notes.txt",
+		"service-formatted-fe.ctmpl",
+	}, s.getFiles())
+}
+
+func (s *GcTestSuite) Test_CollectGarbage_KeepsOrphans_WhenDryRunIsTrue() {
+	actual, err := CollectGarbage([]string{s.dir}, true)
+
+	s.NoError(err)
+	s.True(actual.DryRun)
+	s.Len(actual.Orphans, 2)
+	s.Len(s.getFiles(), 9)
+}
+
+func (s *GcTestSuite) Test_CollectGarbage_KeepsFiles_WhenServiceNameEndsWithInternal() {
+	AddKnownService("my-service-internal")
+	ioutil.WriteFile(s.dir+"/my-service-internal-fe.cfg", []byte("content"), 0664)
+
+	actual, _ := CollectGarbage([]string{s.dir}, true)
+
+	s.NotContains(actual.Orphans, s.dir+"/my-service-internal-fe.cfg")
+}
+
+func (s *GcTestSuite) Test_CollectGarbage_RemovesFiles_WhenServiceWasRemoved() {
+	RemoveKnownService("my-service")
+
+	actual, _ := CollectGarbage([]string{s.dir}, true)
+
+	s.Contains(actual.Orphans, s.dir+"/my-service-fe.cfg")
+	s.Contains(actual.Orphans, s.dir+"/my-service-be.cfg")
+}
+
+func (s *GcTestSuite) Test_CollectGarbage_ReturnsError_WhenDirectoryDoesNotExist() {
+	_, err := CollectGarbage([]string{s.dir + "/does-not-exist"}, true)
+
+	s.Error(err)
+}
+
+func (s *GcTestSuite) getFiles() []string {
+	files := []string{}
+	infos, _ := ioutil.ReadDir(s.dir)
+	for _, info := range infos {
+		files = append(files, info.Name())
+	}
+	return files
+}
+
+// Suite
+
+func TestGcUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(GcTestSuite))
+}
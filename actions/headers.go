@@ -0,0 +1,91 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var headerNameRegexp = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// SplitHeader returns the name and the value of a header sent as the name and the value separated by a space or a
+// colon (e.g. X-Forwarded-Prefix /api or X-Forwarded-Prefix:/api).
+func SplitHeader(header string) (name, value string) {
+	header = strings.TrimSpace(header)
+	i := strings.IndexAny(header, " :")
+	if i < 0 {
+		return header, ""
+	}
+	return header[:i], strings.TrimSpace(header[i+1:])
+}
+
+// ValidateHeaders makes sure that the headers of the query are valid header names followed, if withValue is true, by
+// a value.
+func ValidateHeaders(query string, headers []string, withValue bool) error {
+	for _, header := range headers {
+		name, value := SplitHeader(header)
+		if !headerNameRegexp.MatchString(name) {
+			return fmt.Errorf("The %s query contains the invalid header name %s", query, name)
+		} else if withValue && len(value) == 0 {
+			return fmt.Errorf("The %s query must contain a value for the header %s", query, name)
+		} else if !withValue && len(value) > 0 {
+			return fmt.Errorf("The %s query must contain only header names", query)
+		} else if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("The value of the header %s cannot contain new lines", name)
+		}
+	}
+	return nil
+}
+
+// FormatHeaders converts the headers into the JSON stored in the registry. Headers can contain commas so they cannot
+// be stored as comma-separated lists.
+func FormatHeaders(headers []string) string {
+	if len(headers) == 0 {
+		return ""
+	}
+	value, _ := json.Marshal(headers)
+	return string(value)
+}
+
+// ParseHeaders is the inverse of FormatHeaders.
+func ParseHeaders(value string) []string {
+	var headers []string
+	json.Unmarshal([]byte(value), &headers)
+	return headers
+}
+
+// GetReqHeaderRules returns the http-request rules that delete, set and add the request headers of the service.
+func (sr ServiceReconfigure) GetReqHeaderRules() []string {
+	return getHeaderRules(sr.DelReqHeader, sr.SetReqHeader, sr.AddReqHeader)
+}
+
+// GetResHeaderRules returns the http-response rules that delete and add the response headers of the service.
+func (sr ServiceReconfigure) GetResHeaderRules() []string {
+	return getHeaderRules(sr.DelResHeader, nil, sr.AddResHeader)
+}
+
+// getHeaderRules deletes the headers before it sets and adds them so that a header can be replaced. Values are escaped
+// since they are written to the configuration as single arguments. HAProxy reads them as log formats so percent signs
+// are doubled to be sent as they are.
+func getHeaderRules(del, set, add []string) []string {
+	escaper := strings.NewReplacer(`\`, `\\`, " ", `\ `, `"`, `\"`, "'", `\'`, "#", `\#`, "%", "%%")
+	rules := []string{}
+	for _, header := range del {
+		name, _ := SplitHeader(header)
+		rules = append(rules, "del-header "+name)
+	}
+	for _, action := range []struct {
+		rule    string
+		headers []string
+	}{
+		{"set-header", set},
+		{"add-header", add},
+	} {
+		for _, header := range action.headers {
+			name, value := SplitHeader(header)
+			rules = append(rules, fmt.Sprintf("%s %s %s", action.rule, name, escaper.Replace(value)))
+		}
+	}
+	return rules
+}
@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// HttpDestination exposes another port of the service over HTTP through its own backend. The destination uses the port
+// and the domains of the service unless it sets its own.
+type HttpDestination struct {
+	Port          string   `json:"port"`
+	ServicePath   []string `json:"servicePath"`
+	ServiceDomain []string `json:"serviceDomain"`
+}
+
+// FormatHttpDestinations converts the destinations into the JSON stored in the registry.
+func FormatHttpDestinations(dests []HttpDestination) string {
+	if len(dests) == 0 {
+		return ""
+	}
+	value, _ := json.Marshal(dests)
+	return string(value)
+}
+
+// ParseHttpDestinations is the inverse of FormatHttpDestinations.
+func ParseHttpDestinations(value string) []HttpDestination {
+	var dests []HttpDestination
+	json.Unmarshal([]byte(value), &dests)
+	return dests
+}
+
+// getHttpDestinationTemplates returns the ACLs and the backends of the additional http destinations of the service.
+// Each destination reuses the templates of the service with its own ACL names. A destination with another port gets
+// its own backend named after its index and its port (e.g. my-service_1-8081-be), so that destinations with the same
+// port never collide, and those with the port of the service use its backend.
+func (m *Reconfigure) getHttpDestinationTemplates(sr ServiceReconfigure, maintenanceFront string) (front, back string) {
+	for i, dest := range sr.HttpDestinations {
+		d := sr
+		d.ServiceName = fmt.Sprintf("%s_%d", sr.ServiceName, i+1)
+		d.ServicePath = dest.ServicePath
+		d.ServiceDomain = append([]string{}, sr.ServiceDomain...)
+		if len(dest.ServiceDomain) > 0 {
+			d.ServiceDomain = append([]string{}, dest.ServiceDomain...)
+		}
+		if len(dest.Port) > 0 {
+			d.Port = dest.Port
+		}
+		if d.Port != sr.Port {
+			d.AclName = fmt.Sprintf("%s_%d-%s", sr.AclName, i+1, d.Port)
+		}
+		// Canaries, maintenance, httpsPort and the backup server are served by the backends of the service itself
+		d.CanaryHeader = ""
+		d.HttpsPort = ""
+		d.BackupHostname = ""
+		// The extra rules are added once, next to the ACLs of the service
+		d.FrontendExtra = ""
+		m.formatData(&d)
+		d.Host = sr.Host
+		destFront, destBack := m.parseTemplate(
+			m.getFrontTemplate(&d, strings.Replace(maintenanceFront, "{{.AclName}}", sr.AclName, -1)),
+			m.getBackTemplate(&d),
+			d)
+		front += destFront
+		if d.Port != sr.Port {
+			back += "\n\n" + destBack
+		}
+	}
+	return front, back
+}
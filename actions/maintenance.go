@@ -0,0 +1,159 @@
+package actions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maintenanceNow is the clock maintenance windows are evaluated against.
+var maintenanceNow = time.Now
+
+// MaintenanceWindow is a daily period, in UTC, during which a service is in maintenance. A window that ends before it
+// starts lasts until the next day. If Days is not empty, the window opens only on those days.
+type MaintenanceWindow struct {
+	Start time.Duration
+	End   time.Duration
+	Days  []time.Weekday
+}
+
+// ParseMaintenanceWindow parses windows formatted as HH:MM-HH:MM, optionally followed by @ and a comma-separated
+// list of days (e.g. 02:00-03:00@Sat,Sun).
+func ParseMaintenanceWindow(value string) (*MaintenanceWindow, error) {
+	invalid := fmt.Errorf("The maintenance window %s is not valid\nThe format is HH:MM-HH:MM, optionally followed by @ and a comma-separated list of days (e.g. 02:00-03:00@Sat,Sun)", value)
+	period, days := value, []string{}
+	if i := strings.Index(value, "@"); i >= 0 {
+		period, days = value[:i], strings.Split(value[i+1:], ",")
+	}
+	times := strings.Split(period, "-")
+	if len(times) != 2 {
+		return nil, invalid
+	}
+	window := MaintenanceWindow{}
+	var err error
+	if window.Start, err = parseTimeOfDay(times[0]); err != nil {
+		return nil, invalid
+	} else if window.End, err = parseTimeOfDay(times[1]); err != nil || window.Start == window.End {
+		return nil, invalid
+	}
+	for _, day := range days {
+		weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]
+		if !ok {
+			return nil, invalid
+		}
+		window.Days = append(window.Days, weekday)
+	}
+	return &window, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("%s is not a time of day", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, fmt.Errorf("%s is not a time of day", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("%s is not a time of day", value)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// Contains returns whether the window is open at the time.
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	// The window that contains the time opened either today or, if it lasts until the next day, yesterday
+	for _, start := range w.getStarts(t, -1, 0) {
+		if !t.Before(start) && t.Before(start.Add(w.getLength())) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextChange returns the first time after t the window opens or closes.
+func (w MaintenanceWindow) NextChange(t time.Time) time.Time {
+	t = t.UTC()
+	next := time.Time{}
+	for _, start := range w.getStarts(t, -1, 7) {
+		for _, change := range []time.Time{start, start.Add(w.getLength())} {
+			if change.After(t) && (next.IsZero() || change.Before(next)) {
+				next = change
+			}
+		}
+	}
+	return next
+}
+
+func (w MaintenanceWindow) getLength() time.Duration {
+	if w.End > w.Start {
+		return w.End - w.Start
+	}
+	return 24*time.Hour - w.Start + w.End
+}
+
+// getStarts returns the times the window opens on the days from the offset first to the offset last relative to t.
+func (w MaintenanceWindow) getStarts(t time.Time, first, last int) []time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	starts := []time.Time{}
+	for offset := first; offset <= last; offset++ {
+		day := midnight.AddDate(0, 0, offset)
+		if w.opensOn(day.Weekday()) {
+			starts = append(starts, day.Add(w.Start))
+		}
+	}
+	return starts
+}
+
+func (w MaintenanceWindow) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInMaintenance returns whether the service is in maintenance at the time. The maintenance query, once set, wins
+// over the maintenance window until it is cleared.
+func (sr ServiceReconfigure) IsInMaintenance(t time.Time) bool {
+	if manual, err := strconv.ParseBool(sr.Maintenance); err == nil {
+		return manual
+	} else if len(sr.MaintenanceWindow) == 0 {
+		return false
+	}
+	window, err := ParseMaintenanceWindow(sr.MaintenanceWindow)
+	return err == nil && window.Contains(t)
+}
+
+// getMaintenanceTemplates route the requests of the service to a backend without servers, so that they are answered
+// with the status 503, while the service is in maintenance.
+func (m *Reconfigure) getMaintenanceTemplates(inMaintenance bool) (front, back string) {
+	if !inMaintenance {
+		return "", ""
+	}
+	front = `
+    use_backend {{.AclName}}-maintenance-be if url_{{.ServiceName}}{{.AclCondition}}`
+	back = `
+
+backend {{.AclName}}-maintenance-be
+    mode http`
+	return front, back
+}
@@ -0,0 +1,115 @@
+// +build !integration
+
+package actions
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type MaintenanceTestSuite struct {
+	suite.Suite
+}
+
+// ParseMaintenanceWindow
+
+func (s MaintenanceTestSuite) Test_ParseMaintenanceWindow_ReturnsWindow() {
+	cases := map[string]MaintenanceWindow{
+		"02:00-03:00":         {Start: 2 * time.Hour, End: 3 * time.Hour},
+		"23:30-01:15":         {Start: 23*time.Hour + 30*time.Minute, End: time.Hour + 15*time.Minute},
+		"02:00-03:00@Sat,sun": {Start: 2 * time.Hour, End: 3 * time.Hour, Days: []time.Weekday{time.Saturday, time.Sunday}},
+	}
+	for value, expected := range cases {
+		actual, err := ParseMaintenanceWindow(value)
+
+		s.NoError(err, value)
+		s.Equal(expected, *actual, value)
+	}
+}
+
+func (s MaintenanceTestSuite) Test_ParseMaintenanceWindow_ReturnsError_WhenWindowIsNotValid() {
+	for _, value := range []string{"", "02:00", "2:00-3:00", "24:00-01:00", "02:60-03:00", "02:00-02:00", "02:00-03:00@Someday", "02:00-03:00@"} {
+		_, err := ParseMaintenanceWindow(value)
+
+		s.Error(err, value)
+	}
+}
+
+// Contains
+
+func (s MaintenanceTestSuite) Test_Contains_ReturnsWhetherWindowIsOpen() {
+	// June 3rd 2017 is a Saturday
+	cases := []struct {
+		window   string
+		time     time.Time
+		expected bool
+	}{
+		{"02:00-03:00", time.Date(2017, 6, 1, 1, 59, 0, 0, time.UTC), false},
+		{"02:00-03:00", time.Date(2017, 6, 1, 2, 0, 0, 0, time.UTC), true},
+		{"02:00-03:00", time.Date(2017, 6, 1, 3, 0, 0, 0, time.UTC), false},
+		{"23:00-01:00", time.Date(2017, 6, 1, 0, 30, 0, 0, time.UTC), true},
+		{"23:00-01:00", time.Date(2017, 6, 1, 23, 30, 0, 0, time.UTC), true},
+		{"02:00-03:00@Sat", time.Date(2017, 6, 2, 2, 30, 0, 0, time.UTC), false},
+		{"02:00-03:00@Sat", time.Date(2017, 6, 3, 2, 30, 0, 0, time.UTC), true},
+		// The window opened on Saturday lasts until Sunday
+		{"23:00-01:00@Sat", time.Date(2017, 6, 4, 0, 30, 0, 0, time.UTC), true},
+		{"23:00-01:00@Sat", time.Date(2017, 6, 3, 0, 30, 0, 0, time.UTC), false},
+		// Windows are in UTC
+		{"02:00-03:00", time.Date(2017, 6, 1, 4, 30, 0, 0, time.FixedZone("CEST", 2*60*60)), true},
+	}
+	for _, c := range cases {
+		window, _ := ParseMaintenanceWindow(c.window)
+
+		s.Equal(c.expected, window.Contains(c.time), c.window+" at "+c.time.String())
+	}
+}
+
+// NextChange
+
+func (s MaintenanceTestSuite) Test_NextChange_ReturnsTimeWindowOpensOrCloses() {
+	cases := []struct {
+		window   string
+		time     time.Time
+		expected time.Time
+	}{
+		{"02:00-03:00", time.Date(2017, 6, 1, 1, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 2, 0, 0, 0, time.UTC)},
+		{"02:00-03:00", time.Date(2017, 6, 1, 2, 0, 0, 0, time.UTC), time.Date(2017, 6, 1, 3, 0, 0, 0, time.UTC)},
+		{"02:00-03:00", time.Date(2017, 6, 1, 3, 0, 0, 0, time.UTC), time.Date(2017, 6, 2, 2, 0, 0, 0, time.UTC)},
+		{"23:00-01:00", time.Date(2017, 6, 1, 23, 30, 0, 0, time.UTC), time.Date(2017, 6, 2, 1, 0, 0, 0, time.UTC)},
+		{"02:00-03:00@Sat", time.Date(2017, 6, 3, 3, 0, 0, 0, time.UTC), time.Date(2017, 6, 10, 2, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		window, _ := ParseMaintenanceWindow(c.window)
+
+		s.Equal(c.expected, window.NextChange(c.time), c.window+" at "+c.time.String())
+	}
+}
+
+// IsInMaintenance
+
+func (s MaintenanceTestSuite) Test_IsInMaintenance_PrefersMaintenanceQueryOverWindow() {
+	inWindow := time.Date(2017, 6, 1, 2, 30, 0, 0, time.UTC)
+	outsideWindow := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		maintenance string
+		time        time.Time
+		expected    bool
+	}{
+		{"", inWindow, true},
+		{"", outsideWindow, false},
+		{"true", outsideWindow, true},
+		{"false", inWindow, false},
+	}
+	for _, c := range cases {
+		sr := ServiceReconfigure{Maintenance: c.maintenance, MaintenanceWindow: "02:00-03:00"}
+
+		s.Equal(c.expected, sr.IsInMaintenance(c.time), c.maintenance+" at "+c.time.String())
+	}
+}
+
+// Suite
+
+func TestMaintenanceUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(MaintenanceTestSuite))
+}
@@ -0,0 +1,54 @@
+package actions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxServiceNameLength is the maximum length of service and ACL names.
+const MaxServiceNameLength = 64
+
+var invalidNameCharRegexp = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// ReservedServiceNames cannot be used as service or ACL names since the generated sections would collide with the
+// ones defined in haproxy.tmpl (e.g. the defaultUsers userlist or the dummy-be backend) or with HAProxy keywords.
+var ReservedServiceNames = []string{
+	"backend",
+	"default",
+	"defaults",
+	"dummy",
+	"frontend",
+	"global",
+	"internal",
+	"listen",
+	"services",
+	"stats",
+	"userlist",
+}
+
+// CanonicalServiceName returns the name under which the service is configured and stored. HAProxy matches names
+// regardless of their case so services whose names differ only by case are the same service.
+func CanonicalServiceName(name string) string {
+	return strings.ToLower(name)
+}
+
+// ValidateServiceName returns an error if the name cannot be used in HAProxy section names. Invalid characters are
+// enclosed in square brackets (e.g. my[ ]service).
+func ValidateServiceName(query, name string) error {
+	if len(name) > MaxServiceNameLength {
+		return fmt.Errorf("The %s query cannot be longer than %d characters", query, MaxServiceNameLength)
+	}
+	if invalidNameCharRegexp.MatchString(name) {
+		highlighted := invalidNameCharRegexp.ReplaceAllStringFunc(name, func(chars string) string {
+			return "[" + chars + "]"
+		})
+		return fmt.Errorf("The %s query contains invalid characters: %s. Only letters, digits, underscores, dots and hyphens are allowed", query, highlighted)
+	}
+	for _, reserved := range ReservedServiceNames {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("The %s query cannot be %s since the name is reserved", query, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// +build !integration
+
+package actions
+
+import (
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+type NamesTestSuite struct {
+	suite.Suite
+}
+
+// ValidateServiceName
+
+func (s NamesTestSuite) Test_ValidateServiceName() {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"go-demo", ""},
+		{"go_demo.v1-2", ""},
+		{"STATS-api", ""},
+		{"my service", "The serviceName query contains invalid characters: my[ ]service. Only letters, digits, underscores, dots and hyphens are allowed"},
+		{"api/v1//x", "The serviceName query contains invalid characters: api[/]v1[//]x. Only letters, digits, underscores, dots and hyphens are allowed"},
+		{"stats", "The serviceName query cannot be stats since the name is reserved"},
+		{"Defaults", "The serviceName query cannot be Defaults since the name is reserved"},
+		{"dummy", "The serviceName query cannot be dummy since the name is reserved"},
+		{strings.Repeat("a", MaxServiceNameLength), ""},
+		{strings.Repeat("a", MaxServiceNameLength+1), "The serviceName query cannot be longer than 64 characters"},
+	}
+	for _, c := range cases {
+		err := ValidateServiceName("serviceName", c.name)
+
+		if len(c.expected) == 0 {
+			s.NoError(err, c.name)
+		} else {
+			s.EqualError(err, c.expected, c.name)
+		}
+	}
+}
+
+// Suite
+
+func TestNamesUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(NamesTestSuite))
+}
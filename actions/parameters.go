@@ -0,0 +1,358 @@
+package actions
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Parameter describes a reconfigure query parameter. The server decodes requests and the client package encodes them
+// using the same definitions so that the two can never diverge.
+type Parameter struct {
+	Name   string
+	Encode func(sr *ServiceReconfigure) string
+	Decode func(sr *ServiceReconfigure, value string)
+	// EncodeValues and DecodeValues replace Encode and Decode for the parameters that can be repeated
+	EncodeValues func(sr *ServiceReconfigure) []string
+	DecodeValues func(sr *ServiceReconfigure, values []string)
+}
+
+// MaxServiceDescriptionLength is the number of characters of the serviceDescription query that are kept.
+const MaxServiceDescriptionLength = 256
+
+// MaxOwnerLength is the number of characters of the owner query that are kept.
+const MaxOwnerLength = 64
+
+// ColorAddressPrefix is the prefix of the queries holding the address of each service color (e.g. addr.blue).
+const ColorAddressPrefix = "addr."
+
+// ColorWeightPrefix is the prefix of the queries holding the weight of the servers of each service color (e.g.
+// weight.green).
+const ColorWeightPrefix = "weight."
+
+// DcAddressPrefix is the prefix of the queries holding the address of the service in each datacenter (e.g. dc.east).
+const DcAddressPrefix = "dc."
+
+// ReconfigureParameters lists all the parameters accepted by the reconfigure endpoint.
+// Lists are comma-separated so their items cannot contain commas. Headers are the exception since they can be repeated.
+var ReconfigureParameters = []Parameter{
+	serviceNameParameter(),
+	stringParameter("aclName", func(sr *ServiceReconfigure) *string { return &sr.AclName }),
+	stringParameter("serviceColor", func(sr *ServiceReconfigure) *string { return &sr.ServiceColor }),
+	stringParameter("serviceCert", func(sr *ServiceReconfigure) *string { return &sr.ServiceCert }),
+	stringParameter("outboundHostname", func(sr *ServiceReconfigure) *string { return &sr.OutboundHostname }),
+	stringParameter("consulTemplateFePath", func(sr *ServiceReconfigure) *string { return &sr.ConsulTemplateFePath }),
+	stringParameter("consulTemplateBePath", func(sr *ServiceReconfigure) *string { return &sr.ConsulTemplateBePath }),
+	stringParameter("pathType", func(sr *ServiceReconfigure) *string { return &sr.PathType }),
+	stringParameter("port", func(sr *ServiceReconfigure) *string { return &sr.Port }),
+	stringParameter("reqRepSearch", func(sr *ServiceReconfigure) *string { return &sr.ReqRepSearch }),
+	stringParameter("reqRepReplace", func(sr *ServiceReconfigure) *string { return &sr.ReqRepReplace }),
+	stringParameter("templateFePath", func(sr *ServiceReconfigure) *string { return &sr.TemplateFePath }),
+	stringParameter("templateBePath", func(sr *ServiceReconfigure) *string { return &sr.TemplateBePath }),
+	stringParameter("serviceAddress", func(sr *ServiceReconfigure) *string { return &sr.ServiceAddress }),
+	stringParameter("profile", func(sr *ServiceReconfigure) *string { return &sr.Profile }),
+	stringParameter("tracingSampleRate", func(sr *ServiceReconfigure) *string { return &sr.TracingSampleRate }),
+	stringParameter("canaryHeader", func(sr *ServiceReconfigure) *string { return &sr.CanaryHeader }),
+	stringParameter("stackName", func(sr *ServiceReconfigure) *string { return &sr.StackName }),
+	stringParameter("proxyRole", func(sr *ServiceReconfigure) *string { return &sr.ProxyRole }),
+	stringParameter("clientCertVerify", func(sr *ServiceReconfigure) *string { return &sr.ClientCertVerify }),
+	stringParameter("maintenance", func(sr *ServiceReconfigure) *string { return &sr.Maintenance }),
+	stringParameter("maintenanceWindow", func(sr *ServiceReconfigure) *string { return &sr.MaintenanceWindow }),
+	stringParameter("certName", func(sr *ServiceReconfigure) *string { return &sr.CertName }),
+	stringParameter("clientCertCaFile", func(sr *ServiceReconfigure) *string { return &sr.ClientCertCaFile }),
+	stringParameter("httpReuse", func(sr *ServiceReconfigure) *string { return &sr.HttpReuse }),
+	stringParameter("poolMaxConn", func(sr *ServiceReconfigure) *string { return &sr.PoolMaxConn }),
+	stringParameter("poolPurgeDelay", func(sr *ServiceReconfigure) *string { return &sr.PoolPurgeDelay }),
+	stringParameter("postReloadHook", func(sr *ServiceReconfigure) *string { return &sr.PostReloadHook }),
+	stringParameter("srcPort", func(sr *ServiceReconfigure) *string { return &sr.SrcPort }),
+	stringParameter("httpsPort", func(sr *ServiceReconfigure) *string { return &sr.HttpsPort }),
+	stringParameter("sslCaCert", func(sr *ServiceReconfigure) *string { return &sr.SslCaCert }),
+	stringParameter("sessionType", func(sr *ServiceReconfigure) *string { return &sr.SessionType }),
+	stringParameter("cookieName", func(sr *ServiceReconfigure) *string { return &sr.CookieName }),
+	stringParameter("balance", func(sr *ServiceReconfigure) *string { return &sr.Balance }),
+	stringParameter("connectionMode", func(sr *ServiceReconfigure) *string { return &sr.ConnectionMode }),
+	stringParameter("reqMode", func(sr *ServiceReconfigure) *string { return &sr.ReqMode }),
+	stringParameter("forwardedProto", func(sr *ServiceReconfigure) *string { return &sr.ForwardedProto }),
+	stringParameter("reqRateLimit", func(sr *ServiceReconfigure) *string { return &sr.ReqRateLimit }),
+	stringParameter("backendExtra", func(sr *ServiceReconfigure) *string { return &sr.BackendExtra }),
+	stringParameter("frontendExtra", func(sr *ServiceReconfigure) *string { return &sr.FrontendExtra }),
+	stringParameter("checkPath", func(sr *ServiceReconfigure) *string { return &sr.CheckPath }),
+	stringParameter("checkMethod", func(sr *ServiceReconfigure) *string { return &sr.CheckMethod }),
+	stringParameter("checkInterval", func(sr *ServiceReconfigure) *string { return &sr.CheckInterval }),
+	stringParameter("checkRise", func(sr *ServiceReconfigure) *string { return &sr.CheckRise }),
+	stringParameter("checkFall", func(sr *ServiceReconfigure) *string { return &sr.CheckFall }),
+	stringParameter("checkTimeout", func(sr *ServiceReconfigure) *string { return &sr.CheckTimeout }),
+	stringParameter("httpCheckExpect", func(sr *ServiceReconfigure) *string { return &sr.HttpCheckExpect }),
+	stringParameter("backupHostname", func(sr *ServiceReconfigure) *string { return &sr.BackupHostname }),
+	stringParameter("backupPort", func(sr *ServiceReconfigure) *string { return &sr.BackupPort }),
+	stringParameter("weight", func(sr *ServiceReconfigure) *string { return &sr.Weight }),
+	stringParameter("maxconn", func(sr *ServiceReconfigure) *string { return &sr.MaxConn }),
+	stringParameter("retries", func(sr *ServiceReconfigure) *string { return &sr.Retries }),
+	boolParameter("redispatch", func(sr *ServiceReconfigure) *bool { return &sr.Redispatch }),
+	boolParameter("redirectWhenHttpProto", func(sr *ServiceReconfigure) *bool { return &sr.RedirectWhenHttpProto }),
+	boolParameter("serviceDomainMatchAll", func(sr *ServiceReconfigure) *bool { return &sr.ServiceDomainMatchAll }),
+	textParameter("serviceDescription", MaxServiceDescriptionLength, func(sr *ServiceReconfigure) *string { return &sr.ServiceDescription }),
+	textParameter("owner", MaxOwnerLength, func(sr *ServiceReconfigure) *string { return &sr.Owner }),
+	listParameter("servicePath", func(sr *ServiceReconfigure) *[]string { return &sr.ServicePath }),
+	listParameter("serviceDomain", func(sr *ServiceReconfigure) *[]string { return &sr.ServiceDomain }),
+	listParameter("corsOrigins", func(sr *ServiceReconfigure) *[]string { return &sr.CorsOrigins }),
+	listParameter("corsMethods", func(sr *ServiceReconfigure) *[]string { return &sr.CorsMethods }),
+	listParameter("corsHeaders", func(sr *ServiceReconfigure) *[]string { return &sr.CorsHeaders }),
+	listParameter("redirectFromDomain", func(sr *ServiceReconfigure) *[]string { return &sr.RedirectFromDomain }),
+	listParameter("allowedMethods", func(sr *ServiceReconfigure) *[]string { return &sr.AllowedMethods }),
+	listParameter("deniedMethods", func(sr *ServiceReconfigure) *[]string { return &sr.DeniedMethods }),
+	listParameter("compressionAlgo", func(sr *ServiceReconfigure) *[]string { return &sr.CompressionAlgo }),
+	listParameter("compressionType", func(sr *ServiceReconfigure) *[]string { return &sr.CompressionType }),
+	listParameter("allowedSourceIPs", func(sr *ServiceReconfigure) *[]string { return &sr.AllowedSourceIPs }),
+	boolParameter("denyHttp", func(sr *ServiceReconfigure) *bool { return &sr.DenyHttp }),
+	headerParameter("addReqHeader", func(sr *ServiceReconfigure) *[]string { return &sr.AddReqHeader }),
+	headerParameter("setReqHeader", func(sr *ServiceReconfigure) *[]string { return &sr.SetReqHeader }),
+	headerParameter("delReqHeader", func(sr *ServiceReconfigure) *[]string { return &sr.DelReqHeader }),
+	headerParameter("addResHeader", func(sr *ServiceReconfigure) *[]string { return &sr.AddResHeader }),
+	headerParameter("delResHeader", func(sr *ServiceReconfigure) *[]string { return &sr.DelResHeader }),
+	boolParameter("skipCheck", func(sr *ServiceReconfigure) *bool { return &sr.SkipCheck }),
+	boolParameter("distribute", func(sr *ServiceReconfigure) *bool { return &sr.Distribute }),
+	boolParameter("internalOnly", func(sr *ServiceReconfigure) *bool { return &sr.InternalOnly }),
+	boolParameter("checkGrpc", func(sr *ServiceReconfigure) *bool { return &sr.CheckGrpc }),
+	boolParameter("allowMissingHost", func(sr *ServiceReconfigure) *bool { return &sr.AllowMissingHost }),
+	boolParameter("httpsOnly", func(sr *ServiceReconfigure) *bool { return &sr.HttpsOnly }),
+	boolParameter("sslVerifyNone", func(sr *ServiceReconfigure) *bool { return &sr.SslVerifyNone }),
+	boolParameter("useDomainMap", func(sr *ServiceReconfigure) *bool { return &sr.UseDomainMap }),
+	boolParameter("letsEncrypt", func(sr *ServiceReconfigure) *bool { return &sr.LetsEncrypt }),
+	timeoutParameter("timeoutServer", func(sr *ServiceReconfigure) *int { return &sr.TimeoutServer }),
+	timeoutParameter("timeoutTunnel", func(sr *ServiceReconfigure) *int { return &sr.TimeoutTunnel }),
+	timeoutParameter("timeoutHttpRequest", func(sr *ServiceReconfigure) *int { return &sr.TimeoutHttpRequest }),
+	timeoutParameter("timeoutQueue", func(sr *ServiceReconfigure) *int { return &sr.TimeoutQueue }),
+	timeoutParameter("timeoutConnect", func(sr *ServiceReconfigure) *int { return &sr.TimeoutConnect }),
+	timeoutParameter("timeoutClient", func(sr *ServiceReconfigure) *int { return &sr.TimeoutClient }),
+	timeoutParameter("reqRatePeriod", func(sr *ServiceReconfigure) *int { return &sr.ReqRatePeriod }),
+	Parameter{
+		Name: "users",
+		Encode: func(sr *ServiceReconfigure) string {
+			users := []string{}
+			for _, user := range sr.Users {
+				users = append(users, fmt.Sprintf("%s:%s", user.Username, user.Password))
+			}
+			return strings.Join(users, ",")
+		},
+		Decode: func(sr *ServiceReconfigure, value string) {
+			for _, user := range strings.Split(value, ",") {
+				userPass := strings.SplitN(user, ":", 2)
+				if len(userPass) == 2 {
+					sr.Users = append(sr.Users, User{Username: userPass[0], Password: userPass[1]})
+				}
+			}
+		},
+	},
+}
+
+// TimeoutParameters lists the parameters holding per-service timeouts in seconds.
+var TimeoutParameters = []string{"timeoutServer", "timeoutTunnel", "timeoutHttpRequest", "timeoutQueue", "timeoutConnect", "timeoutClient"}
+
+// RemoveParameters lists all the parameters accepted by the remove endpoint.
+var RemoveParameters = []Parameter{
+	serviceNameParameter(),
+	stringParameter("aclName", func(sr *ServiceReconfigure) *string { return &sr.AclName }),
+	boolParameter("distribute", func(sr *ServiceReconfigure) *bool { return &sr.Distribute }),
+}
+
+// EncodeParameters converts the service into queries. Empty values are omitted.
+func EncodeParameters(parameters []Parameter, sr ServiceReconfigure) url.Values {
+	query := url.Values{}
+	for _, p := range parameters {
+		if p.EncodeValues != nil {
+			if values := p.EncodeValues(&sr); len(values) > 0 {
+				query[p.Name] = values
+			}
+		} else if value := p.Encode(&sr); len(value) > 0 {
+			query.Set(p.Name, value)
+		}
+	}
+	for color, address := range sr.ColorAddresses {
+		query.Set(ColorAddressPrefix+color, address)
+	}
+	for color, weight := range sr.ColorWeights {
+		query.Set(ColorWeightPrefix+color, weight)
+	}
+	for dc, address := range sr.DcAddresses {
+		query.Set(DcAddressPrefix+dc, address)
+	}
+	encodeDestinations(sr, query)
+	return query
+}
+
+// DecodeParameters converts queries into the service. Empty values are ignored.
+func DecodeParameters(parameters []Parameter, query url.Values) ServiceReconfigure {
+	sr := ServiceReconfigure{}
+	for _, p := range parameters {
+		if p.DecodeValues != nil {
+			if values := query[p.Name]; len(values) > 0 {
+				p.DecodeValues(&sr, values)
+			}
+		} else if value := query.Get(p.Name); len(value) > 0 {
+			p.Decode(&sr, value)
+		}
+	}
+	for key, values := range query {
+		if strings.HasPrefix(key, ColorAddressPrefix) && len(values[0]) > 0 {
+			if sr.ColorAddresses == nil {
+				sr.ColorAddresses = map[string]string{}
+			}
+			sr.ColorAddresses[strings.TrimPrefix(key, ColorAddressPrefix)] = values[0]
+		} else if strings.HasPrefix(key, ColorWeightPrefix) && len(values[0]) > 0 {
+			if sr.ColorWeights == nil {
+				sr.ColorWeights = map[string]string{}
+			}
+			sr.ColorWeights[strings.TrimPrefix(key, ColorWeightPrefix)] = values[0]
+		} else if strings.HasPrefix(key, DcAddressPrefix) && len(values[0]) > 0 {
+			if sr.DcAddresses == nil {
+				sr.DcAddresses = map[string]string{}
+			}
+			sr.DcAddresses[strings.TrimPrefix(key, DcAddressPrefix)] = values[0]
+		}
+	}
+	decodeDestinations(&sr, query)
+	return sr
+}
+
+func stringParameter(name string, field func(sr *ServiceReconfigure) *string) Parameter {
+	return Parameter{
+		Name:   name,
+		Encode: func(sr *ServiceReconfigure) string { return *field(sr) },
+		Decode: func(sr *ServiceReconfigure, value string) { *field(sr) = value },
+	}
+}
+
+// serviceNameParameter decodes the service name into its canonical form. The name is kept as it was sent in
+// ServiceDisplayName if the two differ and it is encoded back so that the service can be decoded again.
+func serviceNameParameter() Parameter {
+	return Parameter{
+		Name: "serviceName",
+		Encode: func(sr *ServiceReconfigure) string {
+			if len(sr.ServiceDisplayName) > 0 {
+				return sr.ServiceDisplayName
+			}
+			return sr.ServiceName
+		},
+		Decode: func(sr *ServiceReconfigure, value string) {
+			sr.ServiceName = CanonicalServiceName(value)
+			if sr.ServiceName != value {
+				sr.ServiceDisplayName = value
+			}
+		},
+	}
+}
+
+// textParameter holds free-form text. Control characters are replaced with spaces so that the text cannot inject
+// lines into logs and the text is truncated to max characters.
+func textParameter(name string, max int, field func(sr *ServiceReconfigure) *string) Parameter {
+	return Parameter{
+		Name:   name,
+		Encode: func(sr *ServiceReconfigure) string { return *field(sr) },
+		Decode: func(sr *ServiceReconfigure, value string) {
+			text := []rune(strings.Map(func(r rune) rune {
+				if unicode.IsControl(r) {
+					return ' '
+				}
+				return r
+			}, value))
+			if len(text) > max {
+				text = text[:max]
+			}
+			*field(sr) = string(text)
+		},
+	}
+}
+
+// timeoutParameter holds a number of seconds. Values are accepted as seconds (e.g. 90) or durations (e.g. 1m30s) and
+// are rounded up to whole seconds. Invalid values are decoded as -1 so that they can be rejected.
+func timeoutParameter(name string, field func(sr *ServiceReconfigure) *int) Parameter {
+	return Parameter{
+		Name: name,
+		Encode: func(sr *ServiceReconfigure) string {
+			if *field(sr) == 0 {
+				return ""
+			}
+			return strconv.Itoa(*field(sr))
+		},
+		Decode: func(sr *ServiceReconfigure, value string) {
+			seconds, err := ParseTimeout(value)
+			if err != nil {
+				seconds = -1
+			}
+			*field(sr) = seconds
+		},
+	}
+}
+
+// ParseTimeout converts seconds or a duration into whole seconds.
+func ParseTimeout(value string) (int, error) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return seconds, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%s is not a number of seconds or a duration (e.g. 90 or 1m30s)", value)
+	}
+	return int(math.Ceil(duration.Seconds())), nil
+}
+
+func listParameter(name string, field func(sr *ServiceReconfigure) *[]string) Parameter {
+	return Parameter{
+		Name:   name,
+		Encode: func(sr *ServiceReconfigure) string { return strings.Join(*field(sr), ",") },
+		Decode: func(sr *ServiceReconfigure, value string) { *field(sr) = strings.Split(value, ",") },
+	}
+}
+
+// headerParameter decodes a single query as a comma-separated list. Each value of a repeated query is a single header
+// so that it can contain commas. Empty values are ignored which lets a single header with commas be sent by repeating
+// the query with an empty value.
+func headerParameter(name string, field func(sr *ServiceReconfigure) *[]string) Parameter {
+	return Parameter{
+		Name: name,
+		EncodeValues: func(sr *ServiceReconfigure) []string {
+			headers := *field(sr)
+			if len(headers) == 0 {
+				return nil
+			}
+			for _, header := range headers {
+				if strings.Contains(header, ",") {
+					return append(append([]string{}, headers...), "")
+				}
+			}
+			return []string{strings.Join(headers, ",")}
+		},
+		DecodeValues: func(sr *ServiceReconfigure, values []string) {
+			headers := []string{}
+			if len(values) == 1 {
+				values = strings.Split(values[0], ",")
+			}
+			for _, value := range values {
+				if len(strings.TrimSpace(value)) > 0 {
+					headers = append(headers, strings.TrimSpace(value))
+				}
+			}
+			if len(headers) > 0 {
+				*field(sr) = headers
+			}
+		},
+	}
+}
+
+func boolParameter(name string, field func(sr *ServiceReconfigure) *bool) Parameter {
+	return Parameter{
+		Name: name,
+		Encode: func(sr *ServiceReconfigure) string {
+			if *field(sr) {
+				return "true"
+			}
+			return ""
+		},
+		Decode: func(sr *ServiceReconfigure, value string) { *field(sr), _ = strconv.ParseBool(value) },
+	}
+}
@@ -0,0 +1,267 @@
+// +build !integration
+
+package actions
+
+import (
+	"github.com/stretchr/testify/suite"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type ParametersTestSuite struct {
+	suite.Suite
+}
+
+// EncodeParameters
+
+func (s ParametersTestSuite) Test_EncodeParameters_OmitsEmptyValues() {
+	sr := ServiceReconfigure{
+		ServiceName: "my-service",
+		ServicePath: []string{"/api/v1", "/api/v2"},
+		SkipCheck:   true,
+	}
+	expected := url.Values{
+		"serviceName": {"my-service"},
+		"servicePath": {"/api/v1,/api/v2"},
+		"skipCheck":   {"true"},
+	}
+
+	actual := EncodeParameters(ReconfigureParameters, sr)
+
+	s.Equal(expected, actual)
+}
+
+func (s ParametersTestSuite) Test_EncodeParameters_AddsColorAddresses() {
+	sr := ServiceReconfigure{ColorAddresses: map[string]string{"blue": "10.0.0.1"}}
+
+	actual := EncodeParameters(ReconfigureParameters, sr)
+
+	s.Equal("10.0.0.1", actual.Get("addr.blue"))
+}
+
+func (s ParametersTestSuite) Test_EncodeParameters_AddsColorWeights() {
+	sr := ServiceReconfigure{ColorWeights: map[string]string{"green": "10"}}
+
+	actual := EncodeParameters(ReconfigureParameters, sr)
+
+	s.Equal("10", actual.Get("weight.green"))
+}
+
+func (s ParametersTestSuite) Test_EncodeParameters_AddsDcAddresses() {
+	sr := ServiceReconfigure{DcAddresses: map[string]string{"east": "10.0.0.1"}}
+
+	actual := EncodeParameters(ReconfigureParameters, sr)
+
+	s.Equal("10.0.0.1", actual.Get("dc.east"))
+}
+
+// DecodeParameters
+
+func (s ParametersTestSuite) Test_DecodeParameters_ReturnsTheEncodedService() {
+	expected := ServiceReconfigure{
+		ServiceName:    "my-service",
+		AclName:        "my-acl",
+		ServicePath:    []string{"/api/v1", "/api/v2"},
+		ServiceDomain:  []string{"my-domain.com"},
+		ServiceCert:    "-----BEGIN CERTIFICATE-----\nabc+/=\n-----END CERTIFICATE-----",
+		Users:          []User{{Username: "user-1", Password: "pass:1"}, {Username: "user-2", Password: "pass-2"}},
+		Distribute:     true,
+		InternalOnly:   true,
+		CorsOrigins:    []string{"*"},
+		ColorAddresses: map[string]string{"blue": "10.0.0.1", "green": "10.0.0.2"},
+		ColorWeights:   map[string]string{"blue": "90", "green": "10"},
+		DcAddresses:    map[string]string{"east": "10.1.0.1", "west": "10.2.0.1"},
+	}
+	query, _ := url.ParseQuery(EncodeParameters(ReconfigureParameters, expected).Encode())
+
+	actual := DecodeParameters(ReconfigureParameters, query)
+
+	s.Equal(expected, actual)
+}
+
+func (s ParametersTestSuite) Test_DecodeParameters_LowersServiceName() {
+	query := url.Values{"serviceName": {"My-Service"}}
+
+	actual := DecodeParameters(RemoveParameters, query)
+
+	s.Equal(ServiceReconfigure{ServiceName: "my-service", ServiceDisplayName: "My-Service"}, actual)
+	s.Equal("My-Service", actual.GetDisplayName())
+	s.Equal("My-Service", EncodeParameters(RemoveParameters, actual).Get("serviceName"))
+}
+
+func (s ParametersTestSuite) Test_DecodeParameters_IgnoresUnknownParameters() {
+	query := url.Values{"serviceName": {"my-service"}, "port": {"1234"}}
+
+	actual := DecodeParameters(RemoveParameters, query)
+
+	s.Equal(ServiceReconfigure{ServiceName: "my-service"}, actual)
+}
+
+func (s ParametersTestSuite) Test_DecodeParameters_TruncatesTextParameters() {
+	query := url.Values{
+		"serviceDescription": {strings.Repeat("ä", MaxServiceDescriptionLength+10)},
+		"owner":              {strings.Repeat("o", MaxOwnerLength+1)},
+	}
+
+	actual := DecodeParameters(ReconfigureParameters, query)
+
+	s.Equal(strings.Repeat("ä", MaxServiceDescriptionLength), actual.ServiceDescription)
+	s.Equal(strings.Repeat("o", MaxOwnerLength), actual.Owner)
+}
+
+func (s ParametersTestSuite) Test_DecodeParameters_ReplacesControlCharactersInTextParameters() {
+	query := url.Values{"serviceDescription": {"Demo\nINFO fake log line\t"}, "owner": {"team\r\ndemo"}}
+
+	actual := DecodeParameters(ReconfigureParameters, query)
+
+	s.Equal("Demo INFO fake log line ", actual.ServiceDescription)
+	s.Equal("team  demo", actual.Owner)
+}
+
+func (s ParametersTestSuite) Test_DecodeParameters_NormalizesTimeoutsToSeconds() {
+	query := url.Values{
+		"timeoutServer":      {"90"},
+		"timeoutTunnel":      {"1h"},
+		"timeoutHttpRequest": {"1m30s"},
+		"timeoutQueue":       {"1500ms"},
+		"timeoutConnect":     {"fast"},
+		"timeoutClient":      {"10m"},
+	}
+
+	actual := DecodeParameters(ReconfigureParameters, query)
+
+	s.Equal(90, actual.TimeoutServer)
+	s.Equal(3600, actual.TimeoutTunnel)
+	s.Equal(90, actual.TimeoutHttpRequest)
+	s.Equal(2, actual.TimeoutQueue)
+	s.Equal(-1, actual.TimeoutConnect)
+	s.Equal(600, actual.TimeoutClient)
+	s.Equal("90", EncodeParameters(ReconfigureParameters, actual).Get("timeoutHttpRequest"))
+}
+
+func (s ParametersTestSuite) Test_DecodeParameters_DecodesIndexedGroups() {
+	query, _ := url.ParseQuery("serviceName=my-service&reqMode.1=http&port.1=8080&servicePath.1=/api&reqMode.2=tcp&srcPort.2=9000&port.2=9000&srcPort.3=9001&port.3=9100")
+
+	actual := DecodeParameters(ReconfigureParameters, query)
+
+	s.Equal("8080", actual.Port)
+	s.Equal([]string{"/api"}, actual.ServicePath)
+	s.Equal([]TcpDestination{{SrcPort: 9000, Port: "9000"}, {SrcPort: 9001, Port: "9100"}}, actual.TcpDestinations)
+	s.Equal(actual, DecodeParameters(ReconfigureParameters, EncodeParameters(ReconfigureParameters, actual)))
+}
+
+func (s ParametersTestSuite) Test_GetTcpDestinations_AddsServiceDestination_WhenReqModeIsTcp() {
+	query, _ := url.ParseQuery("serviceName=my-service&reqMode=TCP&srcPort=1883&port=1883&srcPort.1=8883&port.1=8883")
+
+	actual := DecodeParameters(ReconfigureParameters, query)
+
+	s.True(actual.IsTcp())
+	s.Equal([]TcpDestination{{SrcPort: 1883, Port: "1883"}, {SrcPort: 8883, Port: "8883"}}, actual.GetTcpDestinations())
+	s.Equal(actual, DecodeParameters(ReconfigureParameters, EncodeParameters(ReconfigureParameters, actual)))
+	actual.ReqMode = "http"
+	s.Equal([]TcpDestination{{SrcPort: 8883, Port: "8883"}}, actual.GetTcpDestinations())
+}
+
+func (s ParametersTestSuite) Test_DecodeParameters_DecodesHttpsOnly() {
+	query, _ := url.ParseQuery("serviceName=my-service&httpsOnly=true")
+
+	actual := DecodeParameters(ReconfigureParameters, query)
+
+	s.True(actual.HttpsOnly)
+	s.Equal("true", EncodeParameters(ReconfigureParameters, actual).Get("httpsOnly"))
+}
+
+func (s ParametersTestSuite) Test_DecodeParameters_DecodesHttpDestinations() {
+	query, _ := url.ParseQuery("serviceName=my-service&port.1=8080&servicePath.1=/api&port.2=8081&servicePath.2=/admin&servicePath.3=/docs&serviceDomain.4=admin.com&servicePath.4=/&srcPort.5=9000&port.5=9000")
+
+	actual := DecodeParameters(ReconfigureParameters, query)
+
+	s.Equal("8080", actual.Port)
+	s.Equal([]string{"/api", "/docs"}, actual.ServicePath)
+	s.Equal([]HttpDestination{
+		{Port: "8081", ServicePath: []string{"/admin"}},
+		{ServicePath: []string{"/"}, ServiceDomain: []string{"admin.com"}},
+	}, actual.HttpDestinations)
+	s.Equal([]TcpDestination{{SrcPort: 9000, Port: "9000"}}, actual.TcpDestinations)
+	s.Equal(actual, DecodeParameters(ReconfigureParameters, EncodeParameters(ReconfigureParameters, actual)))
+}
+
+func (s ParametersTestSuite) Test_DecodeParameters_DecodesHeaders() {
+	query, _ := url.ParseQuery("serviceName=my-service&addReqHeader=X-Forwarded-Prefix /api,X-Env:prod&setReqHeader=Accept text/html, application/json&setReqHeader=X-Mode:a,b&delReqHeader=X-Internal-Auth&delReqHeader=")
+
+	actual := DecodeParameters(ReconfigureParameters, query)
+
+	s.Equal([]string{"X-Forwarded-Prefix /api", "X-Env:prod"}, actual.AddReqHeader)
+	s.Equal([]string{"Accept text/html, application/json", "X-Mode:a,b"}, actual.SetReqHeader)
+	s.Equal([]string{"X-Internal-Auth"}, actual.DelReqHeader)
+	s.Equal(actual, DecodeParameters(ReconfigureParameters, EncodeParameters(ReconfigureParameters, actual)))
+	actual.SetReqHeader = []string{"X-Mode a,b"}
+	s.Equal(actual, DecodeParameters(ReconfigureParameters, EncodeParameters(ReconfigureParameters, actual)))
+}
+
+func (s ParametersTestSuite) Test_DecodeParameters_MarksInvalidIndexedGroups() {
+	for rawQuery, expected := range map[string]ServiceReconfigure{
+		"reqMode.1=tcp&srcPort.1=http&port.1=9000":              {TcpDestinations: []TcpDestination{{SrcPort: -1, Port: "9000"}}},
+		"reqMode.1=udp&srcPort.1=9000&port.1=9000":              {TcpDestinations: []TcpDestination{{SrcPort: -1, Port: "9000"}}},
+		"reqMode.1=http&port.1=8080&reqMode.2=http&port.2=8080": {Port: "8080"},
+	} {
+		query, _ := url.ParseQuery(rawQuery)
+
+		actual := DecodeParameters(ReconfigureParameters, query)
+
+		s.Equal(expected, actual, rawQuery)
+	}
+}
+
+// ParseHttpDestinations
+
+func (s ParametersTestSuite) Test_ParseHttpDestinations_ReturnsFormattedDestinations() {
+	dests := []HttpDestination{{Port: "8081", ServicePath: []string{"/admin"}, ServiceDomain: []string{"admin.com"}}}
+
+	value := FormatHttpDestinations(dests)
+
+	s.Equal(dests, ParseHttpDestinations(value))
+	s.Empty(FormatHttpDestinations(nil))
+	s.Nil(ParseHttpDestinations(""))
+}
+
+// ParseHeaders
+
+func (s ParametersTestSuite) Test_ParseHeaders_ReturnsFormattedHeaders() {
+	headers := []string{"Accept text/html, application/json", "X-Env:prod"}
+
+	value := FormatHeaders(headers)
+
+	s.Equal(headers, ParseHeaders(value))
+	s.Empty(FormatHeaders(nil))
+	s.Nil(ParseHeaders(""))
+}
+
+// ParseTcpDestinations
+
+func (s ParametersTestSuite) Test_ParseTcpDestinations_ReturnsFormattedDestinations() {
+	dests := []TcpDestination{{SrcPort: 9001, Port: "9100"}, {SrcPort: 9000, Port: "9000"}}
+
+	value := FormatTcpDestinations(dests)
+
+	s.Equal("9000=9000,9001=9100", value)
+	s.Equal([]TcpDestination{{SrcPort: 9000, Port: "9000"}, {SrcPort: 9001, Port: "9100"}}, ParseTcpDestinations(value))
+	s.Nil(ParseTcpDestinations(""))
+}
+
+// ParseTimeout
+
+func (s ParametersTestSuite) Test_ParseTimeout_ReturnsError_WhenValueIsInvalid() {
+	for _, value := range []string{"", "-5", "-1m", "5 minutes"} {
+		_, err := ParseTimeout(value)
+
+		s.Error(err, value)
+	}
+}
+
+// Suite
+
+func TestParametersUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ParametersTestSuite))
+}
@@ -0,0 +1,131 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// profiles maps profile names to reconfigure queries (e.g. {"public-api": {"corsOrigins": "*"}}).
+var profiles = map[string]map[string]string{}
+var profilesMu = &sync.RWMutex{}
+
+// LoadProfiles reads profiles from a JSON file and replaces the ones that are currently defined.
+func LoadProfiles(path string) error {
+	content, err := readTemplateFile(path)
+	if err != nil {
+		return fmt.Errorf("Could not read the profiles file %s\n%s", path, err.Error())
+	}
+	data := map[string]map[string]string{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return fmt.Errorf("Could not parse the profiles file %s\n%s", path, err.Error())
+	}
+	known := map[string]bool{}
+	for _, p := range ReconfigureParameters {
+		known[p.Name] = true
+	}
+	for name, values := range data {
+		for key := range values {
+			if !known[key] || key == "profile" || key == "serviceName" {
+				return fmt.Errorf("The profile %s contains the parameter %s that cannot be used in profiles", name, key)
+			}
+		}
+	}
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	profiles = data
+	return nil
+}
+
+// GetProfileNames returns the names of all defined profiles, sorted alphabetically.
+func GetProfileNames() []string {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	names := []string{}
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sources of the parameters of a service as reported by GetEffectiveService.
+const (
+	SourceRequest = "request"
+	SourceProfile = "profile"
+	SourceDefault = "default"
+)
+
+// ApplyProfile merges the values of the profile referenced by the service underneath the values that were set
+// explicitly. Parameters listed in ExplicitParameters always win. If ExplicitParameters is nil, all non-empty
+// parameters are treated as explicit.
+func ApplyProfile(sr *ServiceReconfigure) error {
+	if len(sr.Profile) == 0 {
+		return nil
+	}
+	profilesMu.RLock()
+	profile, ok := profiles[sr.Profile]
+	profilesMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("The profile %s does not exist. Available profiles: %s", sr.Profile, strings.Join(GetProfileNames(), ", "))
+	}
+	query := EncodeParameters(ReconfigureParameters, *sr)
+	explicit := getExplicitParameters(*sr, query)
+	merged, _ := mergeParameters(query, explicit, profile, nil)
+	mode := sr.Mode
+	*sr = DecodeParameters(ReconfigureParameters, merged)
+	sr.Mode = mode
+	sr.ExplicitParameters = explicit
+	return nil
+}
+
+// getExplicitParameters returns the ExplicitParameters of the service or, if they are nil, the names of all the
+// parameters in the query.
+func getExplicitParameters(sr ServiceReconfigure, query url.Values) []string {
+	if sr.ExplicitParameters != nil {
+		return sr.ExplicitParameters
+	}
+	explicit := []string{}
+	for key := range query {
+		explicit = append(explicit, key)
+	}
+	sort.Strings(explicit)
+	return explicit
+}
+
+// mergeParameters places the explicit parameters of the query over the profile and the profile over the defaults.
+// Color addresses are always taken from the query. It returns the merged parameters together with the source of each
+// non-empty one.
+func mergeParameters(query url.Values, explicit []string, profile, defaults map[string]string) (url.Values, map[string]string) {
+	isExplicit := map[string]bool{}
+	for _, key := range explicit {
+		isExplicit[key] = true
+	}
+	merged := url.Values{}
+	sources := map[string]string{}
+	for key, value := range defaults {
+		merged.Set(key, value)
+		sources[key] = SourceDefault
+	}
+	for key, value := range profile {
+		if !isExplicit[key] {
+			merged.Set(key, value)
+			sources[key] = SourceProfile
+		}
+	}
+	for key, values := range query {
+		if isExplicit[key] || strings.HasPrefix(key, ColorAddressPrefix) || strings.HasPrefix(key, ColorWeightPrefix) {
+			merged[key] = values
+			sources[key] = SourceRequest
+		}
+	}
+	for key := range sources {
+		if len(merged.Get(key)) == 0 {
+			delete(sources, key)
+		}
+	}
+	return merged, sources
+}
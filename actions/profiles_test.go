@@ -0,0 +1,162 @@
+// +build !integration
+
+package actions
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ProfilesTestSuite struct {
+	suite.Suite
+}
+
+func (s *ProfilesTestSuite) SetupTest() {
+	profiles = map[string]map[string]string{
+		"public-api": {
+			"corsOrigins":  "*",
+			"pathType":     "path_reg",
+			"skipCheck":    "true",
+			"internalOnly": "false",
+		},
+		"internal": {
+			"internalOnly": "true",
+		},
+	}
+}
+
+// LoadProfiles
+
+func (s *ProfilesTestSuite) Test_LoadProfiles_ReplacesProfiles() {
+	readTemplateFileOrig := readTemplateFile
+	defer func() { readTemplateFile = readTemplateFileOrig }()
+	readTemplateFile = func(filename string) ([]byte, error) {
+		return []byte(`{"batch": {"pathType": "path_beg"}}`), nil
+	}
+
+	err := LoadProfiles("/cfg/profiles.json")
+
+	s.NoError(err)
+	s.Equal(map[string]map[string]string{"batch": {"pathType": "path_beg"}}, profiles)
+}
+
+func (s *ProfilesTestSuite) Test_LoadProfiles_ReturnsError_WhenParameterIsUnknown() {
+	readTemplateFileOrig := readTemplateFile
+	defer func() { readTemplateFile = readTemplateFileOrig }()
+	readTemplateFile = func(filename string) ([]byte, error) {
+		return []byte(`{"batch": {"unknown": "value"}}`), nil
+	}
+
+	err := LoadProfiles("/cfg/profiles.json")
+
+	s.Error(err)
+	s.Contains(profiles, "public-api")
+}
+
+func (s *ProfilesTestSuite) Test_LoadProfiles_ReturnsError_WhenFileCannotBeRead() {
+	readTemplateFileOrig := readTemplateFile
+	defer func() { readTemplateFile = readTemplateFileOrig }()
+	readTemplateFile = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+
+	s.Error(LoadProfiles("/cfg/profiles.json"))
+}
+
+// GetProfileNames
+
+func (s *ProfilesTestSuite) Test_GetProfileNames_ReturnsSortedNames() {
+	s.Equal([]string{"internal", "public-api"}, GetProfileNames())
+}
+
+// ApplyProfile
+
+func (s *ProfilesTestSuite) Test_ApplyProfile_DoesNothing_WhenProfileIsEmpty() {
+	sr := ServiceReconfigure{ServiceName: "my-service", PathType: "path_beg"}
+
+	err := ApplyProfile(&sr)
+
+	s.NoError(err)
+	s.Equal(ServiceReconfigure{ServiceName: "my-service", PathType: "path_beg"}, sr)
+}
+
+func (s *ProfilesTestSuite) Test_ApplyProfile_SetsProfileValues() {
+	sr := ServiceReconfigure{ServiceName: "my-service", Profile: "public-api", Mode: "swarm"}
+
+	ApplyProfile(&sr)
+
+	s.Equal([]string{"*"}, sr.CorsOrigins)
+	s.Equal("path_reg", sr.PathType)
+	s.True(sr.SkipCheck)
+	s.Equal("my-service", sr.ServiceName)
+	s.Equal("public-api", sr.Profile)
+	s.Equal("swarm", sr.Mode)
+}
+
+func (s *ProfilesTestSuite) Test_ApplyProfile_KeepsExplicitValues() {
+	sr := ServiceReconfigure{ServiceName: "my-service", Profile: "public-api", PathType: "path_beg"}
+
+	ApplyProfile(&sr)
+
+	s.Equal("path_beg", sr.PathType)
+	s.Equal([]string{"*"}, sr.CorsOrigins)
+}
+
+func (s *ProfilesTestSuite) Test_ApplyProfile_KeepsExplicitEmptyValues() {
+	sr := ServiceReconfigure{
+		ServiceName:        "my-service",
+		Profile:            "public-api",
+		ExplicitParameters: []string{"serviceName", "profile", "skipCheck"},
+	}
+
+	ApplyProfile(&sr)
+
+	s.False(sr.SkipCheck)
+	s.Equal("path_reg", sr.PathType)
+}
+
+func (s *ProfilesTestSuite) Test_ApplyProfile_ReplacesValuesOfThePreviousProfileDefinition() {
+	sr := ServiceReconfigure{
+		ServiceName:        "my-service",
+		Profile:            "public-api",
+		PathType:           "path_reg",
+		CorsOrigins:        []string{"*"},
+		ExplicitParameters: []string{"serviceName", "profile"},
+	}
+	profiles["public-api"] = map[string]string{"pathType": "path_end"}
+
+	ApplyProfile(&sr)
+
+	s.Equal("path_end", sr.PathType)
+	s.Empty(sr.CorsOrigins)
+	s.Equal([]string{"serviceName", "profile"}, sr.ExplicitParameters)
+}
+
+func (s *ProfilesTestSuite) Test_ApplyProfile_KeepsColorAddresses() {
+	sr := ServiceReconfigure{
+		ServiceName:        "my-service",
+		Profile:            "internal",
+		ColorAddresses:     map[string]string{"blue": "10.0.0.1"},
+		ExplicitParameters: []string{"serviceName", "profile"},
+	}
+
+	ApplyProfile(&sr)
+
+	s.True(sr.InternalOnly)
+	s.Equal(map[string]string{"blue": "10.0.0.1"}, sr.ColorAddresses)
+}
+
+func (s *ProfilesTestSuite) Test_ApplyProfile_ReturnsErrorWithAvailableProfiles_WhenProfileDoesNotExist() {
+	sr := ServiceReconfigure{ServiceName: "my-service", Profile: "unknown"}
+
+	err := ApplyProfile(&sr)
+
+	s.EqualError(err, "The profile unknown does not exist. Available profiles: internal, public-api")
+}
+
+// Suite
+
+func TestProfilesUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ProfilesTestSuite))
+}
@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
+	"unicode"
 
 	haproxy "../proxy"
 	"../registry"
@@ -21,6 +24,12 @@ const ServiceTemplateBeFilename = "service-formatted-be.ctmpl"
 
 var mu = &sync.Mutex{}
 
+// localDcWeight and remoteDcWeight are the weights of the datacenter servers when DC_FAILOVER_MODE is weighted.
+const (
+	localDcWeight  = 100
+	remoteDcWeight = 10
+)
+
 type Reconfigurable interface {
 	Executable
 	GetData() (BaseReconfigure, ServiceReconfigure)
@@ -39,32 +48,120 @@ type User struct {
 }
 
 type ServiceReconfigure struct {
-	ServiceName          string   `short:"s" long:"service-name" required:"true" description:"The name of the service that should be reconfigured (e.g. my-service)."`
-	ServiceColor         string   `short:"C" long:"service-color" description:"The color of the service release in case blue-green deployment is performed (e.g. blue)."`
-	ServicePath          []string `short:"p" long:"service-path" description:"Path that should be configured in the proxy (e.g. /api/v1/my-service)."`
-	ServicePort          string
-	ServiceDomain        []string `long:"service-domain" description:"The domain of the service. If specified, proxy will allow access only to requests coming from that domain (e.g. my-domain.com)."`
-	ServiceCert          string   `long:"service-cert" description:"Content of the PEM-encoded certificate to be used by the proxy when serving traffic over SSL."`
-	OutboundHostname     string   `long:"outbound-hostname" description:"The hostname running the service. If specified, proxy will redirect traffic to this hostname instead of using the service's name."`
-	ConsulTemplateFePath string   `long:"consul-template-fe-path" description:"The path to the Consul Template representing snippet of the frontend configuration. If specified, proxy template will be loaded from the specified file."`
-	ConsulTemplateBePath string   `long:"consul-template-be-path" description:"The path to the Consul Template representing snippet of the backend configuration. If specified, proxy template will be loaded from the specified file."`
-	Mode                 string   `short:"m" long:"mode" env:"MODE" description:"If set to 'swarm', proxy will operate assuming that Docker service from v1.12+ is used."`
-	PathType             string
-	Port                 string
-	SkipCheck            bool
-	Acl                  string
-	AclName              string
-	AclCondition         string
-	Users                []User
-	FullServiceName      string
-	Host                 string
-	Distribute           bool
-	LookupRetry          int
-	LookupRetryInterval  int
-	ReqRepSearch         string
-	ReqRepReplace        string
-	TemplateFePath       string
-	TemplateBePath       string
+	ServiceName           string `short:"s" long:"service-name" required:"true" description:"The name of the service that should be reconfigured (e.g. my-service)."`
+	ServiceDisplayName    string
+	ServiceColor          string   `short:"C" long:"service-color" description:"The color of the service release in case blue-green deployment is performed (e.g. blue)."`
+	ServicePath           []string `short:"p" long:"service-path" description:"Path that should be configured in the proxy (e.g. /api/v1/my-service)."`
+	ServicePort           string
+	ServiceDomain         []string `long:"service-domain" description:"The domain of the service. If specified, proxy will allow access only to requests coming from that domain (e.g. my-domain.com)."`
+	ServiceCert           string   `long:"service-cert" description:"Content of the PEM-encoded certificate to be used by the proxy when serving traffic over SSL."`
+	OutboundHostname      string   `long:"outbound-hostname" description:"The hostname running the service. If specified, proxy will redirect traffic to this hostname instead of using the service's name."`
+	ConsulTemplateFePath  string   `long:"consul-template-fe-path" description:"The path to the Consul Template representing snippet of the frontend configuration. If specified, proxy template will be loaded from the specified file."`
+	ConsulTemplateBePath  string   `long:"consul-template-be-path" description:"The path to the Consul Template representing snippet of the backend configuration. If specified, proxy template will be loaded from the specified file."`
+	Mode                  string   `short:"m" long:"mode" env:"MODE" description:"If set to 'swarm', proxy will operate assuming that Docker service from v1.12+ is used."`
+	PathType              string
+	Port                  string
+	SkipCheck             bool
+	Acl                   string
+	AclName               string
+	AclCondition          string
+	Users                 []User
+	FullServiceName       string
+	Host                  string
+	Distribute            bool
+	LookupRetry           int
+	LookupRetryInterval   int
+	ReqRepSearch          string
+	ReqRepReplace         string
+	TemplateFePath        string
+	TemplateBePath        string
+	ServiceAddress        string
+	ColorAddresses        map[string]string
+	DcAddresses           map[string]string
+	CorsOrigins           []string
+	CorsMethods           []string
+	CorsHeaders           []string
+	InternalOnly          bool
+	CheckGrpc             bool
+	Profile               string
+	ExplicitParameters    []string
+	TracingSampleRate     string
+	CanaryHeader          string
+	ServiceDescription    string
+	Owner                 string
+	TimeoutServer         int
+	TimeoutTunnel         int
+	TimeoutHttpRequest    int
+	TimeoutQueue          int
+	TimeoutConnect        int
+	TimeoutClient         int
+	StackName             string
+	AllowMissingHost      bool
+	UseDomainMap          bool
+	LetsEncrypt           bool
+	ProxyRole             string
+	ClientCertVerify      string
+	ClientCertCaFile      string
+	TcpDestinations       []TcpDestination
+	HttpDestinations      []HttpDestination
+	Maintenance           string
+	MaintenanceWindow     string
+	CertName              string
+	HttpReuse             string
+	PoolMaxConn           string
+	PoolPurgeDelay        string
+	PostReloadHook        string
+	SrcPort               string
+	HttpsOnly             bool
+	HttpsPort             string
+	SslVerifyNone         bool
+	SslCaCert             string
+	SessionType           string
+	CookieName            string
+	Balance               string
+	ConnectionMode        string
+	ReqMode               string
+	ServiceDomainMatchAll bool
+	RedirectFromDomain    []string
+	AllowedMethods        []string
+	DeniedMethods         []string
+	DenyHttp              bool
+	AddReqHeader          []string
+	SetReqHeader          []string
+	DelReqHeader          []string
+	AddResHeader          []string
+	DelResHeader          []string
+	ForwardedProto        string
+	CompressionAlgo       []string
+	CompressionType       []string
+	ReqRateLimit          string
+	ReqRatePeriod         int
+	AllowedSourceIPs      []string
+	BackendExtra          string
+	FrontendExtra         string
+	CheckPath             string
+	CheckMethod           string
+	CheckInterval         string
+	CheckRise             string
+	CheckFall             string
+	CheckTimeout          string
+	HttpCheckExpect       string
+	BackupHostname        string
+	BackupPort            string
+	Weight                string
+	ColorWeights          map[string]string
+	MaxConn               string
+	Retries               string
+	Redispatch            bool
+	RedirectWhenHttpProto bool
+}
+
+// GetDisplayName returns the service name as it was sent.
+func (sr ServiceReconfigure) GetDisplayName() string {
+	if len(sr.ServiceDisplayName) > 0 {
+		return sr.ServiceDisplayName
+	}
+	return sr.ServiceName
 }
 
 type BaseReconfigure struct {
@@ -72,6 +169,11 @@ type BaseReconfigure struct {
 	ConfigsPath           string `short:"c" long:"configs-path" default:"/cfg" description:"The path to the configurations directory"`
 	InstanceName          string `long:"proxy-instance-name" env:"PROXY_INSTANCE_NAME" default:"docker-flow" required:"true" description:"The name of the proxy instance."`
 	TemplatesPath         string `short:"t" long:"templates-path" default:"/cfg/tmpl" description:"The path to the templates directory"`
+	ForwardedProtoDefault bool   `long:"forwarded-proto" env:"FORWARDED_PROTO" description:"If set to true, the backends of the services send X-Forwarded-For and X-Forwarded-Proto unless the services are reconfigured with forwardedProto set to false."`
+	CheckXForwardedProto  bool   `long:"check-x-forwarded-proto" env:"CHECK_X_FORWARDED_PROTO" description:"If set to true, the requests of the services with httpsOnly set to true are redirected to HTTPS when X-Forwarded-Proto is http instead of when they do not come through TLS."`
+	// BeforeStartupReload is invoked by ReloadAllServices once the configuration of the services found in the
+	// registry is created. The proxy is reloaded only if it is not set or returns true.
+	BeforeStartupReload   func() bool
 	skipAddressValidation bool
 }
 
@@ -85,24 +187,26 @@ var NewReconfigure = func(baseData BaseReconfigure, serviceData ServiceReconfigu
 func (m *Reconfigure) Execute(args []string) error {
 	mu.Lock()
 	defer mu.Unlock()
-	if isSwarm(m.ServiceReconfigure.Mode) && !m.skipAddressValidation {
-		host := m.ServiceName
-		if len(m.OutboundHostname) > 0 {
-			host = m.OutboundHostname
-		}
-		if _, err := lookupHost(host); err != nil {
-			logPrintf("Could not reach the service %s. Is the service running and connected to the same network as the proxy?", host)
-			return err
-		}
+	// Instances of other roles might not reach the service
+	if !m.MatchesProxyRole() {
+		return m.executeWithdrawal()
 	}
-	if err := m.createConfigs(m.TemplatesPath, &m.ServiceReconfigure); err != nil {
+	if err := m.checkServiceAddress(); err != nil {
 		return err
 	}
-	if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
+	stored, wasStored := getStoredService(m.getStoredName())
+	if err := m.createConfigs(m.TemplatesPath, &m.ServiceReconfigure); err != nil {
 		return err
 	}
-	if err := haproxy.Instance.Reload(); err != nil {
-		return err
+	if !m.applyDomainMap(stored, wasStored) {
+		if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
+			m.restoreConfigs(err, stored, wasStored)
+			return err
+		}
+		haproxy.AddReloadChange(m.ServiceName, haproxy.ReloadActionReconfigure, m.PostReloadHook)
+		if err := haproxy.Instance.Reload(); err != nil {
+			return err
+		}
 	}
 	if len(m.ConsulAddresses) > 0 || !isSwarm(m.ServiceReconfigure.Mode) {
 		if err := m.putToConsul(m.ConsulAddresses, m.ServiceReconfigure, m.InstanceName); err != nil {
@@ -112,6 +216,62 @@ func (m *Reconfigure) Execute(args []string) error {
 	return nil
 }
 
+// restoreConfigs puts back the configuration files the service had before it was reconfigured if the assembled
+// configuration exceeded the limits. Otherwise, the files would keep every following configuration over the limits.
+func (m *Reconfigure) restoreConfigs(err error, stored ServiceReconfigure, wasStored bool) {
+	if _, ok := err.(*haproxy.ConfigLimitError); !ok {
+		return
+	}
+	m.rollbackConfigs(stored, wasStored)
+}
+
+// rollbackConfigs puts back the configuration files of the stored service or, if the service was not stored, removes
+// the files created for it.
+func (m *Reconfigure) rollbackConfigs(stored ServiceReconfigure, wasStored bool) {
+	logPrintf("Restoring the previous configuration of the service %s", m.ServiceName)
+	if wasStored {
+		if err := m.createConfigs(m.TemplatesPath, &stored); err != nil {
+			logPrintf("Could not restore the configuration of the service %s\n%s", m.ServiceName, err.Error())
+		}
+		return
+	}
+	name := m.getStoredName()
+	m.removeStaleFeTemplate(m.TemplatesPath, name, true)
+	m.removeStaleFeTemplate(m.TemplatesPath, name, false)
+	be := fmt.Sprintf("%s/%s-be.cfg", m.TemplatesPath, name)
+	if err := removeBeTemplate(be); err != nil && !os.IsNotExist(err) {
+		logPrintf("Could not remove %s\n%s", be, err.Error())
+	}
+	RemoveKnownService(name)
+}
+
+// getStoredName returns the name createConfigs stores the service under.
+func (m *Reconfigure) getStoredName() string {
+	if isSwarm(m.Mode) && len(m.AclName) > 0 {
+		return m.AclName
+	}
+	return m.ServiceName
+}
+
+// checkServiceAddress fails if the service cannot be resolved in the swarm mode.
+func (m *Reconfigure) checkServiceAddress() error {
+	if !isSwarm(m.ServiceReconfigure.Mode) || m.skipAddressValidation {
+		return nil
+	}
+	host := m.ServiceName
+	if len(m.OutboundHostname) > 0 {
+		host = m.OutboundHostname
+	}
+	if address := m.getServiceAddress(&m.ServiceReconfigure); len(address) > 0 {
+		host = address
+	}
+	if _, err := lookupHost(host); err != nil {
+		logPrintf("Could not reach the service %s. Is the service running and connected to the same network as the proxy?", host)
+		return err
+	}
+	return nil
+}
+
 func (m *Reconfigure) GetData() (BaseReconfigure, ServiceReconfigure) {
 	return m.BaseReconfigure, m.ServiceReconfigure
 }
@@ -119,11 +279,14 @@ func (m *Reconfigure) GetData() (BaseReconfigure, ServiceReconfigure) {
 func (m *Reconfigure) ReloadAllServices(addresses []string, instanceName, mode, listenerAddress string) error {
 	if len(listenerAddress) > 0 {
 		fullAddress := fmt.Sprintf("%s/v1/docker-flow-swarm-listener/notify-services", listenerAddress)
+		start := time.Now()
 		resp, err := httpGet(fullAddress)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("Swarm Listener responded with the status code %d", resp.StatusCode)
+		}
+		ListenerMetrics.Observe("notify_services", listenerAddress, time.Since(start), err, false)
 		if err != nil {
 			return err
-		} else if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("Swarm Listener responded with the status code %d", resp.StatusCode)
 		}
 		logPrintf("A request was sent to the Swarm listener running on %s. The proxy will be reconfigured soon.", listenerAddress)
 	} else if len(addresses) > 0 || !isSwarm(mode) {
@@ -137,9 +300,9 @@ func (m *Reconfigure) reloadFromRegistry(addresses []string, instanceName, mode
 	var err error
 	logPrintf("Configuring existing services")
 	found := false
-	for _, address := range addresses {
+	for _, consulAddress := range registry.OrderConsulAddresses(addresses) {
 		var servicesUrl string
-		address = strings.ToLower(address)
+		address := strings.ToLower(consulAddress)
 		if !strings.HasPrefix(address, "http") {
 			address = fmt.Sprintf("http://%s", address)
 		}
@@ -149,7 +312,8 @@ func (m *Reconfigure) reloadFromRegistry(addresses []string, instanceName, mode
 		} else {
 			servicesUrl = fmt.Sprintf("%s/v1/catalog/services", address)
 		}
-		resp, err = http.Get(servicesUrl)
+		resp, err = (&http.Client{Timeout: registry.ConsulTimeout}).Get(servicesUrl)
+		registry.MarkConsulAddress(consulAddress, err)
 		if err == nil {
 			found = true
 			break
@@ -187,19 +351,42 @@ func (m *Reconfigure) reloadFromRegistry(addresses []string, instanceName, mode
 	for i := 0; i < count; i++ {
 		s := <-c
 		s.Mode = mode
-		if len(s.ServicePath) > 0 {
+		if err := m.validateServiceNames(&s); err != nil {
+			logPrintf("WARNING: The service %s was skipped\n%s", s.ServiceName, err.Error())
+			continue
+		}
+		if !s.MatchesProxyRole() {
+			m.withdraw(m.TemplatesPath, s)
+			continue
+		}
+		// Files of services that are not configured on startup are still in use
+		AddKnownService(s.ServiceName)
+		if len(s.ServicePath) > 0 || s.IsTcp() {
 			logPrintf("\tConfiguring %s", s.ServiceName)
 			m.createConfigs(m.TemplatesPath, &s)
 		}
 	}
+	if _, _, err := WriteDomainMap(m.ConfigsPath); err != nil {
+		logPrintf(err.Error())
+	}
 	if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
 		return err
 	}
+	if m.BeforeStartupReload != nil && !m.BeforeStartupReload() {
+		logPrintf("The proxy was not reloaded with the configuration of the existing services")
+		return nil
+	}
 	return haproxy.Instance.Reload()
 }
 
-func (m *Reconfigure) getService(addresses []string, serviceName, instanceName string, c chan ServiceReconfigure) {
+// getService reads the service from the registry. Services are stored under their canonical names since the
+// registry was migrated but the names of services registered in the Consul catalog are kept as they are.
+func (m *Reconfigure) getService(addresses []string, registeredName, instanceName string, c chan ServiceReconfigure) {
+	serviceName := CanonicalServiceName(registeredName)
 	sr := ServiceReconfigure{ServiceName: serviceName}
+	if serviceName != registeredName {
+		sr.ServiceDisplayName = registeredName
+	}
 
 	path, err := registryInstance.GetServiceAttribute(addresses, serviceName, registry.PATH_KEY, instanceName)
 	domain, err := registryInstance.GetServiceAttribute(addresses, serviceName, registry.DOMAIN_KEY, instanceName)
@@ -215,15 +402,147 @@ func (m *Reconfigure) getService(addresses []string, serviceName, instanceName s
 		sr.ConsulTemplateFePath, _ = m.getServiceAttribute(addresses, serviceName, registry.CONSUL_TEMPLATE_FE_PATH_KEY, instanceName)
 		sr.ConsulTemplateBePath, _ = m.getServiceAttribute(addresses, serviceName, registry.CONSUL_TEMPLATE_BE_PATH_KEY, instanceName)
 		sr.Port, _ = m.getServiceAttribute(addresses, serviceName, registry.PORT, instanceName)
+		sr.ServiceAddress, _ = m.getServiceAttribute(addresses, serviceName, registry.ADDRESS_KEY, instanceName)
+		colorAddresses, _ := m.getServiceAttribute(addresses, serviceName, registry.COLOR_ADDRESSES_KEY, instanceName)
+		sr.ColorAddresses = registry.ParseColorAddresses(colorAddresses)
+		dcAddresses, _ := m.getServiceAttribute(addresses, serviceName, registry.DC_ADDRESSES_KEY, instanceName)
+		sr.DcAddresses = registry.ParseColorAddresses(dcAddresses)
+		corsOrigins, _ := m.getServiceAttribute(addresses, serviceName, registry.CORS_ORIGINS_KEY, instanceName)
+		sr.CorsOrigins = m.splitServiceAttribute(corsOrigins)
+		corsMethods, _ := m.getServiceAttribute(addresses, serviceName, registry.CORS_METHODS_KEY, instanceName)
+		sr.CorsMethods = m.splitServiceAttribute(corsMethods)
+		corsHeaders, _ := m.getServiceAttribute(addresses, serviceName, registry.CORS_HEADERS_KEY, instanceName)
+		sr.CorsHeaders = m.splitServiceAttribute(corsHeaders)
+		internalOnly, _ := m.getServiceAttribute(addresses, serviceName, registry.INTERNAL_ONLY_KEY, instanceName)
+		sr.InternalOnly, _ = strconv.ParseBool(internalOnly)
+		checkGrpc, _ := m.getServiceAttribute(addresses, serviceName, registry.CHECK_GRPC_KEY, instanceName)
+		sr.CheckGrpc, _ = strconv.ParseBool(checkGrpc)
+		sr.Profile, _ = m.getServiceAttribute(addresses, serviceName, registry.PROFILE_KEY, instanceName)
+		explicitParameters, _ := m.getServiceAttribute(addresses, serviceName, registry.EXPLICIT_PARAMETERS_KEY, instanceName)
+		sr.ExplicitParameters = m.splitServiceAttribute(explicitParameters)
+		sr.TracingSampleRate, _ = m.getServiceAttribute(addresses, serviceName, registry.TRACING_SAMPLE_RATE_KEY, instanceName)
+		sr.CanaryHeader, _ = m.getServiceAttribute(addresses, serviceName, registry.CANARY_HEADER_KEY, instanceName)
+		sr.ServiceDescription, _ = m.getServiceAttribute(addresses, serviceName, registry.SERVICE_DESCRIPTION_KEY, instanceName)
+		sr.Owner, _ = m.getServiceAttribute(addresses, serviceName, registry.OWNER_KEY, instanceName)
+		if displayName, _ := m.getServiceAttribute(addresses, serviceName, registry.SERVICE_DISPLAY_NAME_KEY, instanceName); len(displayName) > 0 {
+			sr.ServiceDisplayName = displayName
+		}
+		timeoutServer, _ := m.getServiceAttribute(addresses, serviceName, registry.TIMEOUT_SERVER_KEY, instanceName)
+		sr.TimeoutServer, _ = strconv.Atoi(timeoutServer)
+		timeoutTunnel, _ := m.getServiceAttribute(addresses, serviceName, registry.TIMEOUT_TUNNEL_KEY, instanceName)
+		sr.TimeoutTunnel, _ = strconv.Atoi(timeoutTunnel)
+		timeoutHttpRequest, _ := m.getServiceAttribute(addresses, serviceName, registry.TIMEOUT_HTTP_REQUEST_KEY, instanceName)
+		sr.TimeoutHttpRequest, _ = strconv.Atoi(timeoutHttpRequest)
+		timeoutQueue, _ := m.getServiceAttribute(addresses, serviceName, registry.TIMEOUT_QUEUE_KEY, instanceName)
+		sr.TimeoutQueue, _ = strconv.Atoi(timeoutQueue)
+		timeoutConnect, _ := m.getServiceAttribute(addresses, serviceName, registry.TIMEOUT_CONNECT_KEY, instanceName)
+		sr.TimeoutConnect, _ = strconv.Atoi(timeoutConnect)
+		timeoutClient, _ := m.getServiceAttribute(addresses, serviceName, registry.TIMEOUT_CLIENT_KEY, instanceName)
+		sr.TimeoutClient, _ = strconv.Atoi(timeoutClient)
+		sr.StackName, _ = m.getServiceAttribute(addresses, serviceName, registry.STACK_NAME_KEY, instanceName)
+		allowMissingHost, _ := m.getServiceAttribute(addresses, serviceName, registry.ALLOW_MISSING_HOST_KEY, instanceName)
+		sr.AllowMissingHost, _ = strconv.ParseBool(allowMissingHost)
+		useDomainMap, _ := m.getServiceAttribute(addresses, serviceName, registry.USE_DOMAIN_MAP_KEY, instanceName)
+		sr.UseDomainMap, _ = strconv.ParseBool(useDomainMap)
+		letsEncrypt, _ := m.getServiceAttribute(addresses, serviceName, registry.LETS_ENCRYPT_KEY, instanceName)
+		sr.LetsEncrypt, _ = strconv.ParseBool(letsEncrypt)
+		sr.ProxyRole, _ = m.getServiceAttribute(addresses, serviceName, registry.PROXY_ROLE_KEY, instanceName)
+		sr.ClientCertVerify, _ = m.getServiceAttribute(addresses, serviceName, registry.CLIENT_CERT_VERIFY_KEY, instanceName)
+		sr.ClientCertCaFile, _ = m.getServiceAttribute(addresses, serviceName, registry.CLIENT_CERT_CA_FILE_KEY, instanceName)
+		tcpDestinations, _ := m.getServiceAttribute(addresses, serviceName, registry.TCP_DESTINATIONS_KEY, instanceName)
+		sr.TcpDestinations = ParseTcpDestinations(tcpDestinations)
+		httpDestinations, _ := m.getServiceAttribute(addresses, serviceName, registry.HTTP_DESTINATIONS_KEY, instanceName)
+		sr.HttpDestinations = ParseHttpDestinations(httpDestinations)
+		sr.Maintenance, _ = m.getServiceAttribute(addresses, serviceName, registry.MAINTENANCE_KEY, instanceName)
+		sr.MaintenanceWindow, _ = m.getServiceAttribute(addresses, serviceName, registry.MAINTENANCE_WINDOW_KEY, instanceName)
+		sr.CertName, _ = m.getServiceAttribute(addresses, serviceName, registry.CERT_NAME_KEY, instanceName)
+		sr.HttpReuse, _ = m.getServiceAttribute(addresses, serviceName, registry.HTTP_REUSE_KEY, instanceName)
+		sr.PoolMaxConn, _ = m.getServiceAttribute(addresses, serviceName, registry.POOL_MAX_CONN_KEY, instanceName)
+		sr.PoolPurgeDelay, _ = m.getServiceAttribute(addresses, serviceName, registry.POOL_PURGE_DELAY_KEY, instanceName)
+		sr.PostReloadHook, _ = m.getServiceAttribute(addresses, serviceName, registry.POST_RELOAD_HOOK_KEY, instanceName)
+		sr.SrcPort, _ = m.getServiceAttribute(addresses, serviceName, registry.SRC_PORT_KEY, instanceName)
+		httpsOnly, _ := m.getServiceAttribute(addresses, serviceName, registry.HTTPS_ONLY_KEY, instanceName)
+		sr.HttpsOnly, _ = strconv.ParseBool(httpsOnly)
+		sr.HttpsPort, _ = m.getServiceAttribute(addresses, serviceName, registry.HTTPS_PORT_KEY, instanceName)
+		sslVerifyNone, _ := m.getServiceAttribute(addresses, serviceName, registry.SSL_VERIFY_NONE_KEY, instanceName)
+		sr.SslVerifyNone, _ = strconv.ParseBool(sslVerifyNone)
+		sr.SslCaCert, _ = m.getServiceAttribute(addresses, serviceName, registry.SSL_CA_CERT_KEY, instanceName)
+		sr.SessionType, _ = m.getServiceAttribute(addresses, serviceName, registry.SESSION_TYPE_KEY, instanceName)
+		sr.CookieName, _ = m.getServiceAttribute(addresses, serviceName, registry.COOKIE_NAME_KEY, instanceName)
+		sr.Balance, _ = m.getServiceAttribute(addresses, serviceName, registry.BALANCE_KEY, instanceName)
+		sr.ConnectionMode, _ = m.getServiceAttribute(addresses, serviceName, registry.CONNECTION_MODE_KEY, instanceName)
+		sr.ReqMode, _ = m.getServiceAttribute(addresses, serviceName, registry.REQ_MODE_KEY, instanceName)
+		serviceDomainMatchAll, _ := m.getServiceAttribute(addresses, serviceName, registry.SERVICE_DOMAIN_MATCH_ALL_KEY, instanceName)
+		sr.ServiceDomainMatchAll, _ = strconv.ParseBool(serviceDomainMatchAll)
+		redirectFromDomain, _ := m.getServiceAttribute(addresses, serviceName, registry.REDIRECT_FROM_DOMAIN_KEY, instanceName)
+		sr.RedirectFromDomain = m.splitServiceAttribute(redirectFromDomain)
+		allowedMethods, _ := m.getServiceAttribute(addresses, serviceName, registry.ALLOWED_METHODS_KEY, instanceName)
+		sr.AllowedMethods = m.splitServiceAttribute(allowedMethods)
+		deniedMethods, _ := m.getServiceAttribute(addresses, serviceName, registry.DENIED_METHODS_KEY, instanceName)
+		sr.DeniedMethods = m.splitServiceAttribute(deniedMethods)
+		denyHttp, _ := m.getServiceAttribute(addresses, serviceName, registry.DENY_HTTP_KEY, instanceName)
+		sr.DenyHttp, _ = strconv.ParseBool(denyHttp)
+		addReqHeader, _ := m.getServiceAttribute(addresses, serviceName, registry.ADD_REQ_HEADER_KEY, instanceName)
+		sr.AddReqHeader = ParseHeaders(addReqHeader)
+		setReqHeader, _ := m.getServiceAttribute(addresses, serviceName, registry.SET_REQ_HEADER_KEY, instanceName)
+		sr.SetReqHeader = ParseHeaders(setReqHeader)
+		delReqHeader, _ := m.getServiceAttribute(addresses, serviceName, registry.DEL_REQ_HEADER_KEY, instanceName)
+		sr.DelReqHeader = ParseHeaders(delReqHeader)
+		addResHeader, _ := m.getServiceAttribute(addresses, serviceName, registry.ADD_RES_HEADER_KEY, instanceName)
+		sr.AddResHeader = ParseHeaders(addResHeader)
+		delResHeader, _ := m.getServiceAttribute(addresses, serviceName, registry.DEL_RES_HEADER_KEY, instanceName)
+		sr.DelResHeader = ParseHeaders(delResHeader)
+		sr.ForwardedProto, _ = m.getServiceAttribute(addresses, serviceName, registry.FORWARDED_PROTO_KEY, instanceName)
+		compressionAlgo, _ := m.getServiceAttribute(addresses, serviceName, registry.COMPRESSION_ALGO_KEY, instanceName)
+		sr.CompressionAlgo = m.splitServiceAttribute(compressionAlgo)
+		compressionType, _ := m.getServiceAttribute(addresses, serviceName, registry.COMPRESSION_TYPE_KEY, instanceName)
+		sr.CompressionType = m.splitServiceAttribute(compressionType)
+		sr.ReqRateLimit, _ = m.getServiceAttribute(addresses, serviceName, registry.REQ_RATE_LIMIT_KEY, instanceName)
+		reqRatePeriod, _ := m.getServiceAttribute(addresses, serviceName, registry.REQ_RATE_PERIOD_KEY, instanceName)
+		sr.ReqRatePeriod, _ = strconv.Atoi(reqRatePeriod)
+		allowedSourceIPs, _ := m.getServiceAttribute(addresses, serviceName, registry.ALLOWED_SOURCE_IPS_KEY, instanceName)
+		sr.AllowedSourceIPs = m.splitServiceAttribute(allowedSourceIPs)
+		sr.BackendExtra, _ = m.getServiceAttribute(addresses, serviceName, registry.BACKEND_EXTRA_KEY, instanceName)
+		sr.FrontendExtra, _ = m.getServiceAttribute(addresses, serviceName, registry.FRONTEND_EXTRA_KEY, instanceName)
+		sr.CheckPath, _ = m.getServiceAttribute(addresses, serviceName, registry.CHECK_PATH_KEY, instanceName)
+		sr.CheckMethod, _ = m.getServiceAttribute(addresses, serviceName, registry.CHECK_METHOD_KEY, instanceName)
+		sr.CheckInterval, _ = m.getServiceAttribute(addresses, serviceName, registry.CHECK_INTERVAL_KEY, instanceName)
+		sr.CheckRise, _ = m.getServiceAttribute(addresses, serviceName, registry.CHECK_RISE_KEY, instanceName)
+		sr.CheckFall, _ = m.getServiceAttribute(addresses, serviceName, registry.CHECK_FALL_KEY, instanceName)
+		sr.CheckTimeout, _ = m.getServiceAttribute(addresses, serviceName, registry.CHECK_TIMEOUT_KEY, instanceName)
+		sr.HttpCheckExpect, _ = m.getServiceAttribute(addresses, serviceName, registry.HTTP_CHECK_EXPECT_KEY, instanceName)
+		sr.BackupHostname, _ = m.getServiceAttribute(addresses, serviceName, registry.BACKUP_HOSTNAME_KEY, instanceName)
+		sr.BackupPort, _ = m.getServiceAttribute(addresses, serviceName, registry.BACKUP_PORT_KEY, instanceName)
+		sr.Weight, _ = m.getServiceAttribute(addresses, serviceName, registry.WEIGHT_KEY, instanceName)
+		colorWeights, _ := m.getServiceAttribute(addresses, serviceName, registry.COLOR_WEIGHTS_KEY, instanceName)
+		sr.ColorWeights = registry.ParseColorAddresses(colorWeights)
+		sr.MaxConn, _ = m.getServiceAttribute(addresses, serviceName, registry.MAX_CONN_KEY, instanceName)
+		sr.Retries, _ = m.getServiceAttribute(addresses, serviceName, registry.RETRIES_KEY, instanceName)
+		redispatch, _ := m.getServiceAttribute(addresses, serviceName, registry.REDISPATCH_KEY, instanceName)
+		sr.Redispatch, _ = strconv.ParseBool(redispatch)
+		redirectWhenHttpProto, _ := m.getServiceAttribute(addresses, serviceName, registry.REDIRECT_WHEN_HTTP_PROTO_KEY, instanceName)
+		sr.RedirectWhenHttpProto, _ = strconv.ParseBool(redirectWhenHttpProto)
+		// Profiles might have been redefined since the service was stored
+		if err := ApplyProfile(&sr); err != nil {
+			logPrintf(err.Error())
+		}
 	}
 	c <- sr
 }
 
+func (m *Reconfigure) validateServiceNames(sr *ServiceReconfigure) error {
+	if err := ValidateServiceName("serviceName", sr.ServiceName); err != nil {
+		return err
+	}
+	return ValidateServiceName("aclName", sr.AclName)
+}
+
 // TODO: Remove in favour of registry.GetServiceAttribute
 func (m *Reconfigure) getServiceAttribute(addresses []string, serviceName, key, instanceName string) (string, bool) {
-	for _, address := range addresses {
+	for _, address := range registry.OrderConsulAddresses(addresses) {
 		url := fmt.Sprintf("%s/v1/kv/%s/%s/%s?raw", address, instanceName, serviceName, key)
-		resp, err := http.Get(url)
+		resp, err := (&http.Client{Timeout: registry.ConsulTimeout}).Get(url)
+		registry.MarkConsulAddress(address, err)
 		if err == nil && resp.StatusCode == http.StatusOK {
 			defer resp.Body.Close()
 			body, _ := ioutil.ReadAll(resp.Body)
@@ -233,6 +552,13 @@ func (m *Reconfigure) getServiceAttribute(addresses []string, serviceName, key,
 	return "", false
 }
 
+func (m *Reconfigure) splitServiceAttribute(value string) []string {
+	if len(value) == 0 {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
 func (m *Reconfigure) createConfigs(templatesPath string, sr *ServiceReconfigure) error {
 	logPrintf("Creating configuration for the service %s", sr.ServiceName)
 	feTemplate, beTemplate, err := m.GetTemplates(*sr)
@@ -243,10 +569,16 @@ func (m *Reconfigure) createConfigs(templatesPath string, sr *ServiceReconfigure
 		if len(sr.AclName) == 0 {
 			sr.AclName = sr.ServiceName
 		}
+		m.removeStaleFeTemplate(templatesPath, sr.AclName, sr.InternalOnly)
 		destFe := fmt.Sprintf("%s/%s-fe.cfg", templatesPath, sr.AclName)
+		if sr.InternalOnly {
+			destFe = fmt.Sprintf("%s/%s-internal-fe.cfg", templatesPath, sr.AclName)
+		}
 		writeFeTemplate(destFe, []byte(feTemplate), 0664)
 		destBe := fmt.Sprintf("%s/%s-be.cfg", templatesPath, sr.AclName)
 		writeBeTemplate(destBe, []byte(beTemplate), 0664)
+		AddKnownService(sr.AclName)
+		storeService(sr.AclName, *sr)
 	} else {
 		args := registry.CreateConfigsArgs{
 			Addresses:     m.ConsulAddresses,
@@ -256,27 +588,123 @@ func (m *Reconfigure) createConfigs(templatesPath string, sr *ServiceReconfigure
 			BeFile:        ServiceTemplateBeFilename,
 			BeTemplate:    beTemplate,
 			ServiceName:   sr.ServiceName,
+			InternalOnly:  sr.InternalOnly,
 		}
+		m.removeStaleFeTemplate(templatesPath, sr.ServiceName, sr.InternalOnly)
 		if err = registryInstance.CreateConfigs(&args); err != nil {
 			return err
 		}
+		AddKnownService(sr.ServiceName)
+		storeService(sr.ServiceName, *sr)
 	}
 	return nil
 }
 
+// removeStaleFeTemplate removes the frontend configuration left behind when a service moves between the public and
+// the internal frontend.
+func (m *Reconfigure) removeStaleFeTemplate(templatesPath, name string, internalOnly bool) {
+	stale := fmt.Sprintf("%s/%s-internal-fe.cfg", templatesPath, name)
+	if internalOnly {
+		stale = fmt.Sprintf("%s/%s-fe.cfg", templatesPath, name)
+	}
+	if err := removeFeTemplate(stale); err != nil && !os.IsNotExist(err) {
+		logPrintf("Could not remove %s\n%s", stale, err.Error())
+	}
+}
+
 func (m *Reconfigure) putToConsul(addresses []string, sr ServiceReconfigure, instanceName string) error {
 	r := registry.Registry{
-		ServiceName:          sr.ServiceName,
-		ServiceColor:         sr.ServiceColor,
-		ServicePath:          sr.ServicePath,
-		ServiceDomain:        sr.ServiceDomain,
-		ServiceCert:          sr.ServiceCert,
-		OutboundHostname:     sr.OutboundHostname,
-		PathType:             sr.PathType,
-		SkipCheck:            sr.SkipCheck,
-		ConsulTemplateFePath: sr.ConsulTemplateFePath,
-		ConsulTemplateBePath: sr.ConsulTemplateBePath,
-		Port:                 sr.Port,
+		ServiceName:           sr.ServiceName,
+		ServiceDisplayName:    sr.ServiceDisplayName,
+		ServiceColor:          sr.ServiceColor,
+		ServicePath:           sr.ServicePath,
+		ServiceDomain:         sr.ServiceDomain,
+		ServiceCert:           sr.ServiceCert,
+		OutboundHostname:      sr.OutboundHostname,
+		PathType:              sr.PathType,
+		SkipCheck:             sr.SkipCheck,
+		ConsulTemplateFePath:  sr.ConsulTemplateFePath,
+		ConsulTemplateBePath:  sr.ConsulTemplateBePath,
+		Port:                  sr.Port,
+		ServiceAddress:        sr.ServiceAddress,
+		ColorAddresses:        sr.ColorAddresses,
+		DcAddresses:           sr.DcAddresses,
+		CorsOrigins:           sr.CorsOrigins,
+		CorsMethods:           sr.CorsMethods,
+		CorsHeaders:           sr.CorsHeaders,
+		InternalOnly:          sr.InternalOnly,
+		CheckGrpc:             sr.CheckGrpc,
+		Profile:               sr.Profile,
+		ExplicitParameters:    sr.ExplicitParameters,
+		TracingSampleRate:     sr.TracingSampleRate,
+		CanaryHeader:          sr.CanaryHeader,
+		ServiceDescription:    sr.ServiceDescription,
+		Owner:                 sr.Owner,
+		TimeoutServer:         sr.TimeoutServer,
+		TimeoutTunnel:         sr.TimeoutTunnel,
+		TimeoutHttpRequest:    sr.TimeoutHttpRequest,
+		TimeoutQueue:          sr.TimeoutQueue,
+		TimeoutConnect:        sr.TimeoutConnect,
+		TimeoutClient:         sr.TimeoutClient,
+		StackName:             sr.StackName,
+		AllowMissingHost:      sr.AllowMissingHost,
+		UseDomainMap:          sr.UseDomainMap,
+		LetsEncrypt:           sr.LetsEncrypt,
+		ProxyRole:             sr.ProxyRole,
+		ClientCertVerify:      sr.ClientCertVerify,
+		ClientCertCaFile:      sr.ClientCertCaFile,
+		TcpDestinations:       FormatTcpDestinations(sr.TcpDestinations),
+		HttpDestinations:      FormatHttpDestinations(sr.HttpDestinations),
+		Maintenance:           sr.Maintenance,
+		MaintenanceWindow:     sr.MaintenanceWindow,
+		CertName:              sr.CertName,
+		HttpReuse:             sr.HttpReuse,
+		PoolMaxConn:           sr.PoolMaxConn,
+		PoolPurgeDelay:        sr.PoolPurgeDelay,
+		PostReloadHook:        sr.PostReloadHook,
+		SrcPort:               sr.SrcPort,
+		HttpsOnly:             sr.HttpsOnly,
+		HttpsPort:             sr.HttpsPort,
+		SslVerifyNone:         sr.SslVerifyNone,
+		SslCaCert:             sr.SslCaCert,
+		SessionType:           sr.SessionType,
+		CookieName:            sr.CookieName,
+		Balance:               sr.Balance,
+		ConnectionMode:        sr.ConnectionMode,
+		ReqMode:               sr.ReqMode,
+		ServiceDomainMatchAll: sr.ServiceDomainMatchAll,
+		RedirectFromDomain:    sr.RedirectFromDomain,
+		AllowedMethods:        sr.AllowedMethods,
+		DeniedMethods:         sr.DeniedMethods,
+		DenyHttp:              sr.DenyHttp,
+		AddReqHeader:          FormatHeaders(sr.AddReqHeader),
+		SetReqHeader:          FormatHeaders(sr.SetReqHeader),
+		DelReqHeader:          FormatHeaders(sr.DelReqHeader),
+		AddResHeader:          FormatHeaders(sr.AddResHeader),
+		DelResHeader:          FormatHeaders(sr.DelResHeader),
+		ForwardedProto:        sr.ForwardedProto,
+		CompressionAlgo:       sr.CompressionAlgo,
+		CompressionType:       sr.CompressionType,
+		ReqRateLimit:          sr.ReqRateLimit,
+		ReqRatePeriod:         sr.ReqRatePeriod,
+		AllowedSourceIPs:      sr.AllowedSourceIPs,
+		BackendExtra:          sr.BackendExtra,
+		FrontendExtra:         sr.FrontendExtra,
+		CheckPath:             sr.CheckPath,
+		CheckMethod:           sr.CheckMethod,
+		CheckInterval:         sr.CheckInterval,
+		CheckRise:             sr.CheckRise,
+		CheckFall:             sr.CheckFall,
+		CheckTimeout:          sr.CheckTimeout,
+		HttpCheckExpect:       sr.HttpCheckExpect,
+		BackupHostname:        sr.BackupHostname,
+		BackupPort:            sr.BackupPort,
+		Weight:                sr.Weight,
+		ColorWeights:          sr.ColorWeights,
+		MaxConn:               sr.MaxConn,
+		Retries:               sr.Retries,
+		Redispatch:            sr.Redispatch,
+		RedirectWhenHttpProto: sr.RedirectWhenHttpProto,
 	}
 	if err := registryInstance.PutService(addresses, instanceName, r); err != nil {
 		return err
@@ -305,15 +733,148 @@ func (m *Reconfigure) GetTemplates(sr ServiceReconfigure) (front, back string, e
 			return "", "", err
 		}
 	} else {
+		if err := m.getVersionError(&sr); err != nil {
+			return "", "", err
+		}
 		m.formatData(&sr)
+		if sr.IsTcp() {
+			// The service is reachable only through its tcp frontends
+			_, back = m.parseTemplate("", m.getTcpTemplate(&sr), sr)
+			return "", strings.TrimPrefix(back, "\n\n"), nil
+		}
+		maintenanceFront, maintenanceBack := m.getMaintenanceTemplates(sr.IsInMaintenance(maintenanceNow()))
 		front, back = m.parseTemplate(
-			m.getFrontTemplate(&sr),
-			m.getBackTemplate(&sr),
+			m.getRedirectFromDomainTemplate(&sr)+m.getFrontTemplate(&sr, maintenanceFront),
+			m.getBackTemplate(&sr)+maintenanceBack,
 			sr)
+		if canaryBack := m.getCanaryBackTemplate(sr); len(canaryBack) > 0 {
+			back += "\n\n" + canaryBack
+		}
+		if httpsBack := m.getHttpsBackTemplate(sr); len(httpsBack) > 0 {
+			back += "\n\n" + httpsBack
+		}
+		destFront, destBack := m.getHttpDestinationTemplates(sr, maintenanceFront)
+		front += destFront
+		back += destBack
+		_, tcpBack := m.parseTemplate("", m.getTcpTemplate(&sr), sr)
+		back += tcpBack
+	}
+	if len(sr.SrcPort) > 0 {
+		back += "\n\n" + m.getSrcPortFrontTemplate(&sr, front)
 	}
 	return front, back, nil
 }
 
+// getSrcPortFrontTemplate returns the frontend bound to srcPort. It holds the same rules as the frontends of the ports
+// 80 and 443 and uses the same certificates, which are added by the proxy when it assembles the configuration. Like
+// the tcp frontends, it belongs to the service and is removed together with it.
+func (m *Reconfigure) getSrcPortFrontTemplate(sr *ServiceReconfigure, front string) string {
+	timeout := ""
+	if sr.TimeoutClient > 0 {
+		timeout = fmt.Sprintf("\n    timeout client %ds", sr.TimeoutClient)
+	}
+	return fmt.Sprintf(`frontend services_%s
+    bind *:%s{{.CertsString}}
+    mode http%s%s`, sr.SrcPort, sr.SrcPort, timeout, front)
+}
+
+// getCanaryBackTemplate returns a backend with the servers of the canary color. The frontend routes requests with
+// the canary header to it.
+func (m *Reconfigure) getCanaryBackTemplate(sr ServiceReconfigure) string {
+	header := m.getCanaryHeader(&sr)
+	if header == nil {
+		return ""
+	}
+	canary := sr
+	canary.ServiceName = fmt.Sprintf("%s-%s", sr.ServiceName, header[1])
+	canary.AclName = fmt.Sprintf("%s-%s", sr.AclName, header[1])
+	canary.ServiceColor = header[1]
+	canary.FullServiceName = canary.ServiceName
+	canary.Host = canary.ServiceName
+	canary.DcAddresses = nil
+	// The canary backend has the servers of a single color so their weight does not matter
+	canary.Weight = ""
+	canary.ColorWeights = nil
+	if address := canary.ColorAddresses[header[1]]; len(address) > 0 {
+		canary.Host = address
+	}
+	_, back := m.parseTemplate("", m.getBackTemplate(&canary), canary)
+	return back
+}
+
+// getHttpsBackTemplate returns a backend with the servers listening on httpsPort. The frontend routes the requests that
+// come through HTTPS to it when the service sets a different port as well.
+func (m *Reconfigure) getHttpsBackTemplate(sr ServiceReconfigure) string {
+	if !m.hasHttpsBackend(&sr) {
+		return ""
+	}
+	https := sr
+	https.ServiceName = sr.ServiceName + "-https"
+	https.AclName = sr.AclName + "-https"
+	https.Port = sr.HttpsPort
+	_, back := m.parseTemplate("", m.getBackTemplate(&https), https)
+	return back
+}
+
+// hasHttpsBackend tells whether the service has a separate backend for httpsPort.
+func (m *Reconfigure) hasHttpsBackend(sr *ServiceReconfigure) bool {
+	return len(sr.HttpsPort) > 0 && sr.Port != sr.HttpsPort
+}
+
+// getServerSslTemplate returns the options of the servers of the service when they are reached through TLS, either
+// through httpsPort or because sslVerifyNone or sslCaCert is set. The certificates of the servers are verified only
+// against sslCaCert.
+func (m *Reconfigure) getServerSslTemplate(sr *ServiceReconfigure) string {
+	if len(sr.SslCaCert) > 0 {
+		return fmt.Sprintf(" ssl verify required ca-file %s/%s", haproxy.CaCertsDir, sr.SslCaCert)
+	} else if sr.SslVerifyNone || (len(sr.HttpsPort) > 0 && sr.Port == sr.HttpsPort) {
+		return " ssl verify none"
+	}
+	return ""
+}
+
+// isSticky tells whether the requests of a client are sent to the server that answered its first request.
+func (m *Reconfigure) isSticky(sr *ServiceReconfigure) bool {
+	return strings.EqualFold(sr.SessionType, "sticky-server")
+}
+
+// getServerCookieTemplate returns the cookie identifying the server when the service is sticky. The identifiers include
+// the color of the service so that the cookies issued by the servers of one color never match the servers of another.
+func (m *Reconfigure) getServerCookieTemplate(sr *ServiceReconfigure, suffix string) string {
+	if !m.isSticky(sr) {
+		return ""
+	}
+	return " cookie {{.FullServiceName}}" + suffix
+}
+
+// getCanaryHeader returns the name and the value (color) of the canary header or nil if the canary is not used.
+func (m *Reconfigure) getCanaryHeader(sr *ServiceReconfigure) []string {
+	header := strings.SplitN(sr.CanaryHeader, ":", 2)
+	if len(header) != 2 || header[1] == sr.ServiceColor {
+		return nil
+	}
+	return header
+}
+
+// getVersionError returns an error if the service uses a feature that is not supported by the HAProxy version.
+func (m *Reconfigure) getVersionError(sr *ServiceReconfigure) error {
+	features := []struct {
+		name         string
+		used         bool
+		major, minor int
+	}{
+		{"CORS", len(sr.CorsOrigins) > 0, 2, 2},
+		{"gRPC health checking", sr.CheckGrpc, 2, 2},
+		{"Connection pooling", len(sr.PoolMaxConn) > 0 || len(sr.PoolPurgeDelay) > 0, 1, 9},
+	}
+	for _, f := range features {
+		if f.used && !haproxy.VersionAtLeast(f.major, f.minor) {
+			return fmt.Errorf("%s requires HAProxy %d.%d or newer. The detected version is %s", f.name, f.major, f.minor, haproxy.GetVersion())
+		}
+	}
+	return nil
+}
+
 func (m *Reconfigure) formatData(sr *ServiceReconfigure) {
 	sr.Acl = ""
 	sr.AclCondition = ""
@@ -324,7 +885,21 @@ func (m *Reconfigure) formatData(sr *ServiceReconfigure) {
 	if len(m.OutboundHostname) > 0 {
 		sr.Host = m.OutboundHostname
 	}
-	if len(sr.ServiceDomain) > 0 {
+	if address := m.getServiceAddress(sr); len(address) > 0 {
+		sr.Host = address
+	}
+	if len(sr.ServiceDomain) > 0 && sr.ServiceDomainMatchAll {
+		// The domains and all their subdomains, matched on the Host header without its port
+		domains := []string{}
+		for _, domain := range sr.ServiceDomain {
+			domains = append(domains, strings.TrimLeft(domain, "*."))
+		}
+		sr.ServiceDomain = domains
+		sr.Acl = `
+    acl domain_{{.ServiceName}} req.hdr(host),field(1,:) -m dom -i{{range .ServiceDomain}} {{.}}{{end}}
+    acl domain_{{.ServiceName}} req.hdr(host),field(1,:) -m end -i{{range .ServiceDomain}} .{{.}}{{end}}`
+		sr.AclCondition = fmt.Sprintf(" domain_%s", sr.ServiceName)
+	} else if len(sr.ServiceDomain) > 0 {
 		domFunc := "hdr_dom"
 		for i, domain := range sr.ServiceDomain {
 			if strings.HasPrefix(domain, "*") {
@@ -347,18 +922,210 @@ func (m *Reconfigure) formatData(sr *ServiceReconfigure) {
 	if len(sr.PathType) == 0 {
 		sr.PathType = "path_beg"
 	}
+	// Services reachable only through TLS are served through httpsPort by the backend of the service
+	if len(sr.Port) == 0 {
+		sr.Port = sr.HttpsPort
+	}
+}
+
+// getServiceAddress returns the explicit address of the service. The address specified for the selected color
+// takes precedence over the generic service address.
+func (m *Reconfigure) getServiceAddress(sr *ServiceReconfigure) string {
+	if len(sr.ServiceColor) > 0 && len(sr.ColorAddresses[sr.ServiceColor]) > 0 {
+		return sr.ColorAddresses[sr.ServiceColor]
+	}
+	return sr.ServiceAddress
+}
+
+// getCorsTemplate answers preflight requests without forwarding them to the service and adds CORS headers to all
+// other responses. A request origin is echoed back only if it is one of the allowed origins.
+func (m *Reconfigure) getCorsTemplate(sr *ServiceReconfigure) string {
+	if len(sr.CorsOrigins) == 0 {
+		return ""
+	}
+	origin := `"*"`
+	condition := ""
+	tmpl := ""
+	if sr.CorsOrigins[0] != "*" {
+		origin = `"%[var(txn.cors_origin)]"`
+		condition = " cors_origin_{{.ServiceName}}"
+		tmpl += `
+    http-request set-var(txn.cors_origin) req.hdr(Origin)
+    acl cors_origin_{{.ServiceName}} var(txn.cors_origin) -m str{{range .CorsOrigins}} {{.}}{{end}}`
+	}
+	tmpl += `
+    http-request return status 204 hdr Access-Control-Allow-Origin ` + origin
+	if len(sr.CorsMethods) > 0 {
+		tmpl += ` hdr Access-Control-Allow-Methods "{{range $i, $e := .CorsMethods}}{{if $i}}, {{end}}{{$e}}{{end}}"`
+	}
+	if len(sr.CorsHeaders) > 0 {
+		tmpl += ` hdr Access-Control-Allow-Headers "{{range $i, $e := .CorsHeaders}}{{if $i}}, {{end}}{{$e}}{{end}}"`
+	}
+	tmpl += ` if METH_OPTIONS` + condition + `
+    http-response set-header Access-Control-Allow-Origin ` + origin
+	if len(condition) > 0 {
+		tmpl += ` if` + condition + `
+    http-response add-header Vary Origin`
+	}
+	return tmpl
+}
+
+// getTracingTemplate marks a share of the trace contexts started by the proxy as not sampled. Trace contexts that
+// were received with the request are propagated unchanged.
+func (m *Reconfigure) getTracingTemplate(sr *ServiceReconfigure) string {
+	rate, err := strconv.ParseFloat(sr.TracingSampleRate, 64)
+	if err != nil || rate >= 1 {
+		return ""
+	}
+	condition := fmt.Sprintf("if { var(txn.trace_started) -m bool } !{ rand(1000) lt %d }", int(rate*1000))
+	switch strings.ToLower(os.Getenv("TRACING_HEADERS")) {
+	case "b3":
+		return `
+    http-request set-header X-B3-Sampled 0 ` + condition
+	case "w3c":
+		return `
+    http-request replace-header traceparent ^(.*)-01$ \1-00 ` + condition
+	}
+	return ""
 }
 
-func (m *Reconfigure) getFrontTemplate(sr *ServiceReconfigure) string {
+// getFrontTemplate returns the rules of the service. The maintenance rule, if any, precedes the rules that route to the
+// backends of the service.
+func (m *Reconfigure) getFrontTemplate(sr *ServiceReconfigure, maintenance string) string {
+	// Domains in the map are routed by the rule of the frontend itself
+	if sr.UseDomainMap && len(sr.ServiceDomain) > 0 {
+		return ""
+	}
 	tmpl := fmt.Sprintf(
 		`
-    acl url_{{.ServiceName}}{{range .ServicePath}} {{$.PathType}} {{.}}{{end}}%s
-    use_backend {{.AclName}}-be if url_{{.ServiceName}}{{.AclCondition}}`,
+    acl url_{{.ServiceName}}{{range .ServicePath}} {{$.PathType}} {{.}}{{end}}%s`,
 		sr.Acl,
 	)
+	// Extra rules follow the ACLs so that they can use them and precede the rules of the service
+	if len(sr.FrontendExtra) > 0 {
+		tmpl += `{{range .GetFrontendExtraLines}}
+    {{.}}{{end}}`
+	}
+	if sr.AllowMissingHost {
+		tmpl += `
+    http-request set-var(txn.host_exempt) bool(true) if url_{{.ServiceName}}`
+	}
+	// Requests are denied before they could be redirected by httpsOnly
+	if sr.DenyHttp {
+		tmpl += `
+    http-request deny if !{ ssl_fc } url_{{.ServiceName}}{{.AclCondition}}`
+	}
+	// TLS terminated by a load balancer in front of the proxy is detected through the header it sets
+	if sr.RedirectWhenHttpProto || (sr.HttpsOnly && m.CheckXForwardedProto) {
+		tmpl += `
+    redirect scheme https code 301 if { hdr(X-Forwarded-Proto) -i http } url_{{.ServiceName}}{{.AclCondition}}`
+	} else if sr.HttpsOnly {
+		tmpl += `
+    redirect scheme https code 301 if !{ ssl_fc } url_{{.ServiceName}}{{.AclCondition}}`
+	}
+	if len(sr.AllowedSourceIPs) > 0 {
+		tmpl += `
+    http-request deny if url_{{.ServiceName}}{{.AclCondition}} !{ src{{range .AllowedSourceIPs}} {{.}}{{end}} }`
+	}
+	tmpl += m.getMethodsTemplate(sr)
+	tmpl += m.getClientCertTemplate(sr)
+	tmpl += maintenance
+	// The canary rule must precede the regular one in order to win
+	if header := m.getCanaryHeader(sr); header != nil {
+		tmpl += fmt.Sprintf(`
+    use_backend {{.AclName}}-%s-be if url_{{.ServiceName}}{{.AclCondition}} { req.hdr(%s) -m str %s }`,
+			header[1], header[0], header[1])
+	}
+	if m.hasHttpsBackend(sr) {
+		tmpl += `
+    use_backend {{.AclName}}-https-be if url_{{.ServiceName}}{{.AclCondition}} { ssl_fc }`
+	}
+	tmpl += `
+    use_backend {{.AclName}}-be if url_{{.ServiceName}}{{.AclCondition}}`
 	return tmpl
 }
 
+// getRedirectFromDomainTemplate permanently redirects the requests sent to any of the RedirectFromDomain domains to the
+// first domain of the service. The path and the query string are preserved by the prefix redirect.
+func (m *Reconfigure) getRedirectFromDomainTemplate(sr *ServiceReconfigure) string {
+	if len(sr.RedirectFromDomain) == 0 || len(sr.ServiceDomain) == 0 {
+		return ""
+	}
+	tmpl := `
+    acl redirect_{{.ServiceName}} hdr(host) -i{{range .RedirectFromDomain}} {{.}}{{end}}`
+	if sr.HttpsOnly {
+		return tmpl + `
+    redirect prefix https://{{index .ServiceDomain 0}} code 301 if redirect_{{.ServiceName}}`
+	}
+	return tmpl + `
+    redirect prefix http://{{index .ServiceDomain 0}} code 301 if redirect_{{.ServiceName}} !{ ssl_fc }
+    redirect prefix https://{{index .ServiceDomain 0}} code 301 if redirect_{{.ServiceName}} { ssl_fc }`
+}
+
+// getMethodsTemplate denies the requests of the service sent with methods other than AllowedMethods or with any of
+// DeniedMethods.
+func (m *Reconfigure) getMethodsTemplate(sr *ServiceReconfigure) string {
+	if len(sr.AllowedMethods) > 0 {
+		return `
+    http-request deny deny_status 405 if url_{{.ServiceName}}{{.AclCondition}} !{ method{{range .AllowedMethods}} {{.}}{{end}} }`
+	} else if len(sr.DeniedMethods) > 0 {
+		return `
+    http-request deny deny_status 405 if url_{{.ServiceName}}{{.AclCondition}} { method{{range .DeniedMethods}} {{.}}{{end}} }`
+	}
+	return ""
+}
+
+// getClientCertTemplate denies the requests of the service that do not come with a valid client certificate issued by
+// one of the CAs in ClientCertCaFile. Certificates are verified against all the uploaded CAs so the issuer is checked
+// by its common name. All requests are denied if the CA file cannot be read.
+func (m *Reconfigure) getClientCertTemplate(sr *ServiceReconfigure) string {
+	if len(sr.ClientCertVerify) == 0 {
+		return ""
+	}
+	tmpl := ""
+	if strings.EqualFold(sr.ClientCertVerify, "required") {
+		tmpl += `
+    http-request deny deny_status 403 if url_{{.ServiceName}}{{.AclCondition}} !{ ssl_c_used }`
+	}
+	tmpl += `
+    http-request deny deny_status 403 if url_{{.ServiceName}}{{.AclCondition}} { ssl_c_used } !{ ssl_c_verify 0 }`
+	issuers, err := haproxy.GetCaCommonNames(sr.ClientCertCaFile)
+	if err != nil {
+		logPrintf("Could not read the CA file of the service %s\n%s", sr.ServiceName, err.Error())
+		return tmpl + `
+    http-request deny deny_status 403 if url_{{.ServiceName}}{{.AclCondition}}`
+	}
+	for i, issuer := range issuers {
+		issuers[i] = strings.NewReplacer(`\`, `\\`, " ", `\ `, "{", `\{`, "}", `\}`).Replace(issuer)
+	}
+	return tmpl + fmt.Sprintf(`
+    http-request deny deny_status 403 if url_{{.ServiceName}}{{.AclCondition}} { ssl_c_used } !{ ssl_c_i_dn(CN) -m str %s }`,
+		strings.Join(issuers, " "))
+}
+
+// poolOptionsTemplate limits the concurrent connections of each server, further requests wait in the queue of the
+// backend, and the idle connections it keeps for reuse.
+const poolOptionsTemplate = `{{if .MaxConn}} maxconn {{.MaxConn}}{{end}}{{if .PoolMaxConn}} pool-max-conn {{.PoolMaxConn}}{{end}}{{if .PoolPurgeDelay}} pool-purge-delay {{.PoolPurgeDelay}}{{end}}`
+
+// checkOptionsTemplate tunes the interval of the health checks of each server and the number of checks that change
+// its state.
+const checkOptionsTemplate = `{{if .CheckInterval}} inter {{.CheckInterval}}{{end}}{{if .CheckRise}} rise {{.CheckRise}}{{end}}{{if .CheckFall}} fall {{.CheckFall}}{{end}}`
+
+// swarmCheckTemplate checks the servers of the swarm and service modes only when the service asks for a health check.
+const swarmCheckTemplate = `{{if .CheckGrpc}} check check-proto h2{{else if .CheckPath}} check{{end}}`
+
+// swarmCheckOptionsTemplate tunes the checks of swarmCheckTemplate. Servers that are not checked get no options.
+const swarmCheckOptionsTemplate = `{{if or .CheckGrpc .CheckPath}}` + checkOptionsTemplate + `{{end}}`
+
+// consulCheckTemplate checks the servers of the other modes unless the service skips the health check.
+const consulCheckTemplate = `{{if eq .SkipCheck false}} check{{if .CheckGrpc}} check-proto h2{{end}}{{end}}`
+
+// consulCheckOptionsTemplate tunes the checks of consulCheckTemplate.
+const consulCheckOptionsTemplate = `{{if eq .SkipCheck false}}` + checkOptionsTemplate + `{{end}}`
+
+// weightTemplate sets the weight of the servers of the color of the service.
+const weightTemplate = `{{with .GetWeight}} weight {{.}}{{end}}`
+
 func (m *Reconfigure) getBackTemplate(sr *ServiceReconfigure) string {
 	tmpl := ""
 	if len(sr.Users) > 0 {
@@ -369,19 +1136,78 @@ func (m *Reconfigure) getBackTemplate(sr *ServiceReconfigure) string {
 	}
 	tmpl += `backend {{.AclName}}-be
     mode http`
+	if len(sr.Balance) > 0 {
+		tmpl += `
+    balance {{.Balance}}`
+	}
+	tmpl += m.getTimeoutsTemplate(sr)
+	if len(sr.HttpReuse) > 0 {
+		tmpl += `
+    http-reuse {{.HttpReuse}}`
+	}
+	if len(sr.ConnectionMode) > 0 {
+		tmpl += m.getConnectionModeTemplate(sr)
+	}
+	if len(sr.Retries) > 0 {
+		tmpl += `
+    retries {{.Retries}}`
+	}
+	if sr.Redispatch {
+		tmpl += `
+    option redispatch`
+	}
+	if m.isSticky(sr) {
+		tmpl += `
+    cookie {{if .CookieName}}{{.CookieName}}{{else}}{{.AclName}}{{end}} insert indirect nocache`
+	}
+	tmpl += m.getReqRateLimitTemplate(sr)
+	if len(sr.CompressionAlgo) > 0 {
+		tmpl += `
+    compression algo{{range .CompressionAlgo}} {{.}}{{end}}{{if .CompressionType}}
+    compression type{{range .CompressionType}} {{.}}{{end}}{{end}}`
+	}
+	if m.isForwardedProto(sr) {
+		tmpl += `
+    option forwardfor
+    http-request set-header X-Forwarded-Proto https if { ssl_fc }`
+	}
+	if len(sr.DelReqHeader) > 0 || len(sr.SetReqHeader) > 0 || len(sr.AddReqHeader) > 0 {
+		tmpl += `{{range .GetReqHeaderRules}}
+    http-request {{.}}{{end}}`
+	}
+	if len(sr.DelResHeader) > 0 || len(sr.AddResHeader) > 0 {
+		tmpl += `{{range .GetResHeaderRules}}
+    http-response {{.}}{{end}}`
+	}
 	if len(sr.ReqRepSearch) > 0 && len(sr.ReqRepReplace) > 0 {
 		tmpl += `
     reqrep {{.ReqRepSearch}}     {{.ReqRepReplace}}`
 	}
-	if strings.EqualFold(sr.Mode, "service") || strings.EqualFold(sr.Mode, "swarm") {
+	tmpl += m.getCorsTemplate(sr)
+	tmpl += m.getTracingTemplate(sr)
+	if sr.CheckGrpc {
 		tmpl += `
-    server {{.ServiceName}} {{.Host}}:{{.Port}}`
+    option httpchk
+    http-check send meth POST uri /grpc.health.v1.Health/Check hdr content-type application/grpc
+    http-check expect status 200`
+	} else if len(sr.CheckPath) > 0 {
+		tmpl += `
+    option httpchk {{if .CheckMethod}}{{.CheckMethod}}{{else}}GET{{end}} {{.CheckPath}}{{if .HttpCheckExpect}}
+    http-check expect {{.HttpCheckExpect}}{{end}}`
+	}
+	if (strings.EqualFold(sr.Mode, "service") || strings.EqualFold(sr.Mode, "swarm")) && len(sr.DcAddresses) > 0 {
+		tmpl += m.getDcServersTemplate(sr)
+	} else if strings.EqualFold(sr.Mode, "service") || strings.EqualFold(sr.Mode, "swarm") {
+		tmpl += `
+    server {{.ServiceName}} {{.Host}}:{{.Port}}` + swarmCheckTemplate + poolOptionsTemplate + swarmCheckOptionsTemplate + weightTemplate + m.getServerSslTemplate(sr) + m.getServerCookieTemplate(sr, "")
+		tmpl += m.getColorServersTemplate(sr)
 	} else { // It's Consul
 		tmpl += `
     {{"{{"}}range $i, $e := service "{{.FullServiceName}}" "any"{{"}}"}}
-    server {{"{{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}}"}}{{if eq .SkipCheck false}} check{{end}}
+    server {{"{{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}}"}}` + consulCheckTemplate + poolOptionsTemplate + consulCheckOptionsTemplate + weightTemplate + m.getServerCookieTemplate(sr, `_{{"{{$e.Node}}_{{$i}}_{{$e.Port}}"}}`) + `
     {{"{{end}}"}}`
 	}
+	tmpl += m.getBackupServerTemplate(sr)
 	if len(sr.Users) > 0 {
 		tmpl += `
     acl {{.ServiceName}}UsersAcl http_auth({{.ServiceName}}Users)
@@ -390,6 +1216,189 @@ func (m *Reconfigure) getBackTemplate(sr *ServiceReconfigure) string {
 		tmpl += `
     acl defaultUsersAcl http_auth(defaultUsers)
     http-request auth realm defaultRealm if !defaultUsersAcl`
+	}
+	if len(sr.BackendExtra) > 0 {
+		tmpl += `{{range .GetBackendExtraLines}}
+    {{.}}{{end}}`
+	}
+	return tmpl
+}
+
+// GetBackendExtraLines returns the lines of BackendExtra.
+func (sr ServiceReconfigure) GetBackendExtraLines() []string {
+	return splitExtraLines(sr.BackendExtra)
+}
+
+// GetFrontendExtraLines returns the lines of FrontendExtra.
+func (sr ServiceReconfigure) GetFrontendExtraLines() []string {
+	return splitExtraLines(sr.FrontendExtra)
+}
+
+// splitExtraLines returns the non-empty lines of the value. Lines are separated either with new lines or with the \n
+// sequence since labels cannot easily contain new lines. Other control characters are replaced with spaces. The lines
+// are passed to the templates as data, instead of being part of them, so that they are written to the configuration
+// verbatim.
+func splitExtraLines(value string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(strings.Replace(value, `\n`, "\n", -1), "\n") {
+		line = strings.TrimSpace(strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return ' '
+			}
+			return r
+		}, line))
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// getBackupServerTemplate returns the server that receives the requests only when all the other servers of the service
+// are down. It is checked and configured the same way as the other servers.
+func (m *Reconfigure) getBackupServerTemplate(sr *ServiceReconfigure) string {
+	if len(sr.BackupHostname) == 0 {
+		return ""
+	}
+	check, checkOptions := consulCheckTemplate, consulCheckOptionsTemplate
+	if (strings.EqualFold(sr.Mode, "service") || strings.EqualFold(sr.Mode, "swarm")) && len(sr.DcAddresses) == 0 {
+		check, checkOptions = swarmCheckTemplate, swarmCheckOptionsTemplate
+	}
+	return `
+    server backup-{{.ServiceName}} {{.BackupHostname}}:{{if .BackupPort}}{{.BackupPort}}{{else}}{{.Port}}{{end}}` +
+		check + poolOptionsTemplate + checkOptions + m.getServerSslTemplate(sr) + m.getServerCookieTemplate(sr, "_backup") + " backup"
+}
+
+// GetWeight returns the weight of the servers of the color of the service. The weight of the color takes precedence over
+// the weight of the service.
+func (sr ServiceReconfigure) GetWeight() string {
+	if weight := sr.ColorWeights[sr.ServiceColor]; len(sr.ServiceColor) > 0 && len(weight) > 0 {
+		return weight
+	}
+	return sr.Weight
+}
+
+// getColorServersTemplate adds the servers of the other colors that have a weight to the backend so that the requests
+// are split between the colors according to their weights. The servers are reached through the addresses of the colors
+// and their cookies are the ones they get once their color is selected so that sticky clients stay on them.
+func (m *Reconfigure) getColorServersTemplate(sr *ServiceReconfigure) string {
+	colors := []string{}
+	for color := range sr.ColorWeights {
+		if color != sr.ServiceColor && len(sr.ColorAddresses[color]) > 0 {
+			colors = append(colors, color)
+		}
+	}
+	sort.Strings(colors)
+	tmpl := ""
+	for _, color := range colors {
+		cookie := ""
+		if m.isSticky(sr) {
+			cookie = " cookie {{.ServiceName}}-" + color
+		}
+		tmpl += fmt.Sprintf(`
+    server {{.ServiceName}}-%s %s:{{.Port}}`, color, sr.ColorAddresses[color]) +
+			swarmCheckTemplate + poolOptionsTemplate + swarmCheckOptionsTemplate + " weight " + sr.ColorWeights[color] + m.getServerSslTemplate(sr) + cookie
+	}
+	return tmpl
+}
+
+// getReqRateLimitTemplate denies the clients that send more than ReqRateLimit requests within ReqRatePeriod seconds.
+// The stick table belongs to the backend so it is named after it and is removed together with the service.
+func (m *Reconfigure) getReqRateLimitTemplate(sr *ServiceReconfigure) string {
+	if len(sr.ReqRateLimit) == 0 {
+		return ""
+	}
+	period := sr.ReqRatePeriod
+	if period <= 0 {
+		period = 10
+	}
+	return fmt.Sprintf(`
+    stick-table type ip size 100k expire %ds store http_req_rate(%ds)
+    http-request track-sc0 src
+    http-request deny deny_status 429 if { sc_http_req_rate(0) gt {{.ReqRateLimit}} }`,
+		period, period)
+}
+
+// isForwardedProto tells whether the backend sends X-Forwarded-For and X-Forwarded-Proto. The forwardedProto of the
+// service takes precedence over FORWARDED_PROTO.
+func (m *Reconfigure) isForwardedProto(sr *ServiceReconfigure) bool {
+	if len(sr.ForwardedProto) > 0 {
+		return strings.EqualFold(sr.ForwardedProto, "true")
+	}
+	return m.ForwardedProtoDefault
+}
+
+// getDcServersTemplate returns a server for each datacenter. Servers outside LOCAL_DC are backups or, if
+// DC_FAILOVER_MODE is weighted, receive a reduced share of requests. All servers are equal if none is local.
+func (m *Reconfigure) getDcServersTemplate(sr *ServiceReconfigure) string {
+	dcs := []string{}
+	for dc := range sr.DcAddresses {
+		dcs = append(dcs, dc)
+	}
+	sort.Strings(dcs)
+	localDc := os.Getenv("LOCAL_DC")
+	_, hasLocal := sr.DcAddresses[localDc]
+	weighted := strings.EqualFold(os.Getenv("DC_FAILOVER_MODE"), "weighted")
+	tmpl := ""
+	backups := 0
+	for _, dc := range dcs {
+		options := ""
+		switch {
+		case !hasLocal:
+			// There is nothing to prefer so all the datacenters are used equally
+		case weighted && dc == localDc:
+			options = fmt.Sprintf(" weight %d", localDcWeight)
+		case weighted:
+			options = fmt.Sprintf(" weight %d", remoteDcWeight)
+		case dc != localDc:
+			options = " backup"
+			backups++
+		}
+		tmpl += fmt.Sprintf(`
+    server {{.ServiceName}}_%s %s:{{.Port}}%s%s%s%s%s`,
+			dc, sr.DcAddresses[dc], consulCheckTemplate, poolOptionsTemplate+consulCheckOptionsTemplate, m.getServerSslTemplate(sr), m.getServerCookieTemplate(sr, "_"+dc), options)
+	}
+	// Only the first backup is used otherwise
+	if backups > 1 {
+		tmpl = `
+    option allbackups` + tmpl
+	}
+	return tmpl
+}
+
+// getConnectionModeTemplate overrides the connection mode of the defaults section. HAProxy 1.9 dropped forceclose and
+// httpclose behaves the same way since then.
+func (m *Reconfigure) getConnectionModeTemplate(sr *ServiceReconfigure) string {
+	if sr.ConnectionMode == "forceclose" && haproxy.VersionAtLeast(1, 9) {
+		return `
+    option httpclose`
+	}
+	return `
+    option {{.ConnectionMode}}`
+}
+
+// getTimeoutsTemplate overrides the timeouts of the defaults section.
+func (m *Reconfigure) getTimeoutsTemplate(sr *ServiceReconfigure) string {
+	tmpl := ""
+	timeouts := []struct {
+		name    string
+		seconds int
+	}{
+		{"connect", sr.TimeoutConnect},
+		{"queue", sr.TimeoutQueue},
+		{"http-request", sr.TimeoutHttpRequest},
+		{"server", sr.TimeoutServer},
+		{"tunnel", sr.TimeoutTunnel},
+	}
+	for _, t := range timeouts {
+		if t.seconds > 0 {
+			tmpl += fmt.Sprintf(`
+    timeout %s %ds`, t.name, t.seconds)
+		}
+	}
+	if len(sr.CheckTimeout) > 0 {
+		tmpl += `
+    timeout check {{.CheckTimeout}}`
 	}
 	return tmpl
 }
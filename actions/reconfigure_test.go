@@ -14,6 +14,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 type ReconfigureTestSuite struct {
@@ -62,12 +63,16 @@ func (s *ReconfigureTestSuite) SetupTest() {
 		},
 	}
 	s.reconfigure.skipAddressValidation = true
+	writeDomainMapFile = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
 }
 
 // Suite
 
 func TestReconfigureUnitTestSuite(t *testing.T) {
 	logPrintf = func(format string, v ...interface{}) {}
+	removeFeTemplate = func(name string) error { return nil }
 	s := new(ReconfigureTestSuite)
 	s.ServiceName = "myService"
 	s.PutPathResponse = "PUT_PATH_OK"
@@ -130,6 +135,8 @@ func TestReconfigureUnitTestSuite(t *testing.T) {
 	writeBeTemplate = func(filename string, data []byte, perm os.FileMode) error {
 		return nil
 	}
+	writeDomainMapFileOrig := writeDomainMapFile
+	defer func() { writeDomainMapFile = writeDomainMapFileOrig }()
 	mockObj := getProxyMock("")
 	proxyOrig := haproxy.Instance
 	defer func() { haproxy.Instance = proxyOrig }()
@@ -137,157 +144,1608 @@ func TestReconfigureUnitTestSuite(t *testing.T) {
 	suite.Run(t, s)
 }
 
-// GetTemplate
+// GetTemplate
+
+func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFormattedContent() {
+	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, front)
+	s.Equal(s.ConsulTemplateBe, back)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenUsersEnvIsPresent() {
+	usersOrig := os.Getenv("USERS")
+	defer func() { os.Setenv("USERS", usersOrig) }()
+	os.Setenv("USERS", "anything")
+	expected := `backend myService-be
+    mode http
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
+    {{end}}
+    acl defaultUsersAcl http_auth(defaultUsers)
+    http-request auth realm defaultRealm if !defaultUsersAcl`
+
+	_, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, back)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenUsersIsPresent() {
+	s.reconfigure.Users = []User{
+		{Username: "user-1", Password: "pass-1"},
+		{Username: "user-2", Password: "pass-2"},
+	}
+	expected := `userlist myServiceUsers
+    user user-1 insecure-password pass-1
+    user user-2 insecure-password pass-2
+
+backend myService-be
+    mode http
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
+    {{end}}
+    acl myServiceUsersAcl http_auth(myServiceUsers)
+    http-request auth realm myServiceRealm if !myServiceUsersAcl`
+
+	_, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, back)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFormattedContent_WhenModeIsSwarm() {
+	modes := []string{"service", "sWARm"}
+	for _, mode := range modes {
+		s.reconfigure.ServiceReconfigure.Mode = mode
+		s.reconfigure.ServiceReconfigure.Port = "1234"
+		expected := `backend myService-be
+    mode http
+    server myService myService:1234`
+
+		_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+		s.Equal(expected, actual)
+	}
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesServiceAddress_WhenModeIsSwarm() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.OutboundHostname = "machine-123.my-company.com"
+	s.reconfigure.ServiceAddress = "10.0.0.1"
+	expected := `backend myService-be
+    mode http
+    server myService 10.0.0.1:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesAddressOfTheServiceColor_WhenModeIsSwarm() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.OutboundHostname = "machine-123.my-company.com"
+	s.reconfigure.ServiceAddress = "10.0.0.1"
+	s.reconfigure.ColorAddresses = map[string]string{"blue": "10.0.0.2", "green": "10.0.0.3"}
+	for color, address := range s.reconfigure.ColorAddresses {
+		s.reconfigure.ServiceColor = color
+		expected := fmt.Sprintf(`backend myService-be
+    mode http
+    server myService %s:1234`, address)
+
+		_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+		s.Equal(expected, actual)
+	}
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsRetriesAndRedispatch_WhenPresent() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.Retries = "3"
+	s.reconfigure.Redispatch = true
+	expected := `backend myService-be
+    mode http
+    retries 3
+    option redispatch
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsMaxConn_WhenMaxConnIsPresent() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.MaxConn = "50"
+	s.reconfigure.TimeoutQueue = 30
+	expected := `backend myService-be
+    mode http
+    timeout queue 30s
+    server myService myService:1234 maxconn 50`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsWeight_WhenWeightIsPresent() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.Weight = "50"
+	expected := `backend myService-be
+    mode http
+    server myService myService:1234 weight 50`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_SplitsRequestsBetweenColors_WhenColorWeightsArePresent() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.ServiceColor = "blue"
+	s.reconfigure.SessionType = "sticky-server"
+	s.reconfigure.Weight = "50"
+	s.reconfigure.ColorAddresses = map[string]string{"blue": "10.0.0.2", "green": "10.0.0.3", "pink": "10.0.0.4"}
+	s.reconfigure.ColorWeights = map[string]string{"blue": "90", "green": "10"}
+	expected := `backend myService-be
+    mode http
+    cookie myService insert indirect nocache
+    server myService 10.0.0.2:1234 weight 90 cookie myService-blue
+    server myService-green 10.0.0.3:1234 weight 10 cookie myService-green`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCorsRules_WhenCorsOriginsIsPresent() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "2.2")
+	s.reconfigure.CorsOrigins = []string{"https://my-domain.com", "https://other-domain.com"}
+	s.reconfigure.CorsMethods = []string{"GET", "POST"}
+	s.reconfigure.CorsHeaders = []string{"Content-Type", "Authorization"}
+	expected := `backend myService-be
+    mode http
+    http-request set-var(txn.cors_origin) req.hdr(Origin)
+    acl cors_origin_myService var(txn.cors_origin) -m str https://my-domain.com https://other-domain.com
+    http-request return status 204 hdr Access-Control-Allow-Origin "%[var(txn.cors_origin)]" hdr Access-Control-Allow-Methods "GET, POST" hdr Access-Control-Allow-Headers "Content-Type, Authorization" if METH_OPTIONS cors_origin_myService
+    http-response set-header Access-Control-Allow-Origin "%[var(txn.cors_origin)]" if cors_origin_myService
+    http-response add-header Vary Origin
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
+    {{end}}`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCorsRulesForAnyOrigin_WhenCorsOriginsIsAsterisk() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "2.4")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.CorsOrigins = []string{"*"}
+	expected := `backend myService-be
+    mode http
+    http-request return status 204 hdr Access-Control-Allow-Origin "*" if METH_OPTIONS
+    http-response set-header Access-Control-Allow-Origin "*"
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsError_WhenCorsOriginsIsPresentAndHaProxyIsOlderThan22() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "1.6")
+	s.reconfigure.CorsOrigins = []string{"*"}
+
+	_, _, err := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Error(err)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsGrpcCheck_WhenModeIsSwarmAndCheckGrpcIsTrue() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "2.2")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.CheckGrpc = true
+	expected := `backend myService-be
+    mode http
+    option httpchk
+    http-check send meth POST uri /grpc.health.v1.Health/Check hdr content-type application/grpc
+    http-check expect status 200
+    server myService myService:1234 check check-proto h2`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsGrpcCheck_WhenCheckGrpcIsTrue() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "2.4")
+	s.reconfigure.CheckGrpc = true
+	expected := `backend myService-be
+    mode http
+    option httpchk
+    http-check send meth POST uri /grpc.health.v1.Health/Check hdr content-type application/grpc
+    http-check expect status 200
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check check-proto h2
+    {{end}}`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsError_WhenCheckGrpcIsTrueAndHaProxyIsOlderThan22() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "1.6")
+	s.reconfigure.CheckGrpc = true
+
+	_, _, err := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.EqualError(err, "gRPC health checking requires HAProxy 2.2 or newer. The detected version is 1.6")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsConnectionReuse_WhenModeIsSwarm() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "2.0")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.HttpReuse = "always"
+	s.reconfigure.PoolMaxConn = "100"
+	s.reconfigure.PoolPurgeDelay = "5s"
+	expected := `backend myService-be
+    mode http
+    http-reuse always
+    server myService myService:1234 pool-max-conn 100 pool-purge-delay 5s`
+
+	_, actual, err := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.NoError(err)
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsConnectionReuse() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "1.9")
+	s.reconfigure.HttpReuse = "safe"
+	s.reconfigure.PoolMaxConn = "0"
+	expected := `backend myService-be
+    mode http
+    http-reuse safe
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check pool-max-conn 0
+    {{end}}`
+
+	_, actual, err := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.NoError(err)
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpReuse_WhenHaProxyIsOlderThan19() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "1.8")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.HttpReuse = "never"
+
+	_, actual, err := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.NoError(err)
+	s.Contains(actual, "\n    http-reuse never\n    server myService myService:1234")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsBalance() {
+	s.reconfigure.Balance = "hdr(X-User)"
+	s.reconfigure.TimeoutServer = 60
+	expected := `backend myService-be
+    mode http
+    balance hdr(X-User)
+    timeout server 60s
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
+    {{end}}`
+
+	_, actual, err := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.NoError(err)
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsConnectionMode() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "1.8")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	for _, mode := range []string{"http-keep-alive", "http-server-close", "httpclose", "forceclose"} {
+		s.reconfigure.ConnectionMode = mode
+
+		_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+		s.Contains(actual, "\n    mode http\n    option "+mode+"\n    server myService myService:1234", mode)
+	}
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesHttpClose_WhenConnectionModeIsForceCloseAndHaProxyIs19OrNewer() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "1.9")
+	s.reconfigure.ConnectionMode = "forceclose"
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Contains(actual, "\n    option httpclose\n")
+	s.NotContains(actual, "forceclose")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCookies_WhenSessionTypeIsStickyServer() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.ServiceColor = "blue"
+	s.reconfigure.SessionType = "sticky-server"
+	expected := `backend myService-be
+    mode http
+    cookie myService insert indirect nocache
+    server myService myService:1234 cookie myService-blue`
+
+	_, actual, err := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.NoError(err)
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesCookieName() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.SessionType = "sticky-server"
+	s.reconfigure.CookieName = "MY_SESSION"
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Contains(actual, "\n    cookie MY_SESSION insert indirect nocache\n")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCookiesToConsulServers_WhenSessionTypeIsStickyServer() {
+	s.reconfigure.SessionType = "sticky-server"
+	expected := `backend myService-be
+    mode http
+    cookie myService insert indirect nocache
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check cookie myService_{{$e.Node}}_{{$i}}_{{$e.Port}}
+    {{end}}`
+
+	_, actual, err := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.NoError(err)
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCookiesToDcServers_WhenSessionTypeIsStickyServer() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.SkipCheck = true
+	s.reconfigure.SessionType = "sticky-server"
+	s.reconfigure.DcAddresses = map[string]string{"west": "10.1.0.1", "north": "10.2.0.1"}
+	expected := `backend myService-be
+    mode http
+    cookie myService insert indirect nocache
+    server myService_north 10.2.0.1:1234 cookie myService_north
+    server myService_west 10.1.0.1:1234 cookie myService_west`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsError_WhenPoolIsSetAndHaProxyIsOlderThan19() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "1.8")
+	for _, sr := range []ServiceReconfigure{
+		{ServiceName: "myService", PoolMaxConn: "10"},
+		{ServiceName: "myService", PoolPurgeDelay: "5s"},
+	} {
+		_, _, err := s.reconfigure.GetTemplates(sr)
+
+		s.EqualError(err, "Connection pooling requires HAProxy 1.9 or newer. The detected version is 1.8")
+	}
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsB3Sampling_WhenTracingSampleRateIsPresent() {
+	defer func() { os.Unsetenv("TRACING_HEADERS") }()
+	os.Setenv("TRACING_HEADERS", "b3")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.TracingSampleRate = "0.25"
+	expected := `backend myService-be
+    mode http
+    http-request set-header X-B3-Sampled 0 if { var(txn.trace_started) -m bool } !{ rand(1000) lt 250 }
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsW3cSampling_WhenTracingSampleRateIsPresent() {
+	defer func() { os.Unsetenv("TRACING_HEADERS") }()
+	os.Setenv("TRACING_HEADERS", "w3c")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.TracingSampleRate = "0"
+	expected := `backend myService-be
+    mode http
+    http-request replace-header traceparent ^(.*)-01$ \1-00 if { var(txn.trace_started) -m bool } !{ rand(1000) lt 0 }
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotAddSampling_WhenTracingHeadersIsNotSet() {
+	os.Unsetenv("TRACING_HEADERS")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.TracingSampleRate = "0.25"
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.NotContains(actual, "http-request")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsTimeouts_WhenPresent() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.TimeoutConnect = 3
+	s.reconfigure.TimeoutQueue = 10
+	s.reconfigure.TimeoutHttpRequest = 5
+	s.reconfigure.TimeoutServer = 90
+	s.reconfigure.TimeoutTunnel = 3600
+	expected := `backend myService-be
+    mode http
+    timeout connect 3s
+    timeout queue 10s
+    timeout http-request 5s
+    timeout server 90s
+    timeout tunnel 3600s
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_Execute_StoresService() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.TimeoutServer = 90
+	writeFeTemplateOrig := writeFeTemplate
+	defer func() { writeFeTemplate = writeFeTemplateOrig }()
+	writeFeTemplate = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+	configuredServicesOrig := configuredServices
+	defer func() { configuredServices = configuredServicesOrig }()
+	configuredServices = map[string]ServiceReconfigure{}
+
+	s.reconfigure.Execute([]string{})
+
+	actual := GetServices()
+	s.Len(actual, 1)
+	s.Equal(s.ServiceName, actual[0].ServiceName)
+	s.Equal(90, actual[0].TimeoutServer)
+
+	RemoveKnownService(s.ServiceName)
+
+	s.Empty(GetServices())
+}
+
+func (s ReconfigureTestSuite) Test_Execute_RemovesConfigs_WhenNewServiceExceedsLimits() {
+	s.reconfigure.Mode = "swarm"
+	configuredServicesOrig := configuredServices
+	removeFeTemplateOrig := removeFeTemplate
+	removeBeTemplateOrig := removeBeTemplate
+	proxyOrig := haproxy.Instance
+	defer func() {
+		configuredServices = configuredServicesOrig
+		removeFeTemplate = removeFeTemplateOrig
+		removeBeTemplate = removeBeTemplateOrig
+		haproxy.Instance = proxyOrig
+	}()
+	configuredServices = map[string]ServiceReconfigure{}
+	removed := []string{}
+	removeFeTemplate = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+	removeBeTemplate = removeFeTemplate
+	mockObj := getProxyMock("CreateConfigFromTemplates")
+	mockObj.On("CreateConfigFromTemplates").Return(&haproxy.ConfigLimitError{Limit: "MAX_SERVICES", Subject: "services", Value: 2, Max: 1})
+	haproxy.Instance = mockObj
+
+	err := s.reconfigure.Execute([]string{})
+
+	s.IsType(&haproxy.ConfigLimitError{}, err)
+	mockObj.AssertNotCalled(s.T(), "Reload")
+	s.Contains(removed, s.TemplatesPath+"/"+s.ServiceName+"-fe.cfg")
+	s.Contains(removed, s.TemplatesPath+"/"+s.ServiceName+"-be.cfg")
+	s.Empty(GetServices())
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCanaryBackend_WhenModeIsSwarmAndCanaryHeaderIsPresent() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.ServiceColor = "blue"
+	s.reconfigure.ColorAddresses = map[string]string{"blue": "10.0.0.1", "green": "10.0.0.2"}
+	s.reconfigure.CanaryHeader = "X-Canary:green"
+	expectedFront := `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    use_backend myService-green-be if url_myService { req.hdr(X-Canary) -m str green }
+    use_backend myService-be if url_myService`
+	expectedBack := `backend myService-be
+    mode http
+    server myService 10.0.0.1:1234
+
+backend myService-green-be
+    mode http
+    server myService-green 10.0.0.2:1234`
+
+	actualFront, actualBack, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedFront, actualFront)
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCanaryBackend_WhenCanaryHeaderIsPresent() {
+	s.reconfigure.ServiceColor = "blue"
+	s.reconfigure.ServiceDomain = []string{"my-domain.com"}
+	s.reconfigure.CanaryHeader = "X-Canary:green"
+	expectedFront := `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_dom(host) -i my-domain.com
+    use_backend myService-green-be if url_myService domain_myService { req.hdr(X-Canary) -m str green }
+    use_backend myService-be if url_myService domain_myService`
+	expectedBack := `backend myService-be
+    mode http
+    {{range $i, $e := service "myService-blue" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
+    {{end}}
+
+backend myService-green-be
+    mode http
+    {{range $i, $e := service "myService-green" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
+    {{end}}`
+
+	actualFront, actualBack, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedFront, actualFront)
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsRemoteDcServersAsBackups_WhenLocalDcIsPresent() {
+	defer func() { os.Unsetenv("LOCAL_DC") }()
+	os.Setenv("LOCAL_DC", "east")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.DcAddresses = map[string]string{"west": "10.1.0.1", "east": "10.0.0.1", "north": "10.2.0.1"}
+	expectedBack := `backend myService-be
+    mode http
+    option allbackups
+    server myService_east 10.0.0.1:1234 check
+    server myService_north 10.2.0.1:1234 check backup
+    server myService_west 10.1.0.1:1234 check backup`
+
+	_, actualBack, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsRemoteDcServersWithReducedWeight_WhenDcFailoverModeIsWeighted() {
+	defer func() {
+		os.Unsetenv("LOCAL_DC")
+		os.Unsetenv("DC_FAILOVER_MODE")
+	}()
+	os.Setenv("LOCAL_DC", "east")
+	os.Setenv("DC_FAILOVER_MODE", "weighted")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.DcAddresses = map[string]string{"west": "10.1.0.1", "east": "10.0.0.1"}
+	expectedBack := `backend myService-be
+    mode http
+    server myService_east 10.0.0.1:1234 check weight 100
+    server myService_west 10.1.0.1:1234 check weight 10`
+
+	_, actualBack, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesRemoteDcServersEqually_WhenThereAreNoLocalServers() {
+	defer func() { os.Unsetenv("LOCAL_DC") }()
+	os.Setenv("LOCAL_DC", "east")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.SkipCheck = true
+	s.reconfigure.DcAddresses = map[string]string{"west": "10.1.0.1", "north": "10.2.0.1"}
+	expectedBack := `backend myService-be
+    mode http
+    server myService_north 10.2.0.1:1234
+    server myService_west 10.1.0.1:1234`
+
+	_, actualBack, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedBack, actualBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotAddCanaryBackend_WhenCanaryColorIsTheServiceColor() {
+	s.reconfigure.ServiceColor = "green"
+	s.reconfigure.CanaryHeader = "X-Canary:green"
+
+	actualFront, actualBack, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.NotContains(actualFront, "X-Canary")
+	s.NotContains(actualBack, "myService-green-be")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenModeIsSwarmAndUsersEnvIsPresent() {
+	usersOrig := os.Getenv("USERS")
+	defer func() { os.Setenv("USERS", usersOrig) }()
+	os.Setenv("USERS", "anything")
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	expected := `backend myService-be
+    mode http
+    server myService myService:1234
+    acl defaultUsersAcl http_auth(defaultUsers)
+    http-request auth realm defaultRealm if !defaultUsersAcl`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenModeIsSwarmAndUsersIsPresent() {
+	s.reconfigure.Users = []User{
+		{Username: "user-1", Password: "pass-1"},
+		{Username: "user-2", Password: "pass-2"},
+	}
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	expected := `userlist myServiceUsers
+    user user-1 insecure-password pass-1
+    user user-2 insecure-password pass-2
+
+backend myService-be
+    mode http
+    server myService myService:1234
+    acl myServiceUsersAcl http_auth(myServiceUsers)
+    http-request auth realm myServiceRealm if !myServiceUsersAcl`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHosts() {
+	s.ConsulTemplateFe = `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_dom(host) -i my-domain.com my-other-domain.com
+    use_backend myService-be if url_myService domain_myService`
+	s.reconfigure.ServiceDomain = []string{"my-domain.com", "my-other-domain.com"}
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHostsStartingWithWildcard() {
+	s.ConsulTemplateFe = `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_end(host) -i acme.com .domain.com
+    use_backend myService-be if url_myService domain_myService`
+	s.reconfigure.ServiceDomain = []string{"acme.com", "*.domain.com"}
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHostsAndTheirSubdomains_WhenServiceDomainMatchAllIsTrue() {
+	s.ConsulTemplateFe = `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService req.hdr(host),field(1,:) -m dom -i app.example.com domain.com
+    acl domain_myService req.hdr(host),field(1,:) -m end -i .app.example.com .domain.com
+    use_backend myService-be if url_myService domain_myService`
+	s.reconfigure.ServiceDomain = []string{"app.example.com", "*.domain.com"}
+	s.reconfigure.ServiceDomainMatchAll = true
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_MatchesHostsSentWithPort_WhenServiceDomainMatchAllIsTrue() {
+	s.reconfigure.ServiceDomain = []string{"app.example.com"}
+	s.reconfigure.ServiceDomainMatchAll = true
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	// The port of a Host header such as app.example.com:8080 is stripped before both domain ACLs are evaluated
+	for _, line := range strings.Split(actual, "\n") {
+		if strings.Contains(line, "acl domain_myService") {
+			s.Contains(line, "req.hdr(host),field(1,:)")
+			s.NotContains(line, " hdr(host)")
+			s.NotContains(line, "hdr_end(host)")
+		}
+	}
+	s.Contains(actual, "acl domain_myService req.hdr(host),field(1,:) -m dom -i app.example.com")
+	s.Contains(actual, "acl domain_myService req.hdr(host),field(1,:) -m end -i .app.example.com")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsRedirects_WhenRedirectFromDomainIsPresent() {
+	s.ConsulTemplateFe = `
+    acl redirect_myService hdr(host) -i old-brand.com www.old-brand.com
+    redirect prefix http://new-brand.com code 301 if redirect_myService !{ ssl_fc }
+    redirect prefix https://new-brand.com code 301 if redirect_myService { ssl_fc }
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_dom(host) -i new-brand.com www.new-brand.com
+    use_backend myService-be if url_myService domain_myService`
+	s.reconfigure.ServiceDomain = []string{"new-brand.com", "www.new-brand.com"}
+	s.reconfigure.RedirectFromDomain = []string{"old-brand.com", "www.old-brand.com"}
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpsRedirect_WhenRedirectFromDomainIsPresentAndHttpsOnlyIsTrue() {
+	s.reconfigure.ServiceDomain = []string{"new-brand.com"}
+	s.reconfigure.RedirectFromDomain = []string{"old-brand.com"}
+	s.reconfigure.HttpsOnly = true
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.True(strings.HasPrefix(actual, `
+    acl redirect_myService hdr(host) -i old-brand.com
+    redirect prefix https://new-brand.com code 301 if redirect_myService
+    acl url_myService`))
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DeniesHttpRequests_WhenDenyHttpIsTrue() {
+	s.ConsulTemplateFe = `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_dom(host) -i my-domain.com
+    http-request deny if !{ ssl_fc } url_myService domain_myService
+    use_backend myService-be if url_myService domain_myService`
+	s.reconfigure.ServiceDomain = []string{"my-domain.com"}
+	s.reconfigure.DenyHttp = true
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DeniesOtherMethods_WhenAllowedMethodsArePresent() {
+	s.ConsulTemplateFe = `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_dom(host) -i my-domain.com
+    http-request deny deny_status 405 if url_myService domain_myService !{ method GET POST }
+    use_backend myService-be if url_myService domain_myService`
+	s.reconfigure.ServiceDomain = []string{"my-domain.com"}
+	s.reconfigure.AllowedMethods = []string{"GET", "POST"}
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DeniesMethods_WhenDeniedMethodsArePresent() {
+	s.ConsulTemplateFe = `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    http-request deny deny_status 405 if url_myService { method PUT DELETE }
+    use_backend myService-be if url_myService`
+	s.reconfigure.DeniedMethods = []string{"PUT", "DELETE"}
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DeniesOtherSources_WhenAllowedSourceIPsArePresent() {
+	s.ConsulTemplateFe = `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_dom(host) -i my-domain.com
+    http-request deny if url_myService domain_myService !{ src 10.0.0.0/8 192.168.1.10 }
+    use_backend myService-be if url_myService domain_myService`
+	s.reconfigure.ServiceDomain = []string{"my-domain.com"}
+	s.reconfigure.AllowedSourceIPs = []string{"10.0.0.0/8", "192.168.1.10"}
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHeaderRules_WhenReqHeadersArePresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.AddReqHeader = []string{"X-Forwarded-Prefix /api", "X-Trace:%[unique-id]"}
+	s.reconfigure.SetReqHeader = []string{"Accept text/html, application/json"}
+	s.reconfigure.DelReqHeader = []string{"X-Internal-Auth"}
+	expected := `backend myService-be
+    mode http
+    http-request del-header X-Internal-Auth
+    http-request set-header Accept text/html,\ application/json
+    http-request add-header X-Forwarded-Prefix /api
+    http-request add-header X-Trace %%[unique-id]
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_EscapesPercentSignsOfHeaderValues() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.AddReqHeader = []string{"X-Discount 100%"}
+	s.reconfigure.AddResHeader = []string{"X-Progress:50% done"}
+	expected := `backend myService-be
+    mode http
+    http-request add-header X-Discount 100%%
+    http-response add-header X-Progress 50%%\ done
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsResponseHeaderRules_WhenResHeadersArePresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.AddReqHeader = []string{"X-Forwarded-Prefix /api"}
+	s.reconfigure.AddResHeader = []string{"Cache-Control:max-age=3600, public"}
+	s.reconfigure.DelResHeader = []string{"Server", "X-Powered-By"}
+	s.reconfigure.ReqRepSearch = "this"
+	s.reconfigure.ReqRepReplace = "that"
+	expected := `backend myService-be
+    mode http
+    http-request add-header X-Forwarded-Prefix /api
+    http-response del-header Server
+    http-response del-header X-Powered-By
+    http-response add-header Cache-Control max-age=3600,\ public
+    reqrep this     that
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsForwardedHeaders_WhenForwardedProtoIsTrue() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.ForwardedProto = "true"
+	s.reconfigure.SetReqHeader = []string{"X-Forwarded-Port 443"}
+	expected := `backend myService-be
+    mode http
+    option forwardfor
+    http-request set-header X-Forwarded-Proto https if { ssl_fc }
+    http-request set-header X-Forwarded-Port 443
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesForwardedProtoOverFORWARDED_PROTO() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	for _, c := range []struct {
+		forwardedProtoDefault bool
+		forwardedProto        string
+		expected              bool
+	}{
+		{false, "", false},
+		{true, "", true},
+		{true, "false", false},
+		{false, "true", true},
+		{false, "TRUE", true},
+	} {
+		s.reconfigure.ForwardedProtoDefault = c.forwardedProtoDefault
+		s.reconfigure.ForwardedProto = c.forwardedProto
+
+		_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+		s.Equal(c.expected, strings.Contains(actual, `
+    option forwardfor
+    http-request set-header X-Forwarded-Proto https if { ssl_fc }`), c)
+	}
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCompression_WhenCompressionAlgoIsPresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.CompressionAlgo = []string{"gzip"}
+	s.reconfigure.CompressionType = []string{"application/json", "text/plain"}
+	expected := `backend myService-be
+    mode http
+    compression algo gzip
+    compression type application/json text/plain
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+	s.reconfigure.CompressionType = nil
+	_, actual, _ = s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+	s.Equal(`backend myService-be
+    mode http
+    compression algo gzip
+    server myService myService:1234`, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsStickTable_WhenReqRateLimitIsPresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.ReqRateLimit = "100"
+	s.reconfigure.ReqRatePeriod = 60
+	s.reconfigure.CompressionAlgo = []string{"gzip"}
+	expected := `backend myService-be
+    mode http
+    stick-table type ip size 100k expire 60s store http_req_rate(60s)
+    http-request track-sc0 src
+    http-request deny deny_status 429 if { sc_http_req_rate(0) gt 100 }
+    compression algo gzip
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesPeriodOfTenSeconds_WhenReqRatePeriodIsNotPresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.ReqRateLimit = "20"
+	s.reconfigure.AclName = "my-acl"
+	expected := `backend my-acl-be
+    mode http
+    stick-table type ip size 100k expire 10s store http_req_rate(10s)
+    http-request track-sc0 src
+    http-request deny deny_status 429 if { sc_http_req_rate(0) gt 20 }
+    server myService myService:1234`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AppendsBackendExtra_WhenBackendExtraIsPresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.Users = []User{{Username: "user", Password: "pass"}}
+	s.reconfigure.BackendExtra = "option redispatch\nretries 3\n\n  http-request set-var(txn.braces) str({{x}})  "
+	expected := `userlist myServiceUsers
+    user user insecure-password pass
+
+backend myService-be
+    mode http
+    server myService myService:1234
+    acl myServiceUsersAcl http_auth(myServiceUsers)
+    http-request auth realm myServiceRealm if !myServiceUsersAcl
+    option redispatch
+    retries 3
+    http-request set-var(txn.braces) str({{x}})`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsFrontendExtraAfterAcls_WhenFrontendExtraIsPresent() {
+	s.ConsulTemplateFe = `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_dom(host) -i my-domain.com
+    acl blocked_myService path_beg /api/internal
+    http-request deny if blocked_myService !{ hdr(x-token) -m str "a&b" }
+    redirect scheme https code 301 if !{ ssl_fc } url_myService domain_myService
+    use_backend myService-be if url_myService domain_myService`
+	s.reconfigure.ServiceDomain = []string{"my-domain.com"}
+	s.reconfigure.HttpsOnly = true
+	s.reconfigure.FrontendExtra = "acl blocked_myService path_beg /api/internal\nhttp-request deny if blocked_myService !{ hdr(x-token) -m str \"a&b\" }"
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetBackendExtraLines_SplitsLinesOnEscapedNewLines() {
+	sr := ServiceReconfigure{BackendExtra: `option redispatch\nretries 3`}
+
+	s.Equal([]string{"option redispatch", "retries 3"}, sr.GetBackendExtraLines())
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpCheck_WhenCheckPathIsPresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.CheckPath = "/healthz"
+	expected := `backend myService-be
+    mode http
+    option httpchk GET /healthz
+    server myService myService:1234 check`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesCheckMethod_WhenCheckMethodIsPresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.CheckPath = "/healthz"
+	s.reconfigure.CheckMethod = "HEAD"
+	expected := `backend myService-be
+    mode http
+    option httpchk HEAD /healthz
+    server myService myService:1234 check`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpCheckExpect_WhenHttpCheckExpectIsPresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.CheckPath = "/status"
+	s.reconfigure.HttpCheckExpect = "string READY"
+	expected := `backend myService-be
+    mode http
+    option httpchk GET /status
+    http-check expect string READY
+    server myService myService:1234 check`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpCheckExpect_WhenHttpCheckExpectIsARegularExpression() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.CheckPath = "/status"
+	s.reconfigure.HttpCheckExpect = "rstatus ^2.."
+	expected := `backend myService-be
+    mode http
+    option httpchk GET /status
+    http-check expect rstatus ^2..
+    server myService myService:1234 check`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCheckTuning_WhenCheckTuningIsPresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.CheckPath = "/healthz"
+	s.reconfigure.CheckInterval = "5s"
+	s.reconfigure.CheckRise = "2"
+	s.reconfigure.CheckFall = "3"
+	s.reconfigure.CheckTimeout = "2000"
+	expected := `backend myService-be
+    mode http
+    timeout check 2000
+    option httpchk GET /healthz
+    server myService myService:1234 check inter 5s rise 2 fall 3`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotAddCheckTuning_WhenServersAreNotChecked() {
+	s.reconfigure.CheckInterval = "5s"
+	s.reconfigure.CheckRise = "2"
+	s.reconfigure.CheckFall = "3"
+	s.reconfigure.SkipCheck = true
+	expected := `backend myService-be
+    mode http
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}}
+    {{end}}`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+
+	s.reconfigure.SkipCheck = false
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	expected = `backend myService-be
+    mode http
+    server myService myService:1234`
+
+	_, actual, _ = s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsBackupServer_WhenBackupHostnameIsPresent() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "1234"
+	s.reconfigure.HttpsPort = "1234"
+	s.reconfigure.CheckPath = "/healthz"
+	s.reconfigure.CheckInterval = "5s"
+	s.reconfigure.BackupHostname = "static.example.com"
+	expected := `backend myService-be
+    mode http
+    option httpchk GET /healthz
+    server myService myService:1234 check inter 5s ssl verify none
+    server backup-myService static.example.com:1234 check inter 5s ssl verify none backup`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsBackupServerWithCheck_WhenModeIsNotSwarm() {
+	s.reconfigure.BackupHostname = "10.0.0.1"
+	s.reconfigure.BackupPort = "8080"
+	expected := `backend myService-be
+    mode http
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
+    {{end}}
+    server backup-myService 10.0.0.1:8080 check backup`
+
+	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsReqRep_WhenReqRepSearchAndReqRepReplaceArePresent() {
+	s.reconfigure.ReqRepSearch = "this"
+	s.reconfigure.ReqRepReplace = "that"
+	expected := fmt.Sprintf(`backend myService-be
+    mode http
+    reqrep %s     %s
+    {{range $i, $e := service "%s" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
+    {{end}}`,
+		s.reconfigure.ReqRepSearch,
+		s.reconfigure.ReqRepReplace,
+		s.reconfigure.ServiceName,
+	)
+
+	_, backend, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, backend)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesAclNameForFrontEnd() {
+	s.reconfigure.AclName = "my-acl"
+	s.ConsulTemplateFe = `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    use_backend my-acl-be if url_myService`
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_ExemptsServiceFromHostHeaderCheck_WhenAllowMissingHostIsTrue() {
+	s.reconfigure.AllowMissingHost = true
+	expected := `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    http-request set-var(txn.host_exempt) bool(true) if url_myService
+    use_backend myService-be if url_myService`
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_RedirectsHttpRequestsToHttps_WhenHttpsOnlyIsTrue() {
+	s.reconfigure.HttpsOnly = true
+	s.reconfigure.ServiceDomain = []string{"my-domain.com"}
+	expected := `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_dom(host) -i my-domain.com
+    redirect scheme https code 301 if !{ ssl_fc } url_myService domain_myService
+    use_backend myService-be if url_myService domain_myService`
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_RedirectsByXForwardedProto_WhenRedirectWhenHttpProtoIsTrue() {
+	s.reconfigure.RedirectWhenHttpProto = true
+	s.reconfigure.ServiceDomain = []string{"my-domain.com"}
+	expected := `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_dom(host) -i my-domain.com
+    redirect scheme https code 301 if { hdr(X-Forwarded-Proto) -i http } url_myService domain_myService
+    use_backend myService-be if url_myService domain_myService`
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_RedirectsHttpsOnlyByXForwardedProto_WhenCheckXForwardedProtoIsTrue() {
+	s.reconfigure.CheckXForwardedProto = true
+	s.reconfigure.HttpsOnly = true
+	expected := `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    redirect scheme https code 301 if { hdr(X-Forwarded-Proto) -i http } url_myService
+    use_backend myService-be if url_myService`
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesHttpsPort_WhenPortIsNotSet() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = ""
+	s.reconfigure.HttpsPort = "8443"
+	expectedBack := `backend myService-be
+    mode http
+    server myService myService:8443 ssl verify none`
+
+	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, front)
+	s.Equal(expectedBack, back)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_RoutesHttpsRequestsToHttpsPort_WhenPortIsSet() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "8080"
+	s.reconfigure.HttpsPort = "8443"
+	expectedFront := `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    use_backend myService-https-be if url_myService { ssl_fc }
+    use_backend myService-be if url_myService`
+	expectedBack := `backend myService-be
+    mode http
+    server myService myService:8080
+
+backend myService-https-be
+    mode http
+    server myService-https myService:8443 ssl verify none`
+
+	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedFront, front)
+	s.Equal(expectedBack, back)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_ConnectsThroughTls_WhenSslVerifyNoneIsTrue() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "8080"
+	s.reconfigure.SslVerifyNone = true
+	expectedBack := `backend myService-be
+    mode http
+    server myService myService:8080 ssl verify none`
+
+	_, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedBack, back)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_VerifiesServersAgainstSslCaCert_WhenSslCaCertIsSet() {
+	caCertsDirOrig := haproxy.CaCertsDir
+	defer func() { haproxy.CaCertsDir = caCertsDirOrig }()
+	haproxy.CaCertsDir = "/certs/ca"
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "8080"
+	s.reconfigure.HttpsPort = "8443"
+	s.reconfigure.SslCaCert = "my-ca.pem"
+	expectedBack := `backend myService-be
+    mode http
+    server myService myService:8080 ssl verify required ca-file /certs/ca/my-ca.pem
+
+backend myService-https-be
+    mode http
+    server myService-https myService:8443 ssl verify required ca-file /certs/ca/my-ca.pem`
+
+	_, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedBack, back)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DeniesRequestsWithoutValidClientCert_WhenClientCertVerifyIsRequired() {
+	caCertsDirOrig := haproxy.CaCertsDir
+	defer func() { haproxy.CaCertsDir = caCertsDirOrig }()
+	haproxy.CaCertsDir = "../proxy/test_configs/ca"
+	s.reconfigure.ClientCertVerify = "required"
+	s.reconfigure.ClientCertCaFile = "my-ca.pem"
+	expected := `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    http-request deny deny_status 403 if url_myService !{ ssl_c_used }
+    http-request deny deny_status 403 if url_myService { ssl_c_used } !{ ssl_c_verify 0 }
+    http-request deny deny_status 403 if url_myService { ssl_c_used } !{ ssl_c_i_dn(CN) -m str My\ Root\ CA }
+    use_backend myService-be if url_myService`
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AllowsRequestsWithoutClientCert_WhenClientCertVerifyIsOptional() {
+	caCertsDirOrig := haproxy.CaCertsDir
+	defer func() { haproxy.CaCertsDir = caCertsDirOrig }()
+	haproxy.CaCertsDir = "../proxy/test_configs/ca"
+	s.reconfigure.ClientCertVerify = "optional"
+	s.reconfigure.ClientCertCaFile = "my-ca.pem"
+
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.NotContains(actual, "!{ ssl_c_used }")
+	s.Contains(actual, "http-request deny deny_status 403 if url_myService { ssl_c_used } !{ ssl_c_verify 0 }")
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DeniesAllRequests_WhenClientCertCaFileCannotBeRead() {
+	caCertsDirOrig := haproxy.CaCertsDir
+	defer func() { haproxy.CaCertsDir = caCertsDirOrig }()
+	haproxy.CaCertsDir = "../proxy/test_configs/ca"
+	s.reconfigure.ClientCertVerify = "optional"
+	s.reconfigure.ClientCertCaFile = "other.pem"
 
-func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFormattedContent() {
-	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
 
-	s.Equal(s.ConsulTemplateFe, front)
-	s.Equal(s.ConsulTemplateBe, back)
+	s.Contains(actual, "http-request deny deny_status 403 if url_myService\n")
 }
 
-func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenUsersEnvIsPresent() {
-	usersOrig := os.Getenv("USERS")
-	defer func() { os.Setenv("USERS", usersOrig) }()
-	os.Setenv("USERS", "anything")
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsTcpFrontendAndBackend_WhenServiceHasTcpGroups() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "8080"
+	s.reconfigure.TcpDestinations = []TcpDestination{{SrcPort: 9000, Port: "9000"}, {SrcPort: 9001, Port: "9100"}}
 	expected := `backend myService-be
     mode http
-    {{range $i, $e := service "myService" "any"}}
-    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
-    {{end}}
-    acl defaultUsersAcl http_auth(defaultUsers)
-    http-request auth realm defaultRealm if !defaultUsersAcl`
+    server myService myService:8080
 
-	_, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+frontend tcp_9000
+    bind *:9000
+    mode tcp
+    default_backend myService-be9000-tcp
+
+backend myService-be9000-tcp
+    mode tcp
+    server myService myService:9000
+
+frontend tcp_9001
+    bind *:9001
+    mode tcp
+    default_backend myService-be9001-tcp
+
+backend myService-be9001-tcp
+    mode tcp
+    server myService myService:9100`
 
+	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, front)
 	s.Equal(expected, back)
 }
 
-func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenUsersIsPresent() {
-	s.reconfigure.Users = []User{
-		{Username: "user-1", Password: "pass-1"},
-		{Username: "user-2", Password: "pass-2"},
-	}
-	expected := `userlist myServiceUsers
-    user user-1 insecure-password pass-1
-    user user-2 insecure-password pass-2
+func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsOnlyTcpFrontendAndBackend_WhenReqModeIsTcp() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.ServiceName = "postgres"
+	s.reconfigure.ServicePath = nil
+	s.reconfigure.Port = "5432"
+	s.reconfigure.ReqMode = "tcp"
+	s.reconfigure.SrcPort = "15432"
+	s.reconfigure.Users = []User{{Username: "user", Password: "pass"}}
+	expected := `frontend tcp_15432
+    bind *:15432
+    mode tcp
+    default_backend postgres-be15432-tcp
+
+backend postgres-be15432-tcp
+    mode tcp
+    server postgres postgres:5432`
+
+	front, back, err := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
 
-backend myService-be
-    mode http
-    {{range $i, $e := service "myService" "any"}}
-    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
-    {{end}}
-    acl myServiceUsersAcl http_auth(myServiceUsers)
-    http-request auth realm myServiceRealm if !myServiceUsersAcl`
+	s.NoError(err)
+	s.Empty(front)
+	s.Equal(expected, back)
+}
 
-	_, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsTcpOptions_WhenReqModeIsTcp() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.ServiceName = "postgres"
+	s.reconfigure.ServicePath = nil
+	s.reconfigure.Port = "5432"
+	s.reconfigure.ReqMode = "tcp"
+	s.reconfigure.SrcPort = "15432"
+	s.reconfigure.AllowedSourceIPs = []string{"10.0.0.0/8", "192.168.1.10"}
+	s.reconfigure.BackupHostname = "postgres-replica"
+	s.reconfigure.FrontendExtra = "tcp-request inspect-delay 5s"
+	s.reconfigure.BackendExtra = "balance leastconn"
+	expected := `frontend tcp_15432
+    bind *:15432
+    mode tcp
+    tcp-request connection reject if !{ src 10.0.0.0/8 192.168.1.10 }
+    tcp-request inspect-delay 5s
+    default_backend postgres-be15432-tcp
+
+backend postgres-be15432-tcp
+    mode tcp
+    server postgres postgres:5432
+    server backup-postgres postgres-replica:5432 backup
+    balance leastconn`
+
+	_, back, err := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
 
+	s.NoError(err)
 	s.Equal(expected, back)
 }
 
-func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFormattedContent_WhenModeIsSwarm() {
-	modes := []string{"service", "sWARm"}
-	for _, mode := range modes {
-		s.reconfigure.ServiceReconfigure.Mode = mode
-		s.reconfigure.ServiceReconfigure.Port = "1234"
-		expected := `backend myService-be
-    mode http
-    server myService myService:1234`
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsClientTimeoutToTcpFrontends() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "8080"
+	s.reconfigure.TimeoutClient = 600
+	s.reconfigure.TcpDestinations = []TcpDestination{{SrcPort: 9000, Port: "9000"}}
 
-		_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+	_, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
 
-		s.Equal(expected, actual)
-	}
+	s.Contains(back, `frontend tcp_9000
+    bind *:9000
+    mode tcp
+    timeout client 600s
+    default_backend myService-be9000-tcp`)
 }
 
-func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenModeIsSwarmAndUsersEnvIsPresent() {
-	usersOrig := os.Getenv("USERS")
-	defer func() { os.Setenv("USERS", usersOrig) }()
-	os.Setenv("USERS", "anything")
-	s.reconfigure.ServiceReconfigure.Mode = "swarm"
-	s.reconfigure.ServiceReconfigure.Port = "1234"
-	expected := `backend myService-be
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsBackendForEachHttpDestinationPort() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "8080"
+	s.reconfigure.ServicePath = []string{"/api"}
+	s.reconfigure.HttpDestinations = []HttpDestination{
+		{Port: "8081", ServicePath: []string{"/admin"}},
+		{Port: "8081", ServicePath: []string{"/"}, ServiceDomain: []string{"admin.com"}},
+		{ServicePath: []string{"/docs"}},
+	}
+	expectedFront := `
+    acl url_myService path_beg /api
+    use_backend myService-be if url_myService
+    acl url_myService_1 path_beg /admin
+    use_backend myService_1-8081-be if url_myService_1
+    acl url_myService_2 path_beg /
+    acl domain_myService_2 hdr_dom(host) -i admin.com
+    use_backend myService_2-8081-be if url_myService_2 domain_myService_2
+    acl url_myService_3 path_beg /docs
+    use_backend myService-be if url_myService_3`
+	expectedBack := `backend myService-be
     mode http
-    server myService myService:1234
-    acl defaultUsersAcl http_auth(defaultUsers)
-    http-request auth realm defaultRealm if !defaultUsersAcl`
+    server myService myService:8080
 
-	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+backend myService_1-8081-be
+    mode http
+    server myService_1 myService:8081
 
-	s.Equal(expected, actual)
+backend myService_2-8081-be
+    mode http
+    server myService_2 myService:8081`
+
+	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedFront, front)
+	s.Equal(expectedBack, back)
 }
 
-func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenModeIsSwarmAndUsersIsPresent() {
-	s.reconfigure.Users = []User{
-		{Username: "user-1", Password: "pass-1"},
-		{Username: "user-2", Password: "pass-2"},
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsDistinctBackends_WhenHttpDestinationsUseTheSamePort() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "8080"
+	s.reconfigure.ServicePath = []string{"/api"}
+	s.reconfigure.HttpDestinations = []HttpDestination{
+		{Port: "9000", ServicePath: []string{"/admin"}, ServiceDomain: []string{"admin.com"}},
+		{Port: "9000", ServicePath: []string{"/admin"}, ServiceDomain: []string{"ops.com"}},
 	}
-	s.reconfigure.ServiceReconfigure.Mode = "swarm"
-	s.reconfigure.ServiceReconfigure.Port = "1234"
-	expected := `userlist myServiceUsers
-    user user-1 insecure-password pass-1
-    user user-2 insecure-password pass-2
 
-backend myService-be
-    mode http
-    server myService myService:1234
-    acl myServiceUsersAcl http_auth(myServiceUsers)
-    http-request auth realm myServiceRealm if !myServiceUsersAcl`
+	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
 
-	_, actual, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+	s.Equal(1, strings.Count(back, "backend myService_1-9000-be\n"))
+	s.Equal(1, strings.Count(back, "backend myService_2-9000-be\n"))
+	s.Contains(front, "use_backend myService_1-9000-be if url_myService_1 domain_myService_1")
+	s.Contains(front, "use_backend myService_2-9000-be if url_myService_2 domain_myService_2")
+}
 
-	s.Equal(expected, actual)
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsFrontend_WhenSrcPortIsSet() {
+	s.reconfigure.SrcPort = "8443"
+
+	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateFe, front)
+	s.Equal(s.ConsulTemplateBe+`
+
+frontend services_8443
+    bind *:8443{{.CertsString}}
+    mode http`+s.ConsulTemplateFe, back)
 }
 
-func (s ReconfigureTestSuite) Test_GetTemplates_AddsHosts() {
-	s.ConsulTemplateFe = `
-    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
-    acl domain_myService hdr_dom(host) -i my-domain.com my-other-domain.com
-    use_backend myService-be if url_myService domain_myService`
-	s.reconfigure.ServiceDomain = []string{"my-domain.com", "my-other-domain.com"}
-	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsFrontendWithClientTimeout_WhenSrcPortIsSet() {
+	s.reconfigure.SrcPort = "8443"
+	s.reconfigure.TimeoutClient = 600
 
-	s.Equal(s.ConsulTemplateFe, actual)
+	_, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Contains(back, `
+frontend services_8443
+    bind *:8443{{.CertsString}}
+    mode http
+    timeout client 600s`+s.ConsulTemplateFe)
 }
 
-func (s ReconfigureTestSuite) Test_GetTemplates_AddsHostsStartingWithWildcard() {
-	s.ConsulTemplateFe = `
+func (s ReconfigureTestSuite) Test_GetTemplates_RoutesToMaintenanceBackend_WhenMaintenanceWindowIsOpen() {
+	maintenanceNowOrig := maintenanceNow
+	defer func() { maintenanceNow = maintenanceNowOrig }()
+	// A proxy restarted in the middle of the window configures the service in maintenance
+	maintenanceNow = func() time.Time { return time.Date(2017, 6, 1, 2, 30, 0, 0, time.UTC) }
+	s.reconfigure.MaintenanceWindow = "02:00-03:00"
+	expectedFront := `
     acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
-    acl domain_myService hdr_end(host) -i acme.com .domain.com
-    use_backend myService-be if url_myService domain_myService`
-	s.reconfigure.ServiceDomain = []string{"acme.com", "*.domain.com"}
-	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+    use_backend myService-maintenance-be if url_myService
+    use_backend myService-be if url_myService`
+	expectedBack := s.ConsulTemplateBe + `
 
-	s.Equal(s.ConsulTemplateFe, actual)
+backend myService-maintenance-be
+    mode http`
+
+	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedFront, front)
+	s.Equal(expectedBack, back)
 }
 
-func (s ReconfigureTestSuite) Test_GetTemplates_AddsReqRep_WhenReqRepSearchAndReqRepReplaceArePresent() {
-	s.reconfigure.ReqRepSearch = "this"
-	s.reconfigure.ReqRepReplace = "that"
-	expected := fmt.Sprintf(`backend myService-be
-    mode http
-    reqrep %s     %s
-    {{range $i, $e := service "%s" "any"}}
-    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check
-    {{end}}`,
-		s.reconfigure.ReqRepSearch,
-		s.reconfigure.ReqRepReplace,
-		s.reconfigure.ServiceName,
-	)
+func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotRouteToMaintenanceBackend_WhenMaintenanceIsFalse() {
+	maintenanceNowOrig := maintenanceNow
+	defer func() { maintenanceNow = maintenanceNowOrig }()
+	maintenanceNow = func() time.Time { return time.Date(2017, 6, 1, 2, 30, 0, 0, time.UTC) }
+	s.reconfigure.MaintenanceWindow = "02:00-03:00"
+	s.reconfigure.Maintenance = "false"
 
-	_, backend, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
 
-	s.Equal(expected, backend)
+	s.Equal(s.ConsulTemplateFe, front)
+	s.Equal(s.ConsulTemplateBe, back)
 }
 
-func (s ReconfigureTestSuite) Test_GetTemplates_UsesAclNameForFrontEnd() {
-	s.reconfigure.AclName = "my-acl"
-	s.ConsulTemplateFe = `
-    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
-    use_backend my-acl-be if url_myService`
+func (s ReconfigureTestSuite) Test_GetTemplates_ChangesOnlyUpdatedTcpGroup() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.Port = "8080"
+	s.reconfigure.TcpDestinations = []TcpDestination{{SrcPort: 9000, Port: "9000"}}
+	front, back, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+	s.reconfigure.TcpDestinations = []TcpDestination{{SrcPort: 9000, Port: "9100"}}
+
+	updatedFront, updatedBack, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
+
+	s.Equal(front, updatedFront)
+	s.Equal(strings.Replace(back, "myService:9000", "myService:9100", 1), updatedBack)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsEmptyFrontEnd_WhenUseDomainMapIsTrue() {
+	s.reconfigure.ServiceDomain = []string{"customer-1.com", "customer-2.com"}
+	s.reconfigure.UseDomainMap = true
+
 	actual, _, _ := s.reconfigure.GetTemplates(s.reconfigure.ServiceReconfigure)
 
-	s.Equal(s.ConsulTemplateFe, actual)
+	s.Equal("", actual)
 }
 
 func (s ReconfigureTestSuite) Test_GetTemplates_UsesPathReg() {
@@ -463,6 +1921,64 @@ func (s ReconfigureTestSuite) Test_Execute_WritesFeTemplate_WhenModeIsSwarm() {
 	s.Equal(s.ConsulTemplateFe, actualData)
 }
 
+func (s ReconfigureTestSuite) Test_Execute_WritesInternalFeTemplate_WhenInternalOnlyIsTrue() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.InternalOnly = true
+	var actualFilename string
+	expectedFilename := fmt.Sprintf("%s/%s-internal-fe.cfg", s.TemplatesPath, s.ServiceName)
+	writeFeTemplateOrig := writeFeTemplate
+	defer func() { writeFeTemplate = writeFeTemplateOrig }()
+	writeFeTemplate = func(filename string, data []byte, perm os.FileMode) error {
+		actualFilename = filename
+		return nil
+	}
+
+	s.reconfigure.Execute([]string{})
+
+	s.Equal(expectedFilename, actualFilename)
+}
+
+func (s ReconfigureTestSuite) Test_Execute_RemovesPublicFeTemplate_WhenServiceBecomesInternal() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.InternalOnly = true
+	var actualFilename string
+	removeFeTemplateOrig := removeFeTemplate
+	defer func() { removeFeTemplate = removeFeTemplateOrig }()
+	removeFeTemplate = func(name string) error {
+		actualFilename = name
+		return nil
+	}
+	writeFeTemplateOrig := writeFeTemplate
+	defer func() { writeFeTemplate = writeFeTemplateOrig }()
+	writeFeTemplate = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+
+	s.reconfigure.Execute([]string{})
+
+	s.Equal(fmt.Sprintf("%s/%s-fe.cfg", s.TemplatesPath, s.ServiceName), actualFilename)
+}
+
+func (s ReconfigureTestSuite) Test_Execute_RemovesInternalFeTemplate_WhenServiceBecomesPublic() {
+	s.reconfigure.Mode = "swarm"
+	var actualFilename string
+	removeFeTemplateOrig := removeFeTemplate
+	defer func() { removeFeTemplate = removeFeTemplateOrig }()
+	removeFeTemplate = func(name string) error {
+		actualFilename = name
+		return nil
+	}
+	writeFeTemplateOrig := writeFeTemplate
+	defer func() { writeFeTemplate = writeFeTemplateOrig }()
+	writeFeTemplate = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+
+	s.reconfigure.Execute([]string{})
+
+	s.Equal(fmt.Sprintf("%s/%s-internal-fe.cfg", s.TemplatesPath, s.ServiceName), actualFilename)
+}
+
 func (s ReconfigureTestSuite) Test_Execute_WritesBeTemplate_WhenModeIsService() {
 	s.reconfigure.Mode = "SerVIce"
 	s.reconfigure.Port = "1234"
@@ -662,6 +2178,48 @@ func (s *ReconfigureTestSuite) Test_Execute_PutsDataToConsul() {
 	mockObj.AssertCalled(s.T(), "PutService", []string{s.ConsulAddress}, s.InstanceName, r)
 }
 
+func (s *ReconfigureTestSuite) Test_Execute_PutsAddressesToConsul() {
+	s.reconfigure.ServiceAddress = "10.0.0.1"
+	s.reconfigure.ColorAddresses = map[string]string{"blue": "10.0.0.2", "green": "10.0.0.3"}
+	mockObj := getRegistrarableMock("")
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	registryInstance = mockObj
+	r := registry.Registry{
+		ServiceName:    s.ServiceName,
+		ServicePath:    s.ServicePath,
+		ServiceAddress: s.reconfigure.ServiceAddress,
+		ColorAddresses: s.reconfigure.ColorAddresses,
+	}
+
+	s.reconfigure.Execute([]string{})
+
+	mockObj.AssertCalled(s.T(), "PutService", []string{s.ConsulAddress}, s.InstanceName, r)
+}
+
+func (s *ReconfigureTestSuite) Test_Execute_PutsCorsToConsul() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "2.2")
+	s.reconfigure.CorsOrigins = []string{"*"}
+	s.reconfigure.CorsMethods = []string{"GET", "POST"}
+	s.reconfigure.CorsHeaders = []string{"Content-Type"}
+	mockObj := getRegistrarableMock("")
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	registryInstance = mockObj
+	r := registry.Registry{
+		ServiceName: s.ServiceName,
+		ServicePath: s.ServicePath,
+		CorsOrigins: s.reconfigure.CorsOrigins,
+		CorsMethods: s.reconfigure.CorsMethods,
+		CorsHeaders: s.reconfigure.CorsHeaders,
+	}
+
+	s.reconfigure.Execute([]string{})
+
+	mockObj.AssertCalled(s.T(), "PutService", []string{s.ConsulAddress}, s.InstanceName, r)
+}
+
 func (s *ReconfigureTestSuite) Test_Execute_DoesNotPutDataToConsul_WhenModeIsServiceAndConsulAddressIsEmpty() {
 	s.verifyDoesNotPutDataToConsul("seRViCe")
 }
@@ -791,6 +2349,20 @@ func (s *ReconfigureTestSuite) Test_ReloadAllServices_InvokesProxyReload() {
 	mockObj.AssertCalled(s.T(), "Reload")
 }
 
+func (s *ReconfigureTestSuite) Test_ReloadAllServices_DoesNotInvokeProxyReload_WhenBeforeStartupReloadReturnsFalse() {
+	mockObj := getProxyMock("")
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	s.reconfigure.BeforeStartupReload = func() bool { return false }
+
+	err := s.reconfigure.ReloadAllServices([]string{s.ConsulAddress}, s.InstanceName, s.Mode, "")
+
+	s.NoError(err)
+	mockObj.AssertCalled(s.T(), "CreateConfigFromTemplates")
+	mockObj.AssertNotCalled(s.T(), "Reload")
+}
+
 func (s *ReconfigureTestSuite) Test_ReloadAllServices_ReturnsError_WhenProxyReloadFails() {
 	mockObj := getProxyMock("Reload")
 	mockObj.On("Reload").Return(fmt.Errorf("This is an error"))
@@ -813,6 +2385,32 @@ func (s *ReconfigureTestSuite) Test_ReloadAllServices_AddsHttpIfNotPresent() {
 	s.NoError(err)
 }
 
+func (s *ReconfigureTestSuite) Test_ReloadAllServices_SkipsServices_WhenNamesAreInvalid() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/catalog/services":
+			w.Write([]byte(`{"stats": [], "my:service": [], "go-demo": []}`))
+		case "/v1/kv/" + s.InstanceName + "/stats/path", "/v1/kv/" + s.InstanceName + "/my:service/path", "/v1/kv/" + s.InstanceName + "/go-demo/path":
+			w.Write([]byte("/api"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer func() { srv.Close() }()
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = getProxyMock("")
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	mockObj := getRegistrarableMock("")
+	registryInstance = mockObj
+
+	err := s.reconfigure.ReloadAllServices([]string{srv.URL}, s.InstanceName, "", "")
+
+	s.NoError(err)
+	mockObj.AssertNumberOfCalls(s.T(), "CreateConfigs", 1)
+}
+
 func (s *ReconfigureTestSuite) Test_ReloadAllServices_SendsARequestToSwarmListener_WhenListenerAddressIsDefined() {
 	actual := ""
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -836,6 +2434,26 @@ func (s *ReconfigureTestSuite) Test_ReloadAllServices_ReturnsError_WhenSwarmList
 	s.Error(err)
 }
 
+func (s *ReconfigureTestSuite) Test_ReloadAllServices_RecordsListenerMetrics() {
+	ListenerMetrics.Reset()
+	defer ListenerMetrics.Reset()
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer func() { srv.Close() }()
+
+	s.reconfigure.ReloadAllServices([]string{}, s.InstanceName, s.Mode, srv.URL)
+	status = http.StatusInternalServerError
+	s.reconfigure.ReloadAllServices([]string{}, s.InstanceName, s.Mode, srv.URL)
+	actual := ListenerMetrics.String()
+
+	labels := fmt.Sprintf(`{operation="notify_services",address="%s"}`, srv.URL)
+	s.Contains(actual, "docker_flow_proxy_listener_operations_total"+labels+" 2\n")
+	s.Contains(actual, "docker_flow_proxy_listener_operation_failures_total"+labels+" 1\n")
+	s.Contains(actual, "docker_flow_proxy_listener_operation_duration_seconds_count"+labels+" 2\n")
+}
+
 func (s *ReconfigureTestSuite) Test_ReloadAllServices_ReturnsError_WhenSwarmListenerFails() {
 	httpGetOrig := httpGet
 	defer func() { httpGet = httpGetOrig }()
@@ -979,6 +2597,10 @@ func (m *ProxyMock) AddCert(certName string) {
 	m.Called(certName)
 }
 
+func (m *ProxyMock) RemoveCert(certName string) {
+	m.Called(certName)
+}
+
 func (m *ProxyMock) GetCerts() map[string]string {
 	params := m.Called()
 	return params.Get(0).(map[string]string)
@@ -1001,6 +2623,9 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "AddCert" {
 		mockObj.On("AddCert", mock.Anything).Return(nil)
 	}
+	if skipMethod != "RemoveCert" {
+		mockObj.On("RemoveCert", mock.Anything).Return(nil)
+	}
 	if skipMethod != "GetCerts" {
 		mockObj.On("GetCerts").Return(map[string]string{})
 	}
@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	haproxy "../proxy"
+)
+
+// proxyRole is the role of the proxy instance (e.g. edge). Services reconfigured with proxyRole are configured only
+// on the instances with the same role.
+var proxyRole = ""
+var proxyRoleMu = &sync.RWMutex{}
+
+// unappliedServices holds the services whose proxyRole differs from the role of the instance. They are stored
+// without configuration files so that they can be listed and applied once the role matches. Keys match
+// configuredServices.
+var unappliedServices = map[string]ServiceReconfigure{}
+
+var removeBeTemplate = os.Remove
+
+// SetProxyRole sets the role of the proxy instance. Services are not re-evaluated.
+func SetProxyRole(role string) {
+	proxyRoleMu.Lock()
+	defer proxyRoleMu.Unlock()
+	proxyRole = role
+}
+
+// GetProxyRole returns the role of the proxy instance.
+func GetProxyRole() string {
+	proxyRoleMu.RLock()
+	defer proxyRoleMu.RUnlock()
+	return proxyRole
+}
+
+// MatchesProxyRole tells whether the service should be configured on this instance. Services without proxyRole are
+// configured on all the instances.
+func (sr ServiceReconfigure) MatchesProxyRole() bool {
+	return len(sr.ProxyRole) == 0 || strings.EqualFold(sr.ProxyRole, GetProxyRole())
+}
+
+// GetUnappliedServices returns the services that are stored without configuration because their proxyRole differs
+// from the role of the instance. They are sorted by their names.
+func GetUnappliedServices() []ServiceReconfigure {
+	knownServicesMu.Lock()
+	defer knownServicesMu.Unlock()
+	services := []ServiceReconfigure{}
+	for _, sr := range unappliedServices {
+		services = append(services, sr)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].ServiceName == services[j].ServiceName {
+			return services[i].AclName < services[j].AclName
+		}
+		return services[i].ServiceName < services[j].ServiceName
+	})
+	return services
+}
+
+// RemoveUnappliedService forgets the service stored without configuration. It returns false if the service is not
+// stored as such.
+func RemoveUnappliedService(name string) bool {
+	knownServicesMu.Lock()
+	defer knownServicesMu.Unlock()
+	_, ok := unappliedServices[name]
+	delete(unappliedServices, name)
+	return ok
+}
+
+// storeUnappliedService records the service as not applied and forgets its configuration.
+func storeUnappliedService(name string, sr ServiceReconfigure) {
+	knownServicesMu.Lock()
+	defer knownServicesMu.Unlock()
+	delete(knownServices, name)
+	delete(configuredServices, name)
+	unappliedServices[name] = sr
+}
+
+// withdraw stores the service as not applied and removes the configuration files it might have been given before.
+// It returns true if any file was removed, in which case the proxy configuration should be recreated.
+func (m *Reconfigure) withdraw(templatesPath string, sr ServiceReconfigure) bool {
+	name := sr.ServiceName
+	if isSwarm(sr.Mode) && len(sr.AclName) > 0 {
+		name = sr.AclName
+	}
+	logPrintf("The service %s is not configured since its role %s differs from the role %s of the proxy", sr.ServiceName, sr.ProxyRole, GetProxyRole())
+	storeUnappliedService(name, sr)
+	removed := false
+	removers := map[string]func(string) error{
+		fmt.Sprintf("%s/%s-fe.cfg", templatesPath, name):          removeFeTemplate,
+		fmt.Sprintf("%s/%s-internal-fe.cfg", templatesPath, name): removeFeTemplate,
+		fmt.Sprintf("%s/%s-be.cfg", templatesPath, name):          removeBeTemplate,
+	}
+	for path, remove := range removers {
+		if err := remove(path); err == nil {
+			removed = true
+		} else if !os.IsNotExist(err) {
+			logPrintf("Could not remove %s\n%s", path, err.Error())
+		}
+	}
+	return removed
+}
+
+// executeWithdrawal withdraws the service from this instance and keeps it in the registry so that it is evaluated
+// again on restart.
+func (m *Reconfigure) executeWithdrawal() error {
+	if m.withdraw(m.TemplatesPath, m.ServiceReconfigure) {
+		if _, _, err := WriteDomainMap(m.ConfigsPath); err != nil {
+			logPrintf(err.Error())
+		}
+		if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
+			return err
+		}
+		haproxy.AddReloadChange(m.ServiceName, haproxy.ReloadActionRemove, "")
+		if err := haproxy.Instance.Reload(); err != nil {
+			return err
+		}
+	}
+	if len(m.ConsulAddresses) > 0 || !isSwarm(m.ServiceReconfigure.Mode) {
+		return m.putToConsul(m.ConsulAddresses, m.ServiceReconfigure, m.InstanceName)
+	}
+	return nil
+}
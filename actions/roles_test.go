@@ -0,0 +1,164 @@
+// +build !integration
+
+package actions
+
+import (
+	"github.com/stretchr/testify/suite"
+	"os"
+	"testing"
+
+	haproxy "../proxy"
+)
+
+type RolesTestSuite struct {
+	suite.Suite
+	proxyMock *ProxyMock
+	written   []string
+	removed   []string
+}
+
+func (s *RolesTestSuite) SetupTest() {
+	s.proxyMock = getProxyMock("")
+	haproxy.Instance = s.proxyMock
+	s.written = []string{}
+	s.removed = []string{}
+	writeFeTemplate = func(filename string, data []byte, perm os.FileMode) error {
+		s.written = append(s.written, filename)
+		return nil
+	}
+	writeBeTemplate = writeFeTemplate
+	removeFeTemplate = func(name string) error {
+		s.removed = append(s.removed, name)
+		return nil
+	}
+	removeBeTemplate = removeFeTemplate
+	writeDomainMapFile = func(filename string, data []byte, perm os.FileMode) error {
+		return nil
+	}
+	lookupHost = func(host string) (addrs []string, err error) {
+		return []string{}, nil
+	}
+	configuredServices = map[string]ServiceReconfigure{}
+	unappliedServices = map[string]ServiceReconfigure{}
+	knownServices = map[string]bool{}
+	SetProxyRole("")
+}
+
+// MatchesProxyRole
+
+func (s *RolesTestSuite) Test_MatchesProxyRole_ReturnsTrue_WhenRolesMatchOrServiceHasNoRole() {
+	SetProxyRole("edge")
+
+	s.True(ServiceReconfigure{}.MatchesProxyRole())
+	s.True(ServiceReconfigure{ProxyRole: "Edge"}.MatchesProxyRole())
+	s.False(ServiceReconfigure{ProxyRole: "internal"}.MatchesProxyRole())
+}
+
+// Execute
+
+func (s *RolesTestSuite) Test_Execute_ConfiguresServiceOnlyOnInstanceWithMatchingRole() {
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/demo"}, Port: "8080", Mode: "swarm", ProxyRole: "edge"}
+	base := BaseReconfigure{TemplatesPath: "/tmpl"}
+
+	SetProxyRole("edge")
+	err := NewReconfigure(base, sr).Execute([]string{})
+
+	s.NoError(err)
+	s.Equal([]string{"/tmpl/my-service-fe.cfg", "/tmpl/my-service-be.cfg"}, s.written)
+	s.Len(GetServices(), 1)
+	s.Empty(GetUnappliedServices())
+
+	s.written = []string{}
+	SetProxyRole("internal")
+	err = NewReconfigure(base, sr).Execute([]string{})
+
+	s.NoError(err)
+	s.Empty(s.written)
+	s.Empty(GetServices())
+	s.Equal([]ServiceReconfigure{sr}, GetUnappliedServices())
+}
+
+func (s *RolesTestSuite) Test_Execute_RemovesConfigsAndReloads_WhenAppliedServiceNoLongerMatchesRole() {
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/demo"}, Port: "8080", Mode: "swarm", ProxyRole: "edge"}
+	SetProxyRole("edge")
+	NewReconfigure(BaseReconfigure{TemplatesPath: "/tmpl"}, sr).Execute([]string{})
+	s.proxyMock = getProxyMock("")
+	haproxy.Instance = s.proxyMock
+	s.removed = []string{}
+
+	SetProxyRole("internal")
+	err := NewReconfigure(BaseReconfigure{TemplatesPath: "/tmpl"}, sr).Execute([]string{})
+
+	s.NoError(err)
+	s.Contains(s.removed, "/tmpl/my-service-fe.cfg")
+	s.Contains(s.removed, "/tmpl/my-service-be.cfg")
+	s.proxyMock.AssertCalled(s.T(), "Reload")
+	s.False(knownServices["my-service"])
+}
+
+func (s *RolesTestSuite) Test_Execute_DoesNotReload_WhenUnappliedServiceHasNoConfigs() {
+	removeFeTemplate = func(name string) error {
+		return os.ErrNotExist
+	}
+	removeBeTemplate = removeFeTemplate
+	SetProxyRole("edge")
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/demo"}, Port: "8080", Mode: "swarm", ProxyRole: "internal"}
+
+	err := NewReconfigure(BaseReconfigure{TemplatesPath: "/tmpl"}, sr).Execute([]string{})
+
+	s.NoError(err)
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+// ReconfigureBatch > Execute
+
+func (s *RolesTestSuite) Test_BatchExecute_ConfiguresOnlyServicesWithMatchingRole() {
+	SetProxyRole("edge")
+	services := []ServiceReconfigure{
+		{ServiceName: "service-1", ServicePath: []string{"/1"}, Port: "8080", Mode: "swarm", ProxyRole: "edge"},
+		{ServiceName: "service-2", ServicePath: []string{"/2"}, Port: "8080", Mode: "swarm", ProxyRole: "internal"},
+	}
+
+	err := NewReconfigureBatch(BaseReconfigure{TemplatesPath: "/tmpl"}, services).Execute([]string{})
+
+	s.NoError(err)
+	s.Equal([]string{"/tmpl/service-1-fe.cfg", "/tmpl/service-1-be.cfg"}, s.written)
+	s.Equal([]ServiceReconfigure{services[1]}, GetUnappliedServices())
+}
+
+// RemoveUnappliedService
+
+func (s *RolesTestSuite) Test_RemoveUnappliedService_ReturnsWhetherServiceWasUnapplied() {
+	storeUnappliedService("my-service", ServiceReconfigure{ServiceName: "my-service"})
+
+	s.True(RemoveUnappliedService("my-service"))
+	s.False(RemoveUnappliedService("my-service"))
+	s.Empty(GetUnappliedServices())
+}
+
+// Suite
+
+func TestRolesUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	proxyOrig := haproxy.Instance
+	writeFeTemplateOrig := writeFeTemplate
+	writeBeTemplateOrig := writeBeTemplate
+	removeFeTemplateOrig := removeFeTemplate
+	removeBeTemplateOrig := removeBeTemplate
+	writeDomainMapFileOrig := writeDomainMapFile
+	lookupHostOrig := lookupHost
+	defer func() {
+		haproxy.Instance = proxyOrig
+		writeFeTemplate = writeFeTemplateOrig
+		writeBeTemplate = writeBeTemplateOrig
+		removeFeTemplate = removeFeTemplateOrig
+		removeBeTemplate = removeBeTemplateOrig
+		writeDomainMapFile = writeDomainMapFileOrig
+		lookupHost = lookupHostOrig
+		configuredServices = map[string]ServiceReconfigure{}
+		unappliedServices = map[string]ServiceReconfigure{}
+		knownServices = map[string]bool{}
+		SetProxyRole("")
+	}()
+	suite.Run(t, new(RolesTestSuite))
+}
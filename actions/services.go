@@ -0,0 +1,41 @@
+package actions
+
+import (
+	"sort"
+)
+
+// configuredServices holds the parameters of the services the proxy is configured with. Keys match knownServices.
+var configuredServices = map[string]ServiceReconfigure{}
+
+// storeService records the parameters the configuration files of the service were created from.
+func storeService(name string, sr ServiceReconfigure) {
+	knownServicesMu.Lock()
+	defer knownServicesMu.Unlock()
+	configuredServices[name] = sr
+	delete(unappliedServices, name)
+}
+
+// getStoredService returns the parameters the configuration files of the service were last created from.
+func getStoredService(name string) (ServiceReconfigure, bool) {
+	knownServicesMu.Lock()
+	defer knownServicesMu.Unlock()
+	sr, ok := configuredServices[name]
+	return sr, ok
+}
+
+// GetServices returns the parameters of all the configured services sorted by their names.
+func GetServices() []ServiceReconfigure {
+	knownServicesMu.Lock()
+	defer knownServicesMu.Unlock()
+	services := []ServiceReconfigure{}
+	for _, sr := range configuredServices {
+		services = append(services, sr)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].ServiceName == services[j].ServiceName {
+			return services[i].AclName < services[j].AclName
+		}
+		return services[i].ServiceName < services[j].ServiceName
+	})
+	return services
+}
@@ -0,0 +1,227 @@
+package actions
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	haproxy "../proxy"
+)
+
+const (
+	// CheckPass means that the check found no problems.
+	CheckPass = "pass"
+	// CheckWarn means that the service can be reconfigured but might not behave as expected.
+	CheckWarn = "warn"
+	// CheckFail means that the service should not be reconfigured.
+	CheckFail = "fail"
+)
+
+// Check is the result of a single pre-flight check of a service.
+type Check struct {
+	Name     string
+	Status   string
+	Messages []string `json:",omitempty"`
+}
+
+var checkHaProxyConfig = haproxy.CheckConfig
+
+// NewCheck returns a check that passes if there are no messages and has the status otherwise.
+func NewCheck(name, status string, messages []string) Check {
+	if len(messages) == 0 {
+		return Check{Name: name, Status: CheckPass}
+	}
+	return Check{Name: name, Status: status, Messages: messages}
+}
+
+// Simulate checks the service against the configured services, the certificates and HAProxy without changing
+// anything. Certs map certificate names to their PEM contents.
+func Simulate(base BaseReconfigure, sr ServiceReconfigure, certs map[string]string) []Check {
+	return []Check{
+		checkConflicts(sr),
+		checkRegexps(sr),
+		checkCertCoverage(sr, certs),
+		checkConfig(base, sr),
+	}
+}
+
+// getFileName returns the name used for the configuration files of the service.
+func getFileName(sr ServiceReconfigure) string {
+	if len(sr.AclName) > 0 {
+		return sr.AclName
+	}
+	return sr.ServiceName
+}
+
+// checkConflicts compares the service with the other configured services. Services with the same name are replaced
+// by the reconfiguration so they cannot conflict.
+func checkConflicts(sr ServiceReconfigure) Check {
+	status := CheckWarn
+	messages := []string{}
+	for _, other := range GetServices() {
+		if other.ServiceName == sr.ServiceName {
+			continue
+		}
+		if getFileName(other) == getFileName(sr) {
+			status = CheckFail
+			messages = append(messages, fmt.Sprintf("The aclName %s is already used by the service %s", getFileName(sr), other.ServiceName))
+		}
+		if other.InternalOnly == sr.InternalOnly && sameDomains(other.ServiceDomain, sr.ServiceDomain) {
+			for _, path := range sr.ServicePath {
+				if containsString(other.ServicePath, path) {
+					status = CheckFail
+					messages = append(messages, fmt.Sprintf("The path %s is already routed to the service %s", path, other.ServiceName))
+				}
+			}
+		}
+		if isSwarm(sr.Mode) && len(sr.Port) > 0 && other.Port == sr.Port && len(getTarget(sr)) > 0 && getTarget(other) == getTarget(sr) {
+			messages = append(messages, fmt.Sprintf("The address %s:%s is already used by the service %s", getTarget(sr), sr.Port, other.ServiceName))
+		}
+	}
+	return NewCheck("conflicts", status, messages)
+}
+
+// getTarget returns the explicitly specified address of the service. Services without one are addressed by their
+// names so they cannot share addresses.
+func getTarget(sr ServiceReconfigure) string {
+	if len(sr.ServiceColor) > 0 && len(sr.ColorAddresses[sr.ServiceColor]) > 0 {
+		return sr.ColorAddresses[sr.ServiceColor]
+	} else if len(sr.ServiceAddress) > 0 {
+		return sr.ServiceAddress
+	}
+	return sr.OutboundHostname
+}
+
+func sameDomains(domains, other []string) bool {
+	a := append([]string{}, domains...)
+	b := append([]string{}, other...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRegexps compiles the regular expressions of the service. HAProxy uses PCRE which supports more than Go, so
+// expressions that do not compile are only reported as warnings.
+func checkRegexps(sr ServiceReconfigure) Check {
+	expressions := []string{}
+	if strings.HasSuffix(sr.PathType, "_reg") {
+		expressions = append(expressions, sr.ServicePath...)
+	}
+	if len(sr.ReqRepSearch) > 0 {
+		expressions = append(expressions, sr.ReqRepSearch)
+	}
+	messages := []string{}
+	for _, expression := range expressions {
+		if _, err := regexp.Compile(expression); err != nil {
+			messages = append(messages, fmt.Sprintf("The expression %s might be invalid\n%s", expression, err.Error()))
+		}
+	}
+	return NewCheck("regexps", CheckWarn, messages)
+}
+
+// checkCertCoverage reports the domains of the service that are not covered by any certificate.
+func checkCertCoverage(sr ServiceReconfigure, certs map[string]string) Check {
+	if len(sr.ServiceDomain) == 0 {
+		return NewCheck("certs", CheckWarn, nil)
+	}
+	contents := []string{}
+	for _, content := range certs {
+		contents = append(contents, content)
+	}
+	if len(sr.ServiceCert) > 0 {
+		contents = append(contents, sr.ServiceCert)
+	}
+	if len(contents) == 0 {
+		return NewCheck("certs", CheckWarn, []string{"There are no certificates so the service is served over HTTP only"})
+	}
+	parsed := []*x509.Certificate{}
+	for _, content := range contents {
+		rest := []byte(content)
+		for {
+			var block *pem.Block
+			if block, rest = pem.Decode(rest); block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+				parsed = append(parsed, cert)
+			}
+		}
+	}
+	messages := []string{}
+	for _, domain := range sr.ServiceDomain {
+		covered := false
+		for _, cert := range parsed {
+			if cert.VerifyHostname(domain) == nil {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			messages = append(messages, fmt.Sprintf("The domain %s is not covered by any certificate", domain))
+		}
+	}
+	return NewCheck("certs", CheckWarn, messages)
+}
+
+// checkConfig assembles the configuration of all the services with the candidate one in a temporary directory and
+// checks it with HAProxy.
+func checkConfig(base BaseReconfigure, sr ServiceReconfigure) Check {
+	if !isSwarm(sr.Mode) {
+		return NewCheck("config", CheckWarn, []string{"The configuration is not checked since it is created by Consul Template"})
+	}
+	front, back, err := NewReconfigure(base, sr).GetTemplates(sr)
+	if err != nil {
+		return NewCheck("config", CheckFail, []string{err.Error()})
+	}
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		return NewCheck("config", CheckFail, []string{err.Error()})
+	}
+	defer os.RemoveAll(dir)
+	files, err := ioutil.ReadDir(base.TemplatesPath)
+	if err != nil {
+		return NewCheck("config", CheckFail, []string{fmt.Sprintf("Could not read the directory %s\n%s", base.TemplatesPath, err.Error())})
+	}
+	name := getFileName(sr)
+	for _, file := range files {
+		if file.IsDir() || (file.Name() != "haproxy.tmpl" && !strings.HasSuffix(file.Name(), ".cfg")) {
+			continue
+		}
+		// The current configuration of the service is replaced by the candidate one
+		if file.Name() == name+"-fe.cfg" || file.Name() == name+"-internal-fe.cfg" || file.Name() == name+"-be.cfg" {
+			continue
+		}
+		content, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", base.TemplatesPath, file.Name()))
+		if err != nil {
+			return NewCheck("config", CheckFail, []string{err.Error()})
+		}
+		ioutil.WriteFile(fmt.Sprintf("%s/%s", dir, file.Name()), content, 0664)
+	}
+	feFile := fmt.Sprintf("%s/%s-fe.cfg", dir, name)
+	if sr.InternalOnly {
+		feFile = fmt.Sprintf("%s/%s-internal-fe.cfg", dir, name)
+	}
+	ioutil.WriteFile(feFile, []byte(front), 0664)
+	ioutil.WriteFile(fmt.Sprintf("%s/%s-be.cfg", dir, name), []byte(back), 0664)
+	if err := checkHaProxyConfig(dir); err != nil {
+		return NewCheck("config", CheckFail, []string{err.Error()})
+	}
+	return NewCheck("config", CheckPass, nil)
+}
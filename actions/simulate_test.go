@@ -0,0 +1,236 @@
+// +build !integration
+
+package actions
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+type SimulateTestSuite struct {
+	suite.Suite
+	dir                   string
+	configuredServicesOrg map[string]ServiceReconfigure
+	checkHaProxyConfigOrg func(templatesPath string) error
+}
+
+func (s *SimulateTestSuite) SetupTest() {
+	s.configuredServicesOrg = configuredServices
+	configuredServices = map[string]ServiceReconfigure{
+		"other-service": {ServiceName: "other-service", ServicePath: []string{"/api/v1"}, Mode: "swarm", Port: "8080", ServiceAddress: "10.0.0.1"},
+		"other-acl":     {ServiceName: "acl-service", AclName: "other-acl", ServicePath: []string{"/admin"}, ServiceDomain: []string{"my-domain.com"}},
+	}
+	s.checkHaProxyConfigOrg = checkHaProxyConfig
+	s.dir, _ = ioutil.TempDir("", "templates")
+	for _, file := range []string{"haproxy.tmpl", "other-service-fe.cfg", "other-service-be.cfg", "my-service-fe.cfg", "my-service-be.cfg", "notes.txt"} {
+		ioutil.WriteFile(s.dir+"/"+file, []byte("current "+file), 0664)
+	}
+}
+
+func (s *SimulateTestSuite) TearDownTest() {
+	configuredServices = s.configuredServicesOrg
+	checkHaProxyConfig = s.checkHaProxyConfigOrg
+	os.RemoveAll(s.dir)
+}
+
+func (s SimulateTestSuite) getCert(domains ...string) string {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		DNSNames:     domains,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// checkConflicts
+
+func (s SimulateTestSuite) Test_CheckConflicts_Passes_WhenThereAreNoConflicts() {
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/api/v2"}}
+
+	s.Equal(Check{Name: "conflicts", Status: CheckPass}, checkConflicts(sr))
+}
+
+func (s SimulateTestSuite) Test_CheckConflicts_Passes_WhenTheServiceIsReconfigured() {
+	sr := ServiceReconfigure{ServiceName: "other-service", ServicePath: []string{"/api/v1"}}
+
+	s.Equal(CheckPass, checkConflicts(sr).Status)
+}
+
+func (s SimulateTestSuite) Test_CheckConflicts_Fails_WhenAclNameIsUsed() {
+	sr := ServiceReconfigure{ServiceName: "my-service", AclName: "other-acl", ServicePath: []string{"/api/v2"}}
+
+	actual := checkConflicts(sr)
+
+	s.Equal(CheckFail, actual.Status)
+	s.Equal([]string{"The aclName other-acl is already used by the service acl-service"}, actual.Messages)
+}
+
+func (s SimulateTestSuite) Test_CheckConflicts_Fails_WhenPathIsRoutedToAnotherService() {
+	cases := []ServiceReconfigure{
+		{ServiceName: "my-service", ServicePath: []string{"/api/v1"}},
+		{ServiceName: "my-service", ServicePath: []string{"/admin"}, ServiceDomain: []string{"my-domain.com"}},
+	}
+	for _, sr := range cases {
+		actual := checkConflicts(sr)
+
+		s.Equal(CheckFail, actual.Status)
+		s.Len(actual.Messages, 1)
+	}
+}
+
+func (s SimulateTestSuite) Test_CheckConflicts_Passes_WhenPathIsRoutedOnAnotherDomain() {
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/api/v1"}, ServiceDomain: []string{"my-domain.com"}}
+
+	s.Equal(CheckPass, checkConflicts(sr).Status)
+}
+
+func (s SimulateTestSuite) Test_CheckConflicts_Warns_WhenAddressIsUsed() {
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/api/v2"}, Mode: "swarm", Port: "8080", ServiceAddress: "10.0.0.1"}
+
+	actual := checkConflicts(sr)
+
+	s.Equal(CheckWarn, actual.Status)
+	s.Equal([]string{"The address 10.0.0.1:8080 is already used by the service other-service"}, actual.Messages)
+}
+
+// checkRegexps
+
+func (s SimulateTestSuite) Test_CheckRegexps_Warns_WhenExpressionsDoNotCompile() {
+	sr := ServiceReconfigure{PathType: "path_reg", ServicePath: []string{"^/api/(v1|v2)", "^/api/(v1"}, ReqRepSearch: "^(?!/health)"}
+
+	actual := checkRegexps(sr)
+
+	s.Equal(CheckWarn, actual.Status)
+	s.Len(actual.Messages, 2)
+}
+
+func (s SimulateTestSuite) Test_CheckRegexps_IgnoresPaths_WhenPathTypeIsNotRegexp() {
+	sr := ServiceReconfigure{PathType: "path_beg", ServicePath: []string{"/api/(v1"}}
+
+	s.Equal(Check{Name: "regexps", Status: CheckPass}, checkRegexps(sr))
+}
+
+// checkCertCoverage
+
+func (s SimulateTestSuite) Test_CheckCertCoverage_Passes_WhenDomainsAreCovered() {
+	sr := ServiceReconfigure{ServiceDomain: []string{"my-domain.com", "api.other-domain.com"}}
+	certs := map[string]string{"my-cert.pem": s.getCert("my-domain.com"), "other.pem": s.getCert("*.other-domain.com")}
+
+	s.Equal(Check{Name: "certs", Status: CheckPass}, checkCertCoverage(sr, certs))
+}
+
+func (s SimulateTestSuite) Test_CheckCertCoverage_UsesServiceCert() {
+	sr := ServiceReconfigure{ServiceDomain: []string{"my-domain.com"}, ServiceCert: s.getCert("my-domain.com")}
+
+	s.Equal(CheckPass, checkCertCoverage(sr, map[string]string{}).Status)
+}
+
+func (s SimulateTestSuite) Test_CheckCertCoverage_Warns_WhenDomainsAreNotCovered() {
+	sr := ServiceReconfigure{ServiceDomain: []string{"my-domain.com", "not-covered.com"}}
+	certs := map[string]string{"my-cert.pem": s.getCert("my-domain.com")}
+
+	actual := checkCertCoverage(sr, certs)
+
+	s.Equal(CheckWarn, actual.Status)
+	s.Equal([]string{"The domain not-covered.com is not covered by any certificate"}, actual.Messages)
+}
+
+func (s SimulateTestSuite) Test_CheckCertCoverage_Warns_WhenThereAreNoCerts() {
+	sr := ServiceReconfigure{ServiceDomain: []string{"my-domain.com"}}
+
+	s.Equal(CheckWarn, checkCertCoverage(sr, map[string]string{}).Status)
+}
+
+// checkConfig
+
+func (s SimulateTestSuite) Test_CheckConfig_ChecksCurrentConfigsWithCandidate() {
+	files := map[string]string{}
+	checkHaProxyConfig = func(templatesPath string) error {
+		infos, _ := ioutil.ReadDir(templatesPath)
+		for _, info := range infos {
+			content, _ := ioutil.ReadFile(templatesPath + "/" + info.Name())
+			files[info.Name()] = string(content)
+		}
+		return nil
+	}
+	base := BaseReconfigure{TemplatesPath: s.dir}
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/api/v2"}, Mode: "swarm", Port: "1234"}
+	front, back, _ := NewReconfigure(base, sr).GetTemplates(sr)
+
+	actual := checkConfig(base, sr)
+
+	s.Equal(Check{Name: "config", Status: CheckPass}, actual)
+	s.Equal(map[string]string{
+		"haproxy.tmpl":         "current haproxy.tmpl",
+		"other-service-fe.cfg": "current other-service-fe.cfg",
+		"other-service-be.cfg": "current other-service-be.cfg",
+		"my-service-fe.cfg":    front,
+		"my-service-be.cfg":    back,
+	}, files)
+	_, err := os.Stat(s.dir + "/my-service-fe.cfg")
+	s.NoError(err)
+}
+
+func (s SimulateTestSuite) Test_CheckConfig_Fails_WhenHaProxyCheckFails() {
+	checkHaProxyConfig = func(templatesPath string) error {
+		return fmt.Errorf("The configuration is invalid")
+	}
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/api/v2"}, Mode: "swarm", Port: "1234"}
+
+	actual := checkConfig(BaseReconfigure{TemplatesPath: s.dir}, sr)
+
+	s.Equal(Check{Name: "config", Status: CheckFail, Messages: []string{"The configuration is invalid"}}, actual)
+}
+
+func (s SimulateTestSuite) Test_CheckConfig_Warns_WhenModeIsNotSwarm() {
+	called := false
+	checkHaProxyConfig = func(templatesPath string) error {
+		called = true
+		return nil
+	}
+
+	actual := checkConfig(BaseReconfigure{TemplatesPath: s.dir}, ServiceReconfigure{ServiceName: "my-service"})
+
+	s.Equal(CheckWarn, actual.Status)
+	s.False(called)
+}
+
+// Simulate
+
+func (s SimulateTestSuite) Test_Simulate_ReturnsAllChecks() {
+	checkHaProxyConfig = func(templatesPath string) error {
+		return nil
+	}
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/api/v2"}, Mode: "swarm", Port: "1234"}
+
+	actual := Simulate(BaseReconfigure{TemplatesPath: s.dir}, sr, map[string]string{})
+
+	names := []string{}
+	for _, check := range actual {
+		names = append(names, check.Name)
+		s.Equal(CheckPass, check.Status, check.Name)
+	}
+	s.Equal([]string{"conflicts", "regexps", "certs", "config"}, names)
+}
+
+// Suite
+
+func TestSimulateUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(SimulateTestSuite))
+}
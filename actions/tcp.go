@@ -0,0 +1,185 @@
+package actions
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"../registry"
+)
+
+// TcpDestination exposes a port of the service through a TCP frontend of the proxy bound to SrcPort.
+type TcpDestination struct {
+	SrcPort int    `json:"srcPort"`
+	Port    string `json:"port"`
+}
+
+// Indexed queries describe the groups of a service (e.g. reqMode.2=tcp&srcPort.2=9000&port.2=9000). Groups without
+// reqMode are tcp if they have srcPort and http otherwise.
+const (
+	ReqModePrefix       = "reqMode."
+	SrcPortPrefix       = "srcPort."
+	PortPrefix          = "port."
+	ServicePathPrefix   = "servicePath."
+	ServiceDomainPrefix = "serviceDomain."
+)
+
+// decodeDestinations converts the indexed queries into the destinations of the service. An http group sets port and
+// servicePath unless they are sent without an index. Http groups with another port or with their own serviceDomain
+// are additional http destinations. Invalid source ports and unknown modes are decoded as -1 so that the validation
+// can reject them.
+func decodeDestinations(sr *ServiceReconfigure, query url.Values) {
+	indexes := []int{}
+	for key := range query {
+		for _, prefix := range []string{ReqModePrefix, SrcPortPrefix, PortPrefix, ServicePathPrefix, ServiceDomainPrefix} {
+			if index, err := strconv.Atoi(strings.TrimPrefix(key, prefix)); strings.HasPrefix(key, prefix) && err == nil {
+				if !containsInt(indexes, index) {
+					indexes = append(indexes, index)
+				}
+			}
+		}
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		suffix := strconv.Itoa(index)
+		mode := strings.ToLower(query.Get(ReqModePrefix + suffix))
+		port := query.Get(PortPrefix + suffix)
+		if mode == "tcp" || (len(mode) == 0 && len(query.Get(SrcPortPrefix+suffix)) > 0) {
+			srcPort, err := strconv.Atoi(query.Get(SrcPortPrefix + suffix))
+			if err != nil || srcPort < 1 || srcPort > 65535 {
+				srcPort = -1
+			}
+			sr.TcpDestinations = append(sr.TcpDestinations, TcpDestination{SrcPort: srcPort, Port: port})
+		} else if mode == "http" || len(mode) == 0 {
+			paths := query.Get(ServicePathPrefix + suffix)
+			domains := query.Get(ServiceDomainPrefix + suffix)
+			if len(domains) == 0 && (len(sr.Port) == 0 || len(port) == 0 || port == sr.Port) {
+				if len(sr.Port) == 0 {
+					sr.Port = port
+				}
+				if len(paths) > 0 {
+					sr.ServicePath = append(sr.ServicePath, strings.Split(paths, ",")...)
+				}
+				continue
+			}
+			dest := HttpDestination{Port: port}
+			if len(paths) > 0 {
+				dest.ServicePath = strings.Split(paths, ",")
+			}
+			if len(domains) > 0 {
+				dest.ServiceDomain = strings.Split(domains, ",")
+			}
+			sr.HttpDestinations = append(sr.HttpDestinations, dest)
+		} else {
+			sr.TcpDestinations = append(sr.TcpDestinations, TcpDestination{SrcPort: -1, Port: port})
+		}
+	}
+}
+
+// encodeDestinations converts the TCP and the additional http destinations into indexed queries. The first http group
+// is encoded through the port and servicePath queries.
+func encodeDestinations(sr ServiceReconfigure, query url.Values) {
+	for i, dest := range sr.TcpDestinations {
+		suffix := strconv.Itoa(i + 1)
+		query.Set(ReqModePrefix+suffix, "tcp")
+		query.Set(SrcPortPrefix+suffix, strconv.Itoa(dest.SrcPort))
+		query.Set(PortPrefix+suffix, dest.Port)
+	}
+	for i, dest := range sr.HttpDestinations {
+		suffix := strconv.Itoa(len(sr.TcpDestinations) + i + 1)
+		query.Set(ReqModePrefix+suffix, "http")
+		if len(dest.Port) > 0 {
+			query.Set(PortPrefix+suffix, dest.Port)
+		}
+		if len(dest.ServicePath) > 0 {
+			query.Set(ServicePathPrefix+suffix, strings.Join(dest.ServicePath, ","))
+		}
+		if len(dest.ServiceDomain) > 0 {
+			query.Set(ServiceDomainPrefix+suffix, strings.Join(dest.ServiceDomain, ","))
+		}
+	}
+}
+
+// IsTcp tells whether the whole service is proxied in the tcp mode (reqMode=tcp). Such services have no http part.
+func (sr ServiceReconfigure) IsTcp() bool {
+	return strings.EqualFold(sr.ReqMode, "tcp")
+}
+
+// GetTcpDestinations returns the tcp groups of the service together with the destination of the service itself when
+// it is in the tcp mode. The source port of that destination is srcPort and is -1 if it is not a port.
+func (sr ServiceReconfigure) GetTcpDestinations() []TcpDestination {
+	if !sr.IsTcp() {
+		return sr.TcpDestinations
+	}
+	srcPort, err := strconv.Atoi(sr.SrcPort)
+	if err != nil || srcPort < 1 || srcPort > 65535 || strconv.Itoa(srcPort) != sr.SrcPort {
+		srcPort = -1
+	}
+	return append([]TcpDestination{{SrcPort: srcPort, Port: sr.Port}}, sr.TcpDestinations...)
+}
+
+// FormatTcpDestinations converts the destinations into a comma-separated list of srcPort=port pairs.
+func FormatTcpDestinations(dests []TcpDestination) string {
+	pairs := map[string]string{}
+	for _, dest := range dests {
+		pairs[strconv.Itoa(dest.SrcPort)] = dest.Port
+	}
+	return registry.FormatColorAddresses(pairs)
+}
+
+// ParseTcpDestinations is the inverse of FormatTcpDestinations. Destinations are sorted by their source ports.
+func ParseTcpDestinations(value string) []TcpDestination {
+	var dests []TcpDestination
+	for srcPort, port := range registry.ParseColorAddresses(value) {
+		if value, err := strconv.Atoi(srcPort); err == nil {
+			dests = append(dests, TcpDestination{SrcPort: value, Port: port})
+		}
+	}
+	sort.Slice(dests, func(i, j int) bool { return dests[i].SrcPort < dests[j].SrcPort })
+	return dests
+}
+
+// getTcpTemplate returns a TCP frontend bound to the source port and the backend it forwards connections to for each
+// TCP destination of the service. The frontends are dedicated to the service so they use its client timeout.
+// Services in the tcp mode apply their source addresses, backup server and extra lines to all the destinations.
+func (m *Reconfigure) getTcpTemplate(sr *ServiceReconfigure) string {
+	if len(sr.GetTcpDestinations()) == 0 {
+		return ""
+	}
+	front, back := "", ""
+	if sr.IsTcp() {
+		if len(sr.AllowedSourceIPs) > 0 {
+			front += `
+    tcp-request connection reject if !{ src{{range $.AllowedSourceIPs}} {{.}}{{end}} }`
+		}
+		front += `{{range $.GetFrontendExtraLines}}
+    {{.}}{{end}}`
+		if len(sr.BackupHostname) > 0 {
+			back += `
+    server backup-{{$.ServiceName}} {{$.BackupHostname}}:{{if $.BackupPort}}{{$.BackupPort}}{{else}}{{.Port}}{{end}} backup`
+		}
+		back += `{{range $.GetBackendExtraLines}}
+    {{.}}{{end}}`
+	}
+	return `{{range .GetTcpDestinations}}
+
+frontend tcp_{{.SrcPort}}
+    bind *:{{.SrcPort}}
+    mode tcp{{if $.TimeoutClient}}
+    timeout client {{$.TimeoutClient}}s{{end}}` + front + `
+    default_backend {{$.AclName}}-be{{.SrcPort}}-tcp
+
+backend {{$.AclName}}-be{{.SrcPort}}-tcp
+    mode tcp
+    server {{$.ServiceName}} {{$.Host}}:{{.Port}}` + back + `{{end}}`
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
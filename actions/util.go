@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"../registry"
 	"io/ioutil"
+	"os"
 )
 
 type Executable interface {
@@ -17,7 +18,11 @@ func isSwarm(mode string) bool {
 var lookupHost = net.LookupHost
 var logPrintf = log.Printf
 var httpGet = http.Get
-var registryInstance registry.Registrarable = registry.Consul{}
+var registryInstance registry.Registrarable = registry.NewMetricsRegistry(registry.Consul{})
+
+// ListenerMetrics are the metrics of the requests sent to the Swarm listener.
+var ListenerMetrics = registry.NewOperationMetrics("docker_flow_proxy_listener", "Swarm listener")
 var writeFeTemplate = ioutil.WriteFile
 var writeBeTemplate = ioutil.WriteFile
-var readTemplateFile = ioutil.ReadFile
\ No newline at end of file
+var readTemplateFile = ioutil.ReadFile
+var removeFeTemplate = os.Remove
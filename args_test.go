@@ -4,6 +4,8 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
 	"testing"
@@ -340,6 +342,35 @@ func (s ArgsTestSuite) Test_Parse_ServerDefaultsToEnvVars() {
 	}
 }
 
+func (s ArgsTestSuite) Test_Parse_ParsesServerCommand() {
+	consulAddressOrig := os.Getenv("CONSUL_ADDRESS")
+	defer func() { os.Setenv("CONSUL_ADDRESS", consulAddressOrig) }()
+	os.Unsetenv("CONSUL_ADDRESS")
+	os.Args = []string{"myProgram", "server", "--mode", "swarm"}
+
+	err := Args{}.Parse()
+
+	s.NoError(err)
+}
+
+func (s ArgsTestSuite) Test_Parse_ParsesServerForwardedProto() {
+	defer func() { serverImpl.ForwardedProtoDefault = false }()
+	os.Args = []string{"myProgram", "server", "--forwarded-proto"}
+
+	Args{}.Parse()
+
+	s.True(serverImpl.ForwardedProtoDefault)
+}
+
+func (s ArgsTestSuite) Test_Parse_ParsesServerCheckXForwardedProto() {
+	defer func() { serverImpl.CheckXForwardedProto = false }()
+	os.Args = []string{"myProgram", "server", "--check-x-forwarded-proto"}
+
+	Args{}.Parse()
+
+	s.True(serverImpl.CheckXForwardedProto)
+}
+
 // Suite
 
 func TestArgsUnitTestSuite(t *testing.T) {
@@ -347,10 +378,14 @@ func TestArgsUnitTestSuite(t *testing.T) {
 	registryInstanceOrig := registryInstance
 	defer func() { registryInstance = registryInstanceOrig }()
 	registryInstance = mockObj
-	logPrintf = func(format string, v ...interface{}) {}
+	log.SetOutput(ioutil.Discard)
 	proxyOrig := proxy.Instance
 	defer func() { proxy.Instance = proxyOrig }()
 	proxy.Instance = getProxyMock("")
+	// The scheduler would keep reading the clock that other suites replace
+	startMaintenanceSchedulerOrig := startMaintenanceScheduler
+	defer func() { startMaintenanceScheduler = startMaintenanceSchedulerOrig }()
+	startMaintenanceScheduler = func(m *Serve) {}
 	suite.Run(t, new(ArgsTestSuite))
 }
 
@@ -457,6 +492,10 @@ func (m *ProxyMock) AddCert(certName string) {
 	m.Called(certName)
 }
 
+func (m *ProxyMock) RemoveCert(certName string) {
+	m.Called(certName)
+}
+
 func (m *ProxyMock) GetCerts() map[string]string {
 	params := m.Called()
 	return params.Get(0).(map[string]string)
@@ -479,6 +518,9 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "AddCert" {
 		mockObj.On("AddCert", mock.Anything).Return(nil)
 	}
+	if skipMethod != "RemoveCert" {
+		mockObj.On("RemoveCert", mock.Anything).Return(nil)
+	}
 	if skipMethod != "GetCerts" {
 		mockObj.On("GetCerts").Return(map[string]string{})
 	}
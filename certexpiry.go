@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"./server"
+)
+
+const (
+	certValid = iota
+	certExpiring
+	certExpired
+)
+
+// CertExpiryStatus describes the certificate that expires first and the number of certificates that expire within
+// the warning period or already expired.
+type CertExpiryStatus struct {
+	SoonestCert   string
+	SoonestExpiry time.Time
+	Expiring      int
+}
+
+// CertAlert is sent to the alert webhook when a certificate starts expiring or expires.
+type CertAlert struct {
+	Type     string   `json:"type"`
+	Status   string   `json:"status"`
+	Cert     string   `json:"cert"`
+	Domains  []string `json:"domains"`
+	NotAfter string   `json:"notAfter"`
+}
+
+type certExpiryEntry struct {
+	name     string
+	notAfter time.Time
+	domains  []string
+}
+
+// certExpiryChecker remembers the state each certificate was last alerted for so that every state is alerted only
+// once. Renewed certificates start over since their expiry differs. The warnings are written through logPrintf.
+type certExpiryChecker struct {
+	mu        sync.Mutex
+	warning   time.Duration
+	webhook   string
+	alerted   map[string]int
+	logPrintf func(format string, v ...interface{})
+}
+
+var certExpiry = &certExpiryChecker{warning: 30 * 24 * time.Hour, alerted: map[string]int{}, logPrintf: log.Printf}
+
+// httpPost sends alerts and acknowledgments to the Swarm listener. The timeout keeps a slow webhook from blocking the
+// checks.
+var httpPost = (&http.Client{Timeout: 10 * time.Second}).Post
+
+// getCertExpiryStatus returns the expiry status of the stored certificates or nil if there are none.
+var getCertExpiryStatus = func() *CertExpiryStatus {
+	return certExpiry.Status()
+}
+
+// startCertExpiryCheck checks the certificates immediately and every interval afterwards.
+var startCertExpiryCheck = func(interval, warning time.Duration, webhook string) {
+	certExpiry.mu.Lock()
+	certExpiry.warning = warning
+	certExpiry.webhook = webhook
+	certExpiry.mu.Unlock()
+	go func() {
+		certExpiry.Check()
+		for range time.Tick(interval) {
+			certExpiry.Check()
+		}
+	}()
+}
+
+// Check logs a warning and sends an alert for each certificate that started expiring or expired since the
+// previous check.
+func (c *certExpiryChecker) Check() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := timeNow()
+	seen := map[string]bool{}
+	for _, e := range getCertExpiries(getCerts()) {
+		key := fmt.Sprintf("%s/%d", e.name, e.notAfter.Unix())
+		seen[key] = true
+		state := c.getState(e, now)
+		if state > c.alerted[key] {
+			c.alert(e, state)
+		}
+		c.alerted[key] = state
+	}
+	for key := range c.alerted {
+		if !seen[key] {
+			delete(c.alerted, key)
+		}
+	}
+}
+
+// Status returns the certificate that expires first and the number of certificates that need to be renewed.
+func (c *certExpiryChecker) Status() *CertExpiryStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := getCertExpiries(getCerts())
+	if len(entries) == 0 {
+		return nil
+	}
+	now := timeNow()
+	status := CertExpiryStatus{}
+	for _, e := range entries {
+		if len(status.SoonestCert) == 0 || e.notAfter.Before(status.SoonestExpiry) {
+			status.SoonestCert, status.SoonestExpiry = e.name, e.notAfter
+		}
+		if c.getState(e, now) != certValid {
+			status.Expiring++
+		}
+	}
+	return &status
+}
+
+func (c *certExpiryChecker) getState(e certExpiryEntry, now time.Time) int {
+	if !now.Before(e.notAfter) {
+		return certExpired
+	} else if e.notAfter.Sub(now) <= c.warning {
+		return certExpiring
+	}
+	return certValid
+}
+
+func (c *certExpiryChecker) alert(e certExpiryEntry, state int) {
+	status := "expiring"
+	msg := "WARNING: The certificate %s (%s) expires on %s"
+	if state == certExpired {
+		status = "expired"
+		msg = "WARNING: The certificate %s (%s) expired on %s"
+	}
+	notAfter := e.notAfter.UTC().Format(time.RFC3339)
+	c.logPrintf(msg, e.name, strings.Join(e.domains, ", "), notAfter)
+	if len(c.webhook) == 0 {
+		return
+	}
+	js, _ := json.Marshal(CertAlert{Type: "cert_expiry", Status: status, Cert: e.name, Domains: e.domains, NotAfter: notAfter})
+	resp, err := httpPost(c.webhook, "application/json", bytes.NewReader(js))
+	if err != nil {
+		c.logPrintf("Could not send the alert to %s\n%s", c.webhook, err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		c.logPrintf("The alert webhook %s responded with the status %d", c.webhook, resp.StatusCode)
+	}
+}
+
+// certsExpiring lists the certificates that expire within the duration set through the within query, or
+// CERT_EXPIRY_WARNING if it is missing, starting with the one that expires first. Expired certificates are listed too.
+func (m *Serve) certsExpiring(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	within := m.CertExpiryWarning
+	if value := req.URL.Query().Get("within"); len(value) > 0 {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration < 0 {
+			js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: "The within query must be a duration (e.g. 720h)"})
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(js)
+			return
+		}
+		within = duration
+	}
+	limit := timeNow().Add(within)
+	certs := []server.Cert{}
+	for name, content := range getCerts() {
+		if cert := server.ParseCert(name, content); cert.NotAfter != nil && !cert.NotAfter.After(limit) {
+			certs = append(certs, cert)
+		}
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		if certs[i].NotAfter.Equal(*certs[j].NotAfter) {
+			return certs[i].ProxyServiceName < certs[j].ProxyServiceName
+		}
+		return certs[i].NotAfter.Before(*certs[j].NotAfter)
+	})
+	msg := fmt.Sprintf("%d certificates expire within %s", len(certs), within)
+	js, _ := json.Marshal(server.CertResponse{Status: "OK", Message: msg, Certs: certs})
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// getCertExpiries returns the expiry of the first certificate of each PEM file sorted by the file names. Files
+// without a valid certificate are skipped. The certificates are parsed the same way they are listed.
+func getCertExpiries(certs map[string]string) []certExpiryEntry {
+	entries := []certExpiryEntry{}
+	for name, content := range certs {
+		cert := server.ParseCert(name, content)
+		if cert.NotAfter == nil {
+			continue
+		}
+		domains := cert.DNSNames
+		if len(domains) == 0 {
+			domains = []string{cert.CommonName}
+		}
+		entries = append(entries, certExpiryEntry{name: name, notAfter: *cert.NotAfter, domains: domains})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	return entries
+}
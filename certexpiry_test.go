@@ -0,0 +1,251 @@
+// +build !integration
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"./server"
+)
+
+type CertExpiryTestSuite struct {
+	suite.Suite
+	now     time.Time
+	certs   map[string]string
+	alerts  []CertAlert
+	checker *certExpiryChecker
+}
+
+func (s *CertExpiryTestSuite) SetupTest() {
+	s.now = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.certs = map[string]string{}
+	s.alerts = []CertAlert{}
+	s.checker = &certExpiryChecker{
+		warning:   30 * 24 * time.Hour,
+		webhook:   "http://alerts.com/hook",
+		alerted:   map[string]int{},
+		logPrintf: func(format string, v ...interface{}) {},
+	}
+	timeNow = func() time.Time {
+		return s.now
+	}
+	getCerts = func() map[string]string {
+		return s.certs
+	}
+	httpPost = func(url, contentType string, body io.Reader) (*http.Response, error) {
+		alert := CertAlert{}
+		content, _ := ioutil.ReadAll(body)
+		json.Unmarshal(content, &alert)
+		s.alerts = append(s.alerts, alert)
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}
+}
+
+func (s *CertExpiryTestSuite) getCert(domain string, notAfter time.Time) string {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// Check
+
+func (s *CertExpiryTestSuite) Test_Check_AlertsCertsExpiringWithinWarningPeriod() {
+	s.certs["valid.pem"] = s.getCert("valid.com", s.now.Add(60*24*time.Hour))
+	s.certs["expiring.pem"] = s.getCert("expiring.com", s.now.Add(10*24*time.Hour))
+
+	s.checker.Check()
+
+	s.Equal([]CertAlert{{
+		Type:     "cert_expiry",
+		Status:   "expiring",
+		Cert:     "expiring.pem",
+		Domains:  []string{"expiring.com"},
+		NotAfter: "2017-01-11T00:00:00Z",
+	}}, s.alerts)
+}
+
+func (s *CertExpiryTestSuite) Test_Check_AlertsExpiredCerts() {
+	s.certs["expired.pem"] = s.getCert("expired.com", s.now.Add(-time.Hour))
+
+	s.checker.Check()
+
+	s.Len(s.alerts, 1)
+	s.Equal("expired", s.alerts[0].Status)
+}
+
+func (s *CertExpiryTestSuite) Test_Check_AlertsOncePerThresholdCrossing() {
+	s.certs["my-cert.pem"] = s.getCert("my-domain.com", s.now.Add(40*24*time.Hour))
+
+	statuses := func() []string {
+		actual := []string{}
+		for _, alert := range s.alerts {
+			actual = append(actual, alert.Status)
+		}
+		return actual
+	}
+	s.checker.Check()
+	s.Equal([]string{}, statuses())
+	s.now = s.now.Add(15 * 24 * time.Hour)
+	s.checker.Check()
+	s.now = s.now.Add(12 * time.Hour)
+	s.checker.Check()
+	s.Equal([]string{"expiring"}, statuses())
+	s.now = s.now.Add(30 * 24 * time.Hour)
+	s.checker.Check()
+	s.checker.Check()
+	s.Equal([]string{"expiring", "expired"}, statuses())
+}
+
+func (s *CertExpiryTestSuite) Test_Check_AlertsAgain_WhenRenewedCertStartsExpiring() {
+	s.certs["my-cert.pem"] = s.getCert("my-domain.com", s.now.Add(10*24*time.Hour))
+	s.checker.Check()
+	s.certs["my-cert.pem"] = s.getCert("my-domain.com", s.now.Add(100*24*time.Hour))
+	s.checker.Check()
+	s.now = s.now.Add(80 * 24 * time.Hour)
+
+	s.checker.Check()
+
+	s.Len(s.alerts, 2)
+	s.Equal(map[string]int{fmt.Sprintf("my-cert.pem/%d", s.now.Add(20*24*time.Hour).Unix()): certExpiring}, s.checker.alerted)
+}
+
+func (s *CertExpiryTestSuite) Test_Check_OnlyLogs_WhenWebhookIsNotSet() {
+	s.checker.webhook = ""
+	s.certs["expired.pem"] = s.getCert("expired.com", s.now.Add(-time.Hour))
+	logs := []string{}
+	s.checker.logPrintf = func(format string, v ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, v...))
+	}
+
+	s.checker.Check()
+
+	s.Empty(s.alerts)
+	s.Equal([]string{"WARNING: The certificate expired.pem (expired.com) expired on 2016-12-31T23:00:00Z"}, logs)
+}
+
+func (s *CertExpiryTestSuite) Test_Check_SkipsInvalidCerts() {
+	s.certs["invalid.pem"] = "-----BEGIN CERTIFICATE-----\nabc\n-----END CERTIFICATE-----"
+
+	s.checker.Check()
+
+	s.Empty(s.alerts)
+	s.Empty(s.checker.alerted)
+}
+
+// Status
+
+func (s *CertExpiryTestSuite) Test_Status_ReturnsSoonestExpiryAndExpiringCount() {
+	s.certs["valid.pem"] = s.getCert("valid.com", s.now.Add(60*24*time.Hour))
+	s.certs["expiring.pem"] = s.getCert("expiring.com", s.now.Add(10*24*time.Hour))
+	s.certs["expired.pem"] = s.getCert("expired.com", s.now.Add(-time.Hour))
+
+	actual := s.checker.Status()
+
+	s.Equal(&CertExpiryStatus{SoonestCert: "expired.pem", SoonestExpiry: s.now.Add(-time.Hour), Expiring: 2}, actual)
+}
+
+func (s *CertExpiryTestSuite) Test_Status_ReturnsNil_WhenThereAreNoCerts() {
+	s.Nil(s.checker.Status())
+}
+
+// certsExpiring
+
+func (s *CertExpiryTestSuite) getExpiring(srv *Serve, url string) (*httptest.ResponseRecorder, server.CertResponse) {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", url, nil)
+	srv.ServeHTTP(rw, req)
+	actual := server.CertResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	return rw, actual
+}
+
+func (s *CertExpiryTestSuite) Test_CertsExpiring_ReturnsCertsExpiringWithinWarningPeriod() {
+	s.certs["valid.pem"] = s.getCert("valid.com", s.now.Add(60*24*time.Hour))
+	s.certs["expiring.pem"] = s.getCert("expiring.com", s.now.Add(10*24*time.Hour))
+	s.certs["expired.pem"] = s.getCert("expired.com", s.now.Add(-time.Hour))
+	s.certs["invalid.pem"] = "THIS IS NOT A CERTIFICATE"
+
+	rw, actual := s.getExpiring(&Serve{CertExpiryWarning: 30 * 24 * time.Hour}, "http://127.0.0.1:8080/v1/docker-flow-proxy/certs/expiring")
+
+	s.Equal(200, rw.Code)
+	s.Equal("application/json", rw.Header().Get("Content-Type"))
+	s.Equal("OK", actual.Status)
+	s.Len(actual.Certs, 2)
+	s.Equal("expired.pem", actual.Certs[0].ProxyServiceName)
+	s.Equal("expiring.pem", actual.Certs[1].ProxyServiceName)
+	s.Equal([]string{"expiring.com"}, actual.Certs[1].DNSNames)
+	s.Equal(s.now.Add(10*24*time.Hour), actual.Certs[1].NotAfter.UTC())
+}
+
+func (s *CertExpiryTestSuite) Test_CertsExpiring_UsesWithinQuery() {
+	s.certs["valid.pem"] = s.getCert("valid.com", s.now.Add(60*24*time.Hour))
+	s.certs["expiring.pem"] = s.getCert("expiring.com", s.now.Add(10*24*time.Hour))
+
+	_, actual := s.getExpiring(&Serve{CertExpiryWarning: 30 * 24 * time.Hour}, "http://127.0.0.1:8080/v1/docker-flow-proxy/certs/expiring?within=1440h")
+
+	s.Len(actual.Certs, 2)
+
+	_, actual = s.getExpiring(&Serve{CertExpiryWarning: 30 * 24 * time.Hour}, "http://127.0.0.1:8080/v1/docker-flow-proxy/certs/expiring?within=24h")
+
+	s.Empty(actual.Certs)
+}
+
+func (s *CertExpiryTestSuite) Test_CertsExpiring_ReturnsStatus400_WhenWithinIsNotValid() {
+	for _, within := range []string{"soon", "-24h", "30"} {
+		rw, actual := s.getExpiring(&Serve{}, "http://127.0.0.1:8080/v1/docker-flow-proxy/certs/expiring?within="+within)
+
+		s.Equal(400, rw.Code, within)
+		s.Equal("NOK", actual.Status, within)
+	}
+}
+
+func (s *CertExpiryTestSuite) Test_CertsExpiring_ReturnsStatus405_WhenMethodIsNotGet() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://127.0.0.1:8080/v1/docker-flow-proxy/certs/expiring", nil)
+
+	(&Serve{}).ServeHTTP(rw, req)
+
+	s.Equal(405, rw.Code)
+}
+
+// Suite
+
+func TestCertExpiryUnitTestSuite(t *testing.T) {
+	timeNowOrig := timeNow
+	getCertsOrig := getCerts
+	httpPostOrig := httpPost
+	httpWriterSetContentTypeOrig := httpWriterSetContentType
+	defer func() {
+		timeNow = timeNowOrig
+		getCerts = getCertsOrig
+		httpPost = httpPostOrig
+		httpWriterSetContentType = httpWriterSetContentTypeOrig
+	}()
+	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
+		w.Header().Set("Content-Type", value)
+	}
+	suite.Run(t, new(CertExpiryTestSuite))
+}
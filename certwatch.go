@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"./proxy"
+	"./server"
+)
+
+// certsWatchDir is the directory the certificates are stored in and HAProxy loads them from.
+var certsWatchDir = "/certs"
+
+// certsWatchDebounce is the period without changes after which the proxy is reloaded. Tools that renew certificates
+// tend to write the files in several steps.
+var certsWatchDebounce = 2 * time.Second
+
+// certsWatcher reloads the proxy when the certificates are changed outside of the API. The state of the directory is
+// recorded after each reload so that the changes the proxy already loaded, including the ones made through the API,
+// do not cause another reload.
+type certsWatcher struct {
+	mu       sync.Mutex
+	dir      string
+	changed  map[string]bool
+	timer    *time.Timer
+	snapshot map[string]string
+}
+
+func newCertsWatcher(dir string) *certsWatcher {
+	w := &certsWatcher{dir: dir, changed: map[string]bool{}}
+	w.snapshot = w.readState()
+	return w
+}
+
+// startCertsWatcher watches the directory until the process stops.
+var startCertsWatcher = func(dir string) error {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Could not watch the certificates\n%s", err.Error())
+	}
+	if err := fsWatcher.Add(dir); err != nil {
+		fsWatcher.Close()
+		return fmt.Errorf("Could not watch the certificates in %s\n%s", dir, err.Error())
+	}
+	w := newCertsWatcher(dir)
+	server.CertsReloaded = w.recordState
+	log.Printf("Watching the certificates in %s", dir)
+	go func() {
+		for {
+			select {
+			case event, ok := <-fsWatcher.Events:
+				if !ok {
+					return
+				} else if event.Name == dir && event.Op&fsnotify.Remove == fsnotify.Remove {
+					log.Printf("Stopped watching the certificates since %s was removed", dir)
+					fsWatcher.Close()
+					return
+				}
+				w.change(filepath.Base(event.Name))
+			case err, ok := <-fsWatcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("The certificates watcher failed\n%s", err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+// change schedules the reload. Hidden files are ignored since they are usually temporary files of editors and tools
+// that write certificates atomically. So are the OCSP responses which are updated through the admin socket.
+func (w *certsWatcher) change(name string) {
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, server.OcspExtension) {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.changed[name] = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(certsWatchDebounce, w.flush)
+}
+
+// flush adds the changed certificates to the proxy, or removes the ones that no longer exist, and reloads it unless
+// neither the certificates of the proxy nor their content changed since the last reload.
+func (w *certsWatcher) flush() {
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	changed := w.changed
+	w.changed = map[string]bool{}
+	w.mu.Unlock()
+	state := w.readState()
+	certs := proxy.Instance.GetCerts()
+	updated := false
+	for name := range changed {
+		_, exists := state[name]
+		_, loaded := certs[name]
+		if exists && !loaded {
+			proxy.Instance.AddCert(name)
+			updated = true
+		} else if !exists && loaded {
+			proxy.Instance.RemoveCert(name)
+			updated = true
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !updated && w.equalsSnapshot(state) {
+		return
+	}
+	log.Printf("The certificates in %s changed. Reloading the proxy.", w.dir)
+	if err := proxy.Instance.CreateConfigFromTemplates(); err != nil {
+		log.Printf(err.Error())
+		return
+	}
+	if err := proxy.Instance.Reload(); err != nil {
+		log.Printf(err.Error())
+		return
+	}
+	w.snapshot = state
+}
+
+// recordState marks the current state of the directory as loaded by the proxy.
+func (w *certsWatcher) recordState() {
+	state := w.readState()
+	w.mu.Lock()
+	w.snapshot = state
+	w.mu.Unlock()
+}
+
+func (w *certsWatcher) equalsSnapshot(state map[string]string) bool {
+	if len(state) != len(w.snapshot) {
+		return false
+	}
+	for name, hash := range state {
+		if w.snapshot[name] != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// readState returns the hashes of the certificates in the directory mapped by their names. Directories, including
+// the one with the parts of certificates, and OCSP responses are skipped.
+func (w *certsWatcher) readState() map[string]string {
+	state := map[string]string{}
+	files, _ := ioutil.ReadDir(w.dir)
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") || strings.HasSuffix(file.Name(), server.OcspExtension) {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(w.dir, file.Name()))
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(content)
+		state[file.Name()] = hex.EncodeToString(sum[:])
+	}
+	return state
+}
@@ -0,0 +1,182 @@
+// +build !integration
+
+package main
+
+import (
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	haproxy "./proxy"
+	"./server"
+)
+
+type CertWatchTestSuite struct {
+	suite.Suite
+	dir       string
+	proxyMock *ProxyMock
+	reloaded  chan bool
+}
+
+func (s *CertWatchTestSuite) SetupTest() {
+	s.dir, _ = ioutil.TempDir("", "certs")
+	s.reloaded = make(chan bool, 10)
+	s.setProxyMock(map[string]string{})
+	certsWatchDebounce = 20 * time.Millisecond
+}
+
+func (s *CertWatchTestSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+	server.CertsReloaded = func() {}
+}
+
+func (s *CertWatchTestSuite) setProxyMock(certs map[string]string) {
+	s.proxyMock = new(ProxyMock)
+	s.proxyMock.On("CreateConfigFromTemplates").Return(nil)
+	s.proxyMock.On("AddCert", mock.Anything).Return(nil)
+	s.proxyMock.On("RemoveCert", mock.Anything).Return(nil)
+	s.proxyMock.On("GetCerts").Return(certs)
+	s.proxyMock.On("Reload").Return(nil).Run(func(args mock.Arguments) {
+		s.reloaded <- true
+	})
+	haproxy.Instance = s.proxyMock
+}
+
+func (s *CertWatchTestSuite) writeCert(name, content string) {
+	ioutil.WriteFile(filepath.Join(s.dir, name), []byte(content), 0600)
+}
+
+func (s *CertWatchTestSuite) waitForReload() bool {
+	select {
+	case <-s.reloaded:
+		return true
+	case <-time.After(500 * time.Millisecond):
+		return false
+	}
+}
+
+// flush
+
+func (s *CertWatchTestSuite) Test_Flush_AddsNewCertAndReloads() {
+	w := newCertsWatcher(s.dir)
+	s.writeCert("my-cert.pem", "content")
+
+	w.change("my-cert.pem")
+	w.flush()
+
+	s.proxyMock.AssertCalled(s.T(), "AddCert", "my-cert.pem")
+	s.proxyMock.AssertCalled(s.T(), "CreateConfigFromTemplates")
+	s.proxyMock.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
+func (s *CertWatchTestSuite) Test_Flush_RemovesDeletedCertAndReloads() {
+	s.setProxyMock(map[string]string{"my-cert.pem": "content"})
+	s.writeCert("my-cert.pem", "content")
+	w := newCertsWatcher(s.dir)
+	os.Remove(filepath.Join(s.dir, "my-cert.pem"))
+
+	w.change("my-cert.pem")
+	w.flush()
+
+	s.proxyMock.AssertCalled(s.T(), "RemoveCert", "my-cert.pem")
+	s.proxyMock.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
+func (s *CertWatchTestSuite) Test_Flush_ReloadsWhenContentOfCertChanges() {
+	s.setProxyMock(map[string]string{"my-cert.pem": "content"})
+	s.writeCert("my-cert.pem", "content")
+	w := newCertsWatcher(s.dir)
+	s.writeCert("my-cert.pem", "renewed content")
+
+	w.change("my-cert.pem")
+	w.flush()
+
+	s.proxyMock.AssertNotCalled(s.T(), "AddCert", mock.Anything)
+	s.proxyMock.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
+func (s *CertWatchTestSuite) Test_Flush_DoesNotReload_WhenProxyWasReloadedWithChanges() {
+	s.setProxyMock(map[string]string{"my-cert.pem": "content"})
+	w := newCertsWatcher(s.dir)
+	s.writeCert("my-cert.pem", "content")
+	// The API stores the certificate and reloads the proxy
+	w.recordState()
+
+	w.change("my-cert.pem")
+	w.flush()
+
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+// change
+
+func (s *CertWatchTestSuite) Test_Change_ReloadsOnce_WhenChangesArriveWithinDebounce() {
+	w := newCertsWatcher(s.dir)
+	s.writeCert("my-cert.pem", "content")
+	s.writeCert("other-cert.pem", "content")
+
+	w.change("my-cert.pem")
+	w.change("other-cert.pem")
+	w.change("my-cert.pem")
+
+	s.True(s.waitForReload())
+	s.False(s.waitForReload())
+	s.proxyMock.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
+func (s *CertWatchTestSuite) Test_Change_IgnoresHiddenFiles() {
+	w := newCertsWatcher(s.dir)
+	s.writeCert(".my-cert.pem.tmp", "content")
+
+	w.change(".my-cert.pem.tmp")
+
+	s.False(s.waitForReload())
+}
+
+func (s *CertWatchTestSuite) Test_Change_IgnoresOcspResponses() {
+	w := newCertsWatcher(s.dir)
+	s.writeCert("my-cert.pem.ocsp", "content")
+
+	w.change("my-cert.pem.ocsp")
+
+	s.False(s.waitForReload())
+	s.Empty(w.readState())
+}
+
+// startCertsWatcher
+
+func (s *CertWatchTestSuite) Test_StartCertsWatcher_ReloadsWhenFileIsWritten() {
+	err := startCertsWatcher(s.dir)
+
+	s.NoError(err)
+	s.writeCert("my-cert.pem", "content")
+	s.True(s.waitForReload())
+	s.proxyMock.AssertCalled(s.T(), "AddCert", "my-cert.pem")
+	// The watcher stops once the directory is removed
+	os.RemoveAll(s.dir)
+	s.True(s.waitForReload())
+}
+
+func (s *CertWatchTestSuite) Test_StartCertsWatcher_ReturnsError_WhenDirectoryDoesNotExist() {
+	err := startCertsWatcher(filepath.Join(s.dir, "this-dir-does-not-exist"))
+
+	s.Error(err)
+}
+
+// Suite
+
+func TestCertWatchUnitTestSuite(t *testing.T) {
+	proxyOrig := haproxy.Instance
+	debounceOrig := certsWatchDebounce
+	defer func() {
+		haproxy.Instance = proxyOrig
+		certsWatchDebounce = debounceOrig
+	}()
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(CertWatchTestSuite))
+}
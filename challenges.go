@@ -0,0 +1,77 @@
+package main
+
+import (
+	"regexp"
+	"sync"
+	"time"
+)
+
+// acmeChallengePath is the path ACME servers request HTTP-01 challenges from.
+const acmeChallengePath = "/.well-known/acme-challenge/"
+
+// acmeChallengeTTL is the time challenges are kept if the ttl query is not specified.
+const acmeChallengeTTL = time.Hour
+
+// acmeTokenRegexp matches the base64url characters tokens are made of.
+var acmeTokenRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var timeNow = time.Now
+
+// acmeChallengeStore keeps the key authorizations of pending challenges in memory. Expired challenges are purged
+// every time the store is accessed.
+type acmeChallengeStore struct {
+	mu      sync.Mutex
+	entries map[string]acmeChallenge
+}
+
+type acmeChallenge struct {
+	keyAuth string
+	expires time.Time
+}
+
+var acmeChallenges = newAcmeChallengeStore()
+
+func newAcmeChallengeStore() *acmeChallengeStore {
+	return &acmeChallengeStore{entries: map[string]acmeChallenge{}}
+}
+
+// Put stores the key authorization of the token until the ttl expires.
+func (s *acmeChallengeStore) Put(token, keyAuth string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge()
+	s.entries[token] = acmeChallenge{keyAuth: keyAuth, expires: timeNow().Add(ttl)}
+}
+
+// Get returns the key authorization of the token and whether it exists and has not expired.
+func (s *acmeChallengeStore) Get(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge()
+	challenge, ok := s.entries[token]
+	return challenge.keyAuth, ok
+}
+
+// Delete removes the challenge of the token.
+func (s *acmeChallengeStore) Delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, token)
+}
+
+// Len returns the number of challenges that have not expired.
+func (s *acmeChallengeStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge()
+	return len(s.entries)
+}
+
+func (s *acmeChallengeStore) purge() {
+	now := timeNow()
+	for token, challenge := range s.entries {
+		if !now.Before(challenge.expires) {
+			delete(s.entries, token)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// +build !integration
+
+package main
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type ChallengesTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (s *ChallengesTestSuite) SetupTest() {
+	s.now = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time {
+		return s.now
+	}
+}
+
+// acmeChallengeStore
+
+func (s *ChallengesTestSuite) Test_Get_ReturnsStoredKeyAuth() {
+	store := newAcmeChallengeStore()
+	store.Put("my-token", "my-token.thumbprint", time.Minute)
+
+	actual, ok := store.Get("my-token")
+
+	s.True(ok)
+	s.Equal("my-token.thumbprint", actual)
+}
+
+func (s *ChallengesTestSuite) Test_Get_ReturnsFalse_WhenTokenDoesNotExist() {
+	store := newAcmeChallengeStore()
+
+	_, ok := store.Get("my-token")
+
+	s.False(ok)
+}
+
+func (s *ChallengesTestSuite) Test_Get_ReturnsFalse_WhenChallengeExpired() {
+	store := newAcmeChallengeStore()
+	store.Put("my-token", "my-token.thumbprint", time.Minute)
+	s.now = s.now.Add(time.Minute)
+
+	_, ok := store.Get("my-token")
+
+	s.False(ok)
+}
+
+func (s *ChallengesTestSuite) Test_Put_PurgesExpiredChallenges() {
+	store := newAcmeChallengeStore()
+	store.Put("token-1", "token-1.thumbprint", time.Minute)
+	store.Put("token-2", "token-2.thumbprint", time.Hour)
+	s.now = s.now.Add(2 * time.Minute)
+
+	store.Put("token-3", "token-3.thumbprint", time.Minute)
+
+	s.Equal(map[string]acmeChallenge{
+		"token-2": {keyAuth: "token-2.thumbprint", expires: s.now.Add(-2 * time.Minute).Add(time.Hour)},
+		"token-3": {keyAuth: "token-3.thumbprint", expires: s.now.Add(time.Minute)},
+	}, store.entries)
+}
+
+func (s *ChallengesTestSuite) Test_Delete_RemovesChallenge() {
+	store := newAcmeChallengeStore()
+	store.Put("my-token", "my-token.thumbprint", time.Minute)
+
+	store.Delete("my-token")
+
+	s.Equal(0, store.Len())
+}
+
+// Suite
+
+func TestChallengesUnitTestSuite(t *testing.T) {
+	timeNowOrig := timeNow
+	defer func() { timeNow = timeNowOrig }()
+	suite.Run(t, new(ChallengesTestSuite))
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"../actions"
+)
+
+// Client sends requests to the admin API of Docker Flow: Proxy.
+type Client struct {
+	// Address of the proxy (e.g. http://proxy:8080)
+	Address string
+	// Timeout of a single attempt. Zero means no timeout.
+	Timeout time.Duration
+	// Retries is the number of additional attempts made when the proxy cannot be reached or is unavailable.
+	Retries int
+	// RetryInterval is the pause between two attempts.
+	RetryInterval time.Duration
+	// Token is sent as a bearer token when the proxy is configured with API_TOKENS.
+	Token string
+}
+
+// Response is the v1 response of the reconfigure and remove endpoints.
+type Response struct {
+	Status  string
+	Message string
+	actions.ServiceReconfigure
+}
+
+// Service is an entry of the services endpoint. Certificates and passwords are not returned.
+type Service struct {
+	actions.ServiceReconfigure
+	// Applied is false if the service is not configured because its proxyRole differs from the role of the proxy.
+	Applied bool
+}
+
+// RemoveOptions are the optional parameters of the remove request.
+type RemoveOptions struct {
+	AclName    string
+	Distribute bool
+}
+
+func NewClient(address string) *Client {
+	if !strings.HasPrefix(address, "http") {
+		address = fmt.Sprintf("http://%s", address)
+	}
+	return &Client{
+		Address:       strings.TrimRight(address, "/"),
+		Timeout:       30 * time.Second,
+		RetryInterval: time.Second,
+	}
+}
+
+// Reconfigure adds or updates the service in the proxy.
+func (m *Client) Reconfigure(ctx context.Context, sr actions.ServiceReconfigure) (Response, error) {
+	query := actions.EncodeParameters(actions.ReconfigureParameters, sr)
+	return m.sendServiceRequest(ctx, "/v1/docker-flow-proxy/reconfigure", query)
+}
+
+// Remove removes the service from the proxy.
+func (m *Client) Remove(ctx context.Context, serviceName string, opts RemoveOptions) (Response, error) {
+	sr := actions.ServiceReconfigure{
+		ServiceName: serviceName,
+		AclName:     opts.AclName,
+		Distribute:  opts.Distribute,
+	}
+	query := actions.EncodeParameters(actions.RemoveParameters, sr)
+	return m.sendServiceRequest(ctx, "/v1/docker-flow-proxy/remove", query)
+}
+
+// PutCert stores the PEM-encoded certificate under the specified name.
+func (m *Client) PutCert(ctx context.Context, certName string, pem []byte) error {
+	query := url.Values{}
+	query.Set("certName", certName)
+	_, err := m.send(ctx, "PUT", "/v1/docker-flow-proxy/cert", query, pem)
+	return err
+}
+
+// Config returns the current HAProxy configuration.
+func (m *Client) Config(ctx context.Context) (string, error) {
+	body, err := m.send(ctx, "GET", "/v1/docker-flow-proxy/config", url.Values{}, nil)
+	return string(body), err
+}
+
+// Services returns the services the proxy is configured with, followed by the ones of other proxy roles.
+func (m *Client) Services(ctx context.Context) ([]Service, error) {
+	services := []Service{}
+	body, err := m.send(ctx, "GET", "/v1/docker-flow-proxy/services", url.Values{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &services); err != nil {
+		return nil, fmt.Errorf("Could not parse the response from /v1/docker-flow-proxy/services\n%s", err.Error())
+	}
+	return services, nil
+}
+
+func (m *Client) sendServiceRequest(ctx context.Context, path string, query url.Values) (Response, error) {
+	response := Response{}
+	body, err := m.send(ctx, "GET", path, query, nil)
+	if len(body) > 0 {
+		if jsonErr := json.Unmarshal(body, &response); jsonErr != nil && err == nil {
+			err = fmt.Errorf("Could not parse the response from %s\n%s", path, jsonErr.Error())
+		}
+	}
+	return response, err
+}
+
+func (m *Client) send(ctx context.Context, method, path string, query url.Values, body []byte) ([]byte, error) {
+	addr := fmt.Sprintf("%s%s", m.Address, path)
+	if len(query) > 0 {
+		addr = fmt.Sprintf("%s?%s", addr, query.Encode())
+	}
+	var err error
+	for attempt := 0; attempt <= m.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(m.RetryInterval):
+			}
+		}
+		var content []byte
+		var status int
+		content, status, err = m.sendOnce(ctx, method, addr, body)
+		if err == nil && status >= 300 {
+			err = fmt.Errorf("%s responded with the status code %d\n%s", path, status, string(content))
+		}
+		if !m.isRetryable(ctx, status, err) {
+			return content, err
+		}
+	}
+	return nil, err
+}
+
+func (m *Client) sendOnce(ctx context.Context, method, addr string, body []byte) ([]byte, int, error) {
+	if m.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequest(method, addr, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(m.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+m.Token)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	return content, resp.StatusCode, err
+}
+
+// isRetryable returns true when the proxy could not be reached or is temporarily unavailable.
+func (m *Client) isRetryable(ctx context.Context, status int, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if status == 0 {
+		return err != nil
+	}
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
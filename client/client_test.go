@@ -0,0 +1,205 @@
+// +build !integration
+
+package client
+
+import (
+	"context"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"../actions"
+)
+
+type ClientTestSuite struct {
+	suite.Suite
+	requests []*http.Request
+	bodies   []string
+	statuses []int
+	response string
+	server   *httptest.Server
+}
+
+func (s *ClientTestSuite) SetupTest() {
+	s.requests = []*http.Request{}
+	s.bodies = []string{}
+	s.statuses = []int{}
+	s.response = `{"Status":"OK","ServiceName":"my-service"}`
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(body))
+		status := http.StatusOK
+		if len(s.statuses) >= len(s.requests) {
+			status = s.statuses[len(s.requests)-1]
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(s.response))
+	}))
+}
+
+func (s *ClientTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// NewClient
+
+func (s *ClientTestSuite) Test_NewClient_AddsHttp_WhenNotPresent() {
+	c := NewClient("proxy:8080/")
+
+	s.Equal("http://proxy:8080", c.Address)
+}
+
+// Reconfigure
+
+func (s *ClientTestSuite) Test_Reconfigure_SendsEncodedParameters() {
+	sr := actions.ServiceReconfigure{
+		ServiceName:    "my-service",
+		ServicePath:    []string{"/api/v1", "/api/v2"},
+		ServiceCert:    "-----BEGIN CERTIFICATE-----\nabc+/=\n-----END CERTIFICATE-----",
+		Users:          []actions.User{{Username: "user", Password: "pass:word"}},
+		ColorAddresses: map[string]string{"blue": "10.0.0.1"},
+	}
+
+	NewClient(s.server.URL).Reconfigure(context.Background(), sr)
+
+	s.Require().Len(s.requests, 1)
+	s.Equal("/v1/docker-flow-proxy/reconfigure", s.requests[0].URL.Path)
+	s.Equal(sr, actions.DecodeParameters(actions.ReconfigureParameters, s.requests[0].URL.Query()))
+}
+
+func (s *ClientTestSuite) Test_Reconfigure_SendsToken_WhenTokenIsSet() {
+	c := NewClient(s.server.URL)
+	c.Token = "my-token"
+
+	c.Reconfigure(context.Background(), actions.ServiceReconfigure{ServiceName: "my-service"})
+
+	s.Require().Len(s.requests, 1)
+	s.Equal("Bearer my-token", s.requests[0].Header.Get("Authorization"))
+}
+
+func (s *ClientTestSuite) Test_Reconfigure_ReturnsResponse() {
+	actual, err := NewClient(s.server.URL).Reconfigure(context.Background(), actions.ServiceReconfigure{})
+
+	s.NoError(err)
+	s.Equal("OK", actual.Status)
+	s.Equal("my-service", actual.ServiceName)
+}
+
+func (s *ClientTestSuite) Test_Reconfigure_ReturnsError_WhenStatusIsNotOK() {
+	s.statuses = []int{http.StatusBadRequest}
+
+	_, err := NewClient(s.server.URL).Reconfigure(context.Background(), actions.ServiceReconfigure{})
+
+	s.Error(err)
+	s.Len(s.requests, 1)
+}
+
+func (s *ClientTestSuite) Test_Reconfigure_Retries_WhenProxyIsUnavailable() {
+	s.statuses = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+	c := NewClient(s.server.URL)
+	c.Retries = 2
+	c.RetryInterval = time.Millisecond
+
+	_, err := c.Reconfigure(context.Background(), actions.ServiceReconfigure{})
+
+	s.NoError(err)
+	s.Len(s.requests, 3)
+}
+
+func (s *ClientTestSuite) Test_Reconfigure_ReturnsError_WhenRetriesAreExhausted() {
+	s.statuses = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+	c := NewClient(s.server.URL)
+	c.Retries = 1
+	c.RetryInterval = time.Millisecond
+
+	_, err := c.Reconfigure(context.Background(), actions.ServiceReconfigure{})
+
+	s.Error(err)
+	s.Len(s.requests, 2)
+}
+
+func (s *ClientTestSuite) Test_Reconfigure_ReturnsError_WhenTimeoutIsReached() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+	c := NewClient(server.URL)
+	c.Timeout = time.Millisecond
+
+	_, err := c.Reconfigure(context.Background(), actions.ServiceReconfigure{})
+
+	s.Error(err)
+}
+
+// Remove
+
+func (s *ClientTestSuite) Test_Remove_SendsParameters() {
+	NewClient(s.server.URL).Remove(context.Background(), "my-service", RemoveOptions{AclName: "my-acl", Distribute: true})
+
+	s.Require().Len(s.requests, 1)
+	s.Equal("/v1/docker-flow-proxy/remove", s.requests[0].URL.Path)
+	s.Equal(url.Values{"serviceName": {"my-service"}, "aclName": {"my-acl"}, "distribute": {"true"}}, s.requests[0].URL.Query())
+}
+
+// PutCert
+
+func (s *ClientTestSuite) Test_PutCert_SendsCertInBody() {
+	pem := "-----BEGIN CERTIFICATE-----\nabc\n-----END CERTIFICATE-----"
+
+	err := NewClient(s.server.URL).PutCert(context.Background(), "my-cert.pem", []byte(pem))
+
+	s.NoError(err)
+	s.Equal("PUT", s.requests[0].Method)
+	s.Equal("my-cert.pem", s.requests[0].URL.Query().Get("certName"))
+	s.Equal(pem, s.bodies[0])
+}
+
+// Config
+
+func (s *ClientTestSuite) Test_Config_ReturnsBody() {
+	actual, err := NewClient(s.server.URL).Config(context.Background())
+
+	s.NoError(err)
+	s.Equal("/v1/docker-flow-proxy/config", s.requests[0].URL.Path)
+	s.Equal(`{"Status":"OK","ServiceName":"my-service"}`, actual)
+}
+
+// Services
+
+func (s *ClientTestSuite) Test_Services_ReturnsServices() {
+	s.response = `[
+		{"serviceName": "my-service", "servicePath": ["/api"], "port": "8080", "timeoutServer": 60, "applied": true},
+		{"serviceName": "other-service", "proxyRole": "internal", "applied": false}
+	]`
+
+	actual, err := NewClient(s.server.URL).Services(context.Background())
+
+	s.NoError(err)
+	s.Equal("GET", s.requests[0].Method)
+	s.Equal("/v1/docker-flow-proxy/services", s.requests[0].URL.Path)
+	s.Len(actual, 2)
+	s.Equal("my-service", actual[0].ServiceName)
+	s.Equal([]string{"/api"}, actual[0].ServicePath)
+	s.Equal("8080", actual[0].Port)
+	s.Equal(60, actual[0].TimeoutServer)
+	s.True(actual[0].Applied)
+	s.Equal("internal", actual[1].ProxyRole)
+	s.False(actual[1].Applied)
+}
+
+func (s *ClientTestSuite) Test_Services_ReturnsError_WhenResponseCannotBeParsed() {
+	_, err := NewClient(s.server.URL).Services(context.Background())
+
+	s.Error(err)
+}
+
+// Suite
+
+func TestClientUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
@@ -0,0 +1,134 @@
+// +build !integration
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"./actions"
+	"./client"
+	"./proxy"
+	"./server"
+)
+
+// Runs the client package against the in-process server
+
+type ClientServeTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+func (s *ClientServeTestSuite) SetupTest() {
+	s.server = httptest.NewServer(&Serve{})
+}
+
+func (s *ClientServeTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ClientServeTestSuite) Test_Reconfigure_SendsParametersDecodedByServe() {
+	var actual actions.ServiceReconfigure
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	expected := actions.ServiceReconfigure{
+		ServiceName:   "my-service",
+		ServicePath:   []string{"/api/v1", "/api/v2"},
+		ServiceDomain: []string{"my-domain.com"},
+		ReqRepSearch:  `^([^\ ]*)\ /something/(.*)`,
+		ReqRepReplace: `\1\ /demo/\2`,
+		Users:         []actions.User{{Username: "user-1", Password: "pass&1"}},
+		SkipCheck:     true,
+	}
+
+	response, err := client.NewClient(s.server.URL).Reconfigure(context.Background(), expected)
+
+	s.NoError(err)
+	s.Equal("OK", response.Status)
+	s.Equal(expected, actual)
+	s.Equal(expected.ServicePath, response.ServicePath)
+}
+
+func (s *ClientServeTestSuite) Test_Reconfigure_ReturnsError_WhenServeRejectsTheRequest() {
+	response, err := client.NewClient(s.server.URL).Reconfigure(context.Background(), actions.ServiceReconfigure{ServiceName: "my-service"})
+
+	s.Error(err)
+	s.Equal("NOK", response.Status)
+}
+
+func (s *ClientServeTestSuite) Test_Remove_SendsParametersDecodedByServe() {
+	var actualServiceName, actualAclName string
+	newRemoveOrig := NewRemove
+	defer func() { NewRemove = newRemoveOrig }()
+	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		actualServiceName = serviceName
+		actualAclName = aclName
+		return getRemoveMock("")
+	}
+
+	_, err := client.NewClient(s.server.URL).Remove(context.Background(), "my-service", client.RemoveOptions{AclName: "my-acl"})
+
+	s.NoError(err)
+	s.Equal("my-service", actualServiceName)
+	s.Equal("my-acl", actualAclName)
+}
+
+func (s *ClientServeTestSuite) Test_PutCert_StoresCert() {
+	certsDir, _ := ioutil.TempDir("", "certs")
+	defer os.RemoveAll(certsDir)
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = server.NewCert(certsDir)
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxy.Instance = getProxyMock("")
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "acme.com"}}
+	der, _ := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	content := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+
+	err := client.NewClient(s.server.URL).PutCert(context.Background(), "my-cert.pem", []byte(content))
+
+	s.NoError(err)
+	actual, _ := ioutil.ReadFile(certsDir + "/my-cert.pem")
+	s.Equal(content, string(actual))
+}
+
+func (s *ClientServeTestSuite) Test_Config_ReturnsConfig() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	mockObj := getProxyMock("ReadConfig")
+	mockObj.On("ReadConfig", mock.Anything).Return("some config", nil)
+	proxy.Instance = mockObj
+
+	actual, err := client.NewClient(s.server.URL).Config(context.Background())
+
+	s.NoError(err)
+	s.Equal("some config", actual)
+}
+
+// Suite
+
+func TestClientServeUnitTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(ClientServeTestSuite))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+
+	"./actions"
+	"./server"
+)
+
+// ServeDeps holds the functions the server listens, logs and resolves the other instances with and creates its actions
+// with. They are fields of the server, rather than package variables, so that tests can replace them for a single
+// server without writing globals that goroutines of other servers read. The deps that are not set fall back to the
+// package ones, except for LookupHost and LogPrintf that fall back to net.LookupHost and log.Printf.
+type ServeDeps struct {
+	ListenAndServe      func(addr string, handler http.Handler) error
+	NewReconfigure      func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable
+	NewReconfigureBatch func(baseData actions.BaseReconfigure, services []actions.ServiceReconfigure) actions.Executable
+	NewRemove           func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable
+	NewRemoveServices   func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable
+	Cert                server.Certer
+	Distributor         server.Server
+	HttpPost            func(url, contentType string, body io.Reader) (*http.Response, error)
+	LookupHost          func(host string) (addrs []string, err error)
+	LogPrintf           func(format string, v ...interface{})
+}
+
+// NewServe returns a server that uses the deps. The deps are set once and only read afterwards.
+func NewServe(deps ServeDeps) *Serve {
+	return &Serve{deps: deps}
+}
+
+func (m *Serve) listenAndServe(addr string, handler http.Handler) error {
+	if m.deps.ListenAndServe != nil {
+		return m.deps.ListenAndServe(addr, handler)
+	}
+	return httpListenAndServe(addr, handler)
+}
+
+func (m *Serve) newReconfigure(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+	if m.deps.NewReconfigure != nil {
+		return m.deps.NewReconfigure(baseData, serviceData)
+	}
+	return actions.NewReconfigure(baseData, serviceData)
+}
+
+func (m *Serve) newReconfigureBatch(baseData actions.BaseReconfigure, services []actions.ServiceReconfigure) actions.Executable {
+	if m.deps.NewReconfigureBatch != nil {
+		return m.deps.NewReconfigureBatch(baseData, services)
+	}
+	return actions.NewReconfigureBatch(baseData, services)
+}
+
+func (m *Serve) newRemove(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+	if m.deps.NewRemove != nil {
+		return m.deps.NewRemove(serviceName, aclName, configsPath, templatesPath, consulAddresses, instanceName, mode)
+	}
+	return NewRemove(serviceName, aclName, configsPath, templatesPath, consulAddresses, instanceName, mode)
+}
+
+func (m *Serve) newRemoveServices(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+	if m.deps.NewRemoveServices != nil {
+		return m.deps.NewRemoveServices(services, configsPath, templatesPath, consulAddresses, instanceName, mode)
+	}
+	return NewRemoveServices(services, configsPath, templatesPath, consulAddresses, instanceName, mode)
+}
+
+func (m *Serve) getCert() server.Certer {
+	if m.deps.Cert != nil {
+		return m.deps.Cert
+	}
+	return cert
+}
+
+// getDistributor returns the server that sends requests to the other instances. They are resolved through the
+// LookupHost dep.
+func (m *Serve) getDistributor() server.Server {
+	if m.deps.Distributor != nil {
+		return m.deps.Distributor
+	}
+	return &server.Serve{LookupHost: m.deps.LookupHost}
+}
+
+func (m *Serve) httpPost(url, contentType string, body io.Reader) (*http.Response, error) {
+	if m.deps.HttpPost != nil {
+		return m.deps.HttpPost(url, contentType, body)
+	}
+	return httpPost(url, contentType, body)
+}
+
+func (m *Serve) logPrintf(format string, v ...interface{}) {
+	if m.deps.LogPrintf != nil {
+		m.deps.LogPrintf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
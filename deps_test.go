@@ -0,0 +1,172 @@
+// +build !integration
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"./actions"
+	"./proxy"
+	"./server"
+)
+
+type DepsTestSuite struct {
+	suite.Suite
+	certsDir string
+}
+
+func (s *DepsTestSuite) SetupTest() {
+	s.certsDir, _ = ioutil.TempDir("", "certs")
+	proxy.Instance = getProxyMock("")
+}
+
+func (s *DepsTestSuite) TearDownTest() {
+	os.RemoveAll(s.certsDir)
+}
+
+func (s *DepsTestSuite) getCertAndKey(domain string) string {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: domain}}
+	der, _ := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+// NewServe
+
+func (s *DepsTestSuite) Test_NewServe_UsesDepsInsteadOfPackageFunctions() {
+	var actualAddress string
+	actualServices := []string{}
+	srv := NewServe(ServeDeps{
+		ListenAndServe: func(addr string, handler http.Handler) error {
+			actualAddress = addr
+			return nil
+		},
+		NewReconfigure: func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+			actualServices = append(actualServices, serviceData.ServiceName)
+			return getReconfigureMock("")
+		},
+	})
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/reconfigure?serviceName=my-service&servicePath=/demo&port=8080", nil)
+
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+	srv.listenAndServe("0.0.0.0:8080", srv)
+
+	s.Equal([]string{"my-service"}, actualServices)
+	s.Equal("0.0.0.0:8080", actualAddress)
+}
+
+func (s *DepsTestSuite) Test_NewServe_ResolvesInstancesAndLogsThroughDeps() {
+	var actualHost string
+	logs := []string{}
+	srv := NewServe(ServeDeps{
+		LookupHost: func(host string) (addrs []string, err error) {
+			actualHost = host
+			return nil, fmt.Errorf("This is an error")
+		},
+		LogPrintf: func(format string, v ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, v...))
+		},
+	})
+	srv.ServiceName = "my-proxy"
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/reconfigure?serviceName=my-service&servicePath=/demo&port=8080&distribute=true", nil)
+
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(500, rw.Code)
+	s.Equal("tasks.my-proxy", actualHost)
+	s.Contains(logs, "Processing request http://acme.com/v1/docker-flow-proxy/reconfigure?serviceName=my-service&servicePath=/demo&port=8080&distribute=true")
+}
+
+// ServeHTTP
+
+func (s *DepsTestSuite) Test_ServeHTTP_HandlesConcurrentReconfigureRemoveAndCertRequests() {
+	apiQueue = newWorkQueue(100, 4)
+	defer func() { apiQueue = nil }()
+	mu := sync.Mutex{}
+	reconfigured := map[string]bool{}
+	removed := map[string]bool{}
+	srv := NewServe(ServeDeps{
+		NewReconfigure: func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+			mu.Lock()
+			defer mu.Unlock()
+			reconfigured[serviceData.ServiceName] = true
+			return getReconfigureMock("")
+		},
+		NewRemove: func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+			mu.Lock()
+			defer mu.Unlock()
+			removed[serviceName] = true
+			return getRemoveMock("")
+		},
+		Cert: server.NewCert(s.certsDir),
+	})
+	count := 10
+	certs := []string{}
+	for i := 0; i < count; i++ {
+		certs = append(certs, s.getCertAndKey(fmt.Sprintf("service-%d.com", i)))
+	}
+	codes := make(chan int, count*3)
+	wg := sync.WaitGroup{}
+	for i := 0; i < count; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", fmt.Sprintf("http://acme.com/v1/docker-flow-proxy/reconfigure?serviceName=service-%d&servicePath=/%d&port=8080", i, i), nil)
+			srv.ServeHTTP(rw, req)
+			codes <- rw.Code
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", fmt.Sprintf("http://acme.com/v1/docker-flow-proxy/remove?serviceName=old-service-%d", i), nil)
+			srv.ServeHTTP(rw, req)
+			codes <- rw.Code
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", fmt.Sprintf("http://acme.com/v1/docker-flow-proxy/cert?certName=service-%d.pem", i), strings.NewReader(certs[i]))
+			srv.ServeHTTP(rw, req)
+			codes <- rw.Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		s.Equal(200, code)
+	}
+	s.Len(reconfigured, count)
+	s.Len(removed, count)
+	files, _ := ioutil.ReadDir(s.certsDir)
+	s.Len(files, count)
+}
+
+// Suite
+
+func TestDepsUnitTestSuite(t *testing.T) {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(DepsTestSuite))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"./actions"
+	"./proxy"
+)
+
+// importConfig maps the services of the HAProxy configuration sent in the body and, unless the dryRun query is true,
+// reconfigures them one by one. Services that are invalid or fail are reported without stopping the import.
+func (m *Serve) importConfig(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "POST" {
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: "The import-config endpoint allows only POST requests"})
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write(js)
+		return
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	if len(strings.TrimSpace(string(body))) == 0 {
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: "The body must contain an HAProxy configuration"})
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(js)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(req.URL.Query().Get("dryRun"))
+	result := proxy.ParseConfig(string(body))
+	response := ImportResponse{Status: "OK", DryRun: dryRun, Services: []ImportedServiceResponse{}, Unmapped: result.Unmapped}
+	failed := 0
+	for _, imported := range result.Services {
+		sr := m.getImportedService(imported)
+		service := ImportedServiceResponse{Backend: imported.Backend, Parameters: newResponseV2("OK", "", nil, sr).Parameters}
+		if msg, _ := m.validateReconfigure(sr); len(msg) > 0 {
+			service.Error = msg
+		} else if !dryRun {
+			if err := m.enqueue(sr.ServiceName, func() error {
+				return m.newReconfigure(m.BaseReconfigure, sr).Execute([]string{})
+			}); err != nil {
+				service.Error = err.Error()
+			}
+		}
+		if len(service.Error) > 0 {
+			failed++
+		}
+		response.Services = append(response.Services, service)
+	}
+	if failed > 0 {
+		response.Status = "NOK"
+		response.Message = fmt.Sprintf("%d of the %d services could not be imported", failed, len(result.Services))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// getImportedService converts the service found in the configuration into reconfigure parameters. The address of
+// the server is kept only if it differs from the service name the proxy would use anyway.
+func (m *Serve) getImportedService(imported proxy.ImportedService) actions.ServiceReconfigure {
+	sr := actions.ServiceReconfigure{
+		ServiceName:   actions.CanonicalServiceName(imported.ServiceName),
+		ServicePath:   imported.Paths,
+		PathType:      imported.PathType,
+		ServiceDomain: imported.Domains,
+		Port:          imported.Port,
+		Mode:          m.Mode,
+	}
+	if imported.Host != sr.ServiceName {
+		sr.ServiceAddress = imported.Host
+	}
+	return sr
+}
+
+// getImportServiceNames returns the names of the services the configuration in the body would import. The body is
+// restored so that it can be read again.
+func getImportServiceNames(req *http.Request) []string {
+	body, _ := ioutil.ReadAll(req.Body)
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	names := []string{}
+	for _, imported := range proxy.ParseConfig(string(body)).Services {
+		names = append(names, actions.CanonicalServiceName(imported.ServiceName))
+	}
+	return names
+}
@@ -0,0 +1,160 @@
+// +build !integration
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"./actions"
+)
+
+type ImportConfigTestSuite struct {
+	suite.Suite
+	config       string
+	reconfigured []actions.ServiceReconfigure
+	srv          *Serve
+}
+
+func (s *ImportConfigTestSuite) SetupTest() {
+	content, _ := ioutil.ReadFile("proxy/test_configs/import/generated.cfg")
+	s.config = string(content)
+	s.reconfigured = []actions.ServiceReconfigure{}
+	s.srv = NewServe(ServeDeps{
+		NewReconfigure: func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+			s.reconfigured = append(s.reconfigured, serviceData)
+			return getReconfigureMock("")
+		},
+	})
+	s.srv.Mode = "swarm"
+}
+
+func (s *ImportConfigTestSuite) post(query, body string) (*httptest.ResponseRecorder, ImportResponse) {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://acme.com/v1/docker-flow-proxy/import-config"+query, strings.NewReader(body))
+	s.srv.ServeHTTP(rw, req)
+	actual := ImportResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	return rw, actual
+}
+
+// ServeHTTP
+
+func (s *ImportConfigTestSuite) Test_ServeHTTP_ReconfiguresImportedServices() {
+	rw, actual := s.post("", s.config)
+
+	s.Equal(200, rw.Code)
+	s.Equal("OK", actual.Status)
+	s.Len(actual.Services, 3)
+	s.Require().Len(s.reconfigured, 3)
+	s.Equal(actions.ServiceReconfigure{
+		ServiceName:    "api",
+		ServicePath:    []string{"^/api/v[0-9]+/"},
+		PathType:       "path_reg",
+		Port:           "3000",
+		ServiceAddress: "10.0.0.12",
+		Mode:           "swarm",
+	}, s.reconfigured[0])
+	s.Equal(actions.ServiceReconfigure{
+		ServiceName:   "go-demo",
+		ServicePath:   []string{"/demo", "/demo/api"},
+		ServiceDomain: []string{"go-demo.com"},
+		Port:          "8080",
+		Mode:          "swarm",
+	}, s.reconfigured[1])
+	s.Equal([]string{"*.acme.com"}, s.reconfigured[2].ServiceDomain)
+}
+
+func (s *ImportConfigTestSuite) Test_ServeHTTP_OnlyReturnsMapping_WhenDryRunIsTrue() {
+	rw, actual := s.post("?dryRun=true", s.config)
+
+	s.Equal(200, rw.Code)
+	s.True(actual.DryRun)
+	s.Equal("api-be", actual.Services[0].Backend)
+	s.Equal("api", actual.Services[0].Parameters.ServiceName)
+	s.Equal("10.0.0.12", actual.Services[0].Parameters.ServiceAddress)
+	s.Empty(s.reconfigured)
+}
+
+func (s *ImportConfigTestSuite) Test_ServeHTTP_ReturnsUnmappedSections() {
+	_, actual := s.post("?dryRun=true", s.config+`
+listen stats
+    bind *:9000`)
+
+	s.Equal("listen stats", actual.Unmapped[0].Section)
+	s.Equal("The listen sections are not supported", actual.Unmapped[0].Reason)
+}
+
+func (s *ImportConfigTestSuite) Test_ServeHTTP_ReportsServicesThatCannotBeImported() {
+	s.srv.deps.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		mockObj := getReconfigureMock("Execute")
+		if serviceData.ServiceName == "shop" {
+			mockObj.On("Execute", mock.Anything).Return(fmt.Errorf("This is an error"))
+		} else {
+			mockObj.On("Execute", mock.Anything).Return(nil)
+		}
+		return mockObj
+	}
+	config := strings.Replace(s.config, "api-be", "dummy-be", -1)
+
+	rw, actual := s.post("", config)
+
+	s.Equal(200, rw.Code)
+	s.Equal("NOK", actual.Status)
+	s.Equal("2 of the 3 services could not be imported", actual.Message)
+	s.Contains(actual.Services[0].Error, "reserved")
+	s.Empty(actual.Services[1].Error)
+	s.Equal("This is an error", actual.Services[2].Error)
+}
+
+func (s *ImportConfigTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenBodyIsEmpty() {
+	rw, _ := s.post("", " \n")
+
+	s.Equal(400, rw.Code)
+}
+
+func (s *ImportConfigTestSuite) Test_ServeHTTP_ReturnsStatus405_WhenMethodIsNotPost() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/import-config", nil)
+
+	s.srv.ServeHTTP(rw, req)
+
+	s.Equal(405, rw.Code)
+}
+
+// authorize
+
+func (s *ImportConfigTestSuite) Test_Authorize_RequiresReconfigureOfImportedServices_WhenDryRunIsNotTrue() {
+	defer func() { apiTokens = map[string]ApiToken{} }()
+	apiTokens = map[string]ApiToken{"team-token": {Services: []string{"go-demo", "api"}}}
+	req, _ := http.NewRequest("POST", "http://acme.com/v1/docker-flow-proxy/import-config", strings.NewReader(s.config))
+	req.Header.Set("Authorization", "Bearer team-token")
+
+	status, msg := authorize(req)
+
+	s.Equal(http.StatusForbidden, status)
+	s.Contains(msg, "the service shop")
+	body, _ := ioutil.ReadAll(req.Body)
+	s.Equal(s.config, string(body))
+
+	req, _ = http.NewRequest("POST", "http://acme.com/v1/docker-flow-proxy/import-config?dryRun=true", strings.NewReader(s.config))
+
+	status, _ = authorize(req)
+
+	s.Equal(0, status)
+}
+
+// Suite
+
+func TestImportConfigUnitTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(ImportConfigTestSuite))
+}
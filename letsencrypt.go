@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"./actions"
+	"./proxy"
+)
+
+// letsEncryptRenewInterval is the period of the checks of the certificates obtained from Let's Encrypt.
+const letsEncryptRenewInterval = 12 * time.Hour
+
+// acmeAccountKeyPath is the file the key of the ACME account is stored in. It is kept in the certificates volume so
+// that the same account is used after the proxy restarts.
+var acmeAccountKeyPath = "/certs/acme/account.key"
+
+// letsEncryptAccount holds the client of the registered account. Certificates are obtained one at a time.
+var letsEncryptAccount = struct {
+	sync.Mutex
+	directoryUrl string
+	client       *acmeClient
+}{}
+
+// obtainLetsEncryptCert returns the certificate chain and the private key for the domains. The account is registered
+// with the first certificate and reused by the following ones.
+var obtainLetsEncryptCert = func(directoryUrl, email string, domains []string) (string, error) {
+	letsEncryptAccount.Lock()
+	defer letsEncryptAccount.Unlock()
+	if letsEncryptAccount.client == nil || letsEncryptAccount.directoryUrl != directoryUrl {
+		key, err := getAcmeAccountKey()
+		if err != nil {
+			return "", err
+		}
+		client, err := newAcmeClient(directoryUrl, email, key)
+		if err != nil {
+			return "", err
+		}
+		letsEncryptAccount.directoryUrl, letsEncryptAccount.client = directoryUrl, client
+	}
+	return letsEncryptAccount.client.Obtain(domains)
+}
+
+// getAcmeAccountKey returns the stored key of the ACME account. A new key is generated and stored if there is none.
+// The new key is still used if it cannot be stored.
+func getAcmeAccountKey() (*ecdsa.PrivateKey, error) {
+	if content, err := ioutil.ReadFile(acmeAccountKeyPath); err == nil {
+		block, _ := pem.Decode(content)
+		if block == nil {
+			return nil, fmt.Errorf("The ACME account key %s is not a PEM file", acmeAccountKeyPath)
+		}
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse the ACME account key %s\n%s", acmeAccountKeyPath, err.Error())
+		}
+		return key, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, _ := x509.MarshalECPrivateKey(key)
+	content := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := os.MkdirAll(filepath.Dir(acmeAccountKeyPath), 0700); err != nil {
+		log.Printf("Could not store the ACME account key\n%s", err.Error())
+	} else if err := ioutil.WriteFile(acmeAccountKeyPath, content, 0600); err != nil {
+		log.Printf("Could not store the ACME account key\n%s", err.Error())
+	}
+	return key, nil
+}
+
+// startLetsEncryptRenewal renews the certificates of the services reconfigured with letsEncrypt immediately and every
+// interval afterwards.
+var startLetsEncryptRenewal = func(m *Serve, interval time.Duration) {
+	go func() {
+		m.renewLetsEncryptCerts()
+		for range time.Tick(interval) {
+			m.renewLetsEncryptCerts()
+		}
+	}()
+}
+
+// putLetsEncryptCert obtains a certificate for the domains of the service unless the stored one covers all of them
+// and does not expire within LETS_ENCRYPT_RENEW_BEFORE. The certificate is obtained outside the queue since the
+// challenges take a while. It is then stored through the queue under the first domain, the same name serviceCert
+// uses, and the proxy is reloaded.
+func (m *Serve) putLetsEncryptCert(sr actions.ServiceReconfigure) error {
+	if len(m.LetsEncryptEmail) == 0 {
+		return fmt.Errorf("The letsEncrypt query requires the LETS_ENCRYPT_EMAIL environment variable to be set")
+	} else if !m.EnableAcmeChallenges {
+		return fmt.Errorf("The letsEncrypt query requires the ENABLE_ACME_CHALLENGES environment variable to be set to true")
+	} else if len(sr.ServiceDomain) == 0 {
+		return fmt.Errorf("The letsEncrypt query requires the serviceDomain query")
+	}
+	for _, domain := range sr.ServiceDomain {
+		if strings.HasPrefix(domain, "*") {
+			return fmt.Errorf("Let's Encrypt cannot issue a certificate for the wildcard domain %s through HTTP-01 challenges", domain)
+		}
+	}
+	if !m.isLetsEncryptCertDue(sr) {
+		return nil
+	}
+	m.logPrintf("Obtaining a certificate for %s from Let's Encrypt", strings.Join(sr.ServiceDomain, ", "))
+	content, err := obtainLetsEncryptCert(m.LetsEncryptDirectory, m.LetsEncryptEmail, sr.ServiceDomain)
+	if err != nil {
+		return fmt.Errorf("Could not obtain a certificate for %s from Let's Encrypt\n%s", strings.Join(sr.ServiceDomain, ", "), err.Error())
+	}
+	// The key differs from the service name so that a pending reconfiguration of the service is not replaced
+	return m.enqueue("letsencrypt:"+sr.ServiceName, func() error {
+		if _, err := m.getCert().PutCert(sr.ServiceDomain[0], []byte(content)); err != nil {
+			return err
+		}
+		proxy.Instance.CreateConfigFromTemplates()
+		proxy.Instance.Reload()
+		return nil
+	})
+}
+
+// isLetsEncryptCertDue tells whether the certificate of the service is missing, does not cover all its domains or
+// expires within LETS_ENCRYPT_RENEW_BEFORE.
+func (m *Serve) isLetsEncryptCertDue(sr actions.ServiceReconfigure) bool {
+	content, ok := getCerts()[sr.ServiceDomain[0]]
+	if !ok {
+		return true
+	}
+	entries := getCertExpiries(map[string]string{sr.ServiceDomain[0]: content})
+	if len(entries) == 0 || entries[0].notAfter.Sub(timeNow()) <= m.LetsEncryptRenewBefore {
+		return true
+	}
+	covered := map[string]bool{}
+	for _, domain := range entries[0].domains {
+		covered[strings.ToLower(domain)] = true
+	}
+	for _, domain := range sr.ServiceDomain {
+		if !covered[strings.ToLower(domain)] {
+			return true
+		}
+	}
+	return false
+}
+
+// renewLetsEncryptCerts obtains the certificates that are due for all the services reconfigured with letsEncrypt.
+func (m *Serve) renewLetsEncryptCerts() {
+	for _, sr := range getServices() {
+		if !sr.LetsEncrypt {
+			continue
+		}
+		if err := m.putLetsEncryptCert(sr); err != nil {
+			m.logPrintf(err.Error())
+		}
+	}
+}
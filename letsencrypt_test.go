@@ -0,0 +1,288 @@
+// +build !integration
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"./actions"
+	"./proxy"
+)
+
+type LetsEncryptTestSuite struct {
+	suite.Suite
+	now       time.Time
+	certs     map[string]string
+	obtained  [][]string
+	obtainErr error
+	put       map[string]string
+	proxyMock *ProxyMock
+	serve     *Serve
+}
+
+func (s *LetsEncryptTestSuite) SetupTest() {
+	s.now = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.certs = map[string]string{}
+	s.obtained = [][]string{}
+	s.obtainErr = nil
+	s.put = map[string]string{}
+	timeNow = func() time.Time {
+		return s.now
+	}
+	getCerts = func() map[string]string {
+		return s.certs
+	}
+	obtainLetsEncryptCert = func(directoryUrl, email string, domains []string) (string, error) {
+		s.obtained = append(s.obtained, domains)
+		return "cert-and-key", s.obtainErr
+	}
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			s.put[certName] = string(certContent)
+			return "/certs/" + certName, nil
+		},
+	}
+	s.proxyMock = getProxyMock("")
+	proxy.Instance = s.proxyMock
+	s.serve = &Serve{
+		LetsEncryptEmail:       "admin@acme.com",
+		LetsEncryptDirectory:   "https://acme.com/directory",
+		LetsEncryptRenewBefore: 30 * 24 * time.Hour,
+		EnableAcmeChallenges:   true,
+	}
+}
+
+func (s *LetsEncryptTestSuite) getCert(notAfter time.Time, domains ...string) string {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		DNSNames:     domains,
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// getAcmeAccountKey
+
+func (s *LetsEncryptTestSuite) Test_GetAcmeAccountKey_StoresNewKeyAndReusesIt() {
+	dir, _ := ioutil.TempDir("", "acme")
+	defer os.RemoveAll(dir)
+	acmeAccountKeyPathOrig := acmeAccountKeyPath
+	defer func() { acmeAccountKeyPath = acmeAccountKeyPathOrig }()
+	acmeAccountKeyPath = filepath.Join(dir, "acme", "account.key")
+
+	key, err := getAcmeAccountKey()
+	s.NoError(err)
+	info, err := os.Stat(acmeAccountKeyPath)
+	s.NoError(err)
+	s.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	actual, err := getAcmeAccountKey()
+
+	s.NoError(err)
+	s.Equal(key.D, actual.D)
+}
+
+func (s *LetsEncryptTestSuite) Test_GetAcmeAccountKey_ReturnsError_WhenKeyIsInvalid() {
+	file, _ := ioutil.TempFile("", "account.key")
+	defer os.Remove(file.Name())
+	file.WriteString("not a key")
+	file.Close()
+	acmeAccountKeyPathOrig := acmeAccountKeyPath
+	defer func() { acmeAccountKeyPath = acmeAccountKeyPathOrig }()
+	acmeAccountKeyPath = file.Name()
+
+	_, err := getAcmeAccountKey()
+
+	s.Error(err)
+}
+
+// putLetsEncryptCert
+
+func (s *LetsEncryptTestSuite) Test_PutLetsEncryptCert_ObtainsAndStoresCert_WhenCertDoesNotExist() {
+	sr := actions.ServiceReconfigure{ServiceName: "my-service", ServiceDomain: []string{"acme.com", "www.acme.com"}, LetsEncrypt: true}
+
+	err := s.serve.putLetsEncryptCert(sr)
+
+	s.NoError(err)
+	s.Equal([][]string{{"acme.com", "www.acme.com"}}, s.obtained)
+	s.Equal(map[string]string{"acme.com": "cert-and-key"}, s.put)
+	s.proxyMock.AssertCalled(s.T(), "CreateConfigFromTemplates")
+	s.proxyMock.AssertCalled(s.T(), "Reload")
+}
+
+func (s *LetsEncryptTestSuite) Test_PutLetsEncryptCert_DoesNothing_WhenCertIsValidAndCoversDomains() {
+	s.certs["acme.com"] = s.getCert(s.now.Add(60*24*time.Hour), "acme.com", "www.acme.com")
+	sr := actions.ServiceReconfigure{ServiceName: "my-service", ServiceDomain: []string{"acme.com", "WWW.acme.com"}, LetsEncrypt: true}
+
+	err := s.serve.putLetsEncryptCert(sr)
+
+	s.NoError(err)
+	s.Empty(s.obtained)
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *LetsEncryptTestSuite) Test_PutLetsEncryptCert_ObtainsCert_WhenCertExpiresWithinRenewPeriod() {
+	s.certs["acme.com"] = s.getCert(s.now.Add(10*24*time.Hour), "acme.com")
+	sr := actions.ServiceReconfigure{ServiceName: "my-service", ServiceDomain: []string{"acme.com"}, LetsEncrypt: true}
+
+	s.serve.putLetsEncryptCert(sr)
+
+	s.Len(s.obtained, 1)
+}
+
+func (s *LetsEncryptTestSuite) Test_PutLetsEncryptCert_ObtainsCert_WhenDomainIsNotCovered() {
+	s.certs["acme.com"] = s.getCert(s.now.Add(60*24*time.Hour), "acme.com")
+	sr := actions.ServiceReconfigure{ServiceName: "my-service", ServiceDomain: []string{"acme.com", "api.acme.com"}, LetsEncrypt: true}
+
+	s.serve.putLetsEncryptCert(sr)
+
+	s.Equal([][]string{{"acme.com", "api.acme.com"}}, s.obtained)
+}
+
+func (s *LetsEncryptTestSuite) Test_PutLetsEncryptCert_ReturnsError_WhenCertCannotBeObtained() {
+	s.obtainErr = fmt.Errorf("This is an error")
+	sr := actions.ServiceReconfigure{ServiceName: "my-service", ServiceDomain: []string{"acme.com"}, LetsEncrypt: true}
+
+	err := s.serve.putLetsEncryptCert(sr)
+
+	s.EqualError(err, "Could not obtain a certificate for acme.com from Let's Encrypt\nThis is an error")
+	s.Empty(s.put)
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *LetsEncryptTestSuite) Test_PutLetsEncryptCert_ReturnsError_WhenRequirementsAreNotMet() {
+	cases := []struct {
+		serve  Serve
+		domain []string
+	}{
+		{Serve{EnableAcmeChallenges: true}, []string{"acme.com"}},
+		{Serve{LetsEncryptEmail: "admin@acme.com"}, []string{"acme.com"}},
+		{*s.serve, []string{}},
+		{*s.serve, []string{"*.acme.com"}},
+	}
+	for _, c := range cases {
+		sr := actions.ServiceReconfigure{ServiceName: "my-service", ServiceDomain: c.domain, LetsEncrypt: true}
+
+		err := c.serve.putLetsEncryptCert(sr)
+
+		s.Error(err, fmt.Sprint(c.domain))
+	}
+	s.Empty(s.obtained)
+}
+
+// renewLetsEncryptCerts
+
+func (s *LetsEncryptTestSuite) Test_RenewLetsEncryptCerts_ObtainsDueCertsOfServicesWithLetsEncrypt() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{
+			{ServiceName: "service-1", ServiceDomain: []string{"service-1.com"}, LetsEncrypt: true},
+			{ServiceName: "service-2", ServiceDomain: []string{"service-2.com"}, LetsEncrypt: true},
+			{ServiceName: "service-3", ServiceDomain: []string{"service-3.com"}},
+		}
+	}
+	s.certs["service-1.com"] = s.getCert(s.now.Add(5*24*time.Hour), "service-1.com")
+	s.certs["service-2.com"] = s.getCert(s.now.Add(60*24*time.Hour), "service-2.com")
+
+	s.serve.renewLetsEncryptCerts()
+
+	s.Equal([][]string{{"service-1.com"}}, s.obtained)
+}
+
+// ServeHTTP > Reconfigure
+
+func (s *LetsEncryptTestSuite) Test_ServeHTTP_ReconfiguresServiceAndReturnsLetsEncryptError_WhenCertCannotBeObtained() {
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	reconfigureMock := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return reconfigureMock
+	}
+	s.obtainErr = fmt.Errorf("This is an error")
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/reconfigure?serviceName=my-service&servicePath=/&serviceDomain=acme.com&letsEncrypt=true", nil)
+
+	s.serve.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("OK", actual.Status)
+	s.True(actual.LetsEncrypt)
+	s.Equal("Could not obtain a certificate for acme.com from Let's Encrypt\nThis is an error", actual.LetsEncryptError)
+	reconfigureMock.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *LetsEncryptTestSuite) Test_ServeHTTP_ObtainsCertOutsideQueue() {
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return getReconfigureMock("")
+	}
+	defer func() { apiQueue = nil }()
+	apiQueue = newWorkQueue(1, 1)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/reconfigure?serviceName=my-service&servicePath=/&serviceDomain=acme.com&letsEncrypt=true", nil)
+
+	s.serve.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal(map[string]string{"acme.com": "cert-and-key"}, s.put)
+	s.proxyMock.AssertCalled(s.T(), "Reload")
+}
+
+func (s *LetsEncryptTestSuite) Test_ServeHTTP_DoesNotObtainCert_WhenReconfigureFails() {
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() { actions.NewReconfigure = newReconfigureOrig }()
+	reconfigureMock := getReconfigureMock("Execute")
+	reconfigureMock.On("Execute", []string{}).Return(fmt.Errorf("This is an error"))
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return reconfigureMock
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/reconfigure?serviceName=my-service&servicePath=/&serviceDomain=acme.com&letsEncrypt=true", nil)
+
+	s.serve.ServeHTTP(rw, req)
+
+	s.Equal(500, rw.Code)
+	s.Empty(s.obtained)
+}
+
+// Suite
+
+func TestLetsEncryptUnitTestSuite(t *testing.T) {
+	timeNowOrig := timeNow
+	getCertsOrig := getCerts
+	obtainLetsEncryptCertOrig := obtainLetsEncryptCert
+	certOrig := cert
+	proxyOrig := proxy.Instance
+	defer func() {
+		timeNow = timeNowOrig
+		getCerts = getCertsOrig
+		obtainLetsEncryptCert = obtainLetsEncryptCertOrig
+		cert = certOrig
+		proxy.Instance = proxyOrig
+	}()
+	suite.Run(t, new(LetsEncryptTestSuite))
+}
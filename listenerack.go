@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"./actions"
+	"./proxy"
+)
+
+// defaultListenerAckPath is the path of the Swarm listener acknowledgments are sent to if LISTENER_ACK_PATH is not set.
+const defaultListenerAckPath = "/v1/docker-flow-swarm-listener/notify-proxy-ack"
+
+// listenerAckAttempts is the number of times an acknowledgment is sent before it is given up.
+const listenerAckAttempts = 3
+
+// listenerAckRetryInterval is multiplied by the number of the failed attempt to get the pause before the next one.
+var listenerAckRetryInterval = time.Second
+
+// ListenerAck tells the Swarm listener that the proxy applied the change of the service.
+type ListenerAck struct {
+	ServiceName  string `json:"serviceName"`
+	Action       string `json:"action"`
+	ConfigHash   string `json:"configHash"`
+	InstanceName string `json:"instanceName"`
+}
+
+// notifyListener acknowledges the action performed on the services to the Swarm listener. The acknowledgments are
+// sent in the background and failures are only logged.
+func (m *Serve) notifyListener(action string, serviceNames ...string) {
+	if len(m.ListenerAddress) == 0 || len(serviceNames) == 0 {
+		return
+	}
+	configHash := ""
+	if config, err := proxy.Instance.ReadConfig(); err != nil {
+		m.logPrintf("Could not read the configuration acknowledged to the Swarm listener\n%s", err.Error())
+	} else {
+		configHash = getConfigHash(config)
+	}
+	path := m.ListenerAckPath
+	if len(path) == 0 {
+		path = defaultListenerAckPath
+	}
+	url := m.getListenerAddress() + path
+	for _, serviceName := range serviceNames {
+		ack := ListenerAck{ServiceName: serviceName, Action: action, ConfigHash: configHash, InstanceName: m.InstanceName}
+		go m.sendListenerAck(url, ack)
+	}
+}
+
+func (m *Serve) sendListenerAck(url string, ack ListenerAck) {
+	js, _ := json.Marshal(ack)
+	var err error
+	for attempt := 1; attempt <= listenerAckAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(listenerAckRetryInterval * time.Duration(attempt-1))
+		}
+		start := time.Now()
+		resp, postErr := m.httpPost(url, "application/json", bytes.NewReader(js))
+		if postErr != nil {
+			err = postErr
+		} else if resp.Body.Close(); resp.StatusCode >= 300 {
+			err = fmt.Errorf("The Swarm listener responded with the status %d", resp.StatusCode)
+		} else {
+			err = nil
+		}
+		actions.ListenerMetrics.Observe("ack", url, time.Since(start), err, attempt > 1)
+		if err == nil {
+			return
+		}
+	}
+	m.logPrintf("Could not acknowledge the %s of the service %s to %s\n%s", ack.Action, ack.ServiceName, url, err.Error())
+}
@@ -0,0 +1,174 @@
+// +build !integration
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"./actions"
+	haproxy "./proxy"
+)
+
+type ListenerAckTestSuite struct {
+	suite.Suite
+	srv    *Serve
+	urls   chan string
+	acks   chan ListenerAck
+	status *int
+	logged chan string
+}
+
+// SetupTest creates a server whose deps use only the values of the test so that acknowledgments still being sent by
+// the previous tests do not read them.
+func (s *ListenerAckTestSuite) SetupTest() {
+	urls, acks, status, logged := make(chan string, 10), make(chan ListenerAck, 10), new(int), make(chan string, 10)
+	*status = http.StatusOK
+	s.urls, s.acks, s.status, s.logged = urls, acks, status, logged
+	proxyMock := getProxyMock("ReadConfig")
+	proxyMock.On("ReadConfig").Return("global\n", nil)
+	haproxy.Instance = proxyMock
+	s.srv = s.getServe(func(url, contentType string, body io.Reader) (*http.Response, error) {
+		ack := ListenerAck{}
+		content, _ := ioutil.ReadAll(body)
+		json.Unmarshal(content, &ack)
+		urls <- url
+		acks <- ack
+		return &http.Response{StatusCode: *status, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+}
+
+func (s *ListenerAckTestSuite) getServe(post func(url, contentType string, body io.Reader) (*http.Response, error)) *Serve {
+	logged := s.logged
+	srv := NewServe(ServeDeps{
+		NewReconfigure: func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+			return getReconfigureMock("")
+		},
+		HttpPost: post,
+		LogPrintf: func(format string, v ...interface{}) {
+			select {
+			case logged <- fmt.Sprintf(format, v...):
+			default:
+			}
+		},
+	})
+	srv.ListenerAddress = "swarm-listener"
+	srv.InstanceName = "my-proxy"
+	return srv
+}
+
+func (s *ListenerAckTestSuite) receive() (string, ListenerAck) {
+	select {
+	case url := <-s.urls:
+		return url, <-s.acks
+	case <-time.After(time.Second):
+		s.Fail("The acknowledgment was not sent")
+		return "", ListenerAck{}
+	}
+}
+
+// notifyListener
+
+func (s *ListenerAckTestSuite) Test_NotifyListener_SendsAckToListener() {
+	s.srv.notifyListener("reconfigure", "go-demo")
+
+	url, ack := s.receive()
+	s.Equal("http://swarm-listener:8080/v1/docker-flow-swarm-listener/notify-proxy-ack", url)
+	s.Equal(ListenerAck{ServiceName: "go-demo", Action: "reconfigure", ConfigHash: getConfigHash("global\n"), InstanceName: "my-proxy"}, ack)
+}
+
+func (s *ListenerAckTestSuite) Test_NotifyListener_UsesListenerAckPath() {
+	s.srv.ListenerAckPath = "/v1/acks"
+
+	s.srv.notifyListener("remove", "go-demo")
+
+	url, _ := s.receive()
+	s.Equal("http://swarm-listener:8080/v1/acks", url)
+}
+
+func (s *ListenerAckTestSuite) Test_NotifyListener_DoesNothing_WhenListenerAddressIsNotSet() {
+	s.srv.ListenerAddress = ""
+
+	s.srv.notifyListener("reconfigure", "go-demo")
+
+	select {
+	case <-s.urls:
+		s.Fail("The acknowledgment was sent")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (s *ListenerAckTestSuite) Test_NotifyListener_RetriesFailedAcks() {
+	*s.status = http.StatusServiceUnavailable
+
+	s.srv.notifyListener("reconfigure", "go-demo")
+
+	for i := 0; i < listenerAckAttempts; i++ {
+		s.receive()
+	}
+	select {
+	case msg := <-s.logged:
+		s.Contains(msg, "Could not acknowledge the reconfigure of the service go-demo")
+	case <-time.After(time.Second):
+		s.Fail("The failure was not logged")
+	}
+	s.Len(s.urls, 0)
+}
+
+// ServeHTTP
+
+func (s *ListenerAckTestSuite) Test_ServeHTTP_DoesNotWaitForAck() {
+	release := make(chan bool)
+	defer close(release)
+	acks := s.acks
+	s.srv = s.getServe(func(url, contentType string, body io.Reader) (*http.Response, error) {
+		content, _ := ioutil.ReadAll(body)
+		ack := ListenerAck{}
+		json.Unmarshal(content, &ack)
+		acks <- ack
+		<-release
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/reconfigure?serviceName=go-demo&servicePath=/demo&port=8080", nil)
+
+	done := make(chan bool)
+	go func() {
+		s.srv.ServeHTTP(rw, req)
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.Fail("The response waited for the acknowledgment")
+	}
+	s.Equal(200, rw.Code)
+	select {
+	case ack := <-s.acks:
+		s.Equal("go-demo", ack.ServiceName)
+	case <-time.After(time.Second):
+		s.Fail("The acknowledgment was not sent")
+	}
+}
+
+// Suite
+
+func TestListenerAckUnitTestSuite(t *testing.T) {
+	proxyOrig := haproxy.Instance
+	retryIntervalOrig := listenerAckRetryInterval
+	defer func() {
+		haproxy.Instance = proxyOrig
+		listenerAckRetryInterval = retryIntervalOrig
+	}()
+	listenerAckRetryInterval = 0
+	suite.Run(t, new(ListenerAckTestSuite))
+}
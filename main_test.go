@@ -4,6 +4,8 @@ package main
 
 import (
 	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
 	"testing"
 )
 
@@ -32,6 +34,6 @@ func (s MainTestSuite) Test_Main_InvokesArgsParse() {
 // Suite
 
 func TestMainUnitTestSuite(t *testing.T) {
-	logPrintf = func(format string, v ...interface{}) {}
+	log.SetOutput(ioutil.Discard)
 	suite.Run(t, new(MainTestSuite))
 }
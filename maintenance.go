@@ -0,0 +1,66 @@
+package main
+
+import (
+	"time"
+
+	"./actions"
+)
+
+// maintenanceCheckInterval is the longest time between two checks so that the windows of the services reconfigured
+// in the meantime are scheduled as well.
+var maintenanceCheckInterval = time.Minute
+
+// maintenanceScheduler reconfigures the services whose maintenance windows opened or closed since the previous check.
+// The schedule is computed from the configured services on every check so that it survives restarts, when services
+// are configured according to the windows that are open at the time.
+type maintenanceScheduler struct {
+	serve     *Serve
+	lastCheck time.Time
+}
+
+// startMaintenanceScheduler checks the maintenance windows whenever one of them opens or closes.
+var startMaintenanceScheduler = func(m *Serve) {
+	scheduler := &maintenanceScheduler{serve: m, lastCheck: timeNow()}
+	go func() {
+		for {
+			time.Sleep(scheduler.next(timeNow()).Sub(timeNow()))
+			scheduler.check(timeNow())
+		}
+	}()
+}
+
+// check reconfigures the services that entered or left maintenance since the previous check. Services with the
+// maintenance query set are not affected by their windows.
+func (s *maintenanceScheduler) check(now time.Time) {
+	for _, sr := range getServices() {
+		if len(sr.MaintenanceWindow) == 0 || sr.IsInMaintenance(s.lastCheck) == sr.IsInMaintenance(now) {
+			continue
+		}
+		if sr.IsInMaintenance(now) {
+			s.serve.logPrintf("The maintenance window of the service %s opened", sr.ServiceName)
+		} else {
+			s.serve.logPrintf("The maintenance window of the service %s closed", sr.ServiceName)
+		}
+		if err := s.serve.newReconfigure(s.serve.BaseReconfigure, sr).Execute([]string{}); err != nil {
+			s.serve.logPrintf("Could not apply the maintenance window of the service %s\n%s", sr.ServiceName, err.Error())
+		}
+	}
+	s.lastCheck = now
+}
+
+// next returns the time of the next check. It is the first time any maintenance window opens or closes unless that
+// is more than maintenanceCheckInterval away.
+func (s *maintenanceScheduler) next(now time.Time) time.Time {
+	next := now.Add(maintenanceCheckInterval)
+	for _, sr := range getServices() {
+		if len(sr.MaintenanceWindow) == 0 {
+			continue
+		}
+		if window, err := actions.ParseMaintenanceWindow(sr.MaintenanceWindow); err == nil {
+			if change := window.NextChange(now); change.Before(next) {
+				next = change
+			}
+		}
+	}
+	return next
+}
@@ -0,0 +1,110 @@
+// +build !integration
+
+package main
+
+import (
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"./actions"
+)
+
+type MaintenanceTestSuite struct {
+	suite.Suite
+	services     []actions.ServiceReconfigure
+	reconfigured []actions.ServiceReconfigure
+	serve        *Serve
+}
+
+func (s *MaintenanceTestSuite) SetupTest() {
+	s.services = []actions.ServiceReconfigure{
+		{ServiceName: "batch", MaintenanceWindow: "02:00-03:00"},
+		{ServiceName: "api"},
+	}
+	s.reconfigured = []actions.ServiceReconfigure{}
+	getServices = func() []actions.ServiceReconfigure {
+		return s.services
+	}
+	s.serve = &Serve{}
+	s.serve.deps.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		s.reconfigured = append(s.reconfigured, serviceData)
+		return getReconfigureMock("")
+	}
+}
+
+func (s *MaintenanceTestSuite) at(hour, minute int) time.Time {
+	return time.Date(2017, 6, 1, hour, minute, 0, 0, time.UTC)
+}
+
+// check
+
+func (s *MaintenanceTestSuite) Test_Check_ReconfiguresService_WhenWindowOpensAndCloses() {
+	scheduler := &maintenanceScheduler{serve: s.serve, lastCheck: s.at(1, 0)}
+
+	scheduler.check(s.at(1, 59))
+	s.Empty(s.reconfigured)
+
+	scheduler.check(s.at(2, 0))
+	s.Len(s.reconfigured, 1)
+	s.Equal("batch", s.reconfigured[0].ServiceName)
+
+	scheduler.check(s.at(2, 30))
+	s.Len(s.reconfigured, 1)
+
+	scheduler.check(s.at(3, 0))
+	s.Len(s.reconfigured, 2)
+	s.Equal("batch", s.reconfigured[1].ServiceName)
+}
+
+func (s *MaintenanceTestSuite) Test_Check_ReconfiguresServiceOnlyWhenWindowCloses_WhenRestartedMidWindow() {
+	// The service was configured in maintenance when the proxy started
+	scheduler := &maintenanceScheduler{serve: s.serve, lastCheck: s.at(2, 30)}
+
+	scheduler.check(s.at(2, 45))
+	s.Empty(s.reconfigured)
+
+	scheduler.check(s.at(3, 0))
+	s.Len(s.reconfigured, 1)
+}
+
+func (s *MaintenanceTestSuite) Test_Check_DoesNotReconfigureService_WhenMaintenanceIsSet() {
+	s.services[0].Maintenance = "true"
+	scheduler := &maintenanceScheduler{serve: s.serve, lastCheck: s.at(1, 0)}
+
+	scheduler.check(s.at(2, 0))
+	scheduler.check(s.at(3, 0))
+
+	s.Empty(s.reconfigured)
+}
+
+// next
+
+func (s *MaintenanceTestSuite) Test_Next_ReturnsTimeOfFirstWindowChange() {
+	intervalOrig := maintenanceCheckInterval
+	defer func() { maintenanceCheckInterval = intervalOrig }()
+	maintenanceCheckInterval = 24 * time.Hour
+	s.services = append(s.services, actions.ServiceReconfigure{ServiceName: "reports", MaintenanceWindow: "01:30-04:00"})
+	scheduler := &maintenanceScheduler{serve: s.serve}
+
+	s.Equal(s.at(1, 30), scheduler.next(s.at(1, 29)))
+	s.Equal(s.at(2, 0), scheduler.next(s.at(1, 30)))
+	s.Equal(s.at(3, 0), scheduler.next(s.at(2, 59)))
+}
+
+func (s *MaintenanceTestSuite) Test_Next_ReturnsCheckInterval_WhenNoWindowChangesSooner() {
+	scheduler := &maintenanceScheduler{serve: s.serve}
+
+	s.Equal(s.at(12, 0).Add(maintenanceCheckInterval), scheduler.next(s.at(12, 0)))
+}
+
+// Suite
+
+func TestMaintenanceUnitTestSuite(t *testing.T) {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(MaintenanceTestSuite))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"./server"
+)
+
+// ocspRetryInterval is the delay of the first retry after OCSP responses could not be fetched. The delay doubles with
+// every failed attempt up to the stapling interval.
+var ocspRetryInterval = time.Minute
+
+var stapleOcsp = server.StapleOcsp
+
+// ocspStapler staples the OCSP responses of the certificates in certsDir.
+type ocspStapler struct {
+	certsDir string
+	interval time.Duration
+	retry    time.Duration
+}
+
+// startOcspStapling staples the OCSP responses immediately and every interval afterwards.
+var startOcspStapling = func(certsDir string, interval time.Duration) {
+	stapler := &ocspStapler{certsDir: certsDir, interval: interval}
+	go func() {
+		for {
+			time.Sleep(stapler.staple())
+		}
+	}()
+}
+
+// staple fetches the OCSP responses and returns the delay until the next attempt. Failed attempts are retried sooner.
+func (s *ocspStapler) staple() time.Duration {
+	if err := stapleOcsp(s.certsDir); err != nil {
+		log.Printf(err.Error())
+		if s.retry == 0 {
+			s.retry = ocspRetryInterval
+		} else {
+			s.retry *= 2
+		}
+		if s.retry > s.interval {
+			s.retry = s.interval
+		}
+		return s.retry
+	}
+	s.retry = 0
+	return s.interval
+}
@@ -0,0 +1,68 @@
+// +build !integration
+
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+type OcspTestSuite struct {
+	suite.Suite
+	errors []error
+	dirs   []string
+}
+
+func (s *OcspTestSuite) SetupTest() {
+	s.errors = []error{}
+	s.dirs = []string{}
+	stapleOcsp = func(certsDir string) error {
+		s.dirs = append(s.dirs, certsDir)
+		err := s.errors[0]
+		s.errors = s.errors[1:]
+		return err
+	}
+}
+
+// staple
+
+func (s *OcspTestSuite) Test_Staple_ReturnsInterval_WhenResponsesAreStapled() {
+	s.errors = []error{nil}
+	stapler := &ocspStapler{certsDir: "/certs", interval: time.Hour}
+
+	s.Equal(time.Hour, stapler.staple())
+	s.Equal([]string{"/certs"}, s.dirs)
+}
+
+func (s *OcspTestSuite) Test_Staple_BacksOff_WhenResponsesCannotBeFetched() {
+	err := fmt.Errorf("This is an error")
+	s.errors = []error{err, err, err, err, err, err, err, nil, err}
+	stapler := &ocspStapler{certsDir: "/certs", interval: 10 * time.Minute}
+
+	for _, expected := range []time.Duration{
+		time.Minute,
+		2 * time.Minute,
+		4 * time.Minute,
+		8 * time.Minute,
+		10 * time.Minute,
+		10 * time.Minute,
+		10 * time.Minute,
+		10 * time.Minute,
+		time.Minute,
+	} {
+		s.Equal(expected, stapler.staple())
+	}
+}
+
+// Suite
+
+func TestOcspUnitTestSuite(t *testing.T) {
+	stapleOcspOrig := stapleOcsp
+	defer func() { stapleOcsp = stapleOcspOrig }()
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(OcspTestSuite))
+}
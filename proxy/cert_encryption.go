@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// CertsDir is the directory the certificates are stored in.
+var CertsDir = "/certs"
+
+// DecryptedCertsDir is the directory HAProxy reads the certificates from when CERT_ENCRYPTION_KEY is set. It should be
+// a tmpfs so that the decrypted private keys never reach a disk. CERT_DECRYPTED_DIR takes precedence over it.
+var DecryptedCertsDir = "/dev/shm/dfp-certs"
+
+// encryptedCertBlockType is the type of the PEM block certificates are stored in once they are encrypted.
+const encryptedCertBlockType = "DFP ENCRYPTED CERTIFICATE"
+
+var certRandReader io.Reader = rand.Reader
+var readCertsDir = ioutil.ReadDir
+var mkdirAll = os.MkdirAll
+var removeFile = os.Remove
+
+// IsCertEncryptionEnabled tells whether CERT_ENCRYPTION_KEY is set.
+func IsCertEncryptionEnabled() bool {
+	return len(os.Getenv("CERT_ENCRYPTION_KEY")) > 0
+}
+
+// getCertEncryptionKey returns the AES-256 key set, base64 encoded, through CERT_ENCRYPTION_KEY.
+func getCertEncryptionKey() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv("CERT_ENCRYPTION_KEY"))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("CERT_ENCRYPTION_KEY must be a base64 encoded AES-256 key of 32 bytes")
+	}
+	return key, nil
+}
+
+func getCertCipher() (cipher.AEAD, error) {
+	key, err := getCertEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsEncryptedCert tells whether the content was encrypted by EncryptCert.
+func IsEncryptedCert(content []byte) bool {
+	block, _ := pem.Decode(content)
+	return block != nil && block.Type == encryptedCertBlockType
+}
+
+// EncryptCert encrypts the content with AES-256-GCM and the key set through CERT_ENCRYPTION_KEY and wraps it into a PEM
+// block. The content is returned as is if the encryption is disabled or the content is already encrypted.
+func EncryptCert(content []byte) ([]byte, error) {
+	if !IsCertEncryptionEnabled() || IsEncryptedCert(content) {
+		return content, nil
+	}
+	aead, err := getCertCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(certRandReader, nonce); err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  encryptedCertBlockType,
+		Bytes: aead.Seal(nonce, nonce, content, nil),
+	}), nil
+}
+
+// DecryptCert returns the content encrypted by EncryptCert. Content that is not encrypted is returned as is so that
+// certificates stored before the encryption was enabled keep working.
+func DecryptCert(content []byte) ([]byte, error) {
+	if !IsEncryptedCert(content) {
+		return content, nil
+	} else if !IsCertEncryptionEnabled() {
+		return nil, fmt.Errorf("The certificate is encrypted but CERT_ENCRYPTION_KEY is not set")
+	}
+	aead, err := getCertCipher()
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if len(block.Bytes) < aead.NonceSize() {
+		return nil, fmt.Errorf("The encrypted certificate is too short")
+	}
+	nonce, sealed := block.Bytes[:aead.NonceSize()], block.Bytes[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Could not decrypt the certificate. Was it encrypted with a different CERT_ENCRYPTION_KEY?")
+	}
+	return plain, nil
+}
+
+// ReadCert returns the decrypted content of the certificate file.
+func ReadCert(path string) ([]byte, error) {
+	content, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptCert(content)
+}
+
+// getServedCertsDir returns the directory HAProxy reads the certificates from.
+func getServedCertsDir() string {
+	if !IsCertEncryptionEnabled() {
+		return CertsDir
+	} else if dir := os.Getenv("CERT_DECRYPTED_DIR"); len(dir) > 0 {
+		return dir
+	}
+	return DecryptedCertsDir
+}
+
+// syncDecryptedCerts writes the decrypted certificates of the proxy, together with their OCSP responses, to the
+// directory HAProxy reads them from and removes the files of the certificates the proxy no longer uses. It does nothing
+// unless CERT_ENCRYPTION_KEY is set.
+func syncDecryptedCerts() error {
+	if !IsCertEncryptionEnabled() {
+		return nil
+	}
+	dir := getServedCertsDir()
+	if err := mkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("Could not create the directory %s\n%s", dir, err.Error())
+	}
+	files := map[string]bool{}
+	for name := range data.Certs {
+		content, err := ReadCert(filepath.Join(CertsDir, name))
+		if err != nil {
+			return fmt.Errorf("Could not decrypt the certificate %s\n%s", name, err.Error())
+		}
+		if err := writeFile(filepath.Join(dir, name), content, 0600); err != nil {
+			return err
+		}
+		files[name] = true
+		if ocsp, err := ReadFile(filepath.Join(CertsDir, name+".ocsp")); err == nil {
+			if err := writeFile(filepath.Join(dir, name+".ocsp"), ocsp, 0600); err != nil {
+				return err
+			}
+			files[name+".ocsp"] = true
+		}
+	}
+	infos, _ := readCertsDir(dir)
+	for _, info := range infos {
+		if info.Mode().IsRegular() && !files[info.Name()] {
+			removeFile(filepath.Join(dir, info.Name()))
+		}
+	}
+	return nil
+}
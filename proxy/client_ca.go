@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// CaCertsDir is the directory with the CA certificates client certificates are verified against.
+var CaCertsDir = "/certs/ca"
+var readCaCertsDir = ioutil.ReadDir
+
+// clientCaFile is the name of the file, stored in ConfigsPath, that combines all the CA certificates.
+const clientCaFile = "client-ca.pem"
+
+// getClientCaNames returns the names of the CA certificates. Hidden files are skipped.
+func getClientCaNames() []string {
+	infos, err := readCaCertsDir(CaCertsDir)
+	if err != nil {
+		return []string{}
+	}
+	names := []string{}
+	for _, info := range infos {
+		if info.Mode().IsRegular() && !strings.HasPrefix(info.Name(), ".") {
+			names = append(names, info.Name())
+		}
+	}
+	return names
+}
+
+// getClientCaBundle returns the content of all the CA certificates combined into a single PEM bundle.
+func getClientCaBundle(names []string) ([]byte, error) {
+	bundle := []byte{}
+	for _, name := range names {
+		content, err := ReadFile(filepath.Join(CaCertsDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the CA certificate %s\n%s", name, err.Error())
+		}
+		bundle = append(bundle, content...)
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			bundle = append(bundle, '\n')
+		}
+	}
+	return bundle, nil
+}
+
+// GetCaCommonNames returns the common names of the certificates stored in the CA file. Blocks that are not
+// certificates are ignored.
+func GetCaCommonNames(name string) ([]string, error) {
+	content, err := ReadFile(filepath.Join(CaCertsDir, name))
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	rest := content
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		} else if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil && len(cert.Subject.CommonName) > 0 {
+			names = append(names, cert.Subject.CommonName)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("The CA file %s does not contain any certificate with a common name", name)
+	}
+	return names, nil
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DomainMapFile is the name of the map file in the configs directory that routes domains to backends.
+const DomainMapFile = "domains.map"
+
+var runSocketCommand = func(command string) (string, error) {
+	conn, err := net.DialTimeout("unix", StatsSocket, time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte(command + "\n")); err != nil {
+		return "", err
+	}
+	out, err := ioutil.ReadAll(conn)
+	return string(out), err
+}
+
+// UpdateMap applies the differences between the previous and the current entries of the map file to the running
+// proxy through the admin socket. An error means that the proxy has to be reloaded for the changes to take effect.
+func UpdateMap(path string, previous, current map[string]string) error {
+	keys := []string{}
+	for key := range previous {
+		keys = append(keys, key)
+	}
+	for key := range current {
+		if _, ok := previous[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		oldValue, existed := previous[key]
+		newValue, exists := current[key]
+		command := ""
+		if !exists {
+			command = fmt.Sprintf("del map %s %s", path, key)
+		} else if !existed {
+			command = fmt.Sprintf("add map %s %s %s", path, key, newValue)
+		} else if oldValue != newValue {
+			command = fmt.Sprintf("set map %s %s %s", path, key, newValue)
+		} else {
+			continue
+		}
+		out, err := runSocketCommand(command)
+		if err != nil {
+			return fmt.Errorf("Could not update the map %s\n%s", path, err.Error())
+		} else if out = strings.TrimSpace(out); len(out) > 0 {
+			return fmt.Errorf("Could not update the map %s\n%s", path, out)
+		}
+	}
+	return nil
+}
+
+// getDomainMapRule routes the domains listed in the map file to their backends. The rule is omitted if the file is
+// missing or empty since HAProxy refuses to start with a map that does not exist.
+func (m HaProxy) getDomainMapRule() string {
+	path := fmt.Sprintf("%s/%s", m.ConfigsPath, DomainMapFile)
+	content, err := readConfigsFile(path)
+	if err != nil || len(strings.TrimSpace(string(content))) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+    use_backend %%[req.hdr(host),lower,map(%s)] if { req.hdr(host),lower,map(%s) -m found }`, path, path)
+}
@@ -0,0 +1,119 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"testing"
+)
+
+type DomainMapTestSuite struct {
+	suite.Suite
+	commands []string
+	output   string
+	err      error
+}
+
+func (s *DomainMapTestSuite) SetupTest() {
+	s.commands = []string{}
+	s.output = ""
+	s.err = nil
+	runSocketCommand = func(command string) (string, error) {
+		s.commands = append(s.commands, command)
+		return s.output, s.err
+	}
+}
+
+// UpdateMap
+
+func (s *DomainMapTestSuite) Test_UpdateMap_SendsCommandsForChangedEntries() {
+	previous := map[string]string{"a.com": "service-1-be", "b.com": "service-1-be", "c.com": "service-2-be"}
+	current := map[string]string{"a.com": "service-1-be", "b.com": "service-2-be", "d.com": "service-2-be"}
+
+	err := UpdateMap("/cfg/domains.map", previous, current)
+
+	s.NoError(err)
+	s.Equal([]string{
+		"set map /cfg/domains.map b.com service-2-be",
+		"del map /cfg/domains.map c.com",
+		"add map /cfg/domains.map d.com service-2-be",
+	}, s.commands)
+}
+
+func (s *DomainMapTestSuite) Test_UpdateMap_ReturnsError_WhenSocketIsNotAvailable() {
+	s.err = fmt.Errorf("This is an error")
+
+	err := UpdateMap("/cfg/domains.map", map[string]string{}, map[string]string{"a.com": "service-1-be"})
+
+	s.Error(err)
+}
+
+func (s *DomainMapTestSuite) Test_UpdateMap_ReturnsError_WhenProxyRejectsCommand() {
+	s.output = "Unknown map identifier. Please use #<id> or <file>.\n"
+
+	err := UpdateMap("/cfg/domains.map", map[string]string{}, map[string]string{"a.com": "service-1-be", "b.com": "service-1-be"})
+
+	s.Error(err)
+	s.Len(s.commands, 1)
+}
+
+// CreateConfigFromTemplates
+
+func (s *DomainMapTestSuite) Test_CreateConfigFromTemplates_RoutesDomainsThroughMap_WhenMapIsNotEmpty() {
+	readConfigsFileOrig := readConfigsFile
+	writeFileOrig := writeFile
+	defer func() {
+		readConfigsFile = readConfigsFileOrig
+		writeFile = writeFileOrig
+	}()
+	readConfigsFile = func(filename string) ([]byte, error) {
+		if filename == "test_configs/domains.map" {
+			return []byte("customer-1.com service-1-be\n"), nil
+		}
+		return readConfigsFileOrig(filename)
+	}
+	actualData := ""
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy("test_configs/tmpl", "test_configs", map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Contains(actualData, `    mode http
+    use_backend %[req.hdr(host),lower,map(test_configs/domains.map)] if { req.hdr(host),lower,map(test_configs/domains.map) -m found }`)
+}
+
+func (s *DomainMapTestSuite) Test_CreateConfigFromTemplates_DoesNotRouteDomainsThroughMap_WhenMapIsEmpty() {
+	readConfigsFileOrig := readConfigsFile
+	writeFileOrig := writeFile
+	defer func() {
+		readConfigsFile = readConfigsFileOrig
+		writeFile = writeFileOrig
+	}()
+	readConfigsFile = func(filename string) ([]byte, error) {
+		if filename == "test_configs/domains.map" {
+			return []byte("\n"), nil
+		}
+		return readConfigsFileOrig(filename)
+	}
+	actualData := ""
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy("test_configs/tmpl", "test_configs", map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NotContains(actualData, "domains.map")
+}
+
+// Suite
+
+func TestDomainMapUnitTestSuite(t *testing.T) {
+	runSocketCommandOrig := runSocketCommand
+	defer func() { runSocketCommand = runSocketCommandOrig }()
+	suite.Run(t, new(DomainMapTestSuite))
+}
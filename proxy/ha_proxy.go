@@ -4,11 +4,27 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"net"
 	"os"
 	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// probePath is answered by the proxy itself when ENABLE_PROBE_BACKEND is true.
+const probePath = "/dfp-probe"
+
+// HttpReuseModes are the values accepted by the http-reuse directive.
+var HttpReuseModes = []string{"never", "safe", "aggressive", "always"}
+
+// ConnectionModes are the options that set how HTTP connections are handled.
+var ConnectionModes = []string{"http-keep-alive", "http-server-close", "httpclose", "forceclose"}
+
+var cpuMapRegexp = regexp.MustCompile(`^(auto:)?(all|odd|even|\d+(-\d+)?)(/(all|odd|even|\d+(-\d+)?))?( \d+(-\d+)?)+$`)
+
 type HaProxy struct {
 	TemplatesPath string
 	ConfigsPath   string
@@ -30,6 +46,8 @@ type ConfigData struct {
 	UserList             string
 	ExtraGlobal          string
 	ExtraDefaults        string
+	ExtraFrontend        string
+	ConnectionMode       string
 }
 
 func NewHaProxy(templatesPath, configsPath string, certs map[string]bool) Proxy {
@@ -47,10 +65,41 @@ func (m HaProxy) AddCert(certName string) {
 	data.Certs[certName] = true
 }
 
+func (m HaProxy) RemoveCert(certName string) {
+	delete(data.Certs, certName)
+}
+
+// SetDefaultCert makes the certificate the one served to clients that do not send SNI. It is referenced first on the
+// bind line the next time the configuration is created.
+func SetDefaultCert(certName string) {
+	data.DefaultCert = certName
+}
+
+// GetDefaultCert returns the certificate set through SetDefaultCert.
+func GetDefaultCert() string {
+	return data.DefaultCert
+}
+
+// SetHostHeaderRules makes the configuration deny requests without the Host header and HTTP/1.0 requests the next time
+// it is created.
+func SetHostHeaderRules(requireHostHeader, denyHttp10 bool) {
+	data.RequireHostHeader, data.DenyHttp10 = requireHostHeader, denyHttp10
+}
+
+// GetHostHeaderRules returns the rules set through SetHostHeaderRules.
+func GetHostHeaderRules() (requireHostHeader, denyHttp10 bool) {
+	return data.RequireHostHeader, data.DenyHttp10
+}
+
+// GetCerts returns the decrypted content of the certificates of the proxy. Certificates that cannot be decrypted are
+// returned as they are stored.
 func (m HaProxy) GetCerts() map[string]string {
 	certs := map[string]string{}
 	for cert, _ := range data.Certs {
-		content, _ := ReadFile(fmt.Sprintf("/certs/%s", cert))
+		content, _ := ReadFile(fmt.Sprintf("%s/%s", CertsDir, cert))
+		if decrypted, err := DecryptCert(content); err == nil {
+			content = decrypted
+		}
 		certs[cert] = string(content)
 	}
 	return certs
@@ -65,6 +114,9 @@ func (m HaProxy) RunCmd(extraArgs []string) error {
 		"/var/run/haproxy.pid",
 	}
 	args = append(args, extraArgs...)
+	if err := syncDecryptedCerts(); err != nil {
+		return err
+	}
 	cmd := exec.Command("haproxy", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -75,15 +127,52 @@ func (m HaProxy) RunCmd(extraArgs []string) error {
 	return nil
 }
 
+// CreateConfigFromTemplates writes the configuration assembled from the templates to ConfigsPath. The CA certificates,
+// if there are any, are combined into a single file in the same directory so that the bind can reference it. A
+// configuration that exceeds the limits returned by GetConfigLimits is not written and a ConfigLimitError is returned.
 func (m HaProxy) CreateConfigFromTemplates() error {
 	configsContent, err := m.getConfigs()
 	if err != nil {
 		return err
 	}
+	if err := m.checkConfigLimits(configsContent); err != nil {
+		return err
+	}
+	if names := getClientCaNames(); len(names) > 0 {
+		bundle, err := getClientCaBundle(names)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(fmt.Sprintf("%s/%s", m.ConfigsPath, clientCaFile), bundle, 0664); err != nil {
+			return err
+		}
+	}
 	configPath := fmt.Sprintf("%s/haproxy.cfg", m.ConfigsPath)
 	return writeFile(configPath, []byte(configsContent), 0664)
 }
 
+// CheckConfig assembles the configuration from the templates in the directory, writes it to the same directory and
+// checks it with haproxy -c. The running proxy is not affected.
+func CheckConfig(templatesPath string) error {
+	p := HaProxy{TemplatesPath: templatesPath, ConfigsPath: templatesPath}
+	if err := p.CreateConfigFromTemplates(); err != nil {
+		return err
+	}
+	return checkConfigFile(fmt.Sprintf("%s/haproxy.cfg", templatesPath))
+}
+
+// checkConfigFile checks the configuration file with haproxy -c and returns its output if the check fails.
+func checkConfigFile(configPath string) error {
+	var out bytes.Buffer
+	cmd := exec.Command("haproxy", "-c", "-f", configPath)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmdRunHa(cmd); err != nil {
+		return fmt.Errorf("The configuration is invalid\n%s\n%s", err.Error(), strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
 func (m HaProxy) ReadConfig() (string, error) {
 	configPath := fmt.Sprintf("%s/haproxy.cfg", m.ConfigsPath)
 	out, err := ReadFile(configPath)
@@ -93,40 +182,101 @@ func (m HaProxy) ReadConfig() (string, error) {
 	return string(out[:]), nil
 }
 
+// Reload reloads the proxy or, if MIN_RELOAD_INTERVAL did not elapse since the previous reload, defers it until it
+// does. Errors of deferred reloads are only logged.
 func (m HaProxy) Reload() error {
+	if delay := reloads.Defer(getMinReloadInterval(), m.runDeferredReload); delay > 0 {
+		logPrintf("The reload is deferred by %s since MIN_RELOAD_INTERVAL did not elapse", delay)
+		return nil
+	}
+	return m.reload()
+}
+
+func (m HaProxy) runDeferredReload() {
+	if err := m.reload(); err != nil {
+		logPrintf("The deferred reload failed\n%s", err.Error())
+	}
+}
+
+func (m HaProxy) reload() error {
 	logPrintf("Reloading the proxy")
 	pidPath := "/var/run/haproxy.pid"
 	pid, err := readPidFile(pidPath)
 	if err != nil {
 		return fmt.Errorf("Could not read the %s file\n%s", pidPath, err.Error())
 	}
+	changes := takeReloadChanges()
+	hooks := []HookResult{}
+	if result := runPreReloadHook(changes); result != nil {
+		hooks = append(hooks, *result)
+		if len(result.Error) > 0 {
+			if isPreReloadHookFatal() {
+				addAbortedReloadEntry(hooks)
+				restoreReloadChanges(changes)
+				return fmt.Errorf("The reload was aborted since the pre-reload hook failed\n%s", result.Error)
+			}
+			logPrintf("WARNING: The pre-reload hook failed\n%s", result.Error)
+		}
+	}
+	before, sampled := snapshotStats()
 	cmdArgs := []string{"-sf", string(pid)}
-	return HaProxy{}.RunCmd(cmdArgs)
+	if err := (HaProxy{}.RunCmd(cmdArgs)); err != nil {
+		return err
+	}
+	// Sampling must never delay the response beyond the reload itself
+	entry := addReloadEntry(sampled)
+	if sampled {
+		go sampleReloadErrors(entry, before, getReloadErrorsWindow())
+	}
+	if hooks = append(hooks, runPostReloadHooks(changes)...); len(hooks) > 0 {
+		setReloadHooks(entry, hooks)
+	}
+	return nil
 }
 
 func (m HaProxy) getConfigs() (string, error) {
 	contentArr := []string{}
 	configsFiles := []string{"haproxy.tmpl"}
+	internalFiles := []string{}
 	configs, err := readConfigsDir(m.TemplatesPath)
 	if err != nil {
 		return "", fmt.Errorf("Could not read the directory %s\n%s", m.TemplatesPath, err.Error())
 	}
 	for _, fi := range configs {
-		if strings.HasSuffix(fi.Name(), "-fe.cfg") {
+		if strings.HasSuffix(fi.Name(), "-internal-fe.cfg") {
+			internalFiles = append(internalFiles, fi.Name())
+		} else if strings.HasSuffix(fi.Name(), "-fe.cfg") {
 			configsFiles = append(configsFiles, fi.Name())
 		}
 	}
+	publicFeCount := len(configsFiles)
+	// Internal services are never exposed through the public frontend. They are omitted if the internal port is not set.
+	if len(internalFiles) > 0 && len(os.Getenv("INTERNAL_PORT")) > 0 {
+		configsFiles = append(configsFiles, internalFiles...)
+	} else {
+		internalFiles = []string{}
+	}
 	for _, fi := range configs {
 		if strings.HasSuffix(fi.Name(), "-be.cfg") {
 			configsFiles = append(configsFiles, fi.Name())
 		}
 	}
-	for _, file := range configsFiles {
+	for i, file := range configsFiles {
 		templateBytes, err := readConfigsFile(fmt.Sprintf("%s/%s", m.TemplatesPath, file))
 		if err != nil {
 			return "", fmt.Errorf("Could not read the file %s\n%s", file, err.Error())
 		}
+		if len(internalFiles) > 0 && i == publicFeCount {
+			contentArr = append(contentArr, fmt.Sprintf(`frontend internal
+    bind *:%s
+    mode http`, os.Getenv("INTERNAL_PORT")))
+		}
 		contentArr = append(contentArr, string(templateBytes))
+		if i == publicFeCount-1 {
+			if rules := m.getHostHeaderRules(); len(rules) > 0 {
+				contentArr = append(contentArr, rules)
+			}
+		}
 	}
 	if len(configsFiles) == 1 {
 		contentArr = append(contentArr, `    acl url_dummy path_beg /dummy
@@ -135,20 +285,208 @@ func (m HaProxy) getConfigs() (string, error) {
 backend dummy-be
     server dummy 1.1.1.1:1111 check`)
 	}
+	if backend := m.getProbeBackend(); len(backend) > 0 {
+		contentArr = append(contentArr, backend)
+	}
+	if backend := m.getAcmeChallengeBackend(); len(backend) > 0 {
+		contentArr = append(contentArr, backend)
+	}
+	prometheusFrontend, err := m.getPrometheusFrontend()
+	if err != nil {
+		return "", err
+	} else if len(prometheusFrontend) > 0 {
+		contentArr = append(contentArr, prometheusFrontend)
+	}
 	tmpl, _ := template.New("contentTemplate").Parse(
 		strings.Join(contentArr, "\n\n"),
 	)
+	data, err := m.getConfigData()
+	if err != nil {
+		return "", err
+	}
 	var content bytes.Buffer
-	tmpl.Execute(&content, m.getConfigData())
+	tmpl.Execute(&content, data)
 	return content.String(), nil
 }
 
-func (m HaProxy) getConfigData() ConfigData {
+// GetPrometheusPort returns the port of the frontend of the Prometheus exporter built into HAProxy or an empty string if
+// ENABLE_HAPROXY_PROMETHEUS is not set to true. PROMETHEUS_PORT defaults to 8405.
+func GetPrometheusPort() string {
+	if !strings.EqualFold(os.Getenv("ENABLE_HAPROXY_PROMETHEUS"), "true") {
+		return ""
+	} else if port := os.Getenv("PROMETHEUS_PORT"); len(port) > 0 {
+		return port
+	}
+	return "8405"
+}
+
+// getPrometheusFrontend returns the frontend that serves the metrics of the Prometheus exporter built into HAProxy on
+// /metrics. Requests from outside ADMIN_NETWORKS are denied if it is set.
+func (m HaProxy) getPrometheusFrontend() (string, error) {
+	port := GetPrometheusPort()
+	if len(port) == 0 {
+		return "", nil
+	} else if !VersionAtLeast(2, 0) {
+		return "", fmt.Errorf("ENABLE_HAPROXY_PROMETHEUS requires HAProxy 2.0 or newer. The detected version is %s", GetVersion())
+	} else if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		return "", fmt.Errorf("PROMETHEUS_PORT must be a port. The value is %s", port)
+	}
+	for _, used := range []string{"80", "443", os.Getenv("INTERNAL_PORT")} {
+		if port == used {
+			return "", fmt.Errorf("PROMETHEUS_PORT %s is already used by the proxy", port)
+		}
+	}
+	frontend := fmt.Sprintf(`frontend prometheus
+    bind *:%s
+    mode http`, port)
+	if networks := os.Getenv("ADMIN_NETWORKS"); len(networks) > 0 {
+		ranges := []string{}
+		for _, network := range strings.Split(networks, ",") {
+			network = strings.TrimSpace(network)
+			if _, _, err := net.ParseCIDR(network); err != nil && net.ParseIP(network) == nil {
+				return "", fmt.Errorf("ADMIN_NETWORKS entries must be IP addresses or CIDRs (e.g. 10.0.0.0/8). The invalid entry is %s", network)
+			}
+			ranges = append(ranges, network)
+		}
+		frontend += fmt.Sprintf(`
+    acl admin_network src %s
+    http-request deny if !admin_network`, strings.Join(ranges, " "))
+	}
+	return frontend + `
+    http-request use-service prometheus-exporter if { path /metrics }`, nil
+}
+
+// getAcmeChallengeBackend returns the backend that sends ACME HTTP-01 challenges to the API of the proxy itself.
+func (m HaProxy) getAcmeChallengeBackend() string {
+	if !strings.EqualFold(os.Getenv("ENABLE_ACME_CHALLENGES"), "true") {
+		return ""
+	}
+	port := os.Getenv("PORT")
+	if len(port) == 0 {
+		port = "8080"
+	}
+	return fmt.Sprintf(`backend acme-challenge-be
+    mode http
+    server acme-challenge 127.0.0.1:%s`, port)
+}
+
+// getProbeRules routes the probes sent to probePath to the probe backend before the rules of the services so that
+// they never reach a service. Probes are exempt from the Host header rules. HAProxy versions older than 2.2 cannot
+// return a response from a backend so the frontend answers the probes through monitor-uri instead.
+func (m HaProxy) getProbeRules() string {
+	if !strings.EqualFold(os.Getenv("ENABLE_PROBE_BACKEND"), "true") {
+		return ""
+	} else if !VersionAtLeast(2, 2) {
+		return fmt.Sprintf(`
+    monitor-uri %s`, probePath)
+	}
+	return fmt.Sprintf(`
+    acl url_dfp_probe path %s
+    http-request set-var(txn.host_exempt) bool(true) if url_dfp_probe
+    use_backend dfp-probe-be if url_dfp_probe`, probePath)
+}
+
+// getProbeBackend returns the backend that answers the probes with the status 200 generated by HAProxy itself.
+func (m HaProxy) getProbeBackend() string {
+	if !strings.EqualFold(os.Getenv("ENABLE_PROBE_BACKEND"), "true") || !VersionAtLeast(2, 2) {
+		return ""
+	}
+	return `backend dfp-probe-be
+    mode http
+    http-request return status 200 content-type text/plain string ok`
+}
+
+// getHostHeaderRules denies requests without the Host header and HTTP/1.0 requests as set through SetHostHeaderRules.
+// The rules follow the service frontends so that services reconfigured with allowMissingHost can exempt themselves.
+func (m HaProxy) getHostHeaderRules() string {
+	rules := []string{}
+	if data.RequireHostHeader {
+		rules = append(rules, "    http-request deny deny_status 400 if !{ req.hdr(Host) -m found } !{ var(txn.host_exempt) -m bool }")
+	}
+	if data.DenyHttp10 {
+		rules = append(rules, "    http-request deny deny_status 400 if { req.ver 1.0 } !{ var(txn.host_exempt) -m bool }")
+	}
+	return strings.Join(rules, "\n")
+}
+
+// getTrustedProxyRules replaces the source of requests coming from TRUSTED_PROXY_CIDRS with the last address of their
+// X-Forwarded-For header, the one added by the trusted proxy, so that the rules that follow see the client. The header
+// of the requests coming from anywhere else is removed since the clients could set it to any address. The rules come
+// first so that all the src based rules of the frontend and the services use the replaced source.
+func (m HaProxy) getTrustedProxyRules() (string, error) {
+	cidrs := os.Getenv("TRUSTED_PROXY_CIDRS")
+	if len(cidrs) == 0 {
+		return "", nil
+	}
+	ranges := []string{}
+	for _, cidr := range strings.Split(cidrs, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+			return "", fmt.Errorf("TRUSTED_PROXY_CIDRS entries must be IP addresses or CIDRs (e.g. 10.0.0.0/8). The invalid entry is %s", cidr)
+		}
+		ranges = append(ranges, cidr)
+	}
+	return fmt.Sprintf(`
+    acl trusted_proxy src %s
+    http-request del-header X-Forwarded-For unless trusted_proxy
+    http-request set-src hdr_ip(X-Forwarded-For,-1) if trusted_proxy { req.hdr(X-Forwarded-For) -m found }`, strings.Join(ranges, " ")), nil
+}
+
+// getTracingRules starts a trace context when the request does not contain one. The txn.trace_started variable
+// tells services whether the context was started by the proxy so that they can apply their own sample rate.
+func (m HaProxy) getTracingRules() string {
+	var headers []string
+	switch strings.ToLower(os.Getenv("TRACING_HEADERS")) {
+	case "b3":
+		headers = []string{
+			"X-B3-TraceId %[uuid,regsub(-,,g)]",
+			"X-B3-SpanId %[uuid,regsub(-,,g),bytes(0,16)]",
+			"X-B3-Sampled 1",
+		}
+	case "w3c":
+		headers = []string{
+			"traceparent 00-%[uuid,regsub(-,,g)]-%[uuid,regsub(-,,g),bytes(0,16)]-01",
+		}
+	default:
+		return ""
+	}
+	name := strings.Split(headers[0], " ")[0]
+	rules := fmt.Sprintf(`
+    http-request set-var(txn.trace_started) bool(true) unless { req.hdr(%s) -m found }`, name)
+	for _, header := range headers {
+		rules += fmt.Sprintf(`
+    http-request set-header %s if { var(txn.trace_started) -m bool }`, header)
+	}
+	return rules
+}
+
+func (m HaProxy) getConfigData() (ConfigData, error) {
+	if IsCertEncryptionEnabled() {
+		if _, err := getCertEncryptionKey(); err != nil {
+			return ConfigData{}, err
+		}
+	}
 	certs := []string{}
 	if len(data.Certs) > 0 {
 		certs = append(certs, " ssl")
-		for cert, _ := range data.Certs {
-			certs = append(certs, fmt.Sprintf("crt /certs/%s", cert))
+		// HAProxy serves the first certificate to clients without SNI
+		names := []string{}
+		for cert := range data.Certs {
+			names = append(names, cert)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if names[i] == data.DefaultCert || names[j] == data.DefaultCert {
+				return names[i] == data.DefaultCert
+			}
+			return names[i] < names[j]
+		})
+		for _, cert := range names {
+			certs = append(certs, fmt.Sprintf("crt %s/%s", getServedCertsDir(), cert))
+		}
+		// Certificates are requested but not enforced so that services without clientCertVerify are not affected.
+		// The services that require them deny the requests in their own rules.
+		if len(getClientCaNames()) > 0 {
+			certs = append(certs, fmt.Sprintf("verify optional ca-file %s/%s ca-ignore-err all crt-ignore-err all", m.ConfigsPath, clientCaFile))
 		}
 	}
 	d := ConfigData{
@@ -161,6 +499,7 @@ func (m HaProxy) getConfigData() ConfigData {
 		TimeoutHttpKeepAlive: "15",
 		StatsUser:            "admin",
 		StatsPass:            "admin",
+		ConnectionMode:       "http-server-close",
 	}
 	if len(os.Getenv("TIMEOUT_CONNECT")) > 0 {
 		d.TimeoutConnect = os.Getenv("TIMEOUT_CONNECT")
@@ -194,6 +533,31 @@ func (m HaProxy) getConfigData() ConfigData {
 			d.UserList = fmt.Sprintf("%s    user %s insecure-password %s\n", d.UserList, userPass[0], userPass[1])
 		}
 	}
+	tuning, err := m.getGlobalTuning()
+	if err != nil {
+		return d, err
+	}
+	d.ExtraGlobal += tuning
+	trustedProxyRules, err := m.getTrustedProxyRules()
+	if err != nil {
+		return d, err
+	}
+	d.ExtraFrontend += trustedProxyRules
+	d.ExtraFrontend += m.getProbeRules()
+	d.ExtraFrontend += m.getTracingRules()
+	d.ExtraFrontend += m.getDomainMapRule()
+	if strings.EqualFold(os.Getenv("ENABLE_ACME_CHALLENGES"), "true") {
+		d.ExtraFrontend += `
+    acl url_acme_challenge path_beg /.well-known/acme-challenge/
+    use_backend acme-challenge-be if url_acme_challenge`
+	}
+	if len(os.Getenv("SYSLOG_LISTENER")) > 0 {
+		d.ExtraGlobal += fmt.Sprintf(`
+    log %s local0`, os.Getenv("SYSLOG_LISTENER"))
+		d.ExtraDefaults += `
+    log     global
+    option  httplog`
+	}
 	if strings.EqualFold(os.Getenv("DEBUG"), "true") {
 		d.ExtraGlobal += `
     debug`
@@ -202,5 +566,74 @@ func (m HaProxy) getConfigData() ConfigData {
     option  dontlognull
     option  dontlog-normal`
 	}
-	return d
+	if reuse := os.Getenv("HTTP_REUSE"); len(reuse) > 0 {
+		if !IsHttpReuseMode(reuse) {
+			return d, fmt.Errorf("HTTP_REUSE must be one of %s. The value is %s", strings.Join(HttpReuseModes, ", "), reuse)
+		}
+		d.ExtraDefaults += fmt.Sprintf(`
+    http-reuse %s`, reuse)
+		// Server connections are closed after each response otherwise
+		if reuse != "never" {
+			d.ConnectionMode = "http-keep-alive"
+		}
+	}
+	return d, nil
+}
+
+// IsHttpReuseMode returns true if the value is one of HttpReuseModes.
+func IsHttpReuseMode(value string) bool {
+	for _, mode := range HttpReuseModes {
+		if value == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// IsConnectionMode returns true if the value is one of ConnectionModes.
+func IsConnectionMode(value string) bool {
+	for _, mode := range ConnectionModes {
+		if value == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// getGlobalTuning returns the threading, CPU pinning and connection limit of the global section.
+// HAPROXY_CPU_MAP entries are separated with comma (e.g. auto:1/1-4 0-3,1/5 4).
+func (m HaProxy) getGlobalTuning() (string, error) {
+	tuning := ""
+	if threads := os.Getenv("HAPROXY_THREADS"); len(threads) > 0 {
+		count := runtime.NumCPU()
+		if !strings.EqualFold(threads, "auto") {
+			var err error
+			if count, err = strconv.Atoi(threads); err != nil || count < 1 {
+				return "", fmt.Errorf("HAPROXY_THREADS must be a positive number or auto. The value is %s", threads)
+			}
+		}
+		if !VersionAtLeast(1, 8) {
+			return "", fmt.Errorf("HAPROXY_THREADS requires HAProxy 1.8 or newer. The detected version is %s", GetVersion())
+		}
+		tuning += fmt.Sprintf(`
+    nbthread %d`, count)
+	}
+	if cpuMap := os.Getenv("HAPROXY_CPU_MAP"); len(cpuMap) > 0 {
+		for _, entry := range strings.Split(cpuMap, ",") {
+			entry = strings.TrimSpace(entry)
+			if !cpuMapRegexp.MatchString(entry) {
+				return "", fmt.Errorf("HAPROXY_CPU_MAP entries must be in the format [auto:]PROCESS/THREAD CPU... (e.g. auto:1/1-4 0-3). The invalid entry is %s", entry)
+			}
+			tuning += fmt.Sprintf(`
+    cpu-map %s`, entry)
+		}
+	}
+	if maxconn := os.Getenv("HAPROXY_MAXCONN_GLOBAL"); len(maxconn) > 0 {
+		if count, err := strconv.Atoi(maxconn); err != nil || count < 1 {
+			return "", fmt.Errorf("HAPROXY_MAXCONN_GLOBAL must be a positive number. The value is %s", maxconn)
+		}
+		tuning += fmt.Sprintf(`
+    maxconn %s`, maxconn)
+	}
+	return tuning, nil
 }
@@ -5,8 +5,11 @@ package proxy
 import (
 	"fmt"
 	"github.com/stretchr/testify/suite"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -29,6 +32,7 @@ func TestHaProxyUnitTestSuite(t *testing.T) {
 	s := new(HaProxyTestSuite)
 	s.TemplateContent = `global
     pidfile /var/run/haproxy.pid
+    stats socket /var/run/haproxy.sock mode 600 level admin
     tune.ssl.default-dh-param 2048
 
 defaults
@@ -171,6 +175,83 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_WritesCfgContentsIntoFi
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsInternalFrontend_WhenInternalPortIsSet() {
+	defer func() { os.Unsetenv("INTERNAL_PORT") }()
+	os.Setenv("INTERNAL_PORT", "8081")
+	var actualData string
+	expectedData := fmt.Sprintf(
+		"%s%s",
+		s.TemplateContent,
+		`
+
+config1 fe content
+
+config2 fe content
+
+frontend internal
+    bind *:8081
+    mode http
+
+config3 internal fe content
+
+config1 be content
+
+config2 be content`,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_OmitsInternalServices_WhenInternalPortIsNotSet() {
+	os.Unsetenv("INTERNAL_PORT")
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NotContains(actualData, "config3 internal fe content")
+	s.NotContains(actualData, "frontend internal")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsCertsToFrontendsOfServices() {
+	templatesPath, _ := ioutil.TempDir("", "templates")
+	defer os.RemoveAll(templatesPath)
+	files := map[string]string{
+		"haproxy.tmpl":     "frontend services",
+		"service-1-fe.cfg": "    service-1 fe content",
+		"service-1-be.cfg": `service-1 be content
+
+frontend services_8443
+    bind *:8443{{.CertsString}}
+    mode http
+    service-1 fe content`,
+	}
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(templatesPath, name), []byte(content), 0664)
+	}
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(templatesPath, s.ConfigsPath, map[string]bool{"my-cert.pem": true}).CreateConfigFromTemplates()
+
+	s.Contains(actualData, `frontend services_8443
+    bind *:8443 ssl crt /certs/my-cert.pem
+    mode http
+    service-1 fe content`)
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsDebug() {
 	debugOrig := os.Getenv("DEBUG")
 	defer func() { os.Setenv("DEBUG", debugOrig) }()
@@ -193,6 +274,519 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsDebug() {
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsGlobalTuning_WhenEnvVarsArePresent() {
+	defer func() {
+		os.Unsetenv("HAPROXY_VERSION")
+		os.Unsetenv("HAPROXY_THREADS")
+		os.Unsetenv("HAPROXY_CPU_MAP")
+		os.Unsetenv("HAPROXY_MAXCONN_GLOBAL")
+	}()
+	os.Setenv("HAPROXY_VERSION", "1.8")
+	os.Setenv("HAPROXY_THREADS", "4")
+	os.Setenv("HAPROXY_CPU_MAP", "auto:1/1-2 0-1, 1/3 2")
+	os.Setenv("HAPROXY_MAXCONN_GLOBAL", "20000")
+	var actualData string
+	tmpl := strings.Replace(s.TemplateContent, "tune.ssl.default-dh-param 2048", "tune.ssl.default-dh-param 2048\n    nbthread 4\n    cpu-map auto:1/1-2 0-1\n    cpu-map 1/3 2\n    maxconn 20000", -1)
+	expectedData := fmt.Sprintf(
+		"%s%s",
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NoError(err)
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_UsesNumberOfCpus_WhenThreadsIsAuto() {
+	defer func() {
+		os.Unsetenv("HAPROXY_VERSION")
+		os.Unsetenv("HAPROXY_THREADS")
+	}()
+	os.Setenv("HAPROXY_VERSION", "2.2")
+	os.Setenv("HAPROXY_THREADS", "auto")
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Contains(actualData, fmt.Sprintf("\n    nbthread %d\n", runtime.NumCPU()))
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_DoesNotAddGlobalTuning_WhenEnvVarsAreNotPresent() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NotContains(actualData, "nbthread")
+	s.NotContains(actualData, "cpu-map")
+	s.NotContains(actualData, "global\n    maxconn")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenGlobalTuningIsInvalid() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "2.2")
+	writeFileCalled := false
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		writeFileCalled = true
+		return nil
+	}
+	cases := []struct {
+		variable string
+		value    string
+	}{
+		{"HAPROXY_THREADS", "0"},
+		{"HAPROXY_THREADS", "many"},
+		{"HAPROXY_CPU_MAP", "1/1"},
+		{"HAPROXY_CPU_MAP", "1/1 0; debug"},
+		{"HAPROXY_MAXCONN_GLOBAL", "-1"},
+		{"HTTP_REUSE", "sometimes"},
+		{"TRUSTED_PROXY_CIDRS", "10.0.0.0/33"},
+		{"TRUSTED_PROXY_CIDRS", "10.0.0.0/8, nlb"},
+	}
+	for _, c := range cases {
+		os.Setenv(c.variable, c.value)
+
+		err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+		os.Unsetenv(c.variable)
+		s.Error(err, c.value)
+		s.Contains(err.Error(), c.variable)
+	}
+	s.False(writeFileCalled)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsHttpReuse_WhenHttpReuseIsPresent() {
+	defer func() { os.Unsetenv("HTTP_REUSE") }()
+	os.Setenv("HTTP_REUSE", "safe")
+	var actualData string
+	tmpl := strings.Replace(s.TemplateContent, "    option  dontlog-normal\n    option  http-server-close\n", "    option  dontlog-normal\n    http-reuse safe\n    option  http-keep-alive\n", -1)
+	expectedData := fmt.Sprintf(
+		"%s%s",
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NoError(err)
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_KeepsServerClose_WhenHttpReuseIsNever() {
+	defer func() { os.Unsetenv("HTTP_REUSE") }()
+	os.Setenv("HTTP_REUSE", "never")
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Contains(actualData, "\n    http-reuse never\n    option  http-server-close\n")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenThreadsAreNotSupported() {
+	defer func() {
+		os.Unsetenv("HAPROXY_VERSION")
+		os.Unsetenv("HAPROXY_THREADS")
+	}()
+	os.Setenv("HAPROXY_VERSION", "1.7")
+	os.Setenv("HAPROXY_THREADS", "4")
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.EqualError(err, "HAPROXY_THREADS requires HAProxy 1.8 or newer. The detected version is 1.7")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsLogTarget_WhenSyslogListenerIsPresent() {
+	defer func() { os.Unsetenv("SYSLOG_LISTENER") }()
+	os.Setenv("SYSLOG_LISTENER", "/var/run/haproxy-log.sock")
+	var actualData string
+	tmpl := strings.Replace(s.TemplateContent, "tune.ssl.default-dh-param 2048", "tune.ssl.default-dh-param 2048\n    log /var/run/haproxy-log.sock local0", -1)
+	tmpl = strings.Replace(tmpl, "    option  dontlognull\n", "    log     global\n    option  httplog\n    option  dontlognull\n", -1)
+	expectedData := fmt.Sprintf(
+		"%s%s",
+		tmpl,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReplacesSourceOfTrustedProxies_WhenTrustedProxyCidrsIsSet() {
+	defer func() { os.Unsetenv("TRUSTED_PROXY_CIDRS") }()
+	os.Setenv("TRUSTED_PROXY_CIDRS", "10.0.0.0/8, 192.168.1.10")
+	var actualData string
+	expectedData := fmt.Sprintf(
+		"%s%s%s",
+		s.TemplateContent,
+		`
+    acl trusted_proxy src 10.0.0.0/8 192.168.1.10
+    http-request del-header X-Forwarded-For unless trusted_proxy
+    http-request set-src hdr_ip(X-Forwarded-For,-1) if trusted_proxy { req.hdr(X-Forwarded-For) -m found }`,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_PutsTrustedProxyRulesBeforeOtherFrontendRules() {
+	defer func() {
+		os.Unsetenv("TRUSTED_PROXY_CIDRS")
+		os.Unsetenv("ENABLE_PROBE_BACKEND")
+		os.Unsetenv("TRACING_HEADERS")
+	}()
+	os.Setenv("TRUSTED_PROXY_CIDRS", "10.0.0.0/8")
+	os.Setenv("ENABLE_PROBE_BACKEND", "true")
+	os.Setenv("TRACING_HEADERS", "w3c")
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	setSrc := strings.Index(actualData, "http-request set-src")
+	s.True(setSrc > 0)
+	s.True(setSrc < strings.Index(actualData, "dfp-probe"))
+	s.True(setSrc < strings.Index(actualData, "txn.trace_started"))
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsB3Headers_WhenTracingHeadersIsB3() {
+	defer func() { os.Unsetenv("TRACING_HEADERS") }()
+	os.Setenv("TRACING_HEADERS", "b3")
+	var actualData string
+	expectedData := fmt.Sprintf(
+		"%s%s%s",
+		s.TemplateContent,
+		`
+    http-request set-var(txn.trace_started) bool(true) unless { req.hdr(X-B3-TraceId) -m found }
+    http-request set-header X-B3-TraceId %[uuid,regsub(-,,g)] if { var(txn.trace_started) -m bool }
+    http-request set-header X-B3-SpanId %[uuid,regsub(-,,g),bytes(0,16)] if { var(txn.trace_started) -m bool }
+    http-request set-header X-B3-Sampled 1 if { var(txn.trace_started) -m bool }`,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsTraceparent_WhenTracingHeadersIsW3c() {
+	defer func() { os.Unsetenv("TRACING_HEADERS") }()
+	os.Setenv("TRACING_HEADERS", "w3c")
+	var actualData string
+	expectedData := fmt.Sprintf(
+		"%s%s%s",
+		s.TemplateContent,
+		`
+    http-request set-var(txn.trace_started) bool(true) unless { req.hdr(traceparent) -m found }
+    http-request set-header traceparent 00-%[uuid,regsub(-,,g)]-%[uuid,regsub(-,,g),bytes(0,16)]-01 if { var(txn.trace_started) -m bool }`,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RoutesAcmeChallengesToApi_WhenEnableAcmeChallengesIsTrue() {
+	defer func() {
+		os.Unsetenv("ENABLE_ACME_CHALLENGES")
+		os.Unsetenv("PORT")
+	}()
+	os.Setenv("ENABLE_ACME_CHALLENGES", "true")
+	os.Setenv("PORT", "9090")
+	var actualData string
+	expectedData := fmt.Sprintf(
+		"%s%s%s\n\n%s",
+		s.TemplateContent,
+		`
+    acl url_acme_challenge path_beg /.well-known/acme-challenge/
+    use_backend acme-challenge-be if url_acme_challenge`,
+		s.ServicesContent,
+		`backend acme-challenge-be
+    mode http
+    server acme-challenge 127.0.0.1:9090`,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_DoesNotRouteAcmeChallenges_WhenEnableAcmeChallengesIsNotSet() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NotContains(actualData, "acme-challenge")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsProbeBackend_WhenEnableProbeBackendIsTrue() {
+	defer func() {
+		os.Unsetenv("ENABLE_PROBE_BACKEND")
+		os.Unsetenv("HAPROXY_VERSION")
+	}()
+	os.Setenv("ENABLE_PROBE_BACKEND", "true")
+	os.Setenv("HAPROXY_VERSION", "2.2")
+	var actualData string
+	expectedData := fmt.Sprintf(
+		"%s%s%s\n\n%s",
+		s.TemplateContent,
+		`
+    acl url_dfp_probe path /dfp-probe
+    http-request set-var(txn.host_exempt) bool(true) if url_dfp_probe
+    use_backend dfp-probe-be if url_dfp_probe`,
+		s.ServicesContent,
+		`backend dfp-probe-be
+    mode http
+    http-request return status 200 content-type text/plain string ok`,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsPrometheusFrontend_WhenEnableHaProxyPrometheusIsTrue() {
+	defer func() {
+		os.Unsetenv("ENABLE_HAPROXY_PROMETHEUS")
+		os.Unsetenv("HAPROXY_VERSION")
+	}()
+	os.Setenv("ENABLE_HAPROXY_PROMETHEUS", "true")
+	os.Setenv("HAPROXY_VERSION", "2.0")
+	var actualData string
+	expectedData := fmt.Sprintf(
+		"%s%s\n\n%s",
+		s.TemplateContent,
+		s.ServicesContent,
+		`frontend prometheus
+    bind *:8405
+    mode http
+    http-request use-service prometheus-exporter if { path /metrics }`,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NoError(err)
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_DeniesRequestsFromOutsideAdminNetworksToPrometheusFrontend() {
+	defer func() {
+		os.Unsetenv("ENABLE_HAPROXY_PROMETHEUS")
+		os.Unsetenv("PROMETHEUS_PORT")
+		os.Unsetenv("ADMIN_NETWORKS")
+		os.Unsetenv("HAPROXY_VERSION")
+	}()
+	os.Setenv("ENABLE_HAPROXY_PROMETHEUS", "true")
+	os.Setenv("PROMETHEUS_PORT", "9101")
+	os.Setenv("ADMIN_NETWORKS", "10.0.0.0/8, 192.168.1.10")
+	os.Setenv("HAPROXY_VERSION", "2.4")
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NoError(err)
+	s.True(strings.HasSuffix(actualData, `
+
+frontend prometheus
+    bind *:9101
+    mode http
+    acl admin_network src 10.0.0.0/8 192.168.1.10
+    http-request deny if !admin_network
+    http-request use-service prometheus-exporter if { path /metrics }`), actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_DoesNotAddPrometheusFrontend_WhenEnableHaProxyPrometheusIsNotTrue() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NotContains(actualData, "prometheus")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenPrometheusFrontendIsInvalid() {
+	defer func() {
+		os.Unsetenv("ENABLE_HAPROXY_PROMETHEUS")
+		os.Unsetenv("HAPROXY_VERSION")
+	}()
+	os.Setenv("ENABLE_HAPROXY_PROMETHEUS", "true")
+	writeFileCalled := false
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		writeFileCalled = true
+		return nil
+	}
+	cases := []struct {
+		version  string
+		variable string
+		value    string
+		expected string
+	}{
+		{"1.9", "", "", "ENABLE_HAPROXY_PROMETHEUS requires HAProxy 2.0 or newer. The detected version is 1.9"},
+		{"2.2", "PROMETHEUS_PORT", "metrics", "PROMETHEUS_PORT must be a port. The value is metrics"},
+		{"2.2", "PROMETHEUS_PORT", "443", "PROMETHEUS_PORT 443 is already used by the proxy"},
+		{"2.2", "ADMIN_NETWORKS", "10.0.0.0/8,intranet", "ADMIN_NETWORKS entries must be IP addresses or CIDRs (e.g. 10.0.0.0/8). The invalid entry is intranet"},
+	}
+	for _, c := range cases {
+		os.Setenv("HAPROXY_VERSION", c.version)
+		if len(c.variable) > 0 {
+			os.Setenv(c.variable, c.value)
+		}
+
+		err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+		if len(c.variable) > 0 {
+			os.Unsetenv(c.variable)
+		}
+		s.EqualError(err, c.expected)
+	}
+	s.False(writeFileCalled)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsProbeMonitorUri_WhenHaProxyIsOlderThan22() {
+	defer func() {
+		os.Unsetenv("ENABLE_PROBE_BACKEND")
+		os.Unsetenv("HAPROXY_VERSION")
+	}()
+	os.Setenv("ENABLE_PROBE_BACKEND", "true")
+	os.Setenv("HAPROXY_VERSION", "1.7")
+	var actualData string
+	expectedData := fmt.Sprintf(
+		"%s%s%s",
+		s.TemplateContent,
+		`
+    monitor-uri /dfp-probe`,
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_DoesNotAddProbeBackend_WhenEnableProbeBackendIsNotSet() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NotContains(actualData, "dfp-probe")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_DeniesRequestsWithoutHost_WhenRequireHostHeaderIsTrue() {
+	defer SetHostHeaderRules(false, false)
+	SetHostHeaderRules(true, true)
+	var actualData string
+	expectedData := fmt.Sprintf(
+		"%s%s",
+		s.TemplateContent,
+		`
+
+config1 fe content
+
+config2 fe content
+
+    http-request deny deny_status 400 if !{ req.hdr(Host) -m found } !{ var(txn.host_exempt) -m bool }
+    http-request deny deny_status 400 if { req.ver 1.0 } !{ var(txn.host_exempt) -m bool }
+
+config1 be content
+
+config2 be content`,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_DoesNotDenyRequests_WhenRequireHostHeaderIsNotSet() {
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NotContains(actualData, "http-request deny")
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsCert() {
 	var actualFilename string
 	expectedFilename := fmt.Sprintf("%s/haproxy.cfg", s.ConfigsPath)
@@ -214,6 +808,45 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsCert() {
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsDefaultCertFirst() {
+	defer SetDefaultCert("")
+	var actualData string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+	certs := map[string]bool{"b-cert.pem": true, "z-cert.pem": true, "a-cert.pem": true}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, certs).CreateConfigFromTemplates()
+
+	s.Contains(actualData, "bind *:443 ssl crt /certs/a-cert.pem crt /certs/b-cert.pem crt /certs/z-cert.pem\n")
+
+	SetDefaultCert("z-cert.pem")
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, certs).CreateConfigFromTemplates()
+
+	s.Contains(actualData, "bind *:443 ssl crt /certs/z-cert.pem crt /certs/a-cert.pem crt /certs/b-cert.pem\n")
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_VerifiesClientCerts_WhenCaCertsExist() {
+	caCertsDirOrig := CaCertsDir
+	defer func() { CaCertsDir = caCertsDirOrig }()
+	CaCertsDir = "test_configs/ca"
+	actualData := map[string]string{}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData[filename] = string(data)
+		return nil
+	}
+	expectedCa, _ := ioutil.ReadFile("test_configs/ca/my-ca.pem")
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{"my-cert.pem": true}).CreateConfigFromTemplates()
+
+	s.Equal(string(expectedCa), actualData[s.ConfigsPath+"/client-ca.pem"])
+	s.Contains(
+		actualData[s.ConfigsPath+"/haproxy.cfg"],
+		fmt.Sprintf("bind *:443 ssl crt /certs/my-cert.pem verify optional ca-file %s/client-ca.pem ca-ignore-err all crt-ignore-err all\n", s.ConfigsPath),
+	)
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsUserList() {
 	var actualData string
 	usersOrig := os.Getenv("USERS")
@@ -314,6 +947,42 @@ backend dummy-be
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsConfigLimitError_WhenConfigIsTooLarge() {
+	defer func() { os.Unsetenv("MAX_CONFIG_SIZE") }()
+	os.Setenv("MAX_CONFIG_SIZE", "100")
+	written := false
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		written = true
+		return nil
+	}
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.IsType(&ConfigLimitError{}, err)
+	s.Contains(err.Error(), "MAX_CONFIG_SIZE")
+	s.False(written)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsConfigLimitError_WhenServicesExceedLimit() {
+	defer func() { os.Unsetenv("MAX_SERVICES") }()
+	// The templates directory holds the backends of two services
+	os.Setenv("MAX_SERVICES", "1")
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(&ConfigLimitError{Limit: "MAX_SERVICES", Subject: "services", Value: 2, Max: 1}, err)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RecordsWarnings_WhenConfigIsCloseToLimits() {
+	defer func() { os.Unsetenv("MAX_SERVICES") }()
+	os.Setenv("MAX_SERVICES", "2")
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NoError(err)
+	s.Equal([]string{"The services (2) are close to the MAX_SERVICES limit of 2"}, GetConfigWarnings())
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenReadConfigsFileFails() {
 	readConfigsFileOrig := readConfigsFile
 	defer func() {
@@ -363,6 +1032,40 @@ func (s *HaProxyTestSuite) Test_ReadConfig_ReturnsError_WhenReadFileFails() {
 	s.Error(actual)
 }
 
+// CheckConfig
+
+func (s *HaProxyTestSuite) Test_CheckConfig_RunsHaProxyCheck() {
+	var actualFilename string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualFilename = filename
+		return nil
+	}
+	actual := s.mockHaExecCmd()
+
+	err := CheckConfig(s.TemplatesPath)
+
+	s.NoError(err)
+	s.Equal("test_configs/tmpl/haproxy.cfg", actualFilename)
+	s.Equal([]string{"haproxy", "-c", "-f", "test_configs/tmpl/haproxy.cfg"}, *actual)
+}
+
+func (s *HaProxyTestSuite) Test_CheckConfig_ReturnsOutput_WhenCheckFails() {
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		cmd.Stdout.Write([]byte("[ALERT] unknown keyword 'foo'"))
+		return fmt.Errorf("exit status 1")
+	}
+
+	err := CheckConfig(s.TemplatesPath)
+
+	s.EqualError(err, "The configuration is invalid\nexit status 1\n[ALERT] unknown keyword 'foo'")
+}
+
+func (s *HaProxyTestSuite) Test_CheckConfig_ReturnsError_WhenTemplatesCannotBeRead() {
+	err := CheckConfig("/this/path/does/not/exist")
+
+	s.Error(err)
+}
+
 // Reload
 
 func (s *HaProxyTestSuite) Test_Reload_ReadsPidFile() {
@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Actions of the changes applied by a reload.
+const (
+	ReloadActionReconfigure = "reconfigure"
+	ReloadActionRemove      = "remove"
+)
+
+// Stages of the reload hooks.
+const (
+	HookStagePre  = "pre"
+	HookStagePost = "post"
+)
+
+// HookResult describes a hook run by a reload. Hook is the name of the hook, HOOK_PRE_RELOAD or HOOK_POST_RELOAD for
+// the global ones. ExitCode is -1 if the command could not be run or did not finish within HOOK_TIMEOUT.
+type HookResult struct {
+	Hook     string
+	Stage    string
+	Service  string `json:",omitempty"`
+	ExitCode int
+	Error    string `json:",omitempty"`
+}
+
+// ReloadChange is a change of a service applied by the next reload. Hook is the name of the hook from HOOKS_FILE run
+// after the reload.
+type ReloadChange struct {
+	Service string
+	Action  string
+	Hook    string
+}
+
+// cmdRunHook runs the hook commands. The command is killed once its context is done.
+var cmdRunHook = func(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
+
+// reloadHooks maps the names of the hooks services can reference through postReloadHook to their commands.
+var reloadHooks = map[string]string{}
+var reloadHooksMu = &sync.RWMutex{}
+
+var reloadChanges = []ReloadChange{}
+var reloadChangesMu = &sync.Mutex{}
+
+// LoadReloadHooks reads the hooks services can reference through postReloadHook from a JSON file mapping their names to
+// commands (e.g. {"refresh-dns": "/scripts/refresh-dns.sh"}) and replaces the ones that are currently defined.
+func LoadReloadHooks(path string) error {
+	content, err := ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Could not read the hooks file %s\n%s", path, err.Error())
+	}
+	data := map[string]string{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return fmt.Errorf("Could not parse the hooks file %s\n%s", path, err.Error())
+	}
+	for name, command := range data {
+		if len(strings.TrimSpace(command)) == 0 {
+			return fmt.Errorf("The hook %s does not have a command", name)
+		}
+	}
+	reloadHooksMu.Lock()
+	defer reloadHooksMu.Unlock()
+	reloadHooks = data
+	return nil
+}
+
+// IsReloadHook tells whether the hook is defined in the hooks file.
+func IsReloadHook(name string) bool {
+	reloadHooksMu.RLock()
+	defer reloadHooksMu.RUnlock()
+	_, ok := reloadHooks[name]
+	return ok
+}
+
+// GetReloadHookNames returns the names of all the defined hooks, sorted alphabetically.
+func GetReloadHookNames() []string {
+	reloadHooksMu.RLock()
+	defer reloadHooksMu.RUnlock()
+	names := []string{}
+	for name := range reloadHooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddReloadChange registers a change of a service that the next reload applies. Changes registered while a reload is
+// deferred are all applied by it.
+func AddReloadChange(service, action, hook string) {
+	reloadChangesMu.Lock()
+	defer reloadChangesMu.Unlock()
+	reloadChanges = append(reloadChanges, ReloadChange{Service: service, Action: action, Hook: hook})
+}
+
+// takeReloadChanges returns the registered changes and clears them.
+func takeReloadChanges() []ReloadChange {
+	reloadChangesMu.Lock()
+	defer reloadChangesMu.Unlock()
+	changes := reloadChanges
+	reloadChanges = []ReloadChange{}
+	return changes
+}
+
+// restoreReloadChanges puts back the changes of a reload that was aborted so that the next one applies them.
+func restoreReloadChanges(changes []ReloadChange) {
+	reloadChangesMu.Lock()
+	defer reloadChangesMu.Unlock()
+	reloadChanges = append(changes, reloadChanges...)
+}
+
+// getHookTimeout returns the HOOK_TIMEOUT as a duration (e.g. 500ms) or a number of seconds. It defaults to 30 seconds.
+func getHookTimeout() time.Duration {
+	value := os.Getenv("HOOK_TIMEOUT")
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	} else if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// isPreReloadHookFatal tells whether a failed HOOK_PRE_RELOAD aborts the reload. Only HOOK_PRE_RELOAD_ABORT set to
+// false lets the reload continue.
+func isPreReloadHookFatal() bool {
+	return !strings.EqualFold(os.Getenv("HOOK_PRE_RELOAD_ABORT"), "false")
+}
+
+// runPreReloadHook runs HOOK_PRE_RELOAD. It returns nil if the hook is not set.
+func runPreReloadHook(changes []ReloadChange) *HookResult {
+	command := os.Getenv("HOOK_PRE_RELOAD")
+	if len(command) == 0 {
+		return nil
+	}
+	result := runHook("HOOK_PRE_RELOAD", HookStagePre, command, changes)
+	return &result
+}
+
+// runPostReloadHooks runs HOOK_POST_RELOAD and the hooks of the changed services. Failures are only logged since the
+// proxy is already reloaded.
+func runPostReloadHooks(changes []ReloadChange) []HookResult {
+	results := []HookResult{}
+	if command := os.Getenv("HOOK_POST_RELOAD"); len(command) > 0 {
+		results = append(results, runHook("HOOK_POST_RELOAD", HookStagePost, command, changes))
+	}
+	for _, change := range changes {
+		if len(change.Hook) == 0 {
+			continue
+		}
+		reloadHooksMu.RLock()
+		command, ok := reloadHooks[change.Hook]
+		reloadHooksMu.RUnlock()
+		if !ok {
+			results = append(results, HookResult{
+				Hook:     change.Hook,
+				Stage:    HookStagePost,
+				Service:  change.Service,
+				ExitCode: -1,
+				Error:    fmt.Sprintf("The hook %s is not defined in the hooks file", change.Hook),
+			})
+			continue
+		}
+		result := runHook(change.Hook, HookStagePost, command, []ReloadChange{change})
+		result.Service = change.Service
+		results = append(results, result)
+	}
+	for _, result := range results {
+		if len(result.Error) > 0 {
+			logPrintf("WARNING: The post-reload hook %s failed\n%s", result.Hook, result.Error)
+		}
+	}
+	return results
+}
+
+// runHook runs the command through sh within HOOK_TIMEOUT. The names of the changed services and their actions are
+// passed through the DFP_SERVICE_NAME and DFP_ACTION variables, comma-separated if the reload applies multiple changes.
+func runHook(name, stage, command string, changes []ReloadChange) HookResult {
+	services := []string{}
+	actions := []string{}
+	for _, change := range changes {
+		services = append(services, change.Service)
+		actions = append(actions, change.Action)
+	}
+	timeout := getHookTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(
+		os.Environ(),
+		"DFP_SERVICE_NAME="+strings.Join(services, ","),
+		"DFP_ACTION="+strings.Join(actions, ","),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	result := HookResult{Hook: name, Stage: stage}
+	err := cmdRunHook(cmd)
+	if ctx.Err() == context.DeadlineExceeded {
+		result.ExitCode = -1
+		result.Error = fmt.Sprintf("The hook did not finish within %s", timeout)
+	} else if exitErr, ok := err.(*exec.ExitError); ok {
+		result.ExitCode = -1
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			result.ExitCode = status.ExitStatus()
+		}
+		result.Error = fmt.Sprintf("The hook exited with the status %d", result.ExitCode)
+	} else if err != nil {
+		result.ExitCode = -1
+		result.Error = err.Error()
+	}
+	return result
+}
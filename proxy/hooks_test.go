@@ -0,0 +1,230 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+type HooksTestSuite struct {
+	suite.Suite
+	commands []*exec.Cmd
+}
+
+func (s *HooksTestSuite) SetupTest() {
+	reloadHistory = []*ReloadEntry{}
+	reloadTotals = ReloadTotals{}
+	reloadChanges = []ReloadChange{}
+	reloadHooks = map[string]string{"refresh-dns": "/scripts/refresh-dns.sh"}
+	readPidFile = func(fileName string) ([]byte, error) {
+		return []byte("123"), nil
+	}
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		return nil
+	}
+	readStats = func() (string, error) {
+		return "", fmt.Errorf("This is an error")
+	}
+	s.commands = []*exec.Cmd{}
+	cmdRunHook = func(cmd *exec.Cmd) error {
+		s.commands = append(s.commands, cmd)
+		return nil
+	}
+}
+
+func (s *HooksTestSuite) TearDownTest() {
+	for _, key := range []string{"HOOK_PRE_RELOAD", "HOOK_POST_RELOAD", "HOOK_PRE_RELOAD_ABORT", "HOOK_TIMEOUT"} {
+		os.Unsetenv(key)
+	}
+}
+
+// LoadReloadHooks
+
+func (s *HooksTestSuite) Test_LoadReloadHooks_ReplacesHooks() {
+	readFileOrig := ReadFile
+	defer func() { ReadFile = readFileOrig }()
+	actualPath := ""
+	ReadFile = func(path string) ([]byte, error) {
+		actualPath = path
+		return []byte(`{"warm-cache": "curl -s http://cache/warm"}`), nil
+	}
+
+	err := LoadReloadHooks("/cfg/hooks.json")
+
+	s.NoError(err)
+	s.Equal("/cfg/hooks.json", actualPath)
+	s.Equal([]string{"warm-cache"}, GetReloadHookNames())
+	s.True(IsReloadHook("warm-cache"))
+	s.False(IsReloadHook("refresh-dns"))
+}
+
+func (s *HooksTestSuite) Test_LoadReloadHooks_ReturnsError_WhenHooksAreInvalid() {
+	readFileOrig := ReadFile
+	defer func() { ReadFile = readFileOrig }()
+	for _, content := range []string{`not json`, `{"warm-cache": " "}`} {
+		ReadFile = func(path string) ([]byte, error) {
+			return []byte(content), nil
+		}
+
+		err := LoadReloadHooks("/cfg/hooks.json")
+
+		s.Error(err, content)
+		s.True(IsReloadHook("refresh-dns"), content)
+	}
+}
+
+// Reload
+
+func (s *HooksTestSuite) Test_Reload_RunsHooksWithServiceAndAction() {
+	os.Setenv("HOOK_PRE_RELOAD", "/scripts/pre.sh")
+	os.Setenv("HOOK_POST_RELOAD", "/scripts/post.sh")
+	AddReloadChange("my-service", ReloadActionReconfigure, "refresh-dns")
+	AddReloadChange("other-service", ReloadActionRemove, "")
+
+	err := HaProxy{}.Reload()
+
+	s.NoError(err)
+	s.Len(s.commands, 3)
+	for i, expected := range []struct {
+		command, services, actions string
+	}{
+		{"/scripts/pre.sh", "my-service,other-service", "reconfigure,remove"},
+		{"/scripts/post.sh", "my-service,other-service", "reconfigure,remove"},
+		{"/scripts/refresh-dns.sh", "my-service", "reconfigure"},
+	} {
+		s.Equal([]string{"sh", "-c", expected.command}, s.commands[i].Args)
+		s.Contains(s.commands[i].Env, "DFP_SERVICE_NAME="+expected.services)
+		s.Contains(s.commands[i].Env, "DFP_ACTION="+expected.actions)
+	}
+	s.Equal([]HookResult{
+		{Hook: "HOOK_PRE_RELOAD", Stage: HookStagePre},
+		{Hook: "HOOK_POST_RELOAD", Stage: HookStagePost},
+		{Hook: "refresh-dns", Stage: HookStagePost, Service: "my-service"},
+	}, GetLastReload().Hooks)
+	s.Empty(takeReloadChanges())
+}
+
+func (s *HooksTestSuite) Test_Reload_DoesNotRunHooks_WhenTheyAreNotSet() {
+	AddReloadChange("my-service", ReloadActionReconfigure, "")
+
+	HaProxy{}.Reload()
+
+	s.Empty(s.commands)
+	s.Nil(GetLastReload().Hooks)
+}
+
+func (s *HooksTestSuite) Test_Reload_RecordsExitStatusOfFailedPostReloadHook() {
+	os.Setenv("HOOK_POST_RELOAD", "exit 3")
+	cmdRunHook = func(cmd *exec.Cmd) error {
+		return cmd.Run()
+	}
+
+	err := HaProxy{}.Reload()
+
+	s.NoError(err)
+	s.Equal([]HookResult{
+		{Hook: "HOOK_POST_RELOAD", Stage: HookStagePost, ExitCode: 3, Error: "The hook exited with the status 3"},
+	}, GetLastReload().Hooks)
+	s.Equal(int64(1), GetReloadTotals().Reloads)
+}
+
+func (s *HooksTestSuite) Test_Reload_AbortsReload_WhenPreReloadHookFails() {
+	os.Setenv("HOOK_PRE_RELOAD", "/scripts/pre.sh")
+	AddReloadChange("my-service", ReloadActionReconfigure, "refresh-dns")
+	cmdRunHook = func(cmd *exec.Cmd) error {
+		return fmt.Errorf("This is an error")
+	}
+	reloaded := false
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		reloaded = true
+		return nil
+	}
+
+	err := HaProxy{}.Reload()
+
+	s.EqualError(err, "The reload was aborted since the pre-reload hook failed\nThis is an error")
+	s.False(reloaded)
+	actual := GetLastReload()
+	s.True(actual.Aborted)
+	s.Equal([]HookResult{{Hook: "HOOK_PRE_RELOAD", Stage: HookStagePre, ExitCode: -1, Error: "This is an error"}}, actual.Hooks)
+	s.Equal(int64(0), GetReloadTotals().Reloads)
+	// The next reload applies the changes of the aborted one
+	s.Equal([]ReloadChange{{Service: "my-service", Action: ReloadActionReconfigure, Hook: "refresh-dns"}}, takeReloadChanges())
+}
+
+func (s *HooksTestSuite) Test_Reload_ContinuesReload_WhenPreReloadHookFailsAndAbortIsFalse() {
+	os.Setenv("HOOK_PRE_RELOAD", "/scripts/pre.sh")
+	os.Setenv("HOOK_PRE_RELOAD_ABORT", "false")
+	cmdRunHook = func(cmd *exec.Cmd) error {
+		return fmt.Errorf("This is an error")
+	}
+
+	err := HaProxy{}.Reload()
+
+	s.NoError(err)
+	actual := GetLastReload()
+	s.False(actual.Aborted)
+	s.Equal("This is an error", actual.Hooks[0].Error)
+	s.Equal(int64(1), GetReloadTotals().Reloads)
+}
+
+func (s *HooksTestSuite) Test_Reload_RecordsTimeout_WhenHookDoesNotFinishInTime() {
+	os.Setenv("HOOK_POST_RELOAD", "/scripts/post.sh")
+	os.Setenv("HOOK_TIMEOUT", "10ms")
+	cmdRunHook = func(cmd *exec.Cmd) error {
+		time.Sleep(50 * time.Millisecond)
+		return fmt.Errorf("signal: killed")
+	}
+
+	HaProxy{}.Reload()
+
+	s.Equal([]HookResult{
+		{Hook: "HOOK_POST_RELOAD", Stage: HookStagePost, ExitCode: -1, Error: "The hook did not finish within 10ms"},
+	}, GetLastReload().Hooks)
+}
+
+func (s *HooksTestSuite) Test_Reload_DoesNotRunHooksMissingFromHooksFile() {
+	AddReloadChange("my-service", ReloadActionReconfigure, "rm-rf")
+
+	HaProxy{}.Reload()
+
+	s.Empty(s.commands)
+	s.Equal([]HookResult{{
+		Hook:     "rm-rf",
+		Stage:    HookStagePost,
+		Service:  "my-service",
+		ExitCode: -1,
+		Error:    "The hook rm-rf is not defined in the hooks file",
+	}}, GetLastReload().Hooks)
+}
+
+// getHookTimeout
+
+func (s *HooksTestSuite) Test_GetHookTimeout_AcceptsDurationsAndSeconds() {
+	cases := map[string]time.Duration{"": 30 * time.Second, "5": 5 * time.Second, "1m": time.Minute, "0": 30 * time.Second, "soon": 30 * time.Second}
+	for value, expected := range cases {
+		os.Setenv("HOOK_TIMEOUT", value)
+
+		s.Equal(expected, getHookTimeout(), value)
+	}
+}
+
+// Suite
+
+func TestHooksUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	readStatsOrig := readStats
+	cmdRunHookOrig := cmdRunHook
+	defer func() {
+		readStats = readStatsOrig
+		cmdRunHook = cmdRunHookOrig
+		reloadHooks = map[string]string{}
+		reloadChanges = []ReloadChange{}
+	}()
+	suite.Run(t, new(HooksTestSuite))
+}
@@ -0,0 +1,278 @@
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ImportedService is a service recognized in an HAProxy configuration. Paths and Domains come from the ACLs of the
+// use_backend rule that routes to the backend. Domains matched by their ending are prefixed with *, the same way as
+// the serviceDomain query.
+type ImportedService struct {
+	ServiceName string
+	Backend     string
+	PathType    string   `json:",omitempty"`
+	Paths       []string `json:",omitempty"`
+	Domains     []string `json:",omitempty"`
+	Host        string
+	Port        string
+}
+
+// UnmappedSection is a section, or a line of a section, of an HAProxy configuration that could not be imported.
+type UnmappedSection struct {
+	Section string
+	Line    string `json:",omitempty"`
+	Reason  string
+}
+
+// ImportResult holds the services recognized in an HAProxy configuration and what could not be mapped to them.
+type ImportResult struct {
+	Services []ImportedService
+	Unmapped []UnmappedSection
+}
+
+// importSectionKeywords are the keywords that start a section of an HAProxy configuration.
+var importSectionKeywords = []string{
+	"global", "defaults", "frontend", "backend", "listen", "userlist", "peers", "resolvers", "mailers", "cache",
+	"program", "http-errors", "ring",
+}
+
+// importIgnoredBackendDirectives are backend directives that do not change how a service is configured.
+var importIgnoredBackendDirectives = []string{"mode", "balance", "option forwardfor", "option http-server-close"}
+
+// importFrontendDirectives are the frontend directives that are not routing rules and are provided by the proxy.
+var importFrontendDirectives = []string{"bind", "mode", "option", "timeout", "log", "maxconn"}
+
+type importSection struct {
+	kind  string
+	name  string
+	lines []string
+}
+
+type importAcl struct {
+	criterion string
+	values    []string
+}
+
+// ParseConfig recognizes the services in an HAProxy configuration that could have been generated by the proxy: a
+// use_backend rule conditioned by a path ACL and, optionally, a domain ACL, and a backend with a single server. The
+// global and defaults sections are skipped since the proxy generates its own. Everything else is reported as
+// unmapped together with the reason.
+func ParseConfig(content string) ImportResult {
+	result := ImportResult{Services: []ImportedService{}, Unmapped: []UnmappedSection{}}
+	backends := map[string]importSection{}
+	backendNames := []string{}
+	frontends := []importSection{}
+	for _, section := range splitConfigSections(content) {
+		title := strings.TrimSpace(section.kind + " " + section.name)
+		switch section.kind {
+		case "global", "defaults":
+		case "frontend":
+			frontends = append(frontends, section)
+		case "backend":
+			backends[section.name] = section
+			backendNames = append(backendNames, section.name)
+		default:
+			result.Unmapped = append(result.Unmapped, UnmappedSection{
+				Section: title,
+				Reason:  fmt.Sprintf("The %s sections are not supported", section.kind),
+			})
+		}
+	}
+	routed := map[string]bool{}
+	for _, frontend := range frontends {
+		services, unmapped := parseFrontend(frontend, backends, routed)
+		result.Services = append(result.Services, services...)
+		result.Unmapped = append(result.Unmapped, unmapped...)
+	}
+	for _, name := range backendNames {
+		if !routed[name] {
+			result.Unmapped = append(result.Unmapped, UnmappedSection{
+				Section: "backend " + name,
+				Reason:  "No use_backend rule that could be imported routes to the backend",
+			})
+		}
+	}
+	sort.Slice(result.Services, func(i, j int) bool {
+		return result.Services[i].ServiceName < result.Services[j].ServiceName
+	})
+	return result
+}
+
+// splitConfigSections splits the configuration into sections. Comments and empty lines are dropped.
+func splitConfigSections(content string) []importSection {
+	sections := []importSection{}
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if isSectionKeyword(fields[0]) {
+			section := importSection{kind: fields[0]}
+			if len(fields) > 1 {
+				section.name = fields[1]
+			}
+			sections = append(sections, section)
+		} else if len(sections) > 0 {
+			sections[len(sections)-1].lines = append(sections[len(sections)-1].lines, strings.Join(fields, " "))
+		}
+	}
+	return sections
+}
+
+func isSectionKeyword(word string) bool {
+	for _, keyword := range importSectionKeywords {
+		if word == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFrontend maps the use_backend rules of the frontend to services. The backends the rules route to are marked in
+// routed, whether they could be mapped or not.
+func parseFrontend(frontend importSection, backends map[string]importSection, routed map[string]bool) ([]ImportedService, []UnmappedSection) {
+	title := "frontend " + frontend.name
+	services := []ImportedService{}
+	unmapped := []UnmappedSection{}
+	acls := map[string][]importAcl{}
+	for _, line := range frontend.lines {
+		fields := strings.Fields(line)
+		if fields[0] == "acl" && len(fields) > 3 {
+			acl := importAcl{criterion: fields[2]}
+			for _, value := range fields[3:] {
+				if !strings.HasPrefix(value, "-") {
+					acl.values = append(acl.values, value)
+				}
+			}
+			acls[fields[1]] = append(acls[fields[1]], acl)
+		}
+	}
+	for _, line := range frontend.lines {
+		fields := strings.Fields(line)
+		switch {
+		case fields[0] == "acl":
+		case fields[0] == "use_backend":
+			service, reason := parseUseBackend(fields, acls, backends)
+			if len(reason) > 0 {
+				unmapped = append(unmapped, UnmappedSection{Section: title, Line: line, Reason: reason})
+				continue
+			}
+			host, port, backendUnmapped := parseBackend(backends[service.Backend])
+			// The backend is reported once no matter how many rules route to it
+			if !routed[service.Backend] {
+				unmapped = append(unmapped, backendUnmapped...)
+			}
+			routed[service.Backend] = true
+			if len(host) > 0 {
+				service.Host, service.Port = host, port
+				services = append(services, service)
+			}
+		case isFrontendDirective(fields[0]):
+		default:
+			unmapped = append(unmapped, UnmappedSection{Section: title, Line: line, Reason: "The directive is not supported"})
+		}
+	}
+	return services, unmapped
+}
+
+func isFrontendDirective(word string) bool {
+	for _, directive := range importFrontendDirectives {
+		if word == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// parseUseBackend maps a use_backend rule to a service. The condition must be a conjunction of the ACLs defined in
+// the frontend with exactly one of them matching paths. The reason is returned if the rule cannot be mapped.
+func parseUseBackend(fields []string, acls map[string][]importAcl, backends map[string]importSection) (ImportedService, string) {
+	if len(fields) < 4 || fields[2] != "if" {
+		return ImportedService{}, "Only use_backend rules with an if condition are supported"
+	}
+	backend := fields[1]
+	if _, ok := backends[backend]; !ok {
+		return ImportedService{}, fmt.Sprintf("The backend %s is not defined", backend)
+	}
+	service := ImportedService{ServiceName: strings.TrimSuffix(backend, "-be"), Backend: backend}
+	for _, name := range fields[3:] {
+		definitions, ok := acls[name]
+		if name == "or" || name == "||" {
+			return ImportedService{}, "Conditions with or are not supported"
+		} else if strings.HasPrefix(name, "!") || strings.HasPrefix(name, "{") {
+			return ImportedService{}, "Negated and anonymous ACLs are not supported"
+		} else if !ok {
+			return ImportedService{}, fmt.Sprintf("The condition %s is not an ACL defined in the frontend", name)
+		} else if len(definitions) > 1 {
+			return ImportedService{}, fmt.Sprintf("The ACL %s is defined more than once", name)
+		}
+		acl := definitions[0]
+		switch acl.criterion {
+		case "path_beg", "path", "path_end", "path_reg", "path_dir", "path_sub":
+			if len(service.Paths) > 0 {
+				return ImportedService{}, "The condition contains more than one path ACL"
+			}
+			service.Paths = acl.values
+			if acl.criterion != "path_beg" {
+				service.PathType = acl.criterion
+			}
+		case "hdr(host)", "hdr_dom(host)", "hdr_end(host)":
+			if len(service.Domains) > 0 {
+				return ImportedService{}, "The condition contains more than one domain ACL"
+			}
+			for _, domain := range acl.values {
+				if acl.criterion == "hdr_end(host)" {
+					domain = "*" + domain
+				}
+				service.Domains = append(service.Domains, domain)
+			}
+		default:
+			return ImportedService{}, fmt.Sprintf("The ACL %s uses the unsupported criterion %s", name, acl.criterion)
+		}
+	}
+	if len(service.Paths) == 0 {
+		return ImportedService{}, "The condition does not contain a path ACL"
+	}
+	return service, ""
+}
+
+// parseBackend returns the address of the only server of the backend. The host is empty if the backend cannot be
+// mapped. The directives that are not imported are returned as unmapped.
+func parseBackend(backend importSection) (host, port string, unmapped []UnmappedSection) {
+	title := "backend " + backend.name
+	servers := []string{}
+	for _, line := range backend.lines {
+		fields := strings.Fields(line)
+		if fields[0] == "server" && len(fields) > 2 {
+			servers = append(servers, fields[2])
+		} else if !isIgnoredBackendDirective(line) {
+			unmapped = append(unmapped, UnmappedSection{Section: title, Line: line, Reason: "The directive is not imported"})
+		}
+	}
+	if len(servers) != 1 {
+		return "", "", []UnmappedSection{{
+			Section: title,
+			Reason:  fmt.Sprintf("Only backends with a single server are supported. The backend has %d", len(servers)),
+		}}
+	}
+	address := servers[0]
+	i := strings.LastIndex(address, ":")
+	if i <= 0 || i == len(address)-1 {
+		return "", "", []UnmappedSection{{Section: title, Reason: fmt.Sprintf("The address %s of the server does not contain a port", address)}}
+	}
+	return address[:i], address[i+1:], unmapped
+}
+
+func isIgnoredBackendDirective(line string) bool {
+	for _, directive := range importIgnoredBackendDirectives {
+		if line == directive || strings.HasPrefix(line, directive+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+// +build !integration
+
+package proxy
+
+import (
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"testing"
+)
+
+type ImportTestSuite struct {
+	suite.Suite
+}
+
+func (s *ImportTestSuite) parseFixture(name string) ImportResult {
+	content, err := ioutil.ReadFile("test_configs/import/" + name)
+	s.Require().NoError(err)
+	return ParseConfig(string(content))
+}
+
+func (s *ImportTestSuite) getUnmapped(result ImportResult, section string) []UnmappedSection {
+	unmapped := []UnmappedSection{}
+	for _, u := range result.Unmapped {
+		if u.Section == section {
+			unmapped = append(unmapped, u)
+		}
+	}
+	return unmapped
+}
+
+// ParseConfig
+
+func (s ImportTestSuite) Test_ParseConfig_MapsServicesGeneratedByProxy() {
+	result := s.parseFixture("generated.cfg")
+
+	s.Equal([]ImportedService{
+		{ServiceName: "api", Backend: "api-be", PathType: "path_reg", Paths: []string{"^/api/v[0-9]+/"}, Host: "10.0.0.12", Port: "3000"},
+		{ServiceName: "go-demo", Backend: "go-demo-be", Paths: []string{"/demo", "/demo/api"}, Domains: []string{"go-demo.com"}, Host: "go-demo", Port: "8080"},
+		{ServiceName: "shop", Backend: "shop-be", Paths: []string{"/shop"}, Domains: []string{"*.acme.com"}, Host: "shop", Port: "80"},
+	}, result.Services)
+	s.Empty(result.Unmapped)
+}
+
+func (s ImportTestSuite) Test_ParseConfig_ReportsUnsupportedSections() {
+	result := s.parseFixture("unsupported.cfg")
+
+	s.Equal([]UnmappedSection{{Section: "listen stats", Reason: "The listen sections are not supported"}}, s.getUnmapped(result, "listen stats"))
+	s.Equal([]UnmappedSection{{
+		Section: "backend web-be",
+		Reason:  "No use_backend rule that could be imported routes to the backend",
+	}}, s.getUnmapped(result, "backend web-be"))
+}
+
+func (s ImportTestSuite) Test_ParseConfig_ReportsRulesThatCannotBeMapped() {
+	result := s.parseFixture("unsupported.cfg")
+
+	s.Equal([]UnmappedSection{
+		{Section: "frontend services", Line: "default_backend web-be", Reason: "The directive is not supported"},
+		{Section: "frontend services", Line: "use_backend admin-be if url_web is_admin", Reason: "The ACL is_admin uses the unsupported criterion src"},
+		{Section: "frontend services", Line: "use_backend blue-be if url_blue or url_green", Reason: "Conditions with or are not supported"},
+		{Section: "frontend services", Line: "use_backend canary-v2-be if url_canary { req.hdr(X-Version) -m str v2 }", Reason: "Negated and anonymous ACLs are not supported"},
+		{Section: "frontend services", Line: "use_backend missing-be if url_canary", Reason: "The backend missing-be is not defined"},
+		{Section: "frontend services", Line: "http-request set-header X-Proxy dfp", Reason: "The directive is not supported"},
+	}, s.getUnmapped(result, "frontend services"))
+}
+
+func (s ImportTestSuite) Test_ParseConfig_ReportsBackendsWithMultipleServersOnce() {
+	result := s.parseFixture("unsupported.cfg")
+
+	s.Equal([]UnmappedSection{{
+		Section: "backend cluster-be",
+		Reason:  "Only backends with a single server are supported. The backend has 2",
+	}}, s.getUnmapped(result, "backend cluster-be"))
+}
+
+func (s ImportTestSuite) Test_ParseConfig_MapsServiceAndReportsDirectivesThatAreNotImported() {
+	result := s.parseFixture("unsupported.cfg")
+
+	s.Equal([]ImportedService{
+		{ServiceName: "tuned", Backend: "tuned-be", Paths: []string{"/tuned"}, Host: "tuned", Port: "8080"},
+	}, result.Services)
+	s.Equal([]UnmappedSection{
+		{Section: "backend tuned-be", Line: "timeout server 60s", Reason: "The directive is not imported"},
+	}, s.getUnmapped(result, "backend tuned-be"))
+}
+
+func (s ImportTestSuite) Test_ParseConfig_ReportsRule_WhenConditionHasNoPathAcl() {
+	result := ParseConfig(`frontend services
+    acl domain_web hdr(host) web.com
+    use_backend web-be if domain_web
+
+backend web-be
+    server web web:8080`)
+
+	s.Empty(result.Services)
+	s.Equal("The condition does not contain a path ACL", result.Unmapped[0].Reason)
+}
+
+func (s ImportTestSuite) Test_ParseConfig_ReportsBackend_WhenServerHasNoPort() {
+	result := ParseConfig(`frontend services
+    acl url_web path_beg /web
+    use_backend web-be if url_web
+
+backend web-be
+    server web web`)
+
+	s.Empty(result.Services)
+	s.Equal([]UnmappedSection{{Section: "backend web-be", Reason: "The address web of the server does not contain a port"}}, result.Unmapped)
+}
+
+func (s ImportTestSuite) Test_ParseConfig_ReturnsEmptyResult_WhenContentIsEmpty() {
+	result := ParseConfig("")
+
+	s.Equal(ImportResult{Services: []ImportedService{}, Unmapped: []UnmappedSection{}}, result)
+}
+
+// Suite
+
+func TestImportUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ImportTestSuite))
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ConfigLimits are the sanity limits of the generated configuration. A limit set to zero is disabled.
+type ConfigLimits struct {
+	MaxServicePaths   int
+	MaxServiceDomains int
+	MaxServices       int
+	MaxConfigSize     int
+	// WarningPercent is the share of a limit above which a warning is issued.
+	WarningPercent int
+}
+
+// ConfigLimitError is returned when a value exceeds one of the limits. Limit is the environment variable of the limit.
+type ConfigLimitError struct {
+	Limit   string
+	Subject string
+	Value   int
+	Max     int
+}
+
+func (e *ConfigLimitError) Error() string {
+	return fmt.Sprintf("The %s (%d) exceed the %s limit of %d", e.Subject, e.Value, e.Limit, e.Max)
+}
+
+var configWarningsMu = &sync.Mutex{}
+var configWarnings = []string{}
+
+// GetConfigLimits returns the limits set through MAX_SERVICE_PATHS, MAX_SERVICE_DOMAINS, MAX_SERVICES and
+// MAX_CONFIG_SIZE (in bytes) and the warning threshold set through CONFIG_LIMIT_WARNING (in percent). Values that are
+// not valid numbers are replaced with the defaults.
+func GetConfigLimits() ConfigLimits {
+	return ConfigLimits{
+		MaxServicePaths:   getEnvInt("MAX_SERVICE_PATHS", 1000),
+		MaxServiceDomains: getEnvInt("MAX_SERVICE_DOMAINS", 1000),
+		MaxServices:       getEnvInt("MAX_SERVICES", 0),
+		MaxConfigSize:     getEnvInt("MAX_CONFIG_SIZE", 16*1024*1024),
+		WarningPercent:    getEnvInt("CONFIG_LIMIT_WARNING", 80),
+	}
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value >= 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// CheckService checks the number of paths and domains of a service. It returns the warnings of the values above the
+// warning threshold.
+func (l ConfigLimits) CheckService(serviceName string, paths, domains int) ([]string, error) {
+	warnings := []string{}
+	for _, c := range []struct {
+		limit, subject string
+		value, max     int
+	}{
+		{"MAX_SERVICE_PATHS", "paths of the service " + serviceName, paths, l.MaxServicePaths},
+		{"MAX_SERVICE_DOMAINS", "domains of the service " + serviceName, domains, l.MaxServiceDomains},
+	} {
+		warning, err := l.check(c.limit, c.subject, c.value, c.max)
+		if err != nil {
+			return nil, err
+		} else if len(warning) > 0 {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings, nil
+}
+
+// CheckConfig checks the number of services and the size of the assembled configuration. It returns the warnings of
+// the values above the warning threshold.
+func (l ConfigLimits) CheckConfig(services, size int) ([]string, error) {
+	warnings := []string{}
+	for _, c := range []struct {
+		limit, subject string
+		value, max     int
+	}{
+		{"MAX_SERVICES", "services", services, l.MaxServices},
+		{"MAX_CONFIG_SIZE", "bytes of the configuration", size, l.MaxConfigSize},
+	} {
+		warning, err := l.check(c.limit, c.subject, c.value, c.max)
+		if err != nil {
+			return nil, err
+		} else if len(warning) > 0 {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings, nil
+}
+
+func (l ConfigLimits) check(limit, subject string, value, max int) (string, error) {
+	if max <= 0 {
+		return "", nil
+	} else if value > max {
+		return "", &ConfigLimitError{Limit: limit, Subject: subject, Value: value, Max: max}
+	} else if l.WarningPercent > 0 && value*100 > max*l.WarningPercent {
+		return fmt.Sprintf("The %s (%d) are close to the %s limit of %d", subject, value, limit, max), nil
+	}
+	return "", nil
+}
+
+// GetConfigWarnings returns the warnings issued when the configuration was last assembled.
+func GetConfigWarnings() []string {
+	configWarningsMu.Lock()
+	defer configWarningsMu.Unlock()
+	return append([]string{}, configWarnings...)
+}
+
+// checkConfigLimits checks the assembled configuration against the limits and logs and records the warnings. Every
+// service has a single backend file so they are counted by their backend files.
+func (m HaProxy) checkConfigLimits(content string) error {
+	configs, err := readConfigsDir(m.TemplatesPath)
+	if err != nil {
+		return fmt.Errorf("Could not read the directory %s\n%s", m.TemplatesPath, err.Error())
+	}
+	services := 0
+	for _, fi := range configs {
+		if strings.HasSuffix(fi.Name(), "-be.cfg") {
+			services++
+		}
+	}
+	warnings, err := GetConfigLimits().CheckConfig(services, len(content))
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		logPrintf(warning)
+	}
+	configWarningsMu.Lock()
+	configWarnings = warnings
+	configWarningsMu.Unlock()
+	return nil
+}
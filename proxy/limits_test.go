@@ -0,0 +1,98 @@
+// +build !integration
+
+package proxy
+
+import (
+	"github.com/stretchr/testify/suite"
+	"os"
+	"testing"
+)
+
+type LimitsTestSuite struct {
+	suite.Suite
+}
+
+// GetConfigLimits
+
+func (s *LimitsTestSuite) Test_GetConfigLimits_ReturnsDefaults() {
+	actual := GetConfigLimits()
+
+	s.Equal(ConfigLimits{MaxServicePaths: 1000, MaxServiceDomains: 1000, MaxConfigSize: 16 * 1024 * 1024, WarningPercent: 80}, actual)
+}
+
+func (s *LimitsTestSuite) Test_GetConfigLimits_ReturnsEnvVars() {
+	envs := map[string]string{
+		"MAX_SERVICE_PATHS":    "10",
+		"MAX_SERVICE_DOMAINS":  "20",
+		"MAX_SERVICES":         "30",
+		"MAX_CONFIG_SIZE":      "0",
+		"CONFIG_LIMIT_WARNING": "not-a-number",
+	}
+	for key, value := range envs {
+		defer os.Unsetenv(key)
+		os.Setenv(key, value)
+	}
+
+	actual := GetConfigLimits()
+
+	s.Equal(ConfigLimits{MaxServicePaths: 10, MaxServiceDomains: 20, MaxServices: 30, WarningPercent: 80}, actual)
+}
+
+// CheckService
+
+func (s *LimitsTestSuite) Test_CheckService_ReturnsConfigLimitError_WhenLimitIsExceeded() {
+	limits := ConfigLimits{MaxServicePaths: 10, MaxServiceDomains: 5}
+
+	_, pathsErr := limits.CheckService("my-service", 11, 1)
+	_, domainsErr := limits.CheckService("my-service", 1, 6)
+
+	s.Equal(&ConfigLimitError{Limit: "MAX_SERVICE_PATHS", Subject: "paths of the service my-service", Value: 11, Max: 10}, pathsErr)
+	s.Equal("The paths of the service my-service (11) exceed the MAX_SERVICE_PATHS limit of 10", pathsErr.Error())
+	s.Equal(&ConfigLimitError{Limit: "MAX_SERVICE_DOMAINS", Subject: "domains of the service my-service", Value: 6, Max: 5}, domainsErr)
+}
+
+func (s *LimitsTestSuite) Test_CheckService_ReturnsWarnings_WhenThresholdIsExceeded() {
+	limits := ConfigLimits{MaxServicePaths: 10, MaxServiceDomains: 10, WarningPercent: 80}
+
+	atThreshold, _ := limits.CheckService("my-service", 8, 8)
+	actual, err := limits.CheckService("my-service", 9, 10)
+
+	s.NoError(err)
+	s.Empty(atThreshold)
+	s.Equal([]string{
+		"The paths of the service my-service (9) are close to the MAX_SERVICE_PATHS limit of 10",
+		"The domains of the service my-service (10) are close to the MAX_SERVICE_DOMAINS limit of 10",
+	}, actual)
+}
+
+func (s *LimitsTestSuite) Test_CheckService_DoesNotCheckDisabledLimits() {
+	actual, err := ConfigLimits{WarningPercent: 80}.CheckService("my-service", 40000, 40000)
+
+	s.NoError(err)
+	s.Empty(actual)
+}
+
+// CheckConfig
+
+func (s *LimitsTestSuite) Test_CheckConfig_ReturnsConfigLimitError_WhenLimitIsExceeded() {
+	limits := ConfigLimits{MaxServices: 2, MaxConfigSize: 100}
+
+	_, servicesErr := limits.CheckConfig(3, 10)
+	_, sizeErr := limits.CheckConfig(1, 101)
+
+	s.Equal(&ConfigLimitError{Limit: "MAX_SERVICES", Subject: "services", Value: 3, Max: 2}, servicesErr)
+	s.Equal(&ConfigLimitError{Limit: "MAX_CONFIG_SIZE", Subject: "bytes of the configuration", Value: 101, Max: 100}, sizeErr)
+}
+
+func (s *LimitsTestSuite) Test_CheckConfig_ReturnsWarnings_WhenThresholdIsExceeded() {
+	actual, err := ConfigLimits{MaxServices: 10, MaxConfigSize: 100, WarningPercent: 50}.CheckConfig(6, 50)
+
+	s.NoError(err)
+	s.Equal([]string{"The services (6) are close to the MAX_SERVICES limit of 10"}, actual)
+}
+
+// Suite
+
+func TestLimitsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LimitsTestSuite))
+}
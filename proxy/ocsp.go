@@ -0,0 +1,19 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// SetOcspResponse replaces the OCSP response stapled by the running proxy through the admin socket. HAProxy finds the
+// certificate the response belongs to by itself.
+func SetOcspResponse(der []byte) error {
+	out, err := runSocketCommand("set ssl ocsp-response " + base64.StdEncoding.EncodeToString(der))
+	if err != nil {
+		return fmt.Errorf("Could not update the OCSP response\n%s", err.Error())
+	} else if out = strings.TrimSpace(out); !strings.HasPrefix(out, "OCSP Response updated") {
+		return fmt.Errorf("Could not update the OCSP response\n%s", out)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type OcspTestSuite struct {
+	suite.Suite
+	commands []string
+	output   string
+	err      error
+}
+
+func (s *OcspTestSuite) SetupTest() {
+	s.commands = []string{}
+	s.output = "OCSP Response updated!\n"
+	s.err = nil
+	runSocketCommand = func(command string) (string, error) {
+		s.commands = append(s.commands, command)
+		return s.output, s.err
+	}
+}
+
+// SetOcspResponse
+
+func (s *OcspTestSuite) Test_SetOcspResponse_SendsEncodedResponse() {
+	err := SetOcspResponse([]byte("response"))
+
+	s.NoError(err)
+	s.Equal([]string{"set ssl ocsp-response cmVzcG9uc2U="}, s.commands)
+}
+
+func (s *OcspTestSuite) Test_SetOcspResponse_ReturnsError_WhenProxyRejectsResponse() {
+	s.output = "OCSP single response: Certificate ID does not match any certificate or issuer.\n"
+
+	err := SetOcspResponse([]byte("response"))
+
+	s.Error(err)
+	s.Contains(err.Error(), "does not match any certificate")
+}
+
+func (s *OcspTestSuite) Test_SetOcspResponse_ReturnsError_WhenSocketFails() {
+	s.err = fmt.Errorf("This is an error")
+
+	s.Error(SetOcspResponse([]byte("response")))
+}
+
+// Suite
+
+func TestOcspUnitTestSuite(t *testing.T) {
+	runSocketCommandOrig := runSocketCommand
+	defer func() { runSocketCommand = runSocketCommandOrig }()
+	suite.Run(t, new(OcspTestSuite))
+}
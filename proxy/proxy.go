@@ -3,7 +3,10 @@ package proxy
 var ProxyInstance Proxy = HaProxy{}
 
 type Data struct {
-	Certs map[string]bool
+	Certs             map[string]bool
+	DefaultCert       string
+	RequireHostHeader bool
+	DenyHttp10        bool
 }
 
 var data = Data{}
@@ -14,6 +17,7 @@ type Proxy interface {
 	ReadConfig() (string, error)
 	Reload() error
 	AddCert(certName string)
+	RemoveCert(certName string)
 	GetCerts() map[string]string
 }
 
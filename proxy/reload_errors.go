@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatsSocket is the admin socket declared in haproxy.tmpl.
+const StatsSocket = "/var/run/haproxy.sock"
+
+const maxReloadHistory = 20
+
+// ReloadErrors holds the errors HAProxy reported while a reload was sampled.
+type ReloadErrors struct {
+	ResponseErrors   int64
+	ConnectionErrors int64
+}
+
+// ReloadEntry describes a single reload. Errors are set once the sampling window elapses. Aborted reloads were
+// stopped by a failed HOOK_PRE_RELOAD.
+type ReloadEntry struct {
+	Time     time.Time
+	Sampling bool
+	Errors   *ReloadErrors `json:",omitempty"`
+	Aborted  bool          `json:",omitempty"`
+	Hooks    []HookResult  `json:",omitempty"`
+}
+
+// ReloadTotals aggregates the errors of all the sampled reloads. DeferredReloads counts the reload requests deferred
+// because of MIN_RELOAD_INTERVAL.
+type ReloadTotals struct {
+	Reloads          int64
+	SampledReloads   int64
+	ResponseErrors   int64
+	ConnectionErrors int64
+	DeferredReloads  int64
+}
+
+var reloadHistory = []*ReloadEntry{}
+var reloadTotals = ReloadTotals{}
+var reloadMu = &sync.Mutex{}
+
+var readStats = func() (string, error) {
+	conn, err := net.DialTimeout("unix", StatsSocket, time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("show stat\n")); err != nil {
+		return "", err
+	}
+	out, err := ioutil.ReadAll(conn)
+	return string(out), err
+}
+var sleep = time.Sleep
+
+// GetReloadHistory returns the most recent reloads starting with the oldest one.
+func GetReloadHistory() []ReloadEntry {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	history := []ReloadEntry{}
+	for _, entry := range reloadHistory {
+		history = append(history, *entry)
+	}
+	return history
+}
+
+// GetLastReload returns the most recent reload or nil if the proxy was not reloaded.
+func GetLastReload() *ReloadEntry {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	if len(reloadHistory) == 0 {
+		return nil
+	}
+	entry := *reloadHistory[len(reloadHistory)-1]
+	return &entry
+}
+
+// GetReloadTotals returns the errors aggregated across all the sampled reloads.
+func GetReloadTotals() ReloadTotals {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return reloadTotals
+}
+
+// getReloadErrorsWindow returns the number of seconds errors are sampled after each reload. Zero disables sampling.
+func getReloadErrorsWindow() time.Duration {
+	seconds := 10
+	if len(os.Getenv("RELOAD_ERRORS_WINDOW")) > 0 {
+		seconds, _ = strconv.Atoi(os.Getenv("RELOAD_ERRORS_WINDOW"))
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// snapshotStats reads the counters before a reload. The second value is false if the reload cannot be sampled.
+func snapshotStats() (ReloadErrors, bool) {
+	if getReloadErrorsWindow() <= 0 {
+		return ReloadErrors{}, false
+	}
+	stats, err := readStats()
+	if err != nil {
+		logPrintf("Could not read the stats from %s. Reload errors will not be sampled.\n%s", StatsSocket, err.Error())
+		return ReloadErrors{}, false
+	}
+	before, err := parseStatErrors(stats)
+	if err != nil {
+		logPrintf("Could not parse the stats. Reload errors will not be sampled.\n%s", err.Error())
+		return ReloadErrors{}, false
+	}
+	return before, true
+}
+
+// addReloadEntry records a reload while discarding the oldest entries.
+func addReloadEntry(sampling bool) *ReloadEntry {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	entry := &ReloadEntry{Time: time.Now().UTC(), Sampling: sampling}
+	reloadHistory = append(reloadHistory, entry)
+	if len(reloadHistory) > maxReloadHistory {
+		reloadHistory = reloadHistory[len(reloadHistory)-maxReloadHistory:]
+	}
+	reloadTotals.Reloads++
+	return entry
+}
+
+// addAbortedReloadEntry records a reload stopped by the hooks. It is not counted in the totals since the proxy was not
+// reloaded.
+func addAbortedReloadEntry(hooks []HookResult) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	entry := &ReloadEntry{Time: time.Now().UTC(), Aborted: true, Hooks: hooks}
+	reloadHistory = append(reloadHistory, entry)
+	if len(reloadHistory) > maxReloadHistory {
+		reloadHistory = reloadHistory[len(reloadHistory)-maxReloadHistory:]
+	}
+}
+
+// setReloadHooks attaches the results of the hooks to the entry.
+func setReloadHooks(entry *ReloadEntry, hooks []HookResult) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	entry.Hooks = hooks
+}
+
+// sampleReloadErrors waits for the window to elapse and attaches the errors that occurred since the snapshot.
+// The new HAProxy process starts with fresh counters so a counter lower than its snapshot is taken as is.
+func sampleReloadErrors(entry *ReloadEntry, before ReloadErrors, window time.Duration) {
+	sleep(window)
+	errs := ReloadErrors{}
+	stats, err := readStats()
+	if err == nil {
+		var after ReloadErrors
+		if after, err = parseStatErrors(stats); err == nil {
+			errs.ResponseErrors = counterDelta(before.ResponseErrors, after.ResponseErrors)
+			errs.ConnectionErrors = counterDelta(before.ConnectionErrors, after.ConnectionErrors)
+		}
+	}
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	entry.Sampling = false
+	if err != nil {
+		logPrintf("Could not sample the reload errors\n%s", err.Error())
+		return
+	}
+	entry.Errors = &errs
+	reloadTotals.SampledReloads++
+	reloadTotals.ResponseErrors += errs.ResponseErrors
+	reloadTotals.ConnectionErrors += errs.ConnectionErrors
+}
+
+func counterDelta(before, after int64) int64 {
+	if after < before {
+		return after
+	}
+	return after - before
+}
+
+// parseStatErrors sums the eresp and econ counters of all backends from the output of the show stat command.
+func parseStatErrors(stats string) (ReloadErrors, error) {
+	errs := ReloadErrors{}
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(stats, "# ")))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return errs, err
+	}
+	if len(records) == 0 {
+		return errs, fmt.Errorf("The stats are empty")
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{"svname", "eresp", "econ"} {
+		if _, ok := columns[name]; !ok {
+			return errs, fmt.Errorf("The stats do not contain the %s column", name)
+		}
+	}
+	for _, record := range records[1:] {
+		if len(record) <= columns["econ"] || len(record) <= columns["eresp"] || record[columns["svname"]] != "BACKEND" {
+			continue
+		}
+		eresp, _ := strconv.ParseInt(record[columns["eresp"]], 10, 64)
+		econ, _ := strconv.ParseInt(record[columns["econ"]], 10, 64)
+		errs.ResponseErrors += eresp
+		errs.ConnectionErrors += econ
+	}
+	return errs, nil
+}
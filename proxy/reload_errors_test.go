@@ -0,0 +1,169 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+type ReloadErrorsTestSuite struct {
+	suite.Suite
+}
+
+func (s *ReloadErrorsTestSuite) SetupTest() {
+	reloadHistory = []*ReloadEntry{}
+	reloadTotals = ReloadTotals{}
+	sleep = func(d time.Duration) {}
+	readPidFile = func(fileName string) ([]byte, error) {
+		return []byte("123"), nil
+	}
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		return nil
+	}
+}
+
+func getStatsSnapshot(eresp, econ int) string {
+	return fmt.Sprintf(`# pxname,svname,qcur,econ,eresp,status
+services,FRONTEND,0,,,OPEN
+my-service-be,my-service,0,%d,%d,UP
+my-service-be,BACKEND,0,%d,%d,UP
+other-be,BACKEND,0,1,1,UP
+`, econ, eresp, econ, eresp)
+}
+
+// parseStatErrors
+
+func (s ReloadErrorsTestSuite) Test_ParseStatErrors_SumsBackendCounters() {
+	actual, err := parseStatErrors(getStatsSnapshot(3, 4))
+
+	s.NoError(err)
+	s.Equal(ReloadErrors{ResponseErrors: 4, ConnectionErrors: 5}, actual)
+}
+
+func (s ReloadErrorsTestSuite) Test_ParseStatErrors_ReturnsError_WhenColumnsAreMissing() {
+	_, err := parseStatErrors("# pxname,svname,status\nservices,FRONTEND,OPEN\n")
+
+	s.Error(err)
+}
+
+// Reload
+
+func (s ReloadErrorsTestSuite) Test_Reload_AttachesErrorDeltaToReloadEntry() {
+	snapshots := []string{getStatsSnapshot(3, 4), getStatsSnapshot(10, 6)}
+	readStats = func() (string, error) {
+		snapshot := snapshots[0]
+		snapshots = snapshots[1:]
+		return snapshot, nil
+	}
+	var actualWindow time.Duration
+	sleep = func(d time.Duration) {
+		actualWindow = d
+	}
+
+	HaProxy{}.Reload()
+
+	actual := s.waitForSampling()
+	s.Equal(&ReloadErrors{ResponseErrors: 7, ConnectionErrors: 2}, actual.Errors)
+	s.Equal(10*time.Second, actualWindow)
+	s.Equal(ReloadTotals{Reloads: 1, SampledReloads: 1, ResponseErrors: 7, ConnectionErrors: 2}, GetReloadTotals())
+}
+
+func (s ReloadErrorsTestSuite) Test_Reload_UsesCountersAsIs_WhenTheyWereReset() {
+	snapshots := []string{getStatsSnapshot(10, 6), getStatsSnapshot(2, 1)}
+	readStats = func() (string, error) {
+		snapshot := snapshots[0]
+		snapshots = snapshots[1:]
+		return snapshot, nil
+	}
+
+	HaProxy{}.Reload()
+
+	actual := s.waitForSampling()
+	s.Equal(&ReloadErrors{ResponseErrors: 3, ConnectionErrors: 2}, actual.Errors)
+}
+
+func (s ReloadErrorsTestSuite) Test_Reload_DoesNotWaitForSampling() {
+	release := make(chan bool)
+	defer func() {
+		close(release)
+		s.waitForSampling()
+	}()
+	readStats = func() (string, error) {
+		return getStatsSnapshot(0, 0), nil
+	}
+	sleep = func(d time.Duration) {
+		<-release
+	}
+
+	HaProxy{}.Reload()
+
+	actual := GetLastReload()
+	s.True(actual.Sampling)
+	s.Nil(actual.Errors)
+}
+
+func (s ReloadErrorsTestSuite) Test_Reload_DoesNotSample_WhenStatsCannotBeRead() {
+	readStats = func() (string, error) {
+		return "", fmt.Errorf("This is an error")
+	}
+
+	err := HaProxy{}.Reload()
+
+	s.NoError(err)
+	s.Equal([]ReloadEntry{{Time: GetLastReload().Time}}, GetReloadHistory())
+	s.Equal(ReloadTotals{Reloads: 1}, GetReloadTotals())
+}
+
+func (s ReloadErrorsTestSuite) Test_Reload_DoesNotSample_WhenWindowIsZero() {
+	windowOrig := os.Getenv("RELOAD_ERRORS_WINDOW")
+	defer func() { os.Setenv("RELOAD_ERRORS_WINDOW", windowOrig) }()
+	os.Setenv("RELOAD_ERRORS_WINDOW", "0")
+	called := false
+	readStats = func() (string, error) {
+		called = true
+		return getStatsSnapshot(0, 0), nil
+	}
+
+	HaProxy{}.Reload()
+
+	s.False(called)
+	s.False(GetLastReload().Sampling)
+}
+
+func (s ReloadErrorsTestSuite) Test_Reload_KeepsLimitedHistory() {
+	readStats = func() (string, error) {
+		return "", fmt.Errorf("This is an error")
+	}
+
+	for i := 0; i < maxReloadHistory+5; i++ {
+		HaProxy{}.Reload()
+	}
+
+	s.Len(GetReloadHistory(), maxReloadHistory)
+	s.Equal(int64(maxReloadHistory+5), GetReloadTotals().Reloads)
+}
+
+func (s ReloadErrorsTestSuite) waitForSampling() ReloadEntry {
+	for i := 0; i < 100; i++ {
+		if entry := GetLastReload(); entry != nil && !entry.Sampling {
+			return *entry
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Fail("The reload errors were not sampled")
+	return ReloadEntry{}
+}
+
+// Suite
+
+func TestReloadErrorsUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	readStatsOrig := readStats
+	defer func() { readStats = readStatsOrig }()
+	suite.Run(t, new(ReloadErrorsTestSuite))
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var reloadTimeNow = time.Now
+var reloadAfterFunc = func(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// reloadSchedule defers the reloads requested sooner than MIN_RELOAD_INTERVAL after the previous one. All the reloads
+// requested while one is deferred are coalesced into it since HAProxy reads the latest configuration when it runs.
+type reloadSchedule struct {
+	mu   sync.Mutex
+	last time.Time
+	due  time.Time
+	stop func() bool
+}
+
+var reloads = &reloadSchedule{}
+
+// getMinReloadInterval returns the MIN_RELOAD_INTERVAL as a duration (e.g. 500ms) or a number of seconds. Zero
+// disables the deferral.
+func getMinReloadInterval() time.Duration {
+	value := os.Getenv("MIN_RELOAD_INTERVAL")
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	} else if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+// Defer returns zero if the reload can run now. Otherwise, the reload is scheduled to run through run once the interval
+// elapses and the time left until then is returned.
+func (s *reloadSchedule) Defer(interval time.Duration, run func()) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := reloadTimeNow()
+	if !s.due.IsZero() {
+		s.countDeferred()
+		return s.due.Sub(now)
+	} else if interval <= 0 || s.last.IsZero() || now.Sub(s.last) >= interval {
+		s.last = now
+		return 0
+	}
+	s.due = s.last.Add(interval)
+	s.countDeferred()
+	s.stop = reloadAfterFunc(s.due.Sub(now), func() {
+		s.mu.Lock()
+		if s.due.IsZero() {
+			// The deferred reload was flushed in the meantime
+			s.mu.Unlock()
+			return
+		}
+		s.due = time.Time{}
+		s.last = reloadTimeNow()
+		s.mu.Unlock()
+		run()
+	})
+	return s.due.Sub(now)
+}
+
+// Pending returns the time left until the deferred reload runs or zero if no reload is deferred.
+func (s *reloadSchedule) Pending() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.due.IsZero() {
+		return 0
+	}
+	return s.due.Sub(reloadTimeNow())
+}
+
+// Flush tells whether a reload was deferred and, if it was, cancels it so that the caller can run it immediately.
+func (s *reloadSchedule) Flush() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.due.IsZero() {
+		return false
+	}
+	s.stop()
+	s.due = time.Time{}
+	s.last = reloadTimeNow()
+	return true
+}
+
+func (s *reloadSchedule) countDeferred() {
+	reloadMu.Lock()
+	reloadTotals.DeferredReloads++
+	reloadMu.Unlock()
+}
+
+// GetDeferredReload returns the time left until the deferred reload runs or zero if no reload is deferred. Changes
+// applied before it was called are live once the time elapses.
+func GetDeferredReload() time.Duration {
+	return reloads.Pending()
+}
+
+// FlushDeferredReload runs the deferred reload immediately, ignoring MIN_RELOAD_INTERVAL. It does nothing if no
+// reload is deferred.
+func FlushDeferredReload() error {
+	if !reloads.Flush() {
+		return nil
+	}
+	logPrintf("Running the deferred reload")
+	return HaProxy{}.reload()
+}
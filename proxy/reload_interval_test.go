@@ -0,0 +1,185 @@
+// +build !integration
+
+package proxy
+
+import (
+	"github.com/stretchr/testify/suite"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+type ReloadIntervalTestSuite struct {
+	suite.Suite
+	now     time.Time
+	timers  []time.Duration
+	fire    []func()
+	stopped int
+	runs    int
+}
+
+func (s *ReloadIntervalTestSuite) SetupTest() {
+	s.now = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.timers = []time.Duration{}
+	s.fire = []func(){}
+	s.stopped = 0
+	s.runs = 0
+	reloads = &reloadSchedule{}
+	reloadTotals = ReloadTotals{}
+	reloadHistory = []*ReloadEntry{}
+	reloadTimeNow = func() time.Time {
+		return s.now
+	}
+	reloadAfterFunc = func(d time.Duration, f func()) func() bool {
+		s.timers = append(s.timers, d)
+		s.fire = append(s.fire, f)
+		return func() bool {
+			s.stopped++
+			return true
+		}
+	}
+	readPidFile = func(fileName string) ([]byte, error) {
+		return []byte("123"), nil
+	}
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		s.runs++
+		return nil
+	}
+	os.Setenv("RELOAD_ERRORS_WINDOW", "0")
+}
+
+func (s *ReloadIntervalTestSuite) TearDownTest() {
+	os.Unsetenv("RELOAD_ERRORS_WINDOW")
+	os.Unsetenv("MIN_RELOAD_INTERVAL")
+}
+
+func (s *ReloadIntervalTestSuite) run() {
+	s.runs++
+}
+
+// getMinReloadInterval
+
+func (s *ReloadIntervalTestSuite) Test_GetMinReloadInterval_AcceptsDurationsAndSeconds() {
+	cases := map[string]time.Duration{
+		"":      0,
+		"500ms": 500 * time.Millisecond,
+		"2":     2 * time.Second,
+		"never": 0,
+	}
+	for value, expected := range cases {
+		os.Setenv("MIN_RELOAD_INTERVAL", value)
+
+		s.Equal(expected, getMinReloadInterval(), value)
+	}
+}
+
+// Defer
+
+func (s *ReloadIntervalTestSuite) Test_Defer_RunsReloadsImmediately_WhenIntervalElapsed() {
+	s.Equal(time.Duration(0), reloads.Defer(time.Second, s.run))
+	s.now = s.now.Add(time.Second)
+
+	s.Equal(time.Duration(0), reloads.Defer(time.Second, s.run))
+	s.Empty(s.timers)
+	s.Equal(int64(0), GetReloadTotals().DeferredReloads)
+}
+
+func (s *ReloadIntervalTestSuite) Test_Defer_SchedulesReloadAtEndOfInterval() {
+	reloads.Defer(time.Second, s.run)
+	s.now = s.now.Add(300 * time.Millisecond)
+
+	actual := reloads.Defer(time.Second, s.run)
+
+	s.Equal(700*time.Millisecond, actual)
+	s.Equal([]time.Duration{700 * time.Millisecond}, s.timers)
+	s.Equal(700*time.Millisecond, reloads.Pending())
+	s.Equal(0, s.runs)
+}
+
+func (s *ReloadIntervalTestSuite) Test_Defer_CoalescesReloadsRequestedWhileOneIsDeferred() {
+	reloads.Defer(time.Second, s.run)
+	s.now = s.now.Add(300 * time.Millisecond)
+	reloads.Defer(time.Second, s.run)
+	s.now = s.now.Add(500 * time.Millisecond)
+
+	actual := reloads.Defer(time.Second, s.run)
+	s.now = s.now.Add(200 * time.Millisecond)
+	s.fire[0]()
+
+	s.Equal(200*time.Millisecond, actual)
+	s.Len(s.timers, 1)
+	s.Equal(1, s.runs)
+	s.Equal(int64(2), GetReloadTotals().DeferredReloads)
+	s.Equal(time.Duration(0), reloads.Pending())
+}
+
+func (s *ReloadIntervalTestSuite) Test_Defer_MeasuresIntervalFromDeferredReload() {
+	reloads.Defer(time.Second, s.run)
+	s.now = s.now.Add(300 * time.Millisecond)
+	reloads.Defer(time.Second, s.run)
+	s.now = s.now.Add(700 * time.Millisecond)
+	s.fire[0]()
+	s.now = s.now.Add(400 * time.Millisecond)
+
+	actual := reloads.Defer(time.Second, s.run)
+
+	s.Equal(600*time.Millisecond, actual)
+}
+
+// Reload
+
+func (s *ReloadIntervalTestSuite) Test_Reload_DefersReload_WhenMinReloadIntervalDidNotElapse() {
+	os.Setenv("MIN_RELOAD_INTERVAL", "1s")
+	HaProxy{}.Reload()
+	s.now = s.now.Add(100 * time.Millisecond)
+
+	err := HaProxy{}.Reload()
+
+	s.NoError(err)
+	s.Equal(1, s.runs)
+	s.Equal(900*time.Millisecond, GetDeferredReload())
+	s.fire[0]()
+	s.Equal(2, s.runs)
+	s.Equal(time.Duration(0), GetDeferredReload())
+}
+
+// FlushDeferredReload
+
+func (s *ReloadIntervalTestSuite) Test_FlushDeferredReload_RunsDeferredReloadImmediately() {
+	os.Setenv("MIN_RELOAD_INTERVAL", "1s")
+	HaProxy{}.Reload()
+	HaProxy{}.Reload()
+
+	err := FlushDeferredReload()
+	s.fire[0]()
+
+	s.NoError(err)
+	s.Equal(2, s.runs)
+	s.Equal(1, s.stopped)
+	s.Equal(time.Duration(0), GetDeferredReload())
+}
+
+func (s *ReloadIntervalTestSuite) Test_FlushDeferredReload_DoesNothing_WhenNoReloadIsDeferred() {
+	err := FlushDeferredReload()
+
+	s.NoError(err)
+	s.Equal(0, s.runs)
+}
+
+// Suite
+
+func TestReloadIntervalUnitTestSuite(t *testing.T) {
+	reloadTimeNowOrig := reloadTimeNow
+	reloadAfterFuncOrig := reloadAfterFunc
+	readPidFileOrig := readPidFile
+	cmdRunHaOrig := cmdRunHa
+	defer func() {
+		reloadTimeNow = reloadTimeNowOrig
+		reloadAfterFunc = reloadAfterFuncOrig
+		readPidFile = readPidFileOrig
+		cmdRunHa = cmdRunHaOrig
+		reloads = &reloadSchedule{}
+	}()
+	suite.Run(t, new(ReloadIntervalTestSuite))
+}
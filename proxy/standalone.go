@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// serverLineRegexp matches server lines (e.g. server my-service my-service:8080 check).
+var serverLineRegexp = regexp.MustCompile(`^(\s*server\s+)(\S+)\s+([^\s:]+):(\d+)(.*)$`)
+
+// filePathRegexp matches the arguments referencing files that are mounted into the proxy (e.g. crt /certs/my-cert.pem).
+var filePathRegexp = regexp.MustCompile(`(\b(?:crt|ca-file|crl-file|errorfile\s+\d+)\s+)(/\S+)`)
+
+// CreateStandaloneConfig converts the configuration into one that can run outside the cluster. Servers addressed by
+// names that only the cluster DNS can resolve are replaced with the addresses they currently resolve to. The result
+// is checked with HAProxy before the paths of the mounted files are moved under the prefix, since the files exist
+// only under the original paths.
+func CreateStandaloneConfig(config, pathPrefix string) (string, error) {
+	resolved, err := resolveServers(config)
+	if err != nil {
+		return "", err
+	}
+	if err := checkStandaloneConfig(resolved); err != nil {
+		return "", err
+	}
+	return rewriteFilePaths(resolved, pathPrefix), nil
+}
+
+// resolveServers replaces each server addressed by a name with a server for each of the addresses the name resolves
+// to. Servers addressed by IPs are left as they are.
+func resolveServers(config string) (string, error) {
+	lines := []string{}
+	unresolved := []string{}
+	for _, line := range strings.Split(config, "\n") {
+		parts := serverLineRegexp.FindStringSubmatch(line)
+		if parts == nil || net.ParseIP(parts[3]) != nil {
+			lines = append(lines, line)
+			continue
+		}
+		ips, err := lookupHost(parts[3])
+		if err != nil || len(ips) == 0 {
+			unresolved = append(unresolved, parts[3])
+			continue
+		}
+		for i, ip := range ips {
+			name := parts[2]
+			if len(ips) > 1 {
+				name = fmt.Sprintf("%s_%d", parts[2], i)
+			}
+			lines = append(lines, fmt.Sprintf("%s%s %s:%s%s", parts[1], name, ip, parts[4], parts[5]))
+		}
+	}
+	if len(unresolved) > 0 {
+		return "", fmt.Errorf("Could not resolve the addresses of the servers %s", strings.Join(unresolved, ", "))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func checkStandaloneConfig(config string) error {
+	file, err := ioutil.TempFile("", "standalone")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	file.Close()
+	if err := writeFile(file.Name(), []byte(config), 0664); err != nil {
+		return err
+	}
+	return checkConfigFile(file.Name())
+}
+
+// rewriteFilePaths moves the files referenced by the configuration into the directory. Paths are not changed if the
+// directory is empty.
+func rewriteFilePaths(config, dir string) string {
+	if len(dir) == 0 {
+		return config
+	}
+	return filePathRegexp.ReplaceAllStringFunc(config, func(match string) string {
+		parts := filePathRegexp.FindStringSubmatch(match)
+		return parts[1] + filepath.Join(dir, filepath.Base(parts[2]))
+	})
+}
@@ -0,0 +1,140 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+type StandaloneTestSuite struct {
+	suite.Suite
+	liveConfig    string
+	checkedConfig string
+	checkedArgs   []string
+}
+
+func (s *StandaloneTestSuite) SetupTest() {
+	s.liveConfig = `global
+    pidfile /var/run/haproxy.pid
+    stats socket /var/run/haproxy.sock mode 600 level admin
+    tune.ssl.default-dh-param 2048
+
+frontend services
+    bind *:80
+    bind *:443 ssl crt /certs/my-cert.pem crt /certs/other-cert.pem
+    mode http
+    acl url_my-service path_beg /api
+    use_backend my-service-be if url_my-service
+
+backend my-service-be
+    mode http
+    server my-service my-service:8080
+
+backend scaled-service-be
+    mode http
+    server scaled-service scaled-service:9090 check check-proto h2
+
+backend dummy-be
+    server dummy 1.1.1.1:1111 check`
+	s.checkedConfig = ""
+	s.checkedArgs = nil
+	lookupHost = func(host string) ([]string, error) {
+		switch host {
+		case "my-service":
+			return []string{"10.0.0.2"}, nil
+		case "scaled-service":
+			return []string{"10.0.0.3", "10.0.0.4"}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		s.checkedConfig = string(data)
+		return nil
+	}
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		s.checkedArgs = cmd.Args
+		return nil
+	}
+}
+
+func (s *StandaloneTestSuite) getExpected(certsDir string) string {
+	expected := strings.Replace(s.liveConfig, "/certs/", certsDir, -1)
+	expected = strings.Replace(expected, "server my-service my-service:8080", "server my-service 10.0.0.2:8080", -1)
+	return strings.Replace(
+		expected,
+		"    server scaled-service scaled-service:9090 check check-proto h2",
+		`    server scaled-service_0 10.0.0.3:9090 check check-proto h2
+    server scaled-service_1 10.0.0.4:9090 check check-proto h2`,
+		-1,
+	)
+}
+
+// CreateStandaloneConfig
+
+func (s *StandaloneTestSuite) Test_CreateStandaloneConfig_ResolvesServersAndRewritesPaths() {
+	actual, err := CreateStandaloneConfig(s.liveConfig, "/opt/dr/")
+
+	s.NoError(err)
+	s.Equal(s.getExpected("/opt/dr/"), actual)
+}
+
+func (s *StandaloneTestSuite) Test_CreateStandaloneConfig_AddsSeparatorToPathPrefix() {
+	actual, _ := CreateStandaloneConfig(s.liveConfig, "/opt/dr")
+
+	s.Contains(actual, "crt /opt/dr/my-cert.pem crt /opt/dr/other-cert.pem")
+}
+
+func (s *StandaloneTestSuite) Test_CreateStandaloneConfig_KeepsPaths_WhenPathPrefixIsEmpty() {
+	actual, _ := CreateStandaloneConfig(s.liveConfig, "")
+
+	s.Equal(s.getExpected("/certs/"), actual)
+}
+
+func (s *StandaloneTestSuite) Test_CreateStandaloneConfig_ChecksConfigWithOriginalPaths() {
+	CreateStandaloneConfig(s.liveConfig, "/opt/dr/")
+
+	s.Equal(s.getExpected("/certs/"), s.checkedConfig)
+	s.Equal([]string{"haproxy", "-c", "-f"}, s.checkedArgs[:3])
+}
+
+func (s *StandaloneTestSuite) Test_CreateStandaloneConfig_ReturnsError_WhenCheckFails() {
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		return fmt.Errorf("exit status 1")
+	}
+
+	_, err := CreateStandaloneConfig(s.liveConfig, "/opt/dr/")
+
+	s.Error(err)
+}
+
+func (s *StandaloneTestSuite) Test_CreateStandaloneConfig_ReturnsError_WhenServersCannotBeResolved() {
+	config := s.liveConfig + `
+
+backend unknown-be
+    server unknown-service unknown-service:8080`
+
+	_, err := CreateStandaloneConfig(config, "/opt/dr/")
+
+	s.EqualError(err, "Could not resolve the addresses of the servers unknown-service")
+	s.Empty(s.checkedArgs)
+}
+
+// Suite
+
+func TestStandaloneUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	lookupHostOrig := lookupHost
+	writeFileOrig := writeFile
+	cmdRunHaOrig := cmdRunHa
+	defer func() {
+		lookupHost = lookupHostOrig
+		writeFile = writeFileOrig
+		cmdRunHa = cmdRunHaOrig
+	}()
+	suite.Run(t, new(StandaloneTestSuite))
+}
@@ -3,6 +3,7 @@ package proxy
 import (
 	"io/ioutil"
 	"log"
+	"net"
 	"os/exec"
 )
 
@@ -15,3 +16,4 @@ var ReadFile = ioutil.ReadFile
 var logPrintf = log.Printf
 var readPidFile = ioutil.ReadFile
 var readConfigsDir = ioutil.ReadDir
+var lookupHost = net.LookupHost
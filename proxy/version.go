@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+var versionRegexp = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+var readHaProxyVersion = func() (string, error) {
+	out, err := exec.Command("haproxy", "-v").Output()
+	return string(out), err
+}
+
+// GetVersion returns the version of HAProxy (e.g. 1.6.9).
+// The HAPROXY_VERSION environment variable takes precedence over the version reported by the haproxy binary.
+func GetVersion() string {
+	if len(os.Getenv("HAPROXY_VERSION")) > 0 {
+		return os.Getenv("HAPROXY_VERSION")
+	}
+	out, err := readHaProxyVersion()
+	if err != nil {
+		logPrintf("Could not detect the HAProxy version\n%s", err.Error())
+		return ""
+	}
+	return versionRegexp.FindString(out)
+}
+
+// VersionAtLeast returns true when the HAProxy version is equal or greater than major.minor.
+// It returns false if the version could not be detected.
+func VersionAtLeast(major, minor int) bool {
+	parts := versionRegexp.FindStringSubmatch(GetVersion())
+	if len(parts) != 3 {
+		return false
+	}
+	actualMajor, _ := strconv.Atoi(parts[1])
+	actualMinor, _ := strconv.Atoi(parts[2])
+	return actualMajor > major || (actualMajor == major && actualMinor >= minor)
+}
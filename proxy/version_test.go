@@ -0,0 +1,85 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"testing"
+)
+
+type VersionTestSuite struct {
+	suite.Suite
+}
+
+func (s *VersionTestSuite) SetupTest() {
+	os.Unsetenv("HAPROXY_VERSION")
+}
+
+// GetVersion
+
+func (s VersionTestSuite) Test_GetVersion_ReturnsVersionFromHaProxy() {
+	readHaProxyVersionOrig := readHaProxyVersion
+	defer func() { readHaProxyVersion = readHaProxyVersionOrig }()
+	readHaProxyVersion = func() (string, error) {
+		return "HA-Proxy version 2.2.4-de45672 2020/09/30 - https://haproxy.org/", nil
+	}
+
+	s.Equal("2.2", GetVersion())
+}
+
+func (s VersionTestSuite) Test_GetVersion_ReturnsEnvVar_WhenPresent() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	os.Setenv("HAPROXY_VERSION", "1.7")
+
+	s.Equal("1.7", GetVersion())
+}
+
+func (s VersionTestSuite) Test_GetVersion_ReturnsEmptyString_WhenHaProxyFails() {
+	readHaProxyVersionOrig := readHaProxyVersion
+	defer func() { readHaProxyVersion = readHaProxyVersionOrig }()
+	readHaProxyVersion = func() (string, error) {
+		return "", fmt.Errorf("This is an error")
+	}
+
+	s.Equal("", GetVersion())
+}
+
+// VersionAtLeast
+
+func (s VersionTestSuite) Test_VersionAtLeast_ComparesMajorAndMinor() {
+	defer func() { os.Unsetenv("HAPROXY_VERSION") }()
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{"1.6", false},
+		{"2.1", false},
+		{"2.2", true},
+		{"2.10", true},
+		{"3.0", true},
+	}
+	for _, t := range tests {
+		os.Setenv("HAPROXY_VERSION", t.version)
+
+		s.Equal(t.expected, VersionAtLeast(2, 2), t.version)
+	}
+}
+
+func (s VersionTestSuite) Test_VersionAtLeast_ReturnsFalse_WhenVersionIsUnknown() {
+	readHaProxyVersionOrig := readHaProxyVersion
+	defer func() { readHaProxyVersion = readHaProxyVersionOrig }()
+	readHaProxyVersion = func() (string, error) {
+		return "", fmt.Errorf("This is an error")
+	}
+
+	s.False(VersionAtLeast(1, 0))
+}
+
+// Suite
+
+func TestVersionUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(VersionTestSuite))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// queueRetryAfter is the number of seconds clients are asked to wait when the queue is full.
+const queueRetryAfter = 5
+
+var errQueueFull = errors.New("The queue of pending requests is full. Please retry later.")
+
+// apiQueue holds the reconfigure and remove operations. Operations are executed directly if it is not set.
+var apiQueue *workQueue
+
+// QueueStats describes the state of the queue.
+type QueueStats struct {
+	Depth      int64
+	Rejected   int64
+	Superseded int64
+}
+
+// getQueueStats returns the state of the queue or zero values if there is no queue.
+var getQueueStats = func() QueueStats {
+	if apiQueue == nil {
+		return QueueStats{}
+	}
+	return apiQueue.Stats()
+}
+
+// workQueue runs operations with limited parallelism. Operations on the same service run in the order they were
+// received and a pending operation is replaced by a later one for the same service.
+type workQueue struct {
+	mu         sync.Mutex
+	cond       *sync.Cond
+	size       int
+	pending    []*queuedJob
+	running    map[string]bool
+	rejected   int64
+	superseded int64
+}
+
+type queuedJob struct {
+	key     string
+	run     func() error
+	waiters []chan error
+}
+
+// newWorkQueue returns a queue that holds up to size pending operations and starts the workers that drain it.
+func newWorkQueue(size, workers int) *workQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &workQueue{size: size, running: map[string]bool{}}
+	q.cond = sync.NewCond(&q.mu)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Do queues the operation and waits until it is executed. If a later operation for the same key arrives while it is
+// still pending, only the later one is executed and both callers receive its result. errQueueFull is returned without
+// waiting if the queue is full.
+func (q *workQueue) Do(key string, run func() error) error {
+	done := make(chan error, 1)
+	q.mu.Lock()
+	if i := q.indexOf(key); i >= 0 {
+		q.pending[i] = &queuedJob{key: key, run: run, waiters: append(q.pending[i].waiters, done)}
+		q.superseded++
+	} else if len(q.pending) >= q.size {
+		q.rejected++
+		q.mu.Unlock()
+		return errQueueFull
+	} else {
+		q.pending = append(q.pending, &queuedJob{key: key, run: run, waiters: []chan error{done}})
+		q.cond.Broadcast()
+	}
+	q.mu.Unlock()
+	return <-done
+}
+
+// Stats returns the number of pending operations and the counters of rejected and superseded ones.
+func (q *workQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueStats{Depth: int64(len(q.pending)), Rejected: q.rejected, Superseded: q.superseded}
+}
+
+func (q *workQueue) indexOf(key string) int {
+	for i, job := range q.pending {
+		if job.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// next returns the index of the oldest pending operation whose key is not being worked on or -1 if there is none.
+func (q *workQueue) next() int {
+	for i, job := range q.pending {
+		if !q.running[job.key] {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *workQueue) work() {
+	for {
+		q.mu.Lock()
+		i := q.next()
+		for i < 0 {
+			q.cond.Wait()
+			i = q.next()
+		}
+		job := q.pending[i]
+		q.pending = append(q.pending[:i], q.pending[i+1:]...)
+		q.running[job.key] = true
+		q.mu.Unlock()
+		err := job.run()
+		q.mu.Lock()
+		delete(q.running, job.key)
+		q.cond.Broadcast()
+		q.mu.Unlock()
+		for _, waiter := range job.waiters {
+			waiter <- err
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// +build !integration
+
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type QueueTestSuite struct {
+	suite.Suite
+}
+
+// Do
+
+func (s QueueTestSuite) Test_Do_ReturnsTheResultOfTheOperation() {
+	q := newWorkQueue(10, 1)
+
+	err := q.Do("my-service", func() error {
+		return fmt.Errorf("This is an error")
+	})
+
+	s.EqualError(err, "This is an error")
+	s.Equal(QueueStats{}, q.Stats())
+}
+
+func (s QueueTestSuite) Test_Do_ReturnsErrQueueFull_WhenQueueIsFull() {
+	q := newWorkQueue(1, 1)
+	release := s.block(q)
+	go q.Do("service-2", func() error { return nil })
+	s.waitForDepth(q, 1)
+
+	err := q.Do("service-3", func() error { return nil })
+
+	s.Equal(errQueueFull, err)
+	s.Equal(QueueStats{Depth: 1, Rejected: 1}, q.Stats())
+	close(release)
+}
+
+func (s QueueTestSuite) Test_Do_ExecutesOnlyTheLastPendingOperationForTheSameService() {
+	q := newWorkQueue(10, 1)
+	release := s.block(q)
+	executed := make(chan int, 3)
+	results := make(chan error, 3)
+	for i := 1; i <= 3; i++ {
+		version := i
+		go func() {
+			results <- q.Do("my-service", func() error {
+				executed <- version
+				return fmt.Errorf("version %d", version)
+			})
+		}()
+		s.waitForStats(q, func(stats QueueStats) bool { return stats.Superseded == int64(version-1) && stats.Depth == 1 })
+	}
+
+	close(release)
+
+	for i := 0; i < 3; i++ {
+		s.EqualError(<-results, "version 3")
+	}
+	s.Equal(3, <-executed)
+	s.Len(executed, 0)
+	s.Equal(QueueStats{Superseded: 2}, q.Stats())
+}
+
+func (s QueueTestSuite) Test_Do_AcceptsLaterOperationForTheSameService_WhenQueueIsFull() {
+	q := newWorkQueue(1, 1)
+	release := s.block(q)
+	go q.Do("my-service", func() error { return nil })
+	s.waitForDepth(q, 1)
+	result := make(chan error)
+
+	go func() {
+		result <- q.Do("my-service", func() error { return nil })
+	}()
+	s.waitForStats(q, func(stats QueueStats) bool { return stats.Superseded == 1 })
+	close(release)
+
+	s.NoError(<-result)
+	s.Equal(int64(0), q.Stats().Rejected)
+}
+
+func (s QueueTestSuite) Test_Do_LimitsTheNumberOfOperationsRunningInParallel() {
+	q := newWorkQueue(100, 3)
+	running := int64(0)
+	max := int64(0)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q.Do(fmt.Sprintf("service-%d", i), func() error {
+				current := atomic.AddInt64(&running, 1)
+				for {
+					previous := atomic.LoadInt64(&max)
+					if current <= previous || atomic.CompareAndSwapInt64(&max, previous, current) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&running, -1)
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	s.True(max <= 3, fmt.Sprintf("%d operations were running in parallel", max))
+	s.True(max > 1, "The operations were not running in parallel")
+	s.Equal(int64(0), q.Stats().Depth)
+}
+
+func (s QueueTestSuite) Test_Do_DoesNotRunOperationsForTheSameServiceInParallel() {
+	q := newWorkQueue(100, 4)
+	running := int64(0)
+	overlaps := int64(0)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Do("my-service", func() error {
+				if atomic.AddInt64(&running, 1) > 1 {
+					atomic.AddInt64(&overlaps, 1)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&running, -1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(int64(0), overlaps)
+}
+
+// block keeps a worker busy until the returned channel is closed.
+func (s QueueTestSuite) block(q *workQueue) chan bool {
+	started := make(chan bool)
+	release := make(chan bool)
+	go q.Do("blocker", func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	return release
+}
+
+func (s QueueTestSuite) waitForDepth(q *workQueue, depth int64) {
+	s.waitForStats(q, func(stats QueueStats) bool { return stats.Depth == depth })
+}
+
+func (s QueueTestSuite) waitForStats(q *workQueue, condition func(stats QueueStats) bool) {
+	for i := 0; i < 100; i++ {
+		if condition(q.Stats()) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Fail("The queue did not reach the expected state")
+}
+
+// Suite
+
+func TestQueueUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(QueueTestSuite))
+}
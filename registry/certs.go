@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// CERTS_KEY_PREFIX is the Consul KV prefix certificates are stored under. It is shared by all proxy instances.
+const CERTS_KEY_PREFIX = "docker-flow/certs"
+
+// PutCert stores the certificate under CERTS_KEY_PREFIX. The content is base64 encoded so that any PEM content
+// survives the KV API.
+func (m Consul) PutCert(addresses []string, certName string, certContent []byte) error {
+	value := base64.StdEncoding.EncodeToString(certContent)
+	if err := m.sendKvRequest("PUT", addresses, CERTS_KEY_PREFIX+"/"+certName, value); err != nil {
+		return fmt.Errorf("Could not store the certificate %s in Consul\n%s", certName, err.Error())
+	}
+	return nil
+}
+
+// DeleteCert removes the certificate from CERTS_KEY_PREFIX.
+func (m Consul) DeleteCert(addresses []string, certName string) error {
+	if err := m.sendKvRequest("DELETE", addresses, CERTS_KEY_PREFIX+"/"+certName, ""); err != nil {
+		return fmt.Errorf("Could not remove the certificate %s from Consul\n%s", certName, err.Error())
+	}
+	return nil
+}
+
+// GetCerts returns the decoded content of all certificates stored under CERTS_KEY_PREFIX mapped by their names. Only
+// the CA certificates, stored under the ca/ prefix, are returned from a nested key.
+func (m Consul) GetCerts(addresses []string) (map[string][]byte, error) {
+	var err error
+	for _, address := range OrderConsulAddresses(addresses) {
+		var resp *http.Response
+		url := fmt.Sprintf("%s/v1/kv/%s/?recurse", getConsulUrl(address), CERTS_KEY_PREFIX)
+		resp, err = getConsulClient().Get(url)
+		MarkConsulAddress(address, err)
+		if err != nil {
+			continue
+		}
+		defer resp.Body.Close()
+		certs := map[string][]byte{}
+		if resp.StatusCode == http.StatusNotFound {
+			return certs, nil
+		} else if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("Consul responded with the status %d", resp.StatusCode)
+			continue
+		}
+		// Consul encodes values with base64 as well so the JSON decoder returns the stored base64 content
+		entries := []struct {
+			Key   string
+			Value []byte
+		}{}
+		body, _ := ioutil.ReadAll(resp.Body)
+		if err = json.Unmarshal(body, &entries); err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := strings.TrimPrefix(entry.Key, CERTS_KEY_PREFIX+"/")
+			content, decodeErr := base64.StdEncoding.DecodeString(string(entry.Value))
+			if len(name) == 0 || strings.Contains(strings.TrimPrefix(name, "ca/"), "/") || decodeErr != nil {
+				continue
+			}
+			certs[name] = content
+		}
+		return certs, nil
+	}
+	return nil, fmt.Errorf("Could not retrieve the certificates from Consul\n%s", err)
+}
+
+// sendKvRequest sends the request for the key to the first Consul address that responds with the status 200.
+func (m Consul) sendKvRequest(requestType string, addresses []string, key, value string) error {
+	var err error
+	for _, address := range OrderConsulAddresses(addresses) {
+		url := fmt.Sprintf("%s/v1/kv/%s", getConsulUrl(address), key)
+		request, _ := http.NewRequest(requestType, url, strings.NewReader(value))
+		var resp *http.Response
+		resp, err = getConsulClient().Do(request)
+		MarkConsulAddress(address, err)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("Consul responded with the status %d", resp.StatusCode)
+		}
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type CertsTestSuite struct {
+	suite.Suite
+}
+
+func TestCertsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(CertsTestSuite))
+}
+
+// PutCert
+
+func (s *CertsTestSuite) Test_PutCert_PutsBase64EncodedContentUnderCertsPrefix() {
+	var actualMethod, actualPath, actualBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		actualMethod, actualPath, actualBody = r.Method, r.URL.Path, string(body)
+	}))
+	defer server.Close()
+
+	err := Consul{}.PutCert([]string{server.URL}, "my-cert.pem", []byte("THIS IS A CERTIFICATE"))
+
+	s.NoError(err)
+	s.Equal("PUT", actualMethod)
+	s.Equal("/v1/kv/docker-flow/certs/my-cert.pem", actualPath)
+	s.Equal(base64.StdEncoding.EncodeToString([]byte("THIS IS A CERTIFICATE")), actualBody)
+}
+
+func (s *CertsTestSuite) Test_PutCert_ReturnsError_WhenConsulRespondsWithError() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := Consul{}.PutCert([]string{server.URL}, "my-cert.pem", []byte("THIS IS A CERTIFICATE"))
+
+	s.Error(err)
+}
+
+// DeleteCert
+
+func (s *CertsTestSuite) Test_DeleteCert_SendsDeleteRequest() {
+	var actualMethod, actualPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualMethod, actualPath = r.Method, r.URL.Path
+	}))
+	defer server.Close()
+
+	err := Consul{}.DeleteCert([]string{server.URL}, "my-cert.pem")
+
+	s.NoError(err)
+	s.Equal("DELETE", actualMethod)
+	s.Equal("/v1/kv/docker-flow/certs/my-cert.pem", actualPath)
+}
+
+// GetCerts
+
+func (s *CertsTestSuite) Test_GetCerts_ReturnsDecodedCerts() {
+	var actualQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualQuery = r.URL.RawQuery
+		// Consul encodes the stored values with base64 once more
+		value := base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString([]byte("THIS IS A CERTIFICATE"))))
+		fmt.Fprintf(w, `[{"Key": "docker-flow/certs/my-cert.pem", "Value": "%s"}, {"Key": "docker-flow/certs/nested/cert.pem", "Value": "%s"}, {"Key": "docker-flow/certs/ca/my-ca.pem", "Value": "%s"}]`, value, value, value)
+	}))
+	defer server.Close()
+
+	actual, err := Consul{}.GetCerts([]string{server.URL})
+
+	s.NoError(err)
+	s.Equal("recurse", actualQuery)
+	s.Equal(map[string][]byte{"my-cert.pem": []byte("THIS IS A CERTIFICATE"), "ca/my-ca.pem": []byte("THIS IS A CERTIFICATE")}, actual)
+}
+
+func (s *CertsTestSuite) Test_GetCerts_ReturnsEmptyMap_WhenPrefixDoesNotExist() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	actual, err := Consul{}.GetCerts([]string{server.URL})
+
+	s.NoError(err)
+	s.Empty(actual)
+}
+
+func (s *CertsTestSuite) Test_GetCerts_ReturnsError_WhenConsulIsNotReachable() {
+	_, err := Consul{}.GetCerts([]string{"http:///THIS/URL/DOES/NOT/EXIST"})
+
+	s.Error(err)
+}
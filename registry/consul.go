@@ -6,13 +6,13 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 type Consul struct{}
 
-//TODO: Cache a valid address
-
 var cmdRunConsulTemplate = func(cmd *exec.Cmd) error {
 	return cmd.Run()
 }
@@ -26,6 +26,7 @@ type CreateConfigsArgs struct {
 	BeFile        string
 	BeTemplate    string
 	ServiceName   string
+	InternalOnly  bool
 }
 
 func (m Consul) PutService(addresses []string, instanceName string, r Registry) error {
@@ -41,6 +42,86 @@ func (m Consul) PutService(addresses []string, instanceName string, r Registry)
 		data{CONSUL_TEMPLATE_FE_PATH_KEY, r.ConsulTemplateFePath},
 		data{CONSUL_TEMPLATE_BE_PATH_KEY, r.ConsulTemplateBePath},
 		data{PORT, r.Port},
+		data{ADDRESS_KEY, r.ServiceAddress},
+		data{COLOR_ADDRESSES_KEY, FormatColorAddresses(r.ColorAddresses)},
+		data{DC_ADDRESSES_KEY, FormatColorAddresses(r.DcAddresses)},
+		data{CORS_ORIGINS_KEY, strings.Join(r.CorsOrigins, ",")},
+		data{CORS_METHODS_KEY, strings.Join(r.CorsMethods, ",")},
+		data{CORS_HEADERS_KEY, strings.Join(r.CorsHeaders, ",")},
+		data{INTERNAL_ONLY_KEY, fmt.Sprintf("%t", r.InternalOnly)},
+		data{CHECK_GRPC_KEY, fmt.Sprintf("%t", r.CheckGrpc)},
+		data{PROFILE_KEY, r.Profile},
+		data{EXPLICIT_PARAMETERS_KEY, strings.Join(r.ExplicitParameters, ",")},
+		data{TRACING_SAMPLE_RATE_KEY, r.TracingSampleRate},
+		data{CANARY_HEADER_KEY, r.CanaryHeader},
+		data{SERVICE_DESCRIPTION_KEY, r.ServiceDescription},
+		data{OWNER_KEY, r.Owner},
+		data{SERVICE_DISPLAY_NAME_KEY, r.ServiceDisplayName},
+		data{TIMEOUT_SERVER_KEY, strconv.Itoa(r.TimeoutServer)},
+		data{TIMEOUT_TUNNEL_KEY, strconv.Itoa(r.TimeoutTunnel)},
+		data{TIMEOUT_HTTP_REQUEST_KEY, strconv.Itoa(r.TimeoutHttpRequest)},
+		data{TIMEOUT_QUEUE_KEY, strconv.Itoa(r.TimeoutQueue)},
+		data{TIMEOUT_CONNECT_KEY, strconv.Itoa(r.TimeoutConnect)},
+		data{TIMEOUT_CLIENT_KEY, strconv.Itoa(r.TimeoutClient)},
+		data{STACK_NAME_KEY, r.StackName},
+		data{ALLOW_MISSING_HOST_KEY, fmt.Sprintf("%t", r.AllowMissingHost)},
+		data{USE_DOMAIN_MAP_KEY, fmt.Sprintf("%t", r.UseDomainMap)},
+		data{LETS_ENCRYPT_KEY, fmt.Sprintf("%t", r.LetsEncrypt)},
+		data{PROXY_ROLE_KEY, r.ProxyRole},
+		data{CLIENT_CERT_VERIFY_KEY, r.ClientCertVerify},
+		data{CLIENT_CERT_CA_FILE_KEY, r.ClientCertCaFile},
+		data{TCP_DESTINATIONS_KEY, r.TcpDestinations},
+		data{HTTP_DESTINATIONS_KEY, r.HttpDestinations},
+		data{MAINTENANCE_KEY, r.Maintenance},
+		data{MAINTENANCE_WINDOW_KEY, r.MaintenanceWindow},
+		data{CERT_NAME_KEY, r.CertName},
+		data{HTTP_REUSE_KEY, r.HttpReuse},
+		data{POOL_MAX_CONN_KEY, r.PoolMaxConn},
+		data{POOL_PURGE_DELAY_KEY, r.PoolPurgeDelay},
+		data{POST_RELOAD_HOOK_KEY, r.PostReloadHook},
+		data{SRC_PORT_KEY, r.SrcPort},
+		data{HTTPS_ONLY_KEY, fmt.Sprintf("%t", r.HttpsOnly)},
+		data{HTTPS_PORT_KEY, r.HttpsPort},
+		data{SSL_VERIFY_NONE_KEY, fmt.Sprintf("%t", r.SslVerifyNone)},
+		data{SSL_CA_CERT_KEY, r.SslCaCert},
+		data{SESSION_TYPE_KEY, r.SessionType},
+		data{COOKIE_NAME_KEY, r.CookieName},
+		data{BALANCE_KEY, r.Balance},
+		data{CONNECTION_MODE_KEY, r.ConnectionMode},
+		data{REQ_MODE_KEY, r.ReqMode},
+		data{SERVICE_DOMAIN_MATCH_ALL_KEY, fmt.Sprintf("%t", r.ServiceDomainMatchAll)},
+		data{REDIRECT_FROM_DOMAIN_KEY, strings.Join(r.RedirectFromDomain, ",")},
+		data{ALLOWED_METHODS_KEY, strings.Join(r.AllowedMethods, ",")},
+		data{DENIED_METHODS_KEY, strings.Join(r.DeniedMethods, ",")},
+		data{DENY_HTTP_KEY, fmt.Sprintf("%t", r.DenyHttp)},
+		data{ADD_REQ_HEADER_KEY, r.AddReqHeader},
+		data{SET_REQ_HEADER_KEY, r.SetReqHeader},
+		data{DEL_REQ_HEADER_KEY, r.DelReqHeader},
+		data{ADD_RES_HEADER_KEY, r.AddResHeader},
+		data{DEL_RES_HEADER_KEY, r.DelResHeader},
+		data{FORWARDED_PROTO_KEY, r.ForwardedProto},
+		data{COMPRESSION_ALGO_KEY, strings.Join(r.CompressionAlgo, ",")},
+		data{COMPRESSION_TYPE_KEY, strings.Join(r.CompressionType, ",")},
+		data{REQ_RATE_LIMIT_KEY, r.ReqRateLimit},
+		data{REQ_RATE_PERIOD_KEY, strconv.Itoa(r.ReqRatePeriod)},
+		data{ALLOWED_SOURCE_IPS_KEY, strings.Join(r.AllowedSourceIPs, ",")},
+		data{BACKEND_EXTRA_KEY, r.BackendExtra},
+		data{FRONTEND_EXTRA_KEY, r.FrontendExtra},
+		data{CHECK_PATH_KEY, r.CheckPath},
+		data{CHECK_METHOD_KEY, r.CheckMethod},
+		data{CHECK_INTERVAL_KEY, r.CheckInterval},
+		data{CHECK_RISE_KEY, r.CheckRise},
+		data{CHECK_FALL_KEY, r.CheckFall},
+		data{CHECK_TIMEOUT_KEY, r.CheckTimeout},
+		data{HTTP_CHECK_EXPECT_KEY, r.HttpCheckExpect},
+		data{BACKUP_HOSTNAME_KEY, r.BackupHostname},
+		data{BACKUP_PORT_KEY, r.BackupPort},
+		data{WEIGHT_KEY, r.Weight},
+		data{COLOR_WEIGHTS_KEY, FormatColorAddresses(r.ColorWeights)},
+		data{MAX_CONN_KEY, r.MaxConn},
+		data{RETRIES_KEY, r.Retries},
+		data{REDISPATCH_KEY, fmt.Sprintf("%t", r.Redispatch)},
+		data{REDIRECT_WHEN_HTTP_PROTO_KEY, fmt.Sprintf("%t", r.RedirectWhenHttpProto)},
 	}
 	for _, e := range d {
 		go m.SendPutRequest(addresses, r.ServiceName, e.key, e.value, instanceName, consulChannel)
@@ -55,20 +136,43 @@ func (m Consul) PutService(addresses []string, instanceName string, r Registry)
 	return nil
 }
 
+// FormatColorAddresses converts color (or datacenter) addresses into a comma-separated list of color=address pairs.
+// Pairs are sorted by color so that the stored value is deterministic.
+func FormatColorAddresses(colorAddresses map[string]string) string {
+	pairs := []string{}
+	for color, address := range colorAddresses {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", color, address))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ParseColorAddresses is the inverse of FormatColorAddresses.
+func ParseColorAddresses(value string) map[string]string {
+	var colorAddresses map[string]string
+	for _, pair := range strings.Split(value, ",") {
+		colorAddress := strings.SplitN(pair, "=", 2)
+		if len(colorAddress) == 2 && len(colorAddress[0]) > 0 && len(colorAddress[1]) > 0 {
+			if colorAddresses == nil {
+				colorAddresses = map[string]string{}
+			}
+			colorAddresses[colorAddress[0]] = colorAddress[1]
+		}
+	}
+	return colorAddresses
+}
+
 func (m Consul) SendPutRequest(addresses []string, serviceName, key, value, instanceName string, c chan error) {
 	c <- m.sendRequest("PUT", addresses, serviceName, key, value, instanceName)
 }
 
 func (m Consul) DeleteService(addresses []string, serviceName, instanceName string) error {
 	var err error
-	for _, address := range addresses {
-		if !strings.HasPrefix(address, "http") {
-			address = fmt.Sprintf("http://%s", address)
-		}
-		url := fmt.Sprintf("%s/v1/kv/%s/%s?recurse", address, instanceName, serviceName)
-		client := &http.Client{}
+	for _, address := range OrderConsulAddresses(addresses) {
+		url := fmt.Sprintf("%s/v1/kv/%s/%s?recurse", getConsulUrl(address), instanceName, serviceName)
 		request, _ := http.NewRequest("DELETE", url, nil)
-		_, err = client.Do(request)
+		_, err = getConsulClient().Do(request)
+		MarkConsulAddress(address, err)
 		if err == nil {
 			return nil
 		}
@@ -77,7 +181,11 @@ func (m Consul) DeleteService(addresses []string, serviceName, instanceName stri
 }
 
 func (m Consul) CreateConfigs(args *CreateConfigsArgs) error {
-	if err := m.createConfig(args.Addresses, args.TemplatesPath, args.FeFile, args.FeTemplate, args.ServiceName, "fe"); err != nil {
+	feType := "fe"
+	if args.InternalOnly {
+		feType = "internal-fe"
+	}
+	if err := m.createConfig(args.Addresses, args.TemplatesPath, args.FeFile, args.FeTemplate, args.ServiceName, feType); err != nil {
 		return err
 	}
 	if err := m.createConfig(
@@ -95,9 +203,10 @@ func (m Consul) CreateConfigs(args *CreateConfigsArgs) error {
 
 func (m Consul) GetServiceAttribute(addresses []string, serviceName, key, instanceName string) (string, error) {
 	var err error
-	for _, address := range addresses {
+	for _, address := range OrderConsulAddresses(addresses) {
 		url := fmt.Sprintf("%s/v1/kv/%s/%s/%s?raw", address, instanceName, serviceName, key)
-		resp, err := http.Get(url)
+		resp, err := getConsulClient().Get(url)
+		MarkConsulAddress(address, err)
 		if err == nil && resp.StatusCode == http.StatusOK {
 			defer resp.Body.Close()
 			body, _ := ioutil.ReadAll(resp.Body)
@@ -112,8 +221,10 @@ func (m Consul) createConfig(addresses []string, templatesPath, file, template,
 	WriteConsulTemplateFile(src, []byte(template), 0664)
 	dest := fmt.Sprintf("%s/%s-%s", templatesPath, serviceName, confType)
 	var err error
-	for _, address := range addresses {
-		if err = m.runConsulTemplateCmd(src, dest, address); err == nil {
+	for _, address := range OrderConsulAddresses(addresses) {
+		err = m.runConsulTemplateCmd(src, dest, address)
+		MarkConsulAddress(address, err)
+		if err == nil {
 			return nil
 		}
 	}
@@ -122,14 +233,11 @@ func (m Consul) createConfig(addresses []string, templatesPath, file, template,
 
 func (m Consul) sendRequest(requestType string, addresses []string, serviceName, key, value, instanceName string) error {
 	var err error
-	for _, address := range addresses {
-		if !strings.HasPrefix(address, "http") {
-			address = fmt.Sprintf("http://%s", address)
-		}
-		url := fmt.Sprintf("%s/v1/kv/%s/%s/%s", address, instanceName, serviceName, key)
-		client := &http.Client{}
+	for _, address := range OrderConsulAddresses(addresses) {
+		url := fmt.Sprintf("%s/v1/kv/%s/%s/%s", getConsulUrl(address), instanceName, serviceName, key)
 		request, _ := http.NewRequest(requestType, url, strings.NewReader(value))
-		_, err = client.Do(request)
+		_, err = getConsulClient().Do(request)
+		MarkConsulAddress(address, err)
 		if err == nil {
 			return nil
 		}
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -87,6 +88,86 @@ func (s *ConsulTestSuite) Test_PutService_PutsDataToConsul() {
 		data{"consultemplatefepath", s.registry.ConsulTemplateFePath},
 		data{"consultemplatebepath", s.registry.ConsulTemplateBePath},
 		data{"port", s.registry.Port},
+		data{"address", s.registry.ServiceAddress},
+		data{"coloraddresses", FormatColorAddresses(s.registry.ColorAddresses)},
+		data{"dcaddresses", FormatColorAddresses(s.registry.DcAddresses)},
+		data{"corsorigins", strings.Join(s.registry.CorsOrigins, ",")},
+		data{"corsmethods", strings.Join(s.registry.CorsMethods, ",")},
+		data{"corsheaders", strings.Join(s.registry.CorsHeaders, ",")},
+		data{"internalonly", fmt.Sprintf("%t", s.registry.InternalOnly)},
+		data{"checkgrpc", fmt.Sprintf("%t", s.registry.CheckGrpc)},
+		data{"profile", s.registry.Profile},
+		data{"explicitparameters", strings.Join(s.registry.ExplicitParameters, ",")},
+		data{"tracingsamplerate", s.registry.TracingSampleRate},
+		data{"canaryheader", s.registry.CanaryHeader},
+		data{"servicedescription", s.registry.ServiceDescription},
+		data{"owner", s.registry.Owner},
+		data{"servicedisplayname", s.registry.ServiceDisplayName},
+		data{"timeoutserver", strconv.Itoa(s.registry.TimeoutServer)},
+		data{"timeouttunnel", strconv.Itoa(s.registry.TimeoutTunnel)},
+		data{"timeouthttprequest", strconv.Itoa(s.registry.TimeoutHttpRequest)},
+		data{"timeoutqueue", strconv.Itoa(s.registry.TimeoutQueue)},
+		data{"timeoutconnect", strconv.Itoa(s.registry.TimeoutConnect)},
+		data{"timeoutclient", strconv.Itoa(s.registry.TimeoutClient)},
+		data{"stackname", s.registry.StackName},
+		data{"allowmissinghost", fmt.Sprintf("%t", s.registry.AllowMissingHost)},
+		data{"usedomainmap", fmt.Sprintf("%t", s.registry.UseDomainMap)},
+		data{"letsencrypt", fmt.Sprintf("%t", s.registry.LetsEncrypt)},
+		data{"proxyrole", s.registry.ProxyRole},
+		data{"clientcertverify", s.registry.ClientCertVerify},
+		data{"clientcertcafile", s.registry.ClientCertCaFile},
+		data{"tcpdestinations", s.registry.TcpDestinations},
+		data{"httpdestinations", s.registry.HttpDestinations},
+		data{"maintenance", s.registry.Maintenance},
+		data{"maintenancewindow", s.registry.MaintenanceWindow},
+		data{"certname", s.registry.CertName},
+		data{"httpreuse", s.registry.HttpReuse},
+		data{"poolmaxconn", s.registry.PoolMaxConn},
+		data{"poolpurgedelay", s.registry.PoolPurgeDelay},
+		data{"postreloadhook", s.registry.PostReloadHook},
+		data{"srcport", s.registry.SrcPort},
+		data{"httpsonly", fmt.Sprintf("%t", s.registry.HttpsOnly)},
+		data{"httpsport", s.registry.HttpsPort},
+		data{"sslverifynone", fmt.Sprintf("%t", s.registry.SslVerifyNone)},
+		data{"sslcacert", s.registry.SslCaCert},
+		data{"sessiontype", s.registry.SessionType},
+		data{"cookiename", s.registry.CookieName},
+		data{"balance", s.registry.Balance},
+		data{"connectionmode", s.registry.ConnectionMode},
+		data{"reqmode", s.registry.ReqMode},
+		data{"servicedomainmatchall", fmt.Sprintf("%t", s.registry.ServiceDomainMatchAll)},
+		data{"redirectfromdomain", strings.Join(s.registry.RedirectFromDomain, ",")},
+		data{"allowedmethods", strings.Join(s.registry.AllowedMethods, ",")},
+		data{"deniedmethods", strings.Join(s.registry.DeniedMethods, ",")},
+		data{"denyhttp", fmt.Sprintf("%t", s.registry.DenyHttp)},
+		data{"addreqheader", s.registry.AddReqHeader},
+		data{"setreqheader", s.registry.SetReqHeader},
+		data{"delreqheader", s.registry.DelReqHeader},
+		data{"addresheader", s.registry.AddResHeader},
+		data{"delresheader", s.registry.DelResHeader},
+		data{"forwardedproto", s.registry.ForwardedProto},
+		data{"compressionalgo", strings.Join(s.registry.CompressionAlgo, ",")},
+		data{"compressiontype", strings.Join(s.registry.CompressionType, ",")},
+		data{"reqratelimit", s.registry.ReqRateLimit},
+		data{"reqrateperiod", strconv.Itoa(s.registry.ReqRatePeriod)},
+		data{"allowedsourceips", strings.Join(s.registry.AllowedSourceIPs, ",")},
+		data{"backendextra", s.registry.BackendExtra},
+		data{"frontendextra", s.registry.FrontendExtra},
+		data{"checkpath", s.registry.CheckPath},
+		data{"checkmethod", s.registry.CheckMethod},
+		data{"checkinterval", s.registry.CheckInterval},
+		data{"checkrise", s.registry.CheckRise},
+		data{"checkfall", s.registry.CheckFall},
+		data{"checktimeout", s.registry.CheckTimeout},
+		data{"httpcheckexpect", s.registry.HttpCheckExpect},
+		data{"backuphostname", s.registry.BackupHostname},
+		data{"backupport", s.registry.BackupPort},
+		data{"weight", s.registry.Weight},
+		data{"colorweights", FormatColorAddresses(s.registry.ColorWeights)},
+		data{"maxconn", s.registry.MaxConn},
+		data{"retries", s.registry.Retries},
+		data{"redispatch", fmt.Sprintf("%t", s.registry.Redispatch)},
+		data{"redirectwhenhttpproto", fmt.Sprintf("%t", s.registry.RedirectWhenHttpProto)},
 	}
 	for _, e := range d {
 		s.Contains(actualUrl, fmt.Sprintf("/v1/kv/%s/%s/%s", instanceName, s.registry.ServiceName, e.key))
@@ -95,6 +176,28 @@ func (s *ConsulTestSuite) Test_PutService_PutsDataToConsul() {
 	}
 }
 
+// FormatColorAddresses
+
+func (s *ConsulTestSuite) Test_FormatColorAddresses_ReturnsSortedPairs() {
+	actual := FormatColorAddresses(map[string]string{"green": "10.0.0.3", "blue": "10.0.0.2"})
+
+	s.Equal("blue=10.0.0.2,green=10.0.0.3", actual)
+}
+
+// ParseColorAddresses
+
+func (s *ConsulTestSuite) Test_ParseColorAddresses_ReturnsMap() {
+	actual := ParseColorAddresses("blue=10.0.0.2,green=10.0.0.3")
+
+	s.Equal(map[string]string{"blue": "10.0.0.2", "green": "10.0.0.3"}, actual)
+}
+
+func (s *ConsulTestSuite) Test_ParseColorAddresses_ReturnsNil_WhenValueIsEmpty() {
+	actual := ParseColorAddresses("")
+
+	s.Nil(actual)
+}
+
 func (s *ConsulTestSuite) Test_PutService_ReturnsError_WhenFailure() {
 	err := Consul{}.PutService([]string{"http:///THIS/URL/DOES/NOT/EXIST"}, "my-instance", s.registry)
 
@@ -331,6 +434,26 @@ func (s *ConsulTestSuite) Test_CreateConfigs_RunsConsulTemplate() {
 	s.Equal(expectedBe, actual[1])
 }
 
+func (s *ConsulTestSuite) Test_CreateConfigs_RunsConsulTemplateWithInternalFeDestination_WhenInternalOnly() {
+	var actual [][]string
+	cmdRunConsulTemplate = func(cmd *exec.Cmd) error {
+		actual = append(actual, cmd.Args)
+		return nil
+	}
+	s.createConfigsArgs.InternalOnly = true
+	expected := fmt.Sprintf(
+		`%s/%s:%s/%s-internal-fe.cfg`,
+		s.templatesPath,
+		s.feTemplateName,
+		s.templatesPath,
+		s.serviceName,
+	)
+
+	Consul{}.CreateConfigs(&s.createConfigsArgs)
+
+	s.Equal(expected, actual[0][4])
+}
+
 func (s *ConsulTestSuite) Test_CreateConfigs_CreatesConsulTemplate() {
 	var actual string
 	WriteConsulTemplateFile = func(filename string, data []byte, perm os.FileMode) error {
@@ -478,6 +601,16 @@ func TestConsulUnitTestSuite(t *testing.T) {
 		SkipCheck:            true,
 		ConsulTemplateFePath: "ConsulTemplateFePath",
 		ConsulTemplateBePath: "ConsulTemplateBePath",
+		CorsOrigins:          []string{"https://my-domain.com", "https://other-domain.com"},
+		CorsMethods:          []string{"GET", "POST"},
+		CorsHeaders:          []string{"Content-Type"},
+		Profile:              "public-api",
+		ServiceDescription:   "Serves the demo API",
+		Owner:                "team-demo",
+		ServiceDisplayName:   "My-Service",
+		TimeoutServer:        90,
+		TimeoutTunnel:        3600,
+		ExplicitParameters:   []string{"serviceName", "servicePath"},
 	}
 	suite.Run(t, s)
 }
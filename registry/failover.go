@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConsulTimeout limits each request to a Consul address so that a failed address does not block failover.
+var ConsulTimeout = 5 * time.Second
+
+// AddressStatus describes the health of a single Consul address.
+type AddressStatus struct {
+	Address string
+	Healthy bool
+	Errors  int64
+}
+
+// ConsulStatus describes the address used for Consul requests and the health of all the addresses.
+type ConsulStatus struct {
+	Active    string
+	Addresses []AddressStatus
+}
+
+// consulHealth remembers the last known good address and the addresses that failed.
+var consulHealth = struct {
+	sync.Mutex
+	active string
+	down   map[string]bool
+	errors map[string]int64
+}{down: map[string]bool{}, errors: map[string]int64{}}
+
+var consulProbeOnce = &sync.Once{}
+
+// OrderConsulAddresses returns the last known good address first, followed by the healthy addresses and the ones
+// that failed. The original order is kept otherwise.
+func OrderConsulAddresses(addresses []string) []string {
+	consulHealth.Lock()
+	defer consulHealth.Unlock()
+	ordered := append([]string{}, addresses...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return addressRank(ordered[i]) < addressRank(ordered[j])
+	})
+	return ordered
+}
+
+func addressRank(address string) int {
+	if address == consulHealth.active {
+		return 0
+	} else if consulHealth.down[address] {
+		return 2
+	}
+	return 1
+}
+
+// MarkConsulAddress records the outcome of a request. Failed addresses are tried last until they recover.
+func MarkConsulAddress(address string, err error) {
+	consulHealth.Lock()
+	defer consulHealth.Unlock()
+	if err == nil {
+		consulHealth.active = address
+		delete(consulHealth.down, address)
+		return
+	}
+	consulHealth.errors[address]++
+	consulHealth.down[address] = true
+	if consulHealth.active == address {
+		consulHealth.active = ""
+	}
+}
+
+// GetConsulStatus returns the health of the addresses.
+func GetConsulStatus(addresses []string) ConsulStatus {
+	consulHealth.Lock()
+	defer consulHealth.Unlock()
+	status := ConsulStatus{Active: consulHealth.active, Addresses: []AddressStatus{}}
+	for _, address := range addresses {
+		status.Addresses = append(status.Addresses, AddressStatus{
+			Address: address,
+			Healthy: !consulHealth.down[address],
+			Errors:  consulHealth.errors[address],
+		})
+	}
+	return status
+}
+
+// StartConsulProbe periodically checks the failed addresses in the background and marks the recovered ones as
+// healthy. The probe is started only once.
+func StartConsulProbe(addresses []string, interval time.Duration) {
+	consulProbeOnce.Do(func() {
+		go func() {
+			for range time.Tick(interval) {
+				probeConsulAddresses(addresses)
+			}
+		}()
+	})
+}
+
+func probeConsulAddresses(addresses []string) {
+	for _, address := range addresses {
+		consulHealth.Lock()
+		down := consulHealth.down[address]
+		consulHealth.Unlock()
+		if !down {
+			continue
+		}
+		resp, err := getConsulClient().Get(fmt.Sprintf("%s/v1/status/leader", getConsulUrl(address)))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				consulHealth.Lock()
+				delete(consulHealth.down, address)
+				consulHealth.Unlock()
+			}
+		}
+	}
+}
+
+func getConsulClient() *http.Client {
+	return &http.Client{Timeout: ConsulTimeout}
+}
+
+func getConsulUrl(address string) string {
+	if !strings.HasPrefix(address, "http") {
+		return fmt.Sprintf("http://%s", address)
+	}
+	return address
+}
@@ -0,0 +1,144 @@
+// +build !integration
+
+package registry
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type FailoverTestSuite struct {
+	suite.Suite
+}
+
+func (s *FailoverTestSuite) SetupTest() {
+	consulHealth.active = ""
+	consulHealth.down = map[string]bool{}
+	consulHealth.errors = map[string]int64{}
+	consulProbeOnce = &sync.Once{}
+}
+
+// getHangingServer simulates an address that is down by never responding within ConsulTimeout.
+func (s FailoverTestSuite) getHangingServer(requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		time.Sleep(ConsulTimeout + 100*time.Millisecond)
+	}))
+}
+
+// OrderConsulAddresses
+
+func (s FailoverTestSuite) Test_OrderConsulAddresses_ReturnsActiveFirstAndFailedLast() {
+	MarkConsulAddress("consul-1", fmt.Errorf("This is an error"))
+	MarkConsulAddress("consul-3", nil)
+
+	actual := OrderConsulAddresses([]string{"consul-1", "consul-2", "consul-3"})
+
+	s.Equal([]string{"consul-3", "consul-2", "consul-1"}, actual)
+}
+
+func (s FailoverTestSuite) Test_OrderConsulAddresses_KeepsTheOrder_WhenNothingIsKnown() {
+	actual := OrderConsulAddresses([]string{"consul-1", "consul-2"})
+
+	s.Equal([]string{"consul-1", "consul-2"}, actual)
+}
+
+// GetServiceAttribute
+
+func (s FailoverTestSuite) Test_GetServiceAttribute_UsesTheSecondAddressQuickly_WhenTheFirstOneIsDown() {
+	timeoutOrig := ConsulTimeout
+	defer func() { ConsulTimeout = timeoutOrig }()
+	ConsulTimeout = 200 * time.Millisecond
+	var downRequests int32
+	down := s.getHangingServer(&downRequests)
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("my-value"))
+	}))
+	defer up.Close()
+	addresses := []string{down.URL, up.URL}
+
+	actual, err := Consul{}.GetServiceAttribute(addresses, "my-service", "path", "docker-flow")
+	s.NoError(err)
+	s.Equal("my-value", actual)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		actual, err = Consul{}.GetServiceAttribute(addresses, "my-service", "path", "docker-flow")
+		s.NoError(err)
+		s.Equal("my-value", actual)
+	}
+
+	s.True(time.Since(start) < ConsulTimeout, "Requests should not wait for the failed address")
+	s.Equal(int32(1), atomic.LoadInt32(&downRequests))
+	status := GetConsulStatus(addresses)
+	s.Equal(up.URL, status.Active)
+	s.Equal([]AddressStatus{{Address: down.URL, Healthy: false, Errors: 1}, {Address: up.URL, Healthy: true}}, status.Addresses)
+}
+
+// PutService
+
+func (s FailoverTestSuite) Test_SendPutRequest_RotatesToTheNextAddress_WhenTheActiveOneFails() {
+	requests := map[string]int{}
+	mu := &sync.Mutex{}
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			requests[name]++
+		}
+	}
+	first := httptest.NewServer(handler("first"))
+	second := httptest.NewServer(handler("second"))
+	defer second.Close()
+	addresses := []string{first.URL, second.URL}
+	c := make(chan error)
+
+	go Consul{}.SendPutRequest(addresses, "my-service", "path", "/demo", "docker-flow", c)
+	s.NoError(<-c)
+	first.Close()
+	go Consul{}.SendPutRequest(addresses, "my-service", "path", "/demo", "docker-flow", c)
+	s.NoError(<-c)
+	go Consul{}.SendPutRequest(addresses, "my-service", "path", "/demo", "docker-flow", c)
+	s.NoError(<-c)
+
+	s.Equal(map[string]int{"first": 1, "second": 2}, requests)
+	s.Equal(second.URL, GetConsulStatus(addresses).Active)
+}
+
+// StartConsulProbe
+
+func (s FailoverTestSuite) Test_ProbeConsulAddresses_MarksRecoveredAddressesAsHealthy() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("/v1/status/leader", r.URL.Path)
+		w.Write([]byte(`"10.0.0.1:8300"`))
+	}))
+	defer srv.Close()
+	MarkConsulAddress(srv.URL, fmt.Errorf("This is an error"))
+	MarkConsulAddress("http://consul-2:8500", nil)
+
+	probeConsulAddresses([]string{srv.URL, "http://consul-2:8500"})
+
+	status := GetConsulStatus([]string{srv.URL})
+	s.True(status.Addresses[0].Healthy)
+	s.Equal("http://consul-2:8500", status.Active)
+}
+
+func (s FailoverTestSuite) Test_ProbeConsulAddresses_KeepsAddressesDown_WhenTheyDoNotRespond() {
+	MarkConsulAddress("http://127.0.0.1:1", fmt.Errorf("This is an error"))
+
+	probeConsulAddresses([]string{"http://127.0.0.1:1"})
+
+	s.False(GetConsulStatus([]string{"http://127.0.0.1:1"}).Addresses[0].Healthy)
+}
+
+// Suite
+
+func TestFailoverUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(FailoverTestSuite))
+}
@@ -0,0 +1,195 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsBuckets are the upper bounds, in seconds, of the buckets of the duration histograms.
+var MetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// OperationMetrics counts operations, their failures and retries and records their durations per operation and
+// address. The metrics are written in the Prometheus text format with the names prefixed by Prefix.
+type OperationMetrics struct {
+	Prefix  string
+	Subject string
+	mu      sync.Mutex
+	entries map[operationKey]*operationEntry
+}
+
+type operationKey struct {
+	operation string
+	address   string
+}
+
+type operationEntry struct {
+	count    int64
+	failures int64
+	retries  int64
+	buckets  []int64
+	sum      float64
+}
+
+// NewOperationMetrics returns metrics named after the prefix. The subject describes the operations in the help texts.
+func NewOperationMetrics(prefix, subject string) *OperationMetrics {
+	return &OperationMetrics{Prefix: prefix, Subject: subject, entries: map[operationKey]*operationEntry{}}
+}
+
+// RegistryMetrics are the metrics of the operations performed through the registry returned by NewMetricsRegistry.
+var RegistryMetrics = NewOperationMetrics("docker_flow_proxy_registry", "registry")
+
+// Observe records an operation sent to the address. Retried operations succeeded only after a failed attempt.
+func (m *OperationMetrics) Observe(operation, address string, duration time.Duration, err error, retried bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := operationKey{operation, address}
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = &operationEntry{buckets: make([]int64, len(MetricsBuckets))}
+		m.entries[key] = entry
+	}
+	entry.count++
+	if err != nil {
+		entry.failures++
+	}
+	if retried {
+		entry.retries++
+	}
+	seconds := duration.Seconds()
+	entry.sum += seconds
+	for i, bound := range MetricsBuckets {
+		if seconds <= bound {
+			entry.buckets[i]++
+		}
+	}
+}
+
+// String returns the metrics in the Prometheus text format. Series are sorted by their labels.
+func (m *OperationMetrics) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []operationKey{}
+	for key := range m.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operation == keys[j].operation {
+			return keys[i].address < keys[j].address
+		}
+		return keys[i].operation < keys[j].operation
+	})
+	out := ""
+	counters := []struct {
+		suffix string
+		help   string
+		value  func(e *operationEntry) int64
+	}{
+		{"operations_total", "Number of %s operations.", func(e *operationEntry) int64 { return e.count }},
+		{"operation_failures_total", "Number of failed %s operations.", func(e *operationEntry) int64 { return e.failures }},
+		{"operation_retries_total", "Number of %s operations that succeeded only after a failed attempt.", func(e *operationEntry) int64 { return e.retries }},
+	}
+	for _, counter := range counters {
+		name := m.Prefix + "_" + counter.suffix
+		out += fmt.Sprintf("# HELP %s %s\n# TYPE %s counter\n", name, fmt.Sprintf(counter.help, m.Subject), name)
+		for _, key := range keys {
+			out += fmt.Sprintf("%s{%s} %d\n", name, key.labels(), counter.value(m.entries[key]))
+		}
+	}
+	name := m.Prefix + "_operation_duration_seconds"
+	out += fmt.Sprintf("# HELP %s Duration of the %s operations.\n# TYPE %s histogram\n", name, m.Subject, name)
+	for _, key := range keys {
+		entry := m.entries[key]
+		for i, bound := range MetricsBuckets {
+			le := strconv.FormatFloat(bound, 'f', -1, 64)
+			out += fmt.Sprintf("%s_bucket{%s,le=\"%s\"} %d\n", name, key.labels(), le, entry.buckets[i])
+		}
+		out += fmt.Sprintf("%s_bucket{%s,le=\"+Inf\"} %d\n", name, key.labels(), entry.count)
+		out += fmt.Sprintf("%s_sum{%s} %s\n", name, key.labels(), strconv.FormatFloat(entry.sum, 'f', -1, 64))
+		out += fmt.Sprintf("%s_count{%s} %d\n", name, key.labels(), entry.count)
+	}
+	return out
+}
+
+// Reset removes all the recorded operations.
+func (m *OperationMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = map[operationKey]*operationEntry{}
+}
+
+func (k operationKey) labels() string {
+	return fmt.Sprintf(`operation="%s",address="%s"`, escapeLabel(k.operation), escapeLabel(k.address))
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// metricsRegistry records RegistryMetrics for the operations of the registry it wraps. Operations are labeled with
+// the address they are sent to first. An operation is retried if it succeeded on another address.
+type metricsRegistry struct {
+	Registrarable
+}
+
+// NewMetricsRegistry wraps the registry so that its operations are recorded in RegistryMetrics.
+func NewMetricsRegistry(r Registrarable) Registrarable {
+	return metricsRegistry{r}
+}
+
+// metricsNow is the clock the durations of the operations are measured with.
+var metricsNow = time.Now
+
+func (m metricsRegistry) observe(operation string, addresses []string, f func() error) error {
+	address := ""
+	if ordered := OrderConsulAddresses(addresses); len(ordered) > 0 {
+		address = ordered[0]
+	}
+	start := metricsNow()
+	err := f()
+	retried := false
+	if err == nil && len(addresses) > 1 {
+		retried = GetConsulStatus(addresses).Active != address
+	}
+	RegistryMetrics.Observe(operation, address, metricsNow().Sub(start), err, retried)
+	return err
+}
+
+func (m metricsRegistry) PutService(addresses []string, instanceName string, r Registry) error {
+	return m.observe("put", addresses, func() error {
+		return m.Registrarable.PutService(addresses, instanceName, r)
+	})
+}
+
+func (m metricsRegistry) SendPutRequest(addresses []string, serviceName, key, value, instanceName string, c chan error) {
+	c <- m.observe("put", addresses, func() error {
+		inner := make(chan error, 1)
+		m.Registrarable.SendPutRequest(addresses, serviceName, key, value, instanceName, inner)
+		return <-inner
+	})
+}
+
+func (m metricsRegistry) DeleteService(addresses []string, serviceName, instanceName string) error {
+	return m.observe("delete", addresses, func() error {
+		return m.Registrarable.DeleteService(addresses, serviceName, instanceName)
+	})
+}
+
+func (m metricsRegistry) CreateConfigs(args *CreateConfigsArgs) error {
+	return m.observe("create_configs", args.Addresses, func() error {
+		return m.Registrarable.CreateConfigs(args)
+	})
+}
+
+func (m metricsRegistry) GetServiceAttribute(addresses []string, serviceName, key, instanceName string) (string, error) {
+	value := ""
+	err := m.observe("get", addresses, func() error {
+		var err error
+		value, err = m.Registrarable.GetServiceAttribute(addresses, serviceName, key, instanceName)
+		return err
+	})
+	return value, err
+}
@@ -0,0 +1,143 @@
+// +build !integration
+
+package registry
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (s *MetricsTestSuite) SetupTest() {
+	consulHealth.active = ""
+	consulHealth.down = map[string]bool{}
+	consulHealth.errors = map[string]int64{}
+	consulProbeOnce = &sync.Once{}
+	RegistryMetrics.Reset()
+	s.now = time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	metricsNow = func() time.Time {
+		// Every operation takes 15ms
+		s.now = s.now.Add(15 * time.Millisecond)
+		return s.now
+	}
+}
+
+func (s *MetricsTestSuite) TearDownTest() {
+	metricsNow = time.Now
+}
+
+// fakeRegistry fails the operations on the addresses in failing and succeeds on the first other address.
+type fakeRegistry struct {
+	failing map[string]bool
+}
+
+func (m fakeRegistry) do(addresses []string) error {
+	for _, address := range OrderConsulAddresses(addresses) {
+		if m.failing[address] {
+			MarkConsulAddress(address, fmt.Errorf("%s is down", address))
+			continue
+		}
+		MarkConsulAddress(address, nil)
+		return nil
+	}
+	return fmt.Errorf("All the addresses are down")
+}
+
+func (m fakeRegistry) PutService(addresses []string, instanceName string, r Registry) error {
+	return m.do(addresses)
+}
+
+func (m fakeRegistry) SendPutRequest(addresses []string, serviceName, key, value, instanceName string, c chan error) {
+	c <- m.do(addresses)
+}
+
+func (m fakeRegistry) DeleteService(addresses []string, serviceName, instanceName string) error {
+	return m.do(addresses)
+}
+
+func (m fakeRegistry) CreateConfigs(args *CreateConfigsArgs) error {
+	return m.do(args.Addresses)
+}
+
+func (m fakeRegistry) GetServiceAttribute(addresses []string, serviceName, key, instanceName string) (string, error) {
+	return "value", m.do(addresses)
+}
+
+// NewMetricsRegistry
+
+func (s *MetricsTestSuite) Test_NewMetricsRegistry_CountsOperationsPerAddress() {
+	r := NewMetricsRegistry(fakeRegistry{failing: map[string]bool{}})
+	addresses := []string{"consul-1"}
+	c := make(chan error)
+
+	r.PutService(addresses, "proxy", Registry{})
+	go r.SendPutRequest(addresses, "my-service", "key", "value", "proxy", c)
+	<-c
+	value, _ := r.GetServiceAttribute(addresses, "my-service", "key", "proxy")
+	r.DeleteService(addresses, "my-service", "proxy")
+	r.CreateConfigs(&CreateConfigsArgs{Addresses: addresses})
+	actual := RegistryMetrics.String()
+
+	s.Equal("value", value)
+	for _, expected := range []string{
+		"# TYPE docker_flow_proxy_registry_operations_total counter",
+		`docker_flow_proxy_registry_operations_total{operation="put",address="consul-1"} 2`,
+		`docker_flow_proxy_registry_operations_total{operation="get",address="consul-1"} 1`,
+		`docker_flow_proxy_registry_operations_total{operation="delete",address="consul-1"} 1`,
+		`docker_flow_proxy_registry_operations_total{operation="create_configs",address="consul-1"} 1`,
+		`docker_flow_proxy_registry_operation_failures_total{operation="put",address="consul-1"} 0`,
+		"# TYPE docker_flow_proxy_registry_operation_duration_seconds histogram",
+		`docker_flow_proxy_registry_operation_duration_seconds_bucket{operation="put",address="consul-1",le="0.01"} 0`,
+		`docker_flow_proxy_registry_operation_duration_seconds_bucket{operation="put",address="consul-1",le="0.025"} 2`,
+		`docker_flow_proxy_registry_operation_duration_seconds_bucket{operation="put",address="consul-1",le="+Inf"} 2`,
+		`docker_flow_proxy_registry_operation_duration_seconds_sum{operation="put",address="consul-1"} 0.03`,
+		`docker_flow_proxy_registry_operation_duration_seconds_count{operation="put",address="consul-1"} 2`,
+	} {
+		s.Contains(actual, expected+"\n")
+	}
+}
+
+func (s *MetricsTestSuite) Test_NewMetricsRegistry_CountsFailuresAndRetries() {
+	r := NewMetricsRegistry(fakeRegistry{failing: map[string]bool{"consul-1": true}})
+
+	// The first request fails over to consul-2 which is tried first afterwards
+	r.PutService([]string{"consul-1", "consul-2"}, "proxy", Registry{})
+	r.PutService([]string{"consul-1", "consul-2"}, "proxy", Registry{})
+	r.DeleteService([]string{"consul-1"}, "my-service", "proxy")
+	actual := RegistryMetrics.String()
+
+	for _, expected := range []string{
+		`docker_flow_proxy_registry_operations_total{operation="put",address="consul-1"} 1`,
+		`docker_flow_proxy_registry_operation_retries_total{operation="put",address="consul-1"} 1`,
+		`docker_flow_proxy_registry_operation_failures_total{operation="put",address="consul-1"} 0`,
+		`docker_flow_proxy_registry_operations_total{operation="put",address="consul-2"} 1`,
+		`docker_flow_proxy_registry_operation_retries_total{operation="put",address="consul-2"} 0`,
+		`docker_flow_proxy_registry_operation_failures_total{operation="delete",address="consul-1"} 1`,
+	} {
+		s.Contains(actual, expected+"\n")
+	}
+}
+
+// String
+
+func (s *MetricsTestSuite) Test_String_EscapesLabels() {
+	metrics := NewOperationMetrics("my_prefix", "my")
+
+	metrics.Observe("get", `http://"consul"`, time.Millisecond, nil, false)
+
+	s.True(strings.Contains(metrics.String(), `my_prefix_operations_total{operation="get",address="http://\"consul\""} 1`))
+}
+
+// Suite
+
+func TestMetricsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
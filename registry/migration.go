@@ -0,0 +1,261 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// LegacyLayout describes the way services were stored in Consul by previous versions of the proxy.
+type LegacyLayout struct {
+	// Prefix of the service keys. Services are stored as <Prefix>/<serviceName>/<field>.
+	Prefix string
+	// Fields maps legacy field names to the current ones. Fields that are not listed keep their names.
+	Fields map[string]string
+	// Marker is a field stored only by this layout. If set, services without it are not migrated since the prefix
+	// is shared with the current layout (e.g. the default instance name).
+	Marker string
+}
+
+// LegacyLayouts are checked, in order, when the registry is migrated.
+var LegacyLayouts = []LegacyLayout{
+	{
+		Prefix: "docker-flow-proxy/services",
+		Fields: map[string]string{
+			"service_color":           COLOR_KEY,
+			"service_path":            PATH_KEY,
+			"service_domain":          DOMAIN_KEY,
+			"service_cert":            CERT_KEY,
+			"outbound_hostname":       HOSTNAME_KEY,
+			"path_type":               PATH_TYPE_KEY,
+			"skip_check":              SKIP_CHECK_KEY,
+			"consul_template_fe_path": CONSUL_TEMPLATE_FE_PATH_KEY,
+			"consul_template_be_path": CONSUL_TEMPLATE_BE_PATH_KEY,
+			"service_port":            PORT,
+		},
+	},
+	{
+		Prefix: "docker-flow",
+		Fields: map[string]string{
+			"consultemplatepath": CONSUL_TEMPLATE_FE_PATH_KEY,
+		},
+		Marker: "consultemplatepath",
+	},
+}
+
+// MigrationResult summarizes a registry migration.
+type MigrationResult struct {
+	Migrated []string
+	Failed   map[string]string
+}
+
+type kvEntry struct {
+	Key         string
+	Value       string
+	ModifyIndex uint64
+}
+
+// Migrate copies services stored in one of the LegacyLayouts to the current <instanceName>/<serviceName>/<key> layout.
+// The legacy keys are deleted only if cleanup is true and the service was migrated successfully.
+func (m Consul) Migrate(addresses []string, instanceName string, cleanup bool) (MigrationResult, error) {
+	result := MigrationResult{Migrated: []string{}, Failed: map[string]string{}}
+	for _, layout := range LegacyLayouts {
+		if layout.Prefix == instanceName {
+			continue
+		}
+		entries, err := m.getKvEntries(addresses, layout.Prefix)
+		if err != nil {
+			return result, err
+		}
+		services := m.groupLegacyEntries(layout, entries)
+		names := []string{}
+		for name := range services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			id := fmt.Sprintf("%s/%s", layout.Prefix, name)
+			if err := m.migrateService(addresses, instanceName, name, services[name]); err != nil {
+				result.Failed[id] = err.Error()
+				continue
+			}
+			if cleanup {
+				if err := m.DeleteService(addresses, name, layout.Prefix); err != nil {
+					result.Failed[id] = fmt.Sprintf("Migrated but the legacy keys could not be deleted\n%s", err.Error())
+					continue
+				}
+			}
+			result.Migrated = append(result.Migrated, id)
+		}
+	}
+	return result, nil
+}
+
+// groupLegacyEntries returns the fields of each service, already renamed to the current schema.
+func (m Consul) groupLegacyEntries(layout LegacyLayout, entries []kvEntry) map[string]map[string]string {
+	services := map[string]map[string]string{}
+	marked := map[string]bool{}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Key, layout.Prefix+"/") {
+			continue
+		}
+		parts := strings.Split(strings.TrimPrefix(entry.Key, layout.Prefix+"/"), "/")
+		// The service index (<prefix>/service/<serviceName>) is recreated when the service is migrated
+		if len(parts) != 2 || parts[0] == "service" || len(parts[1]) == 0 {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(entry.Value)
+		if err != nil {
+			continue
+		}
+		field := parts[1]
+		if field == layout.Marker {
+			marked[parts[0]] = true
+		}
+		if newField, ok := layout.Fields[field]; ok {
+			field = newField
+		}
+		if services[parts[0]] == nil {
+			services[parts[0]] = map[string]string{}
+		}
+		services[parts[0]][field] = string(value)
+	}
+	if len(layout.Marker) > 0 {
+		for name := range services {
+			if !marked[name] {
+				delete(services, name)
+			}
+		}
+	}
+	return services
+}
+
+func (m Consul) migrateService(addresses []string, instanceName, serviceName string, fields map[string]string) error {
+	if len(fields[PATH_KEY]) == 0 && len(fields[CONSUL_TEMPLATE_FE_PATH_KEY]) == 0 {
+		return fmt.Errorf("Neither the %s nor the %s key is present", PATH_KEY, CONSUL_TEMPLATE_FE_PATH_KEY)
+	}
+	for key, value := range fields {
+		if err := m.sendRequest("PUT", addresses, serviceName, key, value, instanceName); err != nil {
+			return err
+		}
+	}
+	return m.sendRequest("PUT", addresses, "service", serviceName, "swarm", instanceName)
+}
+
+// MigrateServiceNames moves services stored under names that are not lower case to their lower case names. If
+// multiple names differ only by case, the most recently modified service is kept and the others are deleted.
+// The original name of the kept service is stored as its display name.
+func (m Consul) MigrateServiceNames(addresses []string, instanceName string) (MigrationResult, error) {
+	result := MigrationResult{Migrated: []string{}, Failed: map[string]string{}}
+	entries, err := m.getKvEntries(addresses, instanceName)
+	if err != nil {
+		return result, err
+	}
+	services := map[string]map[string]string{}
+	modified := map[string]uint64{}
+	for _, entry := range entries {
+		parts := strings.Split(strings.TrimPrefix(entry.Key, instanceName+"/"), "/")
+		if len(parts) != 2 || parts[0] == "service" || len(parts[1]) == 0 {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(entry.Value)
+		if err != nil {
+			continue
+		}
+		if services[parts[0]] == nil {
+			services[parts[0]] = map[string]string{}
+		}
+		services[parts[0]][parts[1]] = string(value)
+		if entry.ModifyIndex > modified[parts[0]] {
+			modified[parts[0]] = entry.ModifyIndex
+		}
+	}
+	variants := map[string][]string{}
+	for name := range services {
+		lower := strings.ToLower(name)
+		variants[lower] = append(variants[lower], name)
+	}
+	lowerNames := []string{}
+	for lower := range variants {
+		sort.Strings(variants[lower])
+		lowerNames = append(lowerNames, lower)
+	}
+	sort.Strings(lowerNames)
+	for _, lower := range lowerNames {
+		if len(variants[lower]) == 1 && variants[lower][0] == lower {
+			continue
+		}
+		kept := variants[lower][0]
+		for _, name := range variants[lower] {
+			if modified[name] > modified[kept] {
+				kept = name
+			}
+		}
+		id := fmt.Sprintf("%s/%s", instanceName, kept)
+		if err := m.renameService(addresses, instanceName, kept, lower, variants[lower], services[kept]); err != nil {
+			result.Failed[id] = err.Error()
+			continue
+		}
+		result.Migrated = append(result.Migrated, id)
+	}
+	return result, nil
+}
+
+// renameService stores the fields under the new name and deletes all the names that differ from it.
+func (m Consul) renameService(addresses []string, instanceName, name, newName string, variants []string, fields map[string]string) error {
+	if name != newName {
+		if len(fields[SERVICE_DISPLAY_NAME_KEY]) == 0 {
+			fields[SERVICE_DISPLAY_NAME_KEY] = name
+		}
+		for key, value := range fields {
+			if err := m.sendRequest("PUT", addresses, newName, key, value, instanceName); err != nil {
+				return err
+			}
+		}
+		if err := m.sendRequest("PUT", addresses, "service", newName, "swarm", instanceName); err != nil {
+			return err
+		}
+	}
+	for _, variant := range variants {
+		if variant == newName {
+			continue
+		}
+		// The trailing slash prevents deleting services whose names start with the variant
+		if err := m.sendRequest("DELETE", addresses, variant, "?recurse", "", instanceName); err != nil {
+			return err
+		}
+		if err := m.sendRequest("DELETE", addresses, "service", variant, "", instanceName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m Consul) getKvEntries(addresses []string, prefix string) ([]kvEntry, error) {
+	var err error
+	for _, address := range addresses {
+		if !strings.HasPrefix(address, "http") {
+			address = fmt.Sprintf("http://%s", address)
+		}
+		var resp *http.Response
+		resp, err = http.Get(fmt.Sprintf("%s/v1/kv/%s/?recurse", address, prefix))
+		if err != nil {
+			continue
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return []kvEntry{}, nil
+		}
+		entries := []kvEntry{}
+		if err = json.Unmarshal(body, &entries); err != nil {
+			return nil, fmt.Errorf("Could not parse the keys under %s\n%s", prefix, err.Error())
+		}
+		return entries, nil
+	}
+	return nil, fmt.Errorf("Could not retrieve the keys under %s\n%s", prefix, err)
+}
@@ -0,0 +1,214 @@
+// +build !integration
+
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type MigrationTestSuite struct {
+	suite.Suite
+	kv      map[string]string
+	indexes map[string]uint64
+	mu      *sync.Mutex
+	server  *httptest.Server
+}
+
+func (s *MigrationTestSuite) SetupTest() {
+	s.kv = map[string]string{}
+	s.indexes = map[string]uint64{}
+	s.mu = &sync.Mutex{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		switch r.Method {
+		case "GET":
+			entries := []kvEntry{}
+			for k, v := range s.kv {
+				if strings.HasPrefix(k, key) {
+					entries = append(entries, kvEntry{Key: k, Value: base64.StdEncoding.EncodeToString([]byte(v)), ModifyIndex: s.indexes[k]})
+				}
+			}
+			if len(entries) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			js, _ := json.Marshal(entries)
+			w.Write(js)
+		case "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			s.kv[key] = string(body)
+		case "DELETE":
+			for k := range s.kv {
+				if strings.HasPrefix(k, key) {
+					delete(s.kv, k)
+				}
+			}
+		}
+	}))
+}
+
+func (s *MigrationTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// Migrate
+
+func (s *MigrationTestSuite) Test_Migrate_TranslatesServicesFromDockerFlowProxyServicesLayout() {
+	s.kv["docker-flow-proxy/services/go-demo/service_path"] = "/demo"
+	s.kv["docker-flow-proxy/services/go-demo/service_color"] = "blue"
+	s.kv["docker-flow-proxy/services/go-demo/service_port"] = "8080"
+	s.kv["docker-flow-proxy/services/go-demo/pathtype"] = "path_reg"
+
+	actual, err := Consul{}.Migrate([]string{s.server.URL}, "my-proxy", false)
+
+	s.NoError(err)
+	s.Equal([]string{"docker-flow-proxy/services/go-demo"}, actual.Migrated)
+	s.Equal("/demo", s.kv["my-proxy/go-demo/path"])
+	s.Equal("blue", s.kv["my-proxy/go-demo/color"])
+	s.Equal("8080", s.kv["my-proxy/go-demo/port"])
+	s.Equal("path_reg", s.kv["my-proxy/go-demo/pathtype"])
+	s.Equal("swarm", s.kv["my-proxy/service/go-demo"])
+	s.Equal("/demo", s.kv["docker-flow-proxy/services/go-demo/service_path"])
+}
+
+func (s *MigrationTestSuite) Test_Migrate_TranslatesServicesFromDockerFlowLayout() {
+	s.kv["docker-flow/service/go-demo"] = "swarm"
+	s.kv["docker-flow/go-demo/consultemplatepath"] = "/consul_templates/go-demo-fe.tmpl"
+	s.kv["docker-flow/go-demo/consultemplatebepath"] = "/consul_templates/go-demo-be.tmpl"
+	s.kv["docker-flow-other/go-demo/path"] = "/other"
+
+	actual, err := Consul{}.Migrate([]string{s.server.URL}, "my-proxy", false)
+
+	s.NoError(err)
+	s.Equal([]string{"docker-flow/go-demo"}, actual.Migrated)
+	s.Equal("/consul_templates/go-demo-fe.tmpl", s.kv["my-proxy/go-demo/consultemplatefepath"])
+	s.Equal("/consul_templates/go-demo-be.tmpl", s.kv["my-proxy/go-demo/consultemplatebepath"])
+	s.NotContains(s.kv, "my-proxy/go-demo/path")
+}
+
+func (s *MigrationTestSuite) Test_Migrate_SkipsLayoutWithTheSameNameAsTheInstance() {
+	s.kv["docker-flow/go-demo/path"] = "/demo"
+
+	actual, _ := Consul{}.Migrate([]string{s.server.URL}, "docker-flow", false)
+
+	s.Empty(actual.Migrated)
+	s.Len(s.kv, 1)
+}
+
+func (s *MigrationTestSuite) Test_Migrate_SkipsServicesOfTheDefaultInstance() {
+	s.kv["docker-flow/service/go-demo"] = "swarm"
+	s.kv["docker-flow/go-demo/path"] = "/demo"
+	s.kv["docker-flow/go-demo/consultemplatefepath"] = "/consul_templates/go-demo-fe.tmpl"
+
+	actual, err := Consul{}.Migrate([]string{s.server.URL}, "my-proxy", true)
+
+	s.NoError(err)
+	s.Empty(actual.Migrated)
+	s.Empty(actual.Failed)
+	s.Len(s.kv, 3)
+	s.Equal("/demo", s.kv["docker-flow/go-demo/path"])
+}
+
+func (s *MigrationTestSuite) Test_Migrate_DeletesLegacyKeys_WhenCleanupIsTrue() {
+	s.kv["docker-flow-proxy/services/go-demo/service_path"] = "/demo"
+
+	Consul{}.Migrate([]string{s.server.URL}, "my-proxy", true)
+
+	s.NotContains(s.kv, "docker-flow-proxy/services/go-demo/service_path")
+	s.Equal("/demo", s.kv["my-proxy/go-demo/path"])
+}
+
+func (s *MigrationTestSuite) Test_Migrate_ReportsFailedServices() {
+	s.kv["docker-flow-proxy/services/broken/service_color"] = "blue"
+	s.kv["docker-flow-proxy/services/go-demo/service_path"] = "/demo"
+
+	actual, _ := Consul{}.Migrate([]string{s.server.URL}, "my-proxy", true)
+
+	s.Equal([]string{"docker-flow-proxy/services/go-demo"}, actual.Migrated)
+	s.Contains(actual.Failed, "docker-flow-proxy/services/broken")
+	s.Equal("blue", s.kv["docker-flow-proxy/services/broken/service_color"])
+}
+
+func (s *MigrationTestSuite) Test_Migrate_ReturnsError_WhenConsulIsNotReachable() {
+	_, err := Consul{}.Migrate([]string{"http://127.0.0.1:1"}, "my-proxy", false)
+
+	s.Error(err)
+}
+
+// MigrateServiceNames
+
+func (s *MigrationTestSuite) Test_MigrateServiceNames_MovesServicesToLowerCaseNames() {
+	s.kv["my-proxy/MyService/path"] = "/demo"
+	s.kv["my-proxy/MyService/port"] = "8080"
+	s.kv["my-proxy/service/MyService"] = "swarm"
+	s.kv["my-proxy/MyServiceApi/path"] = "/api"
+	s.kv["my-proxy/go-demo/path"] = "/go-demo"
+
+	actual, err := Consul{}.MigrateServiceNames([]string{s.server.URL}, "my-proxy")
+
+	s.NoError(err)
+	s.Equal([]string{"my-proxy/MyService", "my-proxy/MyServiceApi"}, actual.Migrated)
+	s.Equal("/demo", s.kv["my-proxy/myservice/path"])
+	s.Equal("8080", s.kv["my-proxy/myservice/port"])
+	s.Equal("MyService", s.kv["my-proxy/myservice/servicedisplayname"])
+	s.Equal("swarm", s.kv["my-proxy/service/myservice"])
+	s.Equal("/api", s.kv["my-proxy/myserviceapi/path"])
+	s.Equal("/go-demo", s.kv["my-proxy/go-demo/path"])
+	s.NotContains(s.kv, "my-proxy/MyService/path")
+	s.NotContains(s.kv, "my-proxy/service/MyService")
+	s.NotContains(s.kv, "my-proxy/MyServiceApi/path")
+}
+
+func (s *MigrationTestSuite) Test_MigrateServiceNames_KeepsMostRecentlyModifiedDuplicate() {
+	s.kv["my-proxy/MyService/path"] = "/old"
+	s.indexes["my-proxy/MyService/path"] = 10
+	s.kv["my-proxy/myService/path"] = "/new"
+	s.indexes["my-proxy/myService/path"] = 20
+	s.kv["my-proxy/myservice/path"] = "/oldest"
+	s.indexes["my-proxy/myservice/path"] = 5
+
+	actual, _ := Consul{}.MigrateServiceNames([]string{s.server.URL}, "my-proxy")
+
+	s.Equal([]string{"my-proxy/myService"}, actual.Migrated)
+	s.Equal("/new", s.kv["my-proxy/myservice/path"])
+	s.Equal("myService", s.kv["my-proxy/myservice/servicedisplayname"])
+	s.NotContains(s.kv, "my-proxy/MyService/path")
+	s.NotContains(s.kv, "my-proxy/myService/path")
+}
+
+func (s *MigrationTestSuite) Test_MigrateServiceNames_DeletesDuplicates_WhenLowerCaseServiceIsMostRecent() {
+	s.kv["my-proxy/MyService/path"] = "/old"
+	s.indexes["my-proxy/MyService/path"] = 10
+	s.kv["my-proxy/myservice/path"] = "/new"
+	s.indexes["my-proxy/myservice/path"] = 20
+
+	actual, _ := Consul{}.MigrateServiceNames([]string{s.server.URL}, "my-proxy")
+
+	s.Equal([]string{"my-proxy/myservice"}, actual.Migrated)
+	s.Equal(map[string]string{"my-proxy/myservice/path": "/new"}, s.kv)
+}
+
+func (s *MigrationTestSuite) Test_MigrateServiceNames_DoesNothing_WhenNamesAreLowerCase() {
+	s.kv["my-proxy/go-demo/path"] = "/demo"
+
+	actual, _ := Consul{}.MigrateServiceNames([]string{s.server.URL}, "my-proxy")
+
+	s.Empty(actual.Migrated)
+	s.Equal(map[string]string{"my-proxy/go-demo/path": "/demo"}, s.kv)
+}
+
+// Suite
+
+func TestMigrationUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrationTestSuite))
+}
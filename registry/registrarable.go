@@ -1,30 +1,190 @@
 package registry
 
 const (
-	COLOR_KEY                   = "color"
-	PATH_KEY                    = "path"
-	DOMAIN_KEY                  = "domain"
-	CERT_KEY                    = "cert"
-	HOSTNAME_KEY                = "hostname"
-	PATH_TYPE_KEY               = "pathtype"
-	SKIP_CHECK_KEY              = "skipcheck"
-	CONSUL_TEMPLATE_FE_PATH_KEY = "consultemplatefepath"
-	CONSUL_TEMPLATE_BE_PATH_KEY = "consultemplatebepath"
-	PORT                        = "port"
+	COLOR_KEY                    = "color"
+	PATH_KEY                     = "path"
+	DOMAIN_KEY                   = "domain"
+	CERT_KEY                     = "cert"
+	HOSTNAME_KEY                 = "hostname"
+	PATH_TYPE_KEY                = "pathtype"
+	SKIP_CHECK_KEY               = "skipcheck"
+	CONSUL_TEMPLATE_FE_PATH_KEY  = "consultemplatefepath"
+	CONSUL_TEMPLATE_BE_PATH_KEY  = "consultemplatebepath"
+	PORT                         = "port"
+	ADDRESS_KEY                  = "address"
+	COLOR_ADDRESSES_KEY          = "coloraddresses"
+	DC_ADDRESSES_KEY             = "dcaddresses"
+	CORS_ORIGINS_KEY             = "corsorigins"
+	CORS_METHODS_KEY             = "corsmethods"
+	CORS_HEADERS_KEY             = "corsheaders"
+	INTERNAL_ONLY_KEY            = "internalonly"
+	CHECK_GRPC_KEY               = "checkgrpc"
+	PROFILE_KEY                  = "profile"
+	EXPLICIT_PARAMETERS_KEY      = "explicitparameters"
+	TRACING_SAMPLE_RATE_KEY      = "tracingsamplerate"
+	CANARY_HEADER_KEY            = "canaryheader"
+	SERVICE_DESCRIPTION_KEY      = "servicedescription"
+	OWNER_KEY                    = "owner"
+	SERVICE_DISPLAY_NAME_KEY     = "servicedisplayname"
+	TIMEOUT_SERVER_KEY           = "timeoutserver"
+	TIMEOUT_TUNNEL_KEY           = "timeouttunnel"
+	TIMEOUT_HTTP_REQUEST_KEY     = "timeouthttprequest"
+	TIMEOUT_QUEUE_KEY            = "timeoutqueue"
+	TIMEOUT_CONNECT_KEY          = "timeoutconnect"
+	TIMEOUT_CLIENT_KEY           = "timeoutclient"
+	STACK_NAME_KEY               = "stackname"
+	ALLOW_MISSING_HOST_KEY       = "allowmissinghost"
+	USE_DOMAIN_MAP_KEY           = "usedomainmap"
+	LETS_ENCRYPT_KEY             = "letsencrypt"
+	PROXY_ROLE_KEY               = "proxyrole"
+	CLIENT_CERT_VERIFY_KEY       = "clientcertverify"
+	CLIENT_CERT_CA_FILE_KEY      = "clientcertcafile"
+	TCP_DESTINATIONS_KEY         = "tcpdestinations"
+	HTTP_DESTINATIONS_KEY        = "httpdestinations"
+	MAINTENANCE_KEY              = "maintenance"
+	MAINTENANCE_WINDOW_KEY       = "maintenancewindow"
+	CERT_NAME_KEY                = "certname"
+	HTTP_REUSE_KEY               = "httpreuse"
+	POOL_MAX_CONN_KEY            = "poolmaxconn"
+	POOL_PURGE_DELAY_KEY         = "poolpurgedelay"
+	POST_RELOAD_HOOK_KEY         = "postreloadhook"
+	SRC_PORT_KEY                 = "srcport"
+	HTTPS_ONLY_KEY               = "httpsonly"
+	HTTPS_PORT_KEY               = "httpsport"
+	SSL_VERIFY_NONE_KEY          = "sslverifynone"
+	SSL_CA_CERT_KEY              = "sslcacert"
+	SESSION_TYPE_KEY             = "sessiontype"
+	COOKIE_NAME_KEY              = "cookiename"
+	BALANCE_KEY                  = "balance"
+	CONNECTION_MODE_KEY          = "connectionmode"
+	REQ_MODE_KEY                 = "reqmode"
+	SERVICE_DOMAIN_MATCH_ALL_KEY = "servicedomainmatchall"
+	REDIRECT_FROM_DOMAIN_KEY     = "redirectfromdomain"
+	ALLOWED_METHODS_KEY          = "allowedmethods"
+	DENIED_METHODS_KEY           = "deniedmethods"
+	DENY_HTTP_KEY                = "denyhttp"
+	ADD_REQ_HEADER_KEY           = "addreqheader"
+	SET_REQ_HEADER_KEY           = "setreqheader"
+	DEL_REQ_HEADER_KEY           = "delreqheader"
+	ADD_RES_HEADER_KEY           = "addresheader"
+	DEL_RES_HEADER_KEY           = "delresheader"
+	FORWARDED_PROTO_KEY          = "forwardedproto"
+	COMPRESSION_ALGO_KEY         = "compressionalgo"
+	COMPRESSION_TYPE_KEY         = "compressiontype"
+	REQ_RATE_LIMIT_KEY           = "reqratelimit"
+	REQ_RATE_PERIOD_KEY          = "reqrateperiod"
+	ALLOWED_SOURCE_IPS_KEY       = "allowedsourceips"
+	BACKEND_EXTRA_KEY            = "backendextra"
+	FRONTEND_EXTRA_KEY           = "frontendextra"
+	CHECK_PATH_KEY               = "checkpath"
+	CHECK_METHOD_KEY             = "checkmethod"
+	CHECK_INTERVAL_KEY           = "checkinterval"
+	CHECK_RISE_KEY               = "checkrise"
+	CHECK_FALL_KEY               = "checkfall"
+	CHECK_TIMEOUT_KEY            = "checktimeout"
+	HTTP_CHECK_EXPECT_KEY        = "httpcheckexpect"
+	BACKUP_HOSTNAME_KEY          = "backuphostname"
+	BACKUP_PORT_KEY              = "backupport"
+	WEIGHT_KEY                   = "weight"
+	COLOR_WEIGHTS_KEY            = "colorweights"
+	MAX_CONN_KEY                 = "maxconn"
+	RETRIES_KEY                  = "retries"
+	REDISPATCH_KEY               = "redispatch"
+	REDIRECT_WHEN_HTTP_PROTO_KEY = "redirectwhenhttpproto"
 )
 
 type Registry struct {
-	ServiceName          string
-	Port                 string
-	ServiceColor         string
-	ServicePath          []string
-	ServiceDomain        []string
-	ServiceCert          string
-	OutboundHostname     string
-	PathType             string
-	SkipCheck            bool
-	ConsulTemplateFePath string
-	ConsulTemplateBePath string
+	ServiceName           string
+	ServiceDisplayName    string
+	Port                  string
+	ServiceColor          string
+	ServicePath           []string
+	ServiceDomain         []string
+	ServiceCert           string
+	OutboundHostname      string
+	PathType              string
+	SkipCheck             bool
+	ConsulTemplateFePath  string
+	ConsulTemplateBePath  string
+	ServiceAddress        string
+	ColorAddresses        map[string]string
+	DcAddresses           map[string]string
+	CorsOrigins           []string
+	CorsMethods           []string
+	CorsHeaders           []string
+	InternalOnly          bool
+	CheckGrpc             bool
+	Profile               string
+	ExplicitParameters    []string
+	TracingSampleRate     string
+	CanaryHeader          string
+	ServiceDescription    string
+	Owner                 string
+	TimeoutServer         int
+	TimeoutTunnel         int
+	TimeoutHttpRequest    int
+	TimeoutQueue          int
+	TimeoutConnect        int
+	TimeoutClient         int
+	StackName             string
+	AllowMissingHost      bool
+	UseDomainMap          bool
+	LetsEncrypt           bool
+	ProxyRole             string
+	ClientCertVerify      string
+	ClientCertCaFile      string
+	TcpDestinations       string
+	HttpDestinations      string
+	Maintenance           string
+	MaintenanceWindow     string
+	CertName              string
+	HttpReuse             string
+	PoolMaxConn           string
+	PoolPurgeDelay        string
+	PostReloadHook        string
+	SrcPort               string
+	HttpsOnly             bool
+	HttpsPort             string
+	SslVerifyNone         bool
+	SslCaCert             string
+	SessionType           string
+	CookieName            string
+	Balance               string
+	ConnectionMode        string
+	ReqMode               string
+	ServiceDomainMatchAll bool
+	RedirectFromDomain    []string
+	AllowedMethods        []string
+	DeniedMethods         []string
+	DenyHttp              bool
+	AddReqHeader          string
+	SetReqHeader          string
+	DelReqHeader          string
+	AddResHeader          string
+	DelResHeader          string
+	ForwardedProto        string
+	CompressionAlgo       []string
+	CompressionType       []string
+	ReqRateLimit          string
+	ReqRatePeriod         int
+	AllowedSourceIPs      []string
+	BackendExtra          string
+	FrontendExtra         string
+	CheckPath             string
+	CheckMethod           string
+	CheckInterval         string
+	CheckRise             string
+	CheckFall             string
+	CheckTimeout          string
+	HttpCheckExpect       string
+	BackupHostname        string
+	BackupPort            string
+	Weight                string
+	ColorWeights          map[string]string
+	MaxConn               string
+	Retries               string
+	Redispatch            bool
+	RedirectWhenHttpProto bool
 }
 
 type Registrarable interface {
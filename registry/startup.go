@@ -0,0 +1,54 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// STARTUP_KEY_PREFIX is the Consul KV prefix the startup state of each proxy instance is stored under.
+const STARTUP_KEY_PREFIX = "docker-flow/startup"
+
+// StartupState is the last configuration an instance of the proxy was serving after its startup.
+type StartupState struct {
+	Hash   string
+	Config string
+}
+
+// PutStartupState stores the state under STARTUP_KEY_PREFIX and the name of the proxy instance.
+func (m Consul) PutStartupState(addresses []string, instanceName string, state StartupState) error {
+	value, _ := json.Marshal(state)
+	if err := m.sendKvRequest("PUT", addresses, STARTUP_KEY_PREFIX+"/"+instanceName, string(value)); err != nil {
+		return fmt.Errorf("Could not store the startup state in Consul\n%s", err.Error())
+	}
+	return nil
+}
+
+// GetStartupState returns the state stored by PutStartupState. The state is nil if it was never stored.
+func (m Consul) GetStartupState(addresses []string, instanceName string) (*StartupState, error) {
+	var err error
+	for _, address := range OrderConsulAddresses(addresses) {
+		var resp *http.Response
+		url := fmt.Sprintf("%s/v1/kv/%s/%s?raw", getConsulUrl(address), STARTUP_KEY_PREFIX, instanceName)
+		resp, err = getConsulClient().Get(url)
+		MarkConsulAddress(address, err)
+		if err != nil {
+			continue
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		} else if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("Consul responded with the status %d", resp.StatusCode)
+			continue
+		}
+		state := StartupState{}
+		body, _ := ioutil.ReadAll(resp.Body)
+		if err = json.Unmarshal(body, &state); err != nil {
+			continue
+		}
+		return &state, nil
+	}
+	return nil, fmt.Errorf("Could not retrieve the startup state from Consul\n%s", err)
+}
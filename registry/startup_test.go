@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type StartupTestSuite struct {
+	suite.Suite
+}
+
+func TestStartupUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(StartupTestSuite))
+}
+
+// PutStartupState
+
+func (s *StartupTestSuite) Test_PutStartupState_PutsStateUnderInstanceName() {
+	var actualMethod, actualPath, actualBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		actualMethod, actualPath, actualBody = r.Method, r.URL.Path, string(body)
+	}))
+	defer server.Close()
+
+	err := Consul{}.PutStartupState([]string{server.URL}, "my-proxy", StartupState{Hash: "abc", Config: "global\n"})
+
+	s.NoError(err)
+	s.Equal("PUT", actualMethod)
+	s.Equal("/v1/kv/docker-flow/startup/my-proxy", actualPath)
+	actualState := StartupState{}
+	json.Unmarshal([]byte(actualBody), &actualState)
+	s.Equal(StartupState{Hash: "abc", Config: "global\n"}, actualState)
+}
+
+// GetStartupState
+
+func (s *StartupTestSuite) Test_GetStartupState_ReturnsStoredState() {
+	var actualPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualPath = r.URL.Path
+		w.Write([]byte(`{"Hash": "abc", "Config": "global\n"}`))
+	}))
+	defer server.Close()
+
+	actual, err := Consul{}.GetStartupState([]string{server.URL}, "my-proxy")
+
+	s.NoError(err)
+	s.Equal("/v1/kv/docker-flow/startup/my-proxy", actualPath)
+	s.Equal(&StartupState{Hash: "abc", Config: "global\n"}, actual)
+}
+
+func (s *StartupTestSuite) Test_GetStartupState_ReturnsNil_WhenStateWasNeverStored() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	actual, err := Consul{}.GetStartupState([]string{server.URL}, "my-proxy")
+
+	s.NoError(err)
+	s.Nil(actual)
+}
+
+func (s *StartupTestSuite) Test_GetStartupState_ReturnsError_WhenConsulIsNotReachable() {
+	_, err := Consul{}.GetStartupState([]string{"http:///THIS/URL/DOES/NOT/EXIST"}, "my-proxy")
+
+	s.Error(err)
+}
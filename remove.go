@@ -1,8 +1,11 @@
 package main
 
 import (
+	"./actions"
 	haproxy "./proxy"
 	"fmt"
+	"log"
+	"os"
 	"strings"
 )
 
@@ -35,37 +38,134 @@ var NewRemove = func(serviceName, aclName, configsPath, templatesPath string, co
 	}
 }
 
+// RemoveServices removes multiple services and reloads the proxy only once.
+type RemoveServices struct {
+	Services        []actions.ServiceReconfigure
+	ConfigsPath     string
+	ConsulAddresses []string
+	InstanceName    string
+	TemplatesPath   string
+	Mode            string
+}
+
+var NewRemoveServices = func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+	return &RemoveServices{
+		Services:        services,
+		TemplatesPath:   templatesPath,
+		ConfigsPath:     configsPath,
+		ConsulAddresses: consulAddresses,
+		InstanceName:    instanceName,
+		Mode:            mode,
+	}
+}
+
+// RemoveServicesError is returned when the proxy was reloaded but some of the services could not be removed.
+type RemoveServicesError struct {
+	Services []string
+}
+
+func (e *RemoveServicesError) Error() string {
+	return fmt.Sprintf("Could not remove the services %s", strings.Join(e.Services, ", "))
+}
+
+// Execute removes the files of all the services before reloading the proxy. Services that could not be removed do not
+// prevent the removal of the others and are listed in a RemoveServicesError.
+func (m *RemoveServices) Execute(args []string) error {
+	failed := []string{}
+	for _, sr := range m.Services {
+		log.Printf("Removing %s configuration", sr.ServiceName)
+		r := Remove{}
+		if err := r.removeFiles(m.TemplatesPath, sr.ServiceName, sr.AclName, m.ConsulAddresses, m.InstanceName, m.Mode); err != nil {
+			log.Printf(err.Error())
+			failed = append(failed, sr.ServiceName)
+		}
+	}
+	if _, _, err := writeDomainMap(m.ConfigsPath); err != nil {
+		log.Printf(err.Error())
+	}
+	if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
+		log.Printf(err.Error())
+		return err
+	}
+	for _, sr := range m.Services {
+		haproxy.AddReloadChange(sr.ServiceName, haproxy.ReloadActionRemove, "")
+	}
+	if err := haproxy.Instance.Reload(); err != nil {
+		log.Printf(err.Error())
+		return err
+	}
+	if len(failed) > 0 {
+		return &RemoveServicesError{Services: failed}
+	}
+	return nil
+}
+
 // TODO: Remove args
 func (m *Remove) Execute(args []string) error {
-	logPrintf("Removing %s configuration", m.ServiceName)
+	log.Printf("Removing %s configuration", m.ServiceName)
 	if err := m.removeFiles(m.TemplatesPath, m.ServiceName, m.AclName, m.ConsulAddresses, m.InstanceName, m.Mode); err != nil {
-		logPrintf(err.Error())
+		log.Printf(err.Error())
 		return err
 	}
+	if _, _, err := writeDomainMap(m.ConfigsPath); err != nil {
+		log.Printf(err.Error())
+	}
 	if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
-		logPrintf(err.Error())
+		log.Printf(err.Error())
 		return err
 	}
+	haproxy.AddReloadChange(m.ServiceName, haproxy.ReloadActionRemove, "")
 	if err := haproxy.Instance.Reload(); err != nil {
-		logPrintf(err.Error())
+		log.Printf(err.Error())
 		return err
 	}
 	return nil
 }
 
+// getFilesName returns the name used by the configuration files of the service. Files created by previous versions
+// might use names that differ by case from the canonical ones.
+func (m *Remove) getFilesName(templatesPath, aclName string) string {
+	files, err := readDir(templatesPath)
+	if err != nil {
+		return aclName
+	}
+	for _, file := range files {
+		if file.Name() == aclName+"-be.cfg" {
+			return aclName
+		}
+	}
+	for _, file := range files {
+		if strings.EqualFold(file.Name(), aclName+"-be.cfg") {
+			return strings.TrimSuffix(file.Name(), "-be.cfg")
+		}
+	}
+	return aclName
+}
+
 func (m *Remove) removeFiles(templatesPath, serviceName, aclName string, registryAddresses []string, instanceName, mode string) error {
-	logPrintf("Removing the %s configuration files", serviceName)
+	log.Printf("Removing the %s configuration files", serviceName)
 	if len(aclName) == 0 {
 		aclName = serviceName
 	}
+	aclName = m.getFilesName(templatesPath, aclName)
 	paths := []string{
 		fmt.Sprintf("%s/%s-fe.cfg", templatesPath, aclName),
 		fmt.Sprintf("%s/%s-be.cfg", templatesPath, aclName),
 	}
 	mu.Lock()
 	defer mu.Unlock()
-	for _, path := range paths {
-		if err := osRemove(path); err != nil {
+	actions.RemoveKnownService(aclName)
+	// Services of other roles do not have configuration files
+	if actions.RemoveUnappliedService(aclName) {
+		paths = []string{}
+	}
+	for i, path := range paths {
+		err := osRemove(path)
+		if i == 0 && os.IsNotExist(err) {
+			// Internal services do not have the public frontend configuration
+			err = osRemove(fmt.Sprintf("%s/%s-internal-fe.cfg", templatesPath, aclName))
+		}
+		if err != nil {
 			return err
 		}
 	}
@@ -3,10 +3,15 @@
 package main
 
 import (
-	haproxy "./proxy"
+	"./actions"
 	"fmt"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	haproxy "./proxy"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -73,6 +78,45 @@ func (s RemoveTestSuite) Test_Execute_RemovesConfigurationFileUsingAclName_WhenP
 	s.Equal(expected, actual)
 }
 
+func (s RemoveTestSuite) Test_Execute_RemovesInternalConfigurationFile_WhenPublicIsNotPresent() {
+	var actual []string
+	expected := []string{
+		fmt.Sprintf("%s/%s-fe.cfg", s.TemplatesPath, s.ServiceName),
+		fmt.Sprintf("%s/%s-internal-fe.cfg", s.TemplatesPath, s.ServiceName),
+		fmt.Sprintf("%s/%s-be.cfg", s.TemplatesPath, s.ServiceName),
+	}
+	osRemove = func(name string) error {
+		actual = append(actual, name)
+		if strings.HasSuffix(name, "-fe.cfg") && !strings.HasSuffix(name, "-internal-fe.cfg") {
+			return os.ErrNotExist
+		}
+		return nil
+	}
+
+	s.remove.Execute([]string{})
+
+	s.Equal(expected, actual)
+}
+
+func (s RemoveTestSuite) Test_Execute_RemovesConfigurationFilesWhoseNamesDifferByCase() {
+	dir, _ := ioutil.TempDir("", "templates")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(dir+"/MyService-fe.cfg", []byte(""), 0664)
+	ioutil.WriteFile(dir+"/MyService-be.cfg", []byte(""), 0664)
+	ioutil.WriteFile(dir+"/myservice-api-be.cfg", []byte(""), 0664)
+	var actual []string
+	osRemove = func(name string) error {
+		actual = append(actual, name)
+		return nil
+	}
+	s.remove.ServiceName = "myservice"
+	s.remove.TemplatesPath = dir
+
+	s.remove.Execute([]string{})
+
+	s.Equal([]string{dir + "/MyService-fe.cfg", dir + "/MyService-be.cfg"}, actual)
+}
+
 func (s RemoveTestSuite) Test_Execute_ReturnsError_WhenFailure() {
 	osRemove = func(name string) error {
 		return fmt.Errorf("The file could not be removed")
@@ -176,6 +220,55 @@ func (s RemoveTestSuite) Test_Execute_ReturnsError_WhenDeleteRequestToRegistryFa
 	s.Error(err)
 }
 
+// RemoveServices > Execute
+
+func (s RemoveTestSuite) Test_RemoveServicesExecute_RemovesAllServicesWithSingleReload() {
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	mockObj := getProxyMock("")
+	haproxy.Instance = mockObj
+	var actual []string
+	osRemove = func(name string) error {
+		actual = append(actual, name)
+		return nil
+	}
+	services := []actions.ServiceReconfigure{{ServiceName: "shop_api"}, {ServiceName: "shop_web", AclName: "web-acl"}}
+
+	err := NewRemoveServices(services, s.ConfigsPath, s.TemplatesPath, []string{s.ConsulAddress}, s.InstanceName, "swarm").Execute([]string{})
+
+	s.NoError(err)
+	s.Equal([]string{
+		s.TemplatesPath + "/shop_api-fe.cfg",
+		s.TemplatesPath + "/shop_api-be.cfg",
+		s.TemplatesPath + "/web-acl-fe.cfg",
+		s.TemplatesPath + "/web-acl-be.cfg",
+	}, actual)
+	mockObj.AssertNumberOfCalls(s.T(), "CreateConfigFromTemplates", 1)
+	mockObj.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
+func (s RemoveTestSuite) Test_RemoveServicesExecute_RemovesOtherServices_WhenOneFails() {
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	mockObj := getProxyMock("")
+	haproxy.Instance = mockObj
+	var actual []string
+	osRemove = func(name string) error {
+		if strings.Contains(name, "shop_api") {
+			return fmt.Errorf("The file could not be removed")
+		}
+		actual = append(actual, name)
+		return nil
+	}
+	services := []actions.ServiceReconfigure{{ServiceName: "shop_api"}, {ServiceName: "shop_web"}}
+
+	err := NewRemoveServices(services, s.ConfigsPath, s.TemplatesPath, []string{s.ConsulAddress}, s.InstanceName, "swarm").Execute([]string{})
+
+	s.EqualError(err, "Could not remove the services shop_api")
+	s.Equal([]string{s.TemplatesPath + "/shop_web-fe.cfg", s.TemplatesPath + "/shop_web-be.cfg"}, actual)
+	mockObj.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
 // Suite
 
 func TestRemoveUnitTestSuite(t *testing.T) {
@@ -183,7 +276,7 @@ func TestRemoveUnitTestSuite(t *testing.T) {
 	registryInstanceOrig := registryInstance
 	defer func() { registryInstance = registryInstanceOrig }()
 	registryInstance = mockObj
-	logPrintf = func(format string, v ...interface{}) {}
+	log.SetOutput(ioutil.Discard)
 	suite.Run(t, new(RemoveTestSuite))
 }
 
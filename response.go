@@ -0,0 +1,563 @@
+package main
+
+import (
+	"./actions"
+	"./proxy"
+)
+
+// Response is returned by the v1 endpoints. Its fields are flattened at the top level and must not change in order
+// to keep existing clients working.
+type Response struct {
+	Status                string
+	Message               string
+	Errors                []actions.ParameterError `json:",omitempty"`
+	ServiceName           string
+	AclName               string
+	ServiceColor          string
+	ServicePath           []string
+	ServiceDomain         []string
+	ServiceCert           string
+	OutboundHostname      string
+	ConsulTemplateFePath  string
+	ConsulTemplateBePath  string
+	PathType              string
+	SkipCheck             bool
+	Mode                  string
+	Port                  string
+	Distribute            bool
+	Users                 []actions.User
+	ReqRepSearch          string
+	ReqRepReplace         string
+	TemplateFePath        string
+	TemplateBePath        string
+	ServiceAddress        string
+	ColorAddresses        map[string]string
+	DcAddresses           map[string]string
+	CorsOrigins           []string
+	CorsMethods           []string
+	CorsHeaders           []string
+	InternalOnly          bool
+	CheckGrpc             bool
+	Profile               string
+	TracingSampleRate     string
+	CanaryHeader          string
+	LastReload            *proxy.ReloadEntry `json:",omitempty"`
+	ServiceDescription    string
+	Owner                 string
+	TimeoutServer         int
+	TimeoutTunnel         int
+	TimeoutHttpRequest    int
+	TimeoutQueue          int
+	TimeoutConnect        int
+	TimeoutClient         int
+	StackName             string
+	AllowMissingHost      bool
+	UseDomainMap          bool
+	LetsEncrypt           bool
+	LetsEncryptError      string   `json:",omitempty"`
+	ReloadDeferredMs      int64    `json:",omitempty"`
+	Warnings              []string `json:",omitempty"`
+	ProxyRole             string
+	ClientCertVerify      string
+	ClientCertCaFile      string
+	TcpDestinations       []actions.TcpDestination
+	HttpDestinations      []actions.HttpDestination
+	Maintenance           string
+	MaintenanceWindow     string
+	CertName              string
+	HttpReuse             string
+	PoolMaxConn           string
+	PoolPurgeDelay        string
+	PostReloadHook        string
+	SrcPort               string
+	HttpsOnly             bool
+	HttpsPort             string
+	SslVerifyNone         bool
+	SslCaCert             string
+	SessionType           string
+	CookieName            string
+	Balance               string
+	ConnectionMode        string
+	ReqMode               string
+	ServiceDomainMatchAll bool
+	RedirectFromDomain    []string
+	AllowedMethods        []string
+	DeniedMethods         []string
+	DenyHttp              bool
+	AddReqHeader          []string
+	SetReqHeader          []string
+	DelReqHeader          []string
+	AddResHeader          []string
+	DelResHeader          []string
+	ForwardedProto        string
+	CompressionAlgo       []string
+	CompressionType       []string
+	ReqRateLimit          string
+	ReqRatePeriod         int
+	AllowedSourceIPs      []string
+	BackendExtra          string
+	FrontendExtra         string
+	CheckPath             string
+	CheckMethod           string
+	CheckInterval         string
+	CheckRise             string
+	CheckFall             string
+	CheckTimeout          string
+	HttpCheckExpect       string
+	BackupHostname        string
+	BackupPort            string
+	Weight                string
+	ColorWeights          map[string]string
+	MaxConn               string
+	Retries               string
+	Redispatch            bool
+	RedirectWhenHttpProto bool
+}
+
+// ResponseV2 is returned by the v2 endpoints. All fields are always present so that two responses can be diffed.
+type ResponseV2 struct {
+	Status           string                   `json:"status"`
+	Message          string                   `json:"message"`
+	Errors           []actions.ParameterError `json:"errors"`
+	Parameters       ServiceParameters        `json:"parameters"`
+	LastReload       *proxy.ReloadEntry       `json:"lastReload,omitempty"`
+	LetsEncryptError string                   `json:"letsEncryptError,omitempty"`
+	ReloadDeferredMs int64                    `json:"reloadDeferredMs,omitempty"`
+	Warnings         []string                 `json:"warnings,omitempty"`
+}
+
+// ServiceParameters mirrors the decoded actions.ServiceReconfigure. JSON names match the query parameters.
+type ServiceParameters struct {
+	ServiceName           string                    `json:"serviceName"`
+	AclName               string                    `json:"aclName"`
+	ServiceColor          string                    `json:"serviceColor"`
+	ServicePath           []string                  `json:"servicePath"`
+	ServiceDomain         []string                  `json:"serviceDomain"`
+	ServiceCert           string                    `json:"serviceCert"`
+	OutboundHostname      string                    `json:"outboundHostname"`
+	ConsulTemplateFePath  string                    `json:"consulTemplateFePath"`
+	ConsulTemplateBePath  string                    `json:"consulTemplateBePath"`
+	PathType              string                    `json:"pathType"`
+	SkipCheck             bool                      `json:"skipCheck"`
+	Mode                  string                    `json:"mode"`
+	Port                  string                    `json:"port"`
+	Distribute            bool                      `json:"distribute"`
+	Users                 []UserParameters          `json:"users"`
+	ReqRepSearch          string                    `json:"reqRepSearch"`
+	ReqRepReplace         string                    `json:"reqRepReplace"`
+	TemplateFePath        string                    `json:"templateFePath"`
+	TemplateBePath        string                    `json:"templateBePath"`
+	ServiceAddress        string                    `json:"serviceAddress"`
+	ColorAddresses        map[string]string         `json:"colorAddresses"`
+	DcAddresses           map[string]string         `json:"dcAddresses"`
+	CorsOrigins           []string                  `json:"corsOrigins"`
+	CorsMethods           []string                  `json:"corsMethods"`
+	CorsHeaders           []string                  `json:"corsHeaders"`
+	InternalOnly          bool                      `json:"internalOnly"`
+	CheckGrpc             bool                      `json:"checkGrpc"`
+	Profile               string                    `json:"profile"`
+	TracingSampleRate     string                    `json:"tracingSampleRate"`
+	CanaryHeader          string                    `json:"canaryHeader"`
+	ServiceDescription    string                    `json:"serviceDescription"`
+	Owner                 string                    `json:"owner"`
+	TimeoutServer         int                       `json:"timeoutServer"`
+	TimeoutTunnel         int                       `json:"timeoutTunnel"`
+	TimeoutHttpRequest    int                       `json:"timeoutHttpRequest"`
+	TimeoutQueue          int                       `json:"timeoutQueue"`
+	TimeoutConnect        int                       `json:"timeoutConnect"`
+	TimeoutClient         int                       `json:"timeoutClient"`
+	StackName             string                    `json:"stackName"`
+	AllowMissingHost      bool                      `json:"allowMissingHost"`
+	UseDomainMap          bool                      `json:"useDomainMap"`
+	LetsEncrypt           bool                      `json:"letsEncrypt"`
+	ProxyRole             string                    `json:"proxyRole"`
+	ClientCertVerify      string                    `json:"clientCertVerify"`
+	ClientCertCaFile      string                    `json:"clientCertCaFile"`
+	TcpDestinations       []actions.TcpDestination  `json:"tcpDestinations"`
+	HttpDestinations      []actions.HttpDestination `json:"httpDestinations"`
+	Maintenance           string                    `json:"maintenance"`
+	MaintenanceWindow     string                    `json:"maintenanceWindow"`
+	CertName              string                    `json:"certName"`
+	HttpReuse             string                    `json:"httpReuse"`
+	PoolMaxConn           string                    `json:"poolMaxConn"`
+	PoolPurgeDelay        string                    `json:"poolPurgeDelay"`
+	PostReloadHook        string                    `json:"postReloadHook"`
+	SrcPort               string                    `json:"srcPort"`
+	HttpsOnly             bool                      `json:"httpsOnly"`
+	HttpsPort             string                    `json:"httpsPort"`
+	SslVerifyNone         bool                      `json:"sslVerifyNone"`
+	SslCaCert             string                    `json:"sslCaCert"`
+	SessionType           string                    `json:"sessionType"`
+	CookieName            string                    `json:"cookieName"`
+	Balance               string                    `json:"balance"`
+	ConnectionMode        string                    `json:"connectionMode"`
+	ReqMode               string                    `json:"reqMode"`
+	ServiceDomainMatchAll bool                      `json:"serviceDomainMatchAll"`
+	RedirectFromDomain    []string                  `json:"redirectFromDomain"`
+	AllowedMethods        []string                  `json:"allowedMethods"`
+	DeniedMethods         []string                  `json:"deniedMethods"`
+	DenyHttp              bool                      `json:"denyHttp"`
+	AddReqHeader          []string                  `json:"addReqHeader"`
+	SetReqHeader          []string                  `json:"setReqHeader"`
+	DelReqHeader          []string                  `json:"delReqHeader"`
+	AddResHeader          []string                  `json:"addResHeader"`
+	DelResHeader          []string                  `json:"delResHeader"`
+	ForwardedProto        string                    `json:"forwardedProto"`
+	CompressionAlgo       []string                  `json:"compressionAlgo"`
+	CompressionType       []string                  `json:"compressionType"`
+	ReqRateLimit          string                    `json:"reqRateLimit"`
+	ReqRatePeriod         int                       `json:"reqRatePeriod"`
+	AllowedSourceIPs      []string                  `json:"allowedSourceIPs"`
+	BackendExtra          string                    `json:"backendExtra"`
+	FrontendExtra         string                    `json:"frontendExtra"`
+	CheckPath             string                    `json:"checkPath"`
+	CheckMethod           string                    `json:"checkMethod"`
+	CheckInterval         string                    `json:"checkInterval"`
+	CheckRise             string                    `json:"checkRise"`
+	CheckFall             string                    `json:"checkFall"`
+	CheckTimeout          string                    `json:"checkTimeout"`
+	HttpCheckExpect       string                    `json:"httpCheckExpect"`
+	BackupHostname        string                    `json:"backupHostname"`
+	BackupPort            string                    `json:"backupPort"`
+	Weight                string                    `json:"weight"`
+	ColorWeights          map[string]string         `json:"colorWeights"`
+	MaxConn               string                    `json:"maxconn"`
+	Retries               string                    `json:"retries"`
+	Redispatch            bool                      `json:"redispatch"`
+	RedirectWhenHttpProto bool                      `json:"redirectWhenHttpProto"`
+	// Applied is set only in the list of services. It is false if the service is not configured because its
+	// proxyRole differs from the role of the proxy.
+	Applied *bool `json:"applied,omitempty"`
+}
+
+// ParametersResponse describes the parameters accepted by the reconfigure endpoint.
+type ParametersResponse struct {
+	Parameters  []string
+	Constraints []actions.Constraint
+}
+
+// BatchResponse is returned when services are reconfigured in a batch. Generation is the last applied generation.
+type BatchResponse struct {
+	Status           string
+	Message          string
+	Generation       int64
+	ReloadDeferredMs int64    `json:",omitempty"`
+	Warnings         []string `json:",omitempty"`
+}
+
+// ValidateResponse aggregates the pre-flight checks of a service. Status is NOK if any of the checks failed.
+type ValidateResponse struct {
+	Status string
+	Checks []actions.Check
+}
+
+// StackResponse lists the services removed, or that would be removed during a dry run, together with their stack.
+type StackResponse struct {
+	Status   string
+	Message  string
+	DryRun   bool
+	Services []string
+}
+
+// RemoveResponse is returned by the remove endpoint when the request contains more than one service. Status is NOK if
+// any of the services that exist could not be removed.
+type RemoveResponse struct {
+	Status     string
+	Message    string
+	Distribute bool
+	Services   []RemovedServiceResponse `json:",omitempty"`
+}
+
+// RemovedServiceResponse is the result of removing a single service of a remove request. The Code is the status the
+// request would have returned for that service alone. Unknown services have the code 404.
+type RemovedServiceResponse struct {
+	ServiceName string
+	Status      string
+	Code        int
+	Message     string `json:",omitempty"`
+}
+
+// ImportResponse lists the services mapped from an HAProxy configuration and the parts of the configuration that
+// could not be mapped. Status is NOK if any of the services could not be imported.
+type ImportResponse struct {
+	Status   string
+	Message  string
+	DryRun   bool
+	Services []ImportedServiceResponse
+	Unmapped []proxy.UnmappedSection
+}
+
+// ImportedServiceResponse holds the reconfigure parameters of a service mapped from the backend. Error is set if the
+// service is invalid or could not be reconfigured.
+type ImportedServiceResponse struct {
+	Backend    string
+	Parameters ServiceParameters
+	Error      string `json:",omitempty"`
+}
+
+// CertRemoveResponse holds the number of certificates left after a certificate was removed.
+type CertRemoveResponse struct {
+	Status         string
+	Message        string
+	Certs          int
+	FailedReplicas []string `json:",omitempty"`
+}
+
+// EffectiveServiceResponse holds a service with the defaults of the proxy applied and the source (request, profile or
+// default) of each of its parameters.
+type EffectiveServiceResponse struct {
+	Status  string
+	Message string
+	Service *ServiceParameters `json:",omitempty"`
+	Sources map[string]string  `json:",omitempty"`
+}
+
+// StatusResponse is returned by the endpoints that do not operate on a single service.
+type StatusResponse struct {
+	Status  string
+	Message string
+}
+
+type UserParameters struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func newResponse(sr actions.ServiceReconfigure) Response {
+	return Response{
+		Status:                "OK",
+		ServiceName:           sr.GetDisplayName(),
+		AclName:               sr.AclName,
+		ServiceColor:          sr.ServiceColor,
+		ServicePath:           sr.ServicePath,
+		ServiceDomain:         sr.ServiceDomain,
+		ServiceCert:           sr.ServiceCert,
+		OutboundHostname:      sr.OutboundHostname,
+		ConsulTemplateFePath:  sr.ConsulTemplateFePath,
+		ConsulTemplateBePath:  sr.ConsulTemplateBePath,
+		PathType:              sr.PathType,
+		SkipCheck:             sr.SkipCheck,
+		Mode:                  sr.Mode,
+		Port:                  sr.Port,
+		Distribute:            sr.Distribute,
+		Users:                 sr.Users,
+		ReqRepSearch:          sr.ReqRepSearch,
+		ReqRepReplace:         sr.ReqRepReplace,
+		TemplateFePath:        sr.TemplateFePath,
+		TemplateBePath:        sr.TemplateBePath,
+		ServiceAddress:        sr.ServiceAddress,
+		ColorAddresses:        sr.ColorAddresses,
+		DcAddresses:           sr.DcAddresses,
+		CorsOrigins:           sr.CorsOrigins,
+		CorsMethods:           sr.CorsMethods,
+		CorsHeaders:           sr.CorsHeaders,
+		InternalOnly:          sr.InternalOnly,
+		CheckGrpc:             sr.CheckGrpc,
+		Profile:               sr.Profile,
+		TracingSampleRate:     sr.TracingSampleRate,
+		CanaryHeader:          sr.CanaryHeader,
+		ServiceDescription:    sr.ServiceDescription,
+		Owner:                 sr.Owner,
+		TimeoutServer:         sr.TimeoutServer,
+		TimeoutTunnel:         sr.TimeoutTunnel,
+		TimeoutHttpRequest:    sr.TimeoutHttpRequest,
+		TimeoutQueue:          sr.TimeoutQueue,
+		TimeoutConnect:        sr.TimeoutConnect,
+		TimeoutClient:         sr.TimeoutClient,
+		StackName:             sr.StackName,
+		AllowMissingHost:      sr.AllowMissingHost,
+		UseDomainMap:          sr.UseDomainMap,
+		LetsEncrypt:           sr.LetsEncrypt,
+		ProxyRole:             sr.ProxyRole,
+		ClientCertVerify:      sr.ClientCertVerify,
+		ClientCertCaFile:      sr.ClientCertCaFile,
+		TcpDestinations:       sr.TcpDestinations,
+		HttpDestinations:      sr.HttpDestinations,
+		Maintenance:           sr.Maintenance,
+		MaintenanceWindow:     sr.MaintenanceWindow,
+		CertName:              sr.CertName,
+		HttpReuse:             sr.HttpReuse,
+		PoolMaxConn:           sr.PoolMaxConn,
+		PoolPurgeDelay:        sr.PoolPurgeDelay,
+		PostReloadHook:        sr.PostReloadHook,
+		SrcPort:               sr.SrcPort,
+		HttpsOnly:             sr.HttpsOnly,
+		HttpsPort:             sr.HttpsPort,
+		SslVerifyNone:         sr.SslVerifyNone,
+		SslCaCert:             sr.SslCaCert,
+		SessionType:           sr.SessionType,
+		CookieName:            sr.CookieName,
+		Balance:               sr.Balance,
+		ConnectionMode:        sr.ConnectionMode,
+		ReqMode:               sr.ReqMode,
+		ServiceDomainMatchAll: sr.ServiceDomainMatchAll,
+		RedirectFromDomain:    sr.RedirectFromDomain,
+		AllowedMethods:        sr.AllowedMethods,
+		DeniedMethods:         sr.DeniedMethods,
+		DenyHttp:              sr.DenyHttp,
+		AddReqHeader:          sr.AddReqHeader,
+		SetReqHeader:          sr.SetReqHeader,
+		DelReqHeader:          sr.DelReqHeader,
+		AddResHeader:          sr.AddResHeader,
+		DelResHeader:          sr.DelResHeader,
+		ForwardedProto:        sr.ForwardedProto,
+		CompressionAlgo:       sr.CompressionAlgo,
+		CompressionType:       sr.CompressionType,
+		ReqRateLimit:          sr.ReqRateLimit,
+		ReqRatePeriod:         sr.ReqRatePeriod,
+		AllowedSourceIPs:      sr.AllowedSourceIPs,
+		BackendExtra:          sr.BackendExtra,
+		FrontendExtra:         sr.FrontendExtra,
+		CheckPath:             sr.CheckPath,
+		CheckMethod:           sr.CheckMethod,
+		CheckInterval:         sr.CheckInterval,
+		CheckRise:             sr.CheckRise,
+		CheckFall:             sr.CheckFall,
+		CheckTimeout:          sr.CheckTimeout,
+		HttpCheckExpect:       sr.HttpCheckExpect,
+		BackupHostname:        sr.BackupHostname,
+		BackupPort:            sr.BackupPort,
+		Weight:                sr.Weight,
+		ColorWeights:          sr.ColorWeights,
+		MaxConn:               sr.MaxConn,
+		Retries:               sr.Retries,
+		Redispatch:            sr.Redispatch,
+		RedirectWhenHttpProto: sr.RedirectWhenHttpProto,
+	}
+}
+
+func newResponseV2(status, message string, errs []actions.ParameterError, sr actions.ServiceReconfigure) ResponseV2 {
+	p := ServiceParameters{
+		ServiceName:           sr.GetDisplayName(),
+		AclName:               sr.AclName,
+		ServiceColor:          sr.ServiceColor,
+		ServicePath:           []string{},
+		ServiceDomain:         []string{},
+		ServiceCert:           sr.ServiceCert,
+		OutboundHostname:      sr.OutboundHostname,
+		ConsulTemplateFePath:  sr.ConsulTemplateFePath,
+		ConsulTemplateBePath:  sr.ConsulTemplateBePath,
+		PathType:              sr.PathType,
+		SkipCheck:             sr.SkipCheck,
+		Mode:                  sr.Mode,
+		Port:                  sr.Port,
+		Distribute:            sr.Distribute,
+		Users:                 []UserParameters{},
+		ReqRepSearch:          sr.ReqRepSearch,
+		ReqRepReplace:         sr.ReqRepReplace,
+		TemplateFePath:        sr.TemplateFePath,
+		TemplateBePath:        sr.TemplateBePath,
+		ServiceAddress:        sr.ServiceAddress,
+		ColorAddresses:        map[string]string{},
+		DcAddresses:           map[string]string{},
+		CorsOrigins:           []string{},
+		CorsMethods:           []string{},
+		CorsHeaders:           []string{},
+		InternalOnly:          sr.InternalOnly,
+		CheckGrpc:             sr.CheckGrpc,
+		Profile:               sr.Profile,
+		TracingSampleRate:     sr.TracingSampleRate,
+		CanaryHeader:          sr.CanaryHeader,
+		ServiceDescription:    sr.ServiceDescription,
+		Owner:                 sr.Owner,
+		TimeoutServer:         sr.TimeoutServer,
+		TimeoutTunnel:         sr.TimeoutTunnel,
+		TimeoutHttpRequest:    sr.TimeoutHttpRequest,
+		TimeoutQueue:          sr.TimeoutQueue,
+		TimeoutConnect:        sr.TimeoutConnect,
+		TimeoutClient:         sr.TimeoutClient,
+		StackName:             sr.StackName,
+		AllowMissingHost:      sr.AllowMissingHost,
+		UseDomainMap:          sr.UseDomainMap,
+		LetsEncrypt:           sr.LetsEncrypt,
+		ProxyRole:             sr.ProxyRole,
+		ClientCertVerify:      sr.ClientCertVerify,
+		ClientCertCaFile:      sr.ClientCertCaFile,
+		TcpDestinations:       []actions.TcpDestination{},
+		HttpDestinations:      []actions.HttpDestination{},
+		Maintenance:           sr.Maintenance,
+		MaintenanceWindow:     sr.MaintenanceWindow,
+		CertName:              sr.CertName,
+		HttpReuse:             sr.HttpReuse,
+		PoolMaxConn:           sr.PoolMaxConn,
+		PoolPurgeDelay:        sr.PoolPurgeDelay,
+		PostReloadHook:        sr.PostReloadHook,
+		SrcPort:               sr.SrcPort,
+		HttpsOnly:             sr.HttpsOnly,
+		HttpsPort:             sr.HttpsPort,
+		SslVerifyNone:         sr.SslVerifyNone,
+		SslCaCert:             sr.SslCaCert,
+		SessionType:           sr.SessionType,
+		CookieName:            sr.CookieName,
+		Balance:               sr.Balance,
+		ConnectionMode:        sr.ConnectionMode,
+		ReqMode:               sr.ReqMode,
+		ServiceDomainMatchAll: sr.ServiceDomainMatchAll,
+		RedirectFromDomain:    []string{},
+		AllowedMethods:        []string{},
+		DeniedMethods:         []string{},
+		DenyHttp:              sr.DenyHttp,
+		AddReqHeader:          []string{},
+		SetReqHeader:          []string{},
+		DelReqHeader:          []string{},
+		AddResHeader:          []string{},
+		DelResHeader:          []string{},
+		ForwardedProto:        sr.ForwardedProto,
+		CompressionAlgo:       []string{},
+		CompressionType:       []string{},
+		ReqRateLimit:          sr.ReqRateLimit,
+		ReqRatePeriod:         sr.ReqRatePeriod,
+		AllowedSourceIPs:      []string{},
+		BackendExtra:          sr.BackendExtra,
+		FrontendExtra:         sr.FrontendExtra,
+		CheckPath:             sr.CheckPath,
+		CheckMethod:           sr.CheckMethod,
+		CheckInterval:         sr.CheckInterval,
+		CheckRise:             sr.CheckRise,
+		CheckFall:             sr.CheckFall,
+		CheckTimeout:          sr.CheckTimeout,
+		HttpCheckExpect:       sr.HttpCheckExpect,
+		BackupHostname:        sr.BackupHostname,
+		BackupPort:            sr.BackupPort,
+		Weight:                sr.Weight,
+		ColorWeights:          map[string]string{},
+		MaxConn:               sr.MaxConn,
+		Retries:               sr.Retries,
+		Redispatch:            sr.Redispatch,
+		RedirectWhenHttpProto: sr.RedirectWhenHttpProto,
+	}
+	p.ServicePath = append(p.ServicePath, sr.ServicePath...)
+	p.ServiceDomain = append(p.ServiceDomain, sr.ServiceDomain...)
+	p.CorsOrigins = append(p.CorsOrigins, sr.CorsOrigins...)
+	p.CorsMethods = append(p.CorsMethods, sr.CorsMethods...)
+	p.CorsHeaders = append(p.CorsHeaders, sr.CorsHeaders...)
+	p.RedirectFromDomain = append(p.RedirectFromDomain, sr.RedirectFromDomain...)
+	p.AllowedMethods = append(p.AllowedMethods, sr.AllowedMethods...)
+	p.DeniedMethods = append(p.DeniedMethods, sr.DeniedMethods...)
+	p.AddReqHeader = append(p.AddReqHeader, sr.AddReqHeader...)
+	p.SetReqHeader = append(p.SetReqHeader, sr.SetReqHeader...)
+	p.DelReqHeader = append(p.DelReqHeader, sr.DelReqHeader...)
+	p.AddResHeader = append(p.AddResHeader, sr.AddResHeader...)
+	p.DelResHeader = append(p.DelResHeader, sr.DelResHeader...)
+	p.CompressionAlgo = append(p.CompressionAlgo, sr.CompressionAlgo...)
+	p.CompressionType = append(p.CompressionType, sr.CompressionType...)
+	p.AllowedSourceIPs = append(p.AllowedSourceIPs, sr.AllowedSourceIPs...)
+	p.TcpDestinations = append(p.TcpDestinations, sr.TcpDestinations...)
+	p.HttpDestinations = append(p.HttpDestinations, sr.HttpDestinations...)
+	for _, user := range sr.Users {
+		p.Users = append(p.Users, UserParameters{Username: user.Username, Password: user.Password})
+	}
+	for color, address := range sr.ColorAddresses {
+		p.ColorAddresses[color] = address
+	}
+	for color, weight := range sr.ColorWeights {
+		p.ColorWeights[color] = weight
+	}
+	for dc, address := range sr.DcAddresses {
+		p.DcAddresses[dc] = address
+	}
+	if errs == nil {
+		errs = []actions.ParameterError{}
+	}
+	return ResponseV2{Status: status, Message: message, Errors: errs, Parameters: p}
+}
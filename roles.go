@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"./actions"
+)
+
+// loadProxyRole sets the role of the instance to the content of PROXY_ROLE_FILE or, if it is not set, to PROXY_ROLE.
+func (m *Serve) loadProxyRole() error {
+	role := m.ProxyRole
+	if len(m.ProxyRoleFile) > 0 {
+		content, err := readFile(m.ProxyRoleFile)
+		if err != nil {
+			return fmt.Errorf("Could not read the proxy role file %s\n%s", m.ProxyRoleFile, err.Error())
+		}
+		role = strings.TrimSpace(string(content))
+	}
+	actions.SetProxyRole(role)
+	return nil
+}
+
+// reloadProxyRoleOnSignal reads PROXY_ROLE_FILE every time a SIGHUP is received and reconfigures the services whose
+// proxyRole started or stopped matching the role. The role is kept if the file cannot be read.
+var reloadProxyRoleOnSignal = func(m *Serve) {
+	c := make(chan os.Signal, 1)
+	notifySignal(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			previous := actions.GetProxyRole()
+			if err := m.loadProxyRole(); err != nil {
+				m.logPrintf(err.Error())
+			} else if role := actions.GetProxyRole(); role != previous {
+				m.logPrintf("The role of the proxy changed from %s to %s", previous, role)
+				m.applyProxyRole()
+			}
+		}
+	}()
+}
+
+// applyProxyRole withdraws the configured services whose proxyRole no longer matches the role of the instance and
+// configures the stored ones that match it now.
+func (m *Serve) applyProxyRole() {
+	services := []actions.ServiceReconfigure{}
+	for _, sr := range getServices() {
+		if !sr.MatchesProxyRole() {
+			services = append(services, sr)
+		}
+	}
+	for _, sr := range getUnappliedServices() {
+		if sr.MatchesProxyRole() {
+			services = append(services, sr)
+		}
+	}
+	for _, sr := range services {
+		if err := m.newReconfigure(m.BaseReconfigure, sr).Execute([]string{}); err != nil {
+			m.logPrintf("Could not apply the role of the proxy to the service %s\n%s", sr.ServiceName, err.Error())
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// +build !integration
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"./actions"
+	"./proxy"
+)
+
+type RolesTestSuite struct {
+	suite.Suite
+	dirs []string
+}
+
+func (s *RolesTestSuite) SetupTest() {
+	s.dirs = []string{}
+	proxy.Instance = getProxyMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return &actions.Reconfigure{BaseReconfigure: baseData, ServiceReconfigure: serviceData}
+	}
+}
+
+func (s *RolesTestSuite) TearDownTest() {
+	for _, dir := range s.dirs {
+		os.RemoveAll(dir)
+	}
+	actions.RemoveKnownService("my-service")
+	actions.RemoveUnappliedService("my-service")
+	actions.SetProxyRole("")
+}
+
+func (s *RolesTestSuite) getServe(role string) *Serve {
+	dir, _ := ioutil.TempDir("", "roles")
+	s.dirs = append(s.dirs, dir)
+	return &Serve{
+		Mode:      "swarm",
+		ProxyRole: role,
+		BaseReconfigure: actions.BaseReconfigure{
+			TemplatesPath: dir,
+			ConfigsPath:   dir,
+		},
+	}
+}
+
+// ServeHTTP
+
+func (s *RolesTestSuite) Test_ServeHTTP_ConfiguresServiceOnlyOnInstanceWithMatchingRole() {
+	url := "http://127.0.0.1:8080/v1/docker-flow-proxy/reconfigure?serviceName=my-service&servicePath=/demo&port=8080&outboundHostname=127.0.0.1&proxyRole=edge"
+	expected := map[string]bool{"edge": true, "internal": false}
+	for role, applied := range expected {
+		srv := s.getServe(role)
+		srv.loadProxyRole()
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(200, rw.Code, role)
+		_, err := os.Stat(fmt.Sprintf("%s/my-service-be.cfg", srv.TemplatesPath))
+		s.Equal(applied, err == nil, role)
+		s.Equal(applied, len(actions.GetServices()) == 1, role)
+		s.Equal(!applied, len(actions.GetUnappliedServices()) == 1, role)
+	}
+}
+
+func (s *RolesTestSuite) Test_ServeHTTP_ListsUnappliedServices_WhenUrlIsServices() {
+	getServicesOrig := getServices
+	getUnappliedServicesOrig := getUnappliedServices
+	defer func() {
+		getServices = getServicesOrig
+		getUnappliedServices = getUnappliedServicesOrig
+	}()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{{ServiceName: "service-1"}}
+	}
+	getUnappliedServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{{ServiceName: "service-2", ProxyRole: "internal"}}
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/services", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := []ServiceParameters{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Require().Len(actual, 2)
+	s.Equal("service-1", actual[0].ServiceName)
+	s.True(*actual[0].Applied)
+	s.Equal("service-2", actual[1].ServiceName)
+	s.False(*actual[1].Applied)
+}
+
+// loadProxyRole
+
+func (s *RolesTestSuite) Test_LoadProxyRole_PrefersRoleFile() {
+	readFileOrig := readFile
+	defer func() { readFile = readFileOrig }()
+	readFile = func(filename string) ([]byte, error) {
+		return []byte("internal\n"), nil
+	}
+	srv := Serve{ProxyRole: "edge", ProxyRoleFile: "/run/secrets/role"}
+
+	err := srv.loadProxyRole()
+
+	s.NoError(err)
+	s.Equal("internal", actions.GetProxyRole())
+}
+
+func (s *RolesTestSuite) Test_LoadProxyRole_ReturnsError_WhenRoleFileCannotBeRead() {
+	readFileOrig := readFile
+	defer func() { readFile = readFileOrig }()
+	readFile = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	srv := Serve{ProxyRoleFile: "/run/secrets/role"}
+
+	s.Error(srv.loadProxyRole())
+}
+
+// reloadProxyRoleOnSignal
+
+func (s *RolesTestSuite) Test_ReloadProxyRoleOnSignal_ReconfiguresServicesWhoseRoleMatchChanged() {
+	readFileOrig := readFile
+	notifySignalOrig := notifySignal
+	getServicesOrig := getServices
+	getUnappliedServicesOrig := getUnappliedServices
+	defer func() {
+		readFile = readFileOrig
+		notifySignal = notifySignalOrig
+		getServices = getServicesOrig
+		getUnappliedServices = getUnappliedServicesOrig
+	}()
+	var actualChannel chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) {
+		actualChannel = c
+	}
+	readFile = func(filename string) ([]byte, error) {
+		return []byte("internal"), nil
+	}
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{{ServiceName: "edge-service", ProxyRole: "edge"}, {ServiceName: "any-service"}}
+	}
+	getUnappliedServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{{ServiceName: "internal-service", ProxyRole: "internal"}}
+	}
+	executed := make(chan string, 10)
+	srv := NewServe(ServeDeps{
+		NewReconfigure: func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+			executed <- serviceData.ServiceName
+			return getReconfigureMock("")
+		},
+	})
+	srv.ProxyRoleFile = "/run/secrets/role"
+	actions.SetProxyRole("edge")
+
+	reloadProxyRoleOnSignal(srv)
+	actualChannel <- syscall.SIGHUP
+
+	actual := []string{}
+	for len(actual) < 2 {
+		select {
+		case name := <-executed:
+			actual = append(actual, name)
+		case <-time.After(time.Second):
+			s.Fail("The services were not reconfigured")
+			return
+		}
+	}
+	s.Equal([]string{"edge-service", "internal-service"}, actual)
+	s.Equal("internal", actions.GetProxyRole())
+}
+
+// Suite
+
+func TestRolesUnitTestSuite(t *testing.T) {
+	proxyOrig := proxy.Instance
+	newReconfigureOrig := actions.NewReconfigure
+	defer func() {
+		proxy.Instance = proxyOrig
+		actions.NewReconfigure = newReconfigureOrig
+	}()
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(RolesTestSuite))
+}
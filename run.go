@@ -1,6 +1,10 @@
 package main
 
-import haproxy "./proxy"
+import (
+	haproxy "./proxy"
+	"os"
+	"strings"
+)
 
 type Runnable interface {
 	Execute(args []string) error
@@ -15,5 +19,12 @@ var NewRun = func() Executable {
 }
 
 func (m *Run) Execute(args []string) error {
+	if address := os.Getenv("SYSLOG_LISTENER"); len(address) > 0 && syslogListener == nil {
+		l, err := startSyslogListener(address, strings.EqualFold(os.Getenv("LOG_FORMAT"), "json"))
+		if err != nil {
+			return err
+		}
+		syslogListener = l
+	}
 	return haproxy.HaProxy{}.RunCmd([]string{})
 }
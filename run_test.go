@@ -4,6 +4,8 @@ package main
 
 import (
 	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
 	"testing"
 )
 
@@ -12,7 +14,7 @@ type RunTestSuite struct {
 }
 
 func (s *RunTestSuite) SetupTest() {
-	logPrintf = func(format string, v ...interface{}) {}
+	log.SetOutput(ioutil.Discard)
 }
 
 // NewRun
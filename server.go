@@ -1,15 +1,25 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"./proxy"
 	"./server"
 	"./actions"
+	"./registry"
 )
 
 const (
@@ -22,66 +32,185 @@ type Server interface {
 }
 
 type Serve struct {
-	IP              string `short:"i" long:"ip" default:"0.0.0.0" env:"IP" description:"IP the server listens to."`
-	Mode            string `short:"m" long:"mode" env:"MODE" description:"If set to 'swarm', proxy will operate assuming that Docker service from v1.12+ is used."`
-	ListenerAddress string `short:"l" long:"listener-address" env:"LISTENER_ADDRESS" description:"The address of the Docker Flow: Swarm Listener. The address matches the name of the Swarm service (e.g. swarm-listener)"`
-	Port            string `short:"p" long:"port" default:"8080" env:"PORT" description:"Port the server listens to."`
-	ServiceName     string `short:"n" long:"service-name" default:"proxy" env:"SERVICE_NAME" description:"The name of the proxy service. It is used only when running in 'swarm' mode and must match the '--name' parameter used to launch the service."`
+	IP                      string        `short:"i" long:"ip" default:"0.0.0.0" env:"IP" description:"IP the server listens to."`
+	Mode                    string        `short:"m" long:"mode" env:"MODE" description:"If set to 'swarm', proxy will operate assuming that Docker service from v1.12+ is used."`
+	ListenerAddress         string        `short:"l" long:"listener-address" env:"LISTENER_ADDRESS" description:"The address of the Docker Flow: Swarm Listener. The address matches the name of the Swarm service (e.g. swarm-listener)"`
+	ListenerAckPath         string        `long:"listener-ack-path" default:"/v1/docker-flow-swarm-listener/notify-proxy-ack" env:"LISTENER_ACK_PATH" description:"The path of the Swarm listener successful reconfigure and remove requests are acknowledged to."`
+	Port                    string        `short:"p" long:"port" default:"8080" env:"PORT" description:"Port the server listens to."`
+	ServiceName             string        `short:"n" long:"service-name" default:"proxy" env:"SERVICE_NAME" description:"The name of the proxy service. It is used only when running in 'swarm' mode and must match the '--name' parameter used to launch the service."`
+	MigrateRegistry         bool          `long:"migrate-registry" env:"MIGRATE_REGISTRY" description:"If set to true, services stored in Consul by previous versions of the proxy are migrated to the current layout on startup."`
+	MigrateCleanup          bool          `long:"migrate-cleanup" env:"MIGRATE_CLEANUP" description:"If set to true, the legacy Consul keys are deleted after a service is migrated."`
+	ProfilesPath            string        `long:"profiles" env:"PROFILES" description:"The path to the JSON file with reconfigure profiles (e.g. /cfg/profiles.json)."`
+	HooksPath               string        `long:"hooks" env:"HOOKS_FILE" description:"The path to the JSON file with the commands services can run after reloads through postReloadHook (e.g. /cfg/hooks.json)."`
+	ApiTokensPath           string        `long:"api-tokens" env:"API_TOKENS" description:"The path to the JSON file with API tokens and their scopes (e.g. /run/secrets/tokens.json)."`
+	QueueSize               int           `long:"api-queue-size" default:"100" env:"API_QUEUE_SIZE" description:"The number of reconfigure and remove requests that can wait to be processed. Requests beyond it are rejected with the status 503. Set to 0 to disable the queue."`
+	QueueWorkers            int           `long:"api-queue-workers" default:"1" env:"API_QUEUE_WORKERS" description:"The number of queued requests processed in parallel."`
+	CertExpiryCheckInterval time.Duration `long:"cert-expiry-check-interval" default:"12h" env:"CERT_EXPIRY_CHECK_INTERVAL" description:"How often the certificates are checked for expiry. Set to 0 to disable the check."`
+	CertExpiryWarning       time.Duration `long:"cert-expiry-warning" default:"720h" env:"CERT_EXPIRY_WARNING" description:"Certificates expiring within this period are reported."`
+	AlertWebhook            string        `long:"alert-webhook" env:"ALERT_WEBHOOK" description:"The URL alerts are POSTed to as JSON (e.g. http://alertmanager-bridge:8080/alerts)."`
+	RequireHostHeader       bool          `long:"require-host-header" env:"REQUIRE_HOST_HEADER" description:"If set to true, requests without the Host header are denied with the status 400."`
+	DenyHttp10              bool          `long:"deny-http-1-0" env:"DENY_HTTP_1_0" description:"If set to true, HTTP/1.0 requests are denied with the status 400."`
+	EnableAcmeChallenges    bool          `long:"enable-acme-challenges" env:"ENABLE_ACME_CHALLENGES" description:"If set to true, the proxy answers ACME HTTP-01 challenges stored through the challenge endpoint."`
+	LetsEncryptEmail        string        `long:"lets-encrypt-email" env:"LETS_ENCRYPT_EMAIL" description:"The email of the Let's Encrypt account used to obtain certificates for the services reconfigured with letsEncrypt."`
+	LetsEncryptDirectory    string        `long:"lets-encrypt-directory" default:"https://acme-v02.api.letsencrypt.org/directory" env:"LETS_ENCRYPT_DIRECTORY" description:"The ACME directory certificates are obtained from."`
+	LetsEncryptRenewBefore  time.Duration `long:"lets-encrypt-renew-before" default:"720h" env:"LETS_ENCRYPT_RENEW_BEFORE" description:"Certificates obtained from Let's Encrypt are renewed when they expire within this period."`
+	ProxyRole               string        `long:"proxy-role" env:"PROXY_ROLE" description:"The role of the proxy instance (e.g. edge). Services reconfigured with a different proxyRole are stored but not configured."`
+	DefaultCert             string        `long:"default-cert" env:"DEFAULT_CERT" description:"The name of the certificate served to clients that do not send SNI (e.g. my-cert.pem)."`
+	ProxyRoleFile           string        `long:"proxy-role-file" env:"PROXY_ROLE_FILE" description:"The path to the file containing the role of the proxy instance. It takes precedence over PROXY_ROLE and is read again on SIGHUP."`
+	StartupVerify           bool          `long:"startup-verify" env:"STARTUP_VERIFY" description:"If set to true, the configuration created from Consul on startup is compared with the one stored by the previous run and the differences are logged."`
+	ReservedPaths           string        `long:"reserved-paths" env:"RESERVED_PATHS" description:"The comma-separated paths services cannot be reconfigured with. Defaults to the paths of the API. Set to 'none' to allow all paths."`
+	WatchCerts              bool          `long:"watch-certs" env:"WATCH_CERTS" description:"If set to true, the proxy is reloaded when the certificates in /certs are changed outside of the API."`
+	StartupConfirm          bool          `long:"startup-confirm" env:"STARTUP_CONFIRM" description:"If set to true together with STARTUP_VERIFY, a configuration that differs is not loaded until it is confirmed through the confirm-startup endpoint."`
+	OcspStapling            bool          `long:"ocsp-stapling" env:"OCSP_STAPLING" description:"If set to true, the OCSP responses of the certificates are fetched and stapled."`
+	OcspStaplingInterval    time.Duration `long:"ocsp-stapling-interval" default:"1h" env:"OCSP_STAPLING_INTERVAL" description:"How often the OCSP responses are fetched."`
 	actions.BaseReconfigure
+	migration  *registry.MigrationResult
+	generation int64
+	deps       ServeDeps
 }
 
-var serverImpl = Serve{}
-var cert server.Certer = server.NewCert("/certs")
+type Info struct {
+	Migration         *registry.MigrationResult `json:",omitempty"`
+	Generation        int64                     `json:",omitempty"`
+	Consul            *registry.ConsulStatus    `json:",omitempty"`
+	Certs             *CertExpiryStatus         `json:",omitempty"`
+	RequireHostHeader bool
+	DenyHttp10        bool
+}
 
-type Response struct {
-	Status               string
-	Message              string
-	ServiceName          string
-	AclName              string
-	ServiceColor         string
-	ServicePath          []string
-	ServiceDomain        []string
-	ServiceCert          string
-	OutboundHostname     string
-	ConsulTemplateFePath string
-	ConsulTemplateBePath string
-	PathType             string
-	SkipCheck            bool
-	Mode                 string
-	Port                 string
-	Distribute           bool
-	Users                []actions.User
-	ReqRepSearch         string
-	ReqRepReplace        string
-	TemplateFePath       string
-	TemplateBePath       string
+// BatchRequest holds the full parameters of multiple services. Parameter names match the reconfigure queries.
+type BatchRequest struct {
+	Generation int64
+	Services   []map[string]interface{}
 }
 
+// defaultReservedPaths are the paths services cannot use unless RESERVED_PATHS is set.
+var defaultReservedPaths = []string{"/v1/docker-flow-proxy", "/v2/docker-flow-proxy"}
+
+var dcNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+var canaryHeaderRegexp = regexp.MustCompile(`^[A-Za-z0-9-]+:[A-Za-z0-9_.-]+$`)
+var poolPurgeDelayRegexp = regexp.MustCompile(`^[0-9]+(us|ms|s|m|h|d)?$`)
+var checkDurationRegexp = regexp.MustCompile(`^[1-9][0-9]*(us|ms|s|m|h|d)?$`)
+var httpCheckExpectRegexp = regexp.MustCompile(`^(status|rstatus|string|rstring) [^\r\n]*[^\s]$`)
+var cookieNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+var balanceRegexp = regexp.MustCompile(`^(roundrobin|leastconn|source|uri|hdr\([A-Za-z0-9_-]+\))$`)
+
+// sectionKeywords are the keywords that start HAProxy sections.
+var sectionKeywords = []string{"global", "defaults", "frontend", "backend", "listen", "userlist", "peers", "resolvers"}
+
+// compressionAlgos are the algorithms the compressionAlgo query accepts.
+var compressionAlgos = []string{"identity", "gzip", "deflate", "raw-deflate"}
+var mimeTypeRegexp = regexp.MustCompile(`^[A-Za-z0-9!#$&^_.+-]+/[A-Za-z0-9!#$&^_.+-]+$`)
+
+var methodRegexp = regexp.MustCompile(`^[A-Z]+$`)
+var hostnameRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// consulProbeInterval is the period of the checks of the Consul addresses that failed.
+const consulProbeInterval = 30 * time.Second
+
+// batchMu makes sure that batches are applied in the order of their generations.
+var batchMu = &sync.Mutex{}
+
+var serverImpl = Serve{}
+var cert server.Certer = server.NewCert("/certs")
+
 func (m *Serve) Execute(args []string) error {
 	// TODO: Change map[string]bool{} env vars
 	if proxy.Instance == nil {
 		proxy.Instance = proxy.NewHaProxy(m.TemplatesPath, m.ConfigsPath, map[string]bool{})
 	}
-	logPrintf("Starting HAProxy")
+	m.logPrintf("Starting HAProxy")
+	if len(m.DefaultCert) > 0 {
+		proxy.SetDefaultCert(m.DefaultCert)
+	}
+	proxy.SetHostHeaderRules(m.RequireHostHeader, m.DenyHttp10)
 	m.setConsulAddresses()
+	if len(m.ConsulAddresses) > 1 {
+		startConsulProbe(m.ConsulAddresses, consulProbeInterval)
+	}
+	// Entries of the services that are no longer configured would point to missing backends
+	if _, _, err := writeDomainMap(m.ConfigsPath); err != nil {
+		m.logPrintf(err.Error())
+	}
 	NewRun().Execute([]string{})
 	address := fmt.Sprintf("%s:%s", m.IP, m.Port)
-	recon := actions.NewReconfigure(m.BaseReconfigure, actions.ServiceReconfigure{})
-	lAddr := ""
-	if len(m.ListenerAddress) > 0 {
-		lAddr = fmt.Sprintf("http://%s:8080", m.ListenerAddress)
+	m.getCert().Init()
+	if m.WatchCerts {
+		if err := startCertsWatcher(certsWatchDir); err != nil {
+			m.logPrintf(err.Error())
+		}
+	}
+	if m.CertExpiryCheckInterval > 0 {
+		startCertExpiryCheck(m.CertExpiryCheckInterval, m.CertExpiryWarning, m.AlertWebhook)
+	}
+	if m.MigrateRegistry {
+		m.migrateRegistry()
+	}
+	if len(m.ConsulAddresses) > 0 {
+		m.migrateServiceNames()
+	}
+	if len(m.ProfilesPath) > 0 {
+		if err := loadProfiles(m.ProfilesPath); err != nil {
+			return err
+		}
+	}
+	if len(m.HooksPath) > 0 {
+		if err := loadReloadHooks(m.HooksPath); err != nil {
+			return err
+		}
+	}
+	if err := m.loadProxyRole(); err != nil {
+		return err
+	}
+	if len(m.ProxyRoleFile) > 0 {
+		reloadProxyRoleOnSignal(m)
+	}
+	if m.QueueSize > 0 {
+		apiQueue = newWorkQueue(m.QueueSize, m.QueueWorkers)
+	}
+	if len(m.ApiTokensPath) > 0 {
+		if err := loadApiTokens(m.ApiTokensPath); err != nil {
+			return err
+		}
+		reloadApiTokensOnSignal(m.ApiTokensPath)
+	}
+	// Later reloads are not verified since they use the base data of the server
+	startupBase := m.BaseReconfigure
+	if m.StartupVerify {
+		startupBase.BeforeStartupReload = m.verifyStartupConfig
 	}
-	cert.Init()
-	if err := recon.ReloadAllServices(
+	recon := m.newReconfigure(startupBase, actions.ServiceReconfigure{})
+	err := recon.ReloadAllServices(
 		m.ConsulAddresses,
 		m.InstanceName,
 		m.Mode,
-		lAddr,
-	); err != nil {
+		m.getListenerAddress(),
+	)
+	if err != nil {
 		return err
 	}
-	logPrintf(`Starting "Docker Flow: Proxy"`)
-	if err := httpListenAndServe(address, m); err != nil {
+	if m.StartupVerify {
+		if err := m.saveStartupConfig(); err != nil {
+			m.logPrintf(err.Error())
+		}
+	}
+	// Services are known only after the registry is loaded. Notifications from the listener arrive later.
+	if len(m.ConsulAddresses) > 0 && len(m.getListenerAddress()) == 0 {
+		if result, err := collectGarbage(m.getGarbagePaths(), false); err != nil {
+			m.logPrintf(err.Error())
+		} else {
+			m.logPrintf("Removed %d orphaned configuration files", len(result.Orphans))
+		}
+	}
+	if len(m.LetsEncryptEmail) > 0 {
+		startLetsEncryptRenewal(m, letsEncryptRenewInterval)
+	}
+	startMaintenanceScheduler(m)
+	if m.OcspStapling && m.OcspStaplingInterval > 0 {
+		startOcspStapling(certsWatchDir, m.OcspStaplingInterval)
+	}
+	m.logPrintf(`Starting "Docker Flow: Proxy"`)
+	if err := m.listenAndServe(address, m); err != nil {
 		return err
 	}
 	return nil
@@ -89,24 +218,70 @@ func (m *Serve) Execute(args []string) error {
 
 func (m *Serve) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if !strings.EqualFold(req.URL.Path, "/v1/test") {
-		logPrintf("Processing request %s", req.URL)
+		m.logPrintf("Processing request %s", req.URL)
+	}
+	if status, msg := authorize(req); status > 0 {
+		m.logPrintf(msg)
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: msg})
+		httpWriterSetContentType(w, "application/json")
+		w.WriteHeader(status)
+		w.Write(js)
+		return
 	}
 	switch req.URL.Path {
-	case "/v1/docker-flow-proxy/reconfigure":
-		m.reconfigure(w, req)
-	case "/v1/docker-flow-proxy/remove":
+	case "/v1/docker-flow-proxy/reconfigure", "/v2/docker-flow-proxy/reconfigure":
+		if (req.Method == "POST" || req.Method == "PUT") && strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+			m.reconfigureJson(w, req)
+		} else {
+			m.reconfigure(w, req)
+		}
+	case "/v1/docker-flow-proxy/remove", "/v2/docker-flow-proxy/remove":
 		m.remove(w, req)
+	case "/v1/docker-flow-proxy/stack":
+		m.stack(w, req)
+	case "/v1/docker-flow-proxy/import-config":
+		m.importConfig(w, req)
 	case "/v1/docker-flow-proxy/config":
 		m.config(w, req)
 	case "/v1/docker-flow-proxy/cert":
 		if req.Method == "PUT" {
-			cert.Put(w, req)
+			m.getCert().Put(w, req)
+		} else if req.Method == "DELETE" {
+			m.removeCert(w, req)
 		} else {
-			logPrintf("/v1/docker-flow-proxy/cert endpoint allows only PUT requests. Your was %s", req.Method)
+			m.logPrintf("/v1/docker-flow-proxy/cert endpoint allows only PUT and DELETE requests. Your was %s", req.Method)
 			w.WriteHeader(http.StatusNotFound)
 		}
+	case "/v1/docker-flow-proxy/challenge":
+		m.challenge(w, req)
 	case "/v1/docker-flow-proxy/certs":
-		cert.GetAll(w, req)
+		if req.Method == "PUT" {
+			m.getCert().PutAll(w, req)
+		} else {
+			m.getCert().GetAll(w, req)
+		}
+	case "/v1/docker-flow-proxy/certs/expiring":
+		m.certsExpiring(w, req)
+	case "/v1/docker-flow-proxy/resync":
+		m.resync(w, req)
+	case "/v1/docker-flow-proxy/info":
+		m.info(w, req)
+	case "/v1/docker-flow-proxy/parameters":
+		m.parameters(w, req)
+	case "/v1/docker-flow-proxy/gc":
+		m.gc(w, req)
+	case "/v1/docker-flow-proxy/validate":
+		m.validate(w, req)
+	case "/v1/docker-flow-proxy/services":
+		m.services(w, req)
+	case "/v1/docker-flow-proxy/confirm-startup":
+		m.confirmStartup(w, req)
+	case "/v1/docker-flow-proxy/ping":
+		m.ping(w, req)
+	case "/metrics":
+		m.metrics(w, req)
+	case uiPath:
+		m.ui(w, req)
 	case "/v1/test", "/v2/test":
 		js, _ := json.Marshal(Response{Status: "OK"})
 		httpWriterSetContentType(w, "application/json")
@@ -116,7 +291,17 @@ func (m *Serve) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write(js)
 	default:
-		logPrintf("The endpoint %s is not supported", req.URL.Path)
+		if m.EnableAcmeChallenges && strings.HasPrefix(req.URL.Path, acmeChallengePath) {
+			m.acmeChallenge(w, req)
+			return
+		} else if strings.HasPrefix(req.URL.Path, servicesPath) && strings.HasSuffix(req.URL.Path, "/effective") {
+			m.effectiveService(w, req)
+			return
+		} else if strings.HasPrefix(req.URL.Path, uiPath+"/") {
+			m.ui(w, req)
+			return
+		}
+		m.logPrintf("The endpoint %s is not supported", req.URL.Path)
 		w.WriteHeader(http.StatusNotFound)
 	}
 }
@@ -126,166 +311,1779 @@ func (m *Serve) isValidReconf(name string, path, domain []string, templateFePath
 }
 
 func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
-	sr := actions.ServiceReconfigure{
-		ServiceName:          req.URL.Query().Get("serviceName"),
-		AclName:              req.URL.Query().Get("aclName"),
-		ServiceColor:         req.URL.Query().Get("serviceColor"),
-		ServiceCert:          req.URL.Query().Get("serviceCert"),
-		OutboundHostname:     req.URL.Query().Get("outboundHostname"),
-		ConsulTemplateFePath: req.URL.Query().Get("consulTemplateFePath"),
-		ConsulTemplateBePath: req.URL.Query().Get("consulTemplateBePath"),
-		PathType:             req.URL.Query().Get("pathType"),
-		Port:                 req.URL.Query().Get("port"),
-		Mode:                 m.Mode,
-		ReqRepSearch:         req.URL.Query().Get("reqRepSearch"),
-		ReqRepReplace:        req.URL.Query().Get("reqRepReplace"),
-		TemplateFePath:       req.URL.Query().Get("templateFePath"),
-		TemplateBePath:       req.URL.Query().Get("templateBePath"),
-	}
-	if len(req.URL.Query().Get("servicePath")) > 0 {
-		sr.ServicePath = strings.Split(req.URL.Query().Get("servicePath"), ",")
-	}
-	if len(req.URL.Query().Get("serviceDomain")) > 0 {
-		sr.ServiceDomain = strings.Split(req.URL.Query().Get("serviceDomain"), ",")
-	}
-	if len(req.URL.Query().Get("skipCheck")) > 0 {
-		sr.SkipCheck, _ = strconv.ParseBool(req.URL.Query().Get("skipCheck"))
-	}
-	if len(req.URL.Query().Get("distribute")) > 0 {
-		sr.Distribute, _ = strconv.ParseBool(req.URL.Query().Get("distribute"))
-	}
-	if len(req.URL.Query().Get("users")) > 0 {
-		users := strings.Split(req.URL.Query().Get("users"), ",")
-		for _, user := range users {
-			userPass := strings.Split(user, ":")
-			sr.Users = append(sr.Users, actions.User{Username: userPass[0], Password: userPass[1]})
-		}
+	sr := actions.DecodeParameters(actions.ReconfigureParameters, req.URL.Query())
+	sr.Mode = m.Mode
+	if len(sr.Profile) > 0 {
+		sr.ExplicitParameters = m.getExplicitParameters(req.URL.Query())
 	}
-	response := Response{
-		Status:               "OK",
-		ServiceName:          sr.ServiceName,
-		AclName:              sr.AclName,
-		ServiceColor:         sr.ServiceColor,
-		ServicePath:          sr.ServicePath,
-		ServiceDomain:        sr.ServiceDomain,
-		ServiceCert:          sr.ServiceCert,
-		OutboundHostname:     sr.OutboundHostname,
-		ConsulTemplateFePath: sr.ConsulTemplateFePath,
-		ConsulTemplateBePath: sr.ConsulTemplateBePath,
-		PathType:             sr.PathType,
-		SkipCheck:            sr.SkipCheck,
-		Mode:                 sr.Mode,
-		Port:                 sr.Port,
-		Distribute:           sr.Distribute,
-		Users:                sr.Users,
-		ReqRepSearch:         sr.ReqRepSearch,
-		ReqRepReplace:        sr.ReqRepReplace,
-		TemplateFePath:       sr.TemplateFePath,
-		TemplateBePath:       sr.TemplateBePath,
-	}
-	if m.isValidReconf(sr.ServiceName, sr.ServicePath, sr.ServiceDomain, sr.ConsulTemplateFePath) {
-		if (strings.EqualFold("service", m.Mode) || strings.EqualFold("swarm", m.Mode)) && len(sr.Port) == 0 {
-			m.writeBadRequest(w, &response, `When MODE is set to "service" or "swarm", the port query is mandatory`)
-		} else if sr.Distribute {
-			srv := server.Serve{}
-			if status, err := srv.SendDistributeRequests(req, m.Port, m.ServiceName); err != nil || status >= 300 {
-				m.writeInternalServerError(w, &response, err.Error())
-			} else {
-				response.Message = DISTRIBUTED
-				w.WriteHeader(http.StatusOK)
-			}
+	profileErr := actions.ApplyProfile(&sr)
+	response := newResponse(sr)
+	if profileErr != nil {
+		m.writeBadRequest(w, &response, profileErr.Error())
+	} else if msg, errs := m.validateParameters(sr); len(msg) > 0 {
+		response.Errors = errs
+		m.writeBadRequest(w, &response, msg)
+	} else if err := m.validateConflicts(sr); err != nil {
+		response.Status, response.Message = "NOK", err.Error()
+		w.WriteHeader(http.StatusConflict)
+	} else if warnings, err := m.checkConfigLimits(sr); err != nil {
+		m.writeUnprocessableEntity(w, &response, err.Error())
+	} else if sr.Distribute {
+		if status, err := m.getDistributor().SendDistributeRequests(req, m.Port, m.ServiceName); err != nil || status >= 300 {
+			m.writeInternalServerError(w, &response, err.Error())
 		} else {
-			if len(sr.ServiceCert) > 0 {
-				// Replace \n with proper carriage return as new lines are not supported in labels
-				sr.ServiceCert = strings.Replace(sr.ServiceCert, "\\n", "\n", -1)
-				if len(sr.ServiceDomain) > 0 {
-					cert.PutCert(sr.ServiceDomain[0], []byte(sr.ServiceCert))
-				} else {
-					cert.PutCert(sr.ServiceName, []byte(sr.ServiceCert))
-				}
+			response.Message = DISTRIBUTED
+			w.WriteHeader(http.StatusOK)
+		}
+	} else {
+		response.Warnings = warnings
+		err := m.enqueue(sr.ServiceName, func() error {
+			m.putServiceCert(&sr)
+			if err := m.newReconfigure(m.BaseReconfigure, sr).Execute([]string{}); err != nil {
+				return err
 			}
-			action := actions.NewReconfigure(m.BaseReconfigure, sr)
-			if err := action.Execute([]string{}); err != nil {
-				m.writeInternalServerError(w, &response, err.Error())
-			} else {
-				w.WriteHeader(http.StatusOK)
+			m.notifyListener("reconfigure", sr.ServiceName)
+			return nil
+		})
+		// The service stays configured on HTTP if the certificate cannot be obtained
+		if err == nil && sr.LetsEncrypt && sr.MatchesProxyRole() {
+			if letsEncryptErr := m.putLetsEncryptCert(sr); letsEncryptErr != nil {
+				m.logPrintf(letsEncryptErr.Error())
+				response.LetsEncryptError = letsEncryptErr.Error()
 			}
 		}
-	} else {
-		m.writeBadRequest(w, &response, "The following queries are mandatory: (serviceName and servicePath) or (serviceName, consulTemplateFePath, and consulTemplateBePath)")
+		if _, ok := err.(*proxy.ConfigLimitError); ok {
+			m.writeUnprocessableEntity(w, &response, err.Error())
+		} else if err == errQueueFull {
+			m.writeServiceUnavailable(w, &response, err.Error())
+		} else if err != nil {
+			m.writeInternalServerError(w, &response, err.Error())
+		} else {
+			response.Warnings = append(response.Warnings, proxy.GetConfigWarnings()...)
+			response.ReloadDeferredMs = m.getReloadDeferredMs(req)
+			// Errors are still being sampled at this point unless the sampling is disabled
+			if strings.EqualFold(req.URL.Query().Get("verbose"), "true") {
+				response.LastReload = getLastReload()
+			}
+			w.WriteHeader(http.StatusOK)
+		}
 	}
 	httpWriterSetContentType(w, "application/json")
+	w.Write(m.getResponseJson(req, response, sr))
+}
+
+// reconfigureJson reconfigures the service sent as a JSON object with the same fields as the reconfigure queries.
+// Queries take precedence over the fields of the body. Bodies with the services or the generation field are batches.
+func (m *Serve) reconfigureJson(w http.ResponseWriter, req *http.Request) {
+	query, isBatch, err := getReconfigureJsonQuery(req)
+	if isBatch {
+		// Batches report the bodies that cannot be parsed
+		m.reconfigureBatch(w, req)
+		return
+	} else if err != nil {
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: err.Error()})
+		httpWriterSetContentType(w, "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(js)
+		return
+	}
+	req.URL.RawQuery = query.Encode()
+	m.reconfigure(w, req)
+}
+
+// getReconfigureJsonQuery returns the queries of the service sent as a JSON object merged with the queries of the
+// request, which take precedence. The body is restored since it is read again by batches and sent as is by
+// distribution requests. Bodies that cannot be parsed are treated as batches.
+func getReconfigureJsonQuery(req *http.Request) (url.Values, bool, error) {
+	body, _ := ioutil.ReadAll(req.Body)
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	params := map[string]interface{}{}
+	if err := decodeJson(body, &params); err != nil {
+		return nil, true, nil
+	}
+	for key := range params {
+		// The batch fields are matched the same way json.Unmarshal matches them
+		if strings.EqualFold(key, "services") || strings.EqualFold(key, "generation") {
+			return nil, true, nil
+		}
+	}
+	query, err := getJsonQuery(params)
+	if err != nil {
+		return nil, false, err
+	}
+	for key, values := range req.URL.Query() {
+		query[key] = values
+	}
+	return query, false, nil
+}
+
+// decodeJson decodes the body keeping numbers as they were sent. Otherwise, they would be converted to floats and
+// formatted differently (e.g. 1e+06).
+func decodeJson(body []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("The body contains data after the JSON value")
+	}
+	return nil
+}
+
+// reconfigureBatch applies all the services from the request body with a single reload. Batches with a generation
+// that is not newer than the last applied one are ignored since they were delivered out of order.
+func (m *Serve) reconfigureBatch(w http.ResponseWriter, req *http.Request) {
+	response := BatchResponse{Status: "OK"}
+	status := http.StatusOK
+	batch := BatchRequest{}
+	body, _ := ioutil.ReadAll(req.Body)
+	batchMu.Lock()
+	defer batchMu.Unlock()
+	if err := decodeJson(body, &batch); err != nil {
+		response.Status, response.Message = "NOK", fmt.Sprintf("Could not parse the request body\n%s", err.Error())
+		status = http.StatusBadRequest
+	} else if batch.Generation <= 0 {
+		response.Status, response.Message = "NOK", "The generation must be a positive number"
+		status = http.StatusBadRequest
+	} else if batch.Generation <= m.generation {
+		response.Message = fmt.Sprintf("The generation %d was ignored since the generation %d is already applied", batch.Generation, m.generation)
+	} else if services, msg := m.getBatchServices(batch); len(msg) > 0 {
+		response.Status, response.Message = "NOK", msg
+		status = http.StatusBadRequest
+	} else if warnings, err := m.checkConfigLimits(services...); err != nil {
+		response.Status, response.Message = "NOK", err.Error()
+		status = http.StatusUnprocessableEntity
+	} else {
+		response.Warnings = warnings
+		for i := range services {
+			m.putServiceCert(&services[i])
+		}
+		if err := m.newReconfigureBatch(m.BaseReconfigure, services).Execute([]string{}); err != nil {
+			response.Status, response.Message = "NOK", err.Error()
+			status = http.StatusInternalServerError
+			if _, ok := err.(*proxy.ConfigLimitError); ok {
+				status = http.StatusUnprocessableEntity
+			}
+		} else {
+			response.Warnings = append(response.Warnings, proxy.GetConfigWarnings()...)
+			m.generation = batch.Generation
+			names := []string{}
+			for _, sr := range services {
+				names = append(names, sr.ServiceName)
+			}
+			m.notifyListener("reconfigure", names...)
+			response.ReloadDeferredMs = m.getReloadDeferredMs(req)
+		}
+	}
+	response.Generation = m.generation
 	js, _ := json.Marshal(response)
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(status)
 	w.Write(js)
 }
 
-func (m *Serve) writeBadRequest(w http.ResponseWriter, resp *Response, msg string) {
-	resp.Status = "NOK"
-	resp.Message = msg
-	w.WriteHeader(http.StatusBadRequest)
+// getBatchServices decodes and validates the services of the batch. Lists can be sent as JSON arrays.
+func (m *Serve) getBatchServices(batch BatchRequest) ([]actions.ServiceReconfigure, string) {
+	services := []actions.ServiceReconfigure{}
+	for i, params := range batch.Services {
+		query, err := getJsonQuery(params)
+		if err != nil {
+			return nil, fmt.Sprintf("Service %d: %s", i, err.Error())
+		}
+		sr := actions.DecodeParameters(actions.ReconfigureParameters, query)
+		sr.Mode = m.Mode
+		if len(sr.Profile) > 0 {
+			sr.ExplicitParameters = m.getExplicitParameters(query)
+		}
+		if err := actions.ApplyProfile(&sr); err != nil {
+			return nil, fmt.Sprintf("Service %d (%s): %s", i, sr.ServiceName, err.Error())
+		}
+		if msg, _ := m.validateReconfigure(sr); len(msg) > 0 {
+			return nil, fmt.Sprintf("Service %d (%s): %s", i, sr.ServiceName, msg)
+		}
+		services = append(services, sr)
+	}
+	return services, ""
 }
 
-func (m *Serve) writeInternalServerError(w http.ResponseWriter, resp *Response, msg string) {
-	resp.Status = "NOK"
-	resp.Message = msg
-	w.WriteHeader(http.StatusInternalServerError)
+// getJsonQuery converts the parameters of a service sent as JSON into queries. Values can be strings, numbers and
+// booleans. Lists can be sent as JSON arrays of them. Numbers have to be decoded as json.Number.
+func getJsonQuery(params map[string]interface{}) (url.Values, error) {
+	repeatable := map[string]bool{}
+	for _, p := range actions.ReconfigureParameters {
+		repeatable[p.Name] = p.DecodeValues != nil
+	}
+	query := url.Values{}
+	for key, value := range params {
+		items, isList := value.([]interface{})
+		if !isList {
+			items = []interface{}{value}
+		}
+		values := []string{}
+		for _, item := range items {
+			text, ok := getJsonQueryValue(item)
+			if !ok {
+				return nil, fmt.Errorf("The %s field must be a string, a number, a boolean or a list of them", key)
+			}
+			values = append(values, text)
+		}
+		if !isList || !repeatable[key] {
+			query.Set(key, strings.Join(values, ","))
+		} else if len(values) == 1 {
+			// A single value is split on commas unless it is followed by an empty one, the same way the client sends it
+			query[key] = append(values, "")
+		} else {
+			query[key] = values
+		}
+	}
+	return query, nil
 }
 
-func (m *Serve) remove(w http.ResponseWriter, req *http.Request) {
-	serviceName := req.URL.Query().Get("serviceName")
-	distribute := false
-	response := Response{
-		Status:      "OK",
-		ServiceName: serviceName,
+func getJsonQueryValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nil:
+		return "", true
 	}
-	if len(req.URL.Query().Get("distribute")) > 0 {
-		distribute, _ = strconv.ParseBool(req.URL.Query().Get("distribute"))
-		if distribute {
-			response.Distribute = distribute
-			response.Message = DISTRIBUTED
-		}
+	return "", false
+}
+
+// validate runs the pre-flight checks of a service without reconfiguring the proxy. The service is sent either as
+// reconfigure queries or as a JSON object with the same fields.
+func (m *Serve) validate(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "POST" {
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: "The validate endpoint allows only POST requests"})
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write(js)
+		return
 	}
-	if len(serviceName) == 0 {
-		response.Status = "NOK"
-		response.Message = "The serviceName query is mandatory"
-		w.WriteHeader(http.StatusBadRequest)
-	} else if distribute {
-		srv := server.Serve{}
-		if status, err := srv.SendDistributeRequests(req, m.Port, m.ServiceName); err != nil || status >= 300 {
-			m.writeInternalServerError(w, &response, err.Error())
+	query := req.URL.Query()
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		params := map[string]interface{}{}
+		body, _ := ioutil.ReadAll(req.Body)
+		err := decodeJson(body, &params)
+		if err != nil {
+			err = fmt.Errorf("Could not parse the request body\n%s", err.Error())
 		} else {
-			response.Message = DISTRIBUTED
-			w.WriteHeader(http.StatusOK)
+			query, err = getJsonQuery(params)
+		}
+		if err != nil {
+			js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: err.Error()})
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(js)
+			return
 		}
+	}
+	sr := actions.DecodeParameters(actions.ReconfigureParameters, query)
+	sr.Mode = m.Mode
+	if len(sr.Profile) > 0 {
+		sr.ExplicitParameters = m.getExplicitParameters(query)
+	}
+	messages := []string{}
+	if err := actions.ApplyProfile(&sr); err != nil {
+		messages = append(messages, err.Error())
+	} else if msg, errs := m.validateReconfigure(sr); len(errs) > 0 {
+		for _, e := range errs {
+			messages = append(messages, e.Message)
+		}
+	} else if len(msg) > 0 {
+		messages = append(messages, msg)
+	}
+	response := ValidateResponse{Status: "OK", Checks: []actions.Check{actions.NewCheck("parameters", actions.CheckFail, messages)}}
+	if len(messages) > 0 {
+		// Templates of invalid services cannot be generated
+		response.Checks = append(response.Checks, actions.NewCheck("config", actions.CheckWarn, []string{"The configuration is not checked since the parameters are invalid"}))
 	} else {
-		logPrintf("Processing remove request %s", req.URL.Path)
-		aclName := req.URL.Query().Get("aclName")
-		action := NewRemove(
-			serviceName,
-			aclName,
-			m.BaseReconfigure.ConfigsPath,
-			m.BaseReconfigure.TemplatesPath,
-			m.ConsulAddresses,
-			m.InstanceName,
-			m.Mode,
-		)
-		action.Execute([]string{})
-		w.WriteHeader(http.StatusOK)
+		response.Checks = simulate(m.BaseReconfigure, sr, getCerts())
+		response.Checks = append([]actions.Check{actions.NewCheck("parameters", actions.CheckFail, nil)}, response.Checks...)
+	}
+	status := http.StatusOK
+	for _, check := range response.Checks {
+		if check.Status == actions.CheckFail {
+			response.Status = "NOK"
+			status = http.StatusBadRequest
+		}
 	}
-	httpWriterSetContentType(w, "application/json")
 	js, _ := json.Marshal(response)
+	w.WriteHeader(status)
 	w.Write(js)
 }
 
-func (m *Serve) config(w http.ResponseWriter, req *http.Request) {
-	httpWriterSetContentType(w, "text/html")
-	out, err := proxy.Instance.ReadConfig()
+// validateReconfigure returns the reason why the service cannot be reconfigured or an empty string if it is valid.
+// Constraint violations are returned as parameter errors as well.
+func (m *Serve) validateReconfigure(sr actions.ServiceReconfigure) (string, []actions.ParameterError) {
+	if msg, errs := m.validateParameters(sr); len(msg) > 0 {
+		return msg, errs
+	} else if err := m.validateConflicts(sr); err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+// validateParameters checks the parameters of the service on their own.
+func (m *Serve) validateParameters(sr actions.ServiceReconfigure) (string, []actions.ParameterError) {
+	if sr.IsTcp() && len(sr.ServiceName) == 0 {
+		return "The serviceName query is mandatory", nil
+	} else if !sr.IsTcp() && !m.isValidReconf(sr.ServiceName, sr.ServicePath, sr.ServiceDomain, sr.ConsulTemplateFePath) {
+		return "The following queries are mandatory: (serviceName and servicePath) or (serviceName, consulTemplateFePath, and consulTemplateBePath)", nil
+	} else if err := actions.ValidateServiceName("serviceName", sr.ServiceName); err != nil {
+		return err.Error(), nil
+	} else if err := actions.ValidateServiceName("aclName", sr.AclName); err != nil {
+		return err.Error(), nil
+	} else if (strings.EqualFold("service", m.Mode) || strings.EqualFold("swarm", m.Mode)) && len(sr.Port) == 0 && len(sr.HttpsPort) == 0 {
+		return `When MODE is set to "service" or "swarm", the port or the httpsPort query is mandatory`, nil
+	} else if len(sr.HttpsPort) > 0 && !strings.EqualFold("service", m.Mode) && !strings.EqualFold("swarm", m.Mode) {
+		return `The httpsPort query can be used only when MODE is set to "service" or "swarm"`, nil
+	} else if len(sr.ColorAddresses) > 0 && len(sr.ServiceColor) > 0 && len(sr.ColorAddresses[sr.ServiceColor]) == 0 {
+		return fmt.Sprintf("The addr.%s query is mandatory when serviceColor is %s and addresses are specified per color", sr.ServiceColor, sr.ServiceColor), nil
+	} else if sr.InternalOnly && len(os.Getenv("INTERNAL_PORT")) == 0 {
+		return "The internalOnly query requires the INTERNAL_PORT environment variable to be set", nil
+	} else if err := m.validateCorsOrigins(sr.CorsOrigins); err != nil {
+		return err.Error(), nil
+	} else if err := m.validateTracingSampleRate(sr.TracingSampleRate); err != nil {
+		return err.Error(), nil
+	} else if err := m.validateCanaryHeader(sr); err != nil {
+		return err.Error(), nil
+	} else if err := m.validateDcAddresses(sr); err != nil {
+		return err.Error(), nil
+	} else if err := m.validateDomainMap(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateRedirectFromDomain(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateMethods(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateHeaders(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateCompression(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateReqRateLimit(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateAllowedSourceIPs(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateExtras(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateCheck(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateCheckTuning(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateBackupServer(sr); err != nil {
+		return err.Error(), nil
+	} else if err := m.validateWeights(sr); err != nil {
+		return err.Error(), nil
+	} else if len(sr.ForwardedProto) > 0 && !strings.EqualFold(sr.ForwardedProto, "true") && !strings.EqualFold(sr.ForwardedProto, "false") {
+		return "The forwardedProto query must be either true or false", nil
+	} else if err := m.validateTimeouts(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateConnectionReuse(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateSessionType(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateBalance(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validatePostReloadHook(sr); err != nil {
+		return err.Error(), nil
+	} else if err := m.validateClientCert(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateSslCaCert(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateServiceCertName(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateCertName(sr); err != nil {
+		return err.Error(), nil
+	} else if err := validateMaintenance(sr); err != nil {
+		return err.Error(), nil
+	} else if errs := actions.ValidateConstraints(actions.ReconfigureConstraints, sr); len(errs) > 0 {
+		messages := []string{}
+		for _, e := range errs {
+			messages = append(messages, e.Message)
+		}
+		return strings.Join(messages, "\n"), errs
+	} else if err := m.validateReqMode(sr); err != nil {
+		return err.Error(), nil
+	} else if err := m.validateHttpDestinations(sr); err != nil {
+		return err.Error(), nil
+	} else if err := m.validateTcpDestinations(sr); err != nil {
+		return err.Error(), nil
+	} else if err := m.validateSrcPort(sr); err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+// checkConfigLimits checks the paths and domains of the services and the number of services they add to the configured
+// ones against the limits returned by proxy.GetConfigLimits. The warnings of the values above the warning threshold are
+// logged and returned. The size of the configuration is checked once it is assembled.
+func (m *Serve) checkConfigLimits(services ...actions.ServiceReconfigure) ([]string, error) {
+	limits := proxy.GetConfigLimits()
+	warnings := []string{}
+	names := map[string]bool{}
+	for _, sr := range getServices() {
+		names[sr.ServiceName] = true
+	}
+	for _, sr := range services {
+		serviceWarnings, err := limits.CheckService(sr.ServiceName, len(sr.ServicePath), len(sr.ServiceDomain))
+		if err != nil {
+			return nil, err
+		}
+		warnings = append(warnings, serviceWarnings...)
+		names[sr.ServiceName] = true
+	}
+	configWarnings, err := limits.CheckConfig(len(names), 0)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-	} else {
-		w.WriteHeader(http.StatusOK)
+		return nil, err
+	}
+	warnings = append(warnings, configWarnings...)
+	for _, warning := range warnings {
+		m.logPrintf("%s", warning)
+	}
+	return warnings, nil
+}
+
+// putServiceCert stores the certificate sent together with the service.
+func (m *Serve) putServiceCert(sr *actions.ServiceReconfigure) {
+	if len(sr.ServiceCert) == 0 {
+		return
+	}
+	// Replace \n with proper carriage return as new lines are not supported in labels
+	sr.ServiceCert = strings.Replace(sr.ServiceCert, "\\n", "\n", -1)
+	certName := getServiceCertName(*sr)
+	if len(sr.ServiceDomain) > 0 {
+		if wildcardCert := getWildcardCert(sr.ServiceDomain); len(wildcardCert) > 0 {
+			m.logPrintf("The certificate of the service %s was not stored since %s already covers its domains", sr.ServiceName, wildcardCert)
+			return
+		}
+	}
+	if _, err := m.getCert().PutCert(certName, []byte(sr.ServiceCert)); err != nil {
+		m.logPrintf("Could not store the certificate of the service %s\n%s", sr.ServiceName, err.Error())
+	}
+}
+
+// getServiceCertName returns the name the certificate sent together with the service is stored under.
+func getServiceCertName(sr actions.ServiceReconfigure) string {
+	if len(sr.ServiceDomain) > 0 {
+		return sr.ServiceDomain[0]
+	}
+	return sr.ServiceName
+}
+
+// getWildcardCert returns the name of the wildcard certificate that covers the first domain if the other domains are
+// covered by existing certificates as well. The name is empty if a certificate is named after the first domain, so
+// that the certificate sent with the service replaces it.
+func getWildcardCert(domains []string) string {
+	certs := proxy.Instance.GetCerts()
+	name, wildcard := server.FindCert(certs, domains[0])
+	if !wildcard {
+		return ""
+	}
+	for _, domain := range domains[1:] {
+		if covering, _ := server.FindCert(certs, domain); len(covering) == 0 {
+			return ""
+		}
+	}
+	return name
+}
+
+// validateCorsOrigins accepts either a single asterisk or a list of origins (e.g. https://my-domain.com:8443).
+func (m *Serve) validateCorsOrigins(origins []string) error {
+	for _, origin := range origins {
+		if origin == "*" {
+			if len(origins) > 1 {
+				return fmt.Errorf("The corsOrigins query cannot combine * with other origins")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 || len(strings.Trim(u.Path, "/")) > 0 || len(u.RawQuery) > 0 {
+			return fmt.Errorf("%s is not a valid CORS origin. Origins must be in the format scheme://host[:port]", origin)
+		}
+	}
+	return nil
+}
+
+// validateTracingSampleRate accepts a share of requests between 0 and 1 (e.g. 0.25).
+func (m *Serve) validateTracingSampleRate(rate string) error {
+	if len(rate) == 0 {
+		return nil
+	}
+	if value, err := strconv.ParseFloat(rate, 64); err != nil || value < 0 || value > 1 {
+		return fmt.Errorf("The tracingSampleRate query must be a number between 0 and 1")
+	}
+	return nil
+}
+
+// validateCanaryHeader accepts a header name and a color separated with colon (e.g. X-Canary:green). If addresses are
+// specified per color, the canary color must have one.
+func (m *Serve) validateCanaryHeader(sr actions.ServiceReconfigure) error {
+	if len(sr.CanaryHeader) == 0 {
+		return nil
+	}
+	if !canaryHeaderRegexp.MatchString(sr.CanaryHeader) {
+		return fmt.Errorf("The canaryHeader query must be in the format [HEADER]:[COLOR] (e.g. X-Canary:green)")
+	}
+	color := strings.SplitN(sr.CanaryHeader, ":", 2)[1]
+	if len(sr.ColorAddresses) > 0 && len(sr.ColorAddresses[color]) == 0 {
+		return fmt.Errorf("The addr.%s query is mandatory when canaryHeader routes requests to %s", color, color)
+	}
+	return nil
+}
+
+// validateConflicts checks the service against the paths of the API and the other services. The reconfigure endpoint
+// reports these errors as conflicts.
+func (m *Serve) validateConflicts(sr actions.ServiceReconfigure) error {
+	if err := m.validateReservedPaths(sr); err != nil {
+		return err
+	}
+	return m.validateRedirectFromDomainConflicts(sr)
+}
+
+// validateReservedPaths rejects services whose paths are the paths of the API or are beneath them. Requests sent
+// to those paths through the proxy would reach the service instead of the API. Broader paths (e.g. /) are accepted
+// and regular expressions are not checked.
+func (m *Serve) validateReservedPaths(sr actions.ServiceReconfigure) error {
+	if strings.EqualFold(sr.PathType, "path_reg") {
+		return nil
+	}
+	for _, reserved := range m.getReservedPaths() {
+		for _, path := range sr.ServicePath {
+			if path == reserved || strings.HasPrefix(path, reserved+"/") {
+				return fmt.Errorf(
+					"The servicePath %s would shadow the %s API of the proxy. Set RESERVED_PATHS to change the reserved paths.",
+					path,
+					reserved,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// getReservedPaths returns the RESERVED_PATHS or, if they are not set, the base paths of the API.
+func (m *Serve) getReservedPaths() []string {
+	if len(m.ReservedPaths) == 0 {
+		return defaultReservedPaths
+	} else if strings.EqualFold(m.ReservedPaths, "none") {
+		return []string{}
+	}
+	paths := []string{}
+	for _, path := range strings.Split(m.ReservedPaths, ",") {
+		if path = strings.TrimRight(strings.TrimSpace(path), "/"); len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// validateDcAddresses accepts datacenter names that can be part of server names. Addresses are used only in the
+// swarm mode since Consul discovers the servers.
+func (m *Serve) validateDcAddresses(sr actions.ServiceReconfigure) error {
+	for dc := range sr.DcAddresses {
+		if !isSwarm(m.Mode) {
+			return fmt.Errorf("The %s%s query is supported only when MODE is set to \"service\" or \"swarm\"", actions.DcAddressPrefix, dc)
+		} else if !dcNameRegexp.MatchString(dc) {
+			return fmt.Errorf("The datacenter %s can contain only letters, digits, underscores and hyphens", dc)
+		}
+	}
+	return nil
+}
+
+// validateDomainMap rejects wildcard domains of services routed through the domain map since map lookups match whole
+// domains only.
+func (m *Serve) validateDomainMap(sr actions.ServiceReconfigure) error {
+	if !sr.UseDomainMap {
+		return nil
+	}
+	for _, domain := range sr.ServiceDomain {
+		if strings.Contains(domain, "*") {
+			return fmt.Errorf("The wildcard domain %s cannot be used together with the useDomainMap query", domain)
+		}
+	}
+	return nil
+}
+
+// validateRedirectFromDomain accepts host names other than the domains of the service. Requests are redirected to the
+// first serviceDomain so it cannot be a wildcard.
+func validateRedirectFromDomain(sr actions.ServiceReconfigure) error {
+	if len(sr.RedirectFromDomain) == 0 || len(sr.ServiceDomain) == 0 {
+		return nil
+	} else if strings.Contains(sr.ServiceDomain[0], "*") {
+		return fmt.Errorf("The first serviceDomain cannot be a wildcard when the redirectFromDomain query is used")
+	}
+	for _, domain := range sr.RedirectFromDomain {
+		if !hostnameRegexp.MatchString(domain) {
+			return fmt.Errorf("The redirectFromDomain %s is not a valid host name", domain)
+		}
+		for _, serviceDomain := range sr.ServiceDomain {
+			if matchesDomain(serviceDomain, domain) {
+				return fmt.Errorf("The redirectFromDomain %s is a domain of the service itself", domain)
+			}
+		}
+	}
+	return nil
+}
+
+// validateMethods makes sure that the methods are written in upper case since HAProxy compares them as they are.
+func validateMethods(sr actions.ServiceReconfigure) error {
+	for _, method := range append(append([]string{}, sr.AllowedMethods...), sr.DeniedMethods...) {
+		if !methodRegexp.MatchString(method) {
+			return fmt.Errorf("The method %s must be written in upper case letters", method)
+		}
+	}
+	return nil
+}
+
+// validateHeaders makes sure that the headers added or set come with values and that only names are deleted.
+func validateHeaders(sr actions.ServiceReconfigure) error {
+	if err := actions.ValidateHeaders("addReqHeader", sr.AddReqHeader, true); err != nil {
+		return err
+	} else if err := actions.ValidateHeaders("setReqHeader", sr.SetReqHeader, true); err != nil {
+		return err
+	} else if err := actions.ValidateHeaders("delReqHeader", sr.DelReqHeader, false); err != nil {
+		return err
+	} else if err := actions.ValidateHeaders("addResHeader", sr.AddResHeader, true); err != nil {
+		return err
+	}
+	return actions.ValidateHeaders("delResHeader", sr.DelResHeader, false)
+}
+
+// validateCompression accepts the algorithms supported by HAProxy and well formed MIME types.
+func validateCompression(sr actions.ServiceReconfigure) error {
+	for _, algo := range sr.CompressionAlgo {
+		if !isCompressionAlgo(algo) {
+			return fmt.Errorf("The compressionAlgo query must be one of %s", strings.Join(compressionAlgos, ", "))
+		}
+	}
+	for _, mimeType := range sr.CompressionType {
+		if !mimeTypeRegexp.MatchString(mimeType) {
+			return fmt.Errorf("The compressionType %s is not a valid MIME type", mimeType)
+		}
+	}
+	return nil
+}
+
+func isCompressionAlgo(algo string) bool {
+	for _, known := range compressionAlgos {
+		if algo == known {
+			return true
+		}
+	}
+	return false
+}
+
+// validateReqRateLimit accepts a positive number of requests and a period written as seconds or a duration.
+func validateReqRateLimit(sr actions.ServiceReconfigure) error {
+	if limit, err := strconv.Atoi(sr.ReqRateLimit); len(sr.ReqRateLimit) > 0 && (err != nil || limit < 1) {
+		return fmt.Errorf("The reqRateLimit query must be a positive number of requests")
+	} else if sr.ReqRatePeriod == -1 {
+		return fmt.Errorf("The reqRatePeriod query must be a number of seconds or a duration (e.g. 10 or 1m)")
+	}
+	return nil
+}
+
+// validateAllowedSourceIPs accepts IP addresses and CIDRs.
+func validateAllowedSourceIPs(sr actions.ServiceReconfigure) error {
+	for _, ip := range sr.AllowedSourceIPs {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			return fmt.Errorf("The allowedSourceIPs entries must be IP addresses or CIDRs (e.g. 10.0.0.0/8). The invalid entry is %s", ip)
+		}
+	}
+	return nil
+}
+
+// validateCheck makes sure that the health check path is a path and that the method and the expected response that go
+// with it are well formed.
+func validateCheck(sr actions.ServiceReconfigure) error {
+	if len(sr.CheckPath) == 0 {
+		return nil
+	} else if !strings.HasPrefix(sr.CheckPath, "/") || strings.ContainsAny(sr.CheckPath, " \t\r\n") {
+		return fmt.Errorf("The checkPath query must be a path starting with / and without spaces")
+	} else if len(sr.CheckMethod) > 0 && !methodRegexp.MatchString(sr.CheckMethod) {
+		return fmt.Errorf("The checkMethod query must be an HTTP method in upper case")
+	} else if len(sr.HttpCheckExpect) > 0 && !httpCheckExpectRegexp.MatchString(sr.HttpCheckExpect) {
+		return fmt.Errorf("The httpCheckExpect query must be status, rstatus, string or rstring followed by the expected value (e.g. string READY)")
+	}
+	return nil
+}
+
+// validateCheckTuning makes sure that checkInterval and checkTimeout are positive durations in the HAProxy format and
+// that checkRise and checkFall are positive numbers of checks.
+func validateCheckTuning(sr actions.ServiceReconfigure) error {
+	for _, d := range []struct {
+		query, value string
+	}{
+		{"checkInterval", sr.CheckInterval},
+		{"checkTimeout", sr.CheckTimeout},
+	} {
+		if len(d.value) > 0 && !checkDurationRegexp.MatchString(d.value) {
+			return fmt.Errorf("The %s query must be a positive number of milliseconds or a duration (e.g. 500ms or 5s)", d.query)
+		}
+	}
+	for _, c := range []struct {
+		query, value string
+	}{
+		{"checkRise", sr.CheckRise},
+		{"checkFall", sr.CheckFall},
+	} {
+		if count, err := strconv.Atoi(c.value); len(c.value) > 0 && (err != nil || count < 1) {
+			return fmt.Errorf("The %s query must be a positive number of checks", c.query)
+		}
+	}
+	return nil
+}
+
+// validateBackupServer makes sure that the backup server has a hostname and a port, either its own or the one of the
+// service.
+func validateBackupServer(sr actions.ServiceReconfigure) error {
+	if len(sr.BackupHostname) == 0 {
+		return nil
+	} else if !hostnameRegexp.MatchString(sr.BackupHostname) {
+		return fmt.Errorf("The backupHostname query must be a hostname or an IP address")
+	} else if port, err := strconv.Atoi(sr.BackupPort); len(sr.BackupPort) > 0 && (err != nil || port < 1 || port > 65535 || strconv.Itoa(port) != sr.BackupPort) {
+		return fmt.Errorf("The backupPort query must be a port")
+	} else if len(sr.BackupPort) == 0 && len(sr.Port) == 0 {
+		return fmt.Errorf("The backupPort query is mandatory when the port query is not set")
+	}
+	return nil
+}
+
+// validateWeights makes sure that the weights are between 1 and 256 and that the servers of the other colors can be
+// added to the backend of the service. Weights are not used by the servers of the datacenters.
+func (m *Serve) validateWeights(sr actions.ServiceReconfigure) error {
+	if (len(sr.Weight) > 0 || len(sr.ColorWeights) > 0) && len(sr.DcAddresses) > 0 {
+		return fmt.Errorf("The weight queries cannot be combined with the %s queries", actions.DcAddressPrefix)
+	} else if len(sr.Weight) > 0 && !isWeight(sr.Weight) {
+		return fmt.Errorf("The weight query must be a number between 1 and 256")
+	}
+	for color, weight := range sr.ColorWeights {
+		if !isWeight(weight) {
+			return fmt.Errorf("The %s%s query must be a number between 1 and 256", actions.ColorWeightPrefix, color)
+		} else if color == sr.ServiceColor {
+			continue
+		} else if !isSwarm(m.Mode) {
+			return fmt.Errorf("The %s%s query is supported only when MODE is set to \"service\" or \"swarm\"", actions.ColorWeightPrefix, color)
+		} else if len(sr.ColorAddresses[color]) == 0 {
+			return fmt.Errorf("The %s%s query requires the %s%s query", actions.ColorWeightPrefix, color, actions.ColorAddressPrefix, color)
+		}
+	}
+	return nil
+}
+
+func isWeight(value string) bool {
+	weight, err := strconv.Atoi(value)
+	return err == nil && weight >= 1 && weight <= 256 && strconv.Itoa(weight) == value
+}
+
+// validateExtras rejects the lines of backendExtra and frontendExtra that would start a new section and, with it, move
+// the following lines of the configuration into that section.
+func validateExtras(sr actions.ServiceReconfigure) error {
+	for _, extra := range []struct {
+		query string
+		lines []string
+	}{
+		{"backendExtra", sr.GetBackendExtraLines()},
+		{"frontendExtra", sr.GetFrontendExtraLines()},
+	} {
+		for _, line := range extra.lines {
+			keyword := strings.ToLower(strings.Fields(line)[0])
+			for _, section := range sectionKeywords {
+				if keyword == section {
+					return fmt.Errorf("The %s query cannot contain the section keyword %s", extra.query, section)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateRedirectFromDomainConflicts makes sure that the requests redirected by the service are not routed to other
+// services and that the service does not take over the domains other services redirect from.
+func (m *Serve) validateRedirectFromDomainConflicts(sr actions.ServiceReconfigure) error {
+	for _, other := range getServices() {
+		if other.ServiceName == sr.ServiceName {
+			continue
+		}
+		for _, domain := range sr.RedirectFromDomain {
+			for _, otherDomain := range append(append([]string{}, other.ServiceDomain...), other.RedirectFromDomain...) {
+				if matchesDomain(otherDomain, domain) {
+					return fmt.Errorf("The redirectFromDomain %s is used by the service %s", domain, other.ServiceName)
+				}
+			}
+		}
+		for _, domain := range other.RedirectFromDomain {
+			for _, serviceDomain := range sr.ServiceDomain {
+				if matchesDomain(serviceDomain, domain) {
+					return fmt.Errorf("The serviceDomain %s is redirected by the service %s", serviceDomain, other.ServiceName)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchesDomain tells whether the host is the domain or, if the domain starts with a wildcard, ends with it.
+func matchesDomain(domain, host string) bool {
+	if strings.HasPrefix(domain, "*") {
+		return strings.HasSuffix(strings.ToLower(host), strings.ToLower(strings.TrimLeft(domain, "*")))
+	}
+	return strings.EqualFold(domain, host)
+}
+
+// validateServiceCertName makes sure that the certificate sent together with the service can be stored under the
+// name derived from serviceDomain or serviceName.
+func validateServiceCertName(sr actions.ServiceReconfigure) error {
+	if len(sr.ServiceCert) == 0 {
+		return nil
+	} else if err := server.ValidateCertName(getServiceCertName(sr)); err != nil {
+		return fmt.Errorf("The serviceCert query cannot be stored\n%s", err.Error())
+	}
+	return nil
+}
+
+// validateCertName makes sure that the certificate the service references through certName was uploaded.
+func validateCertName(sr actions.ServiceReconfigure) error {
+	if len(sr.CertName) == 0 {
+		return nil
+	} else if err := server.ValidateCertName(sr.CertName); err != nil {
+		return err
+	} else if _, ok := getCerts()[sr.CertName]; !ok {
+		return fmt.Errorf("The certificate %s referenced by the certName query does not exist\nUpload it through the cert endpoint first", sr.CertName)
+	}
+	return nil
+}
+
+// validateMaintenance makes sure that the maintenance query is a boolean and that the maintenance window can be parsed.
+func validateMaintenance(sr actions.ServiceReconfigure) error {
+	if _, err := strconv.ParseBool(sr.Maintenance); len(sr.Maintenance) > 0 && err != nil {
+		return fmt.Errorf("The maintenance query must be either true or false")
+	} else if len(sr.MaintenanceWindow) == 0 {
+		return nil
+	} else if _, err := actions.ParseMaintenanceWindow(sr.MaintenanceWindow); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateClientCert makes sure that clientCertVerify is either required or optional and that clientCertCaFile names
+// a CA file uploaded through the cert endpoint.
+func (m *Serve) validateClientCert(sr actions.ServiceReconfigure) error {
+	if len(sr.ClientCertVerify) == 0 {
+		return nil
+	} else if !strings.EqualFold(sr.ClientCertVerify, "required") && !strings.EqualFold(sr.ClientCertVerify, "optional") {
+		return fmt.Errorf("The clientCertVerify query must be either required or optional")
+	} else if len(sr.ClientCertCaFile) == 0 {
+		return nil
+	} else if sr.ClientCertCaFile != filepath.Base(sr.ClientCertCaFile) {
+		return fmt.Errorf("The clientCertCaFile query must be a file name")
+	} else if _, err := proxy.GetCaCommonNames(sr.ClientCertCaFile); err != nil {
+		return fmt.Errorf("The CA file %s cannot be used\n%s", sr.ClientCertCaFile, err.Error())
+	}
+	return nil
+}
+
+// validateSslCaCert makes sure that sslCaCert names a CA file uploaded through the cert endpoint.
+func validateSslCaCert(sr actions.ServiceReconfigure) error {
+	if len(sr.SslCaCert) == 0 {
+		return nil
+	} else if sr.SslCaCert != filepath.Base(sr.SslCaCert) {
+		return fmt.Errorf("The sslCaCert query must be a file name")
+	} else if _, err := proxy.GetCaCommonNames(sr.SslCaCert); err != nil {
+		return fmt.Errorf("The CA file %s cannot be used\n%s", sr.SslCaCert, err.Error())
+	}
+	return nil
+}
+
+// validateReqMode makes sure that services in the tcp mode have no http groups. The queries that make sense only for
+// http are listed in actions.ReconfigureConstraints.
+func (m *Serve) validateReqMode(sr actions.ServiceReconfigure) error {
+	if len(sr.ReqMode) > 0 && !strings.EqualFold(sr.ReqMode, "http") && !sr.IsTcp() {
+		return fmt.Errorf("The reqMode query must be either http or tcp")
+	} else if !sr.IsTcp() {
+		return nil
+	} else if !strings.EqualFold("service", m.Mode) && !strings.EqualFold("swarm", m.Mode) {
+		return fmt.Errorf(`The reqMode query can be set to tcp only when MODE is set to "service" or "swarm"`)
+	} else if len(sr.HttpDestinations) > 0 {
+		return fmt.Errorf("The servicePath.N query cannot be used when reqMode is tcp")
+	}
+	return nil
+}
+
+// validateHttpDestinations makes sure that each additional http group has its own paths.
+func (m *Serve) validateHttpDestinations(sr actions.ServiceReconfigure) error {
+	if len(sr.HttpDestinations) == 0 {
+		return nil
+	} else if !strings.EqualFold("service", m.Mode) && !strings.EqualFold("swarm", m.Mode) {
+		return fmt.Errorf(`The http groups with another port or serviceDomain can be used only when MODE is set to "service" or "swarm"`)
+	} else if sr.UseDomainMap {
+		return fmt.Errorf("The http groups with another port or serviceDomain cannot be used by services with useDomainMap set to true")
+	}
+	for _, dest := range sr.HttpDestinations {
+		if len(dest.ServicePath) == 0 {
+			return fmt.Errorf("The servicePath query of each http group with another port or serviceDomain is mandatory")
+		}
+	}
+	return nil
+}
+
+// validateTcpDestinations makes sure that the source ports of the tcp groups are neither used by the proxy itself
+// nor by the other services.
+func (m *Serve) validateTcpDestinations(sr actions.ServiceReconfigure) error {
+	if len(sr.GetTcpDestinations()) == 0 {
+		return nil
+	} else if !strings.EqualFold("service", m.Mode) && !strings.EqualFold("swarm", m.Mode) {
+		return fmt.Errorf(`The tcp groups can be used only when MODE is set to "service" or "swarm"`)
+	}
+	used := map[string]bool{}
+	for _, dest := range sr.GetTcpDestinations() {
+		srcPort := strconv.Itoa(dest.SrcPort)
+		if dest.SrcPort < 1 {
+			return fmt.Errorf("The reqMode queries must be either http or tcp and the srcPort queries must be ports")
+		} else if len(dest.Port) == 0 {
+			return fmt.Errorf("The port query of the tcp group with the srcPort %s is mandatory", srcPort)
+		} else if used[srcPort] {
+			return fmt.Errorf("The srcPort %s is used by more than one tcp group", srcPort)
+		}
+		for _, port := range m.getReservedPorts() {
+			if port == srcPort {
+				return fmt.Errorf("The srcPort %s is used by the proxy", srcPort)
+			}
+		}
+		if !sr.IsTcp() && srcPort == sr.SrcPort {
+			return fmt.Errorf("The srcPort %s cannot be used by both tcp and http groups", srcPort)
+		}
+		for _, other := range getServices() {
+			if other.ServiceName != sr.ServiceName && !other.IsTcp() && other.SrcPort == srcPort {
+				return fmt.Errorf("The srcPort %s is used by the service %s in the http mode", srcPort, other.ServiceName)
+			}
+			for _, otherDest := range other.GetTcpDestinations() {
+				if other.ServiceName != sr.ServiceName && otherDest.SrcPort == dest.SrcPort {
+					return fmt.Errorf("The srcPort %s is already used by the service %s", srcPort, other.ServiceName)
+				}
+			}
+		}
+		used[srcPort] = true
+	}
+	return nil
+}
+
+// validateSrcPort makes sure that the port an http service is bound to through srcPort is used neither by the proxy
+// itself nor by the other services since the frontend bound to the port belongs to the service.
+func (m *Serve) validateSrcPort(sr actions.ServiceReconfigure) error {
+	if len(sr.SrcPort) == 0 || sr.IsTcp() {
+		return nil
+	} else if port, err := strconv.Atoi(sr.SrcPort); err != nil || port < 1 || port > 65535 || strconv.Itoa(port) != sr.SrcPort {
+		return fmt.Errorf("The srcPort query must be a port")
+	} else if !strings.EqualFold("service", m.Mode) && !strings.EqualFold("swarm", m.Mode) {
+		return fmt.Errorf(`The srcPort query can be used only when MODE is set to "service" or "swarm"`)
+	} else if sr.InternalOnly {
+		return fmt.Errorf("The srcPort query cannot be used by services with internalOnly set to true")
+	} else if sr.UseDomainMap {
+		return fmt.Errorf("The srcPort query cannot be used by services with useDomainMap set to true")
+	}
+	for _, port := range m.getReservedPorts() {
+		if port == sr.SrcPort {
+			return fmt.Errorf("The srcPort %s is used by the proxy", sr.SrcPort)
+		}
+	}
+	for _, other := range getServices() {
+		if other.ServiceName == sr.ServiceName {
+			continue
+		} else if !other.IsTcp() && other.SrcPort == sr.SrcPort {
+			return fmt.Errorf("The srcPort %s is already used by the service %s", sr.SrcPort, other.ServiceName)
+		}
+		for _, dest := range other.GetTcpDestinations() {
+			if strconv.Itoa(dest.SrcPort) == sr.SrcPort {
+				return fmt.Errorf("The srcPort %s is used by the service %s in the tcp mode", sr.SrcPort, other.ServiceName)
+			}
+		}
+	}
+	return nil
+}
+
+// getReservedPorts returns the ports the proxy binds its own frontends to.
+func (m *Serve) getReservedPorts() []string {
+	return []string{"80", "443", m.Port, os.Getenv("INTERNAL_PORT"), proxy.GetPrometheusPort()}
+}
+
+// validateTimeouts rejects timeouts that could not be decoded into seconds. The client timeout can be set only for
+// services with frontends of their own.
+func (m *Serve) validateTimeouts(sr actions.ServiceReconfigure) error {
+	query := actions.EncodeParameters(actions.ReconfigureParameters, sr)
+	for _, name := range actions.TimeoutParameters {
+		if query.Get(name) == "-1" {
+			return fmt.Errorf("The %s query must be a number of seconds or a duration (e.g. 90 or 1m30s)", name)
+		}
+	}
+	if sr.TimeoutClient > 0 && len(sr.SrcPort) == 0 && len(sr.TcpDestinations) == 0 {
+		return fmt.Errorf("The timeoutClient query requires srcPort or tcp groups since the client timeout applies to the whole frontend")
+	}
+	return nil
+}
+
+// validateConnectionReuse makes sure that httpReuse is one of the modes of HAProxy, that maxconn is a positive number
+// of connections, that retries is a number of retries, that poolMaxConn is a number of connections or -1 for no limit, that poolPurgeDelay is a duration in the HAProxy format (e.g. 500ms or 5s) and that
+// connectionMode is one of the connection modes of HAProxy.
+func validateConnectionReuse(sr actions.ServiceReconfigure) error {
+	if len(sr.HttpReuse) > 0 && !proxy.IsHttpReuseMode(sr.HttpReuse) {
+		return fmt.Errorf("The httpReuse query must be one of %s", strings.Join(proxy.HttpReuseModes, ", "))
+	} else if count, err := strconv.Atoi(sr.MaxConn); len(sr.MaxConn) > 0 && (err != nil || count < 1) {
+		return fmt.Errorf("The maxconn query must be a positive number of connections")
+	} else if count, err := strconv.Atoi(sr.Retries); len(sr.Retries) > 0 && (err != nil || count < 0) {
+		return fmt.Errorf("The retries query must be a number of retries")
+	} else if count, err := strconv.Atoi(sr.PoolMaxConn); len(sr.PoolMaxConn) > 0 && (err != nil || count < -1) {
+		return fmt.Errorf("The poolMaxConn query must be a number of connections or -1 for no limit")
+	} else if len(sr.PoolPurgeDelay) > 0 && !poolPurgeDelayRegexp.MatchString(sr.PoolPurgeDelay) {
+		return fmt.Errorf("The poolPurgeDelay query must be a duration (e.g. 500ms or 5s)")
+	} else if len(sr.ConnectionMode) > 0 && !proxy.IsConnectionMode(sr.ConnectionMode) {
+		return fmt.Errorf("The connectionMode query must be one of %s", strings.Join(proxy.ConnectionModes, ", "))
+	}
+	return nil
+}
+
+// validateSessionType makes sure that sessionType is sticky-server and that cookieName is a valid cookie name that is
+// used only by sticky services.
+func validateSessionType(sr actions.ServiceReconfigure) error {
+	if len(sr.SessionType) > 0 && !strings.EqualFold(sr.SessionType, "sticky-server") {
+		return fmt.Errorf("The sessionType query must be sticky-server")
+	} else if len(sr.CookieName) > 0 && len(sr.SessionType) == 0 {
+		return fmt.Errorf("The cookieName query requires sessionType to be set to sticky-server")
+	} else if len(sr.CookieName) > 0 && !cookieNameRegexp.MatchString(sr.CookieName) {
+		return fmt.Errorf("The cookieName query can contain only letters, digits, underscores and hyphens")
+	}
+	return nil
+}
+
+// validateBalance makes sure that balance is one of the supported load balancing algorithms of HAProxy.
+func validateBalance(sr actions.ServiceReconfigure) error {
+	if len(sr.Balance) > 0 && !balanceRegexp.MatchString(sr.Balance) {
+		return fmt.Errorf("The balance query must be one of roundrobin, leastconn, source, uri or hdr(<name>)")
+	}
+	return nil
+}
+
+// validatePostReloadHook makes sure that the postReloadHook is one of the hooks defined in HOOKS_FILE. Services cannot
+// run arbitrary commands.
+func validatePostReloadHook(sr actions.ServiceReconfigure) error {
+	if len(sr.PostReloadHook) > 0 && !proxy.IsReloadHook(sr.PostReloadHook) {
+		return fmt.Errorf(
+			"The postReloadHook %s is not defined in HOOKS_FILE. Available hooks: %s",
+			sr.PostReloadHook,
+			strings.Join(proxy.GetReloadHookNames(), ", "),
+		)
+	}
+	return nil
+}
+
+// getExplicitParameters returns the names of the reconfigure parameters present in the query.
+func (m *Serve) getExplicitParameters(query url.Values) []string {
+	explicit := []string{}
+	for _, p := range actions.ReconfigureParameters {
+		if _, ok := query[p.Name]; ok {
+			explicit = append(explicit, p.Name)
+		}
+	}
+	return explicit
+}
+
+// getReloadDeferredMs returns the milliseconds left until the reload deferred because of MIN_RELOAD_INTERVAL makes the
+// changes live. If the force query is true, the deferred reload runs immediately instead.
+func (m *Serve) getReloadDeferredMs(req *http.Request) int64 {
+	if strings.EqualFold(req.URL.Query().Get("force"), "true") {
+		if err := flushDeferredReload(); err != nil {
+			m.logPrintf(err.Error())
+		}
+		return 0
+	}
+	return int64(getDeferredReload() / time.Millisecond)
+}
+
+func (m *Serve) writeBadRequest(w http.ResponseWriter, resp *Response, msg string) {
+	resp.Status = "NOK"
+	resp.Message = msg
+	w.WriteHeader(http.StatusBadRequest)
+}
+
+func (m *Serve) writeInternalServerError(w http.ResponseWriter, resp *Response, msg string) {
+	resp.Status = "NOK"
+	resp.Message = msg
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// writeUnprocessableEntity tells the client that the request is valid but would exceed a configuration limit.
+func (m *Serve) writeUnprocessableEntity(w http.ResponseWriter, resp *Response, msg string) {
+	resp.Status = "NOK"
+	resp.Message = msg
+	w.WriteHeader(http.StatusUnprocessableEntity)
+}
+
+// writeServiceUnavailable asks the client to retry later.
+func (m *Serve) writeServiceUnavailable(w http.ResponseWriter, resp *Response, msg string) {
+	resp.Status = "NOK"
+	resp.Message = msg
+	w.Header().Set("Retry-After", strconv.Itoa(queueRetryAfter))
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// enqueue runs the operation through the queue or, if the queue is disabled, directly.
+func (m *Serve) enqueue(serviceName string, run func() error) error {
+	if apiQueue == nil {
+		return run()
+	}
+	return apiQueue.Do(serviceName, run)
+}
+
+func (m *Serve) remove(w http.ResponseWriter, req *http.Request) {
+	if names := getRemoveServiceNames(req); len(names) > 1 {
+		m.removeServices(w, req, names)
+		return
+	}
+	sr := actions.DecodeParameters(actions.RemoveParameters, req.URL.Query())
+	serviceName := sr.ServiceName
+	distribute := sr.Distribute
+	response := Response{
+		Status:      "OK",
+		ServiceName: sr.GetDisplayName(),
+	}
+	if distribute {
+		response.Distribute = distribute
+		response.Message = DISTRIBUTED
+	}
+	if len(serviceName) == 0 {
+		response.Status = "NOK"
+		response.Message = "The serviceName query is mandatory"
+		w.WriteHeader(http.StatusBadRequest)
+	} else if distribute {
+		if status, err := m.getDistributor().SendDistributeRequests(req, m.Port, m.ServiceName); err != nil || status >= 300 {
+			m.writeInternalServerError(w, &response, err.Error())
+		} else {
+			response.Message = DISTRIBUTED
+			w.WriteHeader(http.StatusOK)
+		}
+	} else {
+		m.logPrintf("Processing remove request %s", req.URL.Path)
+		action := m.newRemove(
+			serviceName,
+			sr.AclName,
+			m.BaseReconfigure.ConfigsPath,
+			m.BaseReconfigure.TemplatesPath,
+			m.ConsulAddresses,
+			m.InstanceName,
+			m.Mode,
+		)
+		if err := m.enqueue(serviceName, func() error {
+			if err := action.Execute([]string{}); err == nil {
+				m.notifyListener("remove", serviceName)
+			}
+			return nil
+		}); err == errQueueFull {
+			m.writeServiceUnavailable(w, &response, err.Error())
+		} else {
+			response.ReloadDeferredMs = m.getReloadDeferredMs(req)
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	httpWriterSetContentType(w, "application/json")
+	w.Write(m.getResponseJson(req, response, sr))
+}
+
+// removeServices removes all the services listed in the serviceName query with a single reload. Unknown services are
+// reported without failing the removal of the others.
+func (m *Serve) removeServices(w http.ResponseWriter, req *http.Request, names []string) {
+	httpWriterSetContentType(w, "application/json")
+	distribute, _ := strconv.ParseBool(req.URL.Query().Get("distribute"))
+	response := RemoveResponse{Status: "OK", Distribute: distribute}
+	status := http.StatusOK
+	for _, name := range names {
+		if len(name) == 0 {
+			response.Status, response.Message = "NOK", "The serviceName query cannot contain empty names"
+			js, _ := json.Marshal(response)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(js)
+			return
+		}
+	}
+	if distribute {
+		// The batch is forwarded as-is so that each instance removes it with a single reload
+		if code, err := m.getDistributor().SendDistributeRequests(req, m.Port, m.ServiceName); err != nil || code >= 300 {
+			response.Status, response.Message = "NOK", err.Error()
+			status = http.StatusInternalServerError
+		} else {
+			response.Message = DISTRIBUTED
+		}
+		js, _ := json.Marshal(response)
+		w.WriteHeader(status)
+		w.Write(js)
+		return
+	}
+	services := []actions.ServiceReconfigure{}
+	known := getServices()
+	for _, name := range names {
+		result := RemovedServiceResponse{ServiceName: name, Status: "OK", Code: http.StatusOK}
+		found := false
+		for _, sr := range known {
+			if sr.ServiceName == actions.CanonicalServiceName(name) {
+				services = append(services, sr)
+				found = true
+			}
+		}
+		if !found {
+			result.Status, result.Code, result.Message = "NOK", http.StatusNotFound, "The service is not configured"
+		}
+		response.Services = append(response.Services, result)
+	}
+	if len(services) > 0 {
+		m.logPrintf("Removing the services %s", strings.Join(names, ", "))
+		action := m.newRemoveServices(
+			services,
+			m.BaseReconfigure.ConfigsPath,
+			m.BaseReconfigure.TemplatesPath,
+			m.ConsulAddresses,
+			m.InstanceName,
+			m.Mode,
+		)
+		err := m.enqueue("remove/"+strings.Join(names, ","), func() error {
+			err := action.Execute([]string{})
+			// Services that were not listed as failed are removed once the proxy is reloaded
+			notRemoved := map[string]bool{}
+			if removeErr, ok := err.(*RemoveServicesError); ok {
+				for _, name := range removeErr.Services {
+					notRemoved[name] = true
+				}
+			} else if err != nil {
+				return err
+			}
+			removed := []string{}
+			for _, sr := range services {
+				if !notRemoved[sr.ServiceName] {
+					removed = append(removed, sr.ServiceName)
+				}
+			}
+			m.notifyListener("remove", removed...)
+			return err
+		})
+		failed := map[string]bool{}
+		switch removeErr := err.(type) {
+		case nil:
+		case *RemoveServicesError:
+			for _, name := range removeErr.Services {
+				failed[name] = true
+			}
+		default:
+			// The request was not processed or the proxy was not reloaded so none of the removals took effect
+			status = http.StatusInternalServerError
+			if err == errQueueFull {
+				w.Header().Set("Retry-After", strconv.Itoa(queueRetryAfter))
+				status = http.StatusServiceUnavailable
+			}
+		}
+		if err != nil {
+			response.Status, response.Message = "NOK", err.Error()
+		}
+		for i, result := range response.Services {
+			if result.Code != http.StatusOK {
+				continue
+			} else if failed[actions.CanonicalServiceName(result.ServiceName)] {
+				response.Services[i].Status, response.Services[i].Code = "NOK", http.StatusInternalServerError
+				response.Services[i].Message = "Could not remove the service"
+			} else if status != http.StatusOK {
+				response.Services[i].Status, response.Services[i].Code, response.Services[i].Message = "NOK", status, err.Error()
+			}
+		}
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// getRemoveServiceNames returns the names listed in the serviceName query. The query can be repeated and each value
+// can contain a comma-separated list. Empty names are kept so that they can be rejected.
+func getRemoveServiceNames(req *http.Request) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, value := range req.URL.Query()["serviceName"] {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if !seen[name] || len(name) == 0 {
+				names = append(names, name)
+			}
+			seen[name] = true
+		}
+	}
+	return names
+}
+
+// stack removes, or only reports when the dryRun query is true, all the services of a stack with a single reload.
+func (m *Serve) stack(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "DELETE" {
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: "The stack endpoint accepts only DELETE requests"})
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write(js)
+		return
+	}
+	name := strings.TrimSpace(req.URL.Query().Get("name"))
+	if len(name) == 0 {
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: "The name query is mandatory and cannot be empty"})
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(js)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(req.URL.Query().Get("dryRun"))
+	services := getStackServices(name)
+	response := StackResponse{Status: "OK", DryRun: dryRun, Services: []string{}}
+	for _, sr := range services {
+		response.Services = append(response.Services, sr.GetDisplayName())
+	}
+	status := http.StatusOK
+	if !dryRun && len(services) > 0 {
+		m.logPrintf("Removing the services of the stack %s", name)
+		action := m.newRemoveServices(
+			services,
+			m.BaseReconfigure.ConfigsPath,
+			m.BaseReconfigure.TemplatesPath,
+			m.ConsulAddresses,
+			m.InstanceName,
+			m.Mode,
+		)
+		if err := m.enqueue("stack/"+name, func() error {
+			return action.Execute([]string{})
+		}); err == errQueueFull {
+			response.Status, response.Message = "NOK", err.Error()
+			w.Header().Set("Retry-After", strconv.Itoa(queueRetryAfter))
+			status = http.StatusServiceUnavailable
+		} else if err != nil {
+			response.Status, response.Message = "NOK", err.Error()
+			status = http.StatusInternalServerError
+		}
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// getStackServices returns the services stored with the stack name or, since Docker names the services of a stack
+// [STACK]_[SERVICE], the ones prefixed with it.
+func getStackServices(name string) []actions.ServiceReconfigure {
+	services := []actions.ServiceReconfigure{}
+	if len(strings.TrimSpace(name)) == 0 {
+		return services
+	}
+	prefix := actions.CanonicalServiceName(strings.TrimSpace(name)) + "_"
+	for _, sr := range getServices() {
+		if strings.EqualFold(sr.StackName, name) || strings.HasPrefix(sr.ServiceName, prefix) {
+			services = append(services, sr)
+		}
+	}
+	return services
+}
+
+// challenge stores, or removes when the method is DELETE, the key authorization of an ACME HTTP-01 challenge.
+func (m *Serve) challenge(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	response := StatusResponse{Status: "OK"}
+	status := http.StatusOK
+	token := req.URL.Query().Get("token")
+	keyAuth := ""
+	ttl := acmeChallengeTTL
+	if req.Method == "PUT" {
+		body, _ := ioutil.ReadAll(req.Body)
+		req.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+		keyAuth = strings.TrimSpace(string(body))
+	}
+	if seconds, err := strconv.Atoi(req.URL.Query().Get("ttl")); err == nil && seconds > 0 {
+		ttl = time.Duration(seconds) * time.Second
+	}
+	distribute, _ := strconv.ParseBool(req.URL.Query().Get("distribute"))
+	if !m.EnableAcmeChallenges {
+		response.Status, response.Message = "NOK", "ACME challenges are disabled. Set ENABLE_ACME_CHALLENGES to true to enable them."
+		status = http.StatusNotFound
+	} else if req.Method != "PUT" && req.Method != "DELETE" {
+		response.Status, response.Message = "NOK", "The challenge endpoint accepts only PUT and DELETE requests"
+		status = http.StatusMethodNotAllowed
+	} else if !acmeTokenRegexp.MatchString(token) {
+		response.Status, response.Message = "NOK", "The token query is mandatory and can contain only letters, digits, underscores and hyphens"
+		status = http.StatusBadRequest
+	} else if req.Method == "PUT" && !strings.HasPrefix(keyAuth, token+".") {
+		response.Status, response.Message = "NOK", "The body must contain the key authorization of the token ([TOKEN].[THUMBPRINT])"
+		status = http.StatusBadRequest
+	} else if distribute {
+		if _, err := m.getDistributor().SendDistributeRequests(req, m.Port, m.ServiceName); err != nil {
+			response.Status, response.Message = "NOK", err.Error()
+			status = http.StatusInternalServerError
+		} else {
+			response.Message = DISTRIBUTED
+		}
+	} else if req.Method == "DELETE" {
+		acmeChallenges.Delete(token)
+	} else {
+		acmeChallenges.Put(token, keyAuth, ttl)
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// removeCert deletes the certificate named in the certName query and outputs the number of remaining certificates.
+// removeCertOrCa removes the CA certificate if the ca query is true and the server certificate otherwise.
+func (m *Serve) removeCertOrCa(certName string, req *http.Request) error {
+	if ca, _ := strconv.ParseBool(req.URL.Query().Get("ca")); ca {
+		return m.getCert().RemoveCa(certName)
+	}
+	return m.getCert().Remove(certName)
+}
+
+func (m *Serve) removeCert(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	response := CertRemoveResponse{Status: "OK"}
+	status := http.StatusOK
+	certName := req.URL.Query().Get("certName")
+	distribute, _ := strconv.ParseBool(req.URL.Query().Get("distribute"))
+	if len(certName) == 0 {
+		response.Status, response.Message = "NOK", "The certName query is mandatory"
+		status = http.StatusBadRequest
+	} else if err := server.ValidateCertName(certName); err != nil {
+		response.Status, response.Message = "NOK", err.Error()
+		status = http.StatusBadRequest
+	} else if distribute {
+		if code, err := m.getDistributor().SendDistributeRequests(req, m.Port, m.ServiceName); err != nil || code >= 300 {
+			response.Status, response.Message = "NOK", fmt.Sprintf("The distribute requests failed with the status %d", code)
+			status = http.StatusInternalServerError
+			if distributeErr, ok := err.(*server.DistributeError); ok {
+				// The other instances failed rather than the request
+				response.Message, response.FailedReplicas = err.Error(), distributeErr.Addresses
+				status = http.StatusBadGateway
+			} else if err != nil {
+				response.Message = err.Error()
+			}
+		} else {
+			response.Message = DISTRIBUTED
+		}
+	} else if err := m.removeCertOrCa(certName, req); os.IsNotExist(err) {
+		response.Status, response.Message = "NOK", fmt.Sprintf("The certificate %s does not exist", certName)
+		status = http.StatusNotFound
+	} else if err != nil {
+		response.Status, response.Message = "NOK", err.Error()
+		status = http.StatusInternalServerError
+	} else {
+		response.Certs = len(getCerts())
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// acmeChallenge serves the key authorization of the token from the path as plain text. HAProxy routes the challenge
+// path to this handler.
+func (m *Serve) acmeChallenge(w http.ResponseWriter, req *http.Request) {
+	keyAuth, ok := acmeChallenges.Get(strings.TrimPrefix(req.URL.Path, acmeChallengePath))
+	httpWriterSetContentType(w, "text/plain")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(keyAuth))
+}
+
+// getResponseJson returns the v1 response as is while the v2 endpoints
+// nest the request parameters under the parameters field.
+func (m *Serve) getResponseJson(req *http.Request, response Response, sr actions.ServiceReconfigure) []byte {
+	if strings.HasPrefix(req.URL.Path, "/v2/") {
+		responseV2 := newResponseV2(response.Status, response.Message, response.Errors, sr)
+		responseV2.LastReload = response.LastReload
+		responseV2.LetsEncryptError = response.LetsEncryptError
+		responseV2.ReloadDeferredMs = response.ReloadDeferredMs
+		responseV2.Warnings = response.Warnings
+		js, _ := json.Marshal(responseV2)
+		return js
+	}
+	js, _ := json.Marshal(response)
+	return js
+}
+
+func (m *Serve) config(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "text/html")
+	out, err := proxy.Instance.ReadConfig()
+	pathPrefix := req.URL.Query().Get("pathPrefix")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if !strings.EqualFold(req.URL.Query().Get("standalone"), "true") {
+		w.WriteHeader(http.StatusOK)
+	} else if len(pathPrefix) > 0 && !strings.HasPrefix(pathPrefix, "/") {
+		out = "The pathPrefix query must be an absolute path"
+		w.WriteHeader(http.StatusBadRequest)
+	} else if out, err = createStandaloneConfig(out, pathPrefix); err != nil {
+		out = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.Write([]byte(out))
+}
+
+// resync reloads the profiles and reconfigures all the services so that redefined profiles are applied.
+func (m *Serve) resync(w http.ResponseWriter, req *http.Request) {
+	response := StatusResponse{Status: "OK"}
+	status := http.StatusOK
+	if len(m.ProfilesPath) > 0 {
+		if err := loadProfiles(m.ProfilesPath); err != nil {
+			response = StatusResponse{Status: "NOK", Message: err.Error()}
+			status = http.StatusInternalServerError
+		}
+	}
+	if status == http.StatusOK {
+		recon := m.newReconfigure(m.BaseReconfigure, actions.ServiceReconfigure{})
+		if err := recon.ReloadAllServices(m.ConsulAddresses, m.InstanceName, m.Mode, m.getListenerAddress()); err != nil {
+			response = StatusResponse{Status: "NOK", Message: err.Error()}
+			status = http.StatusInternalServerError
+		}
+	}
+	js, _ := json.Marshal(response)
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+func (m *Serve) getListenerAddress() string {
+	if len(m.ListenerAddress) > 0 {
+		return fmt.Sprintf("http://%s:8080", m.ListenerAddress)
+	}
+	return ""
+}
+
+func (m *Serve) info(w http.ResponseWriter, req *http.Request) {
+	batchMu.Lock()
+	info := Info{Migration: m.migration, Generation: m.generation}
+	batchMu.Unlock()
+	if len(m.ConsulAddresses) > 0 {
+		status := getConsulStatus(m.ConsulAddresses)
+		info.Consul = &status
+	}
+	info.Certs = getCertExpiryStatus()
+	// The rules are reported as the proxy configures them
+	info.RequireHostHeader, info.DenyHttp10 = proxy.GetHostHeaderRules()
+	js, _ := json.Marshal(info)
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// services outputs the parameters of the configured services. Timeout queries suffixed with Gt or Lt (e.g.
+// timeoutServerGt=60) return only the services with overrides greater or lower than the value.
+func (m *Serve) services(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	filters, err := m.getTimeoutFilters(req.URL.Query())
+	if err != nil {
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: err.Error()})
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(js)
+		return
+	}
+	services := []ServiceParameters{}
+	configured := getServices()
+	for i, sr := range append(configured, getUnappliedServices()...) {
+		// Services of other roles follow the configured ones
+		applied := i < len(configured)
+		query := actions.EncodeParameters(actions.ReconfigureParameters, sr)
+		matches := true
+		for _, f := range filters {
+			seconds, _ := strconv.Atoi(query.Get(f.parameter))
+			if (f.greater && seconds <= f.seconds) || (!f.greater && seconds >= f.seconds) {
+				matches = false
+			}
+		}
+		if matches {
+			// Certificates and passwords are not exposed
+			sr.ServiceCert = ""
+			params := newResponseV2("OK", "", nil, sr).Parameters
+			for i := range params.Users {
+				params.Users[i].Password = ""
+			}
+			params.Applied = &applied
+			services = append(services, params)
+		}
+	}
+	js, _ := json.Marshal(services)
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// servicesPath is the prefix of the endpoints that operate on a single configured service.
+const servicesPath = "/v1/docker-flow-proxy/services/"
+
+// effectiveService outputs the service, found by its serviceName or aclName, with the defaults of the proxy applied
+// and the source of each parameter. Certificates and passwords are omitted.
+func (m *Serve) effectiveService(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	name := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, servicesPath), "/effective")
+	for _, sr := range getServices() {
+		if sr.ServiceName != actions.CanonicalServiceName(name) && sr.AclName != name {
+			continue
+		}
+		effective, sources := actions.GetEffectiveService(sr)
+		effective.ServiceCert = ""
+		params := newResponseV2("OK", "", nil, effective).Parameters
+		for i := range params.Users {
+			params.Users[i].Password = ""
+		}
+		js, _ := json.Marshal(EffectiveServiceResponse{Status: "OK", Service: &params, Sources: sources})
+		w.WriteHeader(http.StatusOK)
+		w.Write(js)
+		return
+	}
+	js, _ := json.Marshal(EffectiveServiceResponse{Status: "NOK", Message: fmt.Sprintf("The service %s is not configured", name)})
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(js)
+}
+
+type timeoutFilter struct {
+	parameter string
+	greater   bool
+	seconds   int
+}
+
+func (m *Serve) getTimeoutFilters(query url.Values) ([]timeoutFilter, error) {
+	filters := []timeoutFilter{}
+	for key := range query {
+		filter := timeoutFilter{}
+		for _, name := range actions.TimeoutParameters {
+			if key == name+"Gt" || key == name+"Lt" {
+				filter.parameter = name
+				filter.greater = strings.HasSuffix(key, "Gt")
+			}
+		}
+		if len(filter.parameter) == 0 {
+			return nil, fmt.Errorf("The %s query is not supported. Use a timeout query suffixed with Gt or Lt (e.g. timeoutServerGt)", key)
+		}
+		seconds, err := actions.ParseTimeout(query.Get(key))
+		if err != nil {
+			return nil, fmt.Errorf("The %s query is invalid\n%s", key, err.Error())
+		}
+		filter.seconds = seconds
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// metrics outputs the reload, log, queue and certificate counters, as well as the metrics of the registry and Swarm
+// listener operations, in the Prometheus text format.
+func (m *Serve) metrics(w http.ResponseWriter, req *http.Request) {
+	totals := getReloadTotals()
+	queue := getQueueStats()
+	certs := CertExpiryStatus{}
+	if status := getCertExpiryStatus(); status != nil {
+		certs = *status
+	}
+	soonestExpiry := int64(0)
+	if !certs.SoonestExpiry.IsZero() {
+		soonestExpiry = certs.SoonestExpiry.Unix()
+	}
+	metrics := []struct {
+		name  string
+		help  string
+		kind  string
+		value int64
+	}{
+		{"docker_flow_proxy_reloads_total", "Number of proxy reloads.", "counter", totals.Reloads},
+		{"docker_flow_proxy_sampled_reloads_total", "Number of proxy reloads with sampled errors.", "counter", totals.SampledReloads},
+		{"docker_flow_proxy_reload_response_errors_total", "Backend response errors observed after reloads.", "counter", totals.ResponseErrors},
+		{"docker_flow_proxy_reload_connection_errors_total", "Backend connection errors observed after reloads.", "counter", totals.ConnectionErrors},
+		{"docker_flow_proxy_deferred_reloads_total", "Number of reload requests deferred because of MIN_RELOAD_INTERVAL.", "counter", totals.DeferredReloads},
+		{"docker_flow_proxy_syslog_dropped_lines_total", "HAProxy log lines dropped because stdout could not keep up.", "counter", getDroppedSyslogLines()},
+		{"docker_flow_proxy_queue_depth", "Number of reconfigure and remove requests waiting to be processed.", "gauge", queue.Depth},
+		{"docker_flow_proxy_queue_rejected_total", "Number of requests rejected because the queue was full.", "counter", queue.Rejected},
+		{"docker_flow_proxy_queue_superseded_total", "Number of queued requests replaced by a later request for the same service.", "counter", queue.Superseded},
+		{"docker_flow_proxy_certs_expiring", "Number of certificates expiring within the warning period or already expired.", "gauge", int64(certs.Expiring)},
+		{"docker_flow_proxy_cert_soonest_expiry_timestamp_seconds", "Expiry of the certificate that expires first or 0 if there are no certificates.", "gauge", soonestExpiry},
+	}
+	out := ""
+	for _, metric := range metrics {
+		out += fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n%s %d\n", metric.name, metric.help, metric.name, metric.kind, metric.name, metric.value)
+	}
+	out += registry.RegistryMetrics.String() + actions.ListenerMetrics.String()
+	httpWriterSetContentType(w, "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(out))
+}
+
+// gc removes, or only reports when the dryRun query is true, the configuration files of unknown services.
+func (m *Serve) gc(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "POST" {
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: "The gc endpoint accepts only POST requests"})
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write(js)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(req.URL.Query().Get("dryRun"))
+	result, err := collectGarbage(m.getGarbagePaths(), dryRun)
+	if err != nil {
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: err.Error()})
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(js)
+		return
+	}
+	js, _ := json.Marshal(result)
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+func (m *Serve) getGarbagePaths() []string {
+	paths := []string{m.TemplatesPath}
+	if m.ConfigsPath != m.TemplatesPath {
+		paths = append(paths, m.ConfigsPath)
+	}
+	return paths
+}
+
+// parameters outputs the reconfigure parameters and the constraints between them.
+func (m *Serve) parameters(w http.ResponseWriter, req *http.Request) {
+	response := ParametersResponse{Parameters: []string{}, Constraints: actions.ReconfigureConstraints}
+	for _, p := range actions.ReconfigureParameters {
+		response.Parameters = append(response.Parameters, p.Name)
+	}
+	js, _ := json.Marshal(response)
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+func (m *Serve) migrateRegistry() {
+	if len(m.ConsulAddresses) == 0 {
+		m.logPrintf("Registry migration is skipped since CONSUL_ADDRESS is not set")
+		return
+	}
+	m.logPrintf("Migrating services stored in legacy Consul layouts")
+	result, err := migrateRegistry(m.ConsulAddresses, m.InstanceName, m.MigrateCleanup)
+	if err != nil {
+		m.logPrintf("Registry migration failed\n%s", err.Error())
+	}
+	m.logPrintf("\tMigrated %d services", len(result.Migrated))
+	for service, msg := range result.Failed {
+		m.logPrintf("\tCould not migrate %s\n%s", service, msg)
+	}
+	m.migration = &result
+}
+
+// migrateServiceNames moves services registered by previous versions under names that are not lower case so that
+// services whose names differ only by case are merged.
+func (m *Serve) migrateServiceNames() {
+	result, err := migrateServiceNames(m.ConsulAddresses, m.InstanceName)
+	if err != nil {
+		m.logPrintf("Service names migration failed\n%s", err.Error())
+		return
+	}
+	for _, service := range result.Migrated {
+		m.logPrintf("\tMigrated %s to its lower case name", service)
+	}
+	for service, msg := range result.Failed {
+		m.logPrintf("\tCould not migrate %s to its lower case name\n%s", service, msg)
 	}
-	w.Write([]byte(out))
 }
 
 func (m *Serve) setConsulAddresses() {
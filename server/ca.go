@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"../proxy"
+)
+
+// caNamePrefix is prepended to the names of the CA certificates stored in Consul so that they are not restored as
+// server certificates.
+const caNamePrefix = "ca/"
+
+// validateCaCert makes sure that the content contains at least one PEM encoded certificate. Private keys are not
+// required since the CA certificates are used only to verify client certificates.
+func validateCaCert(certContent []byte) error {
+	rest := certContent
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return fmt.Errorf("The CA certificate is not valid\nThe content does not contain a PEM encoded CERTIFICATE block")
+		} else if block.Type == "CERTIFICATE" {
+			return nil
+		}
+	}
+}
+
+// storeCa validates the CA certificate, stores it in proxy.CaCertsDir and reloads the proxy. Unlike server
+// certificates, CA certificates are not added to the list of certificates the proxy serves.
+func (m *Cert) storeCa(w http.ResponseWriter, certName string, certContent []byte) (string, error) {
+	if err := validateCaCert(certContent); err != nil {
+		return "", m.writeError(w, err)
+	}
+	if m.isConsulStore() {
+		if err := consulCertStore.PutCert(m.ConsulAddresses, caNamePrefix+certName, certContent); err != nil {
+			return "", m.writeError(w, err)
+		}
+	}
+	path, err := m.writeCaFile(certName, certContent)
+	if err != nil {
+		return "", m.writeError(w, err)
+	}
+	logPrintf("Stored CA certificate %s", certName)
+	proxy.Instance.CreateConfigFromTemplates()
+	proxy.Instance.Reload()
+	CertsReloaded()
+	m.writeOK(w, CertResponse{Status: "OK", Message: ""})
+	return path, nil
+}
+
+// RemoveCa deletes the CA certificate and reloads the proxy. The error satisfies os.IsNotExist if the CA certificate
+// does not exist.
+func (m *Cert) RemoveCa(certName string) error {
+	if m.isConsulStore() {
+		if err := consulCertStore.DeleteCert(m.ConsulAddresses, caNamePrefix+certName); err != nil {
+			return err
+		}
+	}
+	mu.Lock()
+	err := os.Remove(filepath.Join(proxy.CaCertsDir, certName))
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+	proxy.Instance.CreateConfigFromTemplates()
+	proxy.Instance.Reload()
+	CertsReloaded()
+	logPrintf("Removed CA certificate %s", certName)
+	return nil
+}
+
+func (m *Cert) writeCaFile(certName string, certContent []byte) (path string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(proxy.CaCertsDir, 0755); err != nil {
+		return "", err
+	}
+	path = filepath.Join(proxy.CaCertsDir, certName)
+	if f, err := os.Create(path); err != nil {
+		return "", err
+	} else {
+		defer f.Close()
+		f.Write(certContent)
+	}
+	path, _ = filepath.Abs(path)
+	return path, nil
+}
@@ -1,55 +1,232 @@
 package server
 
 import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"../proxy"
+	"../registry"
 )
 
 var mu = &sync.Mutex{}
+var certNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._*-]+$`)
+
+// consulCertStore is the store used when CERT_STORE is set to consul.
+var consulCertStore certStorer = registry.Consul{}
+
+// CertsReloaded is invoked after the proxy is reloaded with the certificates stored or removed through the API.
+var CertsReloaded = func() {}
+
+type certStorer interface {
+	PutCert(addresses []string, certName string, certContent []byte) error
+	DeleteCert(addresses []string, certName string) error
+	GetCerts(addresses []string) (map[string][]byte, error)
+}
 
 type Certer interface {
 	Put(w http.ResponseWriter, req *http.Request) (string, error)
 	PutCert(certName string, certContent []byte) (string, error)
+	PutAll(w http.ResponseWriter, req *http.Request) (CertArchiveResponse, error)
+	Remove(certName string) error
+	RemoveCa(certName string) error
 	GetAll(w http.ResponseWriter, req *http.Request) (CertResponse, error)
 	Init() error
 }
 
+// Cert holds a certificate together with the validity and the names parsed from its first PEM block. Certificates
+// that could not be parsed are listed with the reason in the Error field. Fingerprint is the hex-encoded SHA-256 of
+// the whole file so that replicas can be compared. LastStaple is the time the OCSP response of the certificate was last
+// stapled. Certificates are persisted in Consul as well if Store is set to consul. The Docker secrets in SecretsDir
+// named with the cert- prefix are imported on startup.
 type Cert struct {
 	ServicePort      string
 	ProxyServiceName string
 	CertsDir         string
 	CertContent      string
+	Store            string     `json:"-"`
+	SecretsDir       string     `json:"-"`
+	ConsulAddresses  []string   `json:"-"`
+	CommonName       string     `json:",omitempty"`
+	DNSNames         []string   `json:",omitempty"`
+	NotBefore        *time.Time `json:",omitempty"`
+	NotAfter         *time.Time `json:",omitempty"`
+	Default          bool       `json:",omitempty"`
+	Error            string     `json:",omitempty"`
+	Fingerprint      string     `json:",omitempty"`
+	Size             int        `json:",omitempty"`
+	LastStaple       *time.Time `json:",omitempty"`
 }
 
 type CertResponse struct {
-	Status  string
-	Message string
-	Certs   []Cert
+	Status         string
+	Message        string
+	Certs          []Cert
+	Pending        []PendingCert `json:",omitempty"`
+	FailedReplicas []string      `json:",omitempty"`
 }
 
+// GetAll lists the certificates. If the domain query is set, only the certificate FindCert returns for the domain is
+// listed and the status is 404 if there is none.
 func (m *Cert) GetAll(w http.ResponseWriter, req *http.Request) (CertResponse, error) {
 	pCerts := proxy.Instance.GetCerts()
+	if domain := req.URL.Query().Get("domain"); len(domain) > 0 {
+		return m.getDomainCert(w, pCerts, domain)
+	}
 	certs := []Cert{}
 	for name, content := range pCerts {
-		cert := Cert{ProxyServiceName: name, CertsDir: "/certs", CertContent: content}
-		certs = append(certs, cert)
+		certs = append(certs, ParseCert(name, content))
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].ProxyServiceName < certs[j].ProxyServiceName
+	})
+	msg := CertResponse{Status: "OK", Message: "", Certs: certs, Pending: m.getPendingCerts()}
+	m.writeOK(w, msg)
+	return msg, nil
+}
+
+func (m *Cert) getDomainCert(w http.ResponseWriter, pCerts map[string]string, domain string) (CertResponse, error) {
+	name, wildcard := FindCert(pCerts, domain)
+	if len(name) == 0 {
+		msg := CertResponse{Status: "NOK", Message: fmt.Sprintf("No certificate covers the domain %s", domain), Certs: []Cert{}}
+		httpWriterSetContentType(w, "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		js, _ := json.Marshal(msg)
+		w.Write(js)
+		return msg, nil
+	}
+	cert := ParseCert(name, pCerts[name])
+	match := "exactly"
+	if wildcard {
+		match = "through a wildcard"
+	}
+	msg := CertResponse{
+		Status:  "OK",
+		Message: fmt.Sprintf("The certificate %s matches the domain %s %s", name, domain, match),
+		Certs:   []Cert{cert},
 	}
-	msg := CertResponse{Status: "OK", Message: "", Certs: certs}
 	m.writeOK(w, msg)
 	return msg, nil
 }
 
+// validateCert makes sure that the content contains a PEM encoded certificate and the private key paired with it.
+// HAProxy refuses to start with any other content.
+func validateCert(certContent []byte) error {
+	certs, keys := 0, 0
+	rest := certContent
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		} else if block.Type == "CERTIFICATE" {
+			certs++
+		} else if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			keys++
+		}
+	}
+	if certs == 0 {
+		return fmt.Errorf("The certificate is not valid\nThe content does not contain a PEM encoded CERTIFICATE block")
+	} else if keys == 0 {
+		return fmt.Errorf("The certificate is not valid\nThe content does not contain a PEM encoded PRIVATE KEY block")
+	} else if _, err := tls.X509KeyPair(certContent, certContent); err != nil {
+		return fmt.Errorf("The certificate is not valid\n%s", err.Error())
+	}
+	return nil
+}
+
+// ParseCert returns the certificate of the proxy stored under the name together with the information parsed from its
+// content. The listing and the expiry checks both rely on it so that they cannot disagree. If CERT_ENCRYPTION_KEY is
+// set, the content is returned encrypted so that private keys are never sent in clear. The fingerprint is still the one
+// of the decrypted content so that replicas can be compared.
+func ParseCert(name, content string) Cert {
+	cert := Cert{ProxyServiceName: name, CertsDir: "/certs", CertContent: content, Default: name == proxy.GetDefaultCert()}
+	setCertInfo(&cert)
+	setCertFingerprint(&cert)
+	cert.LastStaple = getLastStaple(name)
+	if proxy.IsCertEncryptionEnabled() {
+		encrypted, _ := proxy.EncryptCert([]byte(content))
+		cert.CertContent = string(encrypted)
+	}
+	return cert
+}
+
+// setCertFingerprint sets the SHA-256 fingerprint and the size of the content.
+func setCertFingerprint(cert *Cert) {
+	sum := sha256.Sum256([]byte(cert.CertContent))
+	cert.Fingerprint = hex.EncodeToString(sum[:])
+	cert.Size = len(cert.CertContent)
+}
+
+// setCertInfo parses the first certificate of the PEM content.
+func setCertInfo(cert *Cert) {
+	rest := []byte(cert.CertContent)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			cert.Error = "The content does not contain a PEM encoded certificate"
+			return
+		} else if block.Type != "CERTIFICATE" {
+			continue
+		}
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			cert.Error = fmt.Sprintf("Could not parse the certificate\n%s", err.Error())
+			return
+		}
+		cert.CommonName = parsed.Subject.CommonName
+		cert.DNSNames = parsed.DNSNames
+		cert.NotBefore = &parsed.NotBefore
+		cert.NotAfter = &parsed.NotAfter
+		return
+	}
+}
+
+// ValidateCertName makes sure that the certificate can be stored under the name without leaving the certificates
+// directory. Only letters, digits, dots, hyphens, underscores and the asterisk of wildcard domains are allowed and the
+// name cannot start with a dot.
+func ValidateCertName(certName string) error {
+	if len(certName) == 0 || len(certName) > 255 || strings.HasPrefix(certName, ".") || !certNameRegexp.MatchString(certName) {
+		return fmt.Errorf("The certificate name %s is not valid\nIt must be a file name with only letters, digits, dots, hyphens, underscores and asterisks and cannot start with a dot", strconv.Quote(certName))
+	}
+	return nil
+}
+
+// PutCert stores the certificate unless it fails ValidateCertName or validateCert.
 func (m *Cert) PutCert(certName string, certContent []byte) (string, error) {
+	if err := ValidateCertName(certName); err != nil {
+		return "", err
+	} else if err := validateCert(certContent); err != nil {
+		return "", err
+	}
+	path, err := m.putCert(certName, certContent)
+	if err == nil {
+		// The callers reload the proxy right after the certificate is stored
+		CertsReloaded()
+	}
+	return path, err
+}
+
+func (m *Cert) putCert(certName string, certContent []byte) (string, error) {
+	if m.isConsulStore() {
+		if err := consulCertStore.PutCert(m.ConsulAddresses, certName, certContent); err != nil {
+			return "", err
+		}
+	}
 	path, err := m.writeFile(certName, certContent)
 	if err != nil {
 		return "", err
@@ -71,15 +248,37 @@ func (m *Cert) Put(w http.ResponseWriter, req *http.Request) (string, error) {
 		m.writeError(w, err)
 		return "", err
 	}
+	if ca, _ := strconv.ParseBool(req.URL.Query().Get("ca")); ca {
+		return m.storeCa(w, certName, certContent)
+	} else if part := req.URL.Query().Get("part"); len(part) > 0 {
+		return m.putPart(w, req, certName, part, certContent)
+	}
+	return m.storeCert(w, req, certName, certContent)
+}
 
-	path, err := m.PutCert(certName, certContent)
+// storeCert validates the certificate, unless the force query is true, stores it and reloads the proxy. The certificate
+// becomes the default one if the default query is true.
+func (m *Cert) storeCert(w http.ResponseWriter, req *http.Request, certName string, certContent []byte) (string, error) {
+	// Formats HAProxy accepts but Go cannot parse are stored with the force query
+	if force, _ := strconv.ParseBool(req.URL.Query().Get("force")); !force {
+		if err := validateCert(certContent); err != nil {
+			m.writeError(w, err)
+			return "", err
+		}
+	}
+	path, err := m.putCert(certName, certContent)
 	if err != nil {
 		m.writeError(w, err)
 		return "", err
 	}
+	if isDefault, _ := strconv.ParseBool(req.URL.Query().Get("default")); isDefault {
+		proxy.SetDefaultCert(certName)
+		logPrintf("The certificate %s is the default one", certName)
+	}
 
 	proxy.Instance.CreateConfigFromTemplates()
 	proxy.Instance.Reload()
+	CertsReloaded()
 
 	msg := CertResponse{Status: "OK", Message: ""}
 	m.writeOK(w, msg)
@@ -87,10 +286,49 @@ func (m *Cert) Put(w http.ResponseWriter, req *http.Request) (string, error) {
 	return path, nil
 }
 
+// Remove deletes the certificate, together with its pending parts, and reloads the proxy so that it stops using it.
+// The entry is deleted from Consul first if Store is set to consul. The error satisfies os.IsNotExist if neither the
+// certificate nor any of its parts exist.
+func (m *Cert) Remove(certName string) error {
+	// The certificate would be restored on the next start if the entry stayed in Consul
+	if m.isConsulStore() {
+		if err := consulCertStore.DeleteCert(m.ConsulAddresses, certName); err != nil {
+			return err
+		}
+	}
+	removedParts := m.removeParts(certName)
+	mu.Lock()
+	err := os.Remove(fmt.Sprintf("%s/%s", m.CertsDir, certName))
+	mu.Unlock()
+	if os.IsNotExist(err) && removedParts {
+		logPrintf("Removed the pending parts of the certificate %s", certName)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	proxy.Instance.RemoveCert(certName)
+	proxy.Instance.CreateConfigFromTemplates()
+	proxy.Instance.Reload()
+	CertsReloaded()
+	logPrintf("Removed certificate %s", certName)
+	return nil
+}
+
+// Init restores the certificates stored in Consul, if Store is set to consul, imports the certificates mounted as
+// Docker secrets and fetches the certificates from the other instances of the proxy. Secrets take precedence over the
+// certificates of the other instances. The proxy is reloaded if any certificate was restored, imported or fetched.
 func (m *Cert) Init() error {
+	m.encryptPlainCerts()
+	restored := m.restoreFromStore()
+	secrets := m.importSecrets()
+	restored = restored || len(secrets) > 0
 	dns := fmt.Sprintf("tasks.%s", m.ProxyServiceName)
 	client := &http.Client{}
 	if ips, err := lookupHost(dns); err != nil {
+		if restored {
+			proxy.Instance.CreateConfigFromTemplates()
+			proxy.Instance.Reload()
+		}
 		return err
 	} else {
 		certs := []Cert{}
@@ -111,11 +349,17 @@ func (m *Cert) Init() error {
 				}
 			}
 		}
-		if len(certs) > 0 {
-			for _, cert := range certs {
-				proxy.Instance.AddCert(cert.ProxyServiceName)
-				m.writeFile(cert.ProxyServiceName, []byte(cert.CertContent))
+		for _, cert := range certs {
+			if secrets[cert.ProxyServiceName] {
+				continue
+			} else if _, err := proxy.DecryptCert([]byte(cert.CertContent)); err != nil {
+				logPrintf("Could not fetch the certificate %s\n%s", cert.ProxyServiceName, err.Error())
+				continue
 			}
+			proxy.Instance.AddCert(cert.ProxyServiceName)
+			m.writeFile(cert.ProxyServiceName, []byte(cert.CertContent))
+		}
+		if len(certs) > 0 || restored {
 			proxy.Instance.CreateConfigFromTemplates()
 			proxy.Instance.Reload()
 		}
@@ -123,11 +367,90 @@ func (m *Cert) Init() error {
 	return nil
 }
 
+// encryptPlainCerts encrypts the certificates in CertsDir that were stored before CERT_ENCRYPTION_KEY was set. The
+// certificates that are already encrypted, the OCSP responses and hidden files are left as they are.
+func (m *Cert) encryptPlainCerts() {
+	if !proxy.IsCertEncryptionEnabled() {
+		return
+	}
+	infos, err := ioutil.ReadDir(m.CertsDir)
+	if err != nil {
+		logPrintf("Could not read the directory %s\n%s", m.CertsDir, err.Error())
+		return
+	}
+	encrypted := 0
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") || strings.HasSuffix(info.Name(), OcspExtension) {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(m.CertsDir, info.Name()))
+		if err != nil || proxy.IsEncryptedCert(content) {
+			continue
+		}
+		if _, err := m.writeFile(info.Name(), content); err != nil {
+			logPrintf("Could not encrypt the certificate %s\n%s", info.Name(), err.Error())
+			continue
+		}
+		encrypted++
+	}
+	if encrypted > 0 {
+		logPrintf("Encrypted %d certificates stored before CERT_ENCRYPTION_KEY was set", encrypted)
+	}
+}
+
+// restoreFromStore writes the certificates stored in Consul to CertsDir and returns whether any was restored. Failures
+// are only logged so that the certificates of the other instances can still be fetched.
+func (m *Cert) restoreFromStore() bool {
+	if !m.isConsulStore() {
+		return false
+	}
+	certs, err := consulCertStore.GetCerts(m.ConsulAddresses)
+	if err != nil {
+		logPrintf(err.Error())
+		return false
+	}
+	for name, content := range certs {
+		if err := ValidateCertName(strings.TrimPrefix(name, caNamePrefix)); err != nil {
+			logPrintf("Could not restore the certificate %s\n%s", name, err.Error())
+			continue
+		} else if strings.HasPrefix(name, caNamePrefix) {
+			if _, err := m.writeCaFile(strings.TrimPrefix(name, caNamePrefix), content); err != nil {
+				logPrintf("Could not restore the CA certificate %s\n%s", name, err.Error())
+			}
+			continue
+		}
+		if _, err := m.writeFile(name, content); err != nil {
+			logPrintf("Could not restore the certificate %s\n%s", name, err.Error())
+			continue
+		}
+		proxy.Instance.AddCert(name)
+	}
+	if len(certs) > 0 {
+		logPrintf("Restored %d certificates from Consul", len(certs))
+	}
+	return len(certs) > 0
+}
+
+func (m *Cert) isConsulStore() bool {
+	return strings.EqualFold(m.Store, "consul") && len(m.ConsulAddresses) > 0
+}
+
+// certRequest is the JSON body of cert requests sent with the application/json content type.
+type certRequest struct {
+	Name string `json:"name"`
+	Cert string `json:"cert"`
+}
+
 func (m *Cert) getCertFromRequest(w http.ResponseWriter, req *http.Request) (certName string, certContent []byte, err error) {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		return m.getCertFromJson(req)
+	}
 	certName = req.URL.Query().Get("certName")
 	if len(certName) == 0 {
 		err := fmt.Errorf("Query parameter certName is mandatory")
 		return "", []byte{}, err
+	} else if err := ValidateCertName(certName); err != nil {
+		return "", []byte{}, err
 	}
 	defer func() { req.Body.Close() }()
 	certContent, err = ioutil.ReadAll(req.Body)
@@ -140,22 +463,54 @@ func (m *Cert) getCertFromRequest(w http.ResponseWriter, req *http.Request) (cer
 	return certName, certContent, nil
 }
 
+// getCertFromJson decodes the name and the content of the certificate from the JSON body. Escaped new lines in the
+// content are unescaped the same way as those of the serviceCert query.
+func (m *Cert) getCertFromJson(req *http.Request) (certName string, certContent []byte, err error) {
+	defer func() { req.Body.Close() }()
+	data := certRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		return "", []byte{}, fmt.Errorf("Could not decode the JSON body\n%s", err.Error())
+	} else if len(data.Name) == 0 {
+		return "", []byte{}, fmt.Errorf("The name field of the JSON body is mandatory")
+	} else if len(data.Cert) == 0 {
+		return "", []byte{}, fmt.Errorf("The cert field of the JSON body is mandatory")
+	} else if err := ValidateCertName(data.Name); err != nil {
+		return "", []byte{}, err
+	}
+	return data.Name, []byte(strings.Replace(data.Cert, "\\n", "\n", -1)), nil
+}
+
 func (m *Cert) sendDistributeRequests(w http.ResponseWriter, req *http.Request) error {
 	_, port, err := net.SplitHostPort(req.URL.Host)
 	if err != nil {
 		port = "8080"
 	}
 	status, err := server.SendDistributeRequests(req, port, m.ProxyServiceName)
-	if err != nil {
+	if distributeErr, ok := err.(*DistributeError); ok {
+		w.WriteHeader(http.StatusBadGateway)
+		js, _ := json.Marshal(CertResponse{
+			Status:         "NOK",
+			Message:        err.Error(),
+			FailedReplicas: distributeErr.Addresses,
+		})
+		w.Write(js)
+		return err
+	} else if err != nil {
 		return m.writeError(w, err)
 	} else if status >= 300 {
 		msg := fmt.Sprintf("Distribution request failed with status %d", status)
 		return m.writeError(w, fmt.Errorf(msg))
 	}
+	m.writeOK(w, CertResponse{Status: "OK", Message: "Distributed to all instances"})
 	return nil
 }
 
+// writeFile stores the certificate in CertsDir. It is encrypted first if CERT_ENCRYPTION_KEY is set.
 func (m *Cert) writeFile(certName string, certContent []byte) (path string, err error) {
+	certContent, err = proxy.EncryptCert(certContent)
+	if err != nil {
+		return "", err
+	}
 	mu.Lock()
 	defer mu.Unlock()
 	if f, err := os.Create(fmt.Sprintf("%s/%s", m.CertsDir, certName)); err != nil {
@@ -175,7 +530,12 @@ func (m *Cert) writeOK(w http.ResponseWriter, msg interface{}) {
 }
 
 func (m *Cert) writeError(w http.ResponseWriter, err error) error {
-	w.WriteHeader(http.StatusBadRequest)
+	return m.writeErrorStatus(w, http.StatusBadRequest, err)
+}
+
+func (m *Cert) writeErrorStatus(w http.ResponseWriter, status int, err error) error {
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(status)
 	js, _ := json.Marshal(CertResponse{
 		Status:  "NOK",
 		Message: err.Error(),
@@ -185,9 +545,20 @@ func (m *Cert) writeError(w http.ResponseWriter, err error) error {
 }
 
 func NewCert(certsDir string) *Cert {
+	consulAddresses := []string{}
+	if len(os.Getenv("CONSUL_ADDRESS")) > 0 {
+		consulAddresses = strings.Split(os.Getenv("CONSUL_ADDRESS"), ",")
+	}
+	secretsDir := "/run/secrets"
+	if len(os.Getenv("CERT_SECRETS_DIR")) > 0 {
+		secretsDir = os.Getenv("CERT_SECRETS_DIR")
+	}
 	return &Cert{
 		CertsDir:         certsDir,
 		ProxyServiceName: os.Getenv("SERVICE_NAME"),
 		ServicePort:      "8080",
+		Store:            os.Getenv("CERT_STORE"),
+		SecretsDir:       secretsDir,
+		ConsulAddresses:  consulAddresses,
 	}
 }
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"../proxy"
+)
+
+// CertArchiveResponse lists the outcome of each certificate found in an archive.
+type CertArchiveResponse struct {
+	Status  string
+	Message string
+	Files   []CertArchiveFile
+}
+
+// CertArchiveFile is the outcome of a single entry of an archive. Entries that are not stored have the reason in
+// the Error field.
+type CertArchiveFile struct {
+	Name   string
+	Status string
+	Error  string `json:",omitempty"`
+}
+
+// maxCertArchiveSize is the number of bytes of the archive sent to PutAll that are read. Larger archives are rejected.
+var maxCertArchiveSize int64 = 10 << 20
+
+// maxCertArchiveFileSize is the number of bytes each file of an archive can have once it is decompressed.
+var maxCertArchiveFileSize int64 = 1 << 20
+
+// maxCertArchiveContentSize is the number of bytes all the files of an archive can have once they are decompressed.
+var maxCertArchiveContentSize int64 = 10 << 20
+
+// certArchiveTooLargeError is returned when an archive or its content exceeds the limits.
+type certArchiveTooLargeError struct {
+	message string
+}
+
+func (e certArchiveTooLargeError) Error() string {
+	return e.message
+}
+
+// archiveCert is a certificate read from an archive together with the content it replaces, if any.
+type archiveCert struct {
+	name     string
+	content  []byte
+	previous []byte
+}
+
+// PutAll stores all the valid certificates of the tar (optionally gzipped) or zip archive sent in the body and reloads
+// the proxy once. Invalid entries are reported without stopping the upload. If any valid certificate cannot be
+// stored, those stored before it are reverted and the proxy is not reloaded so that it never runs with only some of
+// them. Archives that exceed the size limits are rejected with the status 413.
+func (m *Cert) PutAll(w http.ResponseWriter, req *http.Request) (CertArchiveResponse, error) {
+	if distribute, _ := strconv.ParseBool(req.URL.Query().Get("distribute")); distribute {
+		return CertArchiveResponse{}, m.sendDistributeRequests(w, req)
+	}
+	defer func() { req.Body.Close() }()
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxCertArchiveSize+1))
+	if err != nil {
+		return CertArchiveResponse{}, m.writeError(w, err)
+	} else if int64(len(body)) > maxCertArchiveSize {
+		err := fmt.Errorf("The archive is larger than %d bytes", maxCertArchiveSize)
+		return CertArchiveResponse{}, m.writeErrorStatus(w, http.StatusRequestEntityTooLarge, err)
+	}
+	entries, err := readCertArchive(body)
+	if _, ok := err.(certArchiveTooLargeError); ok {
+		return CertArchiveResponse{}, m.writeErrorStatus(w, http.StatusRequestEntityTooLarge, err)
+	} else if err != nil {
+		return CertArchiveResponse{}, m.writeError(w, err)
+	} else if len(entries) == 0 {
+		return CertArchiveResponse{}, m.writeError(w, fmt.Errorf("The archive does not contain any file"))
+	}
+	response := CertArchiveResponse{Status: "OK", Files: []CertArchiveFile{}}
+	certs := []archiveCert{}
+	for _, entry := range entries {
+		file := CertArchiveFile{Name: entry.name, Status: "OK"}
+		if err := ValidateCertName(entry.name); err != nil {
+			file.Status, file.Error = "NOK", err.Error()
+		} else if err := validateCert(entry.content); err != nil {
+			file.Status, file.Error = "NOK", err.Error()
+		} else {
+			// Stored certificates are decrypted so that Consul keeps getting them in clear if they are reverted
+			if entry.previous, _ = ioutil.ReadFile(filepath.Join(m.CertsDir, entry.name)); entry.previous != nil {
+				if decrypted, err := proxy.DecryptCert(entry.previous); err == nil {
+					entry.previous = decrypted
+				}
+			}
+			certs = append(certs, entry)
+		}
+		response.Files = append(response.Files, file)
+	}
+	for i, cert := range certs {
+		if _, err := m.putCert(cert.name, cert.content); err != nil {
+			m.revertCerts(certs[:i])
+			response.Status = "NOK"
+			response.Message = fmt.Sprintf("The certificate %s could not be stored so none of the certificates were stored\n%s", cert.name, err.Error())
+			for j := range response.Files {
+				if response.Files[j].Status == "OK" {
+					response.Files[j].Status = "NOK"
+					response.Files[j].Error = "Reverted"
+				}
+			}
+			m.writeArchiveResponse(w, http.StatusInternalServerError, response)
+			return response, err
+		}
+	}
+	if len(certs) > 0 {
+		proxy.Instance.CreateConfigFromTemplates()
+		proxy.Instance.Reload()
+		CertsReloaded()
+	}
+	if failed := len(entries) - len(certs); failed > 0 {
+		response.Status = "NOK"
+		response.Message = fmt.Sprintf("%d of the %d certificates could not be stored", failed, len(entries))
+	}
+	logPrintf("Stored %d certificates from the archive", len(certs))
+	m.writeArchiveResponse(w, http.StatusOK, response)
+	return response, nil
+}
+
+// revertCerts restores the certificates replaced by the archive and removes the ones it added.
+func (m *Cert) revertCerts(certs []archiveCert) {
+	for _, cert := range certs {
+		if cert.previous != nil {
+			if _, err := m.putCert(cert.name, cert.previous); err != nil {
+				logPrintf("Could not restore the certificate %s\n%s", cert.name, err.Error())
+			}
+			continue
+		}
+		if m.isConsulStore() {
+			if err := consulCertStore.DeleteCert(m.ConsulAddresses, cert.name); err != nil {
+				logPrintf(err.Error())
+			}
+		}
+		mu.Lock()
+		os.Remove(filepath.Join(m.CertsDir, cert.name))
+		mu.Unlock()
+		proxy.Instance.RemoveCert(cert.name)
+	}
+}
+
+func (m *Cert) writeArchiveResponse(w http.ResponseWriter, status int, response CertArchiveResponse) {
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(status)
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// readCertArchive returns the regular files of the archive named after their base names. The format is detected from
+// the content so the content type of the request does not matter. Hidden files, such as those added by macOS, are
+// skipped. Files are read only up to the size limits so that small archives cannot decompress into huge ones.
+func readCertArchive(content []byte) ([]archiveCert, error) {
+	entries := []archiveCert{}
+	names := map[string]bool{}
+	total := int64(0)
+	add := func(name string, r io.Reader) error {
+		name = filepath.Base(name)
+		if strings.HasPrefix(name, ".") {
+			return nil
+		} else if names[name] {
+			return fmt.Errorf("The archive contains the file %s more than once", name)
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(r, maxCertArchiveFileSize+1))
+		if err != nil {
+			return fmt.Errorf("Could not read the file %s of the archive\n%s", name, err.Error())
+		} else if int64(len(data)) > maxCertArchiveFileSize {
+			return certArchiveTooLargeError{fmt.Sprintf("The file %s of the archive is larger than %d bytes", name, maxCertArchiveFileSize)}
+		} else if total += int64(len(data)); total > maxCertArchiveContentSize {
+			return certArchiveTooLargeError{fmt.Sprintf("The files of the archive are larger than %d bytes", maxCertArchiveContentSize)}
+		}
+		names[name] = true
+		entries = append(entries, archiveCert{name: name, content: data})
+		return nil
+	}
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the zip archive\n%s", err.Error())
+		}
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("Could not read the file %s of the archive\n%s", f.Name, err.Error())
+			}
+			err = add(f.Name, rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return entries, nil
+	}
+	var r io.Reader = bytes.NewReader(content)
+	if bytes.HasPrefix(content, []byte{0x1f, 0x8b}) {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the gzip archive\n%s", err.Error())
+		}
+		defer gr.Close()
+		r = gr
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Could not read the tar archive\n%s", err.Error())
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if err := add(header.Name, tr); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,196 @@
+// +build !integration
+
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"../proxy"
+)
+
+type CertArchiveTestSuite struct {
+	suite.Suite
+	certsDir  string
+	proxyMock *ProxyMock
+}
+
+func (s *CertArchiveTestSuite) SetupTest() {
+	s.certsDir, _ = ioutil.TempDir("", "certs")
+	s.proxyMock = getProxyMock("")
+	proxy.Instance = s.proxyMock
+}
+
+func (s *CertArchiveTestSuite) TearDownTest() {
+	os.RemoveAll(s.certsDir)
+}
+
+func (s *CertArchiveTestSuite) getTar(files map[string]string, names ...string) []byte {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, name := range names {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg})
+		tw.Write([]byte(files[name]))
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+func (s *CertArchiveTestSuite) putAll(body []byte) (*httptest.ResponseRecorder, CertArchiveResponse) {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "http://acme.com/v1/docker-flow-proxy/certs", bytes.NewReader(body))
+	NewCert(s.certsDir).PutAll(rw, req)
+	actual := CertArchiveResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	return rw, actual
+}
+
+// PutAll
+
+func (s *CertArchiveTestSuite) Test_PutAll_StoresValidCertsAndReloadsOnce() {
+	certs := map[string]string{
+		"certs/a.pem": new(CertTestSuite).getCertAndKey(),
+		"certs/b.pem": new(CertTestSuite).getCertAndKey(),
+		"c.pem":       "THIS IS NOT A CERTIFICATE",
+		"._a.pem":     "macOS metadata",
+	}
+
+	rw, actual := s.putAll(s.getTar(certs, "certs/a.pem", "certs/b.pem", "c.pem", "._a.pem"))
+
+	s.Equal(200, rw.Code)
+	s.Equal("NOK", actual.Status)
+	s.Equal("1 of the 3 certificates could not be stored", actual.Message)
+	s.Require().Len(actual.Files, 3)
+	s.Equal(CertArchiveFile{Name: "a.pem", Status: "OK"}, actual.Files[0])
+	s.Equal(CertArchiveFile{Name: "b.pem", Status: "OK"}, actual.Files[1])
+	s.Equal("NOK", actual.Files[2].Status)
+	s.Contains(actual.Files[2].Error, "does not contain a PEM encoded CERTIFICATE block")
+	content, _ := ioutil.ReadFile(s.certsDir + "/a.pem")
+	s.Equal(certs["certs/a.pem"], string(content))
+	_, err := os.Stat(s.certsDir + "/c.pem")
+	s.True(os.IsNotExist(err))
+	s.proxyMock.AssertCalled(s.T(), "AddCert", "a.pem")
+	s.proxyMock.AssertCalled(s.T(), "AddCert", "b.pem")
+	s.proxyMock.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
+func (s *CertArchiveTestSuite) Test_PutAll_ReadsGzippedTarAndZip() {
+	cert := new(CertTestSuite).getCertAndKey()
+	gzipped := new(bytes.Buffer)
+	gw := gzip.NewWriter(gzipped)
+	gw.Write(s.getTar(map[string]string{"a.pem": cert}, "a.pem"))
+	gw.Close()
+	zipped := new(bytes.Buffer)
+	zw := zip.NewWriter(zipped)
+	f, _ := zw.Create("dir/a.pem")
+	f.Write([]byte(cert))
+	zw.Close()
+
+	for _, body := range [][]byte{gzipped.Bytes(), zipped.Bytes()} {
+		rw, actual := s.putAll(body)
+
+		s.Equal(200, rw.Code)
+		s.Equal("OK", actual.Status)
+		s.Equal([]CertArchiveFile{{Name: "a.pem", Status: "OK"}}, actual.Files)
+	}
+}
+
+func (s *CertArchiveTestSuite) Test_PutAll_RevertsStoredCertsAndDoesNotReload_WhenCertCannotBeWritten() {
+	certs := map[string]string{
+		"a.pem": new(CertTestSuite).getCertAndKey(),
+		"b.pem": new(CertTestSuite).getCertAndKey(),
+		"c.pem": new(CertTestSuite).getCertAndKey(),
+	}
+	ioutil.WriteFile(s.certsDir+"/a.pem", []byte("EXISTING CERTIFICATE"), 0644)
+	// A directory with the name of the certificate cannot be overwritten
+	os.Mkdir(s.certsDir+"/c.pem", 0755)
+
+	rw, actual := s.putAll(s.getTar(certs, "a.pem", "b.pem", "c.pem"))
+
+	s.Equal(500, rw.Code)
+	s.Equal("NOK", actual.Status)
+	s.Contains(actual.Message, "The certificate c.pem could not be stored")
+	for _, file := range actual.Files {
+		s.Equal("NOK", file.Status)
+	}
+	content, _ := ioutil.ReadFile(s.certsDir + "/a.pem")
+	s.Equal("EXISTING CERTIFICATE", string(content))
+	_, err := os.Stat(s.certsDir + "/b.pem")
+	s.True(os.IsNotExist(err))
+	s.proxyMock.AssertCalled(s.T(), "RemoveCert", "b.pem")
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *CertArchiveTestSuite) Test_PutAll_ReturnsStatus400_WhenArchiveIsInvalid() {
+	cert := new(CertTestSuite).getCertAndKey()
+	for _, body := range [][]byte{
+		[]byte("THIS IS NOT AN ARCHIVE"),
+		s.getTar(map[string]string{}),
+		s.getTar(map[string]string{"a.pem": cert, "dir/a.pem": cert}, "a.pem", "dir/a.pem"),
+	} {
+		rw, _ := s.putAll(body)
+
+		s.Equal(400, rw.Code)
+	}
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *CertArchiveTestSuite) Test_PutAll_ReturnsStatus413_WhenArchiveIsTooLarge() {
+	maxCertArchiveSizeOrig := maxCertArchiveSize
+	maxCertArchiveFileSizeOrig := maxCertArchiveFileSize
+	maxCertArchiveContentSizeOrig := maxCertArchiveContentSize
+	defer func() {
+		maxCertArchiveSize = maxCertArchiveSizeOrig
+		maxCertArchiveFileSize = maxCertArchiveFileSizeOrig
+		maxCertArchiveContentSize = maxCertArchiveContentSizeOrig
+	}()
+	cert := new(CertTestSuite).getCertAndKey()
+	archive := s.getTar(map[string]string{"a.pem": cert, "b.pem": cert}, "a.pem", "b.pem")
+	gzipped := new(bytes.Buffer)
+	gw := gzip.NewWriter(gzipped)
+	gw.Write(s.getTar(map[string]string{"a.pem": cert + strings.Repeat("\n", 1<<20)}, "a.pem"))
+	gw.Close()
+	for _, c := range []struct {
+		body                     []byte
+		size, fileSize, contSize int64
+		expected                 string
+	}{
+		{archive, int64(len(archive) - 1), 1 << 20, 10 << 20, "The archive is larger than"},
+		{archive, 10 << 20, int64(len(cert) - 1), 10 << 20, "The file a.pem of the archive is larger than"},
+		{archive, 10 << 20, 1 << 20, int64(len(cert) + 1), "The files of the archive are larger than"},
+		{gzipped.Bytes(), 10 << 20, 1 << 20, 10 << 20, "The file a.pem of the archive is larger than 1048576 bytes"},
+	} {
+		maxCertArchiveSize, maxCertArchiveFileSize, maxCertArchiveContentSize = c.size, c.fileSize, c.contSize
+
+		rw, _ := s.putAll(c.body)
+
+		s.Equal(413, rw.Code, c.expected)
+		s.Contains(rw.Body.String(), c.expected)
+	}
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+	_, err := os.Stat(s.certsDir + "/a.pem")
+	s.True(os.IsNotExist(err))
+}
+
+// Suite
+
+func TestCertArchiveUnitTestSuite(t *testing.T) {
+	proxyOrig := proxy.Instance
+	logPrintfOrig := logPrintf
+	defer func() {
+		proxy.Instance = proxyOrig
+		logPrintf = logPrintfOrig
+	}()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(CertArchiveTestSuite))
+}
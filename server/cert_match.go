@@ -0,0 +1,63 @@
+package server
+
+import (
+	"sort"
+	"strings"
+)
+
+// certExtensions are removed from the names of certificates before they are matched against domains.
+var certExtensions = []string{".pem", ".crt"}
+
+// FindCert returns the name of the certificate that would be served for the domain and whether it matched through a
+// wildcard. A certificate matches if its name without the extension, its common name or one of its DNS names is the
+// domain or a wildcard covering a single label of it (*.example.com covers api.example.com but neither example.com
+// nor v1.api.example.com). Exact matches are preferred over wildcards. The name is empty if no certificate matches.
+func FindCert(certs map[string]string, domain string) (name string, wildcard bool) {
+	names := []string{}
+	for certName := range certs {
+		names = append(names, certName)
+	}
+	sort.Strings(names)
+	for _, certName := range names {
+		exact, matched := matchCert(certName, certs[certName], domain)
+		if exact {
+			return certName, false
+		} else if matched && len(name) == 0 {
+			name, wildcard = certName, true
+		}
+	}
+	return name, wildcard
+}
+
+// matchCert returns whether any of the names of the certificate is the domain or covers it through a wildcard.
+func matchCert(certName, certContent, domain string) (exact, wildcard bool) {
+	names := []string{certName}
+	for _, extension := range certExtensions {
+		if strings.HasSuffix(strings.ToLower(certName), extension) {
+			names[0] = certName[:len(certName)-len(extension)]
+		}
+	}
+	cert := Cert{CertContent: certContent}
+	setCertInfo(&cert)
+	if len(cert.CommonName) > 0 {
+		names = append(names, cert.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	for _, name := range names {
+		if strings.EqualFold(name, domain) {
+			return true, false
+		} else if matchWildcard(name, domain) {
+			wildcard = true
+		}
+	}
+	return false, wildcard
+}
+
+func matchWildcard(pattern, domain string) bool {
+	pattern, domain = strings.ToLower(pattern), strings.ToLower(domain)
+	if !strings.HasPrefix(pattern, "*.") || !strings.HasSuffix(domain, pattern[1:]) {
+		return false
+	}
+	label := strings.TrimSuffix(domain, pattern[1:])
+	return len(label) > 0 && !strings.ContainsAny(label, ".*")
+}
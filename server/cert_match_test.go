@@ -0,0 +1,113 @@
+// +build !integration
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/stretchr/testify/suite"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"../proxy"
+)
+
+type CertMatchTestSuite struct {
+	suite.Suite
+}
+
+func (s *CertMatchTestSuite) getCert(commonName string, dnsNames ...string) string {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: commonName}, DNSNames: dnsNames}
+	der, _ := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// FindCert
+
+func (s *CertMatchTestSuite) Test_FindCert_MatchesSingleLabelWildcardName() {
+	certs := map[string]string{"*.example.com": "", "other.com": ""}
+
+	for domain, expected := range map[string]string{
+		"api.example.com":    "*.example.com",
+		"API.Example.com":    "*.example.com",
+		"example.com":        "",
+		"v1.api.example.com": "",
+		"api.example.org":    "",
+	} {
+		actual, _ := FindCert(certs, domain)
+
+		s.Equal(expected, actual, domain)
+	}
+}
+
+func (s *CertMatchTestSuite) Test_FindCert_PrefersExactName() {
+	certs := map[string]string{"*.example.com.pem": "", "api.example.com.pem": ""}
+
+	actual, wildcard := FindCert(certs, "api.example.com")
+
+	s.Equal("api.example.com.pem", actual)
+	s.False(wildcard)
+}
+
+func (s *CertMatchTestSuite) Test_FindCert_MatchesNamesOfCertificate() {
+	certs := map[string]string{
+		"my-cert.pem":    s.getCert("example.com", "example.com", "*.example.com"),
+		"other-cert.pem": s.getCert("acme.com"),
+	}
+
+	actual, wildcard := FindCert(certs, "shop.example.com")
+
+	s.Equal("my-cert.pem", actual)
+	s.True(wildcard)
+	actual, wildcard = FindCert(certs, "acme.com")
+	s.Equal("other-cert.pem", actual)
+	s.False(wildcard)
+}
+
+// GetAll
+
+func (s *CertMatchTestSuite) Test_GetAll_ReturnsCertCoveringDomain_WhenDomainIsSet() {
+	proxyMock := getProxyMock("GetCerts")
+	proxyMock.On("GetCerts").Return(map[string]string{"*.example.com": s.getCert("*.example.com"), "acme.com": s.getCert("acme.com")})
+	proxy.Instance = proxyMock
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/certs?domain=api.example.com", nil)
+
+	NewCert("../certs").GetAll(rw, req)
+
+	s.Equal(200, rw.Code)
+	actual := CertResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Len(actual.Certs, 1)
+	s.Equal("*.example.com", actual.Certs[0].ProxyServiceName)
+	s.Equal("The certificate *.example.com matches the domain api.example.com through a wildcard", actual.Message)
+}
+
+func (s *CertMatchTestSuite) Test_GetAll_ReturnsStatus404_WhenNoCertCoversDomain() {
+	proxyMock := getProxyMock("GetCerts")
+	proxyMock.On("GetCerts").Return(map[string]string{"acme.com": s.getCert("acme.com")})
+	proxy.Instance = proxyMock
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/certs?domain=api.example.com", nil)
+
+	NewCert("../certs").GetAll(rw, req)
+
+	s.Equal(404, rw.Code)
+	s.Contains(rw.Body.String(), "No certificate covers the domain api.example.com")
+}
+
+// Suite
+
+func TestCertMatchUnitTestSuite(t *testing.T) {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	suite.Run(t, new(CertMatchTestSuite))
+}
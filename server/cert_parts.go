@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"../proxy"
+)
+
+// certPartsDir is the directory, inside CertsDir, the parts of the certificates uploaded separately wait in until
+// both of them are present. HAProxy loads only the certificates it was told about so the parts are never used.
+const certPartsDir = ".pending"
+
+// certParts lists the parts in the order they are combined in.
+var certParts = []string{"cert", "key"}
+
+// PendingCert is a certificate with only some of its parts uploaded.
+type PendingCert struct {
+	Name  string
+	Parts []string
+}
+
+// putPart stores the part of the certificate. Once both the cert and the key parts are present, they are combined
+// into the certificate, which is stored the same way as the ones uploaded at once. The parts are kept if the combined
+// certificate is not valid so that only the wrong one needs to be uploaded again.
+func (m *Cert) putPart(w http.ResponseWriter, req *http.Request, certName, part string, content []byte) (string, error) {
+	if part != "cert" && part != "key" {
+		err := fmt.Errorf("The part query must be either cert or key")
+		m.writeError(w, err)
+		return "", err
+	}
+	if err := m.writePart(certName, part, content); err != nil {
+		m.writeError(w, err)
+		return "", err
+	}
+	logPrintf("Stored the %s part of the certificate %s", part, certName)
+	parts := m.readParts(certName)
+	combined := ""
+	for _, name := range certParts {
+		content, ok := parts[name]
+		if !ok {
+			m.writeOK(w, CertResponse{
+				Status:  "OK",
+				Message: fmt.Sprintf("The certificate %s is pending until the %s part is uploaded", certName, name),
+			})
+			return "", nil
+		}
+		combined += content
+		if !strings.HasSuffix(combined, "\n") {
+			combined += "\n"
+		}
+	}
+	path, err := m.storeCert(w, req, certName, []byte(combined))
+	if err == nil {
+		m.removeParts(certName)
+	}
+	return path, err
+}
+
+func (m *Cert) getPartPath(certName, part string) string {
+	return fmt.Sprintf("%s/%s/%s.%s", m.CertsDir, certPartsDir, certName, part)
+}
+
+func (m *Cert) writePart(certName, part string, content []byte) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(fmt.Sprintf("%s/%s", m.CertsDir, certPartsDir), 0755); err != nil {
+		return err
+	}
+	content, err := proxy.EncryptCert(content)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(m.getPartPath(certName, part), content, 0600)
+}
+
+// readParts returns the content of the parts of the certificate that were uploaded.
+func (m *Cert) readParts(certName string) map[string]string {
+	mu.Lock()
+	defer mu.Unlock()
+	parts := map[string]string{}
+	for _, part := range certParts {
+		if content, err := ioutil.ReadFile(m.getPartPath(certName, part)); err == nil {
+			if decrypted, err := proxy.DecryptCert(content); err == nil {
+				content = decrypted
+			}
+			parts[part] = string(content)
+		}
+	}
+	return parts
+}
+
+// removeParts deletes the parts of the certificate. It returns true if any part was deleted.
+func (m *Cert) removeParts(certName string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	removed := false
+	for _, part := range certParts {
+		if err := os.Remove(m.getPartPath(certName, part)); err == nil {
+			removed = true
+		}
+	}
+	return removed
+}
+
+// getPendingCerts returns the certificates with only some of their parts uploaded sorted by their names.
+func (m *Cert) getPendingCerts() []PendingCert {
+	mu.Lock()
+	files, _ := ioutil.ReadDir(fmt.Sprintf("%s/%s", m.CertsDir, certPartsDir))
+	mu.Unlock()
+	pending := map[string][]string{}
+	for _, file := range files {
+		for _, part := range certParts {
+			if name := strings.TrimSuffix(file.Name(), "."+part); name != file.Name() {
+				pending[name] = append(pending[name], part)
+			}
+		}
+	}
+	var certs []PendingCert
+	for name, parts := range pending {
+		sort.Strings(parts)
+		certs = append(certs, PendingCert{Name: name, Parts: parts})
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].Name < certs[j].Name
+	})
+	return certs
+}
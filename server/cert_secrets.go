@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"../proxy"
+)
+
+// certSecretPrefix is the prefix of the Docker secrets imported as certificates.
+const certSecretPrefix = "cert-"
+
+// importSecrets copies the certificates mounted as Docker secrets, the files in SecretsDir named cert-[NAME], to
+// CertsDir as [NAME]. Secrets that are not valid certificates are skipped with a warning so that they do not prevent
+// the proxy from starting. The names of the imported certificates are returned.
+func (m *Cert) importSecrets() map[string]bool {
+	imported := map[string]bool{}
+	if len(m.SecretsDir) == 0 {
+		return imported
+	}
+	files, err := ioutil.ReadDir(m.SecretsDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logPrintf("Could not read the secrets directory %s\n%s", m.SecretsDir, err.Error())
+		}
+		return imported
+	}
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasPrefix(fi.Name(), certSecretPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(fi.Name(), certSecretPrefix)
+		if err := m.importSecret(fi.Name(), name); err != nil {
+			logPrintf("WARNING: The secret %s was not imported\n%s", fi.Name(), err.Error())
+			continue
+		}
+		proxy.Instance.AddCert(name)
+		imported[name] = true
+	}
+	if len(imported) > 0 {
+		logPrintf("Imported %d certificates from %s", len(imported), m.SecretsDir)
+	}
+	return imported
+}
+
+func (m *Cert) importSecret(secret, name string) error {
+	if err := ValidateCertName(name); err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", m.SecretsDir, secret))
+	if err != nil {
+		return err
+	}
+	if err := validateCert(content); err != nil {
+		return err
+	}
+	_, err = m.writeFile(name, content)
+	return err
+}
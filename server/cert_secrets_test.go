@@ -0,0 +1,148 @@
+// +build !integration
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"../proxy"
+)
+
+type CertSecretsTestSuite struct {
+	suite.Suite
+	certsDir   string
+	secretsDir string
+	proxyMock  *ProxyMock
+	cert       *Cert
+}
+
+func (s *CertSecretsTestSuite) SetupTest() {
+	s.certsDir, _ = ioutil.TempDir("", "certs")
+	s.secretsDir, _ = ioutil.TempDir("", "secrets")
+	s.proxyMock = getProxyMock("")
+	proxy.Instance = s.proxyMock
+	s.cert = NewCert(s.certsDir)
+	s.cert.SecretsDir = s.secretsDir
+	lookupHost = func(host string) (addrs []string, err error) {
+		return []string{}, nil
+	}
+}
+
+func (s *CertSecretsTestSuite) TearDownTest() {
+	os.RemoveAll(s.certsDir)
+	os.RemoveAll(s.secretsDir)
+}
+
+func (s *CertSecretsTestSuite) writeSecret(name, content string) {
+	ioutil.WriteFile(fmt.Sprintf("%s/%s", s.secretsDir, name), []byte(content), 0400)
+}
+
+func (s *CertSecretsTestSuite) readCert(name string) string {
+	content, _ := ioutil.ReadFile(fmt.Sprintf("%s/%s", s.certsDir, name))
+	return string(content)
+}
+
+// NewCert
+
+func (s *CertSecretsTestSuite) Test_NewCert_SetsSecretsDir() {
+	defer func() { os.Unsetenv("CERT_SECRETS_DIR") }()
+
+	s.Equal("/run/secrets", NewCert("/certs").SecretsDir)
+
+	os.Setenv("CERT_SECRETS_DIR", "/my/secrets")
+
+	s.Equal("/my/secrets", NewCert("/certs").SecretsDir)
+}
+
+// Init
+
+func (s *CertSecretsTestSuite) Test_Init_ImportsCertsFromSecrets() {
+	cert := new(CertTestSuite).getCertAndKey()
+	s.writeSecret("cert-acme.pem", cert)
+	s.writeSecret("tokens.json", "{}")
+
+	s.cert.Init()
+
+	s.Equal(cert, s.readCert("acme.pem"))
+	s.Empty(s.readCert("tokens.json"))
+	s.proxyMock.AssertCalled(s.T(), "AddCert", "acme.pem")
+	s.proxyMock.AssertNumberOfCalls(s.T(), "AddCert", 1)
+	s.proxyMock.AssertNumberOfCalls(s.T(), "CreateConfigFromTemplates", 1)
+	s.proxyMock.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
+func (s *CertSecretsTestSuite) Test_Init_SkipsSecretsThatAreNotValid() {
+	var logged []string
+	logPrintf = func(format string, v ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	}
+	defer func() { logPrintf = func(format string, v ...interface{}) {} }()
+	s.writeSecret("cert-broken.pem", "This is not a certificate")
+	s.writeSecret("cert-.hidden.pem", new(CertTestSuite).getCertAndKey())
+
+	err := s.cert.Init()
+
+	s.NoError(err)
+	s.Empty(s.readCert("broken.pem"))
+	s.Empty(s.readCert(".hidden.pem"))
+	s.proxyMock.AssertNotCalled(s.T(), "AddCert", "broken.pem")
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+	s.Contains(strings.Join(logged, "\n"), "WARNING: The secret cert-broken.pem was not imported")
+	s.Contains(strings.Join(logged, "\n"), "WARNING: The secret cert-.hidden.pem was not imported")
+}
+
+func (s *CertSecretsTestSuite) Test_Init_DoesNotFail_WhenSecretsDirDoesNotExist() {
+	s.cert.SecretsDir = "/this/dir/does/not/exist"
+
+	err := s.cert.Init()
+
+	s.NoError(err)
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *CertSecretsTestSuite) Test_Init_PrefersSecretsOverCertsOfOtherInstances() {
+	secret := new(CertTestSuite).getCertAndKey()
+	s.writeSecret("cert-acme.pem", secret)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		js, _ := json.Marshal(CertResponse{Status: "OK", Certs: []Cert{
+			{ProxyServiceName: "acme.pem", CertContent: "Content of the old acme.pem"},
+			{ProxyServiceName: "other.pem", CertContent: "Content of other.pem"},
+		}})
+		w.Write(js)
+	}))
+	defer testServer.Close()
+	lookupHost = func(host string) (addrs []string, err error) {
+		ip, port, _ := net.SplitHostPort(strings.Replace(testServer.URL, "http://", "", -1))
+		return []string{net.JoinHostPort(ip, port)}, nil
+	}
+
+	s.cert.Init()
+
+	s.Equal(secret, s.readCert("acme.pem"))
+	s.Equal("Content of other.pem", s.readCert("other.pem"))
+	s.proxyMock.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
+// Suite
+
+func TestCertSecretsUnitTestSuite(t *testing.T) {
+	proxyOrig := proxy.Instance
+	logPrintfOrig := logPrintf
+	lookupHostOrig := lookupHost
+	defer func() {
+		proxy.Instance = proxyOrig
+		logPrintf = logPrintfOrig
+		lookupHost = lookupHostOrig
+	}()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(CertSecretsTestSuite))
+}
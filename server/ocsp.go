@@ -0,0 +1,217 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"../proxy"
+)
+
+// OcspExtension is appended to the name of a certificate to get the name of the file with its OCSP response. HAProxy
+// loads the file together with the certificate.
+const OcspExtension = ".ocsp"
+
+var ocspOidSha1 = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+var ocspOidBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+// The structures below are the parts of RFC 6960 needed to request the status of a single certificate.
+
+type ocspCertId struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspRequest struct {
+	TbsRequest struct {
+		RequestList []struct {
+			CertId ocspCertId
+		}
+	}
+}
+
+type ocspResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	} `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspBasicResponse struct {
+	TbsResponseData struct {
+		Version     int `asn1:"optional,default:0,explicit,tag:0"`
+		ResponderId asn1.RawValue
+		ProducedAt  time.Time `asn1:"generalized"`
+		Responses   []ocspSingleResponse
+		Extensions  []pkix.Extension `asn1:"optional,explicit,tag:1"`
+	}
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certs              []asn1.RawValue `asn1:"optional,explicit,tag:0"`
+}
+
+type ocspSingleResponse struct {
+	CertId  ocspCertId
+	Good    asn1.Flag `asn1:"optional,tag:0"`
+	Revoked struct {
+		RevocationTime time.Time       `asn1:"generalized"`
+		Reason         asn1.Enumerated `asn1:"optional,explicit,tag:0"`
+	} `asn1:"optional,tag:1"`
+	Unknown    asn1.Flag        `asn1:"optional,tag:2"`
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"optional,generalized,explicit,tag:0"`
+	Extensions []pkix.Extension `asn1:"optional,explicit,tag:1"`
+}
+
+// ocspStaples holds the time the OCSP response of each certificate was last stapled.
+var ocspStaples = struct {
+	sync.Mutex
+	times map[string]time.Time
+}{times: map[string]time.Time{}}
+
+// ocspPost sends the OCSP requests. The timeout keeps a slow responder from blocking the other certificates.
+var ocspPost = (&http.Client{Timeout: 10 * time.Second}).Post
+var ocspNow = time.Now
+var setOcspResponse = proxy.SetOcspResponse
+
+// StapleOcsp fetches the OCSP response of each certificate of the proxy from the responder set in the certificate and
+// writes it next to the certificate in certsDir. The running proxy is updated through the admin socket. If that
+// fails, the file is loaded with the next reload. Certificates without a responder are skipped. The issuer has to be
+// the second certificate of the chain. The error lists the certificates whose responses could not be fetched.
+func StapleOcsp(certsDir string) error {
+	certs := proxy.Instance.GetCerts()
+	names := []string{}
+	for name := range certs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failed := []string{}
+	for _, name := range names {
+		if err := stapleOcsp(certsDir, name, []byte(certs[name])); err != nil {
+			logPrintf("WARNING: The OCSP response of the certificate %s could not be stapled\n%s", name, err.Error())
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("The OCSP responses of the certificates %v could not be stapled", failed)
+	}
+	return nil
+}
+
+func stapleOcsp(certsDir, name string, content []byte) error {
+	chain := []*x509.Certificate{}
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 || len(chain[0].OCSPServer) == 0 {
+		return nil
+	} else if len(chain) < 2 {
+		return fmt.Errorf("The chain does not contain the issuer of the certificate")
+	}
+	request, err := newOcspRequest(chain[0], chain[1])
+	if err != nil {
+		return err
+	}
+	resp, err := ocspPost(chain[0].OCSPServer[0], "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("The responder %s returned the status %d", chain[0].OCSPServer[0], resp.StatusCode)
+	}
+	single, err := parseOcspResponse(body, chain[0].SerialNumber)
+	if err != nil {
+		return err
+	} else if !single.Good {
+		logPrintf("WARNING: The OCSP responder does not report the certificate %s as good", name)
+	}
+	if err := ioutil.WriteFile(filepath.Join(certsDir, name+OcspExtension), body, 0644); err != nil {
+		return err
+	}
+	if err := setOcspResponse(body); err != nil {
+		logPrintf("The OCSP response of the certificate %s will be loaded with the next reload\n%s", name, err.Error())
+	}
+	ocspStaples.Lock()
+	ocspStaples.times[name] = ocspNow()
+	ocspStaples.Unlock()
+	return nil
+}
+
+func newOcspRequest(cert, issuer *x509.Certificate) ([]byte, error) {
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return nil, err
+	}
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(publicKeyInfo.PublicKey.RightAlign())
+	request := ocspRequest{}
+	request.TbsRequest.RequestList = []struct{ CertId ocspCertId }{{ocspCertId{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: ocspOidSha1, Parameters: asn1.RawValue{Tag: asn1.TagNull}},
+		NameHash:      nameHash[:],
+		IssuerKeyHash: keyHash[:],
+		SerialNumber:  cert.SerialNumber,
+	}}}
+	return asn1.Marshal(request)
+}
+
+// parseOcspResponse returns the status of the certificate with the serial number. The signature is not verified
+// since HAProxy checks the response before stapling it.
+func parseOcspResponse(der []byte, serialNumber *big.Int) (*ocspSingleResponse, error) {
+	response := ocspResponse{}
+	if _, err := asn1.Unmarshal(der, &response); err != nil {
+		return nil, fmt.Errorf("Could not parse the OCSP response\n%s", err.Error())
+	} else if response.Status != 0 {
+		return nil, fmt.Errorf("The OCSP responder returned the status %d", response.Status)
+	} else if !response.ResponseBytes.ResponseType.Equal(ocspOidBasicResponse) {
+		return nil, fmt.Errorf("The OCSP response type %s is not supported", response.ResponseBytes.ResponseType)
+	}
+	basic := ocspBasicResponse{}
+	if _, err := asn1.Unmarshal(response.ResponseBytes.Response, &basic); err != nil {
+		return nil, fmt.Errorf("Could not parse the OCSP response\n%s", err.Error())
+	}
+	for i := range basic.TbsResponseData.Responses {
+		single := &basic.TbsResponseData.Responses[i]
+		if single.CertId.SerialNumber != nil && single.CertId.SerialNumber.Cmp(serialNumber) == 0 {
+			return single, nil
+		}
+	}
+	return nil, fmt.Errorf("The OCSP response does not contain the status of the certificate")
+}
+
+// getLastStaple returns the time the OCSP response of the certificate was last stapled or nil if it never was.
+func getLastStaple(name string) *time.Time {
+	ocspStaples.Lock()
+	defer ocspStaples.Unlock()
+	if t, ok := ocspStaples.times[name]; ok {
+		return &t
+	}
+	return nil
+}
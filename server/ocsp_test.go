@@ -0,0 +1,278 @@
+// +build !integration
+
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"../proxy"
+)
+
+type OcspTestSuite struct {
+	suite.Suite
+	certsDir  string
+	proxyMock *ProxyMock
+	issuer    *x509.Certificate
+	chain     string
+	requests  [][]byte
+	response  []byte
+	responses [][]byte
+	now       time.Time
+}
+
+func (s *OcspTestSuite) SetupTest() {
+	s.certsDir, _ = ioutil.TempDir("", "certs")
+	s.chain = s.getChain("http://ocsp.acme.com")
+	s.requests = [][]byte{}
+	s.response = s.getResponse(big.NewInt(2), true)
+	s.responses = [][]byte{}
+	s.now = time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	s.proxyMock = getProxyMock("GetCerts")
+	s.proxyMock.On("GetCerts").Return(map[string]string{"acme.pem": s.chain})
+	proxy.Instance = s.proxyMock
+	ocspStaples.times = map[string]time.Time{}
+	ocspNow = func() time.Time {
+		return s.now
+	}
+	ocspPost = func(url, contentType string, body io.Reader) (*http.Response, error) {
+		request, _ := ioutil.ReadAll(body)
+		s.requests = append(s.requests, request)
+		s.Equal("http://ocsp.acme.com", url)
+		s.Equal("application/ocsp-request", contentType)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(s.response))}, nil
+	}
+	setOcspResponse = func(der []byte) error {
+		s.responses = append(s.responses, der)
+		return nil
+	}
+}
+
+func (s *OcspTestSuite) TearDownTest() {
+	os.RemoveAll(s.certsDir)
+}
+
+// getChain returns a certificate with the serial number 2 and the responder URL followed by its issuer.
+func (s *OcspTestSuite) getChain(responder string) string {
+	issuerKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	issuerTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Acme CA"},
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	issuerDer, _ := x509.CreateCertificate(rand.Reader, &issuerTemplate, &issuerTemplate, &issuerKey.PublicKey, issuerKey)
+	s.issuer, _ = x509.ParseCertificate(issuerDer)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "acme.com"},
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if len(responder) > 0 {
+		template.OCSPServer = []string{responder}
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, &template, s.issuer, &key.PublicKey, issuerKey)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issuerDer})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+// getResponse returns an unsigned OCSP response with the status of the certificate with the serial number.
+func (s *OcspTestSuite) getResponse(serialNumber *big.Int, good bool) []byte {
+	keyHash, _ := asn1.Marshal([]byte("key hash"))
+	basic := ocspBasicResponse{}
+	basic.TbsResponseData.ResponderId = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: keyHash}
+	basic.TbsResponseData.ProducedAt = time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	basic.TbsResponseData.Responses = []ocspSingleResponse{{
+		CertId:     ocspCertId{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: ocspOidSha1}, NameHash: []byte{1}, IssuerKeyHash: []byte{2}, SerialNumber: serialNumber},
+		Good:       asn1.Flag(good),
+		ThisUpdate: time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC),
+		NextUpdate: time.Date(2017, 6, 8, 0, 0, 0, 0, time.UTC),
+	}}
+	if !good {
+		basic.TbsResponseData.Responses[0].Revoked.RevocationTime = time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)
+	}
+	basic.SignatureAlgorithm = pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}}
+	basic.Signature = asn1.BitString{Bytes: []byte("signature"), BitLength: 72}
+	basicDer, err := asn1.Marshal(basic)
+	s.NoError(err)
+	response := ocspResponse{}
+	response.ResponseBytes.ResponseType = ocspOidBasicResponse
+	response.ResponseBytes.Response = basicDer
+	der, err := asn1.Marshal(response)
+	s.NoError(err)
+	return der
+}
+
+func (s *OcspTestSuite) readFile(name string) string {
+	content, _ := ioutil.ReadFile(fmt.Sprintf("%s/%s", s.certsDir, name))
+	return string(content)
+}
+
+// StapleOcsp
+
+func (s *OcspTestSuite) Test_StapleOcsp_RequestsStatusOfCert() {
+	err := StapleOcsp(s.certsDir)
+
+	s.NoError(err)
+	s.Len(s.requests, 1)
+	request := ocspRequest{}
+	_, err = asn1.Unmarshal(s.requests[0], &request)
+	s.NoError(err)
+	s.Len(request.TbsRequest.RequestList, 1)
+	certId := request.TbsRequest.RequestList[0].CertId
+	nameHash := sha1.Sum(s.issuer.RawSubject)
+	s.Equal(ocspOidSha1, certId.HashAlgorithm.Algorithm)
+	s.Equal(nameHash[:], certId.NameHash)
+	s.Len(certId.IssuerKeyHash, sha1.Size)
+	s.Equal(big.NewInt(2), certId.SerialNumber)
+}
+
+func (s *OcspTestSuite) Test_StapleOcsp_WritesResponseAndUpdatesProxy() {
+	err := StapleOcsp(s.certsDir)
+
+	s.NoError(err)
+	s.Equal(string(s.response), s.readFile("acme.pem.ocsp"))
+	s.Equal([][]byte{s.response}, s.responses)
+	s.Equal(&s.now, getLastStaple("acme.pem"))
+}
+
+func (s *OcspTestSuite) Test_StapleOcsp_WritesResponse_WhenProxyCannotBeUpdated() {
+	setOcspResponse = func(der []byte) error {
+		return fmt.Errorf("This is an error")
+	}
+
+	err := StapleOcsp(s.certsDir)
+
+	s.NoError(err)
+	s.Equal(string(s.response), s.readFile("acme.pem.ocsp"))
+	s.NotNil(getLastStaple("acme.pem"))
+}
+
+func (s *OcspTestSuite) Test_StapleOcsp_StaplesRevokedStatus() {
+	s.response = s.getResponse(big.NewInt(2), false)
+
+	err := StapleOcsp(s.certsDir)
+
+	s.NoError(err)
+	s.Equal(string(s.response), s.readFile("acme.pem.ocsp"))
+}
+
+func (s *OcspTestSuite) Test_StapleOcsp_SkipsCertsWithoutResponder() {
+	s.proxyMock = getProxyMock("GetCerts")
+	s.proxyMock.On("GetCerts").Return(map[string]string{"acme.pem": s.getChain(""), "other.pem": "THIS IS NOT A CERTIFICATE"})
+	proxy.Instance = s.proxyMock
+
+	err := StapleOcsp(s.certsDir)
+
+	s.NoError(err)
+	s.Empty(s.requests)
+	s.Nil(getLastStaple("acme.pem"))
+}
+
+func (s *OcspTestSuite) Test_StapleOcsp_ReturnsError_WhenResponseCannotBeUsed() {
+	for name, response := range map[string][]byte{
+		"other serial number": s.getResponse(big.NewInt(3), true),
+		"not a response":      []byte("THIS IS NOT A RESPONSE"),
+		"unsuccessful status": {0x30, 0x03, 0x0a, 0x01, 0x06},
+	} {
+		s.response = response
+
+		err := StapleOcsp(s.certsDir)
+
+		s.Error(err, name)
+		s.Empty(s.readFile("acme.pem.ocsp"), name)
+		s.Nil(getLastStaple("acme.pem"), name)
+	}
+}
+
+func (s *OcspTestSuite) Test_StapleOcsp_ReturnsError_WhenResponderFails() {
+	ocspPost = func(url, contentType string, body io.Reader) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(bytes.NewReader([]byte{}))}, nil
+	}
+
+	err := StapleOcsp(s.certsDir)
+
+	s.Error(err)
+	s.Contains(err.Error(), "acme.pem")
+	s.Empty(s.responses)
+}
+
+func (s *OcspTestSuite) Test_StapleOcsp_ReturnsError_WhenChainDoesNotContainIssuer() {
+	chain := s.getChain("http://ocsp.acme.com")
+	block, _ := pem.Decode([]byte(chain))
+	s.proxyMock = getProxyMock("GetCerts")
+	s.proxyMock.On("GetCerts").Return(map[string]string{"acme.pem": string(pem.EncodeToMemory(block))})
+	proxy.Instance = s.proxyMock
+
+	s.Error(StapleOcsp(s.certsDir))
+	s.Empty(s.requests)
+}
+
+// parseOcspResponse
+
+func (s *OcspTestSuite) Test_ParseOcspResponse_ReturnsStatusOfCert() {
+	good, err := parseOcspResponse(s.getResponse(big.NewInt(2), true), big.NewInt(2))
+
+	s.NoError(err)
+	s.True(bool(good.Good))
+	s.Equal(time.Date(2017, 6, 8, 0, 0, 0, 0, time.UTC), good.NextUpdate.UTC())
+
+	revoked, err := parseOcspResponse(s.getResponse(big.NewInt(2), false), big.NewInt(2))
+
+	s.NoError(err)
+	s.False(bool(revoked.Good))
+	s.Equal(time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC), revoked.Revoked.RevocationTime.UTC())
+}
+
+// GetAll
+
+func (s *OcspTestSuite) Test_GetAll_ReturnsLastStaple() {
+	StapleOcsp(s.certsDir)
+
+	actual, _ := NewCert(s.certsDir).GetAll(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/docker-flow-proxy/certs", nil))
+
+	s.Len(actual.Certs, 1)
+	s.Equal(&s.now, actual.Certs[0].LastStaple)
+}
+
+// Suite
+
+func TestOcspUnitTestSuite(t *testing.T) {
+	proxyOrig := proxy.Instance
+	logPrintfOrig := logPrintf
+	ocspPostOrig := ocspPost
+	ocspNowOrig := ocspNow
+	setOcspResponseOrig := setOcspResponse
+	defer func() {
+		proxy.Instance = proxyOrig
+		logPrintf = logPrintfOrig
+		ocspPost = ocspPostOrig
+		ocspNow = ocspNowOrig
+		setOcspResponse = setOcspResponseOrig
+		ocspStaples.times = map[string]time.Time{}
+	}()
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(OcspTestSuite))
+}
@@ -13,7 +13,21 @@ type Server interface {
 	SendDistributeRequests(req *http.Request, port, proxyServiceName string) (status int, err error)
 }
 
-type Serve struct{}
+// Serve sends requests to the instances of the proxy. LookupHost resolves the instances and defaults to the package
+// one if it is not set.
+type Serve struct {
+	LookupHost func(host string) (addrs []string, err error)
+}
+
+// DistributeError lists the addresses of the instances that did not accept a distributed request. The request can be
+// retried against them without repeating it on the others.
+type DistributeError struct {
+	Addresses []string
+}
+
+func (e *DistributeError) Error() string {
+	return fmt.Sprintf("Could not send distribute request to the following addresses: %s", e.Addresses)
+}
 
 func (m *Serve) SendDistributeRequests(req *http.Request, port, proxyServiceName string) (status int, err error) {
 	values := req.URL.Query()
@@ -22,19 +36,32 @@ func (m *Serve) SendDistributeRequests(req *http.Request, port, proxyServiceName
 	dns := fmt.Sprintf("tasks.%s", proxyServiceName)
 	failedDns := []string{}
 	method := req.Method
+	// Tokens are shared by all the instances
+	authorization := req.Header.Get("Authorization")
+	contentType := req.Header.Get("Content-Type")
 	body := ""
 	if req.Body != nil {
 		defer func() { req.Body.Close() }()
 		reqBody, _ := ioutil.ReadAll(req.Body)
 		body = string(reqBody)
 	}
-	if ips, err := lookupHost(dns); err == nil {
+	lookup := m.LookupHost
+	if lookup == nil {
+		lookup = lookupHost
+	}
+	if ips, err := lookup(dns); err == nil {
 		for i := 0; i < len(ips); i++ {
 			req.URL.Host = fmt.Sprintf("%s:%s", ips[i], port)
 			client := &http.Client{}
 			addr := fmt.Sprintf("http://%s:%s%s?%s", ips[i], port, req.URL.Path, req.URL.RawQuery)
 			logPrintf("Sending distribution request to %s", addr)
 			req, _ := http.NewRequest(method, addr, strings.NewReader(body))
+			if len(authorization) > 0 {
+				req.Header.Set("Authorization", authorization)
+			}
+			if len(contentType) > 0 {
+				req.Header.Set("Content-Type", contentType)
+			}
 			if resp, err := client.Do(req); err != nil || resp.StatusCode >= 300 {
 				failedDns = append(failedDns, ips[i])
 			}
@@ -43,7 +70,7 @@ func (m *Serve) SendDistributeRequests(req *http.Request, port, proxyServiceName
 		return http.StatusBadRequest, fmt.Errorf("Could not perform DNS %s lookup. If the proxy is not called 'proxy', you must set SERVICE_NAME=<name-of-the-proxy>.", dns)
 	}
 	if len(failedDns) > 0 {
-		return http.StatusBadRequest, fmt.Errorf("Could not send distribute request to the following addresses: %s", failedDns)
+		return http.StatusBadGateway, &DistributeError{Addresses: failedDns}
 	}
 	return http.StatusOK, err
 }
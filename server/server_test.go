@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"strings"
 	"testing"
 )
@@ -18,10 +19,16 @@ type ServerTestSuite struct {
 	ServiceName    string
 	Server         *httptest.Server
 	DnsIps         []string
+	certsDir       string
 	suite.Suite
 }
 
 func (s *ServerTestSuite) SetupTest() {
+	s.certsDir, _ = ioutil.TempDir("", "certs")
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	os.RemoveAll(s.certsDir)
 }
 
 func TestServerUnitTestSuite(t *testing.T) {
@@ -152,6 +159,50 @@ func (s *ServerTestSuite) Test_SendDistributeRequests_SendsHttpRequestForEachIpW
 
 	s.Assert().Equal("PUT", actualProtocol)
 }
+func (s *ServerTestSuite) Test_SendDistributeRequests_SendsHttpRequestForEachIpWithTheAuthorizationHeader() {
+	actualAuthorization := ""
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualAuthorization = r.Header.Get("Authorization")
+	}))
+	defer func() { testServer.Close() }()
+	tsAddr := strings.Replace(testServer.URL, "http://", "", -1)
+	dnsIpsOrig := s.DnsIps
+	defer func() { s.DnsIps = dnsIpsOrig }()
+	s.DnsIps = []string{strings.Split(tsAddr, ":")[0]}
+	port := strings.Split(tsAddr, ":")[1]
+
+	srv := Serve{}
+	addr := fmt.Sprintf("http://initial-proxy-address:%s%s&distribute=true", port, s.ReconfigureUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+	req.Header.Set("Authorization", "Bearer my-token")
+
+	srv.SendDistributeRequests(req, port, s.ServiceName)
+
+	s.Equal("Bearer my-token", actualAuthorization)
+}
+
+func (s *ServerTestSuite) Test_SendDistributeRequests_SendsHttpRequestForEachIpWithTheContentTypeHeader() {
+	actualContentType := ""
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualContentType = r.Header.Get("Content-Type")
+	}))
+	defer func() { testServer.Close() }()
+	tsAddr := strings.Replace(testServer.URL, "http://", "", -1)
+	dnsIpsOrig := s.DnsIps
+	defer func() { s.DnsIps = dnsIpsOrig }()
+	s.DnsIps = []string{strings.Split(tsAddr, ":")[0]}
+	port := strings.Split(tsAddr, ":")[1]
+
+	srv := Serve{}
+	addr := fmt.Sprintf("http://initial-proxy-address:%s%s&distribute=true", port, s.ReconfigureUrl)
+	req, _ := http.NewRequest("PUT", addr, strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+
+	srv.SendDistributeRequests(req, port, s.ServiceName)
+
+	s.Equal("application/json", actualContentType)
+}
+
 
 func (s *ServerTestSuite) Test_SendDistributeRequests_SendsHttpRequestForEachIpWithTheBody() {
 	actualBody := ""
@@ -194,10 +245,31 @@ func (s *ServerTestSuite) Test_SendDistributeRequests_ReturnsError_WhenRequestFa
 
 	status, err := srv.SendDistributeRequests(req, port, s.ServiceName)
 
-	s.Assertions.Equal(http.StatusBadRequest, status)
+	s.Assertions.Equal(http.StatusBadGateway, status)
 	s.Assertions.Error(err)
 }
 
+func (s *ServerTestSuite) Test_SendDistributeRequests_ReturnsFailedAddresses_WhenSomeRequestsFail() {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer func() { testServer.Close() }()
+	tsAddr := strings.Replace(testServer.URL, "http://", "", -1)
+	port := strings.Split(tsAddr, ":")[1]
+	dnsIpsOrig := s.DnsIps
+	defer func() { s.DnsIps = dnsIpsOrig }()
+	// Nothing listens on the port of the test server on 127.0.0.2
+	s.DnsIps = []string{strings.Split(tsAddr, ":")[0], "127.0.0.2"}
+
+	srv := Serve{}
+	addr := fmt.Sprintf("http://initial-proxy-address:%s%s&distribute=true", port, s.ReconfigureUrl)
+	req, _ := http.NewRequest("PUT", addr, nil)
+
+	_, err := srv.SendDistributeRequests(req, port, s.ServiceName)
+
+	s.Require().IsType(&DistributeError{}, err)
+	s.Equal([]string{"127.0.0.2"}, err.(*DistributeError).Addresses)
+	s.Equal("Could not send distribute request to the following addresses: [127.0.0.2]", err.Error())
+}
+
 // Mocks
 
 type ServerMock struct {
@@ -3,19 +3,26 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	haproxy "./proxy"
 	"./server"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"./actions"
+	"./registry"
 )
 
 type ServerTestSuite struct {
@@ -34,7 +41,6 @@ type ServerTestSuite struct {
 	RequestReconfigure *http.Request
 	RequestRemove      *http.Request
 	InstanceName       string
-	DnsIps             []string
 	Server             *httptest.Server
 }
 
@@ -68,6 +74,20 @@ func (s *ServerTestSuite) SetupTest() {
 	httpListenAndServe = func(addr string, handler http.Handler) error {
 		return nil
 	}
+	collectGarbage = func(paths []string, dryRun bool) (actions.GarbageResult, error) {
+		return actions.GarbageResult{}, nil
+	}
+	startConsulProbe = func(addresses []string, interval time.Duration) {}
+	startCertExpiryCheck = func(interval, warning time.Duration, webhook string) {}
+	startLetsEncryptRenewal = func(m *Serve, interval time.Duration) {}
+	startMaintenanceScheduler = func(m *Serve) {}
+	startOcspStapling = func(certsDir string, interval time.Duration) {}
+	writeDomainMap = func(configsPath string) (map[string]string, map[string]string, error) {
+		return map[string]string{}, map[string]string{}, nil
+	}
+	migrateServiceNames = func(addresses []string, instanceName string) (registry.MigrationResult, error) {
+		return registry.MigrationResult{}, nil
+	}
 	serverImpl = Serve{
 		BaseReconfigure: actions.BaseReconfigure{
 			ConsulAddresses: []string{s.ConsulAddress},
@@ -77,9 +97,7 @@ func (s *ServerTestSuite) SetupTest() {
 	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
 		return getReconfigureMock("")
 	}
-	logPrintfOrig := logPrintf
-	defer func() { logPrintf = logPrintfOrig }()
-	logPrintf = func(format string, v ...interface{}) {}
+	log.SetOutput(ioutil.Discard)
 }
 
 // Execute
@@ -103,9 +121,7 @@ func (s *ServerTestSuite) Test_Execute_InvokesHTTPListenAndServe() {
 
 func (s *ServerTestSuite) Test_Execute_ReturnsError_WhenHTTPListenAndServeFails() {
 	orig := httpListenAndServe
-	defer func() {
-		httpListenAndServe = orig
-	}()
+	defer func() { httpListenAndServe = orig }()
 	httpListenAndServe = func(addr string, handler http.Handler) error {
 		return fmt.Errorf("This is an error")
 	}
@@ -115,11 +131,20 @@ func (s *ServerTestSuite) Test_Execute_ReturnsError_WhenHTTPListenAndServeFails(
 	s.Error(actual)
 }
 
+func (s *ServerTestSuite) Test_Execute_SetsHostHeaderRulesOfProxy() {
+	defer haproxy.SetHostHeaderRules(false, false)
+	srv := Serve{RequireHostHeader: true, DenyHttp10: true}
+
+	srv.Execute([]string{})
+
+	requireHostHeader, denyHttp10 := haproxy.GetHostHeaderRules()
+	s.True(requireHostHeader)
+	s.True(denyHttp10)
+}
+
 func (s *ServerTestSuite) Test_Execute_InvokesRunExecute() {
 	orig := NewRun
-	defer func() {
-		NewRun = orig
-	}()
+	defer func() { NewRun = orig }()
 	mockObj := getRunMock("")
 	NewRun = func() Executable {
 		return mockObj
@@ -130,6 +155,156 @@ func (s *ServerTestSuite) Test_Execute_InvokesRunExecute() {
 	mockObj.AssertCalled(s.T(), "Execute", []string{})
 }
 
+func (s *ServerTestSuite) Test_Execute_StartsConsulProbe_WhenThereAreMultipleConsulAddresses() {
+	var actual []string
+	startConsulProbe = func(addresses []string, interval time.Duration) {
+		actual = addresses
+	}
+	consulAddressOrig := os.Getenv("CONSUL_ADDRESS")
+	defer func() { os.Setenv("CONSUL_ADDRESS", consulAddressOrig) }()
+	os.Setenv("CONSUL_ADDRESS", "consul-1:8500,http://consul-2:8500")
+
+	serverImpl.Execute([]string{})
+
+	s.Equal([]string{"http://consul-1:8500", "http://consul-2:8500"}, actual)
+}
+
+func (s *ServerTestSuite) Test_Execute_VerifiesStartupConfig_WhenStartupVerifyIsSet() {
+	startupOrig, startupStoreOrig, proxyOrig := startup, startupStore, haproxy.Instance
+	defer func() { startup, startupStore, haproxy.Instance = startupOrig, startupStoreOrig, proxyOrig }()
+	startup = &startupGate{}
+	storeMock := getStartupStoreMock("")
+	startupStore = storeMock
+	haproxy.Instance = getProxyMock("")
+	verified := false
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		mockObj := getReconfigureMock("ReloadAllServices")
+		mockObj.On("ReloadAllServices", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			verified = baseData.BeforeStartupReload()
+		})
+		return mockObj
+	}
+	serverImpl.StartupVerify = true
+
+	serverImpl.Execute([]string{})
+
+	s.True(verified)
+	storeMock.AssertCalled(s.T(), "GetStartupState", mock.Anything, s.InstanceName)
+	storeMock.AssertCalled(s.T(), "PutStartupState", mock.Anything, s.InstanceName, mock.Anything)
+	s.Nil(serverImpl.BeforeStartupReload)
+}
+
+func (s *ServerTestSuite) Test_Execute_StartsCertsWatcher_WhenWatchCertsIsSet() {
+	startCertsWatcherOrig := startCertsWatcher
+	defer func() { startCertsWatcher = startCertsWatcherOrig }()
+	actual := ""
+	startCertsWatcher = func(dir string) error {
+		actual = dir
+		return nil
+	}
+
+	serverImpl.Execute([]string{})
+
+	s.Empty(actual)
+
+	serverImpl.WatchCerts = true
+	serverImpl.Execute([]string{})
+
+	s.Equal("/certs", actual)
+}
+
+func (s *ServerTestSuite) Test_Execute_StartsCertExpiryCheck_WhenIntervalIsSet() {
+	actual := []interface{}{}
+	startCertExpiryCheck = func(interval, warning time.Duration, webhook string) {
+		actual = []interface{}{interval, warning, webhook}
+	}
+	serverImpl.CertExpiryCheckInterval = 12 * time.Hour
+	serverImpl.CertExpiryWarning = 720 * time.Hour
+	serverImpl.AlertWebhook = "http://alerts.com/hook"
+
+	serverImpl.Execute([]string{})
+
+	s.Equal([]interface{}{12 * time.Hour, 720 * time.Hour, "http://alerts.com/hook"}, actual)
+}
+
+func (s *ServerTestSuite) Test_Execute_DoesNotStartCertExpiryCheck_WhenIntervalIsZero() {
+	invoked := false
+	startCertExpiryCheck = func(interval, warning time.Duration, webhook string) {
+		invoked = true
+	}
+
+	serverImpl.Execute([]string{})
+
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_Execute_StartsLetsEncryptRenewal_WhenEmailIsSet() {
+	actual := time.Duration(0)
+	startLetsEncryptRenewal = func(m *Serve, interval time.Duration) {
+		actual = interval
+	}
+	serverImpl.LetsEncryptEmail = "admin@acme.com"
+	defer func() { serverImpl.LetsEncryptEmail = "" }()
+
+	serverImpl.Execute([]string{})
+
+	s.Equal(letsEncryptRenewInterval, actual)
+}
+
+func (s *ServerTestSuite) Test_Execute_DoesNotStartLetsEncryptRenewal_WhenEmailIsNotSet() {
+	invoked := false
+	startLetsEncryptRenewal = func(m *Serve, interval time.Duration) {
+		invoked = true
+	}
+
+	serverImpl.Execute([]string{})
+
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_Execute_StartsOcspStapling_WhenOcspStaplingIsSet() {
+	actualDir := ""
+	actualInterval := time.Duration(0)
+	startOcspStapling = func(certsDir string, interval time.Duration) {
+		actualDir = certsDir
+		actualInterval = interval
+	}
+	serverImpl.OcspStapling = true
+	serverImpl.OcspStaplingInterval = time.Hour
+	defer func() {
+		serverImpl.OcspStapling = false
+		serverImpl.OcspStaplingInterval = 0
+	}()
+
+	serverImpl.Execute([]string{})
+
+	s.Equal("/certs", actualDir)
+	s.Equal(time.Hour, actualInterval)
+}
+
+func (s *ServerTestSuite) Test_Execute_DoesNotStartOcspStapling_WhenOcspStaplingIsNotSet() {
+	invoked := false
+	startOcspStapling = func(certsDir string, interval time.Duration) {
+		invoked = true
+	}
+	serverImpl.OcspStaplingInterval = time.Hour
+	defer func() { serverImpl.OcspStaplingInterval = 0 }()
+
+	serverImpl.Execute([]string{})
+
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_Execute_SetsDefaultCert_WhenDefaultCertIsSet() {
+	defer haproxy.SetDefaultCert("")
+	serverImpl.DefaultCert = "my-cert.pem"
+	defer func() { serverImpl.DefaultCert = "" }()
+
+	serverImpl.Execute([]string{})
+
+	s.Equal("my-cert.pem", haproxy.GetDefaultCert())
+}
+
 func (s *ServerTestSuite) Test_Execute_InvokesCertInit() {
 	invoked := false
 	err := serverImpl.Execute([]string{})
@@ -227,6 +402,188 @@ func (s *ServerTestSuite) Test_Execute_ReturnsError_WhenReloadAllServicesFails()
 	s.Error(actual)
 }
 
+func (s *ServerTestSuite) Test_Execute_LoadsApiTokens_WhenApiTokensPathIsSet() {
+	readFileOrig := readFile
+	notifySignalOrig := notifySignal
+	defer func() {
+		readFile = readFileOrig
+		notifySignal = notifySignalOrig
+		apiTokens = map[string]ApiToken{}
+	}()
+	actualPath := ""
+	readFile = func(fileName string) ([]byte, error) {
+		actualPath = fileName
+		return []byte(`{"my-token": {"services": ["my-*"]}}`), nil
+	}
+	var actualSignals []os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) {
+		actualSignals = sig
+	}
+	srv := Serve{ApiTokensPath: "/run/secrets/tokens.json"}
+
+	srv.Execute([]string{})
+
+	s.Equal("/run/secrets/tokens.json", actualPath)
+	s.Equal(ApiToken{Services: []string{"my-*"}}, apiTokens["my-token"])
+	s.Equal([]os.Signal{syscall.SIGHUP}, actualSignals)
+}
+
+func (s *ServerTestSuite) Test_Execute_ReturnsError_WhenApiTokensCannotBeLoaded() {
+	readFileOrig := readFile
+	defer func() { readFile = readFileOrig }()
+	readFile = func(fileName string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	srv := Serve{ApiTokensPath: "/run/secrets/tokens.json"}
+
+	actual := srv.Execute([]string{})
+
+	s.Error(actual)
+}
+
+func (s *ServerTestSuite) Test_Execute_LoadsReloadHooks_WhenHooksPathIsSet() {
+	loadReloadHooksOrig := loadReloadHooks
+	defer func() { loadReloadHooks = loadReloadHooksOrig }()
+	actualPath := ""
+	loadReloadHooks = func(path string) error {
+		actualPath = path
+		return nil
+	}
+	srv := Serve{HooksPath: "/cfg/hooks.json"}
+
+	srv.Execute([]string{})
+
+	s.Equal("/cfg/hooks.json", actualPath)
+}
+
+func (s *ServerTestSuite) Test_Execute_ReturnsError_WhenReloadHooksCannotBeLoaded() {
+	loadReloadHooksOrig := loadReloadHooks
+	defer func() { loadReloadHooks = loadReloadHooksOrig }()
+	loadReloadHooks = func(path string) error {
+		return fmt.Errorf("This is an error")
+	}
+	srv := Serve{HooksPath: "/cfg/hooks.json"}
+
+	actual := srv.Execute([]string{})
+
+	s.EqualError(actual, "This is an error")
+}
+
+func (s *ServerTestSuite) Test_Execute_MigratesRegistry_WhenMigrateRegistryIsTrue() {
+	var actualAddresses []string
+	var actualInstanceName string
+	var actualCleanup bool
+	migrateRegistryOrig := migrateRegistry
+	defer func() { migrateRegistry = migrateRegistryOrig }()
+	migrateRegistry = func(addresses []string, instanceName string, cleanup bool) (registry.MigrationResult, error) {
+		actualAddresses = addresses
+		actualInstanceName = instanceName
+		actualCleanup = cleanup
+		return registry.MigrationResult{Migrated: []string{"docker-flow/go-demo"}}, nil
+	}
+	defer func() { os.Unsetenv("CONSUL_ADDRESS") }()
+	os.Setenv("CONSUL_ADDRESS", s.ConsulAddress)
+	srv := Serve{MigrateRegistry: true, MigrateCleanup: true}
+	srv.InstanceName = s.InstanceName
+
+	srv.Execute([]string{})
+
+	s.Equal([]string{s.ConsulAddress}, actualAddresses)
+	s.Equal(s.InstanceName, actualInstanceName)
+	s.True(actualCleanup)
+	s.Equal([]string{"docker-flow/go-demo"}, srv.migration.Migrated)
+}
+
+func (s *ServerTestSuite) Test_Execute_DoesNotMigrateRegistry_WhenMigrateRegistryIsFalse() {
+	invoked := false
+	migrateRegistryOrig := migrateRegistry
+	defer func() { migrateRegistry = migrateRegistryOrig }()
+	migrateRegistry = func(addresses []string, instanceName string, cleanup bool) (registry.MigrationResult, error) {
+		invoked = true
+		return registry.MigrationResult{}, nil
+	}
+	defer func() { os.Unsetenv("CONSUL_ADDRESS") }()
+	os.Setenv("CONSUL_ADDRESS", s.ConsulAddress)
+	srv := Serve{}
+
+	srv.Execute([]string{})
+
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_Execute_MigratesServiceNames_WhenConsulAddressIsSet() {
+	var actualAddresses []string
+	var actualInstanceName string
+	migrateServiceNames = func(addresses []string, instanceName string) (registry.MigrationResult, error) {
+		actualAddresses = addresses
+		actualInstanceName = instanceName
+		return registry.MigrationResult{}, nil
+	}
+	defer func() { os.Unsetenv("CONSUL_ADDRESS") }()
+	os.Setenv("CONSUL_ADDRESS", s.ConsulAddress)
+	srv := Serve{}
+	srv.InstanceName = s.InstanceName
+
+	srv.Execute([]string{})
+
+	s.Equal([]string{s.ConsulAddress}, actualAddresses)
+	s.Equal(s.InstanceName, actualInstanceName)
+}
+
+func (s *ServerTestSuite) Test_Execute_DoesNotMigrateServiceNames_WhenConsulAddressIsNotSet() {
+	invoked := false
+	migrateServiceNames = func(addresses []string, instanceName string) (registry.MigrationResult, error) {
+		invoked = true
+		return registry.MigrationResult{}, nil
+	}
+	srv := Serve{}
+
+	srv.Execute([]string{})
+
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_Execute_CollectsGarbage_WhenServicesAreLoadedFromConsul() {
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return getReconfigureMock("")
+	}
+	var actualPaths []string
+	actualDryRun := true
+	collectGarbage = func(paths []string, dryRun bool) (actions.GarbageResult, error) {
+		actualPaths = paths
+		actualDryRun = dryRun
+		return actions.GarbageResult{}, nil
+	}
+	defer func() { os.Unsetenv("CONSUL_ADDRESS") }()
+	os.Setenv("CONSUL_ADDRESS", s.ConsulAddress)
+	srv := Serve{}
+	srv.TemplatesPath = "/cfg/tmpl"
+	srv.ConfigsPath = "/cfg"
+
+	srv.Execute([]string{})
+
+	s.Equal([]string{"/cfg/tmpl", "/cfg"}, actualPaths)
+	s.False(actualDryRun)
+}
+
+func (s *ServerTestSuite) Test_Execute_DoesNotCollectGarbage_WhenListenerAddressIsSet() {
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return getReconfigureMock("")
+	}
+	invoked := false
+	collectGarbage = func(paths []string, dryRun bool) (actions.GarbageResult, error) {
+		invoked = true
+		return actions.GarbageResult{}, nil
+	}
+	defer func() { os.Unsetenv("CONSUL_ADDRESS") }()
+	os.Setenv("CONSUL_ADDRESS", s.ConsulAddress)
+	serverImpl.ListenerAddress = "swarm-listener"
+
+	serverImpl.Execute([]string{})
+
+	s.False(invoked)
+}
+
 func (s *ServerTestSuite) Test_Execute_SetsConsulAddressesToEmptySlice_WhenEnvVarIsNotset() {
 	srv := Serve{}
 
@@ -350,6 +707,132 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatusNotFound_WhenUrlIsCertAndM
 	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 404)
 }
 
+func (s *ServerTestSuite) Test_ServeHTTP_RemovesCertAndReturnsRemainingCount_WhenUrlIsCertAndMethodIsDelete() {
+	actual := ""
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		RemoveMock: func(certName string) error {
+			actual = certName
+			return nil
+		},
+	}
+	getCertsOrig := getCerts
+	defer func() { getCerts = getCertsOrig }()
+	getCerts = func() map[string]string {
+		return map[string]string{"my-other-cert.pem": "", "my-third-cert.pem": ""}
+	}
+	expected, _ := json.Marshal(CertRemoveResponse{Status: "OK", Certs: 2})
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/cert?certName=my-cert.pem", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal(string(expected), rw.Body.String())
+	s.Equal("my-cert.pem", actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RemovesCaCert_WhenUrlIsCertAndCaIsTrue() {
+	actual := ""
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		RemoveCaMock: func(certName string) error {
+			actual = certName
+			return nil
+		},
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/cert?certName=my-ca.pem&ca=true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal("my-ca.pem", actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenUrlIsCertAndCertDoesNotExist() {
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		RemoveMock: func(certName string) error {
+			return &os.PathError{Op: "remove", Path: "/certs/" + certName, Err: os.ErrNotExist}
+		},
+	}
+	expected, _ := json.Marshal(CertRemoveResponse{Status: "NOK", Message: "The certificate my-cert.pem does not exist"})
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/cert?certName=my-cert.pem", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(404, rw.Code)
+	s.Equal(string(expected), rw.Body.String())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus502_WhenUrlIsCertAndDistributeRequestsFail() {
+	distributor := DistributorMock{
+		SendDistributeRequestsMock: func(req *http.Request, port, proxyServiceName string) (int, error) {
+			return http.StatusBadGateway, &server.DistributeError{Addresses: []string{"10.0.0.2"}}
+		},
+	}
+	expected, _ := json.Marshal(CertRemoveResponse{
+		Status:         "NOK",
+		Message:        "Could not send distribute request to the following addresses: [10.0.0.2]",
+		FailedReplicas: []string{"10.0.0.2"},
+	})
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/cert?certName=my-cert.pem&distribute=true", nil)
+
+	srv := NewServe(ServeDeps{Distributor: distributor})
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(502, rw.Code)
+	s.Equal(string(expected), rw.Body.String())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenUrlIsCertAndDistributeRequestsReturnErrorStatus() {
+	distributor := DistributorMock{
+		SendDistributeRequestsMock: func(req *http.Request, port, proxyServiceName string) (int, error) {
+			return http.StatusInternalServerError, nil
+		},
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/cert?certName=my-cert.pem&distribute=true", nil)
+
+	srv := NewServe(ServeDeps{Distributor: distributor})
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(500, rw.Code)
+	s.Contains(rw.Body.String(), "The distribute requests failed with the status 500")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsCertAndCertNameIsNotFileName() {
+	invoked := false
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		RemoveMock: func(certName string) error {
+			invoked = true
+			return nil
+		},
+	}
+
+	for _, certName := range []string{"", "../haproxy.cfg", "dir/my-cert.pem", "..", "%2e%2e%2fhaproxy.cfg", "my%20cert.pem"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/cert?certName="+certName, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, certName)
+	}
+	s.False(invoked)
+}
+
 // ServeHTTP > Certs
 
 func (s *ServerTestSuite) Test_ServeHTTP_InvokesCertGetAll_WhenUrlIsCerts() {
@@ -370,6 +853,24 @@ func (s *ServerTestSuite) Test_ServeHTTP_InvokesCertGetAll_WhenUrlIsCerts() {
 	s.Assert().True(invoked)
 }
 
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesCertPutAll_WhenUrlIsCertsAndMethodIsPut() {
+	invoked := false
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		PutAllMock: func(http.ResponseWriter, *http.Request) (server.CertArchiveResponse, error) {
+			invoked = true
+			return server.CertArchiveResponse{}, nil
+		},
+	}
+	req, _ := http.NewRequest("PUT", s.CertsUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Assert().True(invoked)
+}
+
 // ServeHTTP > Reconfigure
 
 func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToJSON_WhenUrlIsReconfigure() {
@@ -534,332 +1035,3188 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithSkipCheck_WhenPresent()
 	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_WritesErrorHeader_WhenReconfigureDistributeIsTrueAndError() {
-	serve := Serve{}
-	serve.Port = s.Port
-	addr := fmt.Sprintf("http://127.0.0.1:8080%s&distribute=true&returnError=true", s.ReconfigureUrl)
-	req, _ := http.NewRequest("GET", addr, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithAddresses_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&serviceAddress=10.0.0.1&addr.pink=10.0.0.2&addr.green=10.0.0.3", nil)
+	expected, _ := json.Marshal(Response{
+		Status:           "OK",
+		ServiceName:      s.ServiceName,
+		ServiceColor:     s.ServiceColor,
+		ServicePath:      s.ServicePath,
+		ServiceDomain:    s.ServiceDomain,
+		OutboundHostname: s.OutboundHostname,
+		ServiceAddress:   "10.0.0.1",
+		ColorAddresses:   map[string]string{"pink": "10.0.0.2", "green": "10.0.0.3"},
+	})
 
-	serve.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_WritesErrorHeader_WhenRemoveDistributeIsTrueAndError() {
-	serve := Serve{}
-	serve.Port = s.Port
-	addr := fmt.Sprintf("http://127.0.0.1:8080%s&distribute=true&returnError=true", s.RemoveUrl)
-	req, _ := http.NewRequest("GET", addr, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenAddressOfTheServiceColorIsNotPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&addr.green=10.0.0.3", nil)
 
-	serve.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsReconfigureAndServiceNameQueryIsNotPresent() {
-	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithCors_WhenPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&corsOrigins=https://my-domain.com,http://other-domain.com:8080&corsMethods=GET,POST&corsHeaders=Content-Type", nil)
+	expected, _ := json.Marshal(Response{
+		Status:           "OK",
+		ServiceName:      s.ServiceName,
+		ServiceColor:     s.ServiceColor,
+		ServicePath:      s.ServicePath,
+		ServiceDomain:    s.ServiceDomain,
+		OutboundHostname: s.OutboundHostname,
+		CorsOrigins:      []string{"https://my-domain.com", "http://other-domain.com:8080"},
+		CorsMethods:      []string{"GET", "POST"},
+		CorsHeaders:      []string{"Content-Type"},
+	})
 
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServicePathQueryIsNotPresent() {
-	url := fmt.Sprintf("%s?serviceName=my-service", s.ReconfigureBaseUrl)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenCorsOriginsIsInvalid() {
+	origins := []string{
+		"my-domain.com",
+		"ftp://my-domain.com",
+		"https://my-domain.com/path",
+		"https://my-domain.com,*",
+	}
+	for _, origin := range origins {
+		rw := getResponseWriterMock()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&corsOrigins="+origin, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		rw.AssertCalled(s.T(), "WriteHeader", 400)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServiceNameOrAclNameIsInvalid() {
+	for _, query := range []string{"serviceName=my%20service&servicePath=/api", "serviceName=stats&servicePath=/api", "serviceName=my-service&servicePath=/api&aclName=a/b"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_EscapesHtmlOfServiceDescriptionAndOwner() {
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	for _, version := range []string{"v1", "v2"} {
+		rw := httptest.NewRecorder()
+		url := strings.Replace(s.ReconfigureUrl, "/v1/", "/"+version+"/", 1) + "&serviceDescription=%3Cb%3EDemo%3C%2Fb%3E&owner=team-demo"
+		req, _ := http.NewRequest("GET", url, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal("<b>Demo</b>", actual.ServiceDescription)
+		s.Equal("team-demo", actual.Owner)
+		s.Contains(rw.Body.String(), `\u003cb\u003eDemo\u003c/b\u003e`, version)
+		s.NotContains(rw.Body.String(), "<b>", version)
+		s.Contains(rw.Body.String(), "team-demo", version)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsLastReload_WhenVerboseIsTrue() {
+	getLastReloadOrig := getLastReload
+	defer func() { getLastReload = getLastReloadOrig }()
+	getLastReload = func() *haproxy.ReloadEntry {
+		return &haproxy.ReloadEntry{Errors: &haproxy.ReloadErrors{ResponseErrors: 3, ConnectionErrors: 1}}
+	}
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return getReconfigureMock("")
+	}
+	for _, version := range []string{"v1", "v2"} {
+		rw := httptest.NewRecorder()
+		url := strings.Replace(s.ReconfigureUrl, "/v1/", "/"+version+"/", 1) + "&verbose=true"
+		req, _ := http.NewRequest("GET", url, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(200, rw.Code)
+		s.Contains(rw.Body.String(), `"Errors":{"ResponseErrors":3,"ConnectionErrors":1}`, version)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsReloadDeferredMs_WhenReloadIsDeferred() {
+	getDeferredReloadOrig := getDeferredReload
+	defer func() { getDeferredReload = getDeferredReloadOrig }()
+	getDeferredReload = func() time.Duration {
+		return 1500 * time.Millisecond
+	}
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return getReconfigureMock("")
+	}
+	expected := map[string]string{"v1": `"ReloadDeferredMs":1500`, "v2": `"reloadDeferredMs":1500`}
+	for version, field := range expected {
+		rw := httptest.NewRecorder()
+		url := strings.Replace(s.ReconfigureUrl, "/v1/", "/"+version+"/", 1)
+		req, _ := http.NewRequest("GET", url, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(200, rw.Code)
+		s.Contains(rw.Body.String(), field, version)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_FlushesDeferredReload_WhenForceIsTrue() {
+	getDeferredReloadOrig := getDeferredReload
+	flushDeferredReloadOrig := flushDeferredReload
+	defer func() {
+		getDeferredReload = getDeferredReloadOrig
+		flushDeferredReload = flushDeferredReloadOrig
+	}()
+	getDeferredReload = func() time.Duration {
+		return 1500 * time.Millisecond
+	}
+	flushed := 0
+	flushDeferredReload = func() error {
+		flushed++
+		return nil
+	}
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&force=true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal(1, flushed)
+	s.NotContains(rw.Body.String(), "ReloadDeferredMs")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotReturnLastReload_WhenVerboseIsNotTrue() {
+	getLastReloadOrig := getLastReload
+	defer func() { getLastReload = getLastReloadOrig }()
+	getLastReload = func() *haproxy.ReloadEntry {
+		return &haproxy.ReloadEntry{}
+	}
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.NotContains(rw.Body.String(), "LastReload")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsReloadErrorMetrics_WhenUrlIsMetrics() {
+	getReloadTotalsOrig := getReloadTotals
+	defer func() { getReloadTotals = getReloadTotalsOrig }()
+	getReloadTotals = func() haproxy.ReloadTotals {
+		return haproxy.ReloadTotals{Reloads: 5, SampledReloads: 4, ResponseErrors: 7, ConnectionErrors: 2, DeferredReloads: 8}
+	}
+	getDroppedSyslogLinesOrig := getDroppedSyslogLines
+	defer func() { getDroppedSyslogLines = getDroppedSyslogLinesOrig }()
+	getDroppedSyslogLines = func() int64 {
+		return 9
+	}
+	getQueueStatsOrig := getQueueStats
+	defer func() { getQueueStats = getQueueStatsOrig }()
+	getQueueStats = func() QueueStats {
+		return QueueStats{Depth: 3, Rejected: 4, Superseded: 6}
+	}
+	getCertExpiryStatusOrig := getCertExpiryStatus
+	defer func() { getCertExpiryStatus = getCertExpiryStatusOrig }()
+	getCertExpiryStatus = func() *CertExpiryStatus {
+		return &CertExpiryStatus{SoonestCert: "my-cert.pem", SoonestExpiry: time.Unix(1500000000, 0), Expiring: 2}
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/metrics", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Contains(rw.Body.String(), "# TYPE docker_flow_proxy_reloads_total counter\ndocker_flow_proxy_reloads_total 5\n")
+	s.Contains(rw.Body.String(), "docker_flow_proxy_sampled_reloads_total 4\n")
+	s.Contains(rw.Body.String(), "docker_flow_proxy_reload_response_errors_total 7\n")
+	s.Contains(rw.Body.String(), "docker_flow_proxy_reload_connection_errors_total 2\n")
+	s.Contains(rw.Body.String(), "docker_flow_proxy_deferred_reloads_total 8\n")
+	s.Contains(rw.Body.String(), "docker_flow_proxy_syslog_dropped_lines_total 9\n")
+	s.Contains(rw.Body.String(), "# TYPE docker_flow_proxy_queue_depth gauge\ndocker_flow_proxy_queue_depth 3\n")
+	s.Contains(rw.Body.String(), "docker_flow_proxy_queue_rejected_total 4\n")
+	s.Contains(rw.Body.String(), "docker_flow_proxy_queue_superseded_total 6\n")
+	s.Contains(rw.Body.String(), "# TYPE docker_flow_proxy_certs_expiring gauge\ndocker_flow_proxy_certs_expiring 2\n")
+	s.Contains(rw.Body.String(), "docker_flow_proxy_cert_soonest_expiry_timestamp_seconds 1500000000\n")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsRegistryAndListenerMetrics_WhenUrlIsMetrics() {
+	registry.RegistryMetrics.Reset()
+	actions.ListenerMetrics.Reset()
+	defer func() {
+		registry.RegistryMetrics.Reset()
+		actions.ListenerMetrics.Reset()
+	}()
+	registry.RegistryMetrics.Observe("put", "consul-1", 20*time.Millisecond, nil, false)
+	registry.RegistryMetrics.Observe("put", "consul-1", 20*time.Millisecond, fmt.Errorf("This is an error"), false)
+	actions.ListenerMetrics.Observe("notify_services", "http://swarm-listener:8080", time.Second, nil, false)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/metrics", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Contains(rw.Body.String(), `docker_flow_proxy_registry_operations_total{operation="put",address="consul-1"} 2`+"\n")
+	s.Contains(rw.Body.String(), `docker_flow_proxy_registry_operation_failures_total{operation="put",address="consul-1"} 1`+"\n")
+	s.Contains(rw.Body.String(), "# TYPE docker_flow_proxy_registry_operation_duration_seconds histogram\n")
+	s.Contains(rw.Body.String(), `docker_flow_proxy_listener_operations_total{operation="notify_services",address="http://swarm-listener:8080"} 1`+"\n")
+	s.Contains(rw.Body.String(), `docker_flow_proxy_listener_operation_duration_seconds_bucket{operation="notify_services",address="http://swarm-listener:8080",le="0.5"} 0`+"\n")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenCanaryHeaderIsInvalid() {
+	for _, query := range []string{"&canaryHeader=X-Canary", "&canaryHeader=X Canary:green", "&canaryHeader=X-Canary:green&addr.pink=10.0.0.1"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenDcAddressesAreInvalid() {
+	data := []struct{ mode, query string }{
+		{"swarm", "&port=1234&dc.east%20coast=10.0.0.1"},
+		{"", "&dc.east=10.0.0.1"},
+	}
+	for _, d := range data {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+d.query, nil)
+
+		srv := Serve{Mode: d.mode}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, d.query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUseDomainMapIsCombinedWithWildcardDomain() {
+	invoked := false
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		invoked = true
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	url := fmt.Sprintf("%s?serviceName=my-service&servicePath=/demo&serviceDomain=*.acme.com&useDomainMap=true", s.ReconfigureBaseUrl)
 	req, _ := http.NewRequest("GET", url, nil)
 
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus409_WhenServicePathShadowsApi() {
+	invoked := false
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		invoked = true
+		return getReconfigureMock("")
+	}
+	for _, path := range []string{"/v1/docker-flow-proxy", "/v1/docker-flow-proxy/reconfigure", "/demo,/v2/docker-flow-proxy"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath="+path, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(409, rw.Code, path)
+		s.Contains(rw.Body.String(), "would shadow the", path)
+	}
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_AcceptsPathsThatDoNotShadowApi() {
+	for _, query := range []string{"servicePath=/", "servicePath=/v1", "servicePath=/v1/docker-flow-proxy-ui", "servicePath=^/v1/docker-flow-proxy&pathType=path_reg"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(200, rw.Code, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_UsesReservedPaths_WhenSet() {
+	data := []struct {
+		reservedPaths, path string
+		expected            int
+	}{
+		{"none", "/v1/docker-flow-proxy", 200},
+		{"/admin/, /v1/docker-flow-proxy", "/admin/users", 409},
+		{"/admin", "/v1/docker-flow-proxy", 200},
+	}
+	for _, d := range data {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath="+d.path, nil)
+
+		srv := Serve{ReservedPaths: d.reservedPaths}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(d.expected, rw.Code, d.reservedPaths)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenBatchServicePathShadowsApi() {
+	rw := httptest.NewRecorder()
+	body := `{"Generation": 1, "Services": [{"serviceName": "my-service", "servicePath": "/v1/docker-flow-proxy"}]}`
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "would shadow the /v1/docker-flow-proxy API")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenBodyIsJson() {
+	for _, method := range []string{"PUT", "POST"} {
+		var actual actions.ServiceReconfigure
+		actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+			actual = serviceData
+			return getReconfigureMock("")
+		}
+		rw := httptest.NewRecorder()
+		body := `{"serviceName": "my-service", "servicePath": ["/path/a", "/path/b"], "port": 1234, "distribute": false}`
+		req, _ := http.NewRequest(method, s.ReconfigureBaseUrl, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		srv := Serve{Mode: "swarm"}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(200, rw.Code, method)
+		s.Equal("my-service", actual.ServiceName, method)
+		s.Equal([]string{"/path/a", "/path/b"}, actual.ServicePath, method)
+		s.Equal("1234", actual.Port, method)
+		response := Response{}
+		json.Unmarshal(rw.Body.Bytes(), &response)
+		s.Equal("OK", response.Status, method)
+		s.Equal("my-service", response.ServiceName, method)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_KeepsNumbersOfJsonBody() {
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	body := `{"serviceName": "my-service", "servicePath": "/path", "port": 8080, "maxconn": 1000000, "timeoutServer": 120}`
+	req, _ := http.NewRequest("PUT", s.ReconfigureBaseUrl, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal("8080", actual.Port)
+	s.Equal("1000000", actual.MaxConn)
+	s.Equal(120, actual.TimeoutServer)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenJsonBodyContainsObject() {
+	rw := httptest.NewRecorder()
+	body := `{"serviceName": "my-service", "servicePath": "/path", "port": {"value": 8080}}`
+	req, _ := http.NewRequest("PUT", s.ReconfigureBaseUrl, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "The port field must be a string, a number, a boolean or a list of them")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReconfigureQueriesTakePrecedenceOverJsonBody() {
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	body := `{"serviceName": "my-service", "servicePath": "/path", "port": "1234"}`
+	req, _ := http.NewRequest("PUT", s.ReconfigureBaseUrl+"?port=4321", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal("4321", actual.Port)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenJsonBodyDoesNotHaveServicePath() {
+	body := `{"serviceName": "my-service"}`
+	req, _ := http.NewRequest("PUT", s.ReconfigureBaseUrl, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	getReq, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service", nil)
+	expected := httptest.NewRecorder()
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(expected, getReq)
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Equal(expected.Body.String(), rw.Body.String())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenJsonBodyCannotBeParsed() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", s.ReconfigureBaseUrl, strings.NewReader(`{"serviceName":`))
+	req.Header.Set("Content-Type", "application/json")
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "Could not parse the request body")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenDcAddressesAreValid() {
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&port=1234&dc.east=10.0.0.1&dc.west_2=10.1.0.1", nil)
+
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal(map[string]string{"east": "10.0.0.1", "west_2": "10.1.0.1"}, actual.DcAddresses)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenCanaryHeaderIsValid() {
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&canaryHeader=X-Canary:green", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal("X-Canary:green", actual.CanaryHeader)
+	s.Contains(rw.Body.String(), `"CanaryHeader":"X-Canary:green"`)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsAllConstraintErrors_WhenParametersAreInvalid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&reqRepSearch=search&corsMethods=GET", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(400, rw.Code)
+	s.Equal("NOK", actual.Status)
+	s.Equal([]actions.ParameterError{
+		{Parameter: "reqRepSearch", Message: "The reqRepSearch query requires the reqRepReplace query"},
+		{Parameter: "corsMethods", Message: "The corsMethods query requires the corsOrigins query"},
+	}, actual.Errors)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenTracingSampleRateIsInvalid() {
+	for _, rate := range []string{"abc", "-0.1", "1.5"} {
+		rw := getResponseWriterMock()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&tracingSampleRate="+rate, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		rw.AssertCalled(s.T(), "WriteHeader", 400)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithInternalOnly_WhenPresent() {
+	defer func() { os.Unsetenv("INTERNAL_PORT") }()
+	os.Setenv("INTERNAL_PORT", "8081")
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&internalOnly=true", nil)
+	expected, _ := json.Marshal(Response{
+		Status:           "OK",
+		ServiceName:      s.ServiceName,
+		ServiceColor:     s.ServiceColor,
+		ServicePath:      s.ServicePath,
+		ServiceDomain:    s.ServiceDomain,
+		OutboundHostname: s.OutboundHostname,
+		InternalOnly:     true,
+	})
+
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenModeIsServiceAndPortIsNotPresent() {
-	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenInternalOnlyIsTrueAndInternalPortIsNotSet() {
+	os.Unsetenv("INTERNAL_PORT")
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&internalOnly=true", nil)
 
-	srv := Serve{Mode: "service"}
+	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
 	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenModeIsSwarmAndPortIsNotPresent() {
-	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_AppliesProfile_WhenProfileIsPresent() {
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	s.loadProfiles(`{"public-api": {"pathType": "path_reg", "outboundHostname": "from-profile"}}`)
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&profile=public-api", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("public-api", actual.Profile)
+	s.Equal("path_reg", actual.PathType)
+	s.Equal(s.OutboundHostname, actual.OutboundHostname)
+	s.Contains(actual.ExplicitParameters, "outboundHostname")
+	s.NotContains(actual.ExplicitParameters, "pathType")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenProfileDoesNotExist() {
+	s.loadProfiles(`{"public-api": {}, "internal": {}}`)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&profile=unknown", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "Available profiles: internal, public-api")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_WritesErrorHeader_WhenReconfigureDistributeIsTrueAndError() {
+	serve := Serve{}
+	serve.Port = s.Port
+	addr := fmt.Sprintf("http://127.0.0.1:8080%s&distribute=true&returnError=true", s.ReconfigureUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+
+	serve.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_WritesErrorHeader_WhenRemoveDistributeIsTrueAndError() {
+	serve := Serve{}
+	serve.Port = s.Port
+	addr := fmt.Sprintf("http://127.0.0.1:8080%s&distribute=true&returnError=true", s.RemoveUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+
+	serve.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsReconfigureAndServiceNameQueryIsNotPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServicePathQueryIsNotPresent() {
+	url := fmt.Sprintf("%s?serviceName=my-service", s.ReconfigureBaseUrl)
+	req, _ := http.NewRequest("GET", url, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenModeIsServiceAndPortIsNotPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+
+	srv := Serve{Mode: "service"}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenModeIsSwarmAndPortIsNotPresent() {
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+
+	srv := Serve{Mode: "swARM"}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute() {
+	s.ServiceReconfigure.AclName = "my-acl"
+	url := fmt.Sprintf("%s&aclName=my-acl", s.ReconfigureUrl)
+	req, _ := http.NewRequest("GET", url, nil)
+	//	s.RequestReconfigure.u
+	s.invokesReconfigure(req, true)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeReconfigureExecute_WhenDistributeIsTrue() {
+	req, _ := http.NewRequest(
+		"GET",
+		fmt.Sprintf("%s&distribute=true", s.ReconfigureUrl),
+		nil,
+	)
+	s.invokesReconfigure(req, false)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeRemoveExecute_WhenDistributeIsTrue() {
+	req, _ := http.NewRequest(
+		"GET",
+		fmt.Sprintf("%s&distribute=true", s.RemoveUrl),
+		nil,
+	)
+	s.invokesReconfigure(req, false)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReconfigureExecuteFails() {
+	mockObj := getReconfigureMock("Execute")
+	mockObj.On("Execute", []string{}).Return(fmt.Errorf("This is an error"))
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ExecutesReconfigureThroughTheQueue() {
+	defer func() { apiQueue = nil }()
+	apiQueue = newWorkQueue(1, 1)
+	mockObj := getReconfigureMock("Execute")
+	mockObj.On("Execute", []string{}).Return(fmt.Errorf("This is an error"))
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, s.RequestReconfigure)
+
+	s.Equal(500, rw.Code)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus503_WhenQueueIsFull() {
+	defer func() { apiQueue = nil }()
+	apiQueue = newWorkQueue(0, 1)
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	for _, req := range []*http.Request{s.RequestReconfigure, s.RequestRemove} {
+		rw := httptest.NewRecorder()
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(503, rw.Code)
+		s.Equal("5", rw.Header().Get("Retry-After"))
+		s.Contains(rw.Body.String(), `"Status":"NOK"`)
+	}
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+	s.Equal(int64(2), apiQueue.Stats().Rejected)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus422_WhenServiceExceedsConfigLimits() {
+	defer func() {
+		os.Unsetenv("MAX_SERVICE_PATHS")
+		os.Unsetenv("MAX_SERVICE_DOMAINS")
+	}()
+	os.Setenv("MAX_SERVICE_PATHS", "2")
+	os.Setenv("MAX_SERVICE_DOMAINS", "1")
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	for query, expected := range map[string]string{
+		"&servicePath=/a,/b,/c":                           "The paths of the service my-service (3) exceed the MAX_SERVICE_PATHS limit of 2",
+		"&servicePath=/a&serviceDomain=a.com,b.com":       "The domains of the service my-service (2) exceed the MAX_SERVICE_DOMAINS limit of 1",
+		"&servicePath=/a,/b,/c&serviceDomain=a.com,b.com": "MAX_SERVICE_PATHS",
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(422, rw.Code, query)
+		s.Contains(rw.Body.String(), `"Status":"NOK"`, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+	mockObj.AssertNotCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus422_WhenNewServiceExceedsServicesLimit() {
+	defer func() { os.Unsetenv("MAX_SERVICES") }()
+	os.Setenv("MAX_SERVICES", "2")
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{{ServiceName: "service-1"}, {ServiceName: "service-2"}}
+	}
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return getReconfigureMock("")
+	}
+	rwNew := httptest.NewRecorder()
+	reqNew, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=service-3&servicePath=/3", nil)
+	rwExisting := httptest.NewRecorder()
+	reqExisting, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=service-2&servicePath=/2", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rwNew, reqNew)
+	srv.ServeHTTP(rwExisting, reqExisting)
+
+	s.Equal(422, rwNew.Code)
+	s.Contains(rwNew.Body.String(), "The services (3) exceed the MAX_SERVICES limit of 2")
+	s.Equal(200, rwExisting.Code)
+	s.Contains(rwExisting.Body.String(), `"Warnings":["The services (2) are close to the MAX_SERVICES limit of 2"]`)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus422_WhenAssembledConfigExceedsLimits() {
+	mockObj := getReconfigureMock("Execute")
+	mockObj.On("Execute", []string{}).Return(&haproxy.ConfigLimitError{Limit: "MAX_CONFIG_SIZE", Subject: "bytes of the configuration", Value: 200, Max: 100})
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, s.RequestReconfigure)
+
+	s.Equal(422, rw.Code)
+	s.Contains(rw.Body.String(), "The bytes of the configuration (200) exceed the MAX_CONFIG_SIZE limit of 100")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJson_WhenConsulTemplatePathIsPresent() {
+	pathFe := "/path/to/consul/fe/template"
+	pathBe := "/path/to/consul/fe/template"
+	address := fmt.Sprintf(
+		"%s?serviceName=%s&consulTemplateFePath=%s&consulTemplateBePath=%s",
+		s.ReconfigureBaseUrl,
+		s.ServiceName,
+		pathFe,
+		pathBe)
+	req, _ := http.NewRequest("GET", address, nil)
+	expected, _ := json.Marshal(Response{
+		Status:               "OK",
+		ServiceName:          s.ServiceName,
+		ConsulTemplateFePath: pathFe,
+		ConsulTemplateBePath: pathBe,
+		PathType:             s.PathType,
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenConsulTemplatePathIsPresent() {
+	pathFe := "/path/to/consul/fe/template"
+	pathBe := "/path/to/consul/be/template"
+	mockObj := getReconfigureMock("")
+	var actualBase actions.BaseReconfigure
+	expectedBase := actions.BaseReconfigure{
+		ConsulAddresses: []string{s.ConsulAddress},
+	}
+	expectedService := actions.ServiceReconfigure{
+		ServiceName:          "myservice",
+		ServiceDisplayName:   s.ServiceName,
+		ConsulTemplateFePath: pathFe,
+		ConsulTemplateBePath: pathBe,
+		PathType:             s.PathType,
+	}
+	var actualService actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actualBase = baseData
+		actualService = serviceData
+		return mockObj
+	}
+	serverImpl := Serve{BaseReconfigure: expectedBase}
+	address := fmt.Sprintf(
+		"%s?serviceName=%s&consulTemplateFePath=%s&consulTemplateBePath=%s",
+		s.ReconfigureBaseUrl,
+		s.ServiceName,
+		pathFe,
+		pathBe)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(expectedBase, actualBase)
+	s.Equal(expectedService, actualService)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCert_WhenServiceCertIsPresent() {
+	actualCertName := ""
+	expectedCert := "my-cert with new line \\n"
+	actualCert := ""
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			actualCertName = certName
+			actualCert = string(certContent[:])
+			return "", nil
+		},
+	}
+	address := fmt.Sprintf(
+		"%s?serviceName=%s&servicePath=%s&serviceCert=%s",
+		s.ReconfigureBaseUrl,
+		s.ServiceName,
+		strings.Join(s.ServicePath, ","),
+		expectedCert,
+	)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("myservice", actualCertName)
+	s.Equal(strings.Replace(expectedCert, "\\n", "\n", -1), actualCert)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCertWithDomainName_WhenServiceCertIsPresent() {
+	actualCertName := ""
+	expectedCert := "my-cert"
+	actualCert := ""
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			actualCertName = certName
+			actualCert = string(certContent[:])
+			return "", nil
+		},
+	}
+	address := fmt.Sprintf("%s&serviceDomain=%s&serviceCert=%s", s.ReconfigureUrl, s.ServiceDomain[0], expectedCert)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(s.ServiceDomain[0], actualCertName)
+	s.Equal(expectedCert, actualCert)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokePutCert_WhenWildcardCertCoversDomains() {
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	actualCertNames := []string{}
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			actualCertNames = append(actualCertNames, certName)
+			return "", nil
+		},
+	}
+	for _, certs := range []map[string]string{
+		{"*.example.com": ""},
+		{"*.example.com": "", "api.example.com.pem": ""},
+	} {
+		proxyMock := getProxyMock("GetCerts")
+		proxyMock.On("GetCerts").Return(certs)
+		haproxy.Instance = proxyMock
+		address := fmt.Sprintf("%s?serviceName=api&servicePath=/api&serviceDomain=api.example.com,web.example.com&serviceCert=my-cert", s.ReconfigureBaseUrl)
+		req, _ := http.NewRequest("GET", address, nil)
+
+		serverImpl.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	s.Equal([]string{"api.example.com"}, actualCertNames)
+}
+
+// ServeHTTP > Golden responses
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsGoldenJson_WhenUrlIsReconfigureV1() {
+	s.verifyGoldenResponse(s.ReconfigureUrl+"&users=user1:pass1&port=1234", "reconfigure-v1.json")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsGoldenJson_WhenUrlIsReconfigureV2() {
+	url := strings.Replace(s.ReconfigureUrl, "/v1/", "/v2/", 1)
+	s.verifyGoldenResponse(url+"&users=user1:pass1&port=1234&addr.pink=10.0.0.2", "reconfigure-v2.json")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsGoldenJson_WhenUrlIsReconfigureV2AndQueriesAreMissing() {
+	s.verifyGoldenResponse("/v2/docker-flow-proxy/reconfigure?serviceName=myService", "reconfigure-v2-bad-request.json")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsGoldenJson_WhenUrlIsRemoveV2() {
+	s.verifyGoldenResponse("/v2/docker-flow-proxy/remove?serviceName=myService&aclName=my-acl", "remove-v2.json")
+}
+
+// ServeHTTP > Remove
+
+func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToJSON_WhenUrlIsRemove() {
+	var actual string
+	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
+		actual = value
+	}
+	req, _ := http.NewRequest("GET", s.RemoveUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("application/json", actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJSON_WhenUrlIsRemove() {
+	expected, _ := json.Marshal(Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, s.RequestRemove)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsRemoveAndServiceNameQueryIsNotPresent() {
+	req, _ := http.NewRequest("GET", s.RemoveBaseUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+// ServeHTTP > Challenge
+
+func (s *ServerTestSuite) Test_ServeHTTP_ServesStoredAcmeChallenge() {
+	defer func() { acmeChallenges = newAcmeChallengeStore() }()
+	srv := Serve{EnableAcmeChallenges: true}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "http://acme.com/v1/docker-flow-proxy/challenge?token=my-token_1&ttl=60", strings.NewReader("my-token_1.thumbprint\n"))
+
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	rw = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "http://acme.com/.well-known/acme-challenge/my-token_1", nil)
+
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal("text/plain", rw.Header().Get("Content-Type"))
+	s.Equal("my-token_1.thumbprint", rw.Body.String())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenAcmeChallengeDoesNotExist() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/.well-known/acme-challenge/my-token", nil)
+
+	srv := Serve{EnableAcmeChallenges: true}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(404, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RemovesAcmeChallenge_WhenMethodIsDelete() {
+	defer func() { acmeChallenges = newAcmeChallengeStore() }()
+	acmeChallenges.Put("my-token", "my-token.thumbprint", time.Minute)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/challenge?token=my-token", nil)
+
+	srv := Serve{EnableAcmeChallenges: true}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal(0, acmeChallenges.Len())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotServeAcmeChallenges_WhenDisabled() {
+	defer func() { acmeChallenges = newAcmeChallengeStore() }()
+	acmeChallenges.Put("my-token", "my-token.thumbprint", time.Minute)
+	srv := Serve{}
+	for _, req := range []*http.Request{
+		httptest.NewRequest("PUT", "http://acme.com/v1/docker-flow-proxy/challenge?token=other-token", strings.NewReader("other-token.thumbprint")),
+		httptest.NewRequest("GET", "http://acme.com/.well-known/acme-challenge/my-token", nil),
+	} {
+		rw := httptest.NewRecorder()
+
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(404, rw.Code)
+	}
+	s.Equal(1, acmeChallenges.Len())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenAcmeChallengeIsInvalid() {
+	defer func() { acmeChallenges = newAcmeChallengeStore() }()
+	data := []struct{ query, body string }{
+		{"", "my-token.thumbprint"},
+		{"?token=my/token", "my/token.thumbprint"},
+		{"?token=my-token", ""},
+		{"?token=my-token", "other-token.thumbprint"},
+	}
+	for _, d := range data {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "http://acme.com/v1/docker-flow-proxy/challenge"+d.query, strings.NewReader(d.body))
+
+		srv := Serve{EnableAcmeChallenges: true}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, d.query+" "+d.body)
+	}
+	s.Equal(0, acmeChallenges.Len())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus405_WhenChallengeMethodIsGet() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/challenge?token=my-token", nil)
+
+	srv := Serve{EnableAcmeChallenges: true}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(405, rw.Code)
+}
+
+// ServeHTTP > Stack
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus405_WhenStackMethodIsNotDelete() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/stack?name=shop", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(405, rw.Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenStackNameIsEmpty() {
+	invoked := false
+	newRemoveServices := func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		invoked = true
+		return getRemoveMock("")
+	}
+	for _, query := range []string{"", "?name=", "?name=%20%20"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/stack"+query, nil)
+
+		srv := NewServe(ServeDeps{NewRemoveServices: newRemoveServices})
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code)
+		s.Contains(rw.Body.String(), "The name query is mandatory")
+	}
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RemovesServicesPrefixedWithStackName() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = getStackServicesMock
+	var actual []actions.ServiceReconfigure
+	mockObj := getRemoveMock("")
+	newRemoveServices := func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		actual = services
+		return mockObj
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/stack?name=Shop", nil)
+
+	srv := NewServe(ServeDeps{NewRemoveServices: newRemoveServices})
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal([]actions.ServiceReconfigure{{ServiceName: "shop_api"}, {ServiceName: "shop_web", AclName: "web"}}, actual)
+	actualResponse := StackResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actualResponse)
+	s.Equal(StackResponse{Status: "OK", Services: []string{"shop_api", "shop_web"}}, actualResponse)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RemovesServicesWithStackNameMetadata() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = getStackServicesMock
+	var actual []actions.ServiceReconfigure
+	newRemoveServices := func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		actual = services
+		return getRemoveMock("")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/stack?name=monitoring", nil)
+
+	srv := NewServe(ServeDeps{NewRemoveServices: newRemoveServices})
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal([]actions.ServiceReconfigure{{ServiceName: "grafana", StackName: "monitoring"}}, actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotRemoveStackServices_WhenDryRunIsTrue() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = getStackServicesMock
+	invoked := false
+	newRemoveServices := func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		invoked = true
+		return getRemoveMock("")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/stack?name=shop&dryRun=true", nil)
+
+	srv := NewServe(ServeDeps{NewRemoveServices: newRemoveServices})
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	actual := StackResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(StackResponse{Status: "OK", DryRun: true, Services: []string{"shop_api", "shop_web"}}, actual)
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenStackRemovalFails() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = getStackServicesMock
+	mockObj := getRemoveMock("Execute")
+	mockObj.On("Execute", mock.Anything).Return(fmt.Errorf("This is an error"))
+	newRemoveServices := func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		return mockObj
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "http://acme.com/v1/docker-flow-proxy/stack?name=shop", nil)
+
+	srv := NewServe(ServeDeps{NewRemoveServices: newRemoveServices})
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(500, rw.Code)
+	s.Contains(rw.Body.String(), "This is an error")
+}
+
+func getStackServicesMock() []actions.ServiceReconfigure {
+	return []actions.ServiceReconfigure{
+		{ServiceName: "grafana", StackName: "monitoring"},
+		{ServiceName: "shop_api"},
+		{ServiceName: "shop_web", AclName: "web"},
+		{ServiceName: "shopping_cart"},
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesRemoveExecute() {
+	mockObj := getRemoveMock("")
+	aclName := "my-acl"
+	var actual Remove
+	expected := Remove{
+		ServiceName:     "myservice",
+		TemplatesPath:   "",
+		ConfigsPath:     "",
+		ConsulAddresses: []string{s.ConsulAddress},
+		InstanceName:    s.InstanceName,
+		AclName:         aclName,
+	}
+	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		actual = Remove{
+			ServiceName:     serviceName,
+			AclName:         aclName,
+			TemplatesPath:   templatesPath,
+			ConfigsPath:     configsPath,
+			ConsulAddresses: consulAddresses,
+			InstanceName:    instanceName,
+			Mode:            mode,
+		}
+		return mockObj
+	}
+	url := fmt.Sprintf("%s?serviceName=%s&aclName=%s", s.RemoveBaseUrl, s.ServiceName, aclName)
+	req, _ := http.NewRequest("GET", url, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(expected, actual)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+// ServeHTTP > Remove multiple services
+
+func (s *ServerTestSuite) Test_ServeHTTP_RemovesKnownServicesWithSingleReload_WhenServiceNameContainsMultipleNames() {
+	getServicesOrig, osRemoveOrig, proxyOrig := getServices, osRemove, haproxy.Instance
+	defer func() { getServices, osRemove, haproxy.Instance = getServicesOrig, osRemoveOrig, proxyOrig }()
+	getServices = getStackServicesMock
+	osRemove = func(name string) error { return nil }
+	proxyMock := getProxyMock("")
+	haproxy.Instance = proxyMock
+	configsPath, _ := ioutil.TempDir("", "configs")
+	defer os.RemoveAll(configsPath)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/remove?serviceName=shop_api,review-1&serviceName=grafana", nil)
+
+	srv := NewServe(ServeDeps{})
+	srv.ConfigsPath = configsPath
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	actual := RemoveResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(RemoveResponse{Status: "OK", Services: []RemovedServiceResponse{
+		{ServiceName: "shop_api", Status: "OK", Code: 200},
+		{ServiceName: "review-1", Status: "NOK", Code: 404, Message: "The service is not configured"},
+		{ServiceName: "grafana", Status: "OK", Code: 200},
+	}}, actual)
+	proxyMock.AssertNumberOfCalls(s.T(), "Reload", 1)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReportsServicesThatCouldNotBeRemoved_WhenServiceNameContainsMultipleNames() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = getStackServicesMock
+	var actual []actions.ServiceReconfigure
+	mockObj := getRemoveMock("Execute")
+	mockObj.On("Execute", mock.Anything).Return(&RemoveServicesError{Services: []string{"shop_web"}})
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/remove?serviceName=shop_api,shop_web", nil)
+
+	srv := NewServe(ServeDeps{NewRemoveServices: func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		actual = services
+		return mockObj
+	}})
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal([]actions.ServiceReconfigure{{ServiceName: "shop_api"}, {ServiceName: "shop_web", AclName: "web"}}, actual)
+	response := RemoveResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &response)
+	s.Equal("NOK", response.Status)
+	s.Equal(RemovedServiceResponse{ServiceName: "shop_api", Status: "OK", Code: 200}, response.Services[0])
+	s.Equal(RemovedServiceResponse{ServiceName: "shop_web", Status: "NOK", Code: 500, Message: "Could not remove the service"}, response.Services[1])
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenMultipleServicesCannotBeReloaded() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = getStackServicesMock
+	mockObj := getRemoveMock("Execute")
+	mockObj.On("Execute", mock.Anything).Return(fmt.Errorf("This is an error"))
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/remove?serviceName=shop_api,unknown", nil)
+
+	srv := NewServe(ServeDeps{NewRemoveServices: func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		return mockObj
+	}})
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(500, rw.Code)
+	response := RemoveResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &response)
+	s.Equal(RemovedServiceResponse{ServiceName: "shop_api", Status: "NOK", Code: 500, Message: "This is an error"}, response.Services[0])
+	s.Equal(404, response.Services[1].Code)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServiceNameContainsEmptyNames() {
+	invoked := false
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/remove?serviceName=shop_api,,shop_web", nil)
+
+	srv := NewServe(ServeDeps{NewRemoveServices: func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		invoked = true
+		return getRemoveMock("")
+	}})
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotRemoveMultipleServices_WhenDistributeIsTrue() {
+	invoked := false
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://acme.com/v1/docker-flow-proxy/remove?serviceName=shop_api,shop_web&distribute=true", nil)
+
+	srv := NewServe(ServeDeps{NewRemoveServices: func(services []actions.ServiceReconfigure, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		invoked = true
+		return getRemoveMock("")
+	}})
+	srv.ServiceName = "this-proxy-does-not-exist"
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(500, rw.Code)
+	s.Contains(rw.Body.String(), `"Distribute":true`)
+	s.False(invoked)
+}
+
+// ServeHTTP > Config
+
+func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToText_WhenUrlIsConfig() {
+	var actual string
+	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
+		actual = value
+	}
+	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("text/html", actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsConfig_WhenUrlIsConfig() {
+	expected := "some text"
+	readFileOrig := haproxy.ReadFile
+	defer func() { haproxy.ReadFile = readFileOrig }()
+	haproxy.ReadFile = func(filename string) ([]byte, error) {
+		return []byte(expected), nil
+	}
+
+	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReadFileFails() {
+	readFileOrig := readFile
+	defer func() { readFile = readFileOrig }()
+	readFile = func(filename string) ([]byte, error) {
+		return []byte(""), fmt.Errorf("This is an error")
+	}
+
+	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStandaloneConfig_WhenStandaloneIsTrue() {
+	readFileOrig := haproxy.ReadFile
+	createStandaloneConfigOrig := createStandaloneConfig
+	defer func() {
+		haproxy.ReadFile = readFileOrig
+		createStandaloneConfig = createStandaloneConfigOrig
+	}()
+	haproxy.ReadFile = func(filename string) ([]byte, error) {
+		return []byte("live config"), nil
+	}
+	actualConfig, actualPrefix := "", ""
+	createStandaloneConfig = func(config, pathPrefix string) (string, error) {
+		actualConfig, actualPrefix = config, pathPrefix
+		return "standalone config", nil
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ConfigUrl+"?standalone=true&pathPrefix=/opt/dr/", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal("standalone config", rw.Body.String())
+	s.Equal("live config", actualConfig)
+	s.Equal("/opt/dr/", actualPrefix)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenStandaloneConfigIsInvalid() {
+	readFileOrig := haproxy.ReadFile
+	createStandaloneConfigOrig := createStandaloneConfig
+	defer func() {
+		haproxy.ReadFile = readFileOrig
+		createStandaloneConfig = createStandaloneConfigOrig
+	}()
+	haproxy.ReadFile = func(filename string) ([]byte, error) {
+		return []byte("live config"), nil
+	}
+	createStandaloneConfig = func(config, pathPrefix string) (string, error) {
+		return "", fmt.Errorf("The configuration is invalid")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ConfigUrl+"?standalone=true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(500, rw.Code)
+	s.Equal("The configuration is invalid", rw.Body.String())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenPathPrefixIsRelative() {
+	readFileOrig := haproxy.ReadFile
+	defer func() { haproxy.ReadFile = readFileOrig }()
+	haproxy.ReadFile = func(filename string) ([]byte, error) {
+		return []byte("live config"), nil
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ConfigUrl+"?standalone=true&pathPrefix=opt/dr", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+}
+
+// ServeHTTP > Resync
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReloadsProfilesAndServices_WhenUrlIsResync() {
+	var actualPath string
+	loadProfilesOrig := loadProfiles
+	defer func() { loadProfiles = loadProfilesOrig }()
+	loadProfiles = func(path string) error {
+		actualPath = path
+		return nil
+	}
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/resync", nil)
+	srv := Serve{ProfilesPath: "/cfg/profiles.json", ListenerAddress: "swarm-listener"}
+	srv.ConsulAddresses = []string{s.ConsulAddress}
+	srv.InstanceName = s.InstanceName
+
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("/cfg/profiles.json", actualPath)
+	mockObj.AssertCalled(s.T(), "ReloadAllServices", []string{s.ConsulAddress}, s.InstanceName, "", "http://swarm-listener:8080")
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenResyncCannotLoadProfiles() {
+	loadProfilesOrig := loadProfiles
+	defer func() { loadProfiles = loadProfilesOrig }()
+	loadProfiles = func(path string) error {
+		return fmt.Errorf("This is an error")
+	}
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/resync", nil)
+	srv := Serve{ProfilesPath: "/cfg/profiles.json"}
+
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+	mockObj.AssertNotCalled(s.T(), "ReloadAllServices", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// ServeHTTP > Info
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsMigrationSummary_WhenUrlIsInfo() {
+	migration := registry.MigrationResult{
+		Migrated: []string{"docker-flow/go-demo"},
+		Failed:   map[string]string{"docker-flow/broken": "This is an error"},
+	}
+	expected, _ := json.Marshal(Info{Migration: &migration, Generation: 7})
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/info", nil)
+
+	srv := Serve{migration: &migration, generation: 7}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", expected)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsConsulStatus_WhenUrlIsInfoAndConsulAddressesAreSet() {
+	getConsulStatusOrig := getConsulStatus
+	defer func() { getConsulStatus = getConsulStatusOrig }()
+	status := registry.ConsulStatus{
+		Active: "http://consul-2:8500",
+		Addresses: []registry.AddressStatus{
+			{Address: "http://consul-1:8500", Healthy: false, Errors: 3},
+			{Address: "http://consul-2:8500", Healthy: true},
+		},
+	}
+	actualAddresses := []string{}
+	getConsulStatus = func(addresses []string) registry.ConsulStatus {
+		actualAddresses = addresses
+		return status
+	}
+	expected, _ := json.Marshal(Info{Consul: &status})
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/info", nil)
+
+	srv := Serve{BaseReconfigure: actions.BaseReconfigure{ConsulAddresses: []string{"http://consul-1:8500", "http://consul-2:8500"}}}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(string(expected), rw.Body.String())
+	s.Equal([]string{"http://consul-1:8500", "http://consul-2:8500"}, actualAddresses)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsCertExpiryStatus_WhenUrlIsInfo() {
+	getCertExpiryStatusOrig := getCertExpiryStatus
+	defer func() { getCertExpiryStatus = getCertExpiryStatusOrig }()
+	status := CertExpiryStatus{SoonestCert: "my-cert.pem", SoonestExpiry: time.Unix(1500000000, 0).UTC(), Expiring: 1}
+	getCertExpiryStatus = func() *CertExpiryStatus {
+		return &status
+	}
+	expected, _ := json.Marshal(Info{Certs: &status})
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/info", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(string(expected), rw.Body.String())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReportsHostHeaderEnforcement_WhenUrlIsInfo() {
+	expected, _ := json.Marshal(Info{RequireHostHeader: true, DenyHttp10: true})
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/info", nil)
+
+	defer haproxy.SetHostHeaderRules(false, false)
+	haproxy.SetHostHeaderRules(true, true)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Contains(rw.Body.String(), `"RequireHostHeader":true,"DenyHttp10":true`)
+	s.Equal(string(expected), rw.Body.String())
+}
+
+// ServeHTTP > Services
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsServices_WhenUrlIsServices() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{
+			{ServiceName: "fast-service", TimeoutServer: 10},
+			{ServiceName: "slow-service", TimeoutServer: 300, TimeoutTunnel: 3600, ServiceCert: "my-cert", Users: []actions.User{{Username: "user", Password: "pass"}}},
+			{ServiceName: "default-service"},
+		}
+	}
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"fast-service", "slow-service", "default-service"}},
+		{"?timeoutServerGt=60", []string{"slow-service"}},
+		{"?timeoutServerGt=1m", []string{"slow-service"}},
+		{"?timeoutServerLt=60", []string{"fast-service", "default-service"}},
+		{"?timeoutServerGt=5&timeoutServerLt=60", []string{"fast-service"}},
+		{"?timeoutTunnelGt=0", []string{"slow-service"}},
+	}
+	for _, c := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/services"+c.query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		actual := []ServiceParameters{}
+		json.Unmarshal(rw.Body.Bytes(), &actual)
+		names := []string{}
+		for _, service := range actual {
+			names = append(names, service.ServiceName)
+		}
+		s.Equal(200, rw.Code, c.query)
+		s.Equal(c.expected, names, c.query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotReturnCertsAndPasswords_WhenUrlIsServices() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{
+			{ServiceName: "my-service", TimeoutServer: 300, ServiceCert: "my-cert", Users: []actions.User{{Username: "user", Password: "pass"}}},
+		}
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/services", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Contains(rw.Body.String(), `"timeoutServer":300`)
+	s.Contains(rw.Body.String(), `"username":"user"`)
+	s.NotContains(rw.Body.String(), "my-cert")
+	s.NotContains(rw.Body.String(), `"password":"pass"`)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServicesFilterIsInvalid() {
+	for _, query := range []string{"?serviceName=my-service", "?timeoutServerGt=long", "?timeoutServer=60"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/services"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+	}
+}
+
+// ServeHTTP > Effective Service
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsEffectiveService_WhenUrlIsServiceEffective() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{
+			{ServiceName: "other-service"},
+			{
+				ServiceName:        "my-service",
+				Profile:            "public-api",
+				PathType:           "path_reg",
+				TimeoutServer:      300,
+				ServiceCert:        "my-cert",
+				Users:              []actions.User{{Username: "user", Password: "pass"}},
+				ExplicitParameters: []string{"serviceName", "profile", "timeoutServer", "users"},
+			},
+		}
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/services/my-service/effective", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := EffectiveServiceResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("OK", actual.Status)
+	s.Equal("my-service", actual.Service.ServiceName)
+	s.Equal("path_reg", actual.Service.PathType)
+	s.Equal(300, actual.Service.TimeoutServer)
+	s.Equal(30, actual.Service.TimeoutQueue)
+	s.Equal(actions.SourceRequest, actual.Sources["timeoutServer"])
+	s.Equal(actions.SourceProfile, actual.Sources["pathType"])
+	s.Equal(actions.SourceDefault, actual.Sources["timeoutQueue"])
+	s.NotContains(rw.Body.String(), "my-cert")
+	s.NotContains(rw.Body.String(), `"password":"pass"`)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenEffectiveServiceIsNotConfigured() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{{ServiceName: "other-service"}}
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/services/my-service/effective", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(404, rw.Code)
+	s.Contains(rw.Body.String(), "The service my-service is not configured")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenTimeoutIsInvalid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&timeoutServer=forever", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "The timeoutServer query must be a number of seconds or a duration")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenTimeoutClientIsInvalid() {
+	cases := map[string]string{
+		"&timeoutClient=soon&srcPort=8443": "The timeoutClient query must be a number of seconds or a duration",
+		"&timeoutClient=600":               "The timeoutClient query requires srcPort or tcp groups",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsTimeouts_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&timeoutServer=10m&timeoutClient=600&srcPort=8443&port=8080", nil)
+
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal(600, actual.TimeoutServer)
+	s.Equal(600, actual.TimeoutClient)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServiceDomainMatchAllIsInvalid() {
+	cases := map[string]string{
+		"&serviceDomainMatchAll=true":                                         "The serviceDomainMatchAll query requires the serviceDomain query",
+		"&serviceDomainMatchAll=true&serviceDomain=app.com&useDomainMap=true": "The serviceDomainMatchAll query cannot be combined with the useDomainMap query",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsServiceDomainMatchAll_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&serviceDomainMatchAll=true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.True(actual.ServiceDomainMatchAll)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenRedirectFromDomainIsInvalid() {
+	cases := map[string]string{
+		"&redirectFromDomain=old.com":                                     "The redirectFromDomain query requires the serviceDomain query",
+		"&redirectFromDomain=old.com&serviceDomain=*.new.com":             "The first serviceDomain cannot be a wildcard when the redirectFromDomain query is used",
+		"&redirectFromDomain=*.old.com&serviceDomain=new.com":             "The redirectFromDomain *.old.com is not a valid host name",
+		"&redirectFromDomain=old.com/path&serviceDomain=new.com":          "The redirectFromDomain old.com/path is not a valid host name",
+		"&redirectFromDomain=NEW.com&serviceDomain=new.com":               "The redirectFromDomain NEW.com is a domain of the service itself",
+		"&redirectFromDomain=www.new.com&serviceDomain=new.com,*.new.com": "The redirectFromDomain www.new.com is a domain of the service itself",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus409_WhenRedirectFromDomainConflictsWithOtherServices() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{
+			{ServiceName: "my-service", ServiceDomain: []string{"old.com"}},
+			{ServiceName: "shop", ServiceDomain: []string{"shop.com", "*.store.com"}},
+			{ServiceName: "legacy", ServiceDomain: []string{"legacy.com"}, RedirectFromDomain: []string{"legacy.org"}},
+		}
+	}
+	cases := map[string]string{
+		"&serviceDomain=new.com&redirectFromDomain=shop.com":     "The redirectFromDomain shop.com is used by the service shop",
+		"&serviceDomain=new.com&redirectFromDomain=eu.store.com": "The redirectFromDomain eu.store.com is used by the service shop",
+		"&serviceDomain=new.com&redirectFromDomain=legacy.org":   "The redirectFromDomain legacy.org is used by the service legacy",
+		"&serviceDomain=legacy.org":                              "The serviceDomain legacy.org is redirected by the service legacy",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(409, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsRedirectFromDomain_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&redirectFromDomain=old-brand.com,www.old-brand.com", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal([]string{"old-brand.com", "www.old-brand.com"}, actual.RedirectFromDomain)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsDenyHttp_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&denyHttp=true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.True(actual.DenyHttp)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenReqHeadersAreInvalid() {
+	cases := map[string]string{
+		"&addReqHeader=X-Env":                  "The addReqHeader query must contain a value for the header X-Env",
+		"&setReqHeader=X(Env) prod":            "The setReqHeader query contains the invalid header name X(Env)",
+		"&delReqHeader=X-Internal-Auth secret": "The delReqHeader query must contain only header names",
+		"&addResHeader=Cache-Control":          "The addResHeader query must contain a value for the header Cache-Control",
+		"&delResHeader=Server:nginx":           "The delResHeader query must contain only header names",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsReqHeaders_WhenJsonBodyIsSent() {
+	body := `{"serviceName": "my-service", "servicePath": ["/api"], "setReqHeader": ["Accept text/html, application/json"], "delReqHeader": ["X-Internal-Auth", "X-Debug"]}`
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal([]string{"Accept text/html, application/json"}, actual.SetReqHeader)
+	s.Equal([]string{"X-Internal-Auth", "X-Debug"}, actual.DelReqHeader)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsResHeaders_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&addResHeader=Cache-Control%20no-cache&delResHeader=Server,X-Powered-By", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal([]string{"Cache-Control no-cache"}, actual.AddResHeader)
+	s.Equal([]string{"Server", "X-Powered-By"}, actual.DelResHeader)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenForwardedProtoIsInvalid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&forwardedProto=yes", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "The forwardedProto query must be either true or false")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsForwardedProto_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&forwardedProto=false", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("false", actual.ForwardedProto)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenCompressionIsInvalid() {
+	cases := map[string]string{
+		"&compressionAlgo=brotli":                           "The compressionAlgo query must be one of identity, gzip, deflate, raw-deflate",
+		"&compressionType=application/json":                 "The compressionType query requires the compressionAlgo query",
+		"&compressionAlgo=gzip&compressionType=application": "The compressionType application is not a valid MIME type",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsCompression_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&compressionAlgo=gzip&compressionType=application/json,text/plain", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal([]string{"gzip"}, actual.CompressionAlgo)
+	s.Equal([]string{"application/json", "text/plain"}, actual.CompressionType)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenReqRateLimitIsInvalid() {
+	cases := map[string]string{
+		"&reqRateLimit=0":                      "The reqRateLimit query must be a positive number of requests",
+		"&reqRateLimit=many":                   "The reqRateLimit query must be a positive number of requests",
+		"&reqRateLimit=10&reqRatePeriod=often": "The reqRatePeriod query must be a number of seconds or a duration",
+		"&reqRatePeriod=10s":                   "The reqRatePeriod query requires the reqRateLimit query",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsReqRateLimit_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&reqRateLimit=100&reqRatePeriod=1m", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("100", actual.ReqRateLimit)
+	s.Equal(60, actual.ReqRatePeriod)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenAllowedSourceIPsAreInvalid() {
+	cases := map[string]string{
+		"&allowedSourceIPs=10.0.0.0/33":          "The invalid entry is 10.0.0.0/33",
+		"&allowedSourceIPs=10.0.0.0/8,localhost": "The invalid entry is localhost",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsAllowedSourceIPs_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&allowedSourceIPs=10.0.0.0/8,192.168.1.10,::1", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal([]string{"10.0.0.0/8", "192.168.1.10", "::1"}, actual.AllowedSourceIPs)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenBackendExtraContainsSectionKeywords() {
+	cases := map[string]string{
+		"&backendExtra=" + url.QueryEscape("retries 3\nfrontend evil"):  "The backendExtra query cannot contain the section keyword frontend",
+		"&backendExtra=" + url.QueryEscape(`retries 3\n  Backend evil`): "The backendExtra query cannot contain the section keyword backend",
+		"&backendExtra=" + url.QueryEscape("global"):                    "The backendExtra query cannot contain the section keyword global",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenFrontendExtraContainsSectionKeywords() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&frontendExtra="+url.QueryEscape("acl a path_beg /a\nlisten evil"), nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "The frontendExtra query cannot contain the section keyword listen")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsFrontendExtra_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&frontendExtra="+url.QueryEscape("http-request deny if { path_beg /api/internal }"), nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("http-request deny if { path_beg /api/internal }", actual.FrontendExtra)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsBackendExtra_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&backendExtra="+url.QueryEscape("option redispatch\nretries 3"), nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("option redispatch\nretries 3", actual.BackendExtra)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenCheckIsInvalid() {
+	cases := map[string]string{
+		"&checkMethod=HEAD":                             "The checkMethod query requires the checkPath query",
+		"&checkPath=healthz":                            "The checkPath query must be a path starting with / and without spaces",
+		"&checkPath=/healthz&checkMethod=get":           "The checkMethod query must be an HTTP method in upper case",
+		"&checkPath=/healthz&skipCheck=true":            "The checkPath query cannot be combined with the skipCheck query",
+		"&checkPath=/healthz&checkGrpc=true":            "The checkPath query cannot be combined with the checkGrpc query",
+		"&httpCheckExpect=status+204":                   "The httpCheckExpect query requires the checkPath query",
+		"&checkPath=/status&httpCheckExpect=body+READY": "The httpCheckExpect query must be status, rstatus, string or rstring followed by the expected value (e.g. string READY)",
+		"&checkPath=/status&httpCheckExpect=string":     "The httpCheckExpect query must be status, rstatus, string or rstring followed by the expected value (e.g. string READY)",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenCheckTuningIsInvalid() {
+	cases := map[string]string{
+		"&checkInterval=0":    "The checkInterval query must be a positive number of milliseconds or a duration (e.g. 500ms or 5s)",
+		"&checkInterval=5sec": "The checkInterval query must be a positive number of milliseconds or a duration (e.g. 500ms or 5s)",
+		"&checkTimeout=-1":    "The checkTimeout query must be a positive number of milliseconds or a duration (e.g. 500ms or 5s)",
+		"&checkRise=0":        "The checkRise query must be a positive number of checks",
+		"&checkFall=often":    "The checkFall query must be a positive number of checks",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsCheckTuning_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&checkInterval=5s&checkRise=2&checkFall=3&checkTimeout=2000", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("5s", actual.CheckInterval)
+	s.Equal("2", actual.CheckRise)
+	s.Equal("3", actual.CheckFall)
+	s.Equal("2000", actual.CheckTimeout)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsCheckPathAndCheckMethod_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&checkPath=/healthz&checkMethod=HEAD", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("/healthz", actual.CheckPath)
+	s.Equal("HEAD", actual.CheckMethod)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsHttpCheckExpect_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&checkPath=/status&httpCheckExpect=status+204", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("status 204", actual.HttpCheckExpect)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenBackupServerIsInvalid() {
+	cases := map[string]string{
+		"&backupPort=8080":                                "The backupPort query requires the backupHostname query",
+		"&backupHostname=static_host":                     "The backupHostname query must be a hostname or an IP address",
+		"&backupHostname=static.example.com&backupPort=0": "The backupPort query must be a port",
+		"&backupHostname=static.example.com":              "The backupPort query is mandatory when the port query is not set",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsBackupServer_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&backupHostname=static.example.com&backupPort=8080", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("static.example.com", actual.BackupHostname)
+	s.Equal("8080", actual.BackupPort)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenRetriesIsInvalid() {
+	for _, retries := range []string{"-1", "many"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&retries="+retries, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, retries)
+		s.Contains(rw.Body.String(), "The retries query must be a number of retries", retries)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsRetriesAndRedispatch_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&retries=3&redispatch=true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("3", actual.Retries)
+	s.True(actual.Redispatch)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenMaxConnIsInvalid() {
+	for _, maxConn := range []string{"0", "-1", "many"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&maxconn="+maxConn, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, maxConn)
+		s.Contains(rw.Body.String(), "The maxconn query must be a positive number of connections", maxConn)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsMaxConn_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&maxconn=50&timeoutQueue=30", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("50", actual.MaxConn)
+	s.Equal(30, actual.TimeoutQueue)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenWeightsAreInvalid() {
+	cases := map[string]string{
+		"&weight=0":                                    "The weight query must be a number between 1 and 256",
+		"&weight=257":                                  "The weight query must be a number between 1 and 256",
+		"&serviceColor=blue&weight.blue=high":          "The weight.blue query must be a number between 1 and 256",
+		"&serviceColor=blue&weight.green=10":           "The weight.green query requires the addr.green query",
+		"&weight=10&dc.east=10.0.0.1&dc.west=10.0.0.2": "The weight queries cannot be combined with the dc. queries",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api&port=1234"+query, nil)
+
+		srv := Serve{Mode: "swarm"}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsWeights_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&port=1234&weight=50&weight.pink=90&weight.green=10&addr.pink=10.0.0.2&addr.green=10.0.0.3", nil)
+
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("50", actual.Weight)
+	s.Equal(map[string]string{"pink": "90", "green": "10"}, actual.ColorWeights)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenRedirectWhenHttpProtoIsCombinedWithHttpsOnly() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&redirectWhenHttpProto=true&httpsOnly=true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "The redirectWhenHttpProto query cannot be combined with the httpsOnly query")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsRedirectWhenHttpProto_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&redirectWhenHttpProto=true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.True(actual.RedirectWhenHttpProto)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenMethodsAreInvalid() {
+	cases := map[string]string{
+		"&allowedMethods=GET,POST&deniedMethods=DELETE": "The allowedMethods query cannot be combined with the deniedMethods query",
+		"&allowedMethods=get":                           "The method get must be written in upper case letters",
+		"&deniedMethods=PUT,DELETE%20":                  "The method DELETE  must be written in upper case letters",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=my-service&servicePath=/api"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsMethods_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&allowedMethods=GET,POST", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal([]string{"GET", "POST"}, actual.AllowedMethods)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenConnectionReuseIsInvalid() {
+	cases := map[string]string{
+		"&httpReuse=sometimes":     "The httpReuse query must be one of never, safe, aggressive, always",
+		"&httpReuse=SAFE":          "The httpReuse query must be one of never, safe, aggressive, always",
+		"&poolMaxConn=many":        "The poolMaxConn query must be a number of connections or -1 for no limit",
+		"&poolMaxConn=-2":          "The poolMaxConn query must be a number of connections or -1 for no limit",
+		"&poolPurgeDelay=5 s":      "The poolPurgeDelay query must be a duration",
+		"&poolPurgeDelay=5seconds": "The poolPurgeDelay query must be a duration",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+strings.Replace(query, " ", "%20", -1), nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsConnectionReuse_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&httpReuse=always&poolMaxConn=-1&poolPurgeDelay=5s", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("always", actual.HttpReuse)
+	s.Equal("-1", actual.PoolMaxConn)
+	s.Equal("5s", actual.PoolPurgeDelay)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenSessionTypeIsInvalid() {
+	cases := map[string]string{
+		"&sessionType=sticky":                               "The sessionType query must be sticky-server",
+		"&cookieName=MY_SESSION":                            "The cookieName query requires sessionType to be set to sticky-server",
+		"&sessionType=sticky-server&cookieName=my%20cookie": "The cookieName query can contain only letters, digits, underscores and hyphens",
+	}
+	for query, expected := range cases {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenBalanceIsInvalid() {
+	for _, balance := range []string{"random", "LEASTCONN", "hdr()", "hdr(X-User", "roundrobin%20leastconn"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&balance="+balance, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, balance)
+		s.Contains(rw.Body.String(), "The balance query must be one of roundrobin, leastconn, source, uri or hdr(", balance)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsBalance_WhenReconfigureIsValid() {
+	for _, balance := range []string{"roundrobin", "leastconn", "source", "uri", "hdr(X-User)"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&balance="+balance, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		actual := Response{}
+		json.Unmarshal(rw.Body.Bytes(), &actual)
+		s.Equal(200, rw.Code, balance)
+		s.Equal(balance, actual.Balance)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenConnectionModeIsInvalid() {
+	for _, mode := range []string{"keep-alive", "HTTPCLOSE", "http-tunnel"} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&connectionMode="+mode, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, mode)
+		s.Contains(rw.Body.String(), "The connectionMode query must be one of http-keep-alive, http-server-close, httpclose, forceclose", mode)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsConnectionMode_WhenReconfigureIsValid() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&connectionMode=http-server-close", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("http-server-close", actual.ConnectionMode)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenPostReloadHookIsNotDefined() {
+	s.loadReloadHooks(`{"refresh-dns": "/scripts/refresh-dns.sh", "warm-cache": "/scripts/warm-cache.sh"}`)
+	defer s.loadReloadHooks(`{}`)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&postReloadHook=rm-rf", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "The postReloadHook rm-rf is not defined in HOOKS_FILE. Available hooks: refresh-dns, warm-cache")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsPostReloadHook_WhenItIsDefined() {
+	s.loadReloadHooks(`{"refresh-dns": "/scripts/refresh-dns.sh"}`)
+	defer s.loadReloadHooks(`{}`)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&postReloadHook=refresh-dns", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Equal("refresh-dns", actual.PostReloadHook)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400AndDoesNotStoreServiceCert_WhenCertNameIsNotValid() {
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	invoked := false
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			invoked = true
+			return "", nil
+		},
+	}
+	for _, query := range []string{
+		"?serviceName=my-service&servicePath=/demo&serviceCert=cert&serviceDomain=%2e%2e%2fevil.com",
+		"?serviceName=my-service&servicePath=/demo&serviceCert=cert&serviceDomain=../../etc/cron.d/evil",
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), "The serviceCert query cannot be stored", query)
+	}
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecuteWithCertName_WhenCertExists() {
+	getCertsOrig := getCerts
+	defer func() { getCerts = getCertsOrig }()
+	getCerts = func() map[string]string {
+		return map[string]string{"my-cert.pem": "CERT"}
+	}
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&certName=my-cert.pem", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal("my-cert.pem", actual.CertName)
+	s.Empty(actual.ServiceCert)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenCertNameIsInvalid() {
+	getCertsOrig := getCerts
+	defer func() { getCerts = getCertsOrig }()
+	getCerts = func() map[string]string {
+		return map[string]string{"my-cert.pem": "CERT"}
+	}
+	for query, expected := range map[string]string{
+		"&certName=other-cert.pem":              "The certificate other-cert.pem referenced by the certName query does not exist",
+		"&certName=%2e%2e%2fmy-cert.pem":        "The certificate name",
+		"&certName=my-cert.pem&serviceCert=PEM": "The certName query cannot be combined with the serviceCert query",
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenMaintenanceQueriesAreInvalid() {
+	for query, expected := range map[string]string{
+		"&maintenance=sometimes":           "The maintenance query must be either true or false",
+		"&maintenanceWindow=2am-3am":       "The maintenance window 2am-3am is not valid",
+		"&maintenanceWindow=02:00-03:00@X": "The maintenance window 02:00-03:00@X is not valid",
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenTcpGroupsAreInvalid() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{{ServiceName: "other-service", TcpDestinations: []actions.TcpDestination{{SrcPort: 9100, Port: "9100"}}}}
+	}
+	for query, expected := range map[string]string{
+		"&port=8080&reqMode.1=tcp&srcPort.1=abc&port.1=9000":               "the srcPort queries must be ports",
+		"&port=8080&reqMode.1=tcp&srcPort.1=9000":                          "The port query of the tcp group with the srcPort 9000 is mandatory",
+		"&port=8080&reqMode.1=tcp&srcPort.1=443&port.1=9000":               "The srcPort 443 is used by the proxy",
+		"&port=8080&srcPort.1=9000&port.1=9000&srcPort.2=9000&port.2=9001": "The srcPort 9000 is used by more than one tcp group",
+		"&port=8080&reqMode.1=tcp&srcPort.1=9100&port.1=9000":              "The srcPort 9100 is already used by the service other-service",
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+query, nil)
+
+		srv := Serve{Mode: "swarm", Port: "8080"}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenSrcPortIsUsedByPrometheusFrontend() {
+	defer func() {
+		os.Unsetenv("ENABLE_HAPROXY_PROMETHEUS")
+		os.Unsetenv("PROMETHEUS_PORT")
+	}()
+	os.Setenv("ENABLE_HAPROXY_PROMETHEUS", "true")
+	os.Setenv("PROMETHEUS_PORT", "9101")
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&port=8080&reqMode.1=tcp&srcPort.1=9101&port.1=9000", nil)
+
+	srv := Serve{Mode: "swarm", Port: "8080"}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "The srcPort 9101 is used by the proxy")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenOnlyHttpsPortIsSet() {
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&httpsPort=8443", nil)
+
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal("8443", actual.HttpsPort)
+	response := Response{}
+	json.Unmarshal(rw.Body.Bytes(), &response)
+	s.Equal("8443", response.HttpsPort)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenHttpsPortIsUsedInDefaultMode() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&httpsPort=8443", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "The httpsPort query can be used only when MODE is set to")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenHttpGroupsAreInvalid() {
+	for query, expected := range map[string]string{
+		"&port=8080&reqMode.1=http&port.1=8081":                                                     "The servicePath query of each http group with another port or serviceDomain is mandatory",
+		"&port=8080&port.1=8081&servicePath.1=/admin&useDomainMap=true&serviceDomain=my-domain.com": "cannot be used by services with useDomainMap set to true",
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+query, nil)
+
+		srv := Serve{Mode: "swarm", Port: "8080"}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&port=8080&port.1=8081&servicePath.1=/admin", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "can be used only when MODE is set to")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenServiceHasMultipleHttpGroups() {
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	url := s.ReconfigureBaseUrl + "?serviceName=my-service&port.1=8080&servicePath.1=/api&port.2=8081&servicePath.2=/admin"
+	req, _ := http.NewRequest("GET", url, nil)
+
+	srv := Serve{Mode: "swarm", Port: "8080"}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal("8080", actual.Port)
+	s.Equal([]string{"/api"}, actual.ServicePath)
+	s.Equal([]actions.HttpDestination{{Port: "8081", ServicePath: []string{"/admin"}}}, actual.HttpDestinations)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenSrcPortIsInvalid() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{
+			{ServiceName: "tcp-service", TcpDestinations: []actions.TcpDestination{{SrcPort: 9100, Port: "9100"}}},
+			{ServiceName: "http-service", SrcPort: "8443"},
+		}
+	}
+	for query, expected := range map[string]string{
+		"&port=8080&srcPort=abc":                              "The srcPort query must be a port",
+		"&port=8080&srcPort=70000":                            "The srcPort query must be a port",
+		"&port=8080&srcPort=443":                              "The srcPort 443 is used by the proxy",
+		"&port=8080&srcPort=9100":                             "The srcPort 9100 is used by the service tcp-service in the tcp mode",
+		"&port=8080&srcPort=8443&useDomainMap=true":           "cannot be used by services with useDomainMap set to true",
+		"&port=8080&srcPort=9000&srcPort.1=9000&port.1=9000":  "The srcPort 9000 cannot be used by both tcp and http groups",
+		"&port=8080&reqMode.1=tcp&srcPort.1=8443&port.1=9000": "The srcPort 8443 is used by the service http-service in the http mode",
+		"&port=8080&srcPort=8443":                             "The srcPort 8443 is already used by the service http-service",
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&serviceDomain=my-domain.com"+query, nil)
+
+		srv := Serve{Mode: "swarm", Port: "8080"}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenSrcPortIsSetOutsideSwarmMode() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&port=8080&srcPort=8443", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), `The srcPort query can be used only when MODE is set to \"service\" or \"swarm\"`)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenServiceHasHttpAndTcpGroups() {
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	url := s.ReconfigureBaseUrl + "?serviceName=my-service&reqMode.1=http&port.1=8080&servicePath.1=/api&reqMode.2=tcp&srcPort.2=9000&port.2=9000"
+	req, _ := http.NewRequest("GET", url, nil)
+
+	srv := Serve{Mode: "swarm", Port: "8080"}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.Equal("8080", actual.Port)
+	s.Equal([]string{"/api"}, actual.ServicePath)
+	s.Equal([]actions.TcpDestination{{SrcPort: 9000, Port: "9000"}}, actual.TcpDestinations)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenReqModeIsTcp() {
+	var actual actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actual = serviceData
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=postgres&reqMode=tcp&srcPort=5432&port=5432", nil)
+
+	srv := Serve{Mode: "swarm", Port: "8080"}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(200, rw.Code)
+	s.True(actual.IsTcp())
+	s.Equal([]actions.TcpDestination{{SrcPort: 5432, Port: "5432"}}, actual.GetTcpDestinations())
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenReqModeIsTcpAndTcpOptionsAreSet() {
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return getReconfigureMock("")
+	}
+	for _, query := range []string{
+		"&allowedSourceIPs=10.0.0.0/8",
+		"&backupHostname=postgres-replica",
+		"&backendExtra=balance%20leastconn",
+		"&frontendExtra=tcp-request%20inspect-delay%205s",
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=postgres&reqMode=tcp&srcPort=5432&port=5432"+query, nil)
+
+		srv := Serve{Mode: "swarm", Port: "8080"}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(200, rw.Code, query+" "+rw.Body.String())
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenReqModeIsInvalid() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{
+			{ServiceName: "mqtt", ReqMode: "tcp", SrcPort: "1883", Port: "1883"},
+			{ServiceName: "web", SrcPort: "8443", Port: "8080"},
+		}
+	}
+	for query, expected := range map[string]string{
+		"&reqMode=udp&servicePath=/db":                               "The reqMode query must be either http or tcp",
+		"&reqMode=tcp":                                               "The reqMode=tcp query requires the srcPort query",
+		"&reqMode=tcp&srcPort=5432&servicePath=/db":                  "The servicePath query cannot be combined with the reqMode=tcp query",
+		"&reqMode=tcp&srcPort=5432&serviceDomain=db.com":             "The serviceDomain query cannot be combined with the reqMode=tcp query",
+		"&reqMode=tcp&srcPort=5432&users=user:pass":                  "The users query cannot be combined with the reqMode=tcp query",
+		"&reqMode=tcp&srcPort=5432&reqRepSearch=a":                   "The reqRepSearch query cannot be combined with the reqMode=tcp query",
+		"&reqMode=tcp&srcPort=5432&allowedMethods=GET":               "The allowedMethods query cannot be combined with the reqMode=tcp query",
+		"&reqMode=tcp&srcPort=5432&sessionType=sticky-server":        "The sessionType query cannot be combined with the reqMode=tcp query",
+		"&reqMode=tcp&srcPort=5432&sslVerifyNone=true":               "The sslVerifyNone query cannot be combined with the reqMode=tcp query",
+		"&reqMode=tcp&srcPort=5432&port.1=8081&servicePath.1=/admin": "The servicePath.N query cannot be used when reqMode is tcp",
+		"&reqMode=tcp&srcPort=db":                                    "the srcPort queries must be ports",
+		"&reqMode=tcp&srcPort=443":                                   "The srcPort 443 is used by the proxy",
+		"&reqMode=tcp&srcPort=1883":                                  "The srcPort 1883 is already used by the service mqtt",
+		"&reqMode=tcp&srcPort=8443":                                  "The srcPort 8443 is used by the service web in the http mode",
+		"&reqMode=tcp&srcPort=5432&srcPort.1=5432&port.1=80":         "The srcPort 5432 is used by more than one tcp group",
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=postgres&port=5432"+query, nil)
+
+		srv := Serve{Mode: "swarm", Port: "8080"}
+		srv.ServeHTTP(rw, req)
+
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureBaseUrl+"?serviceName=postgres&port=5432&reqMode=tcp&srcPort=5432", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), `The reqMode query can be set to tcp only when MODE is set to \"service\" or \"swarm\"`)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenHttpSrcPortIsUsedByTcpService() {
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{{ServiceName: "mqtt", ReqMode: "tcp", SrcPort: "1883", Port: "1883"}}
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&port=8080&srcPort=1883", nil)
 
-	srv := Serve{Mode: "swARM"}
-	srv.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{Mode: "swarm", Port: "8080"}
+	srv.ServeHTTP(rw, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "The srcPort 1883 is used by the service mqtt in the tcp mode")
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute() {
-	s.ServiceReconfigure.AclName = "my-acl"
-	url := fmt.Sprintf("%s&aclName=my-acl", s.ReconfigureUrl)
-	req, _ := http.NewRequest("GET", url, nil)
-	//	s.RequestReconfigure.u
-	s.invokesReconfigure(req, true)
-}
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenClientCertQueriesAreInvalid() {
+	caCertsDirOrig := haproxy.CaCertsDir
+	defer func() { haproxy.CaCertsDir = caCertsDirOrig }()
+	haproxy.CaCertsDir = "proxy/test_configs/ca"
+	for query, expected := range map[string]string{
+		"&clientCertVerify=always&clientCertCaFile=my-ca.pem":   "The clientCertVerify query must be either required or optional",
+		"&clientCertVerify=required":                            "The clientCertVerify query requires the clientCertCaFile query",
+		"&clientCertCaFile=my-ca.pem":                           "The clientCertCaFile query requires the clientCertVerify query",
+		"&clientCertVerify=required&clientCertCaFile=../ca.pem": "The clientCertCaFile query must be a file name",
+		"&clientCertVerify=required&clientCertCaFile=other.pem": "The CA file other.pem cannot be used",
+		"&sslCaCert=../ca.pem":                                  "The sslCaCert query must be a file name",
+		"&sslCaCert=other.pem":                                  "The CA file other.pem cannot be used",
+		"&sslCaCert=my-ca.pem&sslVerifyNone=true":               "The sslVerifyNone query cannot be combined with the sslCaCert query",
+	} {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", s.ReconfigureUrl+query, nil)
 
-func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeReconfigureExecute_WhenDistributeIsTrue() {
-	req, _ := http.NewRequest(
-		"GET",
-		fmt.Sprintf("%s&distribute=true", s.ReconfigureUrl),
-		nil,
-	)
-	s.invokesReconfigure(req, false)
-}
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
 
-func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeRemoveExecute_WhenDistributeIsTrue() {
-	req, _ := http.NewRequest(
-		"GET",
-		fmt.Sprintf("%s&distribute=true", s.RemoveUrl),
-		nil,
-	)
-	s.invokesReconfigure(req, false)
+		s.Equal(400, rw.Code, query)
+		s.Contains(rw.Body.String(), expected, query)
+	}
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReconfigureExecuteFails() {
-	mockObj := getReconfigureMock("Execute")
-	mockObj.On("Execute", []string{}).Return(fmt.Errorf("This is an error"))
-	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
-		return mockObj
-	}
+// ServeHTTP > Validate
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus405_WhenValidateIsNotPost() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/validate?serviceName=my-service", nil)
 
 	srv := Serve{}
-	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+	srv.ServeHTTP(rw, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+	s.Equal(405, rw.Code)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJson_WhenConsulTemplatePathIsPresent() {
-	pathFe := "/path/to/consul/fe/template"
-	pathBe := "/path/to/consul/fe/template"
-	address := fmt.Sprintf(
-		"%s?serviceName=%s&consulTemplateFePath=%s&consulTemplateBePath=%s",
-		s.ReconfigureBaseUrl,
-		s.ServiceName,
-		pathFe,
-		pathBe)
-	req, _ := http.NewRequest("GET", address, nil)
-	expected, _ := json.Marshal(Response{
-		Status:               "OK",
-		ServiceName:          s.ServiceName,
-		ConsulTemplateFePath: pathFe,
-		ConsulTemplateBePath: pathBe,
-		PathType:             s.PathType,
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsChecks_WhenUrlIsValidate() {
+	simulateOrig := simulate
+	defer func() { simulate = simulateOrig }()
+	var actualSr actions.ServiceReconfigure
+	simulate = func(base actions.BaseReconfigure, sr actions.ServiceReconfigure, certs map[string]string) []actions.Check {
+		actualSr = sr
+		return []actions.Check{{Name: "certs", Status: actions.CheckWarn, Messages: []string{"This is a warning"}}}
+	}
+	expected, _ := json.Marshal(ValidateResponse{
+		Status: "OK",
+		Checks: []actions.Check{
+			{Name: "parameters", Status: actions.CheckPass},
+			{Name: "certs", Status: actions.CheckWarn, Messages: []string{"This is a warning"}},
+		},
 	})
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8080/v1/docker-flow-proxy/validate?serviceName=my-service&servicePath=/api&port=1234", nil)
 
-	srv := Serve{}
-	srv.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+	s.Equal(200, rw.Code)
+	s.Equal(string(expected), rw.Body.String())
+	s.Equal("my-service", actualSr.ServiceName)
+	s.Equal("swarm", actualSr.Mode)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenConsulTemplatePathIsPresent() {
-	pathFe := "/path/to/consul/fe/template"
-	pathBe := "/path/to/consul/be/template"
-	mockObj := getReconfigureMock("")
-	var actualBase actions.BaseReconfigure
-	expectedBase := actions.BaseReconfigure{
-		ConsulAddresses: []string{s.ConsulAddress},
-	}
-	expectedService := actions.ServiceReconfigure{
-		ServiceName:          s.ServiceName,
-		ConsulTemplateFePath: pathFe,
-		ConsulTemplateBePath: pathBe,
-		PathType:             s.PathType,
-	}
-	var actualService actions.ServiceReconfigure
-	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
-		actualBase = baseData
-		actualService = serviceData
-		return mockObj
+func (s *ServerTestSuite) Test_ServeHTTP_DecodesJsonBody_WhenUrlIsValidate() {
+	simulateOrig := simulate
+	defer func() { simulate = simulateOrig }()
+	var actualSr actions.ServiceReconfigure
+	simulate = func(base actions.BaseReconfigure, sr actions.ServiceReconfigure, certs map[string]string) []actions.Check {
+		actualSr = sr
+		return []actions.Check{}
 	}
-	serverImpl := Serve{BaseReconfigure: expectedBase}
-	address := fmt.Sprintf(
-		"%s?serviceName=%s&consulTemplateFePath=%s&consulTemplateBePath=%s",
-		s.ReconfigureBaseUrl,
-		s.ServiceName,
-		pathFe,
-		pathBe)
-	req, _ := http.NewRequest("GET", address, nil)
+	body := `{"serviceName": "my-service", "servicePath": ["/api", "/admin"], "port": 1234}`
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8080/v1/docker-flow-proxy/validate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
 
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
 
-	s.Equal(expectedBase, actualBase)
-	s.Equal(expectedService, actualService)
-	mockObj.AssertCalled(s.T(), "Execute", []string{})
+	s.Equal(200, rw.Code)
+	s.Equal([]string{"/api", "/admin"}, actualSr.ServicePath)
+	s.Equal("1234", actualSr.Port)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCert_WhenServiceCertIsPresent() {
-	actualCertName := ""
-	expectedCert := "my-cert with new line \\n"
-	actualCert := ""
-	certOrig := cert
-	defer func() { cert = certOrig }()
-	cert = CertMock{
-		PutCertMock: func(certName string, certContent []byte) (string, error) {
-			actualCertName = certName
-			actualCert = string(certContent[:])
-			return "", nil
-		},
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenValidateParametersAreInvalid() {
+	simulateOrig := simulate
+	defer func() { simulate = simulateOrig }()
+	called := false
+	simulate = func(base actions.BaseReconfigure, sr actions.ServiceReconfigure, certs map[string]string) []actions.Check {
+		called = true
+		return []actions.Check{}
 	}
-	address := fmt.Sprintf(
-		"%s?serviceName=%s&servicePath=%s&serviceCert=%s",
-		s.ReconfigureBaseUrl,
-		s.ServiceName,
-		strings.Join(s.ServicePath, ","),
-		expectedCert,
-	)
-	req, _ := http.NewRequest("GET", address, nil)
-
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8080/v1/docker-flow-proxy/validate?servicePath=/api", nil)
 
-	s.Equal(s.ServiceName, actualCertName)
-	s.Equal(strings.Replace(expectedCert, "\\n", "\n", -1), actualCert)
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := ValidateResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(400, rw.Code)
+	s.Equal("NOK", actual.Status)
+	s.Equal("parameters", actual.Checks[0].Name)
+	s.Equal(actions.CheckFail, actual.Checks[0].Status)
+	s.NotEmpty(actual.Checks[0].Messages)
+	s.False(called)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCertWithDomainName_WhenServiceCertIsPresent() {
-	actualCertName := ""
-	expectedCert := "my-cert"
-	actualCert := ""
-	certOrig := cert
-	defer func() { cert = certOrig }()
-	cert = CertMock{
-		PutCertMock: func(certName string, certContent []byte) (string, error) {
-			actualCertName = certName
-			actualCert = string(certContent[:])
-			return "", nil
-		},
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenValidateCheckFails() {
+	simulateOrig := simulate
+	defer func() { simulate = simulateOrig }()
+	simulate = func(base actions.BaseReconfigure, sr actions.ServiceReconfigure, certs map[string]string) []actions.Check {
+		return []actions.Check{{Name: "conflicts", Status: actions.CheckFail, Messages: []string{"This is a conflict"}}}
 	}
-	address := fmt.Sprintf("%s&serviceDomain=%s&serviceCert=%s", s.ReconfigureUrl, s.ServiceDomain[0], expectedCert)
-	req, _ := http.NewRequest("GET", address, nil)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8080/v1/docker-flow-proxy/validate?serviceName=my-service&servicePath=/api&port=1234", nil)
 
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
 
-	s.Equal(s.ServiceDomain[0], actualCertName)
-	s.Equal(expectedCert, actualCert)
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), `"Status":"NOK"`)
+	s.Contains(rw.Body.String(), "This is a conflict")
 }
 
-// ServeHTTP > Remove
+// ServeHTTP > Reconfigure Batch
 
-func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToJSON_WhenUrlIsRemove() {
-	var actual string
-	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
-		actual = value
+func (s *ServerTestSuite) Test_ServeHTTP_AppliesBatches_WhenGenerationsAreOutOfOrderOrDuplicated() {
+	applied := []string{}
+	newReconfigureBatch := func(baseData actions.BaseReconfigure, services []actions.ServiceReconfigure) actions.Executable {
+		for _, sr := range services {
+			applied = append(applied, sr.ServiceName)
+		}
+		return getReconfigureMock("")
 	}
-	req, _ := http.NewRequest("GET", s.RemoveUrl, nil)
+	srv := NewServe(ServeDeps{NewReconfigureBatch: newReconfigureBatch})
+	for _, generation := range []int{2, 1, 2, 3} {
+		rw := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"generation": %d, "services": [{"serviceName": "service-%d", "servicePath": ["/api"]}]}`, generation, generation)
+		req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
 
-	srv := Serve{}
-	srv.ServeHTTP(s.ResponseWriter, req)
+		srv.ServeHTTP(rw, req)
 
-	s.Equal("application/json", actual)
-}
+		s.Equal(200, rw.Code)
+	}
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJSON_WhenUrlIsRemove() {
-	expected, _ := json.Marshal(Response{
-		Status:      "OK",
-		ServiceName: s.ServiceName,
-	})
+	s.Equal([]string{"service-2", "service-3"}, applied)
+	s.Equal(int64(3), srv.generation)
+}
 
-	srv := Serve{}
-	srv.ServeHTTP(s.ResponseWriter, s.RequestRemove)
+func (s *ServerTestSuite) Test_ServeHTTP_DecodesBatchServices() {
+	var actual []actions.ServiceReconfigure
+	newReconfigureBatch := func(baseData actions.BaseReconfigure, services []actions.ServiceReconfigure) actions.Executable {
+		actual = services
+		return getReconfigureMock("")
+	}
+	body := `{"generation": 1, "services": [{"serviceName": "my-service", "servicePath": ["/api/v1", "/api/v2"], "skipCheck": true, "corsOrigins": "*"}]}`
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	srv := NewServe(ServeDeps{NewReconfigureBatch: newReconfigureBatch})
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	s.Equal([]actions.ServiceReconfigure{{
+		ServiceName: "my-service",
+		ServicePath: []string{"/api/v1", "/api/v2"},
+		SkipCheck:   true,
+		CorsOrigins: []string{"*"},
+	}}, actual)
+}
 
-	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400AndKeepsGeneration_WhenBatchServiceIsInvalid() {
+	invoked := false
+	newReconfigureBatch := func(baseData actions.BaseReconfigure, services []actions.ServiceReconfigure) actions.Executable {
+		invoked = true
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	body := `{"generation": 5, "services": [{"serviceName": "my-service", "servicePath": "/api"}, {"serviceName": "no-path"}]}`
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	srv := NewServe(ServeDeps{NewReconfigureBatch: newReconfigureBatch})
+	srv.generation = 4
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+	s.Contains(rw.Body.String(), "Service 1 (no-path)")
+	s.False(invoked)
+	s.Equal(int64(4), srv.generation)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsRemoveAndServiceNameQueryIsNotPresent() {
-	req, _ := http.NewRequest("GET", s.RemoveBaseUrl, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_KeepsGeneration_WhenBatchFails() {
+	newReconfigureBatch := func(baseData actions.BaseReconfigure, services []actions.ServiceReconfigure) actions.Executable {
+		mockObj := getReconfigureMock("Execute")
+		mockObj.On("Execute", mock.Anything).Return(fmt.Errorf("This is an error"))
+		return mockObj
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(`{"generation": 5, "services": []}`))
+	req.Header.Set("Content-Type", "application/json")
 
-	srv := Serve{}
-	srv.ServeHTTP(s.ResponseWriter, req)
+	srv := NewServe(ServeDeps{NewReconfigureBatch: newReconfigureBatch})
+	srv.generation = 4
+	srv.ServeHTTP(rw, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.Equal(500, rw.Code)
+	s.Equal(int64(4), srv.generation)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesRemoveExecute() {
-	mockObj := getRemoveMock("")
-	aclName := "my-acl"
-	var actual Remove
-	expected := Remove{
-		ServiceName:     s.ServiceName,
-		TemplatesPath:   "",
-		ConfigsPath:     "",
-		ConsulAddresses: []string{s.ConsulAddress},
-		InstanceName:    s.InstanceName,
-		AclName:         aclName,
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus422AndKeepsGeneration_WhenBatchExceedsServicesLimit() {
+	defer func() { os.Unsetenv("MAX_SERVICES") }()
+	os.Setenv("MAX_SERVICES", "2")
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{{ServiceName: "service-1"}}
 	}
-	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
-		actual = Remove{
-			ServiceName:     serviceName,
-			AclName:         aclName,
-			TemplatesPath:   templatesPath,
-			ConfigsPath:     configsPath,
-			ConsulAddresses: consulAddresses,
-			InstanceName:    instanceName,
-			Mode:            mode,
-		}
+	invoked := false
+	newReconfigureBatch := func(baseData actions.BaseReconfigure, services []actions.ServiceReconfigure) actions.Executable {
+		invoked = true
+		return getReconfigureMock("")
+	}
+	rw := httptest.NewRecorder()
+	// Each service fits the limit on its own but not together with the others
+	body := `{"generation": 5, "services": [{"serviceName": "service-1", "servicePath": "/1"}, {"serviceName": "service-2", "servicePath": "/2"}, {"serviceName": "service-3", "servicePath": "/3"}]}`
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	srv := NewServe(ServeDeps{NewReconfigureBatch: newReconfigureBatch})
+	srv.generation = 4
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(422, rw.Code)
+	s.Contains(rw.Body.String(), "The services (3) exceed the MAX_SERVICES limit of 2")
+	s.False(invoked)
+	s.Equal(int64(4), srv.generation)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus422_WhenAssembledBatchConfigExceedsLimits() {
+	newReconfigureBatch := func(baseData actions.BaseReconfigure, services []actions.ServiceReconfigure) actions.Executable {
+		mockObj := getReconfigureMock("Execute")
+		mockObj.On("Execute", mock.Anything).Return(&haproxy.ConfigLimitError{Limit: "MAX_CONFIG_SIZE", Subject: "bytes of the configuration", Value: 200, Max: 100})
 		return mockObj
 	}
-	url := fmt.Sprintf("%s?serviceName=%s&aclName=%s", s.RemoveBaseUrl, s.ServiceName, aclName)
-	req, _ := http.NewRequest("GET", url, nil)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(`{"generation": 5, "services": []}`))
+	req.Header.Set("Content-Type", "application/json")
 
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	srv := NewServe(ServeDeps{NewReconfigureBatch: newReconfigureBatch})
+	srv.ServeHTTP(rw, req)
 
-	s.Equal(expected, actual)
-	mockObj.AssertCalled(s.T(), "Execute", []string{})
+	s.Equal(422, rw.Code)
+	s.Contains(rw.Body.String(), "MAX_CONFIG_SIZE")
+	s.Equal(int64(0), srv.generation)
 }
 
-// ServeHTTP > Config
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenBatchGenerationIsMissing() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(`{"services": []}`))
+	req.Header.Set("Content-Type", "application/json")
 
-func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToText_WhenUrlIsConfig() {
-	var actual string
-	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
-		actual = value
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal(400, rw.Code)
+}
+
+// ServeHTTP > GC
+
+func (s *ServerTestSuite) Test_ServeHTTP_CollectsGarbage_WhenUrlIsGc() {
+	actualDryRun := false
+	collectGarbage = func(paths []string, dryRun bool) (actions.GarbageResult, error) {
+		actualDryRun = dryRun
+		return actions.GarbageResult{DryRun: dryRun, Orphans: []string{"/cfg/tmpl/old-be.cfg"}}, nil
 	}
-	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
+	expected, _ := json.Marshal(actions.GarbageResult{DryRun: true, Orphans: []string{"/cfg/tmpl/old-be.cfg"}})
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8080/v1/docker-flow-proxy/gc?dryRun=true", nil)
 
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.Equal("text/html", actual)
+	s.True(actualDryRun)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", expected)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsConfig_WhenUrlIsConfig() {
-	expected := "some text"
-	readFileOrig := haproxy.ReadFile
-	defer func() { haproxy.ReadFile = readFileOrig }()
-	haproxy.ReadFile = func(filename string) ([]byte, error) {
-		return []byte(expected), nil
-	}
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus405_WhenGcMethodIsNotPost() {
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/gc", nil)
 
-	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 405)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReadFileFails() {
-	readFileOrig := readFile
-	defer func() { readFile = readFileOrig }()
-	readFile = func(filename string) ([]byte, error) {
-		return []byte(""), fmt.Errorf("This is an error")
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenGcFails() {
+	collectGarbage = func(paths []string, dryRun bool) (actions.GarbageResult, error) {
+		return actions.GarbageResult{}, fmt.Errorf("This is an error")
 	}
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8080/v1/docker-flow-proxy/gc", nil)
 
-	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
 	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
 }
 
+// ServeHTTP > Parameters
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsParametersAndConstraints_WhenUrlIsParameters() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080/v1/docker-flow-proxy/parameters", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	actual := ParametersResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	s.Equal(200, rw.Code)
+	s.Contains(actual.Parameters, "serviceName")
+	s.Equal(actions.ReconfigureConstraints, actual.Constraints)
+}
+
+func (s *ServerTestSuite) loadProfiles(content string) {
+	profilesFile, _ := ioutil.TempFile("", "profiles")
+	defer os.Remove(profilesFile.Name())
+	profilesFile.WriteString(content)
+	profilesFile.Close()
+	actions.LoadProfiles(profilesFile.Name())
+}
+
+func (s *ServerTestSuite) loadReloadHooks(content string) {
+	hooksFile, _ := ioutil.TempFile("", "hooks")
+	defer os.Remove(hooksFile.Name())
+	hooksFile.WriteString(content)
+	hooksFile.Close()
+	haproxy.LoadReloadHooks(hooksFile.Name())
+}
+
 // Suite
 
 func TestServerUnitTestSuite(t *testing.T) {
 	s := new(ServerTestSuite)
-	logPrintf = func(format string, v ...interface{}) {}
+	log.SetOutput(ioutil.Discard)
 	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		actualPath := r.URL.Path
 		if r.Method == "GET" {
@@ -885,13 +4242,6 @@ func TestServerUnitTestSuite(t *testing.T) {
 	}))
 	defer func() { s.Server.Close() }()
 	addr := strings.Replace(s.Server.URL, "http://", "", -1)
-	s.DnsIps = []string{strings.Split(addr, ":")[0]}
-
-	lookupHostOrig := lookupHost
-	defer func() { lookupHost = lookupHostOrig }()
-	lookupHost = func(host string) (addrs []string, err error) {
-		return s.DnsIps, nil
-	}
 
 	s.Port = strings.Split(addr, ":")[1]
 
@@ -946,11 +4296,22 @@ func getResponseWriterMock() *ResponseWriterMock {
 	return mockObj
 }
 
+type DistributorMock struct {
+	SendDistributeRequestsMock func(req *http.Request, port, proxyServiceName string) (int, error)
+}
+
+func (m DistributorMock) SendDistributeRequests(req *http.Request, port, proxyServiceName string) (int, error) {
+	return m.SendDistributeRequestsMock(req, port, proxyServiceName)
+}
+
 type CertMock struct {
-	PutMock     func(http.ResponseWriter, *http.Request) (string, error)
-	PutCertMock func(certName string, certContent []byte) (string, error)
-	GetAllMock  func(w http.ResponseWriter, req *http.Request) (server.CertResponse, error)
-	GetInitMock func() error
+	PutMock      func(http.ResponseWriter, *http.Request) (string, error)
+	PutCertMock  func(certName string, certContent []byte) (string, error)
+	PutAllMock   func(w http.ResponseWriter, req *http.Request) (server.CertArchiveResponse, error)
+	GetAllMock   func(w http.ResponseWriter, req *http.Request) (server.CertResponse, error)
+	GetInitMock  func() error
+	RemoveMock   func(certName string) error
+	RemoveCaMock func(certName string) error
 }
 
 func (m CertMock) Put(w http.ResponseWriter, req *http.Request) (string, error) {
@@ -961,10 +4322,22 @@ func (m CertMock) PutCert(certName string, certContent []byte) (string, error) {
 	return m.PutCertMock(certName, certContent)
 }
 
+func (m CertMock) PutAll(w http.ResponseWriter, req *http.Request) (server.CertArchiveResponse, error) {
+	return m.PutAllMock(w, req)
+}
+
 func (m CertMock) GetAll(w http.ResponseWriter, req *http.Request) (server.CertResponse, error) {
 	return m.GetAllMock(w, req)
 }
 
+func (m CertMock) Remove(certName string) error {
+	return m.RemoveMock(certName)
+}
+
+func (m CertMock) RemoveCa(certName string) error {
+	return m.RemoveCaMock(certName)
+}
+
 func (m CertMock) Init() error {
 	return m.GetInitMock()
 }
@@ -1029,6 +4402,26 @@ func getReconfigureMock(skipMethod string) *ReconfigureMock {
 
 // Util
 
+func (s *ServerTestSuite) verifyGoldenResponse(url, goldenFile string) {
+	mockObj := getRemoveMock("")
+	newRemoveOrig := NewRemove
+	defer func() { NewRemove = newRemoveOrig }()
+	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		return mockObj
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", url, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	var actual bytes.Buffer
+	json.Indent(&actual, rw.Body.Bytes(), "", "  ")
+	expected, err := ioutil.ReadFile(fmt.Sprintf("test_configs/responses/%s", goldenFile))
+	s.NoError(err)
+	s.Equal(strings.TrimSpace(string(expected)), actual.String())
+}
+
 func (s *ServerTestSuite) invokesReconfigure(req *http.Request, invoke bool) {
 	mockObj := getReconfigureMock("")
 	var actualBase actions.BaseReconfigure
@@ -1048,9 +4441,13 @@ func (s *ServerTestSuite) invokesReconfigure(req *http.Request, invoke bool) {
 
 	serverImpl.ServeHTTP(s.ResponseWriter, req)
 
+	// Service names are decoded into their canonical form
+	expectedService := s.ServiceReconfigure
+	expectedService.ServiceName = actions.CanonicalServiceName(s.ServiceName)
+	expectedService.ServiceDisplayName = s.ServiceName
 	if invoke {
 		s.Equal(expectedBase, actualBase)
-		s.Equal(s.ServiceReconfigure, actualService)
+		s.Equal(expectedService, actualService)
 		mockObj.AssertCalled(s.T(), "Execute", []string{})
 	} else {
 		mockObj.AssertNotCalled(s.T(), "Execute", []string{})
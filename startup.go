@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"./proxy"
+	"./registry"
+)
+
+// startupDiffLimit is the maximum number of changed lines included in the diff summary.
+const startupDiffLimit = 20
+
+type startupStorer interface {
+	PutStartupState(addresses []string, instanceName string, state registry.StartupState) error
+	GetStartupState(addresses []string, instanceName string) (*registry.StartupState, error)
+}
+
+var startupStore startupStorer = registry.Consul{}
+
+// startupGate holds the state of the startup verification. The configuration is verified once it is created from
+// the registry and, if it must be confirmed, the proxy is not reloaded until the confirm-startup request arrives.
+type startupGate struct {
+	mu       sync.Mutex
+	verified bool
+	pending  bool
+	diff     []string
+}
+
+var startup = &startupGate{}
+
+type StartupResponse struct {
+	Status  string
+	Message string
+	Pending bool
+	Diff    []string `json:",omitempty"`
+}
+
+// verifyStartupConfig compares the configuration created from the registry with the one stored by the previous run
+// and logs the differences. It returns false if the reload must wait for the confirmation.
+func (m *Serve) verifyStartupConfig() bool {
+	config, err := proxy.Instance.ReadConfig()
+	if err != nil {
+		m.logPrintf("The startup configuration could not be verified\n%s", err.Error())
+		return true
+	}
+	startup.mu.Lock()
+	defer startup.mu.Unlock()
+	startup.verified = true
+	previous, err := startupStore.GetStartupState(m.ConsulAddresses, m.InstanceName)
+	if err != nil {
+		m.logPrintf("The startup configuration could not be verified\n%s", err.Error())
+		return true
+	} else if previous == nil {
+		m.logPrintf("There is no configuration stored by a previous run to verify the startup configuration against")
+		return true
+	} else if previous.Hash == getConfigHash(config) {
+		m.logPrintf("The startup configuration matches the one stored by the previous run")
+		return true
+	}
+	diff := getConfigDiff(previous.Config, config)
+	m.logPrintf("The startup configuration differs from the one stored by the previous run\n%s", strings.Join(diff, "\n"))
+	if !m.StartupConfirm {
+		return true
+	}
+	startup.pending = true
+	startup.diff = diff
+	m.logPrintf("The proxy will be reloaded once the startup is confirmed through /v1/docker-flow-proxy/confirm-startup")
+	return false
+}
+
+// saveStartupConfig stores the current configuration as the last known good one. Nothing is stored if the startup
+// configuration was not verified or waits for the confirmation.
+func (m *Serve) saveStartupConfig() error {
+	startup.mu.Lock()
+	defer startup.mu.Unlock()
+	if !startup.verified || startup.pending {
+		return nil
+	}
+	return m.putStartupConfig()
+}
+
+func (m *Serve) putStartupConfig() error {
+	config, err := proxy.Instance.ReadConfig()
+	if err != nil {
+		return err
+	}
+	state := registry.StartupState{Hash: getConfigHash(config), Config: config}
+	return startupStore.PutStartupState(m.ConsulAddresses, m.InstanceName, state)
+}
+
+// confirmStartup reloads the proxy with the configuration that waits for the confirmation. GET requests return the
+// differences that are waiting.
+func (m *Serve) confirmStartup(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	startup.mu.Lock()
+	defer startup.mu.Unlock()
+	response := StartupResponse{Status: "OK", Pending: startup.pending, Diff: startup.diff}
+	status := http.StatusOK
+	if req.Method == "GET" {
+		if !startup.pending {
+			response.Message = "The startup does not wait for a confirmation"
+		}
+	} else if req.Method != "POST" {
+		response = StartupResponse{Status: "NOK", Message: "The confirm-startup endpoint allows only GET and POST requests"}
+		status = http.StatusMethodNotAllowed
+	} else if !startup.pending {
+		response = StartupResponse{Status: "NOK", Message: "The startup does not wait for a confirmation"}
+		status = http.StatusConflict
+	} else if err := proxy.Instance.Reload(); err != nil {
+		response.Status = "NOK"
+		response.Message = err.Error()
+		status = http.StatusInternalServerError
+	} else {
+		m.logPrintf("The startup was confirmed")
+		startup.pending = false
+		response.Pending = false
+		response.Message = "The proxy was reloaded"
+		if err := m.putStartupConfig(); err != nil {
+			m.logPrintf(err.Error())
+		}
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// ping responds with the status 503 while the startup waits for the confirmation.
+func (m *Serve) ping(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	startup.mu.Lock()
+	pending := startup.pending
+	startup.mu.Unlock()
+	if pending {
+		js, _ := json.Marshal(StatusResponse{Status: "NOK", Message: "The startup waits for a confirmation"})
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(js)
+		return
+	}
+	js, _ := json.Marshal(StatusResponse{Status: "OK"})
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+func getConfigHash(config string) string {
+	sum := sha256.Sum256([]byte(config))
+	return hex.EncodeToString(sum[:])
+}
+
+// getConfigDiff summarizes the lines added to and removed from the configuration. The order of lines is ignored so
+// that services written in a different order do not show up as changes.
+func getConfigDiff(previous, current string) []string {
+	counts := map[string]int{}
+	for _, line := range getConfigLines(previous) {
+		counts[line]--
+	}
+	for _, line := range getConfigLines(current) {
+		counts[line]++
+	}
+	lines := []string{}
+	for line := range counts {
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	added, removed := []string{}, []string{}
+	for _, line := range lines {
+		for i := 0; i < counts[line]; i++ {
+			added = append(added, "+ "+line)
+		}
+		for i := 0; i > counts[line]; i-- {
+			removed = append(removed, "- "+line)
+		}
+	}
+	diff := []string{fmt.Sprintf("%d lines added, %d lines removed", len(added), len(removed))}
+	changed := append(removed, added...)
+	if len(changed) > startupDiffLimit {
+		diff = append(diff, changed[:startupDiffLimit]...)
+		diff = append(diff, fmt.Sprintf("... and %d more", len(changed)-startupDiffLimit))
+	} else {
+		diff = append(diff, changed...)
+	}
+	return diff
+}
+
+func getConfigLines(config string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(config, "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
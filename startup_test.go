@@ -0,0 +1,246 @@
+// +build !integration
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	haproxy "./proxy"
+	"./registry"
+)
+
+type StartupTestSuite struct {
+	suite.Suite
+	srv       *Serve
+	proxyMock *ProxyMock
+	storeMock *StartupStoreMock
+}
+
+func (s *StartupTestSuite) SetupTest() {
+	startup = &startupGate{}
+	s.srv = &Serve{StartupConfirm: true}
+	s.srv.ConsulAddresses = []string{"http://consul:8500"}
+	s.srv.InstanceName = "my-proxy"
+	s.proxyMock = getProxyMock("ReadConfig")
+	s.proxyMock.On("ReadConfig").Return("global\nbackend go-demo-be8080\n", nil)
+	haproxy.Instance = s.proxyMock
+	s.storeMock = getStartupStoreMock("")
+	startupStore = s.storeMock
+}
+
+func (s *StartupTestSuite) setPreviousConfig(config string) {
+	s.storeMock = getStartupStoreMock("GetStartupState")
+	s.storeMock.On("GetStartupState", mock.Anything, mock.Anything).Return(
+		&registry.StartupState{Hash: getConfigHash(config), Config: config},
+		nil,
+	)
+	startupStore = s.storeMock
+}
+
+func (s *StartupTestSuite) serve(method, path string) (*httptest.ResponseRecorder, StartupResponse) {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, "http://acme.com/v1/docker-flow-proxy/"+path, nil)
+	s.srv.ServeHTTP(rw, req)
+	actual := StartupResponse{}
+	json.Unmarshal(rw.Body.Bytes(), &actual)
+	return rw, actual
+}
+
+// verifyStartupConfig
+
+func (s *StartupTestSuite) Test_VerifyStartupConfig_ReturnsTrue_WhenNothingWasStored() {
+	s.True(s.srv.verifyStartupConfig())
+	s.storeMock.AssertCalled(s.T(), "GetStartupState", []string{"http://consul:8500"}, "my-proxy")
+	s.False(startup.pending)
+}
+
+func (s *StartupTestSuite) Test_VerifyStartupConfig_ReturnsTrue_WhenConfigMatchesStoredHash() {
+	s.setPreviousConfig("global\nbackend go-demo-be8080\n")
+
+	s.True(s.srv.verifyStartupConfig())
+	s.False(startup.pending)
+}
+
+func (s *StartupTestSuite) Test_VerifyStartupConfig_ReturnsFalse_WhenConfigDiffersAndConfirmIsSet() {
+	s.setPreviousConfig("global\nbackend users-be8080\n")
+
+	s.False(s.srv.verifyStartupConfig())
+	s.True(startup.pending)
+	s.Equal([]string{"1 lines added, 1 lines removed", "- backend users-be8080", "+ backend go-demo-be8080"}, startup.diff)
+}
+
+func (s *StartupTestSuite) Test_VerifyStartupConfig_ReturnsTrue_WhenConfigDiffersAndConfirmIsNotSet() {
+	s.setPreviousConfig("global\nbackend users-be8080\n")
+	s.srv.StartupConfirm = false
+
+	s.True(s.srv.verifyStartupConfig())
+	s.False(startup.pending)
+}
+
+func (s *StartupTestSuite) Test_VerifyStartupConfig_ReturnsTrue_WhenStoreFails() {
+	s.storeMock = getStartupStoreMock("GetStartupState")
+	s.storeMock.On("GetStartupState", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("This is an error"))
+	startupStore = s.storeMock
+
+	s.True(s.srv.verifyStartupConfig())
+}
+
+// saveStartupConfig
+
+func (s *StartupTestSuite) Test_SaveStartupConfig_StoresHashAndConfig() {
+	s.srv.verifyStartupConfig()
+
+	s.srv.saveStartupConfig()
+
+	s.storeMock.AssertCalled(s.T(), "PutStartupState", []string{"http://consul:8500"}, "my-proxy", registry.StartupState{
+		Hash:   getConfigHash("global\nbackend go-demo-be8080\n"),
+		Config: "global\nbackend go-demo-be8080\n",
+	})
+}
+
+func (s *StartupTestSuite) Test_SaveStartupConfig_DoesNotStore_WhenStartupWaitsForConfirmation() {
+	s.setPreviousConfig("global\n")
+	s.srv.verifyStartupConfig()
+
+	s.srv.saveStartupConfig()
+
+	s.storeMock.AssertNotCalled(s.T(), "PutStartupState", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *StartupTestSuite) Test_SaveStartupConfig_DoesNotStore_WhenConfigWasNotVerified() {
+	s.srv.saveStartupConfig()
+
+	s.storeMock.AssertNotCalled(s.T(), "PutStartupState", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// ServeHTTP
+
+func (s *StartupTestSuite) Test_ServeHTTP_PingReturnsStatus503_WhenStartupWaitsForConfirmation() {
+	rw, _ := s.serve("GET", "ping")
+
+	s.Equal(200, rw.Code)
+
+	startup.pending = true
+	rw, _ = s.serve("GET", "ping")
+
+	s.Equal(503, rw.Code)
+}
+
+func (s *StartupTestSuite) Test_ServeHTTP_ConfirmStartupReloadsProxyAndStoresConfig() {
+	s.setPreviousConfig("global\n")
+	s.srv.verifyStartupConfig()
+
+	rw, actual := s.serve("POST", "confirm-startup")
+
+	s.Equal(200, rw.Code)
+	s.False(actual.Pending)
+	s.Equal("1 lines added, 0 lines removed", actual.Diff[0])
+	s.proxyMock.AssertCalled(s.T(), "Reload")
+	s.storeMock.AssertCalled(s.T(), "PutStartupState", mock.Anything, mock.Anything, mock.Anything)
+	rw, _ = s.serve("GET", "ping")
+	s.Equal(200, rw.Code)
+}
+
+func (s *StartupTestSuite) Test_ServeHTTP_ConfirmStartupReturnsStatus409_WhenStartupDoesNotWait() {
+	rw, _ := s.serve("POST", "confirm-startup")
+
+	s.Equal(409, rw.Code)
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *StartupTestSuite) Test_ServeHTTP_ConfirmStartupKeepsWaiting_WhenReloadFails() {
+	s.proxyMock = getProxyMock("Reload")
+	s.proxyMock.On("Reload").Return(fmt.Errorf("This is an error"))
+	haproxy.Instance = s.proxyMock
+	startup.pending = true
+
+	rw, actual := s.serve("POST", "confirm-startup")
+
+	s.Equal(500, rw.Code)
+	s.True(actual.Pending)
+	s.True(startup.pending)
+}
+
+func (s *StartupTestSuite) Test_ServeHTTP_ConfirmStartupReturnsPendingDiff_WhenMethodIsGet() {
+	startup.pending = true
+	startup.diff = []string{"1 lines added, 0 lines removed", "+ backend go-demo-be8080"}
+
+	rw, actual := s.serve("GET", "confirm-startup")
+
+	s.Equal(200, rw.Code)
+	s.True(actual.Pending)
+	s.Equal(startup.diff, actual.Diff)
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+// getConfigDiff
+
+func (s *StartupTestSuite) Test_GetConfigDiff_IgnoresOrderAndIndentation() {
+	actual := getConfigDiff("backend a\n    server a a:80\nbackend b\n", "backend b\nbackend a\n  server a a:80\n")
+
+	s.Equal([]string{"0 lines added, 0 lines removed"}, actual)
+}
+
+func (s *StartupTestSuite) Test_GetConfigDiff_LimitsNumberOfLines() {
+	current := ""
+	for i := 0; i < startupDiffLimit+5; i++ {
+		current += fmt.Sprintf("backend be-%02d\n", i)
+	}
+
+	actual := getConfigDiff("", current)
+
+	s.Len(actual, startupDiffLimit+2)
+	s.Equal("25 lines added, 0 lines removed", actual[0])
+	s.Equal("... and 5 more", actual[len(actual)-1])
+}
+
+// Suite
+
+func TestStartupUnitTestSuite(t *testing.T) {
+	proxyOrig := haproxy.Instance
+	startupStoreOrig := startupStore
+	startupOrig := startup
+	defer func() {
+		haproxy.Instance = proxyOrig
+		startupStore = startupStoreOrig
+		startup = startupOrig
+	}()
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(StartupTestSuite))
+}
+
+// Mock
+
+type StartupStoreMock struct {
+	mock.Mock
+}
+
+func (m *StartupStoreMock) PutStartupState(addresses []string, instanceName string, state registry.StartupState) error {
+	params := m.Called(addresses, instanceName, state)
+	return params.Error(0)
+}
+
+func (m *StartupStoreMock) GetStartupState(addresses []string, instanceName string) (*registry.StartupState, error) {
+	params := m.Called(addresses, instanceName)
+	state, _ := params.Get(0).(*registry.StartupState)
+	return state, params.Error(1)
+}
+
+func getStartupStoreMock(skipMethod string) *StartupStoreMock {
+	mockObj := new(StartupStoreMock)
+	if skipMethod != "PutStartupState" {
+		mockObj.On("PutStartupState", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}
+	if skipMethod != "GetStartupState" {
+		mockObj.On("GetStartupState", mock.Anything, mock.Anything).Return(nil, nil)
+	}
+	return mockObj
+}
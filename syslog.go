@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const syslogBufferSize = 1000
+
+// syslogFrameRegexp matches RFC 3164 frames (e.g. <134>Oct 16 12:00:00 haproxy[12]: message) sent by HAProxy.
+var syslogFrameRegexp = regexp.MustCompile(`^<(\d{1,3})>(\w{3} [ \d]\d \d{2}:\d{2}:\d{2}) ([^\[: ]+)(?:\[(\d+)\])?: ?(.*)$`)
+
+var syslogOut io.Writer = os.Stdout
+var syslogListener *SyslogListener
+
+// SyslogListener receives HAProxy logs over UDP or a unix socket and writes them to stdout.
+// Lines that cannot be written fast enough are dropped so that HAProxy is never blocked.
+type SyslogListener struct {
+	Address string
+	Json    bool
+	conn    net.PacketConn
+	lines   chan string
+	dropped int64
+}
+
+// SyslogLine is the JSON representation of a log line.
+type SyslogLine struct {
+	Facility  int    `json:"facility"`
+	Severity  int    `json:"severity"`
+	Timestamp string `json:"timestamp"`
+	Program   string `json:"program"`
+	Pid       int    `json:"pid,omitempty"`
+	Message   string `json:"message"`
+}
+
+// getDroppedSyslogLines returns the number of log lines dropped since the listener was started.
+var getDroppedSyslogLines = func() int64 {
+	if syslogListener == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&syslogListener.dropped)
+}
+
+// startSyslogListener listens on a unix datagram socket if the address is a path and on UDP otherwise.
+func startSyslogListener(address string, jsonFormat bool) (*SyslogListener, error) {
+	network := "udp"
+	if strings.HasPrefix(address, "/") {
+		network = "unixgram"
+		osRemove(address)
+	}
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("Could not start the syslog listener on %s\n%s", address, err.Error())
+	}
+	l := &SyslogListener{
+		Address: conn.LocalAddr().String(),
+		Json:    jsonFormat,
+		conn:    conn,
+		lines:   make(chan string, syslogBufferSize),
+	}
+	go l.receive()
+	go l.write()
+	return l, nil
+}
+
+// Close stops the listener. Lines that were already received are still written.
+func (l *SyslogListener) Close() error {
+	return l.conn.Close()
+}
+
+func (l *SyslogListener) receive() {
+	defer close(l.lines)
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		l.enqueue(l.format(strings.TrimRight(string(buf[:n]), "\r\n\x00")))
+	}
+}
+
+func (l *SyslogListener) enqueue(line string) {
+	select {
+	case l.lines <- line:
+	default:
+		atomic.AddInt64(&l.dropped, 1)
+	}
+}
+
+func (l *SyslogListener) write() {
+	for line := range l.lines {
+		fmt.Fprintln(syslogOut, line)
+	}
+}
+
+// format strips the priority from the frame or, if JSON is requested, converts the frame into SyslogLine.
+// Frames that cannot be parsed are output as they are.
+func (l *SyslogListener) format(frame string) string {
+	parts := syslogFrameRegexp.FindStringSubmatch(frame)
+	if parts == nil {
+		return frame
+	}
+	if !l.Json {
+		return strings.SplitN(frame, ">", 2)[1]
+	}
+	priority, _ := strconv.Atoi(parts[1])
+	pid, _ := strconv.Atoi(parts[4])
+	js, _ := json.Marshal(SyslogLine{
+		Facility:  priority / 8,
+		Severity:  priority % 8,
+		Timestamp: parts[2],
+		Program:   parts[3],
+		Pid:       pid,
+		Message:   parts[5],
+	})
+	return string(js)
+}
@@ -0,0 +1,157 @@
+// +build !integration
+
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type SyslogTestSuite struct {
+	suite.Suite
+	out *syncBuffer
+}
+
+// syncBuffer is written by the listener and read by the tests concurrently.
+type syncBuffer struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+func (b *syncBuffer) waitForLines(count int) []string {
+	for i := 0; i < 100; i++ {
+		b.mu.Lock()
+		lines := append([]string{}, b.lines...)
+		b.mu.Unlock()
+		if len(lines) >= count {
+			return lines
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return []string{}
+}
+
+func (s *SyslogTestSuite) SetupTest() {
+	s.out = &syncBuffer{}
+	syslogOut = s.out
+}
+
+// startSyslogListener
+
+func (s SyslogTestSuite) Test_StartSyslogListener_WritesUdpFramesWithoutPriority() {
+	l, err := startSyslogListener("127.0.0.1:0", false)
+	s.Require().NoError(err)
+	defer l.Close()
+
+	s.sendFrames("udp", l.Address, "<134>Oct 16 12:00:00 haproxy[12]: 10.0.0.1:5000 [16/Oct/2026:12:00:00.000] services my-service-be/my-service 0/0/1/2/3 200 100 - - ---- 1/1/0/0/0 0/0 \"GET / HTTP/1.1\"\n")
+
+	s.Equal(
+		[]string{"Oct 16 12:00:00 haproxy[12]: 10.0.0.1:5000 [16/Oct/2026:12:00:00.000] services my-service-be/my-service 0/0/1/2/3 200 100 - - ---- 1/1/0/0/0 0/0 \"GET / HTTP/1.1\""},
+		s.out.waitForLines(1),
+	)
+}
+
+func (s SyslogTestSuite) Test_StartSyslogListener_WritesJson_WhenJsonIsTrue() {
+	l, err := startSyslogListener("127.0.0.1:0", true)
+	s.Require().NoError(err)
+	defer l.Close()
+
+	s.sendFrames("udp", l.Address, "<134>Oct 16 12:00:00 haproxy[12]: Proxy services started.", "<131>Oct  6 12:00:01 haproxy: Server my-service-be/my-service is DOWN")
+
+	s.Equal(
+		[]string{
+			`{"facility":16,"severity":6,"timestamp":"Oct 16 12:00:00","program":"haproxy","pid":12,"message":"Proxy services started."}`,
+			`{"facility":16,"severity":3,"timestamp":"Oct  6 12:00:01","program":"haproxy","message":"Server my-service-be/my-service is DOWN"}`,
+		},
+		s.out.waitForLines(2),
+	)
+}
+
+func (s SyslogTestSuite) Test_StartSyslogListener_WritesFramesAsTheyAre_WhenTheyCannotBeParsed() {
+	l, err := startSyslogListener("127.0.0.1:0", true)
+	s.Require().NoError(err)
+	defer l.Close()
+
+	s.sendFrames("udp", l.Address, "This is not a syslog frame")
+
+	s.Equal([]string{"This is not a syslog frame"}, s.out.waitForLines(1))
+}
+
+func (s SyslogTestSuite) Test_StartSyslogListener_ListensOnUnixSocket_WhenAddressIsPath() {
+	dir, _ := ioutil.TempDir("", "syslog")
+	defer os.RemoveAll(dir)
+	address := fmt.Sprintf("%s/haproxy-log.sock", dir)
+	l, err := startSyslogListener(address, false)
+	s.Require().NoError(err)
+	defer l.Close()
+
+	s.sendFrames("unixgram", address, "<134>Oct 16 12:00:00 haproxy[12]: Proxy services started.")
+
+	s.Equal([]string{"Oct 16 12:00:00 haproxy[12]: Proxy services started."}, s.out.waitForLines(1))
+}
+
+func (s SyslogTestSuite) Test_StartSyslogListener_ReturnsError_WhenAddressIsInvalid() {
+	_, err := startSyslogListener("this-is-not-an-address", false)
+
+	s.Error(err)
+}
+
+// enqueue
+
+func (s SyslogTestSuite) Test_Enqueue_DropsLines_WhenBufferIsFull() {
+	syslogListenerOrig := syslogListener
+	defer func() { syslogListener = syslogListenerOrig }()
+	syslogListener = &SyslogListener{lines: make(chan string, 2)}
+
+	for i := 0; i < 5; i++ {
+		syslogListener.enqueue(fmt.Sprintf("line %d", i))
+	}
+
+	s.Len(syslogListener.lines, 2)
+	s.Equal(int64(3), getDroppedSyslogLines())
+}
+
+// Run
+
+func (s SyslogTestSuite) Test_RunExecute_ReturnsError_WhenSyslogListenerCannotStart() {
+	defer func() { os.Unsetenv("SYSLOG_LISTENER") }()
+	os.Setenv("SYSLOG_LISTENER", "this-is-not-an-address")
+
+	err := NewRun().Execute([]string{})
+
+	s.Error(err)
+	s.Nil(syslogListener)
+}
+
+func (s SyslogTestSuite) sendFrames(network, address string, frames ...string) {
+	conn, err := net.Dial(network, address)
+	s.Require().NoError(err)
+	defer conn.Close()
+	for _, frame := range frames {
+		conn.Write([]byte(frame))
+	}
+}
+
+// Suite
+
+func TestSyslogUnitTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	syslogOutOrig := syslogOut
+	defer func() { syslogOut = syslogOutOrig }()
+	suite.Run(t, new(SyslogTestSuite))
+}
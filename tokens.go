@@ -0,0 +1,266 @@
+package main
+
+import (
+	"./actions"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// OperationReconfigure covers single and batch reconfigure requests.
+	OperationReconfigure = "reconfigure"
+	// OperationRemove covers remove requests.
+	OperationRemove = "remove"
+	// OperationCert covers certificate uploads and removals.
+	OperationCert = "cert"
+)
+
+// ApiToken limits the services a token can operate on and the operations it can perform.
+// Empty lists do not restrict anything.
+type ApiToken struct {
+	Name       string   `json:"name"`
+	Services   []string `json:"services"`
+	Operations []string `json:"operations"`
+}
+
+// apiTokens maps tokens to their scopes. Requests are not authorized if there are no tokens.
+var apiTokens = map[string]ApiToken{}
+var apiTokensMu = &sync.RWMutex{}
+var notifySignal = signal.Notify
+
+// loadApiTokens reads tokens from a JSON file (e.g. {"s3cr3t": {"services": ["team-a-*"], "operations": ["remove"]}})
+// and replaces the ones that are currently defined.
+func loadApiTokens(path string) error {
+	content, err := readFile(path)
+	if err != nil {
+		return fmt.Errorf("Could not read the API tokens file %s\n%s", path, err.Error())
+	}
+	data := map[string]ApiToken{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return fmt.Errorf("Could not parse the API tokens file %s\n%s", path, err.Error())
+	}
+	for token, scope := range data {
+		if len(token) == 0 {
+			return fmt.Errorf("The API tokens file %s contains an empty token", path)
+		}
+		for _, pattern := range scope.Services {
+			if _, err := matchServicePattern(pattern, ""); err != nil {
+				return fmt.Errorf("The API token %s contains the invalid pattern %s", scope.getName(token), pattern)
+			}
+		}
+		for _, operation := range scope.Operations {
+			if operation != OperationReconfigure && operation != OperationRemove && operation != OperationCert {
+				return fmt.Errorf("The API token %s contains the unknown operation %s", scope.getName(token), operation)
+			}
+		}
+	}
+	apiTokensMu.Lock()
+	defer apiTokensMu.Unlock()
+	apiTokens = data
+	return nil
+}
+
+// reloadApiTokensOnSignal reloads the tokens every time a SIGHUP is received. Tokens are kept if the file is invalid.
+func reloadApiTokensOnSignal(path string) {
+	c := make(chan os.Signal, 1)
+	notifySignal(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			if err := loadApiTokens(path); err != nil {
+				log.Printf(err.Error())
+			} else {
+				log.Printf("Reloaded the API tokens from %s", path)
+			}
+		}
+	}()
+}
+
+// getName returns the name of the token or, if it is not set, the beginning of the token so that secrets are not
+// written to logs.
+func (t ApiToken) getName(token string) string {
+	if len(t.Name) > 0 {
+		return t.Name
+	}
+	if len(token) > 4 {
+		return token[:4] + "..."
+	}
+	return "..."
+}
+
+func (t ApiToken) allowsOperation(operation string) bool {
+	if len(t.Operations) == 0 {
+		return true
+	}
+	for _, o := range t.Operations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func (t ApiToken) allowsService(serviceName string) bool {
+	if len(t.Services) == 0 {
+		return true
+	}
+	for _, pattern := range t.Services {
+		if matched, _ := matchServicePattern(actions.CanonicalServiceName(pattern), serviceName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matchServicePattern(pattern, serviceName string) (bool, error) {
+	return path.Match(pattern, serviceName)
+}
+
+// getRequestOperation returns the operation performed by the request and the names of the affected services.
+// Requests that are not restricted return an empty operation.
+func getRequestOperation(req *http.Request) (string, []string) {
+	switch req.URL.Path {
+	case "/v1/docker-flow-proxy/reconfigure", "/v2/docker-flow-proxy/reconfigure":
+		query := req.URL.Query()
+		// The body is parsed the same way reconfigureJson parses it
+		if (req.Method == "POST" || req.Method == "PUT") && strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+			jsonQuery, isBatch, _ := getReconfigureJsonQuery(req)
+			if isBatch {
+				return OperationReconfigure, getBatchServiceNames(req)
+			}
+			query = jsonQuery
+		}
+		return OperationReconfigure, []string{actions.CanonicalServiceName(query.Get("serviceName"))}
+	case "/v1/docker-flow-proxy/remove", "/v2/docker-flow-proxy/remove":
+		names := []string{}
+		for _, name := range getRemoveServiceNames(req) {
+			names = append(names, actions.CanonicalServiceName(name))
+		}
+		if len(names) == 0 {
+			names = append(names, "")
+		}
+		return OperationRemove, names
+	case "/v1/docker-flow-proxy/stack":
+		if req.Method == "DELETE" {
+			names := []string{}
+			for _, sr := range getStackServices(req.URL.Query().Get("name")) {
+				names = append(names, sr.ServiceName)
+			}
+			return OperationRemove, names
+		}
+	case "/v1/docker-flow-proxy/import-config":
+		// Dry runs do not change anything
+		if dryRun, _ := strconv.ParseBool(req.URL.Query().Get("dryRun")); req.Method == "POST" && !dryRun {
+			return OperationReconfigure, getImportServiceNames(req)
+		}
+	case "/v1/docker-flow-proxy/confirm-startup":
+		if req.Method == "POST" {
+			return OperationReconfigure, []string{}
+		}
+	case "/v1/docker-flow-proxy/resync":
+		return OperationReconfigure, []string{}
+	case "/v1/docker-flow-proxy/gc":
+		// Dry runs do not change anything
+		if dryRun, _ := strconv.ParseBool(req.URL.Query().Get("dryRun")); req.Method == "POST" && !dryRun {
+			return OperationReconfigure, []string{}
+		}
+	case "/v1/docker-flow-proxy/challenge":
+		if req.Method == "PUT" || req.Method == "DELETE" {
+			return OperationCert, []string{}
+		}
+	case "/v1/docker-flow-proxy/cert":
+		if req.Method == "PUT" || req.Method == "DELETE" {
+			return OperationCert, []string{}
+		}
+	case "/v1/docker-flow-proxy/certs":
+		if req.Method == "PUT" {
+			return OperationCert, []string{}
+		}
+	}
+	return "", nil
+}
+
+// getBatchServiceNames reads the service names from a batch. The body is restored so that it can be read again.
+// Services that cannot be decoded are returned with empty names.
+func getBatchServiceNames(req *http.Request) []string {
+	body, _ := ioutil.ReadAll(req.Body)
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	batch := BatchRequest{}
+	decodeJson(body, &batch)
+	names := []string{}
+	for _, params := range batch.Services {
+		name := ""
+		if query, err := getJsonQuery(params); err == nil {
+			name = query.Get("serviceName")
+		}
+		names = append(names, actions.CanonicalServiceName(name))
+	}
+	return names
+}
+
+// getRequestToken returns the token sent through the Authorization header as a bearer token.
+func getRequestToken(req *http.Request) string {
+	header := req.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// authorize returns the status and the reason why the request is denied. The status is zero if the request is
+// allowed. All requests are allowed if there are no tokens.
+func authorize(req *http.Request) (int, string) {
+	operation, services := getRequestOperation(req)
+	if len(operation) == 0 {
+		return 0, ""
+	}
+	apiTokensMu.RLock()
+	defer apiTokensMu.RUnlock()
+	if len(apiTokens) == 0 {
+		return 0, ""
+	}
+	token := getRequestToken(req)
+	scope, ok := apiTokens[token]
+	if len(token) == 0 || !ok {
+		return http.StatusUnauthorized, fmt.Sprintf("The %s operation requires a valid token in the Authorization header", operation)
+	}
+	name := scope.getName(token)
+	if !scope.allowsOperation(operation) {
+		return http.StatusForbidden, fmt.Sprintf("The token %s is not allowed to perform the %s operation", name, operation)
+	} else if len(services) == 0 && len(scope.Services) > 0 {
+		// Requests without services affect the whole proxy
+		return http.StatusForbidden, fmt.Sprintf(
+			"The token %s is not allowed to perform the %s operation on all services. Allowed services: %s",
+			name,
+			operation,
+			strings.Join(scope.Services, ", "),
+		)
+	}
+	for _, service := range services {
+		if !scope.allowsService(service) {
+			return http.StatusForbidden, fmt.Sprintf(
+				"The token %s is not allowed to %s the service %s. Allowed services: %s",
+				name,
+				operation,
+				service,
+				strings.Join(scope.Services, ", "),
+			)
+		}
+	}
+	if len(services) > 0 {
+		log.Printf("The token %s performs the %s operation on %s", name, operation, strings.Join(services, ", "))
+	} else {
+		log.Printf("The token %s performs the %s operation", name, operation)
+	}
+	return 0, ""
+}
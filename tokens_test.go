@@ -0,0 +1,314 @@
+// +build !integration
+
+package main
+
+import (
+	"./actions"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type TokensTestSuite struct {
+	suite.Suite
+	tokens string
+}
+
+func (s *TokensTestSuite) SetupTest() {
+	s.tokens = `{
+		"team-a-token": {"name": "team-a", "services": ["team-a-*", "shared-?"], "operations": ["reconfigure", "remove"]},
+		"team-b-token": {"services": ["team-b-*"], "operations": ["reconfigure"]},
+		"admin-token": {"name": "admin"}
+	}`
+	apiTokens = map[string]ApiToken{}
+	readFile = func(fileName string) ([]byte, error) {
+		return []byte(s.tokens), nil
+	}
+}
+
+func (s *TokensTestSuite) TearDownTest() {
+	apiTokens = map[string]ApiToken{}
+}
+
+func (s TokensTestSuite) getRequest(method, url, token string) *http.Request {
+	req, _ := http.NewRequest(method, url, nil)
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// loadApiTokens
+
+func (s TokensTestSuite) Test_LoadApiTokens_ReadsTokensFromFile() {
+	actualPath := ""
+	readFile = func(fileName string) ([]byte, error) {
+		actualPath = fileName
+		return []byte(s.tokens), nil
+	}
+
+	err := loadApiTokens("/run/secrets/tokens.json")
+
+	s.NoError(err)
+	s.Equal("/run/secrets/tokens.json", actualPath)
+	s.Len(apiTokens, 3)
+	s.Equal(ApiToken{Services: []string{"team-b-*"}, Operations: []string{"reconfigure"}}, apiTokens["team-b-token"])
+}
+
+func (s TokensTestSuite) Test_LoadApiTokens_ReturnsError_WhenTokensAreInvalid() {
+	for _, tokens := range []string{
+		`not json`,
+		`{"": {}}`,
+		`{"my-token": {"services": ["team-[a"]}}`,
+		`{"my-token": {"operations": ["delete"]}}`,
+	} {
+		content := tokens
+		readFile = func(fileName string) ([]byte, error) {
+			return []byte(content), nil
+		}
+
+		err := loadApiTokens("/run/secrets/tokens.json")
+
+		s.Error(err, tokens)
+		s.Empty(apiTokens, tokens)
+	}
+}
+
+func (s TokensTestSuite) Test_LoadApiTokens_ReturnsError_WhenFileCannotBeRead() {
+	readFile = func(fileName string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+
+	s.Error(loadApiTokens("/run/secrets/tokens.json"))
+}
+
+// reloadApiTokensOnSignal
+
+func (s TokensTestSuite) Test_ReloadApiTokensOnSignal_ReloadsTokens_WhenSighupIsReceived() {
+	notifySignalOrig := notifySignal
+	defer func() { notifySignal = notifySignalOrig }()
+	var actualChannel chan<- os.Signal
+	var actualSignals []os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) {
+		actualChannel = c
+		actualSignals = sig
+	}
+
+	reloadApiTokensOnSignal("/run/secrets/tokens.json")
+	actualChannel <- syscall.SIGHUP
+
+	s.Equal([]os.Signal{syscall.SIGHUP}, actualSignals)
+	for i := 0; i < 100; i++ {
+		apiTokensMu.RLock()
+		count := len(apiTokens)
+		apiTokensMu.RUnlock()
+		if count > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Len(apiTokens, 3)
+}
+
+// authorize
+
+func (s TokensTestSuite) Test_Authorize_AllowsAllRequests_WhenThereAreNoTokens() {
+	for _, req := range []*http.Request{
+		s.getRequest("GET", "/v1/docker-flow-proxy/reconfigure?serviceName=my-service&servicePath=/api", ""),
+		s.getRequest("GET", "/v1/docker-flow-proxy/remove?serviceName=my-service", ""),
+		s.getRequest("PUT", "/v1/docker-flow-proxy/cert?certName=my-cert.pem", ""),
+	} {
+		status, _ := authorize(req)
+
+		s.Equal(0, status, req.URL.String())
+	}
+}
+
+func (s TokensTestSuite) Test_Authorize_AllowsUnrestrictedEndpoints() {
+	loadApiTokens("/run/secrets/tokens.json")
+
+	for _, url := range []string{"/v1/docker-flow-proxy/config", "/v1/docker-flow-proxy/services", "/v1/test"} {
+		status, _ := authorize(s.getRequest("GET", url, ""))
+
+		s.Equal(0, status, url)
+	}
+}
+
+func (s TokensTestSuite) Test_Authorize_Returns401_WhenTokenIsMissingOrUnknown() {
+	loadApiTokens("/run/secrets/tokens.json")
+
+	for _, token := range []string{"", "unknown-token"} {
+		status, msg := authorize(s.getRequest("GET", "/v1/docker-flow-proxy/remove?serviceName=team-a-api", token))
+
+		s.Equal(http.StatusUnauthorized, status)
+		s.Equal("The remove operation requires a valid token in the Authorization header", msg)
+	}
+}
+
+func (s TokensTestSuite) Test_Authorize_MatchesServiceNamesAgainstGlobs() {
+	loadApiTokens("/run/secrets/tokens.json")
+	cases := []struct {
+		serviceName string
+		expected    int
+	}{
+		{"team-a-api", 0},
+		{"team-a-", 0},
+		{"shared-1", 0},
+		{"shared-10", http.StatusForbidden},
+		{"team-b-api", http.StatusForbidden},
+		{"my-team-a-api", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		status, _ := authorize(s.getRequest("GET", "/v1/docker-flow-proxy/reconfigure?serviceName="+c.serviceName, "team-a-token"))
+
+		s.Equal(c.expected, status, c.serviceName)
+	}
+}
+
+func (s TokensTestSuite) Test_Authorize_ReturnsDeniedService() {
+	loadApiTokens("/run/secrets/tokens.json")
+
+	_, msg := authorize(s.getRequest("GET", "/v2/docker-flow-proxy/remove?serviceName=team-b-api", "team-a-token"))
+
+	s.Equal("The token team-a is not allowed to remove the service team-b-api. Allowed services: team-a-*, shared-?", msg)
+}
+
+func (s TokensTestSuite) Test_Authorize_RestrictsOperations() {
+	loadApiTokens("/run/secrets/tokens.json")
+	cases := []struct {
+		req      *http.Request
+		expected int
+	}{
+		{s.getRequest("GET", "/v1/docker-flow-proxy/reconfigure?serviceName=team-b-api", "team-b-token"), 0},
+		{s.getRequest("GET", "/v1/docker-flow-proxy/remove?serviceName=team-b-api", "team-b-token"), http.StatusForbidden},
+		{s.getRequest("PUT", "/v1/docker-flow-proxy/cert?certName=my-cert.pem", "team-a-token"), http.StatusForbidden},
+		{s.getRequest("PUT", "/v1/docker-flow-proxy/cert?certName=my-cert.pem", "admin-token"), 0},
+		{s.getRequest("DELETE", "/v1/docker-flow-proxy/cert?certName=my-cert.pem", "team-a-token"), http.StatusForbidden},
+		{s.getRequest("PUT", "/v1/docker-flow-proxy/certs", "team-a-token"), http.StatusForbidden},
+		{s.getRequest("GET", "/v1/docker-flow-proxy/certs", ""), 0},
+		{s.getRequest("GET", "/v1/docker-flow-proxy/remove?serviceName=any-service", "admin-token"), 0},
+		{s.getRequest("POST", "/v1/docker-flow-proxy/confirm-startup", ""), http.StatusUnauthorized},
+		{s.getRequest("POST", "/v1/docker-flow-proxy/confirm-startup", "team-b-token"), http.StatusForbidden},
+		{s.getRequest("POST", "/v1/docker-flow-proxy/confirm-startup", "admin-token"), 0},
+		{s.getRequest("GET", "/v1/docker-flow-proxy/confirm-startup", ""), 0},
+		{s.getRequest("GET", "/v1/docker-flow-proxy/resync", ""), http.StatusUnauthorized},
+		{s.getRequest("GET", "/v1/docker-flow-proxy/resync", "team-b-token"), http.StatusForbidden},
+		{s.getRequest("GET", "/v1/docker-flow-proxy/resync", "admin-token"), 0},
+		{s.getRequest("POST", "/v1/docker-flow-proxy/gc", ""), http.StatusUnauthorized},
+		{s.getRequest("POST", "/v1/docker-flow-proxy/gc", "team-b-token"), http.StatusForbidden},
+		{s.getRequest("POST", "/v1/docker-flow-proxy/gc", "admin-token"), 0},
+		{s.getRequest("POST", "/v1/docker-flow-proxy/gc?dryRun=true", ""), 0},
+	}
+	for _, c := range cases {
+		status, msg := authorize(c.req)
+
+		s.Equal(c.expected, status, msg)
+	}
+}
+
+func (s TokensTestSuite) Test_Authorize_ReturnsForbidden_WhenTokenLimitedToServicesPerformsProxyWideOperation() {
+	loadApiTokens("/run/secrets/tokens.json")
+
+	status, msg := authorize(s.getRequest("GET", "/v1/docker-flow-proxy/resync", "team-b-token"))
+
+	s.Equal(http.StatusForbidden, status)
+	s.Equal("The token team... is not allowed to perform the reconfigure operation on all services. Allowed services: team-b-*", msg)
+}
+
+func (s TokensTestSuite) Test_Authorize_ChecksAllServicesOfBatch() {
+	loadApiTokens("/run/secrets/tokens.json")
+	body := `{"generation": 1, "services": [{"serviceName": "team-a-api"}, {"serviceName": "team-b-api"}]}`
+	req, _ := http.NewRequest("POST", "/v1/docker-flow-proxy/reconfigure", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer team-a-token")
+
+	status, msg := authorize(req)
+
+	s.Equal(http.StatusForbidden, status)
+	s.Contains(msg, "team-b-api")
+	s.Equal([]string{"team-a-api", "team-b-api"}, getBatchServiceNames(req))
+}
+
+func (s TokensTestSuite) Test_Authorize_ChecksServiceOfJsonBody() {
+	loadApiTokens("/run/secrets/tokens.json")
+	cases := []struct {
+		method   string
+		query    string
+		body     string
+		expected int
+	}{
+		{"POST", "", `{"serviceName": "team-a-api", "servicePath": "/api"}`, 0},
+		{"PUT", "", `{"serviceName": "team-a-api", "servicePath": "/api"}`, 0},
+		{"POST", "", `{"serviceName": "team-b-api", "servicePath": "/api"}`, http.StatusForbidden},
+		{"PUT", "", `{"serviceName": "team-b-api", "servicePath": "/api"}`, http.StatusForbidden},
+		{"PUT", "?serviceName=team-b-api", `{"serviceName": "team-a-api", "servicePath": "/api"}`, http.StatusForbidden},
+		{"PUT", "?serviceName=team-a-api", `{"serviceName": "team-b-api", "servicePath": "/api"}`, 0},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, "/v1/docker-flow-proxy/reconfigure"+c.query, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer team-a-token")
+
+		status, _ := authorize(req)
+
+		s.Equal(c.expected, status, c.method+" "+c.query+" "+c.body)
+		body, _ := ioutil.ReadAll(req.Body)
+		s.Equal(c.body, string(body))
+	}
+}
+
+func (s TokensTestSuite) Test_Authorize_ChecksAllServicesOfRemove() {
+	loadApiTokens("/run/secrets/tokens.json")
+	req := s.getRequest("GET", "/v1/docker-flow-proxy/remove?serviceName=team-a-api,team-b-api&serviceName=shared-1", "team-a-token")
+
+	status, msg := authorize(req)
+
+	s.Equal(http.StatusForbidden, status)
+	s.Contains(msg, "team-b-api")
+}
+
+func (s TokensTestSuite) Test_Authorize_ChecksAllServicesOfStack() {
+	loadApiTokens("/run/secrets/tokens.json")
+	getServicesOrig := getServices
+	defer func() { getServices = getServicesOrig }()
+	getServices = func() []actions.ServiceReconfigure {
+		return []actions.ServiceReconfigure{{ServiceName: "team-a-api", StackName: "shop"}, {ServiceName: "shop_web"}}
+	}
+	req := s.getRequest("DELETE", "/v1/docker-flow-proxy/stack?name=shop", "team-a-token")
+
+	status, msg := authorize(req)
+
+	s.Equal(http.StatusForbidden, status)
+	s.Contains(msg, "shop_web")
+}
+
+// ServeHTTP
+
+func (s TokensTestSuite) Test_ServeHTTP_ReturnsStatus403_WhenTokenIsNotAllowed() {
+	loadApiTokens("/run/secrets/tokens.json")
+	rw := httptest.NewRecorder()
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, s.getRequest("GET", "/v1/docker-flow-proxy/remove?serviceName=team-b-api", "team-a-token"))
+
+	s.Equal(http.StatusForbidden, rw.Code)
+	s.Contains(rw.Body.String(), `"Status":"NOK"`)
+	s.Contains(rw.Body.String(), "Allowed services: team-a-*, shared-?")
+}
+
+// Suite
+
+func TestTokensUnitTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	readFileOrig := readFile
+	defer func() { readFile = readFileOrig }()
+	suite.Run(t, new(TokensTestSuite))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// uiPath is the address of the UI. Its assets are served beneath it.
+const uiPath = "/v1/docker-flow-proxy/ui"
+
+//go:embed ui
+var uiAssets embed.FS
+
+var uiContentTypes = map[string]string{
+	".html": "text/html; charset=utf-8",
+	".js":   "application/javascript; charset=utf-8",
+	".css":  "text/css; charset=utf-8",
+}
+
+// ui serves the page that lists the services, the certificates and the state of the proxy read from the other
+// endpoints. The assets do not hold any data so they are served without a token. Requests of the page to the endpoints
+// are authorized as any other. Assets are revalidated through their ETag so that an upgraded proxy is never shown with
+// the assets of the previous version.
+func (m *Serve) ui(w http.ResponseWriter, req *http.Request) {
+	if strings.EqualFold(os.Getenv("ENABLE_UI"), "false") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if req.Method != "GET" && req.Method != "HEAD" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, uiPath), "/")
+	if len(name) == 0 {
+		name = "index.html"
+	}
+	content, err := uiAssets.ReadFile(path.Join("ui", name))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	sum := sha256.Sum256(content)
+	httpWriterSetContentType(w, uiContentTypes[path.Ext(name)])
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'")
+	http.ServeContent(w, req, name, time.Time{}, bytes.NewReader(content))
+}
@@ -0,0 +1,145 @@
+// The UI only reads the JSON endpoints of the proxy. The token, if the API asks for one, is kept for the session.
+(function () {
+    "use strict";
+
+    var api = "/v1/docker-flow-proxy";
+    var tokenKey = "docker-flow-proxy-token";
+
+    var views = {
+        services: {
+            path: "/services",
+            columns: ["serviceName", "aclName", "servicePath", "serviceDomain", "port", "certName", "proxyRole", "applied", "maintenance"],
+            rows: function (data) {
+                return data;
+            }
+        },
+        certs: {
+            path: "/certs",
+            columns: ["ProxyServiceName", "CommonName", "DNSNames", "NotAfter", "Default", "Size", "Fingerprint", "Error"],
+            rows: function (data) {
+                return data.Certs || [];
+            }
+        },
+        info: {
+            path: "/info",
+            columns: ["Field", "Value"],
+            rows: function (data) {
+                return flatten("", data, []);
+            }
+        }
+    };
+    var current = "services";
+    var rows = [];
+
+    // flatten turns nested objects into Field and Value rows (e.g. Consul.Active).
+    function flatten(prefix, value, result) {
+        if (value !== null && typeof value === "object" && !Array.isArray(value)) {
+            Object.keys(value).forEach(function (key) {
+                flatten(prefix ? prefix + "." + key : key, value[key], result);
+            });
+        } else {
+            result.push({Field: prefix, Value: value});
+        }
+        return result;
+    }
+
+    // request reads the endpoint and asks for a token once if the API does not authorize the request.
+    function request(path, retried) {
+        var headers = {"Accept": "application/json"};
+        var token = sessionStorage.getItem(tokenKey);
+        if (token) {
+            headers.Authorization = "Bearer " + token;
+        }
+        return fetch(api + path, {headers: headers, credentials: "same-origin"}).then(function (resp) {
+            if ((resp.status === 401 || resp.status === 403) && !retried) {
+                var entered = window.prompt("The API requires a token");
+                if (entered) {
+                    sessionStorage.setItem(tokenKey, entered.trim());
+                    return request(path, true);
+                }
+            }
+            if (!resp.ok) {
+                throw new Error(api + path + " responded with the status " + resp.status);
+            }
+            return resp.json();
+        });
+    }
+
+    function format(value) {
+        if (value === undefined || value === null) {
+            return "";
+        } else if (Array.isArray(value)) {
+            return value.join(", ");
+        } else if (typeof value === "object") {
+            return JSON.stringify(value);
+        }
+        return String(value);
+    }
+
+    function render() {
+        var view = views[current];
+        var filter = document.getElementById("filter").value.toLowerCase();
+        var head = document.querySelector("#table thead");
+        var body = document.querySelector("#table tbody");
+        var headRow = document.createElement("tr");
+        view.columns.forEach(function (column) {
+            var th = document.createElement("th");
+            th.textContent = column;
+            headRow.appendChild(th);
+        });
+        head.replaceChildren(headRow);
+        var matching = rows.filter(function (row) {
+            return view.columns.some(function (column) {
+                return format(row[column]).toLowerCase().indexOf(filter) >= 0;
+            });
+        });
+        body.replaceChildren.apply(body, matching.map(function (row) {
+            var tr = document.createElement("tr");
+            view.columns.forEach(function (column) {
+                var td = document.createElement("td");
+                td.textContent = format(row[column]);
+                if (column === "Error" && row[column]) {
+                    td.className = "error";
+                }
+                tr.appendChild(td);
+            });
+            return tr;
+        }));
+        document.getElementById("empty").hidden = matching.length > 0;
+    }
+
+    function load() {
+        var name = current;
+        var error = document.getElementById("error");
+        request(views[name].path).then(function (data) {
+            // Responses of the views that are no longer selected are dropped
+            if (name !== current) {
+                return;
+            }
+            error.hidden = true;
+            rows = views[name].rows(data);
+            render();
+        }).catch(function (err) {
+            if (name !== current) {
+                return;
+            }
+            error.textContent = err.message;
+            error.hidden = false;
+            rows = [];
+            render();
+        });
+    }
+
+    document.querySelectorAll("nav button").forEach(function (button) {
+        button.addEventListener("click", function () {
+            document.querySelectorAll("nav button").forEach(function (b) {
+                b.classList.toggle("active", b === button);
+            });
+            current = button.getAttribute("data-view");
+            load();
+        });
+    });
+    document.getElementById("filter").addEventListener("input", render);
+    document.getElementById("refresh").addEventListener("click", load);
+    load();
+})();
@@ -0,0 +1,126 @@
+// +build !integration
+
+package main
+
+import (
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+type UiTestSuite struct {
+	suite.Suite
+	serve *Serve
+}
+
+func (s *UiTestSuite) SetupTest() {
+	s.serve = &Serve{}
+}
+
+func (s *UiTestSuite) get(path string, header http.Header) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8080"+path, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	s.serve.ServeHTTP(rw, req)
+	return rw
+}
+
+// ui
+
+func (s *UiTestSuite) Test_Ui_ServesAssets() {
+	cases := map[string]string{
+		"/v1/docker-flow-proxy/ui":            "text/html; charset=utf-8",
+		"/v1/docker-flow-proxy/ui/":           "text/html; charset=utf-8",
+		"/v1/docker-flow-proxy/ui/index.html": "text/html; charset=utf-8",
+		"/v1/docker-flow-proxy/ui/app.js":     "application/javascript; charset=utf-8",
+		"/v1/docker-flow-proxy/ui/style.css":  "text/css; charset=utf-8",
+	}
+	for path, contentType := range cases {
+		rw := s.get(path, nil)
+
+		s.Equal(200, rw.Code, path)
+		s.Equal(contentType, rw.Header().Get("Content-Type"), path)
+		s.Equal("no-cache", rw.Header().Get("Cache-Control"), path)
+		s.Equal("nosniff", rw.Header().Get("X-Content-Type-Options"), path)
+		s.NotEmpty(rw.Header().Get("ETag"), path)
+		s.NotEmpty(rw.Body.String(), path)
+	}
+}
+
+func (s *UiTestSuite) Test_Ui_IndexReferencesAssetsAndEndpoints() {
+	index := s.get("/v1/docker-flow-proxy/ui", nil).Body.String()
+	app := s.get("/v1/docker-flow-proxy/ui/app.js", nil).Body.String()
+
+	s.Contains(index, `src="/v1/docker-flow-proxy/ui/app.js"`)
+	s.Contains(index, `href="/v1/docker-flow-proxy/ui/style.css"`)
+	for _, path := range []string{`"/services"`, `"/certs"`, `"/info"`, `"Bearer "`} {
+		s.Contains(app, path)
+	}
+}
+
+func (s *UiTestSuite) Test_Ui_ReturnsStatus304_WhenAssetIsNotModified() {
+	etag := s.get("/v1/docker-flow-proxy/ui/app.js", nil).Header().Get("ETag")
+
+	rw := s.get("/v1/docker-flow-proxy/ui/app.js", http.Header{"If-None-Match": []string{etag}})
+
+	s.Equal(304, rw.Code)
+	s.Empty(rw.Body.String())
+}
+
+func (s *UiTestSuite) Test_Ui_ReturnsStatus404_WhenUiIsDisabled() {
+	defer os.Unsetenv("ENABLE_UI")
+	os.Setenv("ENABLE_UI", "false")
+
+	for _, path := range []string{"/v1/docker-flow-proxy/ui", "/v1/docker-flow-proxy/ui/app.js"} {
+		rw := s.get(path, nil)
+
+		s.Equal(404, rw.Code, path)
+		s.Empty(rw.Body.String(), path)
+	}
+}
+
+func (s *UiTestSuite) Test_Ui_ReturnsStatus404_WhenAssetDoesNotExist() {
+	for _, path := range []string{"/v1/docker-flow-proxy/ui/missing.js", "/v1/docker-flow-proxy/ui/../../../ui.go", "/v1/docker-flow-proxy/ui/%2e%2e/ui.go"} {
+		rw := s.get(path, nil)
+
+		s.Equal(404, rw.Code, path)
+	}
+}
+
+func (s *UiTestSuite) Test_Ui_ReturnsStatus405_WhenMethodIsNotGet() {
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8080/v1/docker-flow-proxy/ui", strings.NewReader(""))
+
+	s.serve.ServeHTTP(rw, req)
+
+	s.Equal(405, rw.Code)
+}
+
+func (s *UiTestSuite) Test_Ui_ServesAssetsWithoutToken_WhenApiTokensAreSet() {
+	apiTokensOrig := apiTokens
+	defer func() { apiTokens = apiTokensOrig }()
+	apiTokens = map[string]ApiToken{"s3cr3t": {}}
+
+	rw := s.get("/v1/docker-flow-proxy/ui", nil)
+
+	s.Equal(200, rw.Code)
+}
+
+// Suite
+
+func TestUiUnitTestSuite(t *testing.T) {
+	httpWriterSetContentTypeOrig := httpWriterSetContentType
+	defer func() { httpWriterSetContentType = httpWriterSetContentTypeOrig }()
+	log.SetOutput(ioutil.Discard)
+	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
+		w.Header().Set("Content-Type", value)
+	}
+	suite.Run(t, new(UiTestSuite))
+}
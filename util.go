@@ -1,10 +1,10 @@
 package main
 
 import (
+	"./actions"
+	"./proxy"
 	"./registry"
 	"io/ioutil"
-	"log"
-	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -13,15 +13,16 @@ import (
 
 var readTemplateFile = ioutil.ReadFile
 var readFile = ioutil.ReadFile
+var readDir = ioutil.ReadDir
 var writeFeTemplate = ioutil.WriteFile
 var writeBeTemplate = ioutil.WriteFile
 var osRemove = os.Remove
+var writeDomainMap = actions.WriteDomainMap
 var httpListenAndServe = http.ListenAndServe
 var httpWriterSetContentType = func(w http.ResponseWriter, value string) {
 	w.Header().Set("Content-Type", value)
 }
 var httpGet = http.Get
-var logPrintf = log.Printf
 
 type Executable interface {
 	Execute(args []string) error
@@ -31,6 +32,26 @@ func isSwarm(mode string) bool {
 	return strings.EqualFold(mode, "service") || strings.EqualFold(mode, "swarm")
 }
 
-var lookupHost = net.LookupHost
 var mu = &sync.Mutex{}
-var registryInstance registry.Registrarable = registry.Consul{}
+var registryInstance registry.Registrarable = registry.NewMetricsRegistry(registry.Consul{})
+var migrateRegistry = registry.Consul{}.Migrate
+var migrateServiceNames = registry.Consul{}.MigrateServiceNames
+var loadProfiles = actions.LoadProfiles
+var loadReloadHooks = proxy.LoadReloadHooks
+var collectGarbage = actions.CollectGarbage
+var getLastReload = proxy.GetLastReload
+var getReloadTotals = proxy.GetReloadTotals
+var getDeferredReload = proxy.GetDeferredReload
+var flushDeferredReload = proxy.FlushDeferredReload
+var getServices = actions.GetServices
+var getUnappliedServices = actions.GetUnappliedServices
+var simulate = actions.Simulate
+var createStandaloneConfig = proxy.CreateStandaloneConfig
+var getCerts = func() map[string]string {
+	if proxy.Instance == nil {
+		return map[string]string{}
+	}
+	return proxy.Instance.GetCerts()
+}
+var getConsulStatus = registry.GetConsulStatus
+var startConsulProbe = registry.StartConsulProbe